@@ -2,8 +2,12 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 
+	"github.com/nickcecere/btcx/internal/answercache"
 	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
 	"github.com/nickcecere/btcx/internal/resource"
 	"github.com/spf13/cobra"
 )
@@ -11,7 +15,7 @@ import (
 func cacheCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "cache",
-		Short: "Manage the resource cache",
+		Short: i18n.T("cache.short"),
 		Long:  `View and clear cached resources.`,
 	}
 
@@ -25,7 +29,7 @@ func cacheCmd() *cobra.Command {
 func cacheListCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "list",
-		Short: "List cached resources",
+		Short: i18n.T("cache.list.short"),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, _, err := config.Load()
 			if err != nil {
@@ -57,12 +61,16 @@ func cacheListCmd() *cobra.Command {
 func cacheClearCmd() *cobra.Command {
 	var resourceName string
 	var all bool
+	var answers bool
+	var responses bool
 
 	cmd := &cobra.Command{
 		Use:   "clear",
-		Short: "Clear the cache",
+		Short: i18n.T("cache.clear.short"),
 		Example: `  btcx cache clear --all
-  btcx cache clear -r svelte`,
+  btcx cache clear -r svelte
+  btcx cache clear --answers
+  btcx cache clear --responses`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, _, err := config.Load()
 			if err != nil {
@@ -71,7 +79,20 @@ func cacheClearCmd() *cobra.Command {
 
 			mgr := resource.NewManager(cfg.Cache.ResolvedPath)
 
-			if resourceName != "" {
+			if answers {
+				// Clear cached answers only
+				cache := answercache.New(filepath.Join(cfg.Cache.ResolvedPath, "answers"), 0)
+				if err := cache.Clear(); err != nil {
+					return err
+				}
+				fmt.Println("Answer cache cleared.")
+			} else if responses {
+				// Clear cached model responses only
+				if err := os.RemoveAll(filepath.Join(cfg.Cache.ResolvedPath, "responses")); err != nil {
+					return fmt.Errorf("failed to clear response cache: %w", err)
+				}
+				fmt.Println("Response cache cleared.")
+			} else if resourceName != "" {
 				// Clear specific resource
 				if err := mgr.Clear(resourceName); err != nil {
 					return fmt.Errorf("failed to clear %s: %w", resourceName, err)
@@ -84,7 +105,7 @@ func cacheClearCmd() *cobra.Command {
 				}
 				fmt.Println("Cache cleared.")
 			} else {
-				return fmt.Errorf("specify --all or -r <resource>")
+				return fmt.Errorf("specify --all, --answers, --responses or -r <resource>")
 			}
 
 			return nil
@@ -93,6 +114,8 @@ func cacheClearCmd() *cobra.Command {
 
 	cmd.Flags().StringVarP(&resourceName, "resource", "r", "", "Resource to clear")
 	cmd.Flags().BoolVar(&all, "all", false, "Clear all cached resources")
+	cmd.Flags().BoolVar(&answers, "answers", false, "Clear cached answers")
+	cmd.Flags().BoolVar(&responses, "responses", false, "Clear cached model responses")
 
 	return cmd
 }
@@ -100,7 +123,7 @@ func cacheClearCmd() *cobra.Command {
 func cachePathCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "path",
-		Short: "Show cache directory path",
+		Short: i18n.T("cache.path.short"),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, _, err := config.Load()
 			if err != nil {
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/agent"
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/resource"
+	"github.com/nickcecere/btcx/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func cheatsheetCmd() *cobra.Command {
+	var resources []string
+	var topic string
+	var modelName string
+	var noSpinner bool
+	var noAnswerCache bool
+	var exportPath string
+
+	cmd := &cobra.Command{
+		Use:   "cheatsheet",
+		Short: i18n.T("cheatsheet.short"),
+		Long: `Generate a condensed API cheatsheet (signatures, minimal examples and gotchas)
+for a resource, optionally narrowed to a topic. The agent searches the code like
+ask does, so entries are based on the actual source.`,
+		Example: `  btcx cheatsheet -r sveltekit --topic routing
+  btcx cheatsheet -r cobra --export cobra-cheatsheet.md
+  btcx cheatsheet -r react -r react-dom --topic hooks -m claude`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, paths, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if len(resources) == 0 {
+				return fmt.Errorf("at least one resource is required (-r flag)")
+			}
+
+			modelCfg, err := cfg.GetModelConfig(modelName)
+			if err != nil {
+				return fmt.Errorf("failed to get model: %w", err)
+			}
+
+			var configResources []*config.Resource
+			for _, name := range resources {
+				r, ok := cfg.GetResource(name)
+				if !ok {
+					return fmt.Errorf("resource %q not found in config", name)
+				}
+				configResources = append(configResources, r)
+			}
+
+			fmt.Fprintf(os.Stderr, "Preparing resources...\n")
+			mgr := resource.NewManager(cfg.Cache.ResolvedPath).WithLockFile(cfg.ResolvedLockFile)
+			collection, err := mgr.EnsureCollection(context.Background(), configResources)
+			if err != nil {
+				return fmt.Errorf("failed to prepare resources: %w", err)
+			}
+
+			a, err := agent.New(agent.Options{
+				Config:        cfg,
+				ModelConfig:   modelCfg,
+				Collection:    collection,
+				DataDir:       paths.DataDir,
+				NoAnswerCache: noAnswerCache,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create agent: %w", err)
+			}
+
+			var spinner *ui.Spinner
+			if cfg.Output.Spinner && !noSpinner {
+				spinner = ui.NewSpinner("Reading the API...")
+				spinner.Start()
+			}
+
+			var content strings.Builder
+			var totalUsage *provider.Usage
+			callback := func(event provider.StreamEvent) {
+				switch event.Type {
+				case provider.StreamEventText:
+					content.WriteString(event.Delta)
+				case provider.StreamEventToolCall:
+					if spinner != nil && event.ToolCall != nil {
+						spinner.UpdateMessage(fmt.Sprintf("Using %s...", event.ToolCall.Name))
+					}
+				case provider.StreamEventToolResult:
+					if spinner != nil {
+						spinner.UpdateMessage("Reading the API...")
+					}
+				case provider.StreamEventDone:
+					if event.Usage != nil {
+						totalUsage = event.Usage
+					}
+				}
+			}
+
+			resp, err := a.Cheatsheet(context.Background(), topic, callback)
+
+			if spinner != nil {
+				spinner.Stop()
+			}
+
+			if err != nil {
+				return fmt.Errorf("failed to generate cheatsheet: %w", err)
+			}
+
+			// The response holds only the final answer; streamed text also
+			// includes any text written between tool calls
+			finalContent := resp.Content
+			if finalContent == "" {
+				finalContent = content.String()
+			}
+			if totalUsage == nil {
+				totalUsage = &resp.Usage
+			}
+
+			if exportPath != "" {
+				doc := agent.CheatsheetMarkdown(finalContent, resources, topic, a.AttributedCitations(resp.ToolCalls))
+				if err := os.WriteFile(exportPath, []byte(doc), 0644); err != nil {
+					return fmt.Errorf("failed to export cheatsheet: %w", err)
+				}
+			}
+
+			header := "Cheatsheet"
+			if topic != "" {
+				header += ": " + topic
+			}
+			if err := outputDocument(cfg, header, finalContent, totalUsage); err != nil {
+				return err
+			}
+			if exportPath != "" {
+				fmt.Println(ui.Usage.Render(fmt.Sprintf("[Exported to %s]", exportPath)))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&resources, "resource", "r", nil, "Resource(s) to cover")
+	cmd.Flags().StringVarP(&topic, "topic", "t", "", "Narrow the cheatsheet to a topic (e.g. routing)")
+	cmd.Flags().StringVarP(&modelName, "model", "m", "", "Model to use (from config)")
+	cmd.Flags().BoolVar(&noSpinner, "no-spinner", false, "Disable the animated spinner")
+	cmd.Flags().BoolVar(&noAnswerCache, "no-answer-cache", false, "Bypass the answer cache and always ask the model")
+	cmd.Flags().StringVar(&exportPath, "export", "", "Also write the cheatsheet to a markdown file")
+
+	return cmd
+}
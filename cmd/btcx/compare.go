@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/agent"
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/resource"
+	"github.com/nickcecere/btcx/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func compareCmd() *cobra.Command {
+	var resources []string
+	var question string
+	var modelName string
+	var noSpinner bool
+	var outputFormat string
+
+	cmd := &cobra.Command{
+		Use:   "compare",
+		Short: i18n.T("compare.short"),
+		Long: `Compare two or more resources. Each resource is researched separately, then the
+findings are combined into a comparison table with citations from each side.`,
+		Example: `  btcx compare -r react -r svelte -q "How do effects differ?"
+  btcx compare -r express -r fastify -q "How is middleware registered?" -o json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, paths, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if len(resources) < 2 {
+				return fmt.Errorf("at least two resources are required (-r flag)")
+			}
+
+			if question == "" {
+				return fmt.Errorf("question is required (-q flag)")
+			}
+
+			switch outputFormat {
+			case "", "json":
+			default:
+				return fmt.Errorf("unknown output format %q (expected json)", outputFormat)
+			}
+
+			modelCfg, err := cfg.GetModelConfig(modelName)
+			if err != nil {
+				return fmt.Errorf("failed to get model: %w", err)
+			}
+
+			var configResources []*config.Resource
+			for _, name := range resources {
+				r, ok := cfg.GetResource(name)
+				if !ok {
+					return fmt.Errorf("resource %q not found in config", name)
+				}
+				configResources = append(configResources, r)
+			}
+
+			isJSON := outputFormat == "json"
+			showSpinner := cfg.Output.Spinner && !noSpinner && !isJSON
+
+			if !isJSON {
+				fmt.Fprintf(os.Stderr, "Preparing resources...\n")
+			}
+			mgr := resource.NewManager(cfg.Cache.ResolvedPath).WithLockFile(cfg.ResolvedLockFile)
+
+			// One collection per resource keeps each research step scoped
+			var scoped []*resource.Collection
+			for _, r := range configResources {
+				c, err := mgr.EnsureCollection(context.Background(), []*config.Resource{r})
+				if err != nil {
+					return fmt.Errorf("failed to prepare resources: %w", err)
+				}
+				scoped = append(scoped, c)
+			}
+			combined, err := mgr.EnsureCollection(context.Background(), configResources)
+			if err != nil {
+				return fmt.Errorf("failed to prepare resources: %w", err)
+			}
+
+			a, err := agent.New(agent.Options{
+				Config:      cfg,
+				ModelConfig: modelCfg,
+				Collection:  combined,
+				DataDir:     paths.DataDir,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create agent: %w", err)
+			}
+
+			var spinner *ui.Spinner
+			if showSpinner {
+				spinner = ui.NewSpinner("Researching...")
+				spinner.Start()
+			}
+
+			step := ""
+			progress := func(s string) {
+				step = s + "..."
+				if spinner != nil {
+					spinner.UpdateMessage(step)
+				} else if !isJSON {
+					fmt.Fprintln(os.Stderr, step)
+				}
+			}
+
+			var content strings.Builder
+			toolCounts := make(map[string]int)
+			callback := func(event provider.StreamEvent) {
+				switch event.Type {
+				case provider.StreamEventText:
+					content.WriteString(event.Delta)
+				case provider.StreamEventToolCall:
+					if event.ToolCall != nil {
+						toolCounts[event.ToolCall.Name]++
+						if spinner != nil {
+							spinner.UpdateMessage(fmt.Sprintf("%s using %s...", strings.TrimSuffix(step, "..."), event.ToolCall.Name))
+						}
+					}
+				case provider.StreamEventToolResult:
+					if spinner != nil {
+						spinner.UpdateMessage(step)
+					}
+				}
+			}
+
+			resp, err := a.Compare(context.Background(), question, scoped, progress, callback)
+
+			if spinner != nil {
+				spinner.Stop()
+			}
+
+			if err != nil {
+				return fmt.Errorf("failed to compare: %w", err)
+			}
+
+			finalContent := content.String()
+			if finalContent == "" {
+				finalContent = resp.Content
+			}
+			usage := resp.Usage
+
+			if isJSON {
+				return outputJSON(finalContent, toolCounts, &usage, modelCfg, resources, false)
+			}
+
+			return outputDocument(cfg, "Comparison", finalContent, &usage)
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&resources, "resource", "r", nil, "Resources to compare (at least two)")
+	cmd.Flags().StringVarP(&question, "question", "q", "", "What to compare")
+	cmd.Flags().StringVarP(&modelName, "model", "m", "", "Model to use (from config)")
+	cmd.Flags().BoolVar(&noSpinner, "no-spinner", false, "Disable the animated spinner")
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format (json)")
+
+	return cmd
+}
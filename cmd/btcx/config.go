@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 )
@@ -11,7 +12,7 @@ import (
 func configCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "config",
-		Short: "Manage configuration",
+		Short: i18n.T("config.short"),
 		Long:  `View and modify btcx configuration.`,
 	}
 
@@ -25,7 +26,7 @@ func configCmd() *cobra.Command {
 func configShowCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "show",
-		Short: "Show current configuration",
+		Short: i18n.T("config.show.short"),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, _, err := config.Load()
 			if err != nil {
@@ -46,7 +47,7 @@ func configShowCmd() *cobra.Command {
 func configSetCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "set <key> <value>",
-		Short: "Set a configuration value",
+		Short: i18n.T("config.set.short"),
 		Args:  cobra.ExactArgs(2),
 		Example: `  btcx config set provider openai
   btcx config set model gpt-4o
@@ -91,7 +92,7 @@ func configSetCmd() *cobra.Command {
 func configPathCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "path",
-		Short: "Show configuration file paths",
+		Short: i18n.T("config.path.short"),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			paths, err := config.ResolvePaths()
 			if err != nil {
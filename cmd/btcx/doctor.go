@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/resource"
+	"github.com/nickcecere/btcx/internal/search"
+	"github.com/spf13/cobra"
+)
+
+func doctorCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: i18n.T("doctor.short"),
+		Long: `Check that the config loads and which search btcx runs: the ripgrep executable, its version,
+and whether grep and glob use it or the built-in search (ripgrep older than 13 lacks flags grep needs).
+Also shows the proxy each model's requests go through and checks their CA bundles.
+Exits with an error if any check fails.`,
+		Example: `  btcx doctor
+  btcx doctor --shell /opt/rg/bin/rg
+  btcx doctor --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reports := []*resource.Report{doctorConfig(), doctorRipgrep(), doctorNetwork()}
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(reports); err != nil {
+					return fmt.Errorf("failed to encode report: %w", err)
+				}
+			} else {
+				for _, report := range reports {
+					printVerifyReport(report)
+				}
+			}
+
+			for _, report := range reports {
+				if report.Failed() {
+					cmd.SilenceUsage = true
+					return fmt.Errorf("btcx setup has problems")
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output the report as JSON")
+
+	return cmd
+}
+
+// doctorConfig checks that the config loads and validates
+func doctorConfig() *resource.Report {
+	report := &resource.Report{Resource: "config"}
+	cfg, paths, err := config.Load()
+	if err != nil {
+		report.Checks = append(report.Checks, resource.Check{Name: "load", Status: resource.CheckFail, Detail: err.Error()})
+		return report
+	}
+
+	var files []string
+	for _, path := range []string{paths.GlobalConfig, paths.ProjectConfig} {
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	detail := "no config file, using environment variables"
+	if len(files) > 0 {
+		detail = strings.Join(files, ", ")
+	}
+	report.Checks = append(report.Checks,
+		resource.Check{Name: "load", Status: resource.CheckOK, Detail: detail},
+		resource.Check{Name: "resources", Status: resource.CheckOK, Detail: fmt.Sprintf("%d configured", len(cfg.Resources))},
+	)
+	return report
+}
+
+// doctorNetwork reports the proxy each model's requests go through, from
+// its http settings or the HTTPS_PROXY environment, and checks CA bundles
+func doctorNetwork() *resource.Report {
+	report := &resource.Report{Resource: "network"}
+	cfg, _, err := config.Load()
+	if err != nil {
+		return report
+	}
+
+	models := make([]*config.ModelConfig, 0, len(cfg.Models))
+	for i := range cfg.Models {
+		models = append(models, &cfg.Models[i])
+	}
+	if len(models) == 0 {
+		// The legacy provider and model settings
+		if m, err := cfg.GetModelConfig(""); err == nil {
+			models = append(models, m)
+		}
+	}
+
+	for _, m := range models {
+		endpoint := provider.Endpoint(m)
+		if endpoint == "" {
+			continue
+		}
+
+		proxy, err := provider.ProxyFor(endpoint, m.ResolvedHTTP)
+		if err != nil {
+			report.Checks = append(report.Checks, resource.Check{Name: m.Name, Status: resource.CheckFail, Detail: err.Error()})
+			continue
+		}
+		detail := endpoint + " directly"
+		if proxy != "" {
+			detail = endpoint + " via " + proxy
+		}
+		if m.ResolvedHTTP.CABundle != "" {
+			detail += ", trusting " + m.ResolvedHTTP.CABundle
+		}
+		report.Checks = append(report.Checks, resource.Check{Name: m.Name, Status: resource.CheckOK, Detail: detail})
+	}
+	return report
+}
+
+// doctorRipgrep reports the ripgrep executable searches run and which
+// searches fall back to the built-in implementation
+func doctorRipgrep() *resource.Report {
+	report := &resource.Report{Resource: "ripgrep"}
+	add := func(name string, status resource.CheckStatus, format string, args ...interface{}) {
+		report.Checks = append(report.Checks, resource.Check{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	// Searches run the executable from tools.ripgrep, BTCX_RIPGREP or --shell
+	setting := os.Getenv(config.EnvRipgrep)
+	if cfg, _, err := config.Load(); err == nil {
+		setting = cfg.Tools.Ripgrep
+	}
+	search.SetRipgrep(setting)
+	info := search.Ripgrep()
+
+	switch {
+	case info.Setting == search.RipgrepOff:
+		add("path", resource.CheckOK, "off, grep and glob use the built-in search")
+		return report
+	case info.Path == "" && info.Setting != "":
+		add("path", resource.CheckFail, "%q not found: %v", info.Setting, info.Err)
+		return report
+	case info.Path == "":
+		add("path", resource.CheckWarn, "rg not found in PATH, grep and glob use the slower built-in search")
+		return report
+	case info.Err != nil:
+		add("path", resource.CheckFail, "%s: %v", info.Path, info.Err)
+		return report
+	}
+
+	add("path", resource.CheckOK, "%s", info.Path)
+	if info.Version != "" {
+		add("version", resource.CheckOK, "%s", info.Version)
+	} else {
+		add("version", resource.CheckWarn, "unknown (not ripgrep?)")
+	}
+	for _, name := range []string{"grep", "glob"} {
+		if info.Supports(name) {
+			add(name, resource.CheckOK, "ripgrep")
+			continue
+		}
+		add(name, resource.CheckWarn, "built-in search: ripgrep lacks %s (upgrade to ripgrep 13 or later)",
+			strings.Join(info.Missing[name], ", "))
+	}
+	return report
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/agent"
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/resource"
+	"github.com/nickcecere/btcx/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func explainCmd() *cobra.Command {
+	var resourceName string
+	var modelName string
+	var noSpinner bool
+	var outputFormat string
+
+	cmd := &cobra.Command{
+		Use:   "explain <path[:symbol]>",
+		Short: i18n.T("explain.short"),
+		Long: `Explain a file, or a single symbol in it, from a resource.
+
+The file (or the code around the symbol) and the places that reference it are
+sent to the model in one prompt, skipping the open-ended search loop. Paths are
+relative to the resource root.`,
+		Example: `  btcx explain -r svelte packages/svelte/src/internal/client/reactivity/sources.js
+  btcx explain -r svelte packages/svelte/src/internal/client/reactivity/sources.js:mutable_source
+  btcx explain -r cobra command.go:Command.ExecuteC -o json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, paths, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if resourceName == "" {
+				return fmt.Errorf("a resource is required (-r flag)")
+			}
+
+			switch outputFormat {
+			case "", "json":
+			default:
+				return fmt.Errorf("unknown output format %q (expected json)", outputFormat)
+			}
+
+			modelCfg, err := cfg.GetModelConfig(modelName)
+			if err != nil {
+				return fmt.Errorf("failed to get model: %w", err)
+			}
+
+			r, ok := cfg.GetResource(resourceName)
+			if !ok {
+				return fmt.Errorf("resource %q not found in config", resourceName)
+			}
+
+			isJSON := outputFormat == "json"
+			showSpinner := cfg.Output.Spinner && !noSpinner && !isJSON
+
+			if !isJSON {
+				fmt.Fprintf(os.Stderr, "Preparing resources...\n")
+			}
+			mgr := resource.NewManager(cfg.Cache.ResolvedPath).WithLockFile(cfg.ResolvedLockFile)
+			collection, err := mgr.EnsureCollection(context.Background(), []*config.Resource{r})
+			if err != nil {
+				return fmt.Errorf("failed to prepare resources: %w", err)
+			}
+
+			a, err := agent.New(agent.Options{
+				Config:      cfg,
+				ModelConfig: modelCfg,
+				Collection:  collection,
+				DataDir:     paths.DataDir,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create agent: %w", err)
+			}
+
+			var spinner *ui.Spinner
+			if showSpinner {
+				spinner = ui.NewSpinner("Reading code...")
+				spinner.Start()
+			}
+
+			var content strings.Builder
+			var totalUsage *provider.Usage
+			callback := func(event provider.StreamEvent) {
+				switch event.Type {
+				case provider.StreamEventText:
+					content.WriteString(event.Delta)
+				case provider.StreamEventDone:
+					if event.Usage != nil {
+						totalUsage = event.Usage
+					}
+				}
+			}
+
+			target := agent.ParseExplainTarget(resourceName, args[0])
+			resp, err := a.Explain(context.Background(), target, callback)
+
+			if spinner != nil {
+				spinner.Stop()
+			}
+
+			if err != nil {
+				return fmt.Errorf("failed to explain %s: %w", args[0], err)
+			}
+
+			finalContent := content.String()
+			if finalContent == "" {
+				finalContent = resp.Content
+			}
+			if totalUsage == nil {
+				totalUsage = &resp.Usage
+			}
+
+			if isJSON {
+				return outputJSON(finalContent, map[string]int{}, totalUsage, modelCfg, []string{resourceName}, false)
+			}
+			return outputHuman(cfg, finalContent, totalUsage)
+		},
+	}
+
+	cmd.Flags().StringVarP(&resourceName, "resource", "r", "", "Resource containing the file")
+	cmd.Flags().StringVarP(&modelName, "model", "m", "", "Model to use (from config)")
+	cmd.Flags().BoolVar(&noSpinner, "no-spinner", false, "Disable the animated spinner")
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format (json)")
+
+	return cmd
+}
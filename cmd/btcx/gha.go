@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/agent"
+	"github.com/nickcecere/btcx/internal/provider"
+)
+
+// outputGHA outputs the response using GitHub Actions workflow commands
+// The answer is printed in a collapsible group, each citation becomes a
+// notice annotation, and the answer is appended to the job summary when
+// GITHUB_STEP_SUMMARY is set
+func outputGHA(content string, citations []agent.Citation, usage *provider.Usage, resourceNames []string) error {
+	// Workflow commands in the answer (which quotes the resources) must not
+	// run, so command processing is stopped while it's printed
+	token, err := stopCommandsToken()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("::group::btcx answer (%s)\n", escapeGHAData(strings.Join(resourceNames, ", ")))
+	fmt.Printf("::stop-commands::%s\n", token)
+	fmt.Println(content)
+	fmt.Printf("::%s::\n", token)
+	fmt.Println("::endgroup::")
+
+	workspace := os.Getenv("GITHUB_WORKSPACE")
+	for _, c := range citations {
+		props := []string{"title=btcx citation"}
+		message := "Referenced in btcx answer"
+		// Annotations point at files of the workflow's repository, so only
+		// citations of files within it get one; others name the file
+		if file := workspaceFile(workspace, c.LocalPath); file != "" {
+			props = append(props, "file="+escapeGHAProperty(file))
+			if c.StartLine > 0 {
+				props = append(props, fmt.Sprintf("line=%d", c.StartLine))
+			}
+			if c.EndLine > 0 {
+				props = append(props, fmt.Sprintf("endLine=%d", c.EndLine))
+			}
+		} else {
+			message += ": " + c.Path
+		}
+		if c.License != nil {
+			message += " (" + c.License.Attribution() + ")"
+		}
+		fmt.Printf("::notice %s::%s\n", strings.Join(props, ","), escapeGHAData(message))
+	}
+
+	if usage != nil {
+		fmt.Printf("::debug::tokens in=%d out=%d\n", usage.InputTokens, usage.OutputTokens)
+	}
+
+	// Append the answer to the job summary if available
+	if summaryPath := os.Getenv("GITHUB_STEP_SUMMARY"); summaryPath != "" {
+		if err := appendStepSummary(summaryPath, content, citations); err != nil {
+			fmt.Printf("::warning::%s\n", escapeGHAData(fmt.Sprintf("failed to write step summary: %v", err)))
+		}
+	}
+
+	return nil
+}
+
+// appendStepSummary appends the answer and citations to the job summary file
+func appendStepSummary(path, content string, citations []agent.Citation) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var sb strings.Builder
+	sb.WriteString("## btcx answer\n\n")
+	sb.WriteString(content)
+	sb.WriteString("\n")
+
+	if len(citations) > 0 {
+		sb.WriteString("\n### Sources\n\n")
+		for _, c := range citations {
+			sb.WriteString("- " + c.Markdown())
+			if c.Evidence != "" {
+				sb.WriteString(fmt.Sprintf(" [evidence `%s`]", c.Evidence))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	if notes := agent.LicenseNotes(citations); len(notes) > 0 {
+		sb.WriteString("\n### Licenses\n\n")
+		for _, note := range notes {
+			sb.WriteString("- " + note + "\n")
+		}
+	}
+	sb.WriteString("\n")
+
+	_, err = f.WriteString(sb.String())
+	return err
+}
+
+// stopCommandsToken returns a random token for ::stop-commands::
+func stopCommandsToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// workspaceFile returns the path of a file relative to the workflow's
+// workspace, or "" if it's outside it
+func workspaceFile(workspace, path string) string {
+	if workspace == "" || path == "" {
+		return ""
+	}
+	if real, err := filepath.EvalSymlinks(workspace); err == nil {
+		workspace = real
+	}
+	rel, err := filepath.Rel(workspace, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// escapeGHAData escapes the message part of a workflow command
+func escapeGHAData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	s = strings.ReplaceAll(s, "\n", "%0A")
+	return s
+}
+
+// escapeGHAProperty escapes a property value of a workflow command
+func escapeGHAProperty(s string) string {
+	s = escapeGHAData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	s = strings.ReplaceAll(s, ",", "%2C")
+	return s
+}
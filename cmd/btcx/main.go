@@ -3,7 +3,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
 	"github.com/spf13/cobra"
 )
 
@@ -12,24 +15,79 @@ var (
 )
 
 func main() {
+	// Before any command is built, so help text is translated too
+	setLocale()
+
 	rootCmd := &cobra.Command{
 		Use:     "btcx",
-		Short:   "A documentation search agent powered by AI",
-		Long:    `btcx helps you search and understand codebases by asking questions about libraries and frameworks.`,
+		Short:   i18n.T("root.short"),
+		Long:    i18n.T("root.long"),
 		Version: version,
 	}
 
+	// --shell picks the ripgrep executable, e.g. rg.exe on Windows
+	var ripgrep string
+	rootCmd.PersistentFlags().StringVar(&ripgrep, "shell", "", i18n.T("root.flag.shell"))
+	// --force makes model requests past budget.monthlyUSD
+	var force bool
+	rootCmd.PersistentFlags().BoolVar(&force, "force", false, i18n.T("root.flag.force"))
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		// Set on the loaded config, which every command shares; a config
+		// that fails to load is reported by the command
+		cfg, _, err := config.Load()
+		if err != nil {
+			return
+		}
+		if ripgrep != "" {
+			cfg.UseRipgrep(ripgrep)
+		}
+		if force {
+			cfg.ForceBudget()
+		}
+	}
+
 	// Add commands
 	rootCmd.AddCommand(askCmd())
+	rootCmd.AddCommand(replayCmd())
+	rootCmd.AddCommand(explainCmd())
+	rootCmd.AddCommand(summarizeCmd())
+	rootCmd.AddCommand(cheatsheetCmd())
+	rootCmd.AddCommand(compareCmd())
 	rootCmd.AddCommand(tuiCmd())
 	rootCmd.AddCommand(configCmd())
 	rootCmd.AddCommand(resourcesCmd())
 	rootCmd.AddCommand(cacheCmd())
+	rootCmd.AddCommand(warmCmd())
 	rootCmd.AddCommand(threadsCmd())
+	rootCmd.AddCommand(statsCmd())
 	rootCmd.AddCommand(modelsCmd())
+	rootCmd.AddCommand(serveCmd())
+	rootCmd.AddCommand(rpcCmd())
+	rootCmd.AddCommand(doctorCmd())
+	rootCmd.AddCommand(upgradeCmd())
+	rootCmd.AddCommand(telemetryCmd())
 
-	if err := rootCmd.Execute(); err != nil {
+	start := time.Now()
+	cmd, err := rootCmd.ExecuteC()
+	reportTelemetry(cmd, time.Since(start), err)
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
+
+// setLocale activates the message catalog for output.locale, BTCX_LOCALE or
+// the environment's locale
+// It loads the config every command then shares; when that fails, the
+// command reports it and only BTCX_LOCALE and the environment count
+func setLocale() {
+	configured, dir := os.Getenv(config.EnvLocale), ""
+	if cfg, paths, err := config.Load(); err == nil {
+		configured, dir = cfg.Output.Locale, paths.LocalesDir
+	} else if paths, err := config.ResolvePaths(); err == nil {
+		dir = paths.LocalesDir
+	}
+	if _, err := i18n.SetLocale(i18n.Detect(configured), dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
@@ -1,21 +1,33 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/resource"
 	"github.com/nickcecere/btcx/internal/ui"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 func modelsCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "models",
-		Short: "Manage model configurations",
+		Short: i18n.T("models.short"),
 		Long:  `List and manage model configurations.`,
 	}
 
 	cmd.AddCommand(modelsListCmd())
+	cmd.AddCommand(modelsDiscoverCmd())
+	cmd.AddCommand(modelsTestCmd())
 
 	return cmd
 }
@@ -23,7 +35,7 @@ func modelsCmd() *cobra.Command {
 func modelsListCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "list",
-		Short: "List configured models",
+		Short: i18n.T("models.list.short"),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, _, err := config.Load()
 			if err != nil {
@@ -78,6 +90,18 @@ func modelsListCmd() *cobra.Command {
 				if m.BaseURL != "" {
 					fmt.Printf("      Base URL: %s\n", m.BaseURL)
 				}
+				if len(m.Headers) > 0 {
+					// Header values are often secrets, so only names are shown
+					names := make([]string, 0, len(m.Headers))
+					for key := range m.Headers {
+						names = append(names, key)
+					}
+					sort.Strings(names)
+					fmt.Printf("      Headers:  %s\n", strings.Join(names, ", "))
+				}
+				if m.Capabilities != nil {
+					fmt.Printf("      Supports: %s\n", capabilitiesSummary(&m))
+				}
 				if m.APIKey != "" {
 					// Show masked API key
 					masked := m.APIKey
@@ -95,3 +119,372 @@ func modelsListCmd() *cobra.Command {
 		},
 	}
 }
+
+func modelsDiscoverCmd() *cobra.Command {
+	var local bool
+	var ports []int
+
+	cmd := &cobra.Command{
+		Use:   "discover",
+		Short: i18n.T("models.discover.short"),
+		Long: `Find models served on this machine and print ready-to-use model configs.
+
+With --local, the default ports of LM Studio (1234), llama.cpp's llama-server
+(8080) and Ollama (11434) are probed for an OpenAI-compatible /v1/models API.`,
+		Example: `  btcx models discover --local
+  btcx models discover --local --port 5001`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !local {
+				return fmt.Errorf("only local discovery is supported; use --local")
+			}
+
+			cfg, _, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			servers := provider.DiscoverLocal(cmd.Context(), append(provider.DefaultLocalPorts, ports...))
+			if len(servers) == 0 {
+				fmt.Println("No local model servers found.")
+				fmt.Println(ui.Dim.Render("Start Ollama, LM Studio's server or llama-server, or pass --port for other ports."))
+				return nil
+			}
+
+			// Skip models that are already configured and keep names unique
+			names := make(map[string]bool)
+			configured := make(map[string]bool)
+			for _, m := range cfg.Models {
+				names[m.Name] = true
+				configured[string(m.Provider)+" "+strings.TrimSuffix(m.Model, ":latest")] = true
+			}
+
+			var suggested []config.ModelConfig
+			for _, s := range servers {
+				fmt.Printf("%s at %s\n", ui.Bold.Render(localServerName(s.Kind)), s.BaseURL)
+				for _, m := range s.ModelConfigs() {
+					if configured[string(m.Provider)+" "+strings.TrimSuffix(m.Model, ":latest")] {
+						fmt.Printf("  %s %s\n", m.Model, ui.Dim.Render("(already configured)"))
+						continue
+					}
+					fmt.Printf("  %s\n", m.Model)
+
+					base := m.Name
+					for i := 2; names[m.Name]; i++ {
+						m.Name = fmt.Sprintf("%s-%d", base, i)
+					}
+					names[m.Name] = true
+					suggested = append(suggested, m)
+				}
+				fmt.Println()
+			}
+
+			if len(suggested) == 0 {
+				fmt.Println("All local models are already configured.")
+				return nil
+			}
+
+			fmt.Println("Add these to the models list in your config:")
+			fmt.Println()
+			enc := yaml.NewEncoder(os.Stdout)
+			enc.SetIndent(2)
+			if err := enc.Encode(map[string][]config.ModelConfig{"models": suggested}); err != nil {
+				return fmt.Errorf("failed to marshal model configs: %w", err)
+			}
+			return enc.Close()
+		},
+	}
+
+	cmd.Flags().BoolVar(&local, "local", false, "Probe localhost for model servers")
+	cmd.Flags().IntSliceVar(&ports, "port", nil, "Additional localhost port to probe (repeatable)")
+
+	return cmd
+}
+
+func modelsTestCmd() *cobra.Command {
+	var timeout int
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "test [model...]",
+		Short: i18n.T("models.test.short"),
+		Long: `Send a few tiny requests to each configured model (or the named ones) and report
+whether its credentials are accepted, how long it takes to answer, whether it calls
+tools when asked to, and whether streaming works. Tools and streaming are tried
+even when the model's capabilities turn them off, to show whether they could be on.
+
+Requests go straight to the model, bypassing the response cache, budget and rate
+limits. Exits with an error if any check fails.`,
+		Example: `  btcx models test
+  btcx models test claude local
+  btcx models test --timeout 120`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, _, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			var models []*config.ModelConfig
+			for _, name := range args {
+				m, err := cfg.GetModelConfig(name)
+				if err != nil {
+					return err
+				}
+				models = append(models, m)
+			}
+			if len(args) == 0 {
+				for i := range cfg.Models {
+					models = append(models, &cfg.Models[i])
+				}
+				if len(models) == 0 {
+					// The legacy provider and model settings
+					m, err := cfg.GetModelConfig("")
+					if err != nil {
+						return fmt.Errorf("no models configured")
+					}
+					models = append(models, m)
+				}
+			}
+
+			failed := false
+			var reports []*modelReport
+			for _, m := range models {
+				ctx, cancel := context.WithTimeout(cmd.Context(), time.Duration(timeout)*time.Second)
+				report := testModel(ctx, m)
+				cancel()
+
+				failed = failed || report.Failed()
+				if jsonOutput {
+					reports = append(reports, report)
+				} else {
+					printChecks(report.Model, report.Checks)
+				}
+			}
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(reports); err != nil {
+					return fmt.Errorf("failed to encode report: %w", err)
+				}
+			}
+			if failed {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("some models failed their checks")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&timeout, "timeout", 60, "Seconds each model has for all its checks")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output the reports as JSON")
+
+	return cmd
+}
+
+// pingTool is the tool models are asked to call by btcx models test
+var pingTool = provider.Tool{
+	Name:        "ping",
+	Description: "Reply to a ping. Call this when asked to ping.",
+	Parameters: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"message": map[string]interface{}{
+				"type":        "string",
+				"description": "The message to send back",
+			},
+		},
+		"required": []string{"message"},
+	},
+}
+
+// modelReport collects the checks run against a model
+type modelReport struct {
+	Model  string           `json:"model"`
+	Checks []resource.Check `json:"checks"`
+}
+
+// Failed reports whether any check failed
+func (r *modelReport) Failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == resource.CheckFail {
+			return true
+		}
+	}
+	return false
+}
+
+// testModel checks that a model accepts its credentials, answers, calls
+// tools and streams, skipping what its capabilities turn off
+func testModel(ctx context.Context, m *config.ModelConfig) *modelReport {
+	report := &modelReport{Model: m.Name}
+	add := func(name string, status resource.CheckStatus, format string, args ...interface{}) {
+		report.Checks = append(report.Checks, resource.Check{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	p, err := provider.NewFromModelConfig(m)
+	if err != nil {
+		add("setup", resource.CheckFail, "%v", err)
+		return report
+	}
+
+	// A plain answer shows whether the endpoint and credentials work
+	start := time.Now()
+	resp, err := p.Chat(ctx, &provider.ChatRequest{
+		Model:     m.Model,
+		Messages:  []provider.Message{{Role: "user", Content: "Reply with the single word OK."}},
+		MaxTokens: 64,
+	})
+	if err != nil {
+		if authError(err) {
+			add("auth", resource.CheckFail, "credentials rejected: %v", err)
+		} else {
+			add("chat", resource.CheckFail, "%v", err)
+		}
+		return report
+	}
+	add("auth", resource.CheckOK, "credentials accepted")
+	add("chat", resource.CheckOK, "answered in %s (%d tokens in, %d out)", elapsed(start), resp.Usage.InputTokens, resp.Usage.OutputTokens)
+
+	// Tools and streaming are tried even when the capabilities turn them off,
+	// to show whether they could be turned on
+	// Some servers reject a required tool choice while calling tools fine,
+	// so a failed ping is tried again with the choice left to the model
+	start = time.Now()
+	toolsErr := testTools(ctx, p, m, provider.ToolChoiceRequired)
+	if toolsErr != "" {
+		toolsErr = testTools(ctx, p, m, provider.ToolChoiceAuto)
+	}
+	switch {
+	case !m.SupportsTools() && toolsErr != "":
+		add("tools", resource.CheckOK, "not supported; off in capabilities, so tool calls are written as %s text", m.TextToolFormat())
+	case !m.SupportsTools():
+		add("tools", resource.CheckWarn, "called ping in %s, but capabilities.supportsTools is false; tool calls are written as text", elapsed(start))
+	case toolsErr != "":
+		add("tools", resource.CheckFail, "%s; set capabilities.supportsTools: false to have tool calls written as text", toolsErr)
+	default:
+		add("tools", resource.CheckOK, "called ping in %s", elapsed(start))
+	}
+
+	streamed, streamErr := testStreaming(ctx, p, m)
+	switch {
+	case !m.SupportsStreaming() && streamErr != nil:
+		add("streaming", resource.CheckOK, "not supported (%v); off in capabilities", streamErr)
+	case !m.SupportsStreaming():
+		add("streaming", resource.CheckWarn, "%s, but off in capabilities; set capabilities.supportsStreaming: true to stream answers", streamed)
+	case streamErr != nil:
+		add("streaming", resource.CheckFail, "%v; set capabilities.supportsStreaming: false to get answers whole", streamErr)
+	default:
+		add("streaming", resource.CheckOK, "%s", streamed)
+	}
+	return report
+}
+
+// testTools asks the model to call the ping tool with the given tool choice
+// and returns what went wrong ("" if it called it)
+func testTools(ctx context.Context, p provider.Provider, m *config.ModelConfig, mode provider.ToolChoiceMode) string {
+	resp, err := p.Chat(ctx, &provider.ChatRequest{
+		Model:      m.Model,
+		Messages:   []provider.Message{{Role: "user", Content: `Ping with the message "hello".`}},
+		Tools:      []provider.Tool{pingTool},
+		ToolChoice: &provider.ToolChoice{Mode: mode},
+		MaxTokens:  256,
+	})
+	switch {
+	case err != nil:
+		return err.Error()
+	case len(resp.ToolCalls) == 0 || resp.ToolCalls[0].Name != pingTool.Name:
+		return "answered without calling the tool"
+	}
+	return ""
+}
+
+// testStreaming streams a short answer and describes its timing
+func testStreaming(ctx context.Context, p provider.Provider, m *config.ModelConfig) (string, error) {
+	start := time.Now()
+	events, err := p.StreamChat(ctx, &provider.ChatRequest{
+		Model:     m.Model,
+		Messages:  []provider.Message{{Role: "user", Content: "Count from 1 to 5, separated by spaces."}},
+		MaxTokens: 64,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var first time.Duration
+	done := false
+	for event := range events {
+		switch event.Type {
+		case provider.StreamEventText, provider.StreamEventReasoning:
+			if first == 0 {
+				first = time.Since(start)
+			}
+		case provider.StreamEventError:
+			if event.Error != nil {
+				err = event.Error
+			}
+		case provider.StreamEventDone:
+			done = true
+		}
+	}
+	switch {
+	case err != nil:
+		return "", err
+	case !done:
+		return "", fmt.Errorf("the stream ended without finishing")
+	case first == 0:
+		return "", fmt.Errorf("the stream finished without any text")
+	}
+	return fmt.Sprintf("first token after %s, done in %s", first.Round(time.Millisecond), elapsed(start)), nil
+}
+
+// authError reports whether a provider error looks like rejected credentials
+func authError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, hint := range []string{"401", "403", "unauthorized", "forbidden", "authentication", "api key", "api_key", "permission"} {
+		if strings.Contains(msg, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// elapsed returns the time since start, rounded to milliseconds
+func elapsed(start time.Time) time.Duration {
+	return time.Since(start).Round(time.Millisecond)
+}
+
+// localServerName returns a display name for a discovered server kind
+func localServerName(kind string) string {
+	switch kind {
+	case "ollama":
+		return "Ollama"
+	case "lmstudio":
+		return "LM Studio"
+	case "llama.cpp":
+		return "llama.cpp server"
+	}
+	return "OpenAI-compatible server"
+}
+
+// capabilitiesSummary describes what a model supports, e.g.
+// "no tools (react tool calls in text), streaming, images, 8192-token context"
+func capabilitiesSummary(m *config.ModelConfig) string {
+	flag := func(ok bool, name string) string {
+		if ok {
+			return name
+		}
+		return "no " + name
+	}
+	parts := []string{
+		flag(m.SupportsTools(), "tools"),
+		flag(m.SupportsStreaming(), "streaming"),
+		flag(m.SupportsImages(), "images"),
+	}
+	if !m.SupportsTools() {
+		parts[0] = fmt.Sprintf("no tools (%s tool calls in text)", m.TextToolFormat())
+	}
+	if window := m.ContextWindow(); window > 0 {
+		parts = append(parts, fmt.Sprintf("%d-token context", window))
+	}
+	return strings.Join(parts, ", ")
+}
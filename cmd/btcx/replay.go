@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/agent"
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
+	"github.com/spf13/cobra"
+)
+
+func replayCmd() *cobra.Command {
+	var strict bool
+
+	cmd := &cobra.Command{
+		Use:   "replay <session.json>",
+		Short: i18n.T("replay.short"),
+		Long: `Re-run the agent loop of a question recorded with btcx ask --record.
+
+Model responses and tool results come from the recording, so nothing is sent to
+a model and no tool reads the resources. Prompts and settings come from the
+current code and config, and so does what the prompt says about the resources
+(prompt files, overviews, directory maps and outlines of cited files), read from
+their cached copies where they still exist. Requests that differ from the
+recorded ones are reported, so a replay shows how a change, to the code, the
+config or the resources, affects the loop.`,
+		Example: `  btcx ask -r cobra -q "How are flags registered?" --record session.json
+  btcx replay session.json
+  btcx replay session.json --strict`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, _, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			session, err := agent.LoadSession(args[0])
+			if err != nil {
+				return err
+			}
+
+			// The recorded model's settings shape its requests; without it
+			// in the config, the defaults do
+			modelCfg, err := cfg.GetModelConfig(session.Model)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Model %q isn't configured; replaying with default model settings\n", session.Model)
+				modelCfg = &config.ModelConfig{Name: session.Model}
+			}
+
+			// Replayed threads aren't kept
+			dataDir, err := os.MkdirTemp("", "btcx-replay-")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(dataDir)
+
+			opts := session.Options
+			a, err := agent.New(agent.Options{
+				Config:      cfg,
+				ModelConfig: modelCfg,
+				Collection:  session.Collection,
+				DataDir:     dataDir,
+				DiffFrom:    opts.DiffFrom,
+				DiffTo:      opts.DiffTo,
+				Scope:       opts.Scope,
+				Boost:       opts.Boost,
+				Language:    opts.Language,
+				Brevity:     opts.Brevity,
+				Session:     session,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create agent: %w", err)
+			}
+
+			resp, askErr := a.Ask(context.Background(), session.Question)
+			if askErr == nil {
+				if err := outputHuman(cfg, resp.Content, &resp.Usage); err != nil {
+					return err
+				}
+			}
+
+			// Report where the replay left the recording
+			var problems []string
+			if askErr != nil && askErr.Error() != session.Error {
+				problems = append(problems, fmt.Sprintf("the replay failed: %v", askErr))
+			}
+			if diverged := session.Diverged(); len(diverged) == 1 {
+				problems = append(problems, fmt.Sprintf("request %d differs from the recording", diverged[0]))
+			} else if len(diverged) > 1 {
+				problems = append(problems, fmt.Sprintf("requests %s differ from the recording", joinInts(diverged)))
+			}
+			if n := session.Replayed(); n < len(session.Exchanges) {
+				problems = append(problems, fmt.Sprintf("%d of the %d recorded requests were replayed", n, len(session.Exchanges)))
+			}
+			if askErr == nil && resp.Content != session.Answer {
+				problems = append(problems, "the answer differs from the recorded one")
+			}
+
+			fmt.Fprintln(os.Stderr)
+			if len(problems) == 0 {
+				fmt.Fprintf(os.Stderr, "Replayed %d requests and %d tool calls; the replay matches the recording\n",
+					session.Replayed(), len(session.ToolCalls))
+				return nil
+			}
+			for _, p := range problems {
+				fmt.Fprintf(os.Stderr, "Replay: %s\n", p)
+			}
+			if strict {
+				return fmt.Errorf("the replay doesn't match %s", args[0])
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&strict, "strict", false, "Fail when the replay doesn't match the recording")
+
+	return cmd
+}
+
+// joinInts lists numbers separated by commas
+func joinInts(numbers []int) string {
+	s := make([]string, len(numbers))
+	for i, n := range numbers {
+		s[i] = fmt.Sprint(n)
+	}
+	return strings.Join(s, ", ")
+}
@@ -2,10 +2,15 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
 	"github.com/nickcecere/btcx/internal/resource"
+	"github.com/nickcecere/btcx/internal/ui"
 	"github.com/spf13/cobra"
 )
 
@@ -13,7 +18,7 @@ func resourcesCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "resources",
 		Aliases: []string{"res"},
-		Short:   "Manage resources",
+		Short:   i18n.T("resources.short"),
 		Long:    `Add, remove, and list resources for searching.`,
 	}
 
@@ -21,6 +26,8 @@ func resourcesCmd() *cobra.Command {
 	cmd.AddCommand(resourcesAddCmd())
 	cmd.AddCommand(resourcesRemoveCmd())
 	cmd.AddCommand(resourcesFetchCmd())
+	cmd.AddCommand(resourcesVerifyCmd())
+	cmd.AddCommand(resourcesLockCmd())
 
 	return cmd
 }
@@ -28,7 +35,7 @@ func resourcesCmd() *cobra.Command {
 func resourcesListCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "list",
-		Short: "List configured resources",
+		Short: i18n.T("resources.list.short"),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, _, err := config.Load()
 			if err != nil {
@@ -50,6 +57,9 @@ func resourcesListCmd() *cobra.Command {
 					if r.Branch != "" {
 						fmt.Printf("    Branch: %s\n", r.Branch)
 					}
+					if r.Fetch != "" {
+						fmt.Printf("    Fetch: %s\n", r.Fetch)
+					}
 				} else {
 					fmt.Printf("    Path: %s\n", r.Path)
 				}
@@ -59,6 +69,9 @@ func resourcesListCmd() *cobra.Command {
 				if r.Notes != "" {
 					fmt.Printf("    Notes: %s\n", r.Notes)
 				}
+				if r.PromptFile != "" {
+					fmt.Printf("    Prompt file: %s\n", r.PromptFile)
+				}
 				fmt.Println()
 			}
 
@@ -68,14 +81,17 @@ func resourcesListCmd() *cobra.Command {
 }
 
 func resourcesAddCmd() *cobra.Command {
-	var name, resType, url, branch, path, searchPath, notes string
+	var name, resType, url, branch, fetch, forge, path, searchPath, notes, promptFile string
 
 	cmd := &cobra.Command{
 		Use:   "add",
-		Short: "Add a new resource",
+		Short: i18n.T("resources.add.short"),
 		Example: `  # Add a git resource
   btcx resources add -n svelte -t git -u https://github.com/sveltejs/svelte.dev --branch main --search-path apps/svelte.dev
 
+  # Download a huge repository as an archive instead of cloning it
+  btcx resources add -n gecko -t git -u https://github.com/mozilla/gecko-dev --fetch archive
+
   # Add a local resource
   btcx resources add -n myproject -t local -p /path/to/project`,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -91,14 +107,24 @@ func resourcesAddCmd() *cobra.Command {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
 
+			// The config file resolves relative paths from its own directory
+			if promptFile != "" && promptFile[0] != '~' {
+				if abs, err := filepath.Abs(promptFile); err == nil {
+					promptFile = abs
+				}
+			}
+
 			r := config.Resource{
 				Name:       name,
 				Type:       config.ResourceType(resType),
 				URL:        url,
 				Branch:     branch,
+				Fetch:      config.ResourceFetch(fetch),
+				Forge:      forge,
 				Path:       path,
 				SearchPath: searchPath,
 				Notes:      notes,
+				PromptFile: promptFile,
 			}
 
 			if err := cfg.AddResource(r); err != nil {
@@ -122,9 +148,12 @@ func resourcesAddCmd() *cobra.Command {
 	cmd.Flags().StringVarP(&resType, "type", "t", "", "Resource type (git or local)")
 	cmd.Flags().StringVarP(&url, "url", "u", "", "Git repository URL")
 	cmd.Flags().StringVar(&branch, "branch", "", "Git branch")
+	cmd.Flags().StringVar(&fetch, "fetch", "", "How to download git resources: clone (default) or archive")
+	cmd.Flags().StringVar(&forge, "forge", "", "Forge for archive fetches: github, gitlab, bitbucket or gitea (default: from the URL)")
 	cmd.Flags().StringVarP(&path, "path", "p", "", "Local path")
 	cmd.Flags().StringVar(&searchPath, "search-path", "", "Subdirectory to search")
 	cmd.Flags().StringVar(&notes, "notes", "", "Notes for the AI")
+	cmd.Flags().StringVar(&promptFile, "prompt-file", "", "Markdown file with longer guidance for the AI")
 
 	return cmd
 }
@@ -132,7 +161,7 @@ func resourcesAddCmd() *cobra.Command {
 func resourcesRemoveCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "remove <name>",
-		Short: "Remove a resource",
+		Short: i18n.T("resources.remove.short"),
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			name := args[0]
@@ -159,7 +188,7 @@ func resourcesRemoveCmd() *cobra.Command {
 func resourcesFetchCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "fetch [name]",
-		Short: "Fetch/update resources",
+		Short: i18n.T("resources.fetch.short"),
 		Long:  `Fetch or update resources. If no name is specified, all resources are fetched.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, _, err := config.Load()
@@ -167,7 +196,7 @@ func resourcesFetchCmd() *cobra.Command {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
 
-			mgr := resource.NewManager(cfg.Cache.ResolvedPath)
+			mgr := resource.NewManager(cfg.Cache.ResolvedPath).WithLockFile(cfg.ResolvedLockFile)
 
 			if len(args) > 0 {
 				// Fetch specific resource
@@ -198,3 +227,141 @@ func resourcesFetchCmd() *cobra.Command {
 		},
 	}
 }
+
+func resourcesLockCmd() *cobra.Command {
+	var update bool
+
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: i18n.T("resources.lock.short"),
+		Long: `Write a lockfile (btcx.lock by default) with the resolved commit SHA of each git resource.
+While the lockfile exists, fetching and asking check out those commits instead of pulling,
+so answers from CI and eval runs are reproducible. Delete the file to track branches again.`,
+		Example: `  # Pin the commits currently cached
+  btcx resources lock
+
+  # Pull the latest commits first, then pin them
+  btcx resources lock --update`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, _, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			mgr := resource.NewManager(cfg.Cache.ResolvedPath)
+
+			lock, err := mgr.Lock(context.Background(), cfg.Resources, update)
+			if err != nil {
+				return err
+			}
+			if len(lock.Resources) == 0 {
+				fmt.Println("No git resources to lock.")
+				return nil
+			}
+
+			if err := resource.SaveLock(cfg.ResolvedLockFile, lock); err != nil {
+				return err
+			}
+
+			for _, name := range lock.Names() {
+				fmt.Printf("  %s  %s\n", lock.Resources[name].Commit, name)
+			}
+			fmt.Printf("\nWrote %s\n", cfg.ResolvedLockFile)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&update, "update", false, "Pull the latest commits before locking")
+
+	return cmd
+}
+
+func resourcesVerifyCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "verify [name]",
+		Short: i18n.T("resources.verify.short"),
+		Long: `Check each configured resource without fetching it: git URL reachable, branch exists,
+local path present, searchPath valid, estimated size, and whether the cached copy is stale.
+Exits with an error if any check fails.`,
+		Example: `  btcx resources verify
+  btcx resources verify svelte --json`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, _, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			resources := cfg.Resources
+			if len(args) > 0 {
+				r, ok := cfg.GetResource(args[0])
+				if !ok {
+					return fmt.Errorf("resource %q not found", args[0])
+				}
+				resources = []config.Resource{*r}
+			}
+			if len(resources) == 0 {
+				fmt.Println("No resources configured.")
+				return nil
+			}
+
+			mgr := resource.NewManager(cfg.Cache.ResolvedPath).WithLockFile(cfg.ResolvedLockFile)
+
+			var reports []*resource.Report
+			failed := 0
+			for i := range resources {
+				report := mgr.Verify(context.Background(), &resources[i])
+				reports = append(reports, report)
+				if report.Failed() {
+					failed++
+				}
+				if !jsonOutput {
+					printVerifyReport(report)
+				}
+			}
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(reports); err != nil {
+					return fmt.Errorf("failed to encode report: %w", err)
+				}
+			}
+
+			if failed > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d of %d resources failed verification", failed, len(resources))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output the report as JSON")
+
+	return cmd
+}
+
+// printVerifyReport prints the checks for one resource
+func printVerifyReport(report *resource.Report) {
+	printChecks(report.Resource, report.Checks)
+}
+
+// printChecks prints checks under a title
+func printChecks(title string, checks []resource.Check) {
+	fmt.Println(ui.Bold.Render(title))
+	for _, c := range checks {
+		var mark string
+		switch c.Status {
+		case resource.CheckOK:
+			mark = ui.Success.Render("✓")
+		case resource.CheckWarn:
+			mark = ui.Warning.Render("!")
+		default:
+			mark = ui.Error.Render("✗")
+		}
+		fmt.Printf("  %s %-10s %s\n", mark, c.Name, c.Detail)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
+	"github.com/nickcecere/btcx/internal/rpc"
+	"github.com/spf13/cobra"
+)
+
+func rpcCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rpc",
+		Short: i18n.T("rpc.short"),
+		Long: `Run btcx as a long-lived JSON-RPC 2.0 process on stdin/stdout, so editor extensions (Neovim,
+VS Code) can ask many questions without starting btcx for each one.
+
+Messages are framed with Content-Length headers as in LSP, or one JSON object per line; replies
+use the framing of the first message. Closing stdin ends the process.
+
+Methods:
+  initialize      Server name, protocol version and methods
+  ask             Answer a question: {question, resources, model?, thread_id?, no_answer_cache?}
+  cancel          Cancel a running ask: {id} (also $/cancelRequest)
+  listResources   Configured resources
+  listModels      Configured models
+  listThreads     Saved threads, newest first: {limit?, resource?}
+
+While an ask runs, stream.event notifications carry its progress: {id, type, delta?, tool?, usage?}
+with type text, tool_start, tool_finish or usage. Logs and warnings go to stderr.`,
+		Example: `  btcx rpc
+  echo '{"jsonrpc":"2.0","id":1,"method":"listResources"}' | btcx rpc`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, paths, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return rpc.New(cfg, paths).Serve(ctx, os.Stdin, os.Stdout)
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
+	"github.com/nickcecere/btcx/internal/server"
+	"github.com/spf13/cobra"
+)
+
+func serveCmd() *cobra.Command {
+	var addr string
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: i18n.T("serve.short"),
+		Long: `Start an HTTP server that answers questions about configured resources.
+
+Endpoints:
+  GET  /                 Web chat UI
+  GET  /healthz          Health check
+  POST /api/ask          Ask a question (JSON)
+  POST /api/ask/stream   Ask a question, streaming the answer (SSE)
+  GET  /api/ask/stream   Same, with the question in query parameters
+  GET  /api/resources    List configured resources
+  GET  /api/models       List configured models
+  GET  /api/threads      List threads
+  GET  /api/threads/{id} Show a thread
+  POST /api/threads/{id}/share  Create a read-only link to a thread
+  GET  /share/{token}    Shared thread (no API key needed)
+  POST /slack/events     Slack Events API (app mentions), if configured
+  POST /slack/commands   Slack slash command, if configured`,
+		Example: `  btcx serve
+  btcx serve --addr :8080`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, paths, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if addr != "" {
+				cfg.Serve.Addr = addr
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			srv := server.New(cfg, paths)
+
+			fmt.Fprintf(os.Stderr, "Listening on %s\n", cfg.Serve.Addr)
+			if cfg.Serve.Slack.Enabled() {
+				fmt.Fprintf(os.Stderr, "Slack integration enabled\n")
+			}
+
+			return srv.ListenAndServe(ctx)
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", "", "Address to listen on (default from config: 127.0.0.1:8080)")
+
+	return cmd
+}
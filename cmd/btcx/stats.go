@@ -0,0 +1,390 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
+	"github.com/nickcecere/btcx/internal/storage"
+	"github.com/nickcecere/btcx/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// Stats is the token usage summed over saved answers
+type Stats struct {
+	Answers      int         `json:"answers"`
+	Requests     int         `json:"requests"`
+	InputTokens  int         `json:"input_tokens"`
+	OutputTokens int         `json:"output_tokens"`
+	Tools        []ToolStats `json:"tools"`
+	// Categories are present when classify.enabled labeled questions
+	Categories []CategoryStats `json:"categories"`
+}
+
+// ToolStats is the prompt tokens one tool's outputs took up
+type ToolStats struct {
+	Name   string `json:"name"`
+	Calls  int    `json:"calls"`
+	Tokens int    `json:"tokens"`
+	// Share is the fraction of all prompt tokens
+	Share float64 `json:"share"`
+}
+
+// CategoryStats is the questions of one kind and the tokens their answers took
+type CategoryStats struct {
+	Name      string `json:"name"`
+	Questions int    `json:"questions"`
+	// Share is the fraction of all labeled questions
+	Share float64 `json:"share"`
+	// Answers, InputTokens and OutputTokens count fresh answers; cached
+	// answers spend no tokens
+	Answers      int `json:"answers"`
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// BudgetStats is this month's model spend against budget.monthlyUSD
+type BudgetStats struct {
+	Month string `json:"month"`
+	// LimitUSD is budget.monthlyUSD (0 for no limit)
+	LimitUSD float64 `json:"limit_usd"`
+	SpentUSD float64 `json:"spent_usd"`
+	// Share is the fraction of the limit spent
+	Share        float64 `json:"share"`
+	TodayUSD     float64 `json:"today_usd"`
+	Requests     int     `json:"requests"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	// BurnRateUSD is the average spend per day so far this month
+	BurnRateUSD float64 `json:"burn_rate_usd"`
+	// ProjectedUSD is the month's spend if the burn rate holds
+	ProjectedUSD float64 `json:"projected_usd"`
+	// RunsOut is the day the limit is reached at the burn rate, if that's
+	// before the month ends (YYYY-MM-DD)
+	RunsOut string       `json:"runs_out,omitempty"`
+	Models  []ModelSpend `json:"models"`
+}
+
+// ModelSpend is one model's spend this month
+type ModelSpend struct {
+	Name string  `json:"name"`
+	USD  float64 `json:"usd"`
+}
+
+func statsCmd() *cobra.Command {
+	var days int
+	var outputFormat string
+	var budget bool
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: i18n.T("stats.short"),
+		Long: `Show the tokens spent on answers saved in threads, and how much of the prompt tokens each tool's
+outputs took up. Tool outputs are sent again with every later request of the loop, so large list or
+read outputs add up; tune tools.limits to spend less.
+
+Tool shares are estimated by splitting each request's prompt tokens by the size of its parts.
+Answers saved before breakdowns were recorded aren't counted.
+
+With classify.enabled, questions are labeled by kind (how-to, api, debugging, comparison, other)
+and stats shows how questions and tokens split across the labels.
+
+With --budget, stats shows this month's spend (from the models' pricing) against budget.monthlyUSD,
+the burn rate, and when the budget runs out at that rate.`,
+		Example: `  btcx stats
+  btcx stats --days 7 -o json
+  btcx stats --budget`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch outputFormat {
+			case "", "json":
+			default:
+				return fmt.Errorf("unknown output format %q (expected json)", outputFormat)
+			}
+
+			if budget {
+				if days > 0 {
+					return fmt.Errorf("--days doesn't apply to --budget, which covers this month")
+				}
+				return runBudgetStats(outputFormat)
+			}
+
+			paths, err := config.ResolvePaths()
+			if err != nil {
+				return fmt.Errorf("failed to resolve paths: %w", err)
+			}
+			threads, err := storage.NewStorage(paths.DataDir).ListThreads()
+			if err != nil {
+				return err
+			}
+
+			var since time.Time
+			if days > 0 {
+				since = time.Now().AddDate(0, 0, -days)
+			}
+			stats := collectStats(threads, since)
+
+			if outputFormat == "json" {
+				data, err := json.MarshalIndent(stats, "", "  ")
+				if err != nil {
+					return fmt.Errorf("failed to marshal JSON: %w", err)
+				}
+				fmt.Println(string(data))
+				return nil
+			}
+
+			printStats(stats)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&days, "days", 0, "Only count answers from the last N days (default: all)")
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format (json)")
+	cmd.Flags().BoolVar(&budget, "budget", false, "Show this month's spend against budget.monthlyUSD and the burn rate")
+
+	return cmd
+}
+
+// collectStats sums the usage breakdowns of answers given since the cutoff
+func collectStats(threads []*storage.Thread, since time.Time) *Stats {
+	stats := &Stats{Tools: []ToolStats{}, Categories: []CategoryStats{}}
+	tokens := make(map[string]int)
+	calls := make(map[string]int)
+	categories := make(map[string]*CategoryStats)
+	labeled := 0
+	for _, t := range threads {
+		// category is the label of the question being answered
+		var category *CategoryStats
+		for _, msg := range t.Messages {
+			if msg.Role == "user" {
+				category = nil
+				if msg.Category == "" || msg.Timestamp.Before(since) {
+					continue
+				}
+				if categories[msg.Category] == nil {
+					categories[msg.Category] = &CategoryStats{Name: msg.Category}
+				}
+				category = categories[msg.Category]
+				category.Questions++
+				labeled++
+				continue
+			}
+
+			b := msg.Breakdown
+			if b == nil || msg.Timestamp.Before(since) {
+				continue
+			}
+			if category != nil {
+				category.Answers++
+				category.InputTokens += b.InputTokens
+				category.OutputTokens += b.OutputTokens
+			}
+			stats.Answers++
+			stats.Requests += len(b.Iterations)
+			stats.InputTokens += b.InputTokens
+			stats.OutputTokens += b.OutputTokens
+			for name, n := range b.ToolTokens {
+				tokens[name] += n
+			}
+			for _, it := range b.Iterations {
+				for _, name := range it.ToolCalls {
+					calls[name]++
+				}
+			}
+		}
+	}
+
+	for name := range calls {
+		if _, ok := tokens[name]; !ok {
+			tokens[name] = 0
+		}
+	}
+	for name, n := range tokens {
+		tool := ToolStats{Name: name, Calls: calls[name], Tokens: n}
+		if stats.InputTokens > 0 {
+			tool.Share = float64(n) / float64(stats.InputTokens)
+		}
+		stats.Tools = append(stats.Tools, tool)
+	}
+	sort.Slice(stats.Tools, func(i, j int) bool {
+		if stats.Tools[i].Tokens != stats.Tools[j].Tokens {
+			return stats.Tools[i].Tokens > stats.Tools[j].Tokens
+		}
+		return stats.Tools[i].Name < stats.Tools[j].Name
+	})
+
+	for _, c := range categories {
+		c.Share = float64(c.Questions) / float64(labeled)
+		stats.Categories = append(stats.Categories, *c)
+	}
+	sort.Slice(stats.Categories, func(i, j int) bool {
+		if stats.Categories[i].Questions != stats.Categories[j].Questions {
+			return stats.Categories[i].Questions > stats.Categories[j].Questions
+		}
+		return stats.Categories[i].Name < stats.Categories[j].Name
+	})
+	return stats
+}
+
+// printStats prints token usage for the terminal
+func printStats(stats *Stats) {
+	if stats.Answers == 0 {
+		fmt.Println("No token usage recorded yet. Ask a question first.")
+		return
+	}
+
+	fmt.Printf("%s %d (%d model requests, %.1f per answer)\n", ui.Bold.Render("Answers:"),
+		stats.Answers, stats.Requests, float64(stats.Requests)/float64(stats.Answers))
+	fmt.Printf("%s %d in, %d out\n", ui.Bold.Render("Tokens:"), stats.InputTokens, stats.OutputTokens)
+	printCategoryStats(stats.Categories)
+	if len(stats.Tools) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%s\n", ui.Bold.Render("Prompt tokens from tool outputs:"))
+	toolTotal := 0
+	for _, t := range stats.Tools {
+		perCall := 0
+		if t.Calls > 0 {
+			perCall = t.Tokens / t.Calls
+		}
+		fmt.Printf("  %-16s %10d  %5.1f%%  %s\n", t.Name, t.Tokens, t.Share*100,
+			ui.Dim.Render(fmt.Sprintf("%d calls, ~%d per call", t.Calls, perCall)))
+		toolTotal += t.Tokens
+	}
+	if rest := stats.InputTokens - toolTotal; rest > 0 {
+		fmt.Printf("  %-16s %10d  %5.1f%%  %s\n", "other", rest, float64(rest)/float64(stats.InputTokens)*100,
+			ui.Dim.Render("system prompt, tool definitions, questions and answers"))
+	}
+}
+
+// printCategoryStats prints how questions split across their labels
+func printCategoryStats(categories []CategoryStats) {
+	if len(categories) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%s\n", ui.Bold.Render("Questions by kind:"))
+	for _, c := range categories {
+		detail := "no fresh answers"
+		if c.Answers > 0 {
+			detail = fmt.Sprintf("~%d tokens per answer", (c.InputTokens+c.OutputTokens)/c.Answers)
+		}
+		fmt.Printf("  %-16s %10d  %5.1f%%  %s\n", c.Name, c.Questions, c.Share*100, ui.Dim.Render(detail))
+	}
+}
+
+// runBudgetStats prints this month's spend against the budget
+func runBudgetStats(outputFormat string) error {
+	cfg, paths, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	now := time.Now()
+	spend, err := storage.NewStorage(paths.DataDir).LoadSpend(now)
+	if err != nil {
+		return err
+	}
+	stats := collectBudgetStats(spend, cfg.Budget.MonthlyUSD, now)
+
+	if outputFormat == "json" {
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	printBudgetStats(stats, cfg)
+	return nil
+}
+
+// collectBudgetStats works out the burn rate of a month's spend as of now
+func collectBudgetStats(spend *storage.Spend, limit float64, now time.Time) *BudgetStats {
+	stats := &BudgetStats{
+		Month:        spend.Month,
+		LimitUSD:     limit,
+		SpentUSD:     spend.USD,
+		TodayUSD:     spend.Days[now.Format("02")],
+		Requests:     spend.Requests,
+		InputTokens:  spend.InputTokens,
+		OutputTokens: spend.OutputTokens,
+		Models:       []ModelSpend{},
+	}
+	if limit > 0 {
+		stats.Share = spend.USD / limit
+	}
+
+	// Count the current day as elapsed, so a morning's spend isn't
+	// extrapolated over the whole day
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	end := start.AddDate(0, 1, 0)
+	elapsed := float64(now.Day())
+	stats.BurnRateUSD = spend.USD / elapsed
+	stats.ProjectedUSD = stats.BurnRateUSD * end.Sub(start).Hours() / 24
+
+	if limit > 0 && stats.BurnRateUSD > 0 && spend.USD < limit {
+		daysLeft := (limit - spend.USD) / stats.BurnRateUSD
+		runsOut := now.Add(time.Duration(daysLeft * 24 * float64(time.Hour)))
+		if runsOut.Before(end) {
+			stats.RunsOut = runsOut.Format("2006-01-02")
+		}
+	}
+
+	for name, usd := range spend.Models {
+		stats.Models = append(stats.Models, ModelSpend{Name: name, USD: usd})
+	}
+	sort.Slice(stats.Models, func(i, j int) bool {
+		if stats.Models[i].USD != stats.Models[j].USD {
+			return stats.Models[i].USD > stats.Models[j].USD
+		}
+		return stats.Models[i].Name < stats.Models[j].Name
+	})
+	return stats
+}
+
+// printBudgetStats prints the month's spend for the terminal
+func printBudgetStats(stats *BudgetStats, cfg *config.Config) {
+	if stats.LimitUSD > 0 {
+		fmt.Printf("%s $%.2f of $%.2f (%.1f%%) in %s\n", ui.Bold.Render("Spent:"),
+			stats.SpentUSD, stats.LimitUSD, stats.Share*100, stats.Month)
+	} else {
+		fmt.Printf("%s $%.2f in %s %s\n", ui.Bold.Render("Spent:"), stats.SpentUSD, stats.Month,
+			ui.Dim.Render("(no budget.monthlyUSD set)"))
+	}
+	fmt.Printf("%s $%.2f today, %d requests, %d tokens in, %d out\n", ui.Bold.Render("Usage:"),
+		stats.TodayUSD, stats.Requests, stats.InputTokens, stats.OutputTokens)
+	fmt.Printf("%s $%.2f/day, $%.2f projected for the month\n", ui.Bold.Render("Burn rate:"),
+		stats.BurnRateUSD, stats.ProjectedUSD)
+	switch {
+	case stats.LimitUSD <= 0:
+	case stats.SpentUSD >= stats.LimitUSD:
+		fmt.Println(ui.Dim.Render("The budget is spent; model requests need --force until next month."))
+	case stats.RunsOut != "":
+		fmt.Println(ui.Dim.Render(fmt.Sprintf("At this rate the budget runs out on %s.", stats.RunsOut)))
+	default:
+		fmt.Println(ui.Dim.Render("At this rate the budget lasts the month."))
+	}
+
+	if len(stats.Models) > 0 {
+		fmt.Printf("\n%s\n", ui.Bold.Render("By model:"))
+		for _, m := range stats.Models {
+			fmt.Printf("  %-16s %10s\n", m.Name, fmt.Sprintf("$%.2f", m.USD))
+		}
+	}
+
+	var unpriced []string
+	for _, m := range cfg.Models {
+		if m.Pricing == nil {
+			unpriced = append(unpriced, m.Name)
+		}
+	}
+	if len(unpriced) > 0 {
+		fmt.Printf("\n%s\n", ui.Dim.Render("No pricing (not counted): "+strings.Join(unpriced, ", ")))
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/nickcecere/btcx/internal/agent"
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/resource"
+	"github.com/nickcecere/btcx/internal/summary"
+	"github.com/nickcecere/btcx/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func summarizeCmd() *cobra.Command {
+	var resourceName string
+	var modelName string
+	var refresh bool
+	var noSpinner bool
+
+	cmd := &cobra.Command{
+		Use:   "summarize",
+		Short: i18n.T("summarize.short"),
+		Long: `Generate an architecture overview of a resource from its README, package
+manifests and directory layout.
+
+The overview is saved as markdown in the cache and added to the context of later
+questions about the resource. It is reused until the resource changes or
+--refresh is given.`,
+		Example: `  btcx summarize -r svelte
+  btcx summarize -r svelte --refresh -m claude`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, paths, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if resourceName == "" {
+				return fmt.Errorf("a resource is required (-r flag)")
+			}
+
+			r, ok := cfg.GetResource(resourceName)
+			if !ok {
+				return fmt.Errorf("resource %q not found in config", resourceName)
+			}
+
+			fmt.Fprintf(os.Stderr, "Preparing resources...\n")
+			mgr := resource.NewManager(cfg.Cache.ResolvedPath).WithLockFile(cfg.ResolvedLockFile)
+			collection, err := mgr.EnsureCollection(context.Background(), []*config.Resource{r})
+			if err != nil {
+				return fmt.Errorf("failed to prepare resources: %w", err)
+			}
+
+			// Reuse the stored overview while the resource is unchanged
+			store := summary.New(mgr.SummariesDir())
+			if !refresh {
+				existing, err := store.Load(resourceName)
+				if err != nil {
+					return err
+				}
+				if existing != nil && existing.Current(collection.Versions(nil)[resourceName]) {
+					fmt.Fprintf(os.Stderr, "Using overview generated %s (use --refresh to regenerate)\n",
+						existing.Created.Format("2006-01-02 15:04"))
+					return printSummary(cfg, existing, store, nil)
+				}
+			}
+
+			modelCfg, err := cfg.GetModelConfig(modelName)
+			if err != nil {
+				return fmt.Errorf("failed to get model: %w", err)
+			}
+
+			a, err := agent.New(agent.Options{
+				Config:      cfg,
+				ModelConfig: modelCfg,
+				Collection:  collection,
+				DataDir:     paths.DataDir,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create agent: %w", err)
+			}
+
+			var spinner *ui.Spinner
+			if cfg.Output.Spinner && !noSpinner {
+				spinner = ui.NewSpinner("Summarizing...")
+				spinner.Start()
+			}
+
+			var totalUsage *provider.Usage
+			callback := func(event provider.StreamEvent) {
+				if event.Type == provider.StreamEventDone && event.Usage != nil {
+					totalUsage = event.Usage
+				}
+			}
+
+			s, resp, err := a.Summarize(context.Background(), resourceName, callback)
+
+			if spinner != nil {
+				spinner.Stop()
+			}
+
+			if err != nil {
+				return fmt.Errorf("failed to summarize %s: %w", resourceName, err)
+			}
+			if totalUsage == nil {
+				totalUsage = &resp.Usage
+			}
+
+			return printSummary(cfg, s, store, totalUsage)
+		},
+	}
+
+	cmd.Flags().StringVarP(&resourceName, "resource", "r", "", "Resource to summarize")
+	cmd.Flags().StringVarP(&modelName, "model", "m", "", "Model to use (from config)")
+	cmd.Flags().BoolVar(&refresh, "refresh", false, "Regenerate the overview even if it is up to date")
+	cmd.Flags().BoolVar(&noSpinner, "no-spinner", false, "Disable the animated spinner")
+
+	return cmd
+}
+
+// printSummary prints an overview and where it is stored
+func printSummary(cfg *config.Config, s *summary.Summary, store *summary.Store, usage *provider.Usage) error {
+	if err := outputDocument(cfg, "Overview: "+s.Resource, s.Content, usage); err != nil {
+		return err
+	}
+	fmt.Println(ui.Usage.Render(fmt.Sprintf("[Saved to %s]", store.Path(s.Resource))))
+	return nil
+}
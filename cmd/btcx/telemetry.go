@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
+	"github.com/nickcecere/btcx/internal/telemetry"
+	"github.com/nickcecere/btcx/internal/ui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func telemetryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "telemetry",
+		Short: i18n.T("telemetry.short"),
+		Long: `Telemetry is off unless you turn it on. When on, each command reports its name, the names of the
+flags that were set (never their values), how long it took, the class of any error (e.g. "network"),
+the btcx version and the OS. Questions, answers, resource names, paths and error messages are never sent.
+
+DO_NOT_TRACK=1 or BTCX_TELEMETRY=off turns telemetry off regardless of this setting.`,
+	}
+
+	cmd.AddCommand(telemetrySetCmd("on", true))
+	cmd.AddCommand(telemetrySetCmd("off", false))
+	cmd.AddCommand(telemetryStatusCmd())
+
+	return cmd
+}
+
+func telemetrySetCmd(use string, enabled bool) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: i18n.T("telemetry." + use + ".short"),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			paths, err := config.ResolvePaths()
+			if err != nil {
+				return err
+			}
+
+			if _, err := telemetry.Save(paths.DataDir, enabled); err != nil {
+				return err
+			}
+
+			if !enabled {
+				fmt.Println("Telemetry is off.")
+				return nil
+			}
+			fmt.Println("Telemetry is on. Thank you!")
+			fmt.Println(ui.Dim.Render("Only command names, flag names, durations and error classes are sent. Run 'btcx telemetry off' to stop."))
+			if telemetryEndpoint() == "" {
+				fmt.Println(ui.Dim.Render("No telemetry endpoint is configured, so nothing will be sent until telemetry.endpoint is set."))
+			}
+			return nil
+		},
+	}
+}
+
+func telemetryStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: i18n.T("telemetry.status.short"),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			paths, err := config.ResolvePaths()
+			if err != nil {
+				return err
+			}
+
+			state, err := telemetry.Load(paths.DataDir)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case !state.Enabled:
+				fmt.Println("Telemetry is off.")
+			case telemetry.Disabled():
+				fmt.Println("Telemetry is on, but disabled by DO_NOT_TRACK or BTCX_TELEMETRY.")
+			default:
+				fmt.Println("Telemetry is on.")
+			}
+			if !state.Enabled {
+				return nil
+			}
+
+			endpoint := telemetryEndpoint()
+			if endpoint == "" {
+				endpoint = "(none configured; nothing is sent)"
+			}
+			fmt.Printf("  %s %s\n", ui.Bold.Render("Endpoint:"), endpoint)
+			fmt.Printf("  %s %s\n", ui.Bold.Render("Install ID:"), state.ID)
+			fmt.Printf("  %s %s\n", ui.Bold.Render("Since:"), state.Updated.Format("2006-01-02 15:04"))
+			return nil
+		},
+	}
+}
+
+// telemetryEndpoint returns the configured endpoint or the built-in one
+func telemetryEndpoint() string {
+	if cfg, _, err := config.Load(); err == nil && cfg.Telemetry.Endpoint != "" {
+		return cfg.Telemetry.Endpoint
+	}
+	return telemetry.DefaultEndpoint
+}
+
+// reportTelemetry sends a usage event for a finished command if the user
+// turned telemetry on
+// Failures are ignored; telemetry never affects the command's outcome
+func reportTelemetry(cmd *cobra.Command, duration time.Duration, runErr error) {
+	if cmd == nil || telemetry.Disabled() {
+		return
+	}
+
+	name := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name())
+	name = strings.TrimSpace(name)
+	if name == "" || strings.HasPrefix(name, "telemetry") {
+		return
+	}
+
+	paths, err := config.ResolvePaths()
+	if err != nil {
+		return
+	}
+	state, err := telemetry.Load(paths.DataDir)
+	if err != nil || !state.Enabled {
+		return
+	}
+	endpoint := telemetryEndpoint()
+	if endpoint == "" {
+		return
+	}
+
+	var flags []string
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		flags = append(flags, f.Name)
+	})
+
+	event := telemetry.NewEvent(state.ID, version, name, flags, duration, runErr)
+	_ = telemetry.Send(context.Background(), endpoint, event)
+}
@@ -1,24 +1,33 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
+	"text/tabwriter"
 	"time"
 
+	"github.com/nickcecere/btcx/internal/agent"
 	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
 	"github.com/nickcecere/btcx/internal/storage"
+	"github.com/nickcecere/btcx/internal/ui"
 	"github.com/spf13/cobra"
 )
 
 func threadsCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "threads",
-		Short: "Manage conversation threads",
+		Short: i18n.T("threads.short"),
 		Long:  `View, continue, and delete conversation threads.`,
 	}
 
 	cmd.AddCommand(threadsListCmd())
 	cmd.AddCommand(threadsShowCmd())
+	cmd.AddCommand(threadsSummarizeCmd())
 	cmd.AddCommand(threadsDeleteCmd())
 	cmd.AddCommand(threadsClearCmd())
 
@@ -28,11 +37,11 @@ func threadsCmd() *cobra.Command {
 func threadsListCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "list",
-		Short: "List all threads",
+		Short: i18n.T("threads.list.short"),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			_, paths, err := config.Load()
+			paths, err := config.ResolvePaths()
 			if err != nil {
-				return fmt.Errorf("failed to load config: %w", err)
+				return fmt.Errorf("failed to resolve paths: %w", err)
 			}
 
 			store := storage.NewStorage(paths.DataDir)
@@ -52,7 +61,11 @@ func threadsListCmd() *cobra.Command {
 				age := formatAge(t.Updated)
 				resources := strings.Join(t.Resources, ", ")
 				fmt.Printf("  %s\n", t.ID)
-				fmt.Printf("    Title:     %s\n", t.Title)
+				if t.Digest != nil {
+					fmt.Printf("    Summary:   %s\n", t.Digest.Summary)
+				} else {
+					fmt.Printf("    Title:     %s\n", t.Title)
+				}
 				fmt.Printf("    Updated:   %s\n", age)
 				fmt.Printf("    Resources: %s\n", resources)
 				fmt.Printf("    Messages:  %d\n", len(t.Messages))
@@ -65,16 +78,25 @@ func threadsListCmd() *cobra.Command {
 }
 
 func threadsShowCmd() *cobra.Command {
-	return &cobra.Command{
+	var evidence bool
+	var tools bool
+
+	cmd := &cobra.Command{
 		Use:   "show <id>",
-		Short: "Show thread details",
-		Args:  cobra.ExactArgs(1),
+		Short: i18n.T("threads.show.short"),
+		Long: `Show a thread's messages.
+
+With --tools, show each tool call's arguments, duration, result size and truncation in a table.
+With --evidence, print the tool calls behind each answer with their full outputs instead. Outputs that were
+truncated are read from their saved files; their reference IDs (e.g. read-1a2b3c4d) match the evidence IDs
+in answer citations.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			id := args[0]
 
-			_, paths, err := config.Load()
+			paths, err := config.ResolvePaths()
 			if err != nil {
-				return fmt.Errorf("failed to load config: %w", err)
+				return fmt.Errorf("failed to resolve paths: %w", err)
 			}
 
 			store := storage.NewStorage(paths.DataDir)
@@ -84,6 +106,11 @@ func threadsShowCmd() *cobra.Command {
 				return err
 			}
 
+			if evidence {
+				printThreadEvidence(thread)
+				return nil
+			}
+
 			fmt.Printf("Thread: %s\n", thread.ID)
 			fmt.Printf("Title: %s\n", thread.Title)
 			fmt.Printf("Created: %s\n", thread.Created.Format(time.RFC3339))
@@ -91,10 +118,35 @@ func threadsShowCmd() *cobra.Command {
 			fmt.Printf("Resources: %s\n", strings.Join(thread.Resources, ", "))
 			fmt.Printf("Provider: %s\n", thread.Provider)
 			fmt.Printf("Model: %s\n", thread.Model)
+			printSnapshots(thread.Snapshots)
+			if thread.Digest != nil {
+				fmt.Println()
+				printDigest(thread)
+			}
+			if len(thread.Notes) > 0 {
+				fmt.Printf("\n%s\n", ui.Bold.Render("Notes:"))
+				for i, note := range thread.Notes {
+					fmt.Printf("  %d. %s\n", i+1, note)
+				}
+			}
 			fmt.Printf("\nMessages (%d):\n\n", len(thread.Messages))
 
+			results := toolResults(thread)
+
 			for i, msg := range thread.Messages {
-				fmt.Printf("--- Message %d (%s) ---\n", i+1, msg.Role)
+				// The tables already show the tool results
+				if tools && msg.Role == "tool" {
+					continue
+				}
+
+				label := msg.Role
+				if msg.Model != "" {
+					label += ", " + msg.Model
+				}
+				if msg.Retry {
+					label += ", retry"
+				}
+				fmt.Printf("--- Message %d (%s) ---\n", i+1, label)
 				if msg.Content != "" {
 					// Truncate long messages
 					content := msg.Content
@@ -103,7 +155,9 @@ func threadsShowCmd() *cobra.Command {
 					}
 					fmt.Printf("%s\n", content)
 				}
-				if len(msg.ToolCalls) > 0 {
+				if len(msg.ToolCalls) > 0 && tools {
+					printToolCalls(msg.ToolCalls, results)
+				} else if len(msg.ToolCalls) > 0 {
 					fmt.Printf("Tool calls: ")
 					for _, tc := range msg.ToolCalls {
 						fmt.Printf("%s ", tc.Name)
@@ -116,19 +170,240 @@ func threadsShowCmd() *cobra.Command {
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&tools, "tools", false, "Show a table of tool calls with arguments, durations and result sizes")
+	cmd.Flags().BoolVar(&evidence, "evidence", false, "Print the full tool outputs behind each answer")
+
+	return cmd
+}
+
+// printThreadEvidence prints each question's tool calls with their full
+// outputs, read from the saved output files where the result was truncated
+func printThreadEvidence(thread *storage.Thread) {
+	results := toolResults(thread)
+
+	count := 0
+	for _, msg := range thread.Messages {
+		if msg.Role == "user" {
+			fmt.Printf("%s %s\n\n", ui.Bold.Render("Question:"), msg.Content)
+			continue
+		}
+
+		for _, tc := range msg.ToolCalls {
+			count++
+			ref := tc.Evidence
+			if ref == "" {
+				ref = tc.ID
+			}
+			fmt.Printf("--- [%s] %s %s ---\n", ref, tc.Name, compactJSON(tc.Arguments))
+
+			result, ok := results[tc.ID]
+			switch {
+			case !ok:
+				fmt.Println(ui.Dim.Render("(no result recorded)"))
+			case result.Error != "":
+				fmt.Printf("Error: %s\n", result.Error)
+			case result.OutputFile != "":
+				if data, err := os.ReadFile(result.OutputFile); err == nil {
+					fmt.Println(ui.Dim.Render("Saved output: " + result.OutputFile))
+					fmt.Println(string(data))
+				} else {
+					fmt.Println(ui.Dim.Render("Saved output has expired; showing the truncated output kept in the thread"))
+					fmt.Println(result.Output)
+				}
+			default:
+				fmt.Println(result.Output)
+			}
+			fmt.Println()
+		}
+	}
+
+	if count == 0 {
+		fmt.Println("No tool calls in this thread.")
+	}
+}
+
+// toolResults indexes a thread's tool results by tool call ID
+func toolResults(thread *storage.Thread) map[string]storage.ToolResult {
+	results := make(map[string]storage.ToolResult)
+	for _, msg := range thread.Messages {
+		if msg.Role == "tool" && len(msg.ToolResults) > 0 {
+			results[msg.ToolCallID] = msg.ToolResults[0]
+		}
+	}
+	return results
+}
+
+// maxToolArgsWidth caps the arguments column of tool call tables
+const maxToolArgsWidth = 60
+
+// printToolCalls prints a table of tool calls with their results
+func printToolCalls(calls []storage.ToolCall, results map[string]storage.ToolResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  TOOL\tARGUMENTS\tDURATION\tSIZE\tSTATUS")
+	for _, tc := range calls {
+		args := compactJSON(tc.Arguments)
+		if len(args) > maxToolArgsWidth {
+			args = args[:maxToolArgsWidth-3] + "..."
+		}
+
+		duration, size, status := "-", "-", "no result"
+		if result, ok := results[tc.ID]; ok {
+			// Threads saved before sizes were recorded have neither
+			if result.OutputBytes > 0 {
+				size = formatSize(result.OutputBytes)
+				duration = "<1ms"
+			}
+			if result.DurationMs > 0 {
+				duration = (time.Duration(result.DurationMs) * time.Millisecond).String()
+			}
+			switch {
+			case result.Failed():
+				status = "error"
+			case result.OutputFile != "":
+				status = "truncated, saved as " + storage.EvidenceID(result.OutputFile)
+			case result.Truncated:
+				status = "truncated"
+			default:
+				status = "ok"
+			}
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", tc.Name, args, duration, size, status)
+	}
+	w.Flush()
+}
+
+// compactJSON formats JSON on one line, as is if it isn't valid JSON
+func compactJSON(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// formatSize formats a byte count for display
+func formatSize(n int) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	}
+}
+
+func threadsSummarizeCmd() *cobra.Command {
+	var modelName string
+	var refresh bool
+
+	cmd := &cobra.Command{
+		Use:   "summarize <id>",
+		Short: i18n.T("threads.summarize.short"),
+		Long: `Summarize a thread into key findings, cited files and open questions.
+
+The digest is stored on the thread and shown in 'threads list' instead of the first question.
+It is written by threads.summaryModel (a small fast model works well) or the default model.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, paths, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			store := storage.NewStorage(paths.DataDir).WithSpillBytes(cfg.Threads.SpillBytes)
+
+			thread, err := store.LoadThread(args[0])
+			if err != nil {
+				return err
+			}
+
+			// Keep a digest that still covers the whole thread
+			if thread.DigestCurrent() && !refresh {
+				printDigest(thread)
+				return nil
+			}
+
+			if modelName == "" {
+				modelName = cfg.Threads.SummaryModel
+			}
+			modelCfg, err := cfg.GetModelConfig(modelName)
+			if err != nil {
+				return fmt.Errorf("failed to get model: %w", err)
+			}
+			p, err := agent.NewProvider(cfg, modelCfg)
+			if err != nil {
+				return fmt.Errorf("failed to create provider: %w", err)
+			}
+
+			digest, usage, err := agent.SummarizeThread(context.Background(), p, modelCfg, thread)
+			if err != nil {
+				return fmt.Errorf("failed to summarize thread: %w", err)
+			}
+
+			thread.Digest = digest
+			if err := store.SaveThread(thread); err != nil {
+				return err
+			}
+
+			printDigest(thread)
+			if cfg.Output.ShowUsage {
+				fmt.Println()
+				fmt.Println(ui.Usage.Render(fmt.Sprintf("[Tokens: %d in, %d out]",
+					usage.InputTokens, usage.OutputTokens)))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&modelName, "model", "m", "", "Model to use (from config)")
+	cmd.Flags().BoolVar(&refresh, "refresh", false, "Rewrite the digest even if it is up to date")
+
+	return cmd
+}
+
+// printDigest prints a thread's digest
+func printDigest(thread *storage.Thread) {
+	d := thread.Digest
+	fmt.Printf("%s %s\n", ui.Bold.Render("Summary:"), d.Summary)
+
+	sections := []struct {
+		title string
+		items []string
+	}{
+		{"Key findings", d.Findings},
+		{"Cited files", d.Files},
+		{"Open questions", d.OpenQuestions},
+	}
+	for _, s := range sections {
+		if len(s.items) == 0 {
+			continue
+		}
+		fmt.Printf("\n%s\n", ui.Bold.Render(s.title+":"))
+		for _, item := range s.items {
+			fmt.Printf("  - %s\n", item)
+		}
+	}
+
+	note := fmt.Sprintf("Written by %s on %s", d.Model, d.Created.Format("2006-01-02 15:04"))
+	if !thread.DigestCurrent() {
+		note += fmt.Sprintf(" (out of date: covers %d of %d messages)", d.Messages, len(thread.Messages))
+	}
+	fmt.Printf("\n%s\n", ui.Dim.Render(note))
 }
 
 func threadsDeleteCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "delete <id>",
-		Short: "Delete a thread",
+		Short: i18n.T("threads.delete.short"),
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			id := args[0]
 
-			_, paths, err := config.Load()
+			paths, err := config.ResolvePaths()
 			if err != nil {
-				return fmt.Errorf("failed to load config: %w", err)
+				return fmt.Errorf("failed to resolve paths: %w", err)
 			}
 
 			store := storage.NewStorage(paths.DataDir)
@@ -148,15 +423,15 @@ func threadsClearCmd() *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:   "clear",
-		Short: "Delete all threads",
+		Short: i18n.T("threads.clear.short"),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if !confirm {
 				return fmt.Errorf("use --confirm to delete all threads")
 			}
 
-			_, paths, err := config.Load()
+			paths, err := config.ResolvePaths()
 			if err != nil {
-				return fmt.Errorf("failed to load config: %w", err)
+				return fmt.Errorf("failed to resolve paths: %w", err)
 			}
 
 			store := storage.NewStorage(paths.DataDir)
@@ -202,3 +477,21 @@ func formatAge(t time.Time) string {
 	}
 	return fmt.Sprintf("%d days ago", days)
 }
+
+// printSnapshots prints the resource versions a thread was created against
+func printSnapshots(snapshots []storage.ResourceSnapshot) {
+	if len(snapshots) == 0 {
+		return
+	}
+	fmt.Println("Resource versions:")
+	for _, s := range snapshots {
+		switch {
+		case s.Commit != "":
+			fmt.Printf("  %s: commit %s\n", s.Name, s.Commit)
+		case s.Modified != nil:
+			fmt.Printf("  %s: files modified %s\n", s.Name, s.Modified.Format(time.RFC3339))
+		default:
+			fmt.Printf("  %s: unknown\n", s.Name)
+		}
+	}
+}
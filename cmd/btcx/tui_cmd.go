@@ -6,6 +6,7 @@ import (
 
 	"github.com/nickcecere/btcx/internal/agent"
 	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
 	"github.com/nickcecere/btcx/internal/resource"
 	"github.com/nickcecere/btcx/internal/tui"
 	"github.com/spf13/cobra"
@@ -17,7 +18,7 @@ func tuiCmd() *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:   "tui",
-		Short: "Start interactive TUI mode",
+		Short: i18n.T("tui.short"),
 		Long:  `Start an interactive terminal UI for chatting with the AI about resources.`,
 		Example: `  btcx tui -r svelte
   btcx tui -r svelte -r react
@@ -50,7 +51,7 @@ func tuiCmd() *cobra.Command {
 			}
 
 			// Create resource manager
-			mgr := resource.NewManager(cfg.Cache.ResolvedPath)
+			mgr := resource.NewManager(cfg.Cache.ResolvedPath).WithLockFile(cfg.ResolvedLockFile)
 
 			// Ensure collection
 			fmt.Printf("Preparing resources...\n")
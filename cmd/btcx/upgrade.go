@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/i18n"
+	"github.com/nickcecere/btcx/internal/update"
+	"github.com/spf13/cobra"
+)
+
+func upgradeCmd() *cobra.Command {
+	var check bool
+	var target string
+
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: i18n.T("upgrade.short"),
+		Long: `Check GitHub releases for a newer btcx, download the build for this platform,
+verify it against the release checksums and replace the current binary.
+
+With --check nothing is installed; the command exits with an error when a newer
+release exists, so CI can detect when a pinned version falls behind.`,
+		Example: `  btcx upgrade
+  btcx upgrade --check
+  btcx upgrade --version v0.3.0`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			defer cancel()
+
+			var release *update.Release
+			var err error
+			if target != "" {
+				release, err = update.Tagged(ctx, target)
+			} else {
+				release, err = update.Latest(ctx)
+			}
+			if err != nil {
+				return err
+			}
+
+			newer := update.Newer(release.Version(), version)
+			if check {
+				cmd.SilenceUsage = true
+				fmt.Printf("Current: %s\n", version)
+				fmt.Printf("Latest:  %s\n", release.TagName)
+				if newer {
+					return fmt.Errorf("a newer release is available: %s", release.HTMLURL)
+				}
+				fmt.Println("btcx is up to date.")
+				return nil
+			}
+
+			if target == "" && !newer {
+				fmt.Printf("btcx %s is up to date.\n", version)
+				return nil
+			}
+
+			exe, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("failed to locate executable: %w", err)
+			}
+			if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+				exe = resolved
+			}
+
+			fmt.Printf("Downloading %s...\n", update.ArchiveName(release.Version()))
+			binary, err := update.Download(ctx, release)
+			if err != nil {
+				return err
+			}
+
+			if err := update.Replace(exe, binary); err != nil {
+				return err
+			}
+
+			fmt.Printf("Upgraded btcx %s -> %s (%s)\n", version, release.TagName, exe)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&check, "check", false, "Only check for a newer release; exit non-zero if one exists")
+	cmd.Flags().StringVar(&target, "version", "", "Install a specific release tag instead of the latest")
+
+	return cmd
+}
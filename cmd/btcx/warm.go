@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
+	"github.com/nickcecere/btcx/internal/index"
+	"github.com/nickcecere/btcx/internal/resource"
+	"github.com/spf13/cobra"
+)
+
+func warmCmd() *cobra.Command {
+	var resources []string
+
+	cmd := &cobra.Command{
+		Use:   "warm",
+		Short: i18n.T("warm.short"),
+		Long: `Do the work of a first ask ahead of time: fetch the resources, build the collection ask uses for the
+same -r flags, record the file lists the answer cache checks, and build the semantic_search indexes
+(when tools.semanticSearch is on). Meant for CI images and first-day setup.
+
+Without -r every configured resource is warmed in a collection of its own.`,
+		Example: `  btcx warm
+  btcx warm -r svelte -r sveltekit
+  btcx warm -r svelte:packages/svelte/src`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, _, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			names, narrowed, err := narrowResources(resources, nil)
+			if err != nil {
+				return err
+			}
+			// Resources named together share a collection, as in ask
+			sets := [][]string{names}
+			if len(names) == 0 {
+				sets = nil
+				for _, r := range cfg.Resources {
+					sets = append(sets, []string{r.Name})
+				}
+			}
+			if len(sets) == 0 {
+				fmt.Println("No resources configured.")
+				return nil
+			}
+
+			mgr := resource.NewManager(cfg.Cache.ResolvedPath).WithLockFile(cfg.ResolvedLockFile)
+			ctx := context.Background()
+			start := time.Now()
+
+			var indexDir string
+			if cfg.Tools.SemanticSearch {
+				indexDir = filepath.Join(cfg.Cache.ResolvedPath, "index")
+			}
+
+			failed := 0
+			for _, set := range sets {
+				var configResources []*config.Resource
+				for _, name := range set {
+					r, ok := cfg.GetResource(name)
+					if !ok {
+						return fmt.Errorf("resource %q not found in config", name)
+					}
+					if searchPath, ok := narrowed[name]; ok {
+						copied := *r
+						copied.SearchPath = searchPath
+						r = &copied
+					}
+					configResources = append(configResources, r)
+				}
+				if err := warmCollection(ctx, mgr, configResources, indexDir); err != nil {
+					fmt.Fprintf(os.Stderr, "  Error: %v\n", err)
+					failed++
+				}
+			}
+
+			if failed > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d of %d collections failed to warm", failed, len(sets))
+			}
+			fmt.Printf("Done in %s.\n", time.Since(start).Round(100*time.Millisecond))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&resources, "resource", "r", nil, "Resource(s) to warm together (default: all); name:path warms only that directory")
+
+	return cmd
+}
+
+// warmCollection fetches resources, builds their collection and file lists,
+// and builds or refreshes their indexes in indexDir ("" to skip them)
+func warmCollection(ctx context.Context, mgr *resource.Manager, resources []*config.Resource, indexDir string) error {
+	var names []string
+	for _, r := range resources {
+		names = append(names, r.Name)
+	}
+	label := strings.Join(names, ", ")
+
+	step := time.Now()
+	fmt.Printf("Preparing %s...\n", label)
+	collection, err := mgr.EnsureCollection(ctx, resources)
+	if err != nil {
+		return fmt.Errorf("failed to prepare %s: %w", label, err)
+	}
+	if _, err := collection.Manifests(ctx); err != nil {
+		return fmt.Errorf("failed to list files of %s: %w", label, err)
+	}
+	fmt.Printf("  collection %s (%s)\n", collection.Name, time.Since(step).Round(100*time.Millisecond))
+
+	if indexDir == "" {
+		return nil
+	}
+	for _, r := range collection.Resources {
+		// The same root the semantic_search tool indexes
+		root, err := filepath.EvalSymlinks(filepath.Join(collection.Path, r.Name))
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", r.Name, err)
+		}
+		step = time.Now()
+		idx, err := index.Load(ctx, indexDir, root)
+		if err != nil {
+			return fmt.Errorf("failed to index %s: %w", r.Name, err)
+		}
+		fmt.Printf("  index %s: %d chunks (%s)\n", r.Name, len(idx.Chunks()), time.Since(step).Round(100*time.Millisecond))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/config"
+)
+
+// workspaceNotes tell the model what the workspace resource is
+const workspaceNotes = "The user's own project (the current working directory). " +
+	"Questions about \"my code\" or \"our app\" refer to this; the other resources are libraries it uses."
+
+// workspaceResource returns the current repository as an ad-hoc local
+// resource, for ask --here
+// The git root containing the working directory is used, or the working
+// directory itself outside a repository
+func workspaceResource(cfg *config.Config) (*config.Resource, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	root := gitRoot(cwd)
+	if root == "" {
+		root = cwd
+	}
+
+	// Don't shadow a configured resource of the same name
+	name := workspaceName(root)
+	if _, exists := cfg.GetResource(name); exists {
+		name += "-workspace"
+	}
+
+	return &config.Resource{
+		Name:  name,
+		Type:  config.ResourceTypeLocal,
+		Path:  root,
+		Notes: workspaceNotes,
+	}, nil
+}
+
+// gitRoot returns the closest directory at or above dir containing .git
+// ("" if there is none)
+func gitRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// workspaceName turns a directory into a resource name, e.g.
+// "/src/My App" -> "my-app"
+func workspaceName(dir string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '-'
+		}
+	}, filepath.Base(dir))
+	if name = strings.Trim(name, "-."); name == "" {
+		return "workspace"
+	}
+	return name
+}
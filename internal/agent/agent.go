@@ -1,10 +1,20 @@
 package agent
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/answercache"
+	"github.com/nickcecere/btcx/internal/audit"
 	"github.com/nickcecere/btcx/internal/config"
 	"github.com/nickcecere/btcx/internal/provider"
 	"github.com/nickcecere/btcx/internal/resource"
+	"github.com/nickcecere/btcx/internal/search"
 	"github.com/nickcecere/btcx/internal/storage"
+	"github.com/nickcecere/btcx/internal/summary"
 	"github.com/nickcecere/btcx/internal/tool"
 )
 
@@ -30,6 +40,66 @@ type Agent struct {
 
 	// Thread is the current conversation thread
 	Thread *storage.Thread
+
+	// AnswerCache caches answers to near-duplicate questions (nil if disabled)
+	AnswerCache *answercache.Cache
+
+	// Summaries stores generated resource overviews
+	Summaries *summary.Store
+
+	// overviews is the system prompt section with stored resource overviews
+	overviews string
+
+	// guides are the resources' prompt files by resource name
+	guides map[string]string
+
+	// maps are the resources' directory maps by resource name
+	maps map[string]string
+
+	// diffFrom and diffTo are the version range the question is about
+	diffFrom, diffTo string
+
+	// validateExamples checks that code examples in answers build
+	validateExamples bool
+
+	// verifyAnswers has a judge model check answers against the evidence
+	verifyAnswers bool
+
+	// scope is the kind of files searches default to
+	scope search.Scope
+
+	// boostSetting is tools.boost or its override; boost is the kind of
+	// files the current question's searches list first
+	boostSetting string
+	boost        search.Scope
+
+	// templates are the user's prompt overrides
+	templates *PromptTemplates
+
+	// language is the language answers are written in ("" for the default)
+	language string
+
+	// brevity is how long and detailed answers are
+	brevity config.Brevity
+
+	// postProcessors rewrite final answers before they are shown or stored
+	postProcessors []postProcessor
+
+	// searchHint holds suggested search terms for the current question
+	searchHint string
+
+	// followUp outlines the files cited in the previous answer, for
+	// follow-up questions
+	followUp string
+
+	// retrying is set while Retry asks a question again
+	retrying bool
+
+	// triage is the model running searches for strategy: triage (nil until used)
+	triage *triage
+
+	// session records or replays the agent's requests (nil if neither)
+	session *Session
 }
 
 // Options are options for creating a new agent
@@ -39,6 +109,43 @@ type Options struct {
 	Collection  *resource.Collection
 	DataDir     string
 	Thread      *storage.Thread
+
+	// Namespace separates stored threads (e.g. per server user)
+	Namespace string
+
+	// NoAnswerCache bypasses the answer cache for this agent
+	NoAnswerCache bool
+
+	// DiffFrom and DiffTo focus answers on the changes between two versions
+	// of the resources; setting DiffFrom enables the gitdiff tool
+	DiffFrom string
+	DiffTo   string
+
+	// ValidateExamples type-checks code examples in answers, as if
+	// examples.validate were set in the config
+	ValidateExamples bool
+
+	// Verify has a judge model score answers, as if verify.enabled were set
+	// in the config
+	Verify bool
+
+	// Scope restricts searches to docs, code or tests unless the model asks
+	// for another scope
+	Scope search.Scope
+
+	// Boost overrides tools.boost: auto, off, or the scope whose matches
+	// searches list first
+	Boost string
+
+	// Language overrides output.language for the answers
+	Language string
+
+	// Brevity overrides output.brevity for the answers
+	Brevity config.Brevity
+
+	// Session records the agent's model requests and tool calls, or answers
+	// them from a recording when loaded with LoadSession
+	Session *Session
 }
 
 // New creates a new agent
@@ -54,23 +161,166 @@ func New(opts Options) (*Agent, error) {
 	}
 
 	// Create provider from model config
-	p, err := provider.NewFromModelConfig(modelCfg)
+	var p provider.Provider
+	var err error
+	if opts.Session != nil {
+		opts.Session.start(opts, modelCfg)
+		p, err = opts.Session.provider(opts.Config, modelCfg)
+	} else {
+		p, err = NewProvider(opts.Config, modelCfg)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	// Create tool registry with collection path as working directory
-	tools := tool.DefaultRegistry(opts.Collection.Path)
+	tools := tool.DefaultRegistry(opts.Collection.Path, opts.Config.Tools.Sandbox)
 
 	// Set output directory for truncation
 	if opts.Config.Output.ResolvedOutputDir != "" {
 		tools.SetOutputDir(opts.Config.Output.ResolvedOutputDir)
 	}
 
+	// Enable hybrid retrieval over a local chunk index
+	if opts.Config.Tools.SemanticSearch {
+		tools.SetIndexDir(filepath.Join(opts.Config.Cache.ResolvedPath, "index"))
+	}
+
+	// Enable diffs between resource versions
+	if opts.Config.Tools.GitDiff || opts.DiffFrom != "" {
+		tools.EnableGitDiff()
+	}
+
+	tools.SetGrepFormat(opts.Config.Tools.GrepFormat)
+
+	// Searches shell out to the configured ripgrep
+	search.SetRipgrep(opts.Config.Tools.Ripgrep)
+
+	// Let the read tool return images to models that can see them
+	tools.SetImages(modelCfg.SupportsImages())
+
+	// Register user-defined command tools
+	for _, c := range opts.Config.Tools.Custom {
+		tools.Register(tool.NewCustomTool(opts.Collection.Path, c.Name, c.Description, c.CommandPath(), c.Args,
+			c.AllowFlagValues, c.Schema, c.Env, time.Duration(c.Timeout)*time.Second))
+	}
+
+	// Default the search tools to the requested kind of files
+	tools.SetScope(opts.Scope)
+
+	// Apply configured tool limits
+	for _, t := range tools.List() {
+		l := opts.Config.Tools.LimitsFor(t.Name())
+		tools.SetLimits(t.Name(), tool.Limits{
+			MaxOutputBytes:   l.MaxOutputBytes,
+			MaxOutputLines:   l.MaxOutputLines,
+			MaxReadBytes:     l.MaxReadBytes,
+			DefaultReadLines: l.DefaultReadLines,
+			MaxLineLength:    l.MaxLineLength,
+			MaxMatches:       l.MaxMatches,
+			MaxFiles:         l.MaxFiles,
+		})
+	}
+
+	// Record tool calls to the audit log
+	if opts.Config.Audit.Enabled {
+		tools.SetAuditLog(audit.New(opts.Config.Audit.ResolvedPath), opts.Namespace)
+	}
+
+	// Record tool calls to the session, or answer them from it
+	if opts.Session != nil {
+		opts.Session.tools(tools)
+	}
+
 	// Create storage
-	store := storage.NewStorage(opts.DataDir)
+	store := storage.NewStorage(opts.DataDir).WithNamespace(opts.Namespace).WithSpillBytes(opts.Config.Threads.SpillBytes)
 
-	return &Agent{
+	language := strings.TrimSpace(opts.Config.Output.Language)
+	if opts.Language != "" {
+		language = strings.TrimSpace(opts.Language)
+	}
+	brevity := opts.Config.Output.Brevity
+	if opts.Brevity != "" {
+		brevity = opts.Brevity
+	}
+	brevity, err = config.ParseBrevity(string(brevity))
+	if err != nil {
+		return nil, err
+	}
+	boostSetting := opts.Config.Tools.Boost
+	if opts.Boost != "" {
+		boostSetting = opts.Boost
+	}
+
+	postProcessors, err := newPostProcessors(opts.Config.Output.PostProcess)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create answer cache
+	var answers *answercache.Cache
+	// Answers about a version range or scope depend on more than the question,
+	// and recorded sessions run the loop
+	if opts.Config.AnswerCache.Enabled && !opts.NoAnswerCache && opts.DiffFrom == "" && opts.Scope == search.ScopeAll && opts.Session == nil {
+		// Answers in other languages are kept apart
+		dir := filepath.Join(opts.Config.Cache.ResolvedPath, "answers")
+		if language != "" {
+			dir = filepath.Join(dir, languageKey(language))
+		}
+		// and so are answers of another length
+		if brevity != config.BrevityNormal {
+			dir = filepath.Join(dir, string(brevity))
+		}
+		// and so are answers processed by other steps
+		if len(opts.Config.Output.PostProcess) > 0 {
+			dir = filepath.Join(dir, postProcessKey(opts.Config.Output.PostProcess))
+		}
+		answers = answercache.New(dir, opts.Config.AnswerCache.Threshold)
+	}
+
+	// Load overviews generated by btcx summarize
+	summaries := summary.New(filepath.Join(opts.Config.Cache.ResolvedPath, "summaries"))
+	var loaded []*summary.Summary
+	for _, r := range opts.Collection.Resources {
+		if s, err := summaries.Load(r.Name); err == nil && s != nil {
+			loaded = append(loaded, s)
+		}
+	}
+	var overviews string
+	if len(loaded) > 0 {
+		overviews = OverviewsHint(loaded, opts.Collection.Versions(nil))
+	}
+
+	// Load prompt overrides from ~/.config/btcx/prompts
+	var templates *PromptTemplates
+	if paths, err := config.ResolvePaths(); err == nil {
+		templates, err = LoadPromptTemplates(paths.PromptsDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Load resource prompt files
+	guides := make(map[string]string)
+	for _, r := range opts.Collection.Resources {
+		if r.PromptFile == "" {
+			continue
+		}
+		guide, err := LoadGuide(r.PromptFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: resource %q: %v\n", r.Name, err)
+			continue
+		}
+		guides[r.Name] = guide
+	}
+
+	// Map the resources' layouts, cached per resource version
+	var maps map[string]string
+	if opts.Config.Tools.DirectoryMap {
+		maps = LoadDirectoryMaps(filepath.Join(opts.Config.Cache.ResolvedPath, "dirmaps"), opts.Collection)
+	}
+
+	agent := &Agent{
 		Config:      opts.Config,
 		ModelConfig: modelCfg,
 		Provider:    p,
@@ -78,12 +328,109 @@ func New(opts Options) (*Agent, error) {
 		Tools:       tools,
 		Storage:     store,
 		Thread:      opts.Thread,
-	}, nil
+		AnswerCache: answers,
+		Summaries:   summaries,
+		overviews:   overviews,
+		guides:      guides,
+		maps:        maps,
+		diffFrom:    opts.DiffFrom,
+		diffTo:      opts.DiffTo,
+
+		validateExamples: opts.Config.Examples.Validate || opts.ValidateExamples,
+		verifyAnswers:    opts.Config.Verify.Enabled || opts.Verify,
+		scope:            opts.Scope,
+		boostSetting:     boostSetting,
+		language:         language,
+		brevity:          brevity,
+		templates:        templates,
+		postProcessors:   postProcessors,
+		session:          opts.Session,
+	}
+
+	// Keep the model's working notes on the thread
+	if opts.Config.Tools.Notes {
+		tools.EnableNotes(threadNotes{agent: agent})
+	}
+
+	return agent, nil
+}
+
+// NewProvider creates the provider for a model, answering repeated identical
+// requests from the response cache when responseCache is enabled, holding the
+// rest to the model's rateLimit and queueing them in the shared scheduler when
+// it limits the model
+func NewProvider(cfg *config.Config, modelCfg *config.ModelConfig) (provider.Provider, error) {
+	p, err := provider.NewFromModelConfig(modelCfg)
+	if err != nil {
+		return nil, err
+	}
+	// Cached responses don't wait for a slot
+	if key, limit := cfg.Scheduler.Lane(modelCfg); limit > 0 {
+		scheduler := provider.SharedScheduler()
+		scheduler.SetLimit(key, limit)
+		p = provider.NewScheduled(p, scheduler, key)
+	}
+	// Requests wait for the model's quota before taking a scheduler slot
+	if r := modelCfg.RateLimit; r != nil {
+		if limiter := provider.SharedRateLimiter(modelCfg.Name, r.RequestsPerMinute, r.TokensPerMinute); limiter != nil {
+			p = provider.NewRateLimited(p, limiter)
+		}
+	}
+	// Cached responses cost nothing, so they aren't metered
+	meter, err := newBudgetMeter(cfg, modelCfg)
+	if err != nil {
+		return nil, err
+	}
+	if meter != nil {
+		p = provider.NewMetered(p, meter)
+	}
+	if cfg.ResponseCache.Enabled {
+		scope := string(modelCfg.Provider) + " " + modelCfg.BaseURL
+		dir := filepath.Join(cfg.Cache.ResolvedPath, "responses")
+		p = provider.NewCached(p, scope, dir, time.Duration(cfg.ResponseCache.TTL)*time.Hour)
+	}
+	return p, nil
+}
+
+// answerTokens are the max tokens of answer requests for each answer style
+var answerTokens = map[config.Brevity]int{
+	config.BrevityShort:  2048,
+	config.BrevityNormal: 8192,
+	config.BrevityDeep:   16384,
+}
+
+// maxTokens returns the max tokens of requests that write an answer, at
+// most the model's capabilities.maxOutputTokens
+func (a *Agent) maxTokens() int {
+	n, ok := answerTokens[a.brevity]
+	if !ok {
+		n = answerTokens[config.BrevityNormal]
+	}
+	if limit := a.ModelConfig.MaxOutputTokens(); limit > 0 {
+		n = min(n, limit)
+	}
+	return n
 }
 
 // GetSystemPrompt returns the system prompt for this agent
 func (a *Agent) GetSystemPrompt() string {
-	return SystemPrompt(a.Collection)
+	prompt := a.systemPrompt()
+	if _, ok := a.Tools.Get("semantic_search"); ok {
+		prompt += SemanticSearchHint()
+	}
+	if _, ok := a.Tools.Get("gitdiff"); ok {
+		prompt += GitDiffHint(a.getResourceNames(), a.diffFrom, a.diffTo)
+	}
+	if _, ok := a.Tools.Get("note"); ok {
+		prompt += NotesHint()
+	}
+	prompt += CustomToolsHint(a.Config.Tools.Custom)
+	prompt += ScopeHint(a.scope)
+	prompt += BoostHint(a.boost)
+	prompt += LanguageHint(a.language)
+	prompt += BrevityHint(a.brevity)
+	prompt += a.overviews
+	return prompt
 }
 
 // GetTools returns the tools as provider tools
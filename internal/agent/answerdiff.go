@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/nickcecere/btcx/internal/storage"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// answerDiffContext is the number of unchanged lines shown around changes
+const answerDiffContext = 2
+
+// diffLine is one line of a line diff
+type diffLine struct {
+	op   diffmatchpatch.Operation
+	text string
+}
+
+// AnswerDiff returns a unified-style line diff of two answers, with only the
+// changed lines and a little context, and the number of added and deleted lines
+// The diff is "" when the answers are the same
+func AnswerDiff(previous, current string) (string, int, int) {
+	var lines []diffLine
+	added, deleted := 0, 0
+	for _, d := range diff.Do(ensureNewline(previous), ensureNewline(current)) {
+		for _, text := range strings.SplitAfter(d.Text, "\n") {
+			if text == "" {
+				continue
+			}
+			lines = append(lines, diffLine{op: d.Type, text: strings.TrimSuffix(text, "\n")})
+			switch d.Type {
+			case diffmatchpatch.DiffInsert:
+				added++
+			case diffmatchpatch.DiffDelete:
+				deleted++
+			}
+		}
+	}
+	if added == 0 && deleted == 0 {
+		return "", 0, 0
+	}
+
+	// Keep changed lines and the unchanged lines near them
+	keep := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.op == diffmatchpatch.DiffEqual {
+			continue
+		}
+		for j := i - answerDiffContext; j <= i+answerDiffContext; j++ {
+			if j >= 0 && j < len(lines) {
+				keep[j] = true
+			}
+		}
+	}
+
+	var sb strings.Builder
+	gap := false
+	for i, l := range lines {
+		if !keep[i] {
+			gap = true
+			continue
+		}
+		if gap && sb.Len() > 0 {
+			sb.WriteString("@@ ... @@\n")
+		}
+		gap = false
+
+		prefix := " "
+		switch l.op {
+		case diffmatchpatch.DiffInsert:
+			prefix = "+"
+		case diffmatchpatch.DiffDelete:
+			prefix = "-"
+		}
+		sb.WriteString(prefix + l.text + "\n")
+	}
+	return sb.String(), added, deleted
+}
+
+// PreviousAnswerNote returns a markdown section showing how an answer
+// differs from the previous answer to the same question
+func PreviousAnswerNote(thread *storage.Thread, previous *storage.Message, current string) string {
+	when := fmt.Sprintf("%s (thread %s)", previous.Timestamp.Format("2006-01-02 15:04"), thread.ID)
+
+	d, added, deleted := AnswerDiff(previous.Content, current)
+	if d == "" {
+		return fmt.Sprintf("\n\n_[Same answer as on %s.]_", when)
+	}
+
+	// Use a fence longer than any backtick run in the answers
+	fence := "```"
+	for strings.Contains(d, fence) {
+		fence += "`"
+	}
+
+	return fmt.Sprintf("\n\n## Changes Since Previous Answer\n\nCompared with the answer from %s: +%d -%d lines.\n\n%sdiff\n%s%s\n",
+		when, added, deleted, fence, d, fence)
+}
+
+// ensureNewline terminates text with a newline so last lines compare equal
+func ensureNewline(text string) string {
+	if text == "" || strings.HasSuffix(text, "\n") {
+		return text
+	}
+	return text + "\n"
+}
@@ -0,0 +1,34 @@
+package agent
+
+import (
+	"regexp"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/search"
+)
+
+// usageQuestion matches questions asking for examples or how to use something
+var usageQuestion = regexp.MustCompile(`(?i)\b(examples?|usage|how (do|can|should|would) (i|you|we) use|sample code|snippets?)\b`)
+
+// boostFor returns the kind of files searches list first for a question:
+// the configured one, or with auto, examples and tests for usage questions
+// Searches restricted to a scope aren't boosted
+func (a *Agent) boostFor(question string) search.Scope {
+	if a.scope != search.ScopeAll {
+		return search.ScopeAll
+	}
+	switch a.boostSetting {
+	case "", config.BoostAuto:
+		if usageQuestion.MatchString(question) {
+			return search.ScopeExamples
+		}
+		return search.ScopeAll
+	case config.BoostOff:
+		return search.ScopeAll
+	}
+	boost, err := search.ParseScope(a.boostSetting)
+	if err != nil {
+		return search.ScopeAll
+	}
+	return boost
+}
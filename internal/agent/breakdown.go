@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"encoding/json"
+
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/storage"
+)
+
+// charsPerToken approximates the characters in a prompt token, for requests
+// whose provider reports no usage
+const charsPerToken = 4
+
+// recordTurn adds one model request and its response to a usage breakdown
+// The request's input tokens are split between its parts by their share of
+// its characters, so tool outputs are charged again on every request that
+// sends them
+func recordTurn(b *storage.UsageBreakdown, req *provider.ChatRequest, resp *provider.ChatResponse) {
+	total := len(req.System) + len(req.Reminder)
+	if tools, err := json.Marshal(req.Tools); err == nil {
+		total += len(tools)
+	}
+
+	// Characters of tool outputs by tool name
+	names := make(map[string]string)
+	byTool := make(map[string]int)
+	for _, m := range req.Messages {
+		total += len(m.Content)
+		for _, tc := range m.ToolCalls {
+			names[tc.ID] = tc.Name
+			total += len(tc.Arguments)
+		}
+		if m.Role == "tool" {
+			byTool[names[m.ToolCallID]] += len(m.Content)
+		}
+	}
+
+	turn := storage.IterationUsage{
+		InputTokens:  resp.Usage.InputTokens,
+		OutputTokens: resp.Usage.OutputTokens,
+	}
+	if b.ToolTokens == nil {
+		b.ToolTokens = make(map[string]int)
+	}
+	for name, chars := range byTool {
+		tokens := chars / charsPerToken
+		if resp.Usage.InputTokens > 0 && total > 0 {
+			tokens = int(int64(resp.Usage.InputTokens) * int64(chars) / int64(total))
+		}
+		if name == "" {
+			name = "unknown"
+		}
+		b.ToolTokens[name] += tokens
+		turn.ToolTokens += tokens
+	}
+	for _, tc := range resp.ToolCalls {
+		turn.ToolCalls = append(turn.ToolCalls, tc.Name)
+	}
+
+	b.InputTokens += turn.InputTokens
+	b.OutputTokens += turn.OutputTokens
+	b.Iterations = append(b.Iterations, turn)
+}
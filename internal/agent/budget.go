@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/storage"
+)
+
+// ErrBudgetExceeded is returned for model requests once the monthly budget
+// is spent, unless run with --force
+var ErrBudgetExceeded = errors.New("monthly budget exceeded")
+
+// budgetWarnings records the warnings printed by this process, so each is
+// shown once (e.g. "2026-10 80%", or a model without pricing)
+var budgetWarnings sync.Map
+
+// budgetMeter tracks a model's spend against budget.monthlyUSD
+type budgetMeter struct {
+	budget  config.BudgetConfig
+	model   *config.ModelConfig
+	storage *storage.Storage
+}
+
+// newBudgetMeter returns the meter for a model's requests (nil when neither
+// a budget nor the model's pricing is set)
+func newBudgetMeter(cfg *config.Config, modelCfg *config.ModelConfig) (*budgetMeter, error) {
+	if cfg.Budget.MonthlyUSD <= 0 && modelCfg.Pricing == nil {
+		return nil, nil
+	}
+	paths, err := config.ResolvePaths()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve paths: %w", err)
+	}
+	if cfg.Budget.MonthlyUSD > 0 && modelCfg.Pricing == nil {
+		warnOnce("pricing "+modelCfg.Name, fmt.Sprintf(
+			"model %q has no pricing, so its requests don't count toward budget.monthlyUSD", modelCfg.Name))
+	}
+	return &budgetMeter{
+		budget:  cfg.Budget,
+		model:   modelCfg,
+		storage: storage.NewStorage(paths.DataDir),
+	}, nil
+}
+
+// Allow refuses requests once this month's spend reaches the budget
+func (m *budgetMeter) Allow() error {
+	if m.budget.MonthlyUSD <= 0 {
+		return nil
+	}
+	spend, err := m.storage.LoadSpend(time.Now())
+	if err != nil {
+		// An unreadable ledger shouldn't block questions
+		warnOnce("load", fmt.Sprintf("failed to check budget: %v", err))
+		return nil
+	}
+	if spend.USD >= m.budget.MonthlyUSD && !m.budget.Force {
+		return fmt.Errorf("%w: $%.2f of $%.2f spent in %s (raise budget.monthlyUSD, or run with --force)",
+			ErrBudgetExceeded, spend.USD, m.budget.MonthlyUSD, spend.Month)
+	}
+	m.check(spend)
+	return nil
+}
+
+// Record adds a response's cost to this month's spend
+func (m *budgetMeter) Record(usage provider.Usage) {
+	cost := m.model.Pricing.Cost(usage.InputTokens, usage.OutputTokens, usage.CacheReadTokens, usage.CacheWriteTokens)
+	spend, err := m.storage.AddSpend(time.Now(), m.model.Name, usage.InputTokens, usage.OutputTokens, cost)
+	if err != nil {
+		warnOnce("add", fmt.Sprintf("failed to record spend: %v", err))
+		return
+	}
+	m.check(spend)
+}
+
+// check warns once per month when the spend passes 80% and 100% of the budget
+func (m *budgetMeter) check(spend *storage.Spend) {
+	limit := m.budget.MonthlyUSD
+	switch {
+	case limit <= 0:
+	case spend.USD >= limit:
+		warnOnce(spend.Month+" 100%", fmt.Sprintf("the monthly budget of $%.2f is spent ($%.2f in %s)",
+			limit, spend.USD, spend.Month))
+	case spend.USD >= limit*config.BudgetWarnShare:
+		warnOnce(spend.Month+" 80%", fmt.Sprintf("$%.2f of the monthly budget of $%.2f is spent (%.0f%%)",
+			spend.USD, limit, spend.USD/limit*100))
+	}
+}
+
+// warnOnce prints a budget warning the first time key is seen
+func warnOnce(key, message string) {
+	if _, seen := budgetWarnings.LoadOrStore(key, true); !seen {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", message)
+	}
+}
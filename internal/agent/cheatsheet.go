@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/storage"
+)
+
+const cheatsheetInstructions = `Write a condensed API cheatsheet, not an essay. Read the source of the main
+functions, types and options before writing - several searches are fine here.
+
+Format it as Markdown:
+- Group entries under ## headings by area
+- For each entry: the exact signature in a code block, one line on what it does,
+  and a minimal example (2-6 lines) when usage isn't obvious
+- End with a ## Gotchas section listing pitfalls, defaults and edge cases visible in the code
+- Only include APIs you found in the source; mark anything unverified
+
+Keep prose to single sentences and prefer tables or bullets over paragraphs.`
+
+// CheatsheetQuestion builds the request for a cheatsheet on topic
+// An empty topic asks for the resources' core public API
+func CheatsheetQuestion(resources []string, topic string) string {
+	subject := "the core public API"
+	if topic != "" {
+		subject = fmt.Sprintf("%q", topic)
+	}
+	return fmt.Sprintf("Create a cheatsheet for %s in %s.\n\n%s", subject, strings.Join(resources, ", "), cheatsheetInstructions)
+}
+
+// Cheatsheet runs the agent to produce a condensed API cheatsheet
+// It uses the normal search loop, so the cheatsheet is grounded in the code
+// and saved as a thread like any other answer
+func (a *Agent) Cheatsheet(ctx context.Context, topic string, callback StreamCallback) (*Response, error) {
+	title := "Cheatsheet"
+	if topic != "" {
+		title += ": " + topic
+	}
+
+	threadID := generateID()
+	a.Thread = &storage.Thread{
+		ID:        threadID,
+		Title:     truncateTitle(title),
+		Created:   time.Now(),
+		Updated:   time.Now(),
+		Resources: a.getResourceNames(),
+		Provider:  string(a.ModelConfig.Provider),
+		Model:     a.ModelConfig.Model,
+		Snapshots: a.snapshotResources(ctx),
+		Messages:  []storage.Message{},
+	}
+	a.Tools.SetThreadID(threadID)
+
+	return a.AskWithCallback(ctx, CheatsheetQuestion(a.getResourceNames(), topic), callback)
+}
+
+// CheatsheetMarkdown formats a cheatsheet as a standalone markdown document
+// with a title and the files it was based on
+func CheatsheetMarkdown(content string, resources []string, topic string, citations []Citation) string {
+	title := strings.Join(resources, ", ")
+	if topic != "" {
+		title += ": " + topic
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s cheatsheet\n\n", title))
+	sb.WriteString(fmt.Sprintf("_Generated by btcx on %s._\n\n", time.Now().Format("2006-01-02")))
+	sb.WriteString(strings.TrimSpace(content))
+	sb.WriteString("\n")
+
+	if len(citations) > 0 {
+		sb.WriteString("\n## Sources\n\n")
+		for _, c := range citations {
+			sb.WriteString("- " + c.Markdown() + "\n")
+		}
+	}
+	if notes := LicenseNotes(citations); len(notes) > 0 {
+		sb.WriteString("\n## Licenses\n\n")
+		for _, note := range notes {
+			sb.WriteString("- " + note + "\n")
+		}
+	}
+	return sb.String()
+}
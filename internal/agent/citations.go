@@ -0,0 +1,176 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/resource"
+	"github.com/nickcecere/btcx/internal/storage"
+)
+
+// Citation is a file referenced while answering a question
+type Citation struct {
+	// Path is the file path relative to the collection directory
+	Path string `json:"path"`
+
+	// StartLine is the first line read (1-based, 0 if the whole file was read)
+	StartLine int `json:"start_line,omitempty"`
+
+	// EndLine is the last line requested (0 if unbounded)
+	EndLine int `json:"end_line,omitempty"`
+
+	// Resource is the resource the file belongs to
+	Resource string `json:"resource,omitempty"`
+
+	// License is the resource's license, if one was found
+	License *resource.License `json:"license,omitempty"`
+
+	// Evidence is the reference ID of the saved full output of the read, when
+	// it was truncated (see btcx threads show --evidence)
+	Evidence string `json:"evidence,omitempty"`
+
+	// SourcePath is the file's path in its resource's repository or
+	// directory, with the resource's searchPath and links.rewrite applied
+	SourcePath string `json:"source_path,omitempty"`
+
+	// LocalPath is the absolute path of the file read, with the collection's
+	// symlinks resolved
+	LocalPath string `json:"local_path,omitempty"`
+
+	// URL is the file's upstream web URL (resources with links.url)
+	URL string `json:"url,omitempty"`
+}
+
+// Label returns the path to show for the citation: the resource name and
+// the file's path in the resource, or the collection path when unknown
+func (c Citation) Label() string {
+	if c.Resource != "" && c.SourcePath != "" {
+		return c.Resource + "/" + c.SourcePath
+	}
+	return c.Path
+}
+
+// Markdown renders the citation as a list item's text: the label, linked
+// to the file's URL when there is one, and the lines read
+func (c Citation) Markdown() string {
+	text := fmt.Sprintf("`%s`", c.Label())
+	if c.URL != "" {
+		text = fmt.Sprintf("[%s](%s)", text, c.URL)
+	}
+	if c.StartLine > 0 && c.EndLine > 0 {
+		text += fmt.Sprintf(" (lines %d-%d)", c.StartLine, c.EndLine)
+	}
+	return text
+}
+
+// Citations extracts the files the agent read from its tool calls
+// Reads that failed, e.g. of paths outside the collection, are skipped
+func Citations(toolCalls []storage.ToolCall) []Citation {
+	var citations []Citation
+	seen := make(map[string]int)
+
+	for _, tc := range toolCalls {
+		if tc.Name != "read" || tc.Failed {
+			continue
+		}
+
+		var args struct {
+			FilePath string `json:"filePath"`
+			Offset   int    `json:"offset"`
+			Limit    int    `json:"limit"`
+		}
+		if err := json.Unmarshal(tc.Arguments, &args); err != nil || args.FilePath == "" {
+			continue
+		}
+
+		path := filepath.ToSlash(filepath.Clean(args.FilePath))
+		if i, ok := seen[path]; ok {
+			if citations[i].Evidence == "" {
+				citations[i].Evidence = tc.Evidence
+			}
+			continue
+		}
+		seen[path] = len(citations)
+
+		citation := Citation{Path: path, Evidence: tc.Evidence}
+		if args.Offset > 0 || args.Limit > 0 {
+			citation.StartLine = args.Offset + 1
+			if args.Limit > 0 {
+				citation.EndLine = args.Offset + args.Limit
+			}
+		}
+		citations = append(citations, citation)
+	}
+
+	return citations
+}
+
+// AttributedCitations returns the citations of a response with the resource
+// and license of each file, so quoted code can be attributed, and where the
+// file is in its resource and upstream
+func (a *Agent) AttributedCitations(toolCalls []storage.ToolCall) []Citation {
+	citations := Citations(toolCalls)
+	if a.Collection == nil {
+		return citations
+	}
+
+	licenses := make(map[string]*resource.License)
+	for i, c := range citations {
+		r, rel := a.citedResource(c.Path)
+		if r == nil {
+			continue
+		}
+		if _, ok := licenses[r.Name]; !ok {
+			licenses[r.Name] = r.License()
+		}
+		citations[i].Resource = r.Name
+		citations[i].License = licenses[r.Name]
+		citations[i].SourcePath = r.SourcePath(rel)
+		citations[i].LocalPath = filepath.Join(r.Path, filepath.FromSlash(rel))
+		citations[i].URL = r.FileURL(citations[i].SourcePath, c.StartLine, c.EndLine)
+	}
+	return citations
+}
+
+// citedResource returns the resource a cited path belongs to and the
+// slash-separated path of the file within the resource
+func (a *Agent) citedResource(path string) (*resource.CollectionResource, string) {
+	path = filepath.FromSlash(path)
+	if filepath.IsAbs(path) {
+		for i, r := range a.Collection.Resources {
+			if rel, err := filepath.Rel(r.Path, path); err == nil && !strings.HasPrefix(rel, "..") {
+				return &a.Collection.Resources[i], filepath.ToSlash(rel)
+			}
+		}
+		rel, err := filepath.Rel(a.Collection.Path, path)
+		if err != nil {
+			return nil, ""
+		}
+		path = rel
+	}
+
+	name, rel, _ := strings.Cut(filepath.ToSlash(filepath.Clean(path)), "/")
+	for i, r := range a.Collection.Resources {
+		if r.Name == name {
+			return &a.Collection.Resources[i], rel
+		}
+	}
+	return nil, ""
+}
+
+// LicenseNotes lists the license of each cited resource once, e.g.
+// "cobra: Apache-2.0, Copyright 2013-2023 The Cobra Authors"
+func LicenseNotes(citations []Citation) []string {
+	var notes []string
+	seen := make(map[string]bool)
+	for _, c := range citations {
+		if c.License == nil || seen[c.Resource] {
+			continue
+		}
+		seen[c.Resource] = true
+		notes = append(notes, fmt.Sprintf("%s: %s", c.Resource, c.License.Attribution()))
+	}
+	return notes
+}
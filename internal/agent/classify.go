@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/provider"
+)
+
+// QuestionCategories are the labels questions are classified with
+// "other" is used for replies that match none of them
+var QuestionCategories = []string{"how-to", "api", "debugging", "comparison", "other"}
+
+const classifyPrompt = `You label developer questions about a codebase. Reply with ONLY one of these labels:
+
+- how-to: how to do or set up something
+- api: what a function, type, option or config key is, does or accepts
+- debugging: why something fails, errors, unexpected behavior
+- comparison: differences between libraries, versions or approaches
+- other: anything else`
+
+// classifyQuestion labels the question with one of QuestionCategories
+// using the classification model (classify.model, default: the answering model)
+func (a *Agent) classifyQuestion(ctx context.Context, question string) (string, provider.Usage, error) {
+	p, modelCfg := a.Provider, a.ModelConfig
+	if name := a.Config.Classify.Model; name != "" && name != a.ModelConfig.Name {
+		cfg, err := a.Config.GetModelConfig(name)
+		if err != nil {
+			return "", provider.Usage{}, err
+		}
+		p, err = a.newProvider(cfg)
+		if err != nil {
+			return "", provider.Usage{}, err
+		}
+		modelCfg = cfg
+	}
+
+	resp, err := p.Chat(ctx, &provider.ChatRequest{
+		Model:  modelCfg.Model,
+		System: classifyPrompt,
+		Messages: []provider.Message{
+			{Role: "user", Content: question},
+		},
+		MaxTokens: 16,
+	})
+	if err != nil {
+		return "", provider.Usage{}, fmt.Errorf("question classification failed: %w", err)
+	}
+
+	return parseCategory(resp.Content), resp.Usage, nil
+}
+
+// parseCategory finds the label in the model reply ("other" if none fits)
+func parseCategory(content string) string {
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return strings.ContainsRune(" \n\t,.:;`\"'*", r)
+	})
+	for _, word := range words {
+		for _, category := range QuestionCategories {
+			if word == category {
+				return category
+			}
+		}
+	}
+	return "other"
+}
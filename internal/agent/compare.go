@@ -0,0 +1,235 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/resource"
+	"github.com/nickcecere/btcx/internal/storage"
+)
+
+const researchInstructions = `This is the research step of a comparison. Investigate ONLY %s and report
+what you find about the question below: the relevant APIs, how they behave and any limits, each backed
+by a file path and line or a short quote. Don't compare with other libraries and don't speculate about
+them; another step will do the comparison.
+
+Question: %s`
+
+const comparePrompt = `You compare libraries and frameworks for developers choosing between them or moving between them.
+You are given research notes for each one, gathered from its source code, with file citations.
+
+Write a Markdown answer with:
+1. A two or three sentence summary answering the question
+2. A comparison table with one row per aspect and one column per library
+3. ## Key Differences - bullets explaining the differences that matter most
+4. ## When to Use Which - short guidance
+
+Back each claim with the citations from the notes (resource/path or resource/path:line).
+Only use what the notes show. Where the notes found nothing for one side, write "not found" rather than guessing.`
+
+// CompareResearch is what the research step found for one resource
+type CompareResearch struct {
+	// Resource is the resource that was researched
+	Resource string
+
+	// Findings are the research notes
+	Findings string
+
+	// Citations are the files read while researching
+	Citations []Citation
+}
+
+// Compare answers a comparison question across resources
+// Each scoped collection (one per resource) is researched separately with
+// the normal search loop, so findings can't bleed between resources, and a
+// final pass without tools writes a structured comparison from the notes.
+// progress, if set, is called as each step starts
+func (a *Agent) Compare(ctx context.Context, question string, scoped []*resource.Collection, progress func(step string), callback StreamCallback) (*Response, error) {
+	if len(scoped) < 2 {
+		return nil, fmt.Errorf("compare needs at least two resources")
+	}
+
+	// Research events only drive progress; their text isn't the answer
+	researchCallback := toolEvents(callback)
+
+	var research []CompareResearch
+	var toolCalls []storage.ToolCall
+	var usage provider.Usage
+	breakdown := &storage.UsageBreakdown{}
+	for _, collection := range scoped {
+		name := strings.Join(collectionNames(collection), ", ")
+		if progress != nil {
+			progress("Researching " + name)
+		}
+
+		r, err := a.research(ctx, collection, name, question, researchCallback)
+		if err != nil {
+			return nil, fmt.Errorf("research on %s failed: %w", name, err)
+		}
+		research = append(research, CompareResearch{
+			Resource:  name,
+			Findings:  r.Content,
+			Citations: Citations(r.ToolCalls),
+		})
+		toolCalls = append(toolCalls, r.ToolCalls...)
+		addUsage(&usage, r.Usage)
+		breakdown.Add(r.Breakdown)
+	}
+
+	if progress != nil {
+		progress("Comparing")
+	}
+
+	req := &provider.ChatRequest{
+		Model:  a.ModelConfig.Model,
+		System: comparePrompt,
+		Messages: []provider.Message{
+			{Role: "user", Content: compareNotes(question, research)},
+		},
+		MaxTokens: a.maxTokens(),
+	}
+
+	var resp *provider.ChatResponse
+	var err error
+	if callback != nil && a.ModelConfig.SupportsStreaming() {
+		resp, err = a.streamChat(ctx, req, a.answerCallback(callback))
+	} else {
+		resp, err = a.Provider.Chat(ctx, req)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("chat request failed: %w", err)
+	}
+	addUsage(&usage, resp.Usage)
+	recordTurn(breakdown, req, resp)
+
+	content := a.emitAnswer(resp.Content, callback)
+	if content == "" {
+		content = "I was unable to write a comparison from the research."
+	}
+
+	// Keep the question and comparison as a thread so it can be continued
+	a.Thread = &storage.Thread{
+		ID:        generateID(),
+		Title:     truncateTitle(question),
+		Created:   time.Now(),
+		Updated:   time.Now(),
+		Resources: a.getResourceNames(),
+		Provider:  string(a.ModelConfig.Provider),
+		Model:     a.ModelConfig.Model,
+		Snapshots: a.snapshotResources(ctx),
+		Messages: []storage.Message{
+			{Role: "user", Content: question, Timestamp: time.Now()},
+			{Role: "assistant", Content: content, Breakdown: breakdown, Timestamp: time.Now()},
+		},
+	}
+	if err := a.Storage.SaveThread(a.Thread); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save thread: %v\n", err)
+	}
+
+	return &Response{
+		Content:   content,
+		ToolCalls: toolCalls,
+		Usage:     usage,
+		Breakdown: breakdown,
+	}, nil
+}
+
+// research runs the search loop against one scoped collection
+// Research threads are working notes; only the final comparison is saved
+func (a *Agent) research(ctx context.Context, collection *resource.Collection, name, question string, callback StreamCallback) (*Response, error) {
+	scoped, err := a.spawn(a.ModelConfig, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	threadID := generateID()
+	scoped.Thread = &storage.Thread{
+		ID:        threadID,
+		Resources: scoped.getResourceNames(),
+		Messages: []storage.Message{{
+			Role:      "user",
+			Content:   fmt.Sprintf(researchInstructions, name, question),
+			Timestamp: time.Now(),
+		}},
+	}
+	scoped.Tools.SetThreadID(threadID)
+
+	return scoped.runLoop(ctx, callback)
+}
+
+// spawn creates an agent for a sub-task with another model or collection
+// It shares this agent's storage and search settings; its threads are
+// working notes that aren't saved
+func (a *Agent) spawn(modelCfg *config.ModelConfig, collection *resource.Collection) (*Agent, error) {
+	sub, err := New(Options{
+		Config:        a.Config,
+		ModelConfig:   modelCfg,
+		Collection:    collection,
+		NoAnswerCache: true,
+		DiffFrom:      a.diffFrom,
+		DiffTo:        a.diffTo,
+		Scope:         a.scope,
+		Language:      a.language,
+		Brevity:       a.brevity,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if modelCfg == a.ModelConfig {
+		sub.Provider = a.Provider
+	}
+	sub.Storage = a.Storage
+	return sub, nil
+}
+
+// toolEvents wraps a callback so only tool call and result events reach it
+func toolEvents(callback StreamCallback) StreamCallback {
+	if callback == nil {
+		return nil
+	}
+	return func(event provider.StreamEvent) {
+		if event.Type == provider.StreamEventToolCall || event.Type == provider.StreamEventToolResult {
+			callback(event)
+		}
+	}
+}
+
+// compareNotes formats the research for the comparison pass
+func compareNotes(question string, research []CompareResearch) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Question: %s\n", question))
+
+	for _, r := range research {
+		sb.WriteString(fmt.Sprintf("\n# Research notes: %s\n\n%s\n", r.Resource, strings.TrimSpace(r.Findings)))
+		if len(r.Citations) > 0 {
+			sb.WriteString("\nFiles read:\n")
+			for _, c := range r.Citations {
+				sb.WriteString("- " + c.Path + "\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// collectionNames returns the resource names in a collection
+func collectionNames(c *resource.Collection) []string {
+	names := make([]string, 0, len(c.Resources))
+	for _, r := range c.Resources {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+// addUsage adds token usage to a running total
+func addUsage(total *provider.Usage, u provider.Usage) {
+	total.InputTokens += u.InputTokens
+	total.OutputTokens += u.OutputTokens
+	total.TotalTokens += u.TotalTokens
+	total.CacheReadTokens += u.CacheReadTokens
+	total.CacheWriteTokens += u.CacheWriteTokens
+}
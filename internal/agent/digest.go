@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/storage"
+)
+
+const (
+	// maxDigestChars caps the transcript sent to the summary model
+	maxDigestChars = 40000
+	// maxDigestAnswerChars caps a single answer in the transcript
+	maxDigestAnswerChars = 4000
+	// maxDigestSummaryChars caps the one-line summary shown in thread lists
+	maxDigestSummaryChars = 100
+)
+
+const digestPrompt = `You summarize conversations between a developer and an assistant that answers questions
+about source code. You are given the questions and answers, and the files the assistant read.
+
+Reply with ONLY a JSON object, no explanations:
+{"summary": "...", "findings": ["..."], "open_questions": ["..."]}
+
+- summary: one line of at most 80 characters saying what the conversation was about
+- findings: the 1-5 most useful things learned, each one short sentence naming the APIs, files or settings involved
+- open_questions: questions the answers left open or could not confirm; [] if there are none`
+
+// SummarizeThread writes a digest of a thread with the given model
+// The cited files come from the thread's read calls, not from the model
+func SummarizeThread(ctx context.Context, p provider.Provider, modelCfg *config.ModelConfig, thread *storage.Thread) (*storage.Digest, provider.Usage, error) {
+	transcript := formatTranscript(thread)
+	if transcript == "" {
+		return nil, provider.Usage{}, fmt.Errorf("thread %s has no questions to summarize", thread.ID)
+	}
+
+	var toolCalls []storage.ToolCall
+	for _, msg := range thread.Messages {
+		toolCalls = append(toolCalls, msg.ToolCalls...)
+	}
+	var files []string
+	for _, c := range Citations(toolCalls) {
+		files = append(files, c.Path)
+	}
+
+	content := transcript
+	if len(files) > 0 {
+		content += "\n# Files read\n\n" + strings.Join(files, "\n") + "\n"
+	}
+
+	resp, err := p.Chat(ctx, &provider.ChatRequest{
+		Model:  modelCfg.Model,
+		System: digestPrompt,
+		Messages: []provider.Message{
+			{Role: "user", Content: content},
+		},
+		MaxTokens: 1024,
+	})
+	if err != nil {
+		return nil, provider.Usage{}, fmt.Errorf("summary failed: %w", err)
+	}
+
+	digest, err := parseDigest(resp.Content)
+	if err != nil {
+		return nil, resp.Usage, err
+	}
+	digest.Files = files
+	digest.Model = modelCfg.Name
+	digest.Messages = len(thread.Messages)
+	digest.Created = time.Now()
+	return digest, resp.Usage, nil
+}
+
+// formatTranscript lists a thread's questions and final answers
+// Tool calls and results are left out; the answers already sum them up
+func formatTranscript(thread *storage.Thread) string {
+	var sb strings.Builder
+	for _, msg := range thread.Messages {
+		var entry string
+		switch {
+		case msg.Role == "user":
+			entry = fmt.Sprintf("## Question\n\n%s\n\n", msg.Content)
+		case msg.Role == "assistant" && len(msg.ToolCalls) == 0 && msg.Content != "":
+			answer := msg.Content
+			if len(answer) > maxDigestAnswerChars {
+				answer = strings.ToValidUTF8(answer[:maxDigestAnswerChars], "") + "\n... (truncated)"
+			}
+			entry = fmt.Sprintf("## Answer\n\n%s\n\n", answer)
+		default:
+			continue
+		}
+		if sb.Len()+len(entry) > maxDigestChars {
+			sb.WriteString("... (rest of the conversation omitted)\n")
+			break
+		}
+		sb.WriteString(entry)
+	}
+	return sb.String()
+}
+
+// parseDigest extracts the digest from the model reply
+func parseDigest(content string) (*storage.Digest, error) {
+	var reply struct {
+		Summary       string   `json:"summary"`
+		Findings      []string `json:"findings"`
+		OpenQuestions []string `json:"open_questions"`
+	}
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("summary reply has no JSON object")
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &reply); err != nil {
+		return nil, fmt.Errorf("failed to parse summary reply: %w", err)
+	}
+
+	summary := strings.Join(strings.Fields(reply.Summary), " ")
+	if summary == "" {
+		return nil, fmt.Errorf("summary reply has no summary")
+	}
+	if len(summary) > maxDigestSummaryChars {
+		summary = strings.ToValidUTF8(summary[:maxDigestSummaryChars-3], "") + "..."
+	}
+
+	return &storage.Digest{
+		Summary:       summary,
+		Findings:      nonEmpty(reply.Findings),
+		OpenQuestions: nonEmpty(reply.OpenQuestions),
+	}, nil
+}
+
+// nonEmpty trims items and drops empty ones
+func nonEmpty(items []string) []string {
+	var result []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
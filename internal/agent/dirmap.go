@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/resource"
+)
+
+const (
+	// maxMapDirs caps the top-level entries in a directory map
+	maxMapDirs = 40
+
+	// maxMapChildren caps the subdirectories listed per directory
+	maxMapChildren = 24
+
+	// maxMapFiles caps the top-level files listed
+	maxMapFiles = 20
+)
+
+// mapKeyFiles are files worth pointing the model at, besides the README,
+// package manifests and architecture docs, when the resource has them
+var mapKeyFiles = []string{
+	"CONTRIBUTING.md", "CHANGELOG.md", "docs/README.md", "docs/index.md",
+	"main.go", "index.ts", "index.js", "mod.ts", "src/index.ts", "src/index.js",
+	"src/main.rs", "src/lib.rs", "lib/index.js", "__init__.py",
+}
+
+// DirectoryMap outlines a resource for the system prompt: its top two
+// directory levels (subdirectories only, with file counts) and key files,
+// so the model can go straight to the right place instead of listing
+func DirectoryMap(root string) (string, error) {
+	entries, err := visibleEntries(root)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	var files []string
+	dirs := 0
+	for _, e := range entries {
+		if !isDir(root, e) {
+			files = append(files, e.Name())
+			continue
+		}
+		if dirs++; dirs > maxMapDirs {
+			continue
+		}
+
+		children, err := visibleEntries(filepath.Join(root, e.Name()))
+		if err != nil {
+			continue
+		}
+		var subdirs []string
+		fileCount := 0
+		for _, c := range children {
+			if isDir(filepath.Join(root, e.Name()), c) {
+				subdirs = append(subdirs, c.Name()+"/")
+			} else {
+				fileCount++
+			}
+		}
+		if len(subdirs) > maxMapChildren {
+			subdirs = append(subdirs[:maxMapChildren], fmt.Sprintf("... (%d more)", len(subdirs)-maxMapChildren))
+		}
+
+		sb.WriteString(e.Name() + "/")
+		if len(subdirs) > 0 {
+			sb.WriteString(" " + strings.Join(subdirs, " "))
+		}
+		if fileCount > 0 {
+			sb.WriteString(fmt.Sprintf(" (%d files)", fileCount))
+		}
+		sb.WriteString("\n")
+	}
+	if dirs > maxMapDirs {
+		sb.WriteString(fmt.Sprintf("... (%d more directories)\n", dirs-maxMapDirs))
+	}
+	if len(files) > maxMapFiles {
+		files = append(files[:maxMapFiles], fmt.Sprintf("... (%d more)", len(files)-maxMapFiles))
+	}
+	if len(files) > 0 {
+		sb.WriteString("Files: " + strings.Join(files, " ") + "\n")
+	}
+
+	if keys := keyFiles(root); len(keys) > 0 {
+		sb.WriteString("Key files: " + strings.Join(keys, ", ") + "\n")
+	}
+	return sb.String(), nil
+}
+
+// isDir reports whether an entry of dir is a directory or a link to one
+func isDir(dir string, e os.DirEntry) bool {
+	if e.Type()&os.ModeSymlink == 0 {
+		return e.IsDir()
+	}
+	info, err := os.Stat(filepath.Join(dir, e.Name()))
+	return err == nil && info.IsDir()
+}
+
+// keyFiles lists the README, package manifests, architecture docs and entry
+// points a resource has
+func keyFiles(root string) []string {
+	var keys []string
+	if readme := findReadme(root); readme != "" {
+		keys = append(keys, readme)
+	}
+	candidates := append(append(append([]string{}, summaryManifests...), summaryDocs...), mapKeyFiles...)
+	for _, name := range candidates {
+		if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); err == nil && !info.IsDir() {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// cachedMap is a directory map stored in the cache, with the resource
+// version it was made from
+type cachedMap struct {
+	Version string `json:"version"`
+	Path    string `json:"path"`
+	Map     string `json:"map"`
+}
+
+// LoadDirectoryMaps returns the directory map of each resource in the
+// collection, by resource name
+// Maps of versioned resources are cached in dir until the version changes;
+// local directories without a version are mapped every time
+func LoadDirectoryMaps(dir string, collection *resource.Collection) map[string]string {
+	versions := collection.Versions(nil)
+	maps := make(map[string]string, len(collection.Resources))
+	for _, r := range collection.Resources {
+		version := versions[r.Name]
+		path := filepath.Join(dir, r.Name+".json")
+
+		if version != "" {
+			var cached cachedMap
+			if data, err := os.ReadFile(path); err == nil && json.Unmarshal(data, &cached) == nil &&
+				cached.Version == version && cached.Path == r.Path {
+				maps[r.Name] = cached.Map
+				continue
+			}
+		}
+
+		m, err := DirectoryMap(r.Path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to map resource %q: %v\n", r.Name, err)
+			continue
+		}
+		maps[r.Name] = m
+
+		if version != "" {
+			data, err := json.Marshal(cachedMap{Version: version, Path: r.Path, Map: m})
+			if err == nil {
+				if err = os.MkdirAll(dir, 0755); err == nil {
+					err = os.WriteFile(path, data, 0644)
+				}
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to cache map of resource %q: %v\n", r.Name, err)
+			}
+		}
+	}
+	return maps
+}
@@ -0,0 +1,206 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/resource"
+	"github.com/nickcecere/btcx/internal/storage"
+)
+
+const judgePrompt = `You are given a developer's question and candidate answers written by different models that
+searched the same source code, with the files each one read.
+
+Write the single best answer to the question:
+- Keep claims backed by code quotes or file citations, and claims the candidates agree on
+- Where candidates conflict, go with the one whose evidence is stronger and say what is uncertain
+- Drop claims that no candidate backs with code
+- If one candidate is clearly best, you may return it mostly unchanged
+
+Use Markdown, keep the file paths and code blocks from the candidates, and don't mention the candidates
+or the models in your answer.`
+
+// ModelRun is one model's part in an ensemble answer
+type ModelRun struct {
+	// Model is the model config name
+	Model string
+
+	// Judge is set for the model that merged the answers
+	Judge bool
+
+	// Content is the model's answer
+	Content string
+
+	// Usage is the model's token usage
+	Usage provider.Usage
+
+	// Err is set if the model failed; the other answers are still used
+	Err error
+}
+
+// candidate is one model's answer and the files it read
+type candidate struct {
+	content   string
+	citations []Citation
+}
+
+// Ensemble answers a question with each model, then has this agent's model
+// judge the candidates and write the final answer
+// Models run one after another with the normal search loop. If only one
+// succeeds, its answer is used as is. progress, if set, is called as each
+// step starts
+func (a *Agent) Ensemble(ctx context.Context, question string, models []*config.ModelConfig, progress func(step string), callback StreamCallback) (*Response, error) {
+	if len(models) < 2 {
+		return nil, fmt.Errorf("an ensemble needs at least two models")
+	}
+
+	var runs []ModelRun
+	var toolCalls []storage.ToolCall
+	var usage provider.Usage
+	var candidates []candidate
+	var evidence []storage.Message
+	breakdown := &storage.UsageBreakdown{}
+	for _, m := range models {
+		if progress != nil {
+			progress("Asking " + m.Name)
+		}
+
+		r, messages, err := a.askWith(ctx, m, a.Collection, question, toolEvents(callback))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s failed: %v\n", m.Name, err)
+			runs = append(runs, ModelRun{Model: m.Name, Err: err})
+			continue
+		}
+		runs = append(runs, ModelRun{Model: m.Name, Content: a.postProcess(r.Content), Usage: r.Usage})
+		candidates = append(candidates, candidate{content: r.Content, citations: Citations(r.ToolCalls)})
+		toolCalls = append(toolCalls, r.ToolCalls...)
+		evidence = append(evidence, messages...)
+		addUsage(&usage, r.Usage)
+		breakdown.Add(r.Breakdown)
+	}
+
+	var content string
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("every model failed: %w", runs[0].Err)
+	case 1:
+		content = candidates[0].content
+		if answer := a.answerCallback(callback); answer != nil {
+			answer(provider.StreamEvent{Type: provider.StreamEventText, Delta: content})
+		}
+		content = a.emitAnswer(content, callback)
+	default:
+		if progress != nil {
+			progress("Judging with " + a.ModelConfig.Name)
+		}
+		resp, err := a.judge(ctx, question, candidates, a.answerCallback(callback))
+		if err != nil {
+			return nil, err
+		}
+		content = a.emitAnswer(resp.Content, callback)
+		runs = append(runs, ModelRun{Model: a.ModelConfig.Name, Judge: true, Content: content, Usage: resp.Usage})
+		addUsage(&usage, resp.Usage)
+		if content == "" {
+			content = "I was unable to merge the answers."
+		}
+	}
+
+	// Keep the question and final answer as a thread so it can be continued
+	a.Thread = &storage.Thread{
+		ID:        generateID(),
+		Title:     truncateTitle(question),
+		Created:   time.Now(),
+		Updated:   time.Now(),
+		Resources: a.getResourceNames(),
+		Provider:  string(a.ModelConfig.Provider),
+		Model:     a.ModelConfig.Model,
+		Snapshots: a.snapshotResources(ctx),
+		Messages: []storage.Message{
+			{Role: "user", Content: question, Timestamp: time.Now()},
+			{Role: "assistant", Content: content, Breakdown: breakdown, Timestamp: time.Now()},
+		},
+	}
+
+	response := &Response{
+		Content:   content,
+		ToolCalls: toolCalls,
+		Usage:     usage,
+		Runs:      runs,
+		Breakdown: breakdown,
+	}
+	if a.verifyAnswers {
+		a.appendVerification(ctx, question, response, evidence, callback)
+	}
+	if a.validateExamples {
+		a.appendExampleCheck(ctx, response, callback)
+	}
+
+	if err := a.Storage.SaveThread(a.Thread); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save thread: %v\n", err)
+	}
+
+	return response, nil
+}
+
+// askWith runs the search loop for a question with another model or
+// collection
+// It also returns the run's messages, which hold the tool results
+func (a *Agent) askWith(ctx context.Context, modelCfg *config.ModelConfig, collection *resource.Collection, question string, callback StreamCallback) (*Response, []storage.Message, error) {
+	sub, err := a.spawn(modelCfg, collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	threadID := generateID()
+	sub.Thread = &storage.Thread{
+		ID:        threadID,
+		Resources: sub.getResourceNames(),
+		Messages:  []storage.Message{{Role: "user", Content: question, Timestamp: time.Now()}},
+	}
+	sub.Tools.SetThreadID(threadID)
+
+	resp, err := sub.runLoop(ctx, callback)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, sub.Thread.Messages, nil
+}
+
+// judge merges candidate answers into one
+func (a *Agent) judge(ctx context.Context, question string, candidates []candidate, callback StreamCallback) (*provider.ChatResponse, error) {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Question: %s\n", question))
+	for i, c := range candidates {
+		sb.WriteString(fmt.Sprintf("\n# Candidate %d\n\n%s\n", i+1, strings.TrimSpace(c.content)))
+		if len(c.citations) > 0 {
+			sb.WriteString("\nFiles read:\n")
+			for _, cite := range c.citations {
+				sb.WriteString("- " + cite.Path + "\n")
+			}
+		}
+	}
+
+	req := &provider.ChatRequest{
+		Model:     a.ModelConfig.Model,
+		System:    judgePrompt,
+		Messages:  []provider.Message{{Role: "user", Content: sb.String()}},
+		MaxTokens: a.maxTokens(),
+	}
+
+	var resp *provider.ChatResponse
+	var err error
+	if callback != nil && a.ModelConfig.SupportsStreaming() {
+		resp, err = a.streamChat(ctx, req, callback)
+	} else {
+		resp, err = a.Provider.Chat(ctx, req)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("chat request failed: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/examples"
+	"github.com/nickcecere/btcx/internal/provider"
+)
+
+// checkExamples type-checks the code examples in an answer against the
+// collection's resources and returns a note flagging the ones that don't
+// build ("" if there was nothing to check)
+func (a *Agent) checkExamples(ctx context.Context, content string) string {
+	blocks := examples.Extract(content)
+	if len(blocks) == 0 {
+		return ""
+	}
+
+	dirs := make([]string, 0, len(a.Collection.Resources))
+	for _, r := range a.Collection.Resources {
+		dirs = append(dirs, r.Path)
+	}
+
+	results := examples.Check(ctx, blocks, examples.Options{
+		Dirs:    dirs,
+		Timeout: time.Duration(a.Config.Examples.Timeout) * time.Second,
+	})
+	return examples.Summary(results)
+}
+
+// appendExampleCheck adds the example check note to a fresh answer, its
+// thread message and the stream
+func (a *Agent) appendExampleCheck(ctx context.Context, response *Response, callback StreamCallback) {
+	note := a.checkExamples(ctx, response.Content)
+	if note == "" {
+		return
+	}
+
+	response.Content += note
+	if n := len(a.Thread.Messages); n > 0 && a.Thread.Messages[n-1].Role == "assistant" {
+		a.Thread.Messages[n-1].Content += note
+	}
+	if callback != nil {
+		callback(provider.StreamEvent{Type: provider.StreamEventText, Delta: note})
+	}
+}
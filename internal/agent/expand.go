@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/provider"
+)
+
+// maxExpansionTerms caps the number of search terms kept from expansion
+const maxExpansionTerms = 5
+
+const expansionPrompt = `You turn questions about a codebase into search terms.
+Reply with ONLY a JSON array of 3 to 5 short strings: exact identifiers, function or type names,
+config keys, likely file names, or synonyms a developer would grep for. No explanations.
+
+Example question: "how do I make a command accept only two args?"
+Example reply: ["ExactArgs", "Args:", "PositionalArgs", "args.go", "cobra.ExactArgs(2)"]`
+
+// expandQuery asks the model for concrete search terms for the question
+func (a *Agent) expandQuery(ctx context.Context, question string) ([]string, provider.Usage, error) {
+	p, modelCfg := a.Provider, a.ModelConfig
+	if name := a.Config.QueryExpansion.Model; name != "" && name != a.ModelConfig.Name {
+		cfg, err := a.Config.GetModelConfig(name)
+		if err != nil {
+			return nil, provider.Usage{}, err
+		}
+		p, err = a.newProvider(cfg)
+		if err != nil {
+			return nil, provider.Usage{}, err
+		}
+		modelCfg = cfg
+	}
+
+	resp, err := p.Chat(ctx, &provider.ChatRequest{
+		Model:  modelCfg.Model,
+		System: expansionPrompt,
+		Messages: []provider.Message{
+			{Role: "user", Content: fmt.Sprintf("Repositories: %s\n\nQuestion: %s", strings.Join(a.getResourceNames(), ", "), question)},
+		},
+		MaxTokens: 256,
+	})
+	if err != nil {
+		return nil, provider.Usage{}, fmt.Errorf("query expansion failed: %w", err)
+	}
+
+	return parseExpansion(resp.Content), resp.Usage, nil
+}
+
+// parseExpansion extracts search terms from the model reply
+// A JSON array is preferred; otherwise one term per line is accepted
+func parseExpansion(content string) []string {
+	var terms []string
+
+	start, end := strings.Index(content, "["), strings.LastIndex(content, "]")
+	if start >= 0 && end > start {
+		_ = json.Unmarshal([]byte(content[start:end+1]), &terms)
+	}
+	if len(terms) == 0 {
+		for _, line := range strings.Split(content, "\n") {
+			terms = append(terms, strings.TrimLeft(line, "-*0123456789. "))
+		}
+	}
+
+	var result []string
+	seen := make(map[string]bool)
+	for _, term := range terms {
+		term = strings.Trim(strings.TrimSpace(term), "`\"")
+		if term == "" || len(term) > 80 || seen[term] {
+			continue
+		}
+		seen[term] = true
+		result = append(result, term)
+		if len(result) == maxExpansionTerms {
+			break
+		}
+	}
+	return result
+}
+
+// searchTermsHint formats expanded search terms for the system prompt
+func searchTermsHint(terms []string) string {
+	if len(terms) == 0 {
+		return ""
+	}
+
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = "`" + term + "`"
+	}
+
+	return fmt.Sprintf(`
+
+## Suggested Search Terms
+
+These terms were derived from the question and are good first searches: %s
+They are guesses - verify them against the code and drop any that find nothing.
+`, strings.Join(quoted, ", "))
+}
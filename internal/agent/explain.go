@@ -0,0 +1,322 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/search"
+	"github.com/nickcecere/btcx/internal/storage"
+	"github.com/nickcecere/btcx/internal/tool"
+)
+
+const (
+	// maxExplainBytes caps how much of the file is sent to the model
+	maxExplainBytes = 48 * 1024
+
+	// explainContext is the number of lines shown around a symbol definition
+	// when the whole file doesn't fit
+	explainContext = 80
+
+	// maxExplainRefs caps the number of references sent to the model
+	maxExplainRefs = 25
+)
+
+const explainPrompt = `You explain code from open source libraries to developers using them.
+You are given a file (or the part of it around a symbol) and references to it from elsewhere
+in the repository. Explain it using ONLY that source - you cannot search for more.
+
+Structure the explanation as:
+1. **Purpose** - what it is for, in one or two sentences
+2. **How it works** - walk through the important parts, quoting short snippets
+3. **Usage** - how the references use it (skip if there are none)
+4. **Gotchas** - edge cases, side effects or surprising behaviour visible in the code
+
+Cite locations as path:line. If something depends on code that wasn't provided, say so instead of guessing.`
+
+// symbolPattern matches symbols given as path:symbol (e.g. "Button", "Store.subscribe")
+var symbolPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$.]*$`)
+
+// definitionPattern matches lines that typically declare something
+var definitionPattern = regexp.MustCompile(`\b(func|function|def|class|type|interface|struct|enum|trait|impl|fn|const|let|var|export|module|macro)\b`)
+
+// importPattern matches lines that typically import another file, including
+// the bare (optionally aliased) paths of Go import blocks
+var importPattern = regexp.MustCompile(`\b(import|require|from|include|use|mod)\b|^\s*([\w.]+\s+)?"[^"\s]+/[^"\s]+"\s*$`)
+
+// ExplainTarget is the file or symbol to explain
+type ExplainTarget struct {
+	// Resource is the resource containing the file
+	Resource string
+
+	// Path is the file path relative to the resource root
+	Path string
+
+	// Symbol optionally narrows the explanation to one symbol in the file
+	Symbol string
+}
+
+// ParseExplainTarget parses "path/to/file.ts[:symbol]"
+func ParseExplainTarget(resource, arg string) ExplainTarget {
+	target := ExplainTarget{Resource: resource, Path: arg}
+	if i := strings.LastIndex(arg, ":"); i > 0 && symbolPattern.MatchString(arg[i+1:]) {
+		target.Path, target.Symbol = arg[:i], arg[i+1:]
+	}
+	target.Path = filepath.ToSlash(strings.TrimPrefix(target.Path, "./"))
+	return target
+}
+
+// String returns the target as "resource/path[:symbol]"
+func (t ExplainTarget) String() string {
+	s := t.Resource + "/" + t.Path
+	if t.Symbol != "" {
+		s += ":" + t.Symbol
+	}
+	return s
+}
+
+// Explain explains a file or symbol without the search loop
+// The file (or the region around the symbol) and its references are put
+// straight into a single prompt, so the model answers in one turn
+func (a *Agent) Explain(ctx context.Context, target ExplainTarget, callback StreamCallback) (*Response, error) {
+	prompt, err := a.explainContext(target)
+	if err != nil {
+		return nil, err
+	}
+
+	a.Thread = &storage.Thread{
+		ID:        generateID(),
+		Title:     truncateTitle("Explain " + target.String()),
+		Created:   time.Now(),
+		Updated:   time.Now(),
+		Resources: a.getResourceNames(),
+		Provider:  string(a.ModelConfig.Provider),
+		Model:     a.ModelConfig.Model,
+		Snapshots: a.snapshotResources(ctx),
+		Messages: []storage.Message{{
+			Role:      "user",
+			Content:   prompt,
+			Timestamp: time.Now(),
+		}},
+	}
+	a.Tools.SetThreadID(a.Thread.ID)
+
+	req := &provider.ChatRequest{
+		Model:     a.ModelConfig.Model,
+		System:    explainPrompt,
+		Messages:  a.buildMessages(),
+		MaxTokens: a.maxTokens(),
+	}
+
+	var resp *provider.ChatResponse
+	if callback != nil && a.ModelConfig.SupportsStreaming() {
+		resp, err = a.streamChat(ctx, req, a.answerCallback(callback))
+	} else {
+		resp, err = a.Provider.Chat(ctx, req)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("chat request failed: %w", err)
+	}
+
+	content := a.emitAnswer(resp.Content, callback)
+	if content == "" {
+		content = "I was unable to generate an explanation for this code."
+	}
+	a.Thread.Messages = append(a.Thread.Messages, storage.Message{
+		Role:      "assistant",
+		Content:   content,
+		Timestamp: time.Now(),
+	})
+
+	if err := a.Storage.SaveThread(a.Thread); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save thread: %v\n", err)
+	}
+
+	return &Response{
+		Content: content,
+		Usage:   resp.Usage,
+	}, nil
+}
+
+// explainContext builds the user message for an explanation
+func (a *Agent) explainContext(target ExplainTarget) (string, error) {
+	var root string
+	for _, r := range a.Collection.Resources {
+		if r.Name == target.Resource {
+			root = r.Path
+			break
+		}
+	}
+	if root == "" {
+		return "", fmt.Errorf("resource %q is not in the collection", target.Resource)
+	}
+
+	path := filepath.Join(root, filepath.FromSlash(target.Path))
+	if rel, err := filepath.Rel(root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside resource %s", target.Path, target.Resource)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			msg := fmt.Sprintf("file not found: %s/%s", target.Resource, target.Path)
+			if suggestions := tool.SuggestSimilarFiles(path, 3); len(suggestions) > 0 {
+				for i, s := range suggestions {
+					if rel, err := filepath.Rel(root, s); err == nil {
+						suggestions[i] = filepath.ToSlash(rel)
+					}
+				}
+				msg += fmt.Sprintf(" (did you mean %s?)", strings.Join(suggestions, ", "))
+			}
+			return "", fmt.Errorf("%s", msg)
+		}
+		return "", fmt.Errorf("failed to read %s: %w", target.Path, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory; explain takes a file", target.Path)
+	}
+	if binary, _ := tool.IsBinaryContent(path); binary {
+		return "", fmt.Errorf("%s is a binary file", target.Path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", target.Path, err)
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+
+	// Locate the symbol definition
+	defLine := 0
+	if target.Symbol != "" {
+		defLine = findDefinition(lines, target.Symbol)
+		if defLine == 0 {
+			return "", fmt.Errorf("symbol %q not found in %s", target.Symbol, target.Path)
+		}
+	}
+
+	// Send the whole file if it fits, otherwise the region around the symbol
+	start, end := 1, len(lines)
+	if len(data) > maxExplainBytes {
+		if defLine > 0 {
+			start = max(1, defLine-explainContext/4)
+			end = min(len(lines), defLine+explainContext)
+		}
+		size := linesSize(lines[start-1 : end])
+		for end > start && size > maxExplainBytes {
+			size -= len(lines[end-1]) + 1
+			end--
+		}
+	}
+
+	display := target.Resource + "/" + target.Path
+	var sb strings.Builder
+	if target.Symbol != "" {
+		sb.WriteString(fmt.Sprintf("Explain `%s` in %s (defined at line %d).\n\n", target.Symbol, display, defLine))
+	} else {
+		sb.WriteString(fmt.Sprintf("Explain %s.\n\n", display))
+	}
+
+	sb.WriteString(fmt.Sprintf("## %s", display))
+	if start > 1 || end < len(lines) {
+		sb.WriteString(fmt.Sprintf(" (lines %d-%d of %d)", start, end, len(lines)))
+	}
+	sb.WriteString("\n\n```" + strings.TrimPrefix(filepath.Ext(path), ".") + "\n")
+	for i := start; i <= end; i++ {
+		sb.WriteString(fmt.Sprintf("%5d\t%s\n", i, lines[i-1]))
+	}
+	sb.WriteString("```\n")
+
+	refs := findReferences(root, target, defLine)
+	if len(refs) > 0 {
+		sb.WriteString(fmt.Sprintf("\n## References (%d)\n\n", len(refs)))
+		for _, ref := range refs {
+			sb.WriteString(target.Resource + "/" + ref + "\n")
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// findDefinition returns the 1-based line where symbol is most likely defined
+// Lines that look like declarations win; otherwise the first mention is used.
+// For "Type.method" only the last component is searched for
+func findDefinition(lines []string, symbol string) int {
+	name := symbol[strings.LastIndex(symbol, ".")+1:]
+	re := regexp.MustCompile(`(^|[^A-Za-z0-9_$])` + regexp.QuoteMeta(name) + `([^A-Za-z0-9_$]|$)`)
+
+	first := 0
+	for i, line := range lines {
+		if !re.MatchString(line) {
+			continue
+		}
+		if definitionPattern.MatchString(line) {
+			return i + 1
+		}
+		if first == 0 {
+			first = i + 1
+		}
+	}
+	return first
+}
+
+// findReferences finds other places in the resource that mention the target
+// Symbols are matched by name; files by their base name on lines that look
+// like imports
+func findReferences(root string, target ExplainTarget, defLine int) []string {
+	name := target.Symbol[strings.LastIndex(target.Symbol, ".")+1:]
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(target.Path), filepath.Ext(target.Path))
+		if name == "index" || name == "main" || name == "mod" || name == "__init__" {
+			name = filepath.Base(filepath.Dir(target.Path))
+		}
+		if name == "." || name == "" {
+			return nil
+		}
+	}
+
+	matches, err := search.Grep(root, `\b`+regexp.QuoteMeta(name)+`\b`, search.GrepOptions{
+		MaxMatches:    maxExplainRefs * 4,
+		MaxLineLength: 200,
+	})
+	if err != nil {
+		return nil
+	}
+
+	var refs []string
+	for _, m := range matches {
+		rel, err := filepath.Rel(root, m.Path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(rel, ".git/") {
+			continue
+		}
+		// The definition itself (or the file itself) is already in the prompt
+		if rel == target.Path && (target.Symbol == "" || m.LineNum == defLine) {
+			continue
+		}
+		if target.Symbol == "" && !importPattern.MatchString(m.LineText) {
+			continue
+		}
+		refs = append(refs, fmt.Sprintf("%s:%d: %s", rel, m.LineNum, strings.TrimSpace(m.LineText)))
+		if len(refs) == maxExplainRefs {
+			break
+		}
+	}
+	return refs
+}
+
+// linesSize returns the byte size of lines joined by newlines
+func linesSize(lines []string) int {
+	size := 0
+	for _, line := range lines {
+		size += len(line) + 1
+	}
+	return size
+}
@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultSavePath is the file an answer is saved to when no name is given
+const DefaultSavePath = "{date}-{slug}.md"
+
+// maxSlugLength caps the {slug} of a saved answer's file name
+const maxSlugLength = 60
+
+// SavedAnswer is an answer written to a markdown file (btcx ask --save, /save)
+type SavedAnswer struct {
+	// Question is the question that was answered
+	Question string
+
+	// Content is the raw markdown answer
+	Content string
+
+	// Citations are the files the answer was based on
+	Citations []Citation
+
+	// Model is the name of the model that answered
+	Model string
+
+	// ThreadID is the thread the answer belongs to
+	ThreadID string
+
+	// Time is when the answer was saved
+	Time time.Time
+}
+
+// SaveAnswer writes the answer and its citations to the file named by
+// pathTemplate and returns the path written. The template may use {date},
+// {time}, {slug} (from the question), {thread} and {model}, e.g.
+// "answers/{date}-{slug}.md".
+// An existing file is kept, and the answer saved as name-2.md, name-3.md
+// and so on, unless overwrite is set
+func SaveAnswer(pathTemplate string, answer SavedAnswer, overwrite bool) (string, error) {
+	if answer.Time.IsZero() {
+		answer.Time = time.Now()
+	}
+
+	path := ExpandSavePath(pathTemplate, answer)
+	if path == "" {
+		return "", fmt.Errorf("no file name to save the answer to")
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	data := []byte(answer.Markdown())
+	if overwrite {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return "", fmt.Errorf("failed to save answer: %w", err)
+		}
+		return path, nil
+	}
+
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 1; ; n++ {
+		name := path
+		if n > 1 {
+			name = fmt.Sprintf("%s-%d%s", base, n, ext)
+		}
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to save answer: %w", err)
+		}
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to save answer: %w", err)
+		}
+		return name, nil
+	}
+}
+
+// ExpandSavePath fills in the placeholders of a save path template
+func ExpandSavePath(pathTemplate string, answer SavedAnswer) string {
+	slug := Slug(answer.Question)
+	if slug == "" {
+		slug = "answer"
+	}
+	return strings.NewReplacer(
+		"{date}", answer.Time.Format("2006-01-02"),
+		"{time}", answer.Time.Format("150405"),
+		"{slug}", slug,
+		"{thread}", answer.ThreadID,
+		"{model}", Slug(answer.Model),
+	).Replace(strings.TrimSpace(pathTemplate))
+}
+
+// Markdown renders the answer followed by an appendix of its sources
+func (s SavedAnswer) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSpace(s.Content))
+	sb.WriteString("\n")
+
+	if len(s.Citations) > 0 {
+		sb.WriteString("\n## Sources\n\n")
+		for _, c := range s.Citations {
+			sb.WriteString("- " + c.Markdown() + "\n")
+		}
+	}
+	if notes := LicenseNotes(s.Citations); len(notes) > 0 {
+		sb.WriteString("\n## Licenses\n\n")
+		for _, note := range notes {
+			sb.WriteString("- " + note + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// Slug turns text into a lowercase, dash-separated file name part, e.g.
+// "How does $state work?" becomes "how-does-state-work"
+func Slug(text string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+
+	slug := []rune(sb.String())
+	if len(slug) <= maxSlugLength {
+		return string(slug)
+	}
+	// Cut at a word boundary where there is one
+	cut := string(slug[:maxSlugLength])
+	if i := strings.LastIndexByte(cut, '-'); i > 0 {
+		cut = cut[:i]
+	}
+	return cut
+}
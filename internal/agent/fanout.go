@@ -0,0 +1,173 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/resource"
+	"github.com/nickcecere/btcx/internal/storage"
+)
+
+// maxSummaryChars caps each answer's summary in the per-resource table
+const maxSummaryChars = 300
+
+// ResourceAnswer is one resource's answer to a question asked of each
+// resource separately
+type ResourceAnswer struct {
+	// Resource is the resource that was searched
+	Resource string
+
+	// Content is the answer
+	Content string
+
+	// Citations are the files read while answering
+	Citations []Citation
+
+	// Usage is the answer's token usage
+	Usage provider.Usage
+
+	// Err is set if answering failed; the other answers are still used
+	Err error
+}
+
+// EachResource answers a question against each scoped collection (one per
+// resource) independently, with the normal search loop, and combines the
+// answers into a summary table followed by each full answer
+// Unlike Compare, nothing is merged or compared, so answers can't bleed
+// into each other. progress, if set, is called as each resource starts
+func (a *Agent) EachResource(ctx context.Context, question string, scoped []*resource.Collection, progress func(step string), callback StreamCallback) (*Response, []ResourceAnswer, error) {
+	if len(scoped) < 2 {
+		return nil, nil, fmt.Errorf("asking each resource needs at least two resources")
+	}
+
+	var answers []ResourceAnswer
+	var toolCalls []storage.ToolCall
+	var usage provider.Usage
+	breakdown := &storage.UsageBreakdown{}
+	failed := 0
+	for _, collection := range scoped {
+		name := strings.Join(collectionNames(collection), ", ")
+		if progress != nil {
+			progress("Asking " + name)
+		}
+
+		r, _, err := a.askWith(ctx, a.ModelConfig, collection, question, toolEvents(callback))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s failed: %v\n", name, err)
+			answers = append(answers, ResourceAnswer{Resource: name, Err: err})
+			failed++
+			continue
+		}
+		answers = append(answers, ResourceAnswer{
+			Resource:  name,
+			Content:   a.postProcess(r.Content),
+			Citations: Citations(r.ToolCalls),
+			Usage:     r.Usage,
+		})
+		toolCalls = append(toolCalls, r.ToolCalls...)
+		addUsage(&usage, r.Usage)
+		breakdown.Add(r.Breakdown)
+	}
+	if failed == len(answers) {
+		return nil, answers, fmt.Errorf("every resource failed: %w", answers[0].Err)
+	}
+
+	content := resourceAnswerTable(answers)
+	if callback != nil {
+		callback(provider.StreamEvent{Type: provider.StreamEventText, Delta: content})
+	}
+
+	// Keep the question and combined answers as a thread so it can be continued
+	a.Thread = &storage.Thread{
+		ID:        generateID(),
+		Title:     truncateTitle(question),
+		Created:   time.Now(),
+		Updated:   time.Now(),
+		Resources: a.getResourceNames(),
+		Provider:  string(a.ModelConfig.Provider),
+		Model:     a.ModelConfig.Model,
+		Snapshots: a.snapshotResources(ctx),
+		Messages: []storage.Message{
+			{Role: "user", Content: question, Timestamp: time.Now()},
+			{Role: "assistant", Content: content, Breakdown: breakdown, Timestamp: time.Now()},
+		},
+	}
+	if err := a.Storage.SaveThread(a.Thread); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save thread: %v\n", err)
+	}
+
+	return &Response{
+		Content:   content,
+		ToolCalls: toolCalls,
+		Usage:     usage,
+		Breakdown: breakdown,
+	}, answers, nil
+}
+
+// resourceAnswerTable formats per-resource answers as a Markdown table of
+// summaries followed by a section with each full answer
+func resourceAnswerTable(answers []ResourceAnswer) string {
+	var sb strings.Builder
+	sb.WriteString("| Resource | Answer |\n|----------|--------|\n")
+	for _, r := range answers {
+		summary := summarizeAnswer(r.Content)
+		if r.Err != nil {
+			summary = "failed: " + r.Err.Error()
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s |\n", r.Resource, tableCell(summary)))
+	}
+
+	for _, r := range answers {
+		if r.Err != nil {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n## %s\n\n%s\n", r.Resource, strings.TrimSpace(r.Content)))
+	}
+	return sb.String()
+}
+
+// summarizeAnswer returns the first paragraph of prose in an answer,
+// skipping headings, code blocks and tables
+func summarizeAnswer(content string) string {
+	inCode := false
+	var paragraph []string
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+			continue
+		}
+		switch {
+		case inCode, strings.HasPrefix(trimmed, "#"), strings.HasPrefix(trimmed, "|"):
+			continue
+		case trimmed == "":
+			if len(paragraph) > 0 {
+				return truncateSummary(strings.Join(paragraph, " "))
+			}
+		default:
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+	if len(paragraph) == 0 {
+		return "(no answer)"
+	}
+	return truncateSummary(strings.Join(paragraph, " "))
+}
+
+// truncateSummary cuts a summary to maxSummaryChars
+func truncateSummary(s string) string {
+	if len(s) <= maxSummaryChars {
+		return s
+	}
+	return strings.ToValidUTF8(s[:maxSummaryChars], "") + "..."
+}
+
+// tableCell escapes text for a Markdown table cell
+func tableCell(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	return strings.ReplaceAll(s, "|", `\|`)
+}
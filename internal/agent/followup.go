@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/storage"
+	"github.com/nickcecere/btcx/internal/tool"
+)
+
+const (
+	// maxFollowUpFiles is the number of previously cited files outlined for
+	// a follow-up question
+	maxFollowUpFiles = 8
+	// maxOutlineEntries is the number of declarations listed per file
+	maxOutlineEntries = 20
+	// maxOutlineBytes is the size above which files aren't outlined
+	maxOutlineBytes = 2 << 20
+)
+
+// followUpHint returns the system prompt section for a follow-up question:
+// an outline of each file cited in the previous answer, so questions like
+// "show me the full function" can read the right lines without searching
+// again; start is the index of the follow-up in the thread
+func (a *Agent) followUpHint(start int) string {
+	citations := Citations(previousToolCalls(a.Thread.Messages[:start]))
+	if len(citations) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	files := 0
+	for _, c := range citations {
+		if files == maxFollowUpFiles {
+			break
+		}
+		outline, ok := a.outlineFile(c)
+		if !ok {
+			continue
+		}
+		sb.WriteString(outline)
+		files++
+	}
+	if files == 0 {
+		return ""
+	}
+
+	return `
+
+## Files From the Previous Answer
+
+The previous answer cited these files. Declarations are listed with their line numbers, so for follow-ups
+about them (e.g. "show the full function") read the lines you need directly instead of searching again.
+Search as usual for anything else.
+
+` + sb.String()
+}
+
+// previousToolCalls returns the tool calls made to answer the last question
+// in messages
+func previousToolCalls(messages []storage.Message) []storage.ToolCall {
+	var calls []storage.ToolCall
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		if msg.Role == "user" {
+			break
+		}
+		if msg.Role == "assistant" {
+			calls = append(append([]storage.ToolCall{}, msg.ToolCalls...), calls...)
+		}
+	}
+	return calls
+}
+
+// outlineFile describes a cited file: its length, the lines that were read
+// and its declarations; ok is false if it can't be read anymore or the
+// sandbox rejects it, since the thread's citations may be stale or edited
+func (a *Agent) outlineFile(c Citation) (outline string, ok bool) {
+	path, err := a.Tools.Sandbox().Resolve(a.Collection.Path, filepath.FromSlash(c.Path))
+	if err != nil {
+		return "", false
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Size() > maxOutlineBytes {
+		return "", false
+	}
+	if binary, _ := tool.IsBinaryContent(path); binary {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("- %s (%d lines", c.Path, len(lines)))
+	switch {
+	case c.StartLine > 0 && c.EndLine > 0:
+		sb.WriteString(fmt.Sprintf("; lines %d-%d were read", c.StartLine, min(c.EndLine, len(lines))))
+	case c.StartLine > 0:
+		sb.WriteString(fmt.Sprintf("; read from line %d", c.StartLine))
+	}
+	sb.WriteString(")\n")
+
+	entries := 0
+	for i, line := range lines {
+		if !tool.IsDeclaration(line) {
+			continue
+		}
+		if entries == maxOutlineEntries {
+			sb.WriteString("  - ...\n")
+			break
+		}
+		text := strings.TrimSpace(line)
+		if len(text) > 100 {
+			text = text[:97] + "..."
+		}
+		sb.WriteString(fmt.Sprintf("  - %d: %s\n", i+1, text))
+		entries++
+	}
+	return sb.String(), true
+}
@@ -6,11 +6,19 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/nickcecere/btcx/internal/answercache"
+	"github.com/nickcecere/btcx/internal/config"
 	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/resource"
 	"github.com/nickcecere/btcx/internal/storage"
+	"github.com/nickcecere/btcx/internal/tool"
+	"github.com/nickcecere/btcx/internal/webhook"
 )
 
 // loopState tracks state during the agentic loop to detect stuck patterns
@@ -24,8 +32,21 @@ type loopState struct {
 	// totalSearches tracks total number of searches performed
 	totalSearches int
 
-	// hintInjected tracks if we've already injected a hint
-	hintInjected bool
+	// steps is the number of strategy steps already applied
+	steps int
+
+	// guidance is sent as a reminder after the conversation by strategy steps
+	guidance string
+
+	// lastEmpty is the most recent tool call that returned nothing
+	lastEmpty *provider.ToolCall
+
+	// answerNow is set once the model has been told to stop searching
+	answerNow bool
+
+	// synthesize is set once the triage model has handed over to the
+	// answering model
+	synthesize bool
 }
 
 // newLoopState creates a new loop state tracker
@@ -62,6 +83,22 @@ type Response struct {
 
 	// Usage is the token usage
 	Usage provider.Usage
+
+	// Cached is set when the answer was served from the answer cache
+	Cached *answercache.Hit
+
+	// Runs are the per-model results of an ensemble answer
+	Runs []ModelRun
+
+	// Verification is the judge's assessment of the answer (nil unless verified)
+	Verification *storage.Verification
+
+	// Breakdown attributes the search loop's tokens to tools and requests
+	Breakdown *storage.UsageBreakdown
+
+	// partial is set when the loop was cut short (forced completion or
+	// iteration limit); partial answers are never cached
+	partial bool
 }
 
 // StreamCallback is called for each streaming event
@@ -85,50 +122,265 @@ func (a *Agent) AskWithCallback(ctx context.Context, question string, callback S
 			Resources: a.getResourceNames(),
 			Provider:  string(a.ModelConfig.Provider),
 			Model:     a.ModelConfig.Model,
+			Snapshots: a.snapshotResources(ctx),
 			Messages:  []storage.Message{},
 		}
 		// Set thread ID for truncation output organization
 		a.Tools.SetThreadID(threadID)
 	}
 
+	// Only standalone questions are cacheable; follow-ups depend on context
+	standalone := len(a.Thread.Messages) == 0
+	useAnswerCache := a.AnswerCache != nil && standalone
+	var cacheState answercache.State
+	if useAnswerCache {
+		manifests, err := a.Collection.Manifests(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to build resource manifests: %v\n", err)
+		}
+		versions := a.Collection.Versions(manifests)
+		// An answer from part of a resource doesn't answer for all of it
+		for _, r := range a.Collection.Resources {
+			if r.SearchPath != "" && versions[r.Name] != "" {
+				versions[r.Name] += "@" + r.SearchPath
+			}
+		}
+		cacheState = answercache.State{
+			Versions: versions,
+			Hash:     resource.ManifestHasher(manifests),
+		}
+	}
+
+	// Messages from here on are this question's evidence
+	start := len(a.Thread.Messages)
+
 	// Add user message
 	userMsg := storage.Message{
 		Role:      "user",
 		Content:   question,
+		Retry:     a.retrying,
 		Timestamp: time.Now(),
 	}
 	a.Thread.Messages = append(a.Thread.Messages, userMsg)
 
-	// Run the agentic loop
-	response, err := a.runLoop(ctx, callback)
-	if err != nil {
-		return nil, err
+	// Label the question for btcx stats, cached answers included
+	var classifyUsage provider.Usage
+	if a.Config.Classify.Enabled {
+		category, usage, err := a.classifyQuestion(ctx, question)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			a.Thread.Messages[start].Category = category
+			classifyUsage = usage
+		}
+	}
+
+	var response *Response
+	if useAnswerCache {
+		if hit, ok := a.AnswerCache.Lookup(question, cacheState); ok {
+			response = &Response{
+				Content: hit.Answer + cachedNote(hit),
+				Cached:  hit,
+			}
+			a.Thread.Messages = append(a.Thread.Messages, storage.Message{
+				Role:      "assistant",
+				Content:   response.Content,
+				Timestamp: time.Now(),
+			})
+		}
+	}
+
+	if response == nil {
+		// List examples first for usage questions
+		a.boost = a.boostFor(question)
+		a.Tools.SetBoost(a.boost)
+
+		// Suggest search terms for standalone questions
+		a.searchHint = ""
+		var expansionUsage provider.Usage
+		if a.Config.QueryExpansion.Enabled && standalone {
+			terms, usage, err := a.expandQuery(ctx, question)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			} else {
+				a.searchHint = searchTermsHint(terms)
+				expansionUsage = usage
+			}
+		}
+
+		// Follow-ups start from the files the previous answer cited
+		a.followUp = ""
+		if !standalone && !a.retrying {
+			a.followUp = a.followUpHint(start)
+		}
+
+		// Run the agentic loop
+		var err error
+		response, err = a.runLoop(ctx, a.answerCallback(callback))
+		if err != nil {
+			return nil, err
+		}
+		// Process the answer before it is shown, cached or saved
+		response.Content = a.emitAnswer(response.Content, callback)
+		if len(a.postProcessors) > 0 {
+			for i := start; i < len(a.Thread.Messages); i++ {
+				if msg := &a.Thread.Messages[i]; msg.Role == "assistant" && msg.Content != "" {
+					msg.Content = a.postProcess(msg.Content)
+				}
+			}
+		}
+		addUsage(&response.Usage, expansionUsage)
+
+		// Keep the breakdown with the answer, for btcx stats
+		for i := len(a.Thread.Messages) - 1; i >= start; i-- {
+			if a.Thread.Messages[i].Role == "assistant" {
+				a.Thread.Messages[i].Breakdown = response.Breakdown
+				break
+			}
+		}
+
+		if useAnswerCache && !response.partial {
+			cited := a.citedPaths(response.ToolCalls)
+			if err := a.AnswerCache.Store(question, response.Content, a.ModelConfig.Name, cacheState, cited); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to cache answer: %v\n", err)
+			}
+		}
+
+		// Checked after caching so the cache keeps the plain answer
+		if a.verifyAnswers {
+			a.appendVerification(ctx, question, response, a.Thread.Messages[start:], callback)
+		}
+		if a.validateExamples {
+			a.appendExampleCheck(ctx, response, callback)
+		}
+	}
+
+	addUsage(&response.Usage, classifyUsage)
+
+	// Record which model answered, so retries can be compared
+	for i := start; i < len(a.Thread.Messages); i++ {
+		if a.Thread.Messages[i].Role == "assistant" && a.Thread.Messages[i].Model == "" {
+			a.Thread.Messages[i].Model = a.ModelConfig.Name
+		}
 	}
 
 	// Save thread
 	if err := a.Storage.SaveThread(a.Thread); err != nil {
 		// Log but don't fail
-		fmt.Printf("Warning: failed to save thread: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Warning: failed to save thread: %v\n", err)
+	}
+
+	// Notify completion webhook
+	if err := a.sendWebhook(ctx, question, response); err != nil {
+		// Log but don't fail
+		fmt.Fprintf(os.Stderr, "Warning: failed to send webhook: %v\n", err)
 	}
 
 	return response, nil
 }
 
+// citedPaths returns the collection-relative paths of the files read
+func (a *Agent) citedPaths(toolCalls []storage.ToolCall) []string {
+	var paths []string
+	for _, c := range Citations(toolCalls) {
+		path := filepath.FromSlash(c.Path)
+		if filepath.IsAbs(path) {
+			rel, err := filepath.Rel(a.Collection.Path, path)
+			if err != nil {
+				rel = path
+			}
+			path = rel
+		}
+		paths = append(paths, filepath.ToSlash(path))
+	}
+	return paths
+}
+
+// cachedNote describes where a cached answer came from
+func cachedNote(hit *answercache.Hit) string {
+	names := make([]string, 0, len(hit.Versions))
+	for name := range hit.Versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var refs []string
+	for _, name := range names {
+		version := strings.TrimPrefix(hit.Versions[name], "sha256:")
+		if len(version) > 7 {
+			version = version[:7]
+		}
+		refs = append(refs, name+"@"+version)
+	}
+
+	return fmt.Sprintf("\n\n_[Cached answer from %s, cached at %s. Use --no-answer-cache for a fresh answer.]_",
+		hit.Created.Format("2006-01-02 15:04"), strings.Join(refs, ", "))
+}
+
+// sendWebhook posts a summary of the completed ask to the configured webhook
+func (a *Agent) sendWebhook(ctx context.Context, question string, response *Response) error {
+	if a.Config == nil || a.Config.Webhook.URL == "" {
+		return nil
+	}
+
+	return webhook.Send(ctx, a.Config.Webhook, &webhook.Payload{
+		ThreadID: a.Thread.ID,
+		Question: question,
+		Answer:   response.Content,
+		Usage: webhook.Usage{
+			InputTokens:  response.Usage.InputTokens,
+			OutputTokens: response.Usage.OutputTokens,
+			TotalTokens:  response.Usage.TotalTokens,
+		},
+		Model: webhook.Model{
+			Name:     a.ModelConfig.Name,
+			Provider: string(a.ModelConfig.Provider),
+			Model:    a.ModelConfig.Model,
+		},
+		Resources: a.Thread.Resources,
+		Timestamp: time.Now(),
+	})
+}
+
 // runLoop runs the agentic loop until completion
-func (a *Agent) runLoop(ctx context.Context, callback StreamCallback) (*Response, error) {
-	maxIterations := 10 // Prevent infinite loops
+func (a *Agent) runLoop(ctx context.Context, callback StreamCallback) (response *Response, err error) {
+	loop := a.loopConfig()
 	totalUsage := provider.Usage{}
 	var allToolCalls []storage.ToolCall
 	state := newLoopState()
 
-	for i := 0; i < maxIterations; i++ {
+	breakdown := &storage.UsageBreakdown{}
+	defer func() {
+		if response != nil {
+			response.Breakdown = breakdown
+		}
+	}()
+
+	// With strategy: triage a small model runs the searches, and this model
+	// takes over once it is ready to answer
+	tri, err := a.triageModel()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the first question of a thread has nothing to answer from yet
+	firstQuestion := len(a.Thread.Messages) == 1
+
+	for i := 0; i < loop.MaxIterations; i++ {
 		// Build messages for the provider
 		messages := a.buildMessages()
 
-		// Build system prompt, adding hint if stuck
-		systemPrompt := a.GetSystemPrompt()
-		if state.hintInjected {
-			systemPrompt += StuckLoopHint()
+		// Build system prompt
+		systemPrompt := a.GetSystemPrompt() + a.searchHint + a.followUp
+
+		// Guidance for this turn only, e.g. when stuck, goes in a reminder so
+		// the system prompt doesn't change between turns; so do the notes
+		reminder := a.notesBlock() + state.guidance
+
+		// The last turn and forced answers always go to this model
+		triaging := tri != nil && !state.synthesize && !state.answerNow && i < loop.MaxIterations-1
+		if tri != nil && !triaging {
+			reminder += triageSynthesisHint
 		}
 
 		// Create chat request
@@ -137,39 +389,77 @@ func (a *Agent) runLoop(ctx context.Context, callback StreamCallback) (*Response
 			System:    systemPrompt,
 			Messages:  messages,
 			Tools:     a.GetTools(),
-			MaxTokens: 8192,
+			MaxTokens: a.maxTokens(),
+			Reminder:  strings.TrimSpace(reminder),
+		}
+
+		// Search before answering a new question, and stop searching once
+		// the model has to answer
+		if loop.UsesToolChoice() {
+			switch {
+			case state.answerNow || i == loop.MaxIterations-1:
+				req.ToolChoice = &provider.ToolChoice{Mode: provider.ToolChoiceNone}
+			case i == 0 && firstQuestion:
+				req.ToolChoice = &provider.ToolChoice{Mode: provider.ToolChoiceRequired}
+			}
 		}
 
 		var resp *provider.ChatResponse
-		var err error
 
-		// Use streaming mode unless provider is openai-compatible (may have non-standard streaming)
-		useStreaming := callback != nil && a.ModelConfig.Provider != "openai-compatible"
+		// Fit the request to the model that gets it
+		model := a.ModelConfig
+		if triaging {
+			model = tri.model
+		}
+		sent := adaptRequest(req, model)
+
+		// Anthropic turns thinking off under a forced tool choice, and a turn
+		// with thinking can't follow a tool call made without it, so a model
+		// that thinks is never forced to search and thinks on every turn
+		// (adaptRequest copied the request to set its thinking budget)
+		if sent.ThinkingBudget > 0 && sent.ToolChoice != nil && sent.ToolChoice.Mode == provider.ToolChoiceRequired {
+			sent.ToolChoice = nil
+		}
 
-		if useStreaming {
+		// Replies with tool calls written as text aren't streamed, so the
+		// calls don't show up in the answer
+		useStreaming := callback != nil && model.SupportsStreaming() && model.SupportsTools()
+
+		if triaging {
+			// The small model's text is a draft, so it isn't streamed
+			sent.Model = tri.model.Model
+			resp, err = tri.provider.Chat(ctx, sent)
+		} else if useStreaming {
 			// Streaming mode
-			resp, err = a.streamChat(ctx, req, callback)
+			resp, err = a.streamChat(ctx, sent, callback)
 		} else {
 			// Non-streaming mode
-			resp, err = a.Provider.Chat(ctx, req)
+			resp, err = a.Provider.Chat(ctx, sent)
 		}
 
 		if err != nil {
 			return nil, fmt.Errorf("chat request failed: %w", err)
 		}
+		if !model.SupportsTools() {
+			parseTextToolCalls(resp, req, model.TextToolFormat())
+		}
 
 		// Accumulate usage
-		totalUsage.InputTokens += resp.Usage.InputTokens
-		totalUsage.OutputTokens += resp.Usage.OutputTokens
-		totalUsage.TotalTokens += resp.Usage.TotalTokens
+		addUsage(&totalUsage, resp.Usage)
+		recordTurn(breakdown, req, resp)
 
 		// Add assistant message to thread
 		assistantMsg := storage.Message{
-			Role:      "assistant",
-			Content:   resp.Content,
-			Timestamp: time.Now(),
+			Role:               "assistant",
+			Content:            resp.Content,
+			Reasoning:          resp.Reasoning,
+			ReasoningSignature: resp.ReasoningSignature,
+			Timestamp:          time.Now(),
 		}
 
+		// Fix malformed arguments before they are stored or sent back
+		malformed := a.repairToolCalls(resp.ToolCalls)
+
 		// Convert tool calls
 		for _, tc := range resp.ToolCalls {
 			assistantMsg.ToolCalls = append(assistantMsg.ToolCalls, storage.ToolCall{
@@ -184,7 +474,16 @@ func (a *Agent) runLoop(ctx context.Context, callback StreamCallback) (*Response
 			})
 		}
 
+		// The small model is done searching; drop its draft and have this
+		// model answer from the results
+		if triaging && len(resp.ToolCalls) == 0 {
+			state.synthesize = true
+			continue
+		}
+
 		a.Thread.Messages = append(a.Thread.Messages, assistantMsg)
+		assistantIdx := len(a.Thread.Messages) - 1
+		firstCall := len(allToolCalls) - len(resp.ToolCalls)
 
 		// Check if we're done (no tool calls)
 		if len(resp.ToolCalls) == 0 {
@@ -207,14 +506,20 @@ func (a *Agent) runLoop(ctx context.Context, callback StreamCallback) (*Response
 				Content:   content,
 				ToolCalls: allToolCalls,
 				Usage:     totalUsage,
+				partial:   state.answerNow,
 			}, nil
 		}
 
+		// The model was told to answer but kept searching
+		if state.answerNow {
+			return a.forceCompletion(allToolCalls, totalUsage)
+		}
+
 		// Execute tool calls and track patterns
 		hasUsefulResult := false
 		hasRepeatedSearch := false
 
-		for _, tc := range resp.ToolCalls {
+		for i, tc := range resp.ToolCalls {
 			// Track this tool call
 			hash := hashToolCall(tc.Name, tc.Arguments)
 			state.searchHistory[hash]++
@@ -225,16 +530,30 @@ func (a *Agent) runLoop(ctx context.Context, callback StreamCallback) (*Response
 				hasRepeatedSearch = true
 			}
 
-			result, err := a.executeTool(ctx, tc, callback)
+			var result storage.ToolResult
+			var err error
+			if msg := malformed[i]; msg != "" {
+				// Not run; the model is asked to send the call again
+				output := "Error: " + msg
+				result = storage.ToolResult{ToolCallID: tc.ID, Output: output, OutputBytes: len(output)}
+			} else {
+				result, err = a.executeTool(ctx, tc, callback)
+			}
 
 			// Add tool result message
 			toolMsg := storage.Message{
 				Role:       "tool",
-				Content:    result,
+				Content:    result.Output,
 				Timestamp:  time.Now(),
 				ToolCallID: tc.ID,
 			}
 
+			// Failed reads aren't cited
+			if err != nil || result.Failed() {
+				allToolCalls[firstCall+i].Failed = true
+				a.Thread.Messages[assistantIdx].ToolCalls[i].Failed = true
+			}
+
 			if err != nil {
 				toolMsg.ToolResults = []storage.ToolResult{{
 					ToolCallID: tc.ID,
@@ -242,13 +561,22 @@ func (a *Agent) runLoop(ctx context.Context, callback StreamCallback) (*Response
 					Error:      err.Error(),
 				}}
 			} else {
-				toolMsg.ToolResults = []storage.ToolResult{{
-					ToolCallID: tc.ID,
-					Output:     result,
-				}}
+				toolMsg.ToolResults = []storage.ToolResult{result}
+
+				// Truncated outputs are referenced from the call, so citations
+				// can point at the saved evidence
+				if result.OutputFile != "" {
+					ref := storage.EvidenceID(result.OutputFile)
+					allToolCalls[firstCall+i].Evidence = ref
+					a.Thread.Messages[assistantIdx].ToolCalls[i].Evidence = ref
+				}
+
 				// Check if result has useful content
-				if !isEmptyResult(result) {
+				if !isEmptyResult(result.Output) {
 					hasUsefulResult = true
+				} else if tc.Name == "grep" || tc.Name == "glob" {
+					call := tc
+					state.lastEmpty = &call
 				}
 			}
 
@@ -262,20 +590,28 @@ func (a *Agent) runLoop(ctx context.Context, callback StreamCallback) (*Response
 			state.emptyResultCount++
 		}
 
-		// Inject hidden system hint if model is stuck
-		// This is added to the system prompt context, not as a visible message
-		if (state.emptyResultCount >= 2 || hasRepeatedSearch) && !state.hintInjected {
-			state.hintInjected = true
-			// We'll handle this by modifying the system prompt in the next iteration
+		// Escalate one strategy step per stuck round
+		// Guidance is sent as a reminder, not stored as a visible message
+		stuck := state.emptyResultCount >= loop.StuckAfterEmpty || hasRepeatedSearch
+		if stuck && state.steps < len(loop.Strategy) {
+			a.applyStep(ctx, loop.Strategy[state.steps], loop, state)
+			state.steps++
 		}
 
-		// If we've had too many consecutive empty results, force completion
-		if state.emptyResultCount >= 3 {
-			return a.forceCompletion(allToolCalls, totalUsage)
-		}
-
-		// If we've done many searches without progress, force completion
-		if state.totalSearches >= 8 && state.emptyResultCount >= 2 {
+		// Force completion after too many consecutive empty results, or
+		// after many searches without progress
+		if state.emptyResultCount >= loop.ForceAfterEmpty ||
+			(state.totalSearches >= loop.ForceAfterSearches && state.emptyResultCount >= loop.StuckAfterEmpty) {
+			// Give the model one last turn to answer if the strategy allows it
+			if hasStep(loop, config.LoopStepAnswer) && !state.answerNow {
+				a.applyStep(ctx, config.LoopStepAnswer, loop, state)
+				continue
+			}
+			// Let this model answer from the small model's searches
+			if tri != nil && !state.synthesize {
+				state.synthesize = true
+				continue
+			}
 			return a.forceCompletion(allToolCalls, totalUsage)
 		}
 	}
@@ -294,6 +630,7 @@ func (a *Agent) runLoop(ctx context.Context, callback StreamCallback) (*Response
 					Content:   msg.Content + "\n\n[Note: Response may be incomplete due to iteration limit]",
 					ToolCalls: allToolCalls,
 					Usage:     totalUsage,
+					partial:   true,
 				}, nil
 			}
 		}
@@ -309,7 +646,7 @@ func (a *Agent) streamChat(ctx context.Context, req *provider.ChatRequest, callb
 		return nil, err
 	}
 
-	var content string
+	var content, reasoning, signature string
 	var toolCalls []provider.ToolCall
 	var usage provider.Usage
 	var stopReason string
@@ -323,6 +660,11 @@ func (a *Agent) streamChat(ctx context.Context, req *provider.ChatRequest, callb
 		switch event.Type {
 		case provider.StreamEventText:
 			content += event.Delta
+		case provider.StreamEventReasoning:
+			reasoning += event.Delta
+			if event.Signature != "" {
+				signature = event.Signature
+			}
 		case provider.StreamEventToolCall:
 			if event.ToolCall != nil {
 				toolCalls = append(toolCalls, *event.ToolCall)
@@ -338,15 +680,18 @@ func (a *Agent) streamChat(ctx context.Context, req *provider.ChatRequest, callb
 	}
 
 	return &provider.ChatResponse{
-		Content:    content,
-		ToolCalls:  toolCalls,
-		StopReason: stopReason,
-		Usage:      usage,
+		Content:            content,
+		Reasoning:          reasoning,
+		ReasoningSignature: signature,
+		ToolCalls:          toolCalls,
+		StopReason:         stopReason,
+		Usage:              usage,
 	}, nil
 }
 
 // executeTool executes a tool call
-func (a *Agent) executeTool(ctx context.Context, tc provider.ToolCall, callback StreamCallback) (string, error) {
+// Tool errors are returned as the result's output, not as Go errors
+func (a *Agent) executeTool(ctx context.Context, tc provider.ToolCall, callback StreamCallback) (storage.ToolResult, error) {
 	// Notify callback about tool execution starting
 	if callback != nil {
 		callback(provider.StreamEvent{
@@ -355,7 +700,9 @@ func (a *Agent) executeTool(ctx context.Context, tc provider.ToolCall, callback
 		})
 	}
 
+	start := time.Now()
 	result, err := a.Tools.Execute(ctx, tc.Name, tc.Arguments)
+	duration := time.Since(start).Milliseconds()
 
 	// Notify callback about tool execution completing
 	if callback != nil {
@@ -366,19 +713,43 @@ func (a *Agent) executeTool(ctx context.Context, tc provider.ToolCall, callback
 	}
 
 	if err != nil {
-		return fmt.Sprintf("Error: %s", err.Error()), nil // Return error as content, not as Go error
+		output := fmt.Sprintf("Error: %s", err.Error())
+		return storage.ToolResult{ToolCallID: tc.ID, Output: output, OutputBytes: len(output), DurationMs: duration}, nil // Return error as content, not as Go error
 	}
 
-	return result.Output, nil
+	stored := storage.ToolResult{
+		ToolCallID:  tc.ID,
+		Output:      result.Output,
+		OutputBytes: len(result.Output),
+		DurationMs:  duration,
+	}
+	stored.Truncated, _ = result.Metadata["truncated"].(bool)
+	if result.Image != nil {
+		stored.Image = result.Image
+		stored.OutputBytes = len(result.Image.Data)
+	}
+	if path, ok := result.Metadata["outputPath"].(string); ok {
+		stored.OutputFile = path
+		if info, err := os.Stat(path); err == nil {
+			stored.OutputBytes = int(info.Size())
+		}
+	}
+	return stored, nil
 }
 
 // buildMessages builds the message list for the provider
 func (a *Agent) buildMessages() []provider.Message {
 	var messages []provider.Message
+	lastQuestion := 0
 
 	for _, msg := range a.Thread.Messages {
 		switch msg.Role {
 		case "user":
+			// A retry replaces the previous attempt at the same question
+			if msg.Retry {
+				messages = messages[:lastQuestion]
+			}
+			lastQuestion = len(messages)
 			messages = append(messages, provider.Message{
 				Role:    "user",
 				Content: msg.Content,
@@ -386,8 +757,10 @@ func (a *Agent) buildMessages() []provider.Message {
 
 		case "assistant":
 			providerMsg := provider.Message{
-				Role:    "assistant",
-				Content: msg.Content,
+				Role:               "assistant",
+				Content:            msg.Content,
+				Reasoning:          msg.Reasoning,
+				ReasoningSignature: msg.ReasoningSignature,
 			}
 			for _, tc := range msg.ToolCalls {
 				providerMsg.ToolCalls = append(providerMsg.ToolCalls, provider.ToolCall{
@@ -407,11 +780,20 @@ func (a *Agent) buildMessages() []provider.Message {
 					content = msg.ToolResults[0].Output
 				}
 			}
-			messages = append(messages, provider.Message{
+			toolMsg := provider.Message{
 				Role:       "tool",
 				Content:    content,
 				ToolCallID: msg.ToolCallID,
-			})
+			}
+			// Images are dropped for models that can't see them, e.g. after
+			// /retry with another model
+			// Images whose file is gone have no data and are dropped too
+			if len(msg.ToolResults) > 0 && a.ModelConfig.SupportsImages() {
+				if img := msg.ToolResults[0].Image; img != nil && len(img.Data) > 0 {
+					toolMsg.Images = []provider.Image{*img}
+				}
+			}
+			messages = append(messages, toolMsg)
 		}
 	}
 
@@ -434,6 +816,8 @@ func generateID() string {
 
 // truncateTitle truncates a title to a reasonable length
 func truncateTitle(s string) string {
+	// Attached context (e.g. an issue from --from-url) follows the first line
+	s, _, _ = strings.Cut(s, "\n")
 	if len(s) > 50 {
 		return s[:47] + "..."
 	}
@@ -456,8 +840,12 @@ func (a *Agent) forceCompletion(allToolCalls []storage.ToolCall, totalUsage prov
 	if lastContent == "" {
 		// Collect useful tool results
 		var usefulResults []string
+		var question string
 		for _, msg := range a.Thread.Messages {
-			if msg.Role == "tool" && len(msg.Content) > 100 && !isEmptyResult(msg.Content) {
+			if msg.Role == "user" {
+				question = msg.Content
+			}
+			if msg.Role == "tool" && len(msg.Content) > 100 && !isEmptyResult(msg.Content) && len(usefulResults) < 3 {
 				// Truncate to reasonable size
 				content := msg.Content
 				if len(content) > 500 {
@@ -469,22 +857,29 @@ func (a *Agent) forceCompletion(allToolCalls []storage.ToolCall, totalUsage prov
 
 		if len(usefulResults) > 0 {
 			lastContent = "Based on the search results, here is what I found:\n\n"
-			for i, result := range usefulResults {
-				if i >= 3 {
-					break // Limit to 3 results
-				}
+			for _, result := range usefulResults {
 				lastContent += result + "\n\n"
 			}
 			lastContent += "[Note: The model was unable to complete the response. Above are the raw search results.]"
 		} else {
 			lastContent = "I was unable to find specific information about this topic in the codebase after multiple searches. The search patterns used did not return relevant results. Try rephrasing your question or being more specific about what you're looking for."
 		}
+
+		if a.templates != nil {
+			lastContent = renderPrompt(a.templates.ForceCompletion, ForceCompletionData{
+				Question: question,
+				Results:  usefulResults,
+				Searches: len(allToolCalls),
+				Default:  lastContent,
+			}, lastContent)
+		}
 	}
 
 	return &Response{
 		Content:   lastContent,
 		ToolCalls: allToolCalls,
 		Usage:     totalUsage,
+		partial:   true,
 	}, nil
 }
 
@@ -498,6 +893,23 @@ func (a *Agent) GetThread() *storage.Thread {
 	return a.Thread
 }
 
+// repairToolCalls fixes tool call arguments models commonly get wrong, like
+// trailing commas or unquoted keys
+// Calls that can't be repaired get empty arguments, so the thread stays valid
+// JSON, and their error messages are returned by call index
+func (a *Agent) repairToolCalls(calls []provider.ToolCall) map[int]string {
+	malformed := make(map[int]string)
+	for i := range calls {
+		fixed, _, err := tool.RepairArguments(calls[i].Arguments)
+		if err != nil {
+			malformed[i] = a.Tools.MalformedArguments(calls[i].Name, calls[i].Arguments, err)
+			fixed = json.RawMessage("{}")
+		}
+		calls[i].Arguments = fixed
+	}
+	return malformed
+}
+
 // ToolExecutionEvent represents a tool execution for callbacks
 type ToolExecutionEvent struct {
 	Name      string
@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"github.com/nickcecere/btcx/internal/tool"
+)
+
+// threadNotes keeps the note tool's notes on the agent's current thread
+type threadNotes struct {
+	agent *Agent
+}
+
+// Notes returns the current thread's notes
+func (n threadNotes) Notes() []string {
+	if n.agent.Thread == nil {
+		return nil
+	}
+	return n.agent.Thread.Notes
+}
+
+// SetNotes replaces the current thread's notes
+func (n threadNotes) SetNotes(notes []string) {
+	if n.agent.Thread != nil {
+		n.agent.Thread.Notes = notes
+	}
+}
+
+// NotesHint returns the system prompt section for the note tool
+func NotesHint() string {
+	return `
+## Investigation Notes
+
+You also have **note**, a scratchpad that persists across turns and follow-up questions.
+After a tool result that matters, note the finding and where it is (file and symbol) in a sentence.
+Your notes are shown at the end of every turn, so rely on them instead of searching again.
+`
+}
+
+// notesBlock returns the thread's notes as a compact block for the reminder
+// ("" when there are none)
+func (a *Agent) notesBlock() string {
+	if a.Thread == nil || len(a.Thread.Notes) == 0 {
+		return ""
+	}
+	if _, ok := a.Tools.Get("note"); !ok {
+		return ""
+	}
+	return "Investigation notes so far:\n" + tool.FormatNotes(a.Thread.Notes) + "\n\n"
+}
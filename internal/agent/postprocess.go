@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/provider"
+)
+
+// defaultRedaction replaces redact matches without a replacement
+const defaultRedaction = "[REDACTED]"
+
+// postProcessor rewrites a final answer
+type postProcessor func(answer string) string
+
+// newPostProcessors builds the output.postProcess pipeline
+func newPostProcessors(steps []config.PostProcessStep) ([]postProcessor, error) {
+	var pipeline []postProcessor
+	for i, step := range steps {
+		if err := step.Validate(); err != nil {
+			return nil, fmt.Errorf("output.postProcess[%d]: %w", i, err)
+		}
+
+		switch step.Type {
+		case config.PostProcessRedact:
+			re := regexp.MustCompile(step.Pattern)
+			replacement := step.Replacement
+			if replacement == "" {
+				replacement = defaultRedaction
+			}
+			pipeline = append(pipeline, func(answer string) string {
+				return re.ReplaceAllString(answer, replacement)
+			})
+		case config.PostProcessLinks:
+			from, to := step.From, step.To
+			pipeline = append(pipeline, func(answer string) string {
+				return strings.ReplaceAll(answer, from, to)
+			})
+		case config.PostProcessBan:
+			phrases := make([]string, len(step.Phrases))
+			for i, phrase := range step.Phrases {
+				phrases[i] = strings.ToLower(phrase)
+			}
+			pipeline = append(pipeline, func(answer string) string {
+				return dropLines(answer, phrases)
+			})
+		}
+	}
+	return pipeline, nil
+}
+
+// dropLines removes the lines containing any of the (lowercase) phrases
+func dropLines(answer string, phrases []string) string {
+	lines := strings.Split(answer, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		lower := strings.ToLower(line)
+		banned := false
+		for _, phrase := range phrases {
+			if strings.Contains(lower, phrase) {
+				banned = true
+				break
+			}
+		}
+		if !banned {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// postProcessKey identifies a pipeline, so answers cached with other steps
+// are kept apart
+func postProcessKey(steps []config.PostProcessStep) string {
+	data, _ := json.Marshal(steps)
+	sum := sha256.Sum256(data)
+	return "post-" + hex.EncodeToString(sum[:4])
+}
+
+// postProcess runs the pipeline over an answer
+func (a *Agent) postProcess(answer string) string {
+	for _, p := range a.postProcessors {
+		answer = p(answer)
+	}
+	return answer
+}
+
+// answerCallback returns the callback to stream an answer to; with a
+// pipeline, text is held back so only the processed answer is shown
+// (see emitAnswer)
+func (a *Agent) answerCallback(callback StreamCallback) StreamCallback {
+	if callback == nil || len(a.postProcessors) == 0 {
+		return callback
+	}
+	return func(event provider.StreamEvent) {
+		if event.Type != provider.StreamEventText {
+			callback(event)
+		}
+	}
+}
+
+// emitAnswer post-processes an answer streamed through answerCallback and
+// sends the result as one text event
+func (a *Agent) emitAnswer(answer string, callback StreamCallback) string {
+	if len(a.postProcessors) == 0 {
+		return answer
+	}
+	answer = a.postProcess(answer)
+	if callback != nil {
+		callback(provider.StreamEvent{Type: provider.StreamEventText, Delta: answer})
+	}
+	return answer
+}
@@ -2,44 +2,74 @@ package agent
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
+	"github.com/nickcecere/btcx/internal/config"
 	"github.com/nickcecere/btcx/internal/resource"
+	"github.com/nickcecere/btcx/internal/search"
+	"github.com/nickcecere/btcx/internal/summary"
 )
 
+// maxOverviewBytes caps each resource overview added to the system prompt
+const maxOverviewBytes = 6 * 1024
+
+// maxGuideBytes caps each resource prompt file added to the system prompt
+const maxGuideBytes = 8 * 1024
+
 // SystemPrompt generates the system prompt for the agent
-func SystemPrompt(collection *resource.Collection) string {
+// guides maps resource names to the content of their prompt files, and maps
+// to their directory maps
+func SystemPrompt(collection *resource.Collection, guides, maps map[string]string) string {
 	var sb strings.Builder
+	hasLayout := false
 
 	sb.WriteString("You answer coding questions by searching these repositories:\n\n")
 
 	for _, r := range collection.Resources {
 		sb.WriteString(fmt.Sprintf("## %s\n", r.Name))
 		sb.WriteString(fmt.Sprintf("Directory: ./%s\n", r.Name))
+		if r.SearchPath != "" {
+			sb.WriteString(fmt.Sprintf("Contains only %s/ of the repository; paths are relative to it\n", r.SearchPath))
+		}
 		if r.Notes != "" {
 			sb.WriteString(fmt.Sprintf("Notes: %s\n", r.Notes))
 		}
+		if guide := guides[r.Name]; guide != "" {
+			sb.WriteString(fmt.Sprintf("Guide:\n%s\n", guide))
+		}
+		if m := maps[r.Name]; m != "" {
+			sb.WriteString(fmt.Sprintf("Layout (directories with their subdirectories):\n%s", m))
+			hasLayout = true
+		}
 		sb.WriteString("\n")
 	}
 
+	searchFirst := "1. SEARCH FIRST using grep or glob before answering."
+	if hasLayout {
+		searchFirst = "1. SEARCH FIRST using grep or glob before answering; use the layouts above to aim searches at the right directories instead of listing."
+	}
+
 	sb.WriteString(`## Available Tools
 
-You have EXACTLY these 4 tools available - use ONLY these tools:
+You have EXACTLY these tools available - use ONLY these tools:
 
 1. **grep** - Search file contents using regex patterns
 2. **glob** - Find files matching a glob pattern (e.g., "*.go", "**/*.md")
 3. **read** - Read contents of a specific file
-4. **list** - List directory contents
+4. **skim** - Show the start, declaration outline and end of a file in one call
+5. **list** - List directory contents
+6. **read_output** - Page through a tool result that was truncated and saved (only when a result says "Full output saved to")
 
 DO NOT try to use any other tools (like "search" or "find"). They do not exist.
 
 ## How to Answer
 
-1. SEARCH FIRST using grep or glob before answering.
+` + searchFirst + `
 2. After finding relevant code (1-3 searches), STOP SEARCHING and write your answer.
 3. Use grep to find code containing specific patterns.
 4. Use glob to locate files by name.
-5. Use read to examine specific files you found.
+5. Use read to examine specific files you found; skim large files first and read only the sections you need.
 6. Quote code directly from results with file paths.
 7. IMPORTANT: Once you have enough information to answer, respond immediately - do not keep searching.
 8. Say "not found in repos" if you can't find relevant code after 2-3 searches.
@@ -68,15 +98,221 @@ DO NOT try to use any other tools (like "search" or "find"). They do not exist.
 	return sb.String()
 }
 
+// LoadGuide reads a resource prompt file for the system prompt
+// Long files are cut to maxGuideBytes
+func LoadGuide(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt file: %w", err)
+	}
+
+	guide := strings.TrimSpace(string(data))
+	if len(guide) > maxGuideBytes {
+		guide = strings.ToValidUTF8(guide[:maxGuideBytes], "") + "\n...(truncated)"
+	}
+	return guide, nil
+}
+
 // ToolDescriptions returns descriptions for all tools
 var ToolDescriptions = map[string]string{
-	"grep": `Search file contents using regex patterns. Use this to find code containing specific patterns.`,
-	"glob": `Find files matching a glob pattern. Use this to locate files by name.`,
-	"read": `Read the contents of a file. Use this to examine specific files.`,
-	"list": `List directory contents. Use this to explore the codebase structure.`,
+	"grep":            `Search file contents using regex patterns. Use this to find code containing specific patterns.`,
+	"glob":            `Find files matching a glob pattern. Use this to locate files by name.`,
+	"read":            `Read the contents of a file. Use this to examine specific files.`,
+	"skim":            `Skim a file's start, outline and end. Use this to triage large files before reading them.`,
+	"list":            `List directory contents. Use this to explore the codebase structure.`,
+	"read_output":     `Page through a truncated tool output. Use this to continue past a truncated result.`,
+	"semantic_search": `Search the resources by meaning. Use this for conceptual questions when exact names are unknown.`,
+	"gitdiff":         `Diff a resource between two versions. Use this for questions about what changed between releases.`,
+	"note":            `Keep working notes. Use this to record findings so they stay visible on later turns.`,
+}
+
+// SemanticSearchHint returns the system prompt section for the semantic_search tool
+func SemanticSearchHint() string {
+	return `
+## Semantic Search
+
+You also have **semantic_search**, which ranks code and doc snippets by meaning and keywords.
+Use it first for conceptual questions ("how does X work?") when you don't know exact names,
+then grep or read the files it points to. Prefer grep when you know the identifier.
+`
+}
+
+// GitDiffHint returns the system prompt section for the gitdiff tool
+// When from is set, the question is about the changes from that version to
+// to (or the cached version if to is empty)
+func GitDiffHint(resources []string, from, to string) string {
+	hint := `
+## Version Diffs
+
+You also have **gitdiff**, which diffs a resource between two tags, branches or commits.
+For questions about what changed, breaking changes or migrations, answer from the actual diff
+rather than memory: call it with stat=true first, then narrow path to the relevant directory.
+CHANGELOG and migration guide files are also worth reading.
+`
+	if from == "" {
+		return hint
+	}
+
+	target, args := "the cached version", fmt.Sprintf("from=%q", from)
+	if to != "" {
+		target, args = fmt.Sprintf("%q", to), fmt.Sprintf("from=%q, to=%q", from, to)
+	}
+	return hint + fmt.Sprintf(`
+This question is about the changes from %q to %s in %s.
+Start with gitdiff (%s, stat=true) and base the answer on what the diff shows, citing the changed files.
+`, from, target, strings.Join(resources, ", "), args)
+}
+
+// ScopeHint returns the system prompt section for a search scope
+func ScopeHint(scope search.Scope) string {
+	var what string
+	switch scope {
+	case search.ScopeDocs:
+		what = "documentation files (md, mdx, rst). Answer from the prose docs; only look at code if the docs don't cover the question"
+	case search.ScopeCode:
+		what = "source code, without docs and tests. Answer from the implementation"
+	case search.ScopeTests:
+		what = "tests. Answer from how the tests use and exercise the code"
+	case search.ScopeExamples:
+		what = "example code and tests. Answer from how they use the code, quoting them"
+	default:
+		return ""
+	}
+	return fmt.Sprintf(`
+## Search Scope
+
+grep, glob and semantic_search only look at %s.
+Pass scope="all" to search everything.
+`, what)
+}
+
+// BoostHint returns the system prompt section for searches that list a
+// kind of files first
+func BoostHint(boost search.Scope) string {
+	var what string
+	switch boost {
+	case search.ScopeDocs:
+		what = "documentation"
+	case search.ScopeCode:
+		what = "source code"
+	case search.ScopeTests:
+		what = "tests"
+	case search.ScopeExamples:
+		what = "examples and tests"
+	default:
+		return ""
+	}
+	return fmt.Sprintf(`
+## Search Priority
+
+grep and glob list matches in %[1]s first. Prefer reading those: they show real usage.
+Pass scope="%[2]s" to search only them.
+`, what, boost)
+}
+
+// languageNames maps common language codes to names for the prompt
+var languageNames = map[string]string{
+	"de": "German", "en": "English", "es": "Spanish", "fr": "French", "it": "Italian",
+	"ja": "Japanese", "ko": "Korean", "nl": "Dutch", "pl": "Polish", "pt": "Portuguese",
+	"ru": "Russian", "sv": "Swedish", "tr": "Turkish", "uk": "Ukrainian", "zh": "Chinese",
+}
+
+// languageKey normalizes a language for use in file names, e.g. "German" -> "de"
+func languageKey(lang string) string {
+	lower := strings.ToLower(lang)
+	for code, name := range languageNames {
+		if strings.ToLower(name) == lower {
+			return code
+		}
+	}
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, lower)
+}
+
+// LanguageHint returns the system prompt section for the answer language
+// lang is a code like "de" or a language name; "" means no instruction
+func LanguageHint(lang string) string {
+	lang = strings.TrimSpace(lang)
+	if lang == "" {
+		return ""
+	}
+	if name, ok := languageNames[strings.ToLower(lang)]; ok {
+		lang = name
+	}
+	return fmt.Sprintf(`
+## Answer Language
+
+Write your answer in %s. Keep code, identifiers, file paths, commands, error messages and quoted
+source or docs exactly as they are; only your own explanations are in %s.
+`, lang, lang)
 }
 
-// StuckLoopHint returns a hint to add to the system prompt when the model appears stuck
+// BrevityHint returns the system prompt section for the answer style
+func BrevityHint(brevity config.Brevity) string {
+	var style string
+	switch brevity {
+	case config.BrevityShort:
+		style = `Keep the answer short: at most two paragraphs, with one code example only if it is essential.
+Lead with the direct answer and skip background, alternatives and caveats unless they change it.`
+	case config.BrevityDeep:
+		style = `Give an exhaustive walkthrough: explain how the relevant code works step by step, cover the
+options, edge cases and related APIs, and include code examples with their file paths for each part.
+Search thoroughly before answering; completeness matters more than length.`
+	default:
+		return ""
+	}
+	return fmt.Sprintf(`
+## Answer Length
+
+%s
+`, style)
+}
+
+// CustomToolsHint returns the system prompt section listing user-defined tools
+func CustomToolsHint(tools []config.CustomToolConfig) string {
+	if len(tools) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n## Project Tools\n\nYour team also provides these tools:\n\n")
+	for _, t := range tools {
+		sb.WriteString(fmt.Sprintf("- **%s** - %s\n", t.Name, strings.TrimSpace(t.Description)))
+	}
+	return sb.String()
+}
+
+// OverviewsHint returns the system prompt section with stored resource overviews
+// versions maps resource names to their current versions, to flag overviews
+// generated from an older checkout
+func OverviewsHint(overviews []*summary.Summary, versions map[string]string) string {
+	if len(overviews) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n## Repository Overviews\n\nUse these overviews to decide where to search. Verify details in the code before answering.\n")
+	for _, s := range overviews {
+		content := s.Content
+		if len(content) > maxOverviewBytes {
+			content = content[:maxOverviewBytes] + "\n[... truncated]"
+		}
+		// Demote headings so they nest under this section
+		content = strings.ReplaceAll("\n"+content, "\n#", "\n###")
+		sb.WriteString(fmt.Sprintf("\n### %s\n", s.Resource))
+		if !s.Current(versions[s.Resource]) {
+			sb.WriteString("(Generated from an older version; paths may have moved.)\n")
+		}
+		sb.WriteString(strings.TrimSpace(content) + "\n")
+	}
+	return sb.String()
+}
+
+// StuckLoopHint returns a hint to remind the model of when it appears stuck
 func StuckLoopHint() string {
 	return `
 
@@ -93,3 +329,15 @@ Your previous searches have not returned useful results. Please:
 If you cannot find relevant code, it's better to give a helpful general answer than to keep searching indefinitely.
 `
 }
+
+// GeneralAnswerHint returns guidance telling the model to stop searching and answer
+func GeneralAnswerHint() string {
+	return `
+
+## IMPORTANT: Answer Now
+
+Searching has not found what you need. Do NOT call any more tools.
+Write your best answer now using what you found so far and general knowledge.
+Clearly state which parts could not be verified in the source code.
+`
+}
@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nickcecere/btcx/internal/config"
+)
+
+// Retry asks the thread's last question again with the agent's model
+// Both attempts are kept in the thread for comparison; the earlier one is
+// hidden from the model so the new answer doesn't just repeat it
+func (a *Agent) Retry(ctx context.Context, callback StreamCallback) (*Response, error) {
+	if a.Thread == nil {
+		return nil, fmt.Errorf("no question to retry")
+	}
+	question := a.Thread.LastQuestion()
+	if question == "" {
+		return nil, fmt.Errorf("no question to retry in thread %s", a.Thread.ID)
+	}
+
+	a.Thread.Provider = string(a.ModelConfig.Provider)
+	a.Thread.Model = a.ModelConfig.Model
+
+	a.retrying = true
+	defer func() { a.retrying = false }()
+	return a.AskWithCallback(ctx, question, callback)
+}
+
+// UseModel switches the agent to another model for the following questions
+func (a *Agent) UseModel(modelCfg *config.ModelConfig) error {
+	p, err := a.newProvider(modelCfg)
+	if err != nil {
+		return err
+	}
+	a.ModelConfig = modelCfg
+	a.Provider = p
+	a.Tools.SetImages(modelCfg.SupportsImages())
+	return nil
+}
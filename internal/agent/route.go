@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/provider"
+)
+
+// DefaultMaxRoutedResources is how many resources routing picks at most
+const DefaultMaxRoutedResources = 3
+
+const routingPrompt = `You pick which repositories can answer a developer's question.
+You are given the available repositories with their notes. Reply with ONLY a JSON array of repository
+names, most relevant first, with at most %d names. Pick the fewest that cover the question; reply []
+if none are relevant. Use the names exactly as listed. No explanations.`
+
+// SelectResources asks a model which configured resources are relevant to
+// a question, most relevant first
+// The routing model (routing.model) defaults to modelCfg; an empty result
+// means no resource looked relevant
+func SelectResources(ctx context.Context, cfg *config.Config, modelCfg *config.ModelConfig, question string) ([]string, provider.Usage, error) {
+	if name := cfg.Routing.Model; name != "" {
+		var err error
+		modelCfg, err = cfg.GetModelConfig(name)
+		if err != nil {
+			return nil, provider.Usage{}, err
+		}
+	}
+
+	p, err := NewProvider(cfg, modelCfg)
+	if err != nil {
+		return nil, provider.Usage{}, err
+	}
+
+	limit := cfg.Routing.MaxResources
+	if limit <= 0 {
+		limit = DefaultMaxRoutedResources
+	}
+
+	resp, err := p.Chat(ctx, &provider.ChatRequest{
+		Model:  modelCfg.Model,
+		System: fmt.Sprintf(routingPrompt, limit),
+		Messages: []provider.Message{
+			{Role: "user", Content: routingCatalog(cfg.Resources) + "\nQuestion: " + question},
+		},
+		MaxTokens: 256,
+	})
+	if err != nil {
+		return nil, provider.Usage{}, fmt.Errorf("resource selection failed: %w", err)
+	}
+
+	return parseSelection(resp.Content, cfg.Resources, limit), resp.Usage, nil
+}
+
+// routingCatalog lists the configured resources for the routing model
+func routingCatalog(resources []config.Resource) string {
+	var sb strings.Builder
+	sb.WriteString("Repositories:\n")
+	for _, r := range resources {
+		source := r.URL
+		if r.Type == config.ResourceTypeLocal {
+			source = r.Path
+		}
+		sb.WriteString(fmt.Sprintf("- %s (%s)", r.Name, source))
+		if r.Notes != "" {
+			sb.WriteString(": " + strings.Join(strings.Fields(r.Notes), " "))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// parseSelection extracts known resource names from the model reply
+// A JSON array is preferred; otherwise names mentioned in the reply are used
+func parseSelection(content string, resources []config.Resource, limit int) []string {
+	known := make(map[string]string, len(resources))
+	for _, r := range resources {
+		known[strings.ToLower(r.Name)] = r.Name
+	}
+
+	var names []string
+	start, end := strings.Index(content, "["), strings.LastIndex(content, "]")
+	if start >= 0 && end > start {
+		_ = json.Unmarshal([]byte(content[start:end+1]), &names)
+	}
+	if len(names) == 0 {
+		names = strings.FieldsFunc(content, func(r rune) bool {
+			return strings.ContainsRune(" \n\t,`\"'", r)
+		})
+	}
+
+	var result []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name, ok := known[strings.ToLower(strings.TrimSpace(name))]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, name)
+		if len(result) == limit {
+			break
+		}
+	}
+	return result
+}
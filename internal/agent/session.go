@@ -0,0 +1,233 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/resource"
+	"github.com/nickcecere/btcx/internal/search"
+	"github.com/nickcecere/btcx/internal/tool"
+)
+
+// SessionVersion is the format version of recorded sessions
+const SessionVersion = 1
+
+// Session is a recording of one question: every model request with its
+// response and every tool call with its result. btcx ask --record writes
+// one, and btcx replay runs the agent loop against it without contacting
+// models or running tools; the prompt is built from the resources as they
+// are when replaying.
+type Session struct {
+	// Version is the format version (SessionVersion)
+	Version int `json:"version"`
+
+	// Created is when the session was recorded
+	Created time.Time `json:"created"`
+
+	// Question is the question asked
+	Question string `json:"question"`
+
+	// Model is the name of the model that answered
+	Model string `json:"model"`
+
+	// Collection is the resources the question was asked about
+	Collection *resource.Collection `json:"collection"`
+
+	// Options are the ask options that shape the agent's requests
+	Options SessionOptions `json:"options"`
+
+	// Exchanges are the model requests and responses, in order
+	Exchanges []provider.Exchange `json:"exchanges"`
+
+	// ToolCalls are the tool calls and results, in order
+	ToolCalls []tool.Call `json:"toolCalls"`
+
+	// Answer is the final answer
+	Answer string `json:"answer,omitempty"`
+
+	// Error is the error the question failed with
+	Error string `json:"error,omitempty"`
+
+	mu        sync.Mutex
+	replaying bool
+	next      int
+	diverged  []int
+}
+
+// SessionOptions are the options of a recorded question
+type SessionOptions struct {
+	Scope    search.Scope   `json:"scope,omitempty"`
+	Boost    string         `json:"boost,omitempty"`
+	Language string         `json:"language,omitempty"`
+	Brevity  config.Brevity `json:"brevity,omitempty"`
+	DiffFrom string         `json:"diffFrom,omitempty"`
+	DiffTo   string         `json:"diffTo,omitempty"`
+}
+
+// NewSession starts recording a question; pass it as Options.Session
+func NewSession(question string) *Session {
+	return &Session{
+		Version:  SessionVersion,
+		Created:  time.Now(),
+		Question: question,
+	}
+}
+
+// LoadSession reads a recorded session for replaying; pass it as
+// Options.Session
+func LoadSession(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+
+	s := &Session{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", path, err)
+	}
+	if s.Version != SessionVersion {
+		return nil, fmt.Errorf("session %s has format version %d; this btcx replays version %d", path, s.Version, SessionVersion)
+	}
+	if s.Collection == nil {
+		return nil, fmt.Errorf("session %s has no resources", path)
+	}
+	s.replaying = true
+	return s, nil
+}
+
+// Save writes the session as JSON
+func (s *Session) Save(path string) error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// Replaying reports whether the session answers from its recording
+func (s *Session) Replaying() bool {
+	return s.replaying
+}
+
+// Replayed returns the number of recorded requests answered so far
+func (s *Session) Replayed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.next
+}
+
+// Diverged returns the numbers (from 1) of the replayed requests that
+// differ from the recorded ones, e.g. after a prompt change
+func (s *Session) Diverged() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.diverged...)
+}
+
+// start fills in what the agent is asked with, when recording
+func (s *Session) start(opts Options, modelCfg *config.ModelConfig) {
+	if s.replaying {
+		return
+	}
+	s.Model = modelCfg.Name
+	s.Collection = opts.Collection
+	s.Options = SessionOptions{
+		Scope:    opts.Scope,
+		Boost:    opts.Boost,
+		Language: opts.Language,
+		Brevity:  opts.Brevity,
+		DiffFrom: opts.DiffFrom,
+		DiffTo:   opts.DiffTo,
+	}
+}
+
+// provider returns the provider of a model: recorded, or answering from the
+// recording when replaying
+func (s *Session) provider(cfg *config.Config, modelCfg *config.ModelConfig) (provider.Provider, error) {
+	if s.replaying {
+		return provider.NewReplayed(string(modelCfg.Provider), func(req *provider.ChatRequest) (provider.Exchange, error) {
+			return s.nextExchange(modelCfg.Name, req)
+		}), nil
+	}
+
+	p, err := NewProvider(cfg, modelCfg)
+	if err != nil {
+		return nil, err
+	}
+	return provider.NewRecorded(p, modelCfg.Name, func(e provider.Exchange) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.Exchanges = append(s.Exchanges, e)
+	}), nil
+}
+
+// tools records the registry's tool calls, or has them answered from the
+// recording when replaying
+func (s *Session) tools(tools *tool.Registry) {
+	if s.replaying {
+		tools.SetReplay(s.ToolCalls)
+		return
+	}
+	tools.SetRecorder(func(c tool.Call) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.ToolCalls = append(s.ToolCalls, c)
+	})
+}
+
+// nextExchange returns the next recorded exchange for a request to model,
+// noting when the request differs from the recorded one
+func (s *Session) nextExchange(model string, req *provider.ChatRequest) (provider.Exchange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next >= len(s.Exchanges) {
+		return provider.Exchange{}, fmt.Errorf("replay made more model requests than the %d recorded", len(s.Exchanges))
+	}
+	e := s.Exchanges[s.next]
+	s.next++
+	if e.Model != model {
+		return provider.Exchange{}, fmt.Errorf("replay request %d went to %s, but to %s in the recording", s.next, model, e.Model)
+	}
+	if e.Response == nil && e.Error == "" {
+		return provider.Exchange{}, fmt.Errorf("recorded request %d has no response", s.next)
+	}
+	if !sameRequest(e.Request, req) {
+		s.diverged = append(s.diverged, s.next)
+	}
+	return e, nil
+}
+
+// sameRequest reports whether two requests are the same once encoded
+func sameRequest(a, b *provider.ChatRequest) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
+
+// newProvider creates the provider of another model the agent uses, e.g.
+// for triage or verification, recorded along with the agent's own
+func (a *Agent) newProvider(modelCfg *config.ModelConfig) (provider.Provider, error) {
+	if a.session != nil {
+		return a.session.provider(a.Config, modelCfg)
+	}
+	return NewProvider(a.Config, modelCfg)
+}
@@ -0,0 +1,185 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/resource"
+)
+
+// fakeCompletions serves OpenAI chat completions: a grep call first, then an
+// answer
+func fakeCompletions(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		message := map[string]interface{}{"role": "assistant", "content": "Flags are registered with AddFlag in flags.go."}
+		finish := "stop"
+		if calls.Add(1) == 1 {
+			message = map[string]interface{}{
+				"role":    "assistant",
+				"content": "",
+				"tool_calls": []map[string]interface{}{{
+					"id":       "call_1",
+					"type":     "function",
+					"function": map[string]string{"name": "grep", "arguments": `{"pattern":"AddFlag"}`},
+				}},
+			}
+			finish = "tool_calls"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      "chatcmpl-1",
+			"object":  "chat.completion",
+			"created": 1,
+			"model":   "fake",
+			"choices": []map[string]interface{}{{"index": 0, "message": message, "finish_reason": finish}},
+			"usage":   map[string]int{"prompt_tokens": 100, "completion_tokens": 10, "total_tokens": 110},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// testCollection returns a collection of one resource with a single file
+func testCollection(t *testing.T) *resource.Collection {
+	t.Helper()
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "flags.go"), []byte("package flags\n\nfunc AddFlag(name string) {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "collection")
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(repo, filepath.Join(path, "repo")); err != nil {
+		t.Fatal(err)
+	}
+	return &resource.Collection{
+		Name:      "repo",
+		Path:      path,
+		Resources: []resource.CollectionResource{{Name: "repo", Path: repo}},
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server, calls := fakeCompletions(t)
+	cfg := config.Defaults()
+	modelCfg := &config.ModelConfig{
+		Name:     "fake",
+		Provider: config.ProviderOpenAICompatible,
+		Model:    "fake",
+		BaseURL:  server.URL,
+		APIKey:   "test",
+	}
+	collection := testCollection(t)
+	question := "How are flags registered?"
+
+	// Record the question against the fake server
+	session := NewSession(question)
+	a, err := New(Options{Config: &cfg, ModelConfig: modelCfg, Collection: collection, DataDir: t.TempDir(), Session: session})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := a.Ask(context.Background(), question)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Answer = resp.Content
+	if len(session.Exchanges) != 2 || len(session.ToolCalls) != 1 {
+		t.Fatalf("recorded %d exchanges and %d tool calls, want 2 and 1", len(session.Exchanges), len(session.ToolCalls))
+	}
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := session.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay it with the server gone and the resource's file changed, so
+	// both answers have to come from the recording
+	server.Close()
+	if err := os.WriteFile(filepath.Join(collection.Resources[0].Path, "flags.go"), []byte("package flags\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	recorded := calls.Load()
+
+	replay, err := LoadSession(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err = New(Options{Config: &cfg, ModelConfig: modelCfg, Collection: replay.Collection, DataDir: t.TempDir(), Session: replay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = a.Ask(context.Background(), replay.Question)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls.Load() != recorded {
+		t.Error("the replay contacted the model")
+	}
+	if resp.Content != replay.Answer {
+		t.Errorf("replayed answer = %q, want %q", resp.Content, replay.Answer)
+	}
+	if n := replay.Replayed(); n != 2 {
+		t.Errorf("replayed %d requests, want 2", n)
+	}
+	if diverged := replay.Diverged(); len(diverged) > 0 {
+		t.Errorf("requests %v differ from the recording", diverged)
+	}
+}
+
+func TestReplayDiverged(t *testing.T) {
+	server, _ := fakeCompletions(t)
+	cfg := config.Defaults()
+	modelCfg := &config.ModelConfig{
+		Name:     "fake",
+		Provider: config.ProviderOpenAICompatible,
+		Model:    "fake",
+		BaseURL:  server.URL,
+		APIKey:   "test",
+	}
+	question := "How are flags registered?"
+
+	session := NewSession(question)
+	a, err := New(Options{Config: &cfg, ModelConfig: modelCfg, Collection: testCollection(t), DataDir: t.TempDir(), Session: session})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Ask(context.Background(), question); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := session.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// A changed setting changes the prompt of every request
+	replay, err := LoadSession(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := cfg
+	changed.Output.Brevity = config.BrevityShort
+	a, err = New(Options{Config: &changed, ModelConfig: modelCfg, Collection: replay.Collection, DataDir: t.TempDir(), Session: replay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Ask(context.Background(), replay.Question); err != nil {
+		t.Fatal(err)
+	}
+	if diverged := replay.Diverged(); len(diverged) != 2 {
+		t.Errorf("diverged requests = %v, want [1 2]", diverged)
+	}
+}
@@ -0,0 +1,30 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/nickcecere/btcx/internal/resource"
+	"github.com/nickcecere/btcx/internal/storage"
+)
+
+// snapshotResources records the version of each resource in the collection
+// for a new thread: the git commit, or the latest file modification time
+// outside git
+func (a *Agent) snapshotResources(ctx context.Context) []storage.ResourceSnapshot {
+	snapshots := make([]storage.ResourceSnapshot, 0, len(a.Collection.Resources))
+	for _, r := range a.Collection.Resources {
+		snapshot := storage.ResourceSnapshot{Name: r.Name, Commit: resource.Version(r.Path)}
+		if snapshot.Commit == "" {
+			modified, err := resource.LastModified(ctx, r.Path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to snapshot %s: %v\n", r.Name, err)
+			} else if !modified.IsZero() {
+				snapshot.Modified = &modified
+			}
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/provider"
+)
+
+// maxBroadenOutput limits how much of a broadened search is shown to the model
+const maxBroadenOutput = 4000
+
+// identifierPattern matches identifier-like words in a search pattern
+var identifierPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]{2,}`)
+
+// loopConfig returns the loop-detection settings for the active model
+func (a *Agent) loopConfig() config.LoopConfig {
+	loop := config.DefaultLoopConfig()
+	if a.Config != nil {
+		loop = loop.Merge(&a.Config.Loop)
+	}
+	if a.ModelConfig != nil {
+		loop = loop.Merge(a.ModelConfig.Loop)
+	}
+	return loop
+}
+
+// hasStep reports whether the strategy includes step
+func hasStep(loop config.LoopConfig, step config.LoopStep) bool {
+	for _, s := range loop.Strategy {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+// applyStep runs a stuck-loop escalation step, extending the guidance
+// sent to the model as a reminder
+func (a *Agent) applyStep(ctx context.Context, step config.LoopStep, loop config.LoopConfig, state *loopState) {
+	switch step {
+	case config.LoopStepHint:
+		if loop.Hint != "" {
+			state.guidance += "\n\n" + loop.Hint
+		} else {
+			state.guidance += a.stuckHint(state)
+		}
+
+	case config.LoopStepBroaden:
+		state.guidance += a.broadenSearch(ctx, state.lastEmpty)
+
+	case config.LoopStepAnswer:
+		state.answerNow = true
+		state.guidance += GeneralAnswerHint()
+	}
+}
+
+// broadenSearch reruns an empty grep/glob call with a relaxed pattern and
+// describes the outcome for the reminder
+func (a *Agent) broadenSearch(ctx context.Context, tc *provider.ToolCall) string {
+	if tc == nil {
+		return ""
+	}
+
+	args, ok := broadenArgs(tc.Name, tc.Arguments)
+	if !ok {
+		return ""
+	}
+
+	result, err := a.Tools.Execute(ctx, tc.Name, args)
+
+	var b strings.Builder
+	b.WriteString("\n\n## Automatic Broadened Search\n\n")
+	b.WriteString(fmt.Sprintf("`%s %s` found nothing, so it was retried as `%s %s`.\n\n", tc.Name, tc.Arguments, tc.Name, args))
+
+	if err != nil || isEmptyResult(result.Output) {
+		b.WriteString("The broadened search also found nothing; the topic may not exist in these resources under that name.\n")
+		return b.String()
+	}
+
+	output := result.Output
+	if len(output) > maxBroadenOutput {
+		output = output[:maxBroadenOutput] + "\n..."
+	}
+	b.WriteString("Results:\n\n")
+	b.WriteString(output)
+	b.WriteString("\n\nUse these results to choose your next searches.\n")
+	return b.String()
+}
+
+// broadenArgs relaxes the arguments of a grep or glob call
+// grep becomes a case-insensitive search for the longest identifier in the
+// pattern across the whole collection; glob matches the file stem anywhere
+func broadenArgs(name string, raw json.RawMessage) (json.RawMessage, bool) {
+	var args struct {
+		Pattern string `json:"pattern"`
+		Path    string `json:"path,omitempty"`
+		Include string `json:"include,omitempty"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil || args.Pattern == "" {
+		return nil, false
+	}
+
+	var broadened string
+	switch name {
+	case "grep":
+		var longest string
+		for _, word := range identifierPattern.FindAllString(args.Pattern, -1) {
+			if len(word) > len(longest) {
+				longest = word
+			}
+		}
+		if longest == "" {
+			return nil, false
+		}
+		broadened = "(?i)" + longest
+
+	case "glob":
+		base := path.Base(args.Pattern)
+		stem := strings.TrimSuffix(base, path.Ext(base))
+		stem = strings.Trim(stem, "*")
+		if stem == "" || strings.ContainsAny(stem, "*?[]{}") {
+			return nil, false
+		}
+		broadened = "**/*" + stem + "*"
+
+	default:
+		return nil, false
+	}
+
+	if broadened == args.Pattern && args.Path == "" && args.Include == "" {
+		return nil, false
+	}
+
+	out, err := json.Marshal(map[string]string{"pattern": broadened})
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
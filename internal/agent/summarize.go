@@ -0,0 +1,241 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/summary"
+	"github.com/nickcecere/btcx/internal/tool"
+)
+
+const (
+	// maxReadmeBytes caps how much of the README is sent to the model
+	maxReadmeBytes = 16 * 1024
+
+	// maxManifestBytes caps each package manifest sent to the model
+	maxManifestBytes = 6 * 1024
+
+	// maxTreeEntries caps the entries listed per directory in the tree
+	maxTreeEntries = 30
+)
+
+const summarizePrompt = `You write architecture overviews of code repositories for developers who are new to them.
+You are given the README, package manifests and directory layout of one repository.
+
+Write a Markdown overview with exactly these sections:
+## Overview - what the project is and who uses it, in 2-3 sentences
+## Architecture - the main components and how they fit together
+## Key Directories - a bullet list of important directories and what lives in them
+## Entry Points - where execution or the public API starts (files, exports, commands)
+## Core Concepts - the handful of ideas or types a user must understand
+## Where to Look - which paths to search for common kinds of questions
+
+Use real paths from the layout. Keep it under 800 words. Don't invent components that
+aren't visible in the material; say what is unclear instead.`
+
+// summaryManifests are package manifests that describe a project, in the
+// order they are offered to the model
+var summaryManifests = []string{
+	"package.json", "go.mod", "Cargo.toml", "pyproject.toml", "setup.py", "setup.cfg",
+	"pom.xml", "build.gradle", "build.gradle.kts", "composer.json", "Gemfile", "mix.exs",
+	"deno.json", "pnpm-workspace.yaml", "CMakeLists.txt", "Package.swift", "pubspec.yaml",
+}
+
+// summaryDocs are top-level documents worth including besides the README
+var summaryDocs = []string{"ARCHITECTURE.md", "DESIGN.md", "docs/ARCHITECTURE.md", "docs/architecture.md"}
+
+// skipDirs are directories left out of the layout
+var skipDirs = map[string]bool{
+	"node_modules": true, "vendor": true, "dist": true, "build": true, "target": true, "__pycache__": true,
+}
+
+// Summarize generates an architecture overview of a resource and stores it
+// The overview is built from the README, package manifests and directory
+// layout in a single prompt, without the search loop
+func (a *Agent) Summarize(ctx context.Context, name string, callback StreamCallback) (*summary.Summary, *Response, error) {
+	var root string
+	for _, r := range a.Collection.Resources {
+		if r.Name == name {
+			root = r.Path
+			break
+		}
+	}
+	if root == "" {
+		return nil, nil, fmt.Errorf("resource %q is not in the collection", name)
+	}
+
+	material, err := summaryContext(name, root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req := &provider.ChatRequest{
+		Model:  a.ModelConfig.Model,
+		System: summarizePrompt,
+		Messages: []provider.Message{
+			{Role: "user", Content: material},
+		},
+		MaxTokens: 4096,
+	}
+
+	var resp *provider.ChatResponse
+	if callback != nil && a.ModelConfig.SupportsStreaming() {
+		resp, err = a.streamChat(ctx, req, callback)
+	} else {
+		resp, err = a.Provider.Chat(ctx, req)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("chat request failed: %w", err)
+	}
+	if strings.TrimSpace(resp.Content) == "" {
+		return nil, nil, fmt.Errorf("the model returned an empty overview")
+	}
+
+	s := &summary.Summary{
+		Resource: name,
+		Version:  a.Collection.Versions(nil)[name],
+		Model:    a.ModelConfig.Name,
+		Created:  time.Now(),
+		Content:  resp.Content,
+	}
+	if err := a.Summaries.Save(s); err != nil {
+		return nil, nil, err
+	}
+
+	return s, &Response{Content: resp.Content, Usage: resp.Usage}, nil
+}
+
+// summaryContext gathers the README, manifests and layout of a resource
+func summaryContext(name, root string) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Repository: %s\n", name))
+
+	if readme := findReadme(root); readme != "" {
+		if content := readCapped(filepath.Join(root, readme), maxReadmeBytes); content != "" {
+			sb.WriteString(fmt.Sprintf("\n## %s\n\n%s\n", readme, content))
+		}
+	}
+
+	for _, doc := range summaryDocs {
+		if content := readCapped(filepath.Join(root, filepath.FromSlash(doc)), maxReadmeBytes/2); content != "" {
+			sb.WriteString(fmt.Sprintf("\n## %s\n\n%s\n", doc, content))
+		}
+	}
+
+	for _, manifest := range summaryManifests {
+		if content := readCapped(filepath.Join(root, manifest), maxManifestBytes); content != "" {
+			sb.WriteString(fmt.Sprintf("\n## %s\n\n```\n%s\n```\n", manifest, content))
+		}
+	}
+
+	tree, err := layout(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to list %s: %w", name, err)
+	}
+	sb.WriteString("\n## Layout\n\n```\n" + tree + "```\n")
+
+	return sb.String(), nil
+}
+
+// findReadme returns the name of the top-level README, or ""
+func findReadme(root string) string {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		base := strings.TrimSuffix(strings.ToLower(e.Name()), filepath.Ext(e.Name()))
+		if !e.IsDir() && base == "readme" {
+			return e.Name()
+		}
+	}
+	return ""
+}
+
+// readCapped reads up to max bytes of a text file, or "" if it is missing
+// or binary
+func readCapped(path string, max int) string {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return ""
+	}
+	if binary, _ := tool.IsBinaryContent(path); binary {
+		return ""
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	content := strings.TrimSpace(string(data))
+	if len(content) > max {
+		content = content[:max] + "\n[... truncated]"
+	}
+	return content
+}
+
+// layout lists the top two levels of a directory
+// Hidden and generated directories are skipped, and long directories are cut
+func layout(root string) (string, error) {
+	entries, err := visibleEntries(root)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for i, e := range entries {
+		if i == maxTreeEntries {
+			sb.WriteString(fmt.Sprintf("... (%d more)\n", len(entries)-i))
+			break
+		}
+		if !e.IsDir() {
+			sb.WriteString(e.Name() + "\n")
+			continue
+		}
+
+		sb.WriteString(e.Name() + "/\n")
+		children, err := visibleEntries(filepath.Join(root, e.Name()))
+		if err != nil {
+			continue
+		}
+		for j, c := range children {
+			if j == maxTreeEntries {
+				sb.WriteString(fmt.Sprintf("  ... (%d more)\n", len(children)-j))
+				break
+			}
+			suffix := ""
+			if c.IsDir() {
+				suffix = "/"
+			}
+			sb.WriteString("  " + c.Name() + suffix + "\n")
+		}
+	}
+	return sb.String(), nil
+}
+
+// visibleEntries lists a directory without hidden or generated entries,
+// directories first
+func visibleEntries(dir string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var visible []os.DirEntry
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") || (e.IsDir() && skipDirs[e.Name()]) {
+			continue
+		}
+		visible = append(visible, e)
+	}
+	sort.SliceStable(visible, func(i, j int) bool {
+		return visible[i].IsDir() && !visible[j].IsDir()
+	})
+	return visible, nil
+}
@@ -0,0 +1,176 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Prompt template files in the prompts directory (~/.config/btcx/prompts)
+const (
+	// SystemTemplateFile overrides the base system prompt
+	SystemTemplateFile = "system.tmpl"
+	// StuckTemplateFile overrides the hint added when searches keep failing
+	StuckTemplateFile = "stuck.tmpl"
+	// ForceCompletionTemplateFile overrides the answer given when the loop is
+	// cut short before the model wrote anything
+	ForceCompletionTemplateFile = "force-completion.tmpl"
+)
+
+// PromptTemplates holds the user's prompt overrides
+// A nil template keeps the built-in prompt
+type PromptTemplates struct {
+	System          *template.Template
+	Stuck           *template.Template
+	ForceCompletion *template.Template
+}
+
+// SystemPromptData is passed to system.tmpl
+type SystemPromptData struct {
+	// Resources are the resources being searched
+	Resources []PromptResource
+
+	// Default is the built-in system prompt
+	Default string
+}
+
+// PromptResource describes a resource in system.tmpl
+type PromptResource struct {
+	// Name is the resource name
+	Name string
+
+	// Directory is the resource's directory relative to the search root
+	Directory string
+
+	// Notes are the resource's notes from the config
+	Notes string
+
+	// Guide is the content of the resource's prompt file
+	Guide string
+
+	// Map is the resource's directory map (empty with tools.directoryMap off)
+	Map string
+}
+
+// StuckPromptData is passed to stuck.tmpl
+type StuckPromptData struct {
+	// EmptyResults is the number of consecutive tool rounds without results
+	EmptyResults int
+
+	// Searches is the number of searches made so far
+	Searches int
+
+	// LastEmpty is the last grep or glob call that found nothing, e.g.
+	// `grep {"pattern":"useFoo"}` ("" if none)
+	LastEmpty string
+
+	// Default is the built-in hint
+	Default string
+}
+
+// ForceCompletionData is passed to force-completion.tmpl
+type ForceCompletionData struct {
+	// Question is the question being answered
+	Question string
+
+	// Results are excerpts of up to three useful tool results
+	Results []string
+
+	// Searches is the number of tool calls made
+	Searches int
+
+	// Default is the built-in answer
+	Default string
+}
+
+// LoadPromptTemplates parses the prompt templates in dir
+// Missing files (or a missing directory) keep the built-in prompts
+func LoadPromptTemplates(dir string) (*PromptTemplates, error) {
+	var templates PromptTemplates
+	for file, tmpl := range map[string]struct {
+		dst  **template.Template
+		data any
+	}{
+		SystemTemplateFile:          {&templates.System, SystemPromptData{}},
+		StuckTemplateFile:           {&templates.Stuck, StuckPromptData{}},
+		ForceCompletionTemplateFile: {&templates.ForceCompletion, ForceCompletionData{}},
+	} {
+		path := filepath.Join(dir, file)
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt template: %w", err)
+		}
+
+		t, err := template.New(file).Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse prompt template %s: %w", path, err)
+		}
+		// Catch unknown variables now rather than on every request
+		if err := t.Execute(io.Discard, tmpl.data); err != nil {
+			return nil, fmt.Errorf("invalid prompt template %s: %w", path, err)
+		}
+		*tmpl.dst = t
+	}
+	return &templates, nil
+}
+
+// renderPrompt executes a prompt template, falling back to the built-in
+// prompt if there is no template or it fails
+func renderPrompt(t *template.Template, data any, fallback string) string {
+	if t == nil {
+		return fallback
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: prompt template %s failed: %v\n", t.Name(), err)
+		return fallback
+	}
+	return buf.String()
+}
+
+// systemPrompt returns the base system prompt, from system.tmpl if set
+func (a *Agent) systemPrompt() string {
+	prompt := SystemPrompt(a.Collection, a.guides, a.maps)
+	if a.templates == nil || a.templates.System == nil {
+		return prompt
+	}
+
+	data := SystemPromptData{Default: prompt}
+	for _, r := range a.Collection.Resources {
+		data.Resources = append(data.Resources, PromptResource{
+			Name:      r.Name,
+			Directory: "./" + r.Name,
+			Notes:     r.Notes,
+			Guide:     a.guides[r.Name],
+			Map:       a.maps[r.Name],
+		})
+	}
+	return renderPrompt(a.templates.System, data, prompt)
+}
+
+// stuckHint returns the stuck-loop hint, from stuck.tmpl if set
+func (a *Agent) stuckHint(state *loopState) string {
+	hint := StuckLoopHint()
+	if a.templates == nil || a.templates.Stuck == nil {
+		return hint
+	}
+
+	data := StuckPromptData{
+		EmptyResults: state.emptyResultCount,
+		Searches:     state.totalSearches,
+		Default:      hint,
+	}
+	if tc := state.lastEmpty; tc != nil {
+		data.LastEmpty = fmt.Sprintf("%s %s", tc.Name, tc.Arguments)
+	}
+	// Custom hints get the same spacing as the built-in one
+	return "\n\n" + strings.TrimSpace(renderPrompt(a.templates.Stuck, data, hint)) + "\n"
+}
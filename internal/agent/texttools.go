@@ -0,0 +1,414 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/tool"
+)
+
+// Tags around tool calls and results for models without function calling
+const (
+	toolCallOpen    = "<tool_call>"
+	toolCallClose   = "</tool_call>"
+	toolResultClose = "</tool_result>"
+)
+
+// ReAct-style lines for models without function calling
+const (
+	reactAction      = "ACTION:"
+	reactObservation = "OBSERVATION"
+)
+
+// actionLine matches an "ACTION: tool arguments" line; models often bold
+// or lowercase the keyword
+var actionLine = regexp.MustCompile(`(?im)^[ \t*_]*action[*_]*:[*_]*[ \t]*` + "`?" + `([A-Za-z_][\w-]*)` + "`?" + `[ \t]*(.*)$`)
+
+// finalAnswer matches the FINAL ANSWER: label of a ReAct answer
+var finalAnswer = regexp.MustCompile(`(?im)^[ \t*_]*final answer[*_]*:[*_]*`)
+
+// droppedOutput replaces tool outputs that don't fit the context window
+const droppedOutput = "[Output dropped to fit the model's context window; run the tool again if it is still needed]"
+
+// adaptRequest returns the request as the model can take it: with its
+// reasoning settings, within its context window, and with tools in the
+// prompt if it can't call functions
+func adaptRequest(req *provider.ChatRequest, model *config.ModelConfig) *provider.ChatRequest {
+	if effort, budget := model.ReasoningSettings(); effort != "" || budget > 0 {
+		reasoning := *req
+		reasoning.ReasoningEffort, reasoning.ThinkingBudget = effort, budget
+		req = &reasoning
+	}
+	if window := model.ContextWindow(); window > 0 {
+		req = fitContext(req, window)
+	}
+	if !model.SupportsTools() {
+		req = toTextTools(req, model.TextToolFormat())
+	}
+	return req
+}
+
+// fitContext drops the oldest tool outputs of a request until it fits a
+// context window of window tokens, leaving room for the response
+func fitContext(req *provider.ChatRequest, window int) *provider.ChatRequest {
+	fitted := *req
+	if fitted.MaxTokens > window/4 {
+		fitted.MaxTokens = window / 4
+	}
+	limit := (window - fitted.MaxTokens - fitted.ThinkingBudget) * charsPerToken
+
+	size := len(fitted.System) + len(fitted.Reminder)
+	if tools, err := json.Marshal(fitted.Tools); err == nil {
+		size += len(tools)
+	}
+	for _, m := range fitted.Messages {
+		size += len(m.Content)
+		for _, tc := range m.ToolCalls {
+			size += len(tc.Arguments)
+		}
+	}
+	if size <= limit {
+		return &fitted
+	}
+
+	fitted.Messages = append([]provider.Message(nil), req.Messages...)
+	for i := range fitted.Messages {
+		if size <= limit {
+			break
+		}
+		m := &fitted.Messages[i]
+		if m.Role != "tool" || m.Content == droppedOutput {
+			continue
+		}
+		size -= len(m.Content) - len(droppedOutput)
+		m.Content = droppedOutput
+		m.Images = nil
+	}
+	return &fitted
+}
+
+// toTextTools rewrites a request for a model without function calling: the
+// tools are described in the system prompt, and earlier calls and results
+// become plain text turns in the given format
+func toTextTools(req *provider.ChatRequest, format config.ToolFormat) *provider.ChatRequest {
+	converted := *req
+	converted.Tools = nil
+	converted.ToolChoice = nil
+	if len(req.Tools) > 0 {
+		if format == config.ToolFormatReAct {
+			converted.System += reactHint(req.Tools)
+		} else {
+			converted.System += textToolsHint(req.Tools)
+		}
+	}
+
+	if req.ToolChoice != nil {
+		var note string
+		switch req.ToolChoice.Mode {
+		case provider.ToolChoiceNone:
+			note = "Answer now, without any tool calls."
+		case provider.ToolChoiceRequired:
+			note = "Start with a tool call."
+		case provider.ToolChoiceTool:
+			note = fmt.Sprintf("Call the %s tool next.", req.ToolChoice.Name)
+		}
+		converted.Reminder = strings.TrimSpace(converted.Reminder + "\n\n" + note)
+	}
+
+	names := make(map[string]string)
+	converted.Messages = nil
+	results := false
+	for _, m := range req.Messages {
+		switch {
+		case m.Role == "assistant" && len(m.ToolCalls) > 0:
+			var sb strings.Builder
+			sb.WriteString(m.Content)
+			for _, tc := range m.ToolCalls {
+				names[tc.ID] = tc.Name
+				if format == config.ToolFormatReAct {
+					sb.WriteString(fmt.Sprintf("\n%s %s %s", reactAction, tc.Name, compactJSON(tc.Arguments)))
+					continue
+				}
+				call, _ := json.Marshal(map[string]any{"name": tc.Name, "arguments": tc.Arguments})
+				sb.WriteString("\n\n" + toolCallOpen + "\n" + string(call) + "\n" + toolCallClose)
+			}
+			converted.Messages = append(converted.Messages, provider.Message{
+				Role:    "assistant",
+				Content: strings.TrimSpace(sb.String()),
+			})
+			results = false
+		case m.Role == "tool":
+			var result string
+			if format == config.ToolFormatReAct {
+				result = fmt.Sprintf("%s (%s):\n%s", reactObservation, names[m.ToolCallID], m.Content)
+			} else {
+				result = fmt.Sprintf("<tool_result name=%q>\n%s\n%s", names[m.ToolCallID], m.Content, toolResultClose)
+			}
+			// Results of one turn go in one message
+			if n := len(converted.Messages); results && n > 0 {
+				converted.Messages[n-1].Content += "\n\n" + result
+				continue
+			}
+			converted.Messages = append(converted.Messages, provider.Message{Role: "user", Content: result})
+			results = true
+		default:
+			converted.Messages = append(converted.Messages, m)
+			results = false
+		}
+	}
+	return &converted
+}
+
+// compactJSON returns arguments on one line, as an ACTION line needs them
+func compactJSON(args json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, args); err != nil {
+		return "{}"
+	}
+	return buf.String()
+}
+
+// textToolsHint describes the tools for a model without function calling
+func textToolsHint(tools []provider.Tool) string {
+	var sb strings.Builder
+	sb.WriteString(`
+
+## Calling Tools
+
+You can't call functions directly. To use a tool, write a block like this, with the tool's name and its
+arguments as JSON, and nothing after it:
+
+<tool_call>
+{"name": "grep", "arguments": {"pattern": "func NewCommand"}}
+</tool_call>
+
+You can write several blocks to call several tools. Their results come back in the next message, in
+<tool_result> blocks. Once you have what you need, answer without any tool_call block.
+
+Available tools:
+`)
+	sb.WriteString(provider.ToolList(tools, nil))
+	return sb.String()
+}
+
+// reactHint describes the tools for a model without function calling that
+// writes ReAct-style ACTION lines
+func reactHint(tools []provider.Tool) string {
+	var sb strings.Builder
+	sb.WriteString(`
+
+## Calling Tools
+
+You can't call functions directly. To use a tool, write a line with ACTION:, the tool's name and its
+arguments as JSON on one line:
+
+ACTION: grep {"pattern": "func NewCommand"}
+
+When you only need a tool's main argument, you can write it plainly:
+
+ACTION: grep func NewCommand
+
+You can write a short THOUGHT: line first, and several ACTION lines to call several tools. Stop after your
+ACTION lines: their results come back in the next message as OBSERVATION blocks. Once you have what you need,
+write FINAL ANSWER: followed by your answer, without any ACTION line.
+
+Available tools:
+`)
+	sb.WriteString(provider.ToolList(tools, func(t provider.Tool) string {
+		if main := mainArgument(t); main != "" {
+			return fmt.Sprintf("%s (main argument: %s)", t.Name, main)
+		}
+		return t.Name
+	}))
+	return sb.String()
+}
+
+// mainArgument returns the parameter a plain ACTION argument fills: the
+// tool's first required parameter ("" if it has none)
+func mainArgument(t provider.Tool) string {
+	switch required := t.Parameters["required"].(type) {
+	case []string:
+		if len(required) > 0 {
+			return required[0]
+		}
+	case []any:
+		if len(required) > 0 {
+			name, _ := required[0].(string)
+			return name
+		}
+	}
+	return ""
+}
+
+// parseTextToolCalls moves the tool calls written in a reply of a model
+// without function calling to the response's tool calls
+// <tool_call> blocks are read whatever the model was told, since small
+// models drift into them; ACTION lines only for ReAct prompts
+// Calls are ignored when the request forbade them
+func parseTextToolCalls(resp *provider.ChatResponse, req *provider.ChatRequest, format config.ToolFormat) {
+	if strings.Contains(resp.Content, toolCallOpen) {
+		parseToolCallBlocks(resp, req.ToolChoice)
+		return
+	}
+	// Only ReAct prompts ask for ACTION lines; other answers may well
+	// contain one quoted from the resources
+	if format != config.ToolFormatReAct {
+		return
+	}
+	parseActionLines(resp, req)
+	if len(resp.ToolCalls) == 0 {
+		resp.Content = stripFinalAnswer(resp.Content)
+	}
+}
+
+// parseToolCallBlocks reads <tool_call> blocks of JSON
+func parseToolCallBlocks(resp *provider.ChatResponse, choice *provider.ToolChoice) {
+	content := resp.Content
+	start := strings.Index(content, toolCallOpen)
+	if start < 0 {
+		return
+	}
+	text := strings.TrimSpace(content[:start])
+	if choice != nil && choice.Mode == provider.ToolChoiceNone {
+		resp.Content = text
+		return
+	}
+
+	prefix := fmt.Sprintf("text_%d", time.Now().UnixNano())
+	rest := content[start:]
+	for {
+		i := strings.Index(rest, toolCallOpen)
+		if i < 0 {
+			break
+		}
+		rest = rest[i+len(toolCallOpen):]
+		body := rest
+		if j := strings.Index(rest, toolCallClose); j >= 0 {
+			body, rest = rest[:j], rest[j+len(toolCallClose):]
+		} else {
+			rest = ""
+		}
+
+		call, ok := parseTextToolCall(body)
+		if !ok {
+			continue
+		}
+		call.ID = fmt.Sprintf("%s_%d", prefix, len(resp.ToolCalls))
+		resp.ToolCalls = append(resp.ToolCalls, call)
+	}
+	resp.Content = text
+}
+
+// parseTextToolCall parses the JSON of one tool_call block
+func parseTextToolCall(body string) (provider.ToolCall, bool) {
+	body = strings.TrimSpace(body)
+	// Models often fence the JSON
+	body = strings.TrimPrefix(body, "```json")
+	body = strings.TrimPrefix(body, "```")
+	body = strings.TrimSpace(strings.TrimSuffix(body, "```"))
+
+	data := json.RawMessage(body)
+	if repaired, _, err := tool.RepairArguments(data); err == nil {
+		data = repaired
+	}
+	var call struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(data, &call); err != nil || call.Name == "" {
+		return provider.ToolCall{}, false
+	}
+	if len(call.Arguments) == 0 || string(call.Arguments) == "null" {
+		call.Arguments = json.RawMessage("{}")
+	}
+	return provider.ToolCall{Name: call.Name, Arguments: call.Arguments}, true
+}
+
+// parseActionLines reads ReAct-style "ACTION: tool arguments" lines
+// The arguments are JSON, which may run over several lines, or the plain
+// value of the tool's main argument; lines naming no known tool are left in
+// the text
+func parseActionLines(resp *provider.ChatResponse, req *provider.ChatRequest) {
+	tools := make(map[string]provider.Tool, len(req.Tools))
+	for _, t := range req.Tools {
+		tools[t.Name] = t
+	}
+
+	content := resp.Content
+	var matches [][]int
+	for _, m := range actionLine.FindAllStringSubmatchIndex(content, -1) {
+		if _, ok := tools[content[m[2]:m[3]]]; ok {
+			matches = append(matches, m)
+		}
+	}
+	if len(matches) == 0 {
+		return
+	}
+	text := strings.TrimSpace(content[:matches[0][0]])
+	if req.ToolChoice != nil && req.ToolChoice.Mode == provider.ToolChoiceNone {
+		resp.Content = text
+		return
+	}
+
+	prefix := fmt.Sprintf("text_%d", time.Now().UnixNano())
+	for i, m := range matches {
+		name := content[m[2]:m[3]]
+		end := len(content)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		args := actionArguments(strings.TrimSpace(content[m[4]:end]), tools[name])
+		resp.ToolCalls = append(resp.ToolCalls, provider.ToolCall{
+			ID:        fmt.Sprintf("%s_%d", prefix, len(resp.ToolCalls)),
+			Name:      name,
+			Arguments: args,
+		})
+	}
+	resp.Content = text
+}
+
+// actionArguments returns the arguments of an ACTION line as a JSON object
+func actionArguments(raw string, t provider.Tool) json.RawMessage {
+	raw = strings.TrimSpace(strings.Trim(raw, "`"))
+	if raw == "" {
+		return json.RawMessage("{}")
+	}
+	if strings.HasPrefix(raw, "{") {
+		// Take the first JSON value; anything after it is chatter
+		var obj json.RawMessage
+		if err := json.NewDecoder(strings.NewReader(raw)).Decode(&obj); err == nil {
+			return obj
+		}
+		if repaired, _, err := tool.RepairArguments(json.RawMessage(raw)); err == nil {
+			return repaired
+		}
+	}
+
+	// A plain value is the main argument, on the ACTION line only
+	value, _, _ := strings.Cut(raw, "\n")
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	main := mainArgument(t)
+	if main == "" {
+		return json.RawMessage("{}")
+	}
+	data, _ := json.Marshal(map[string]string{main: value})
+	return data
+}
+
+// stripFinalAnswer removes the FINAL ANSWER: label of a ReAct answer, and
+// any THOUGHT before it
+func stripFinalAnswer(content string) string {
+	if loc := finalAnswer.FindStringIndex(content); loc != nil {
+		return strings.TrimSpace(content[loc[1]:])
+	}
+	return content
+}
@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/provider"
+)
+
+// triageSynthesisHint tells the answering model the searches were run for it
+const triageSynthesisHint = `
+
+## Answering
+
+The tool calls above were made for you by a search assistant. Answer the question from their results;
+only search again if something essential is missing.`
+
+// triage is the small model that runs the searches for a model with
+// strategy: triage
+type triage struct {
+	provider provider.Provider
+	model    *config.ModelConfig
+}
+
+// triageModel returns the model that runs the searches for the active model
+// (nil if its strategy isn't triage)
+// The tool iterations are cheap enough for a small local model; the active
+// model only writes the answer from their results
+func (a *Agent) triageModel() (*triage, error) {
+	if a.ModelConfig.Strategy != config.ModelStrategyTriage {
+		return nil, nil
+	}
+
+	if a.triage == nil || a.triage.model.Name != a.ModelConfig.TriageModel {
+		cfg, err := a.Config.GetModelConfig(a.ModelConfig.TriageModel)
+		if err != nil {
+			return nil, err
+		}
+		p, err := a.newProvider(cfg)
+		if err != nil {
+			return nil, err
+		}
+		a.triage = &triage{provider: p, model: cfg}
+	}
+
+	// Images read during triage must suit both models
+	a.Tools.SetImages(a.ModelConfig.SupportsImages() && a.triage.model.SupportsImages())
+	return a.triage, nil
+}
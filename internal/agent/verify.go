@@ -0,0 +1,179 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/storage"
+)
+
+// DefaultVerifyMinScore is the lowest judge score still counted as confident
+const DefaultVerifyMinScore = 4
+
+const (
+	// maxEvidenceChars caps the tool output sent to the judge
+	maxEvidenceChars = 60000
+	// maxEvidenceResultChars caps a single tool result sent to the judge
+	maxEvidenceResultChars = 6000
+)
+
+const verifyPrompt = `You check answers to developer questions about source code.
+You are given the question, the answer, and the evidence: the tool calls the answering agent made and
+what they returned. Judge the answer only against the evidence.
+
+Reply with ONLY a JSON object, no explanations:
+{"correctness": 1-5, "grounding": 1-5, "caveats": ["..."]}
+
+- correctness: 5 if everything the answer claims is right according to the evidence, 1 if it is mostly wrong
+- grounding: 5 if every claim is backed by the evidence, 1 if the answer is mostly unsupported
+- caveats: short, specific warnings a reader should know (wrong or unsupported claims, missing cases,
+  APIs the evidence doesn't show); [] if there are none`
+
+// verify has the judge model score an answer against the evidence
+func (a *Agent) verify(ctx context.Context, question, answer string, evidence []storage.Message) (*storage.Verification, provider.Usage, error) {
+	p, modelCfg := a.Provider, a.ModelConfig
+	if name := a.Config.Verify.Model; name != "" && name != a.ModelConfig.Name {
+		cfg, err := a.Config.GetModelConfig(name)
+		if err != nil {
+			return nil, provider.Usage{}, err
+		}
+		p, err = a.newProvider(cfg)
+		if err != nil {
+			return nil, provider.Usage{}, err
+		}
+		modelCfg = cfg
+	}
+
+	resp, err := p.Chat(ctx, &provider.ChatRequest{
+		Model:  modelCfg.Model,
+		System: verifyPrompt,
+		Messages: []provider.Message{
+			{Role: "user", Content: fmt.Sprintf("Question: %s\n\n# Answer\n\n%s\n\n# Evidence\n\n%s", question, answer, formatEvidence(evidence))},
+		},
+		MaxTokens: 1024,
+	})
+	if err != nil {
+		return nil, provider.Usage{}, fmt.Errorf("verification failed: %w", err)
+	}
+
+	v, err := parseVerification(resp.Content)
+	if err != nil {
+		return nil, resp.Usage, err
+	}
+	v.Model = modelCfg.Name
+
+	minScore := a.Config.Verify.MinScore
+	if minScore <= 0 {
+		minScore = DefaultVerifyMinScore
+	}
+	v.Confident = v.Correctness >= minScore && v.Grounding >= minScore
+	return v, resp.Usage, nil
+}
+
+// parseVerification extracts the judge's scores from its reply
+func parseVerification(content string) (*storage.Verification, error) {
+	var reply struct {
+		Correctness int      `json:"correctness"`
+		Grounding   int      `json:"grounding"`
+		Caveats     []string `json:"caveats"`
+	}
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("verification reply has no JSON object")
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &reply); err != nil {
+		return nil, fmt.Errorf("failed to parse verification reply: %w", err)
+	}
+	if reply.Correctness < 1 || reply.Correctness > 5 || reply.Grounding < 1 || reply.Grounding > 5 {
+		return nil, fmt.Errorf("verification scores out of range (correctness %d, grounding %d)", reply.Correctness, reply.Grounding)
+	}
+
+	v := &storage.Verification{Correctness: reply.Correctness, Grounding: reply.Grounding}
+	for _, c := range reply.Caveats {
+		if c = strings.TrimSpace(c); c != "" {
+			v.Caveats = append(v.Caveats, c)
+		}
+	}
+	return v, nil
+}
+
+// formatEvidence lists the tool calls in messages with their results
+func formatEvidence(messages []storage.Message) string {
+	calls := make(map[string]storage.ToolCall)
+	var sb strings.Builder
+	for _, msg := range messages {
+		for _, tc := range msg.ToolCalls {
+			calls[tc.ID] = tc
+		}
+		if msg.Role != "tool" || len(msg.ToolResults) == 0 {
+			continue
+		}
+
+		result := msg.ToolResults[0]
+		output := result.Output
+		if result.Error != "" {
+			output = "Error: " + result.Error
+		}
+		if len(output) > maxEvidenceResultChars {
+			output = output[:maxEvidenceResultChars] + "\n... (truncated)"
+		}
+
+		tc := calls[msg.ToolCallID]
+		entry := fmt.Sprintf("## %s %s\n\n%s\n\n", tc.Name, string(tc.Arguments), output)
+		if sb.Len()+len(entry) > maxEvidenceChars {
+			sb.WriteString("... (more evidence omitted)\n")
+			break
+		}
+		sb.WriteString(entry)
+	}
+	if sb.Len() == 0 {
+		return "(the agent made no tool calls)"
+	}
+	return sb.String()
+}
+
+// verificationNote formats a verification to append to an answer
+func verificationNote(v *storage.Verification) string {
+	scores := fmt.Sprintf("correctness %d/5, grounding %d/5, checked by %s", v.Correctness, v.Grounding, v.Model)
+	if v.Confident {
+		return fmt.Sprintf("\n\n_[Verified: %s.]_", scores)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n\n**Verification:** low confidence (%s).", scores))
+	if len(v.Caveats) > 0 {
+		sb.WriteString(" Caveats:\n")
+		for _, c := range v.Caveats {
+			sb.WriteString("\n- " + c)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// appendVerification verifies a fresh answer and adds the result to the
+// response, its thread message and the stream
+// Failures are reported as warnings; the answer is kept as is
+func (a *Agent) appendVerification(ctx context.Context, question string, response *Response, evidence []storage.Message, callback StreamCallback) {
+	v, usage, err := a.verify(ctx, question, response.Content, evidence)
+	addUsage(&response.Usage, usage)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+
+	note := verificationNote(v)
+	response.Verification = v
+	response.Content += note
+	if n := len(a.Thread.Messages); n > 0 && a.Thread.Messages[n-1].Role == "assistant" {
+		a.Thread.Messages[n-1].Content += note
+		a.Thread.Messages[n-1].Verification = v
+	}
+	if callback != nil {
+		callback(provider.StreamEvent{Type: provider.StreamEventText, Delta: note})
+	}
+}
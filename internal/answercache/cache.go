@@ -0,0 +1,381 @@
+// Package answercache caches final answers for near-duplicate questions.
+//
+// Questions are normalized and embedded locally with feature hashing (no
+// provider calls), so lookups are free. An entry matches when the resource
+// set and every resource version (git commit or manifest digest) are
+// unchanged, or when every file the answer cited still has the same
+// content hash.
+package answercache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// DefaultThreshold is the minimum cosine similarity for a cache hit
+	DefaultThreshold = 0.92
+
+	// DefaultMaxEntries is the number of entries kept per resource set
+	DefaultMaxEntries = 200
+
+	// embeddingDims is the size of the hashed embedding vector
+	embeddingDims = 512
+)
+
+// Entry is a cached answer
+type Entry struct {
+	// Question is the original question
+	Question string `json:"question"`
+
+	// Vector is the normalized question embedding
+	Vector []float32 `json:"vector"`
+
+	// Answer is the final answer content
+	Answer string `json:"answer"`
+
+	// Versions maps resource name to its version when the answer was cached
+	Versions map[string]string `json:"versions"`
+	// Files maps each cited collection-relative path to its content hash
+	Files map[string]string `json:"files,omitempty"`
+
+	// Model is the model name that produced the answer
+	Model string `json:"model"`
+
+	// Created is when the answer was cached
+	Created time.Time `json:"created"`
+}
+
+// Hit is a successful cache lookup
+type Hit struct {
+	Entry
+
+	// Similarity is the cosine similarity to the cached question
+	Similarity float64
+}
+
+// State describes the current contents of a resource set
+type State struct {
+	// Versions maps resource name to its current version
+	Versions map[string]string
+	// Hash returns the current content hash of a collection-relative
+	// path, or "" if unknown (optional)
+	Hash func(path string) string
+}
+
+// valid reports whether an entry still reflects the current state
+func (s State) valid(e Entry) bool {
+	if sameVersions(e.Versions, s.Versions) {
+		return true
+	}
+	if len(e.Files) == 0 || s.Hash == nil || !sameResources(e.Versions, s.Versions) {
+		return false
+	}
+	for path, hash := range e.Files {
+		if s.Hash(path) != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// Cache stores answers on disk, one file per resource set
+type Cache struct {
+	dir        string
+	threshold  float64
+	maxEntries int
+	mu         sync.Mutex
+}
+
+// New creates a cache in dir
+// A threshold <= 0 uses DefaultThreshold
+func New(dir string, threshold float64) *Cache {
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+	return &Cache{
+		dir:        dir,
+		threshold:  threshold,
+		maxEntries: DefaultMaxEntries,
+	}
+}
+
+// Lookup returns the best cached answer for a near-duplicate question
+// that is still valid for the current state
+func (c *Cache) Lookup(question string, state State) (*Hit, bool) {
+	if !cacheable(state.Versions) {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	entries, err := c.load(state.Versions)
+	c.mu.Unlock()
+	if err != nil {
+		return nil, false
+	}
+
+	vec := Embed(question)
+
+	var best *Hit
+	for _, e := range entries {
+		if !state.valid(e) {
+			continue
+		}
+		sim := cosine(vec, e.Vector)
+		if sim >= c.threshold && (best == nil || sim > best.Similarity) {
+			best = &Hit{Entry: e, Similarity: sim}
+		}
+	}
+
+	return best, best != nil
+}
+
+// Store caches an answer for the question in the current state
+// cited lists the collection-relative paths the answer was based on
+func (c *Cache) Store(question, answer, model string, state State, cited []string) error {
+	versions := state.Versions
+	if !cacheable(versions) {
+		return nil
+	}
+
+	var files map[string]string
+	if state.Hash != nil {
+		for _, path := range cited {
+			hash := state.Hash(path)
+			if hash == "" {
+				// Can't track an unknown file, so fall back to versions only
+				files = nil
+				break
+			}
+			if files == nil {
+				files = make(map[string]string)
+			}
+			files[path] = hash
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := c.load(versions)
+	if err != nil {
+		entries = nil
+	}
+
+	// Drop entries that are no longer valid
+	current := entries[:0]
+	for _, e := range entries {
+		if state.valid(e) {
+			current = append(current, e)
+		}
+	}
+
+	current = append(current, Entry{
+		Question: question,
+		Vector:   Embed(question),
+		Answer:   answer,
+		Versions: versions,
+		Files:    files,
+		Model:    model,
+		Created:  time.Now(),
+	})
+
+	if len(current) > c.maxEntries {
+		current = current[len(current)-c.maxEntries:]
+	}
+
+	return c.save(versions, current)
+}
+
+// Clear removes all cached answers
+func (c *Cache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.RemoveAll(c.dir); err != nil {
+		return fmt.Errorf("failed to clear answer cache: %w", err)
+	}
+	return nil
+}
+
+// path returns the cache file for a resource set
+func (c *Cache) path(versions map[string]string) string {
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sum := sha256.Sum256([]byte(strings.Join(names, "+")))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// load reads the entries for a resource set
+func (c *Cache) load(versions map[string]string) ([]Entry, error) {
+	data, err := os.ReadFile(c.path(versions))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse answer cache: %w", err)
+	}
+	return entries, nil
+}
+
+// save writes the entries for a resource set
+func (c *Cache) save(versions map[string]string, entries []Entry) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create answer cache directory: %w", err)
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal answer cache: %w", err)
+	}
+
+	path := c.path(versions)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write answer cache: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// cacheable reports whether every resource has a known version
+func cacheable(versions map[string]string) bool {
+	if len(versions) == 0 {
+		return false
+	}
+	for _, v := range versions {
+		if v == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// sameVersions reports whether two version maps are identical
+func sameVersions(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// sameResources reports whether two version maps cover the same resources
+func sameResources(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// stopWords are dropped during normalization
+var stopWords = map[string]bool{
+	"a": true, "an": true, "the": true, "is": true, "are": true, "do": true,
+	"does": true, "i": true, "you": true, "me": true, "to": true, "of": true,
+	"in": true, "on": true, "for": true, "and": true, "or": true, "can": true,
+	"please": true, "what": true, "how": true, "it": true, "this": true,
+}
+
+// Normalize lowercases the question, strips punctuation and stop words
+// and returns the remaining tokens
+func Normalize(question string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '$'
+	})
+
+	tokens := fields[:0]
+	for _, f := range fields {
+		if stopWords[f] {
+			continue
+		}
+		// Crude plural stemming so "subcommands" matches "subcommand"
+		if len(f) > 3 && strings.HasSuffix(f, "s") && !strings.HasSuffix(f, "ss") {
+			f = f[:len(f)-1]
+		}
+		tokens = append(tokens, f)
+	}
+	return tokens
+}
+
+// Embed returns an L2-normalized feature-hashed embedding of the question
+// using unigrams and bigrams of the normalized tokens, plus character
+// trigrams of the joined tokens to tolerate spacing and spelling variants
+func Embed(question string) []float32 {
+	vec := make([]float64, embeddingDims)
+	tokens := Normalize(question)
+
+	add := func(feature string, weight float64) {
+		h := fnv.New32a()
+		h.Write([]byte(feature))
+		sum := h.Sum32()
+		sign := 1.0
+		if sum&1 == 1 {
+			sign = -1.0
+		}
+		vec[(sum>>1)%embeddingDims] += sign * weight
+	}
+
+	for i, tok := range tokens {
+		add(tok, 1.0)
+		if i > 0 {
+			add(tokens[i-1]+" "+tok, 0.5)
+		}
+	}
+
+	joined := []rune(strings.Join(tokens, ""))
+	for i := 0; i+3 <= len(joined); i++ {
+		add("#"+string(joined[i:i+3]), 0.3)
+	}
+
+	var norm float64
+	for _, v := range vec {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+
+	result := make([]float32, embeddingDims)
+	if norm == 0 {
+		return result
+	}
+	for i, v := range vec {
+		result[i] = float32(v / norm)
+	}
+	return result
+}
+
+// cosine returns the cosine similarity of two normalized vectors
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
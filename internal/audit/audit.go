@@ -0,0 +1,86 @@
+// Package audit writes an append-only JSONL log of agent tool calls.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry is a single tool invocation
+type Entry struct {
+	Time      time.Time       `json:"time"`
+	ThreadID  string          `json:"thread_id"`
+	User      string          `json:"user,omitempty"`
+	Tool      string          `json:"tool"`
+	Arguments json.RawMessage `json:"arguments"`
+	// DurationMs is how long the tool took to run
+	DurationMs int64 `json:"duration_ms"`
+	// ArgumentBytes is the size of the raw arguments
+	ArgumentBytes int `json:"argument_bytes"`
+	// OutputBytes is the size of the full tool output before truncation
+	OutputBytes int `json:"output_bytes"`
+	// ReturnedBytes is the size of the output returned to the model
+	ReturnedBytes int    `json:"returned_bytes"`
+	Truncated     bool   `json:"truncated,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Log appends entries to a JSONL file
+type Log struct {
+	path string
+	mu   sync.Mutex
+}
+
+// New creates a log that appends to path
+func New(path string) *Log {
+	return &Log{path: path}
+}
+
+// Path returns the log file path
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Write appends an entry to the log
+// Each entry is written with a single append so concurrent processes
+// don't interleave lines
+func (l *Log) Write(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if len(e.Arguments) == 0 {
+		e.Arguments = json.RawMessage("{}")
+	} else if !json.Valid(e.Arguments) {
+		// Keep malformed arguments as a string so the entry still encodes
+		quoted, _ := json.Marshal(string(e.Arguments))
+		e.Arguments = quoted
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
@@ -2,8 +2,12 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 )
@@ -15,6 +19,14 @@ const (
 	GlobalConfigFile = "config.yaml"
 	// ProjectConfigFile is the project-local config filename
 	ProjectConfigFile = "btcx.config.yaml"
+	// PromptsDir is the directory next to the global config holding prompt
+	// template overrides
+	PromptsDir = "prompts"
+	// LocalesDir is the directory next to the global config holding message
+	// catalogs (translations) that aren't built in
+	LocalesDir = "locales"
+	// LockFile is the default resource lockfile name
+	LockFile = "btcx.lock"
 	// DefaultCacheDir is the default cache directory
 	DefaultCacheDir = ".cache/btcx"
 	// DefaultDataDir is the default data directory for threads
@@ -27,8 +39,18 @@ type Paths struct {
 	ProjectConfig string
 	CacheDir      string
 	DataDir       string
+	PromptsDir    string
+	LocalesDir    string
 }
 
+// Memoized results of Load, so repeated calls within one process only
+// read and parse the config files once
+var (
+	loadMu     sync.Mutex
+	loadedCfg  *Config
+	loadedPath *Paths
+)
+
 // ResolvePaths resolves all paths based on the current environment
 func ResolvePaths() (*Paths, error) {
 	homeDir, err := os.UserHomeDir()
@@ -52,12 +74,43 @@ func ResolvePaths() (*Paths, error) {
 	if configPath := os.Getenv("BTCX_CONFIG"); configPath != "" {
 		paths.GlobalConfig = configPath
 	}
+	paths.PromptsDir = filepath.Join(filepath.Dir(paths.GlobalConfig), PromptsDir)
+	paths.LocalesDir = filepath.Join(filepath.Dir(paths.GlobalConfig), LocalesDir)
+	if dataDir := os.Getenv(EnvDataDir); dataDir != "" {
+		paths.DataDir = dataDir
+	}
 
 	return paths, nil
 }
 
 // Load loads and merges configuration from global and project config files
+// The result is cached for the lifetime of the process; Save resets it
 func Load() (*Config, *Paths, error) {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	if loadedCfg != nil {
+		return loadedCfg, loadedPath, nil
+	}
+
+	cfg, paths, err := load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	loadedCfg, loadedPath = cfg, paths
+	return cfg, paths, nil
+}
+
+// Reset clears the cached configuration so the next Load reads from disk
+func Reset() {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+	loadedCfg, loadedPath = nil, nil
+}
+
+// load reads and merges configuration without caching
+func load() (*Config, *Paths, error) {
 	paths, err := ResolvePaths()
 	if err != nil {
 		return nil, nil, err
@@ -70,11 +123,18 @@ func Load() (*Config, *Paths, error) {
 	if err := loadYAML(paths.GlobalConfig, &cfg); err != nil && !os.IsNotExist(err) {
 		return nil, nil, fmt.Errorf("failed to load global config: %w", err)
 	}
+	declaredIn(&cfg, paths.GlobalConfig)
 
 	// Load project config if it exists (overrides global)
 	if err := loadYAML(paths.ProjectConfig, &cfg); err != nil && !os.IsNotExist(err) {
 		return nil, nil, fmt.Errorf("failed to load project config: %w", err)
 	}
+	declaredIn(&cfg, paths.ProjectConfig)
+
+	// Environment variables override both files (and work without them)
+	if err := applyEnv(&cfg); err != nil {
+		return nil, nil, err
+	}
 
 	// Resolve cache path - keep original in Path, put resolved in ResolvedPath
 	if cfg.Cache.Path == "" {
@@ -109,17 +169,110 @@ func Load() (*Config, *Paths, error) {
 		cfg.Output.ResolvedOutputDir = resolved
 	}
 
+	// Resolve audit log path
+	if cfg.Audit.Path == "" {
+		cfg.Audit.ResolvedPath = filepath.Join(paths.DataDir, "audit.jsonl")
+	} else {
+		resolved := cfg.Audit.Path
+		if len(resolved) > 0 && resolved[0] == '~' {
+			homeDir, _ := os.UserHomeDir()
+			resolved = filepath.Join(homeDir, resolved[1:])
+		} else if !filepath.IsAbs(resolved) {
+			resolved = filepath.Join(paths.DataDir, resolved)
+		}
+		cfg.Audit.ResolvedPath = resolved
+	}
+
+	// Resolve resource lockfile path
+	if cfg.LockFile == "" {
+		cfg.ResolvedLockFile = filepath.Join(filepath.Dir(paths.ProjectConfig), LockFile)
+	} else {
+		resolved := cfg.LockFile
+		if len(resolved) > 0 && resolved[0] == '~' {
+			homeDir, _ := os.UserHomeDir()
+			resolved = filepath.Join(homeDir, resolved[1:])
+		} else if !filepath.IsAbs(resolved) {
+			// Relative paths are relative to current directory
+			cwd, _ := os.Getwd()
+			resolved = filepath.Join(cwd, resolved)
+		}
+		cfg.ResolvedLockFile = resolved
+	}
+
 	// Resolve API keys for all models
 	for i := range cfg.Models {
 		cfg.Models[i].APIKey = resolveModelAPIKey(&cfg.Models[i])
 	}
 
+	// Expand environment variables in custom tool environments
+	for i := range cfg.Tools.Custom {
+		for key, value := range cfg.Tools.Custom[i].Env {
+			cfg.Tools.Custom[i].Env[key] = os.ExpandEnv(value)
+		}
+	}
+
+	// Expand environment variables in model request headers
+	for i := range cfg.Models {
+		for key, value := range cfg.Models[i].Headers {
+			cfg.Models[i].Headers[key] = os.ExpandEnv(value)
+		}
+	}
+
+	// Resolve each model's proxy and CA bundle
+	cfg.HTTP = resolveHTTP(cfg.HTTP)
+	for i := range cfg.Models {
+		cfg.Models[i].ResolvedHTTP = resolveHTTP(cfg.HTTP.Merge(cfg.Models[i].HTTP))
+	}
+
+	// Expand environment variables in plugin environments
+	for i := range cfg.Models {
+		if p := cfg.Models[i].Plugin; p != nil {
+			for key, value := range p.Env {
+				p.Env[key] = os.ExpandEnv(value)
+			}
+		}
+	}
+
 	// Load API key for legacy config
 	cfg.APIKey = resolveAPIKey(cfg.Provider, cfg.APIKey)
 
+	// Resolve Slack credentials from environment
+	if cfg.Serve.Slack.SigningSecret == "" {
+		cfg.Serve.Slack.SigningSecret = os.Getenv("SLACK_SIGNING_SECRET")
+	}
+	if cfg.Serve.Slack.BotToken == "" {
+		cfg.Serve.Slack.BotToken = os.Getenv("SLACK_BOT_TOKEN")
+	}
+
+	// Expand environment variables in server API keys
+	for i := range cfg.Serve.APIKeys {
+		cfg.Serve.APIKeys[i].Key = os.ExpandEnv(cfg.Serve.APIKeys[i].Key)
+	}
+
+	// Expand environment variables in webhook headers (e.g. auth tokens)
+	for key, value := range cfg.Webhook.Headers {
+		cfg.Webhook.Headers[key] = os.ExpandEnv(value)
+	}
+
 	return &cfg, paths, nil
 }
 
+// declaredIn records the config file that declared the resources and
+// custom tools not yet attributed to one; a file setting either replaces
+// the whole list
+func declaredIn(cfg *Config, file string) {
+	for i := range cfg.Resources {
+		if cfg.Resources[i].configDir == "" {
+			cfg.Resources[i].configDir = filepath.Dir(file)
+		}
+	}
+	for i := range cfg.Tools.Custom {
+		if cfg.Tools.Custom[i].configDir == "" {
+			cfg.Tools.Custom[i].configDir = filepath.Dir(file)
+		}
+	}
+}
+
 // loadYAML loads a YAML file into the given struct
 func loadYAML(path string, v interface{}) error {
 	data, err := os.ReadFile(path)
@@ -134,6 +287,69 @@ func loadYAML(path string, v interface{}) error {
 	return nil
 }
 
+// localePattern matches locales like de, pt_BR, pt-BR and de_DE.UTF-8
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}([_-][A-Za-z0-9]+)?(\.[A-Za-z0-9-]+)?(@[A-Za-z0-9]+)?$`)
+
+// builtinTools are the names of the agent's built-in tools
+var builtinTools = map[string]bool{
+	"grep":            true,
+	"glob":            true,
+	"read":            true,
+	"skim":            true,
+	"list":            true,
+	"read_output":     true,
+	"semantic_search": true,
+	"gitdiff":         true,
+}
+
+// validToolName reports whether name is usable as a tool name by all providers
+func validToolName(name string) bool {
+	if name == "" || len(name) > 64 {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks that a post-processing step has what its type needs
+func (s *PostProcessStep) Validate() error {
+	switch s.Type {
+	case PostProcessRedact:
+		if s.Pattern == "" {
+			return fmt.Errorf("redact needs a pattern")
+		}
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	case PostProcessLinks:
+		if s.From == "" || s.To == "" {
+			return fmt.Errorf("links needs from and to")
+		}
+	case PostProcessBan:
+		if len(s.Phrases) == 0 {
+			return fmt.Errorf("ban needs phrases")
+		}
+	default:
+		return fmt.Errorf("unknown type %q (expected redact, links or ban)", s.Type)
+	}
+	return nil
+}
+
+// ParseBrevity parses an answer style ("" means normal)
+func ParseBrevity(s string) (Brevity, error) {
+	switch b := Brevity(strings.ToLower(strings.TrimSpace(s))); b {
+	case "", BrevityNormal:
+		return BrevityNormal, nil
+	case BrevityShort, BrevityDeep:
+		return b, nil
+	}
+	return BrevityNormal, fmt.Errorf("unknown brevity %q (expected short, normal or deep)", s)
+}
+
 // resolveModelAPIKey resolves the API key for a model config
 func resolveModelAPIKey(m *ModelConfig) string {
 	// Ollama doesn't require an API key
@@ -157,7 +373,18 @@ func resolveModelAPIKey(m *ModelConfig) string {
 			return key
 		}
 		return os.Getenv("OPENAI_API_KEY")
+	case ProviderAzureOpenAI:
+		return os.Getenv("AZURE_OPENAI_API_KEY")
+	case ProviderDeepSeek:
+		return os.Getenv("DEEPSEEK_API_KEY")
+	case ProviderLlamaCpp:
+		// Only needed when llama-server runs with --api-key
+		return os.Getenv("LLAMA_API_KEY")
 	case ProviderGoogle:
+		// Vertex AI authenticates with Google Cloud credentials
+		if m.Vertex != nil {
+			return ""
+		}
 		return os.Getenv("GOOGLE_API_KEY")
 	}
 
@@ -194,6 +421,8 @@ func resolveAPIKey(provider ProviderType, configKey string) string {
 }
 
 // Save saves the configuration to the global config file
+// Values set by BTCX_* environment variables aren't saved; the config
+// file's values are written in their place
 func Save(cfg *Config) error {
 	paths, err := ResolvePaths()
 	if err != nil {
@@ -206,7 +435,11 @@ func Save(cfg *Config) error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	data, err := yaml.Marshal(cfg)
+	out := *cfg
+	for _, restore := range cfg.envRestores {
+		restore(&out)
+	}
+	data, err := yaml.Marshal(&out)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
@@ -215,6 +448,7 @@ func Save(cfg *Config) error {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 
+	Reset()
 	return nil
 }
 
@@ -255,12 +489,25 @@ func (c *Config) GetModelConfig(name string) (*ModelConfig, error) {
 			Model:    c.Model,
 			BaseURL:  c.BaseURL,
 			APIKey:   c.APIKey,
+
+			ResolvedHTTP: c.HTTP,
 		}, nil
 	}
 
 	return nil, fmt.Errorf("no model configured; add models to config or set provider/model")
 }
 
+// resolveHTTP expands environment variables in the proxy URL and ~ in the
+// CA bundle path
+func resolveHTTP(h HTTPConfig) HTTPConfig {
+	h.Proxy = os.ExpandEnv(h.Proxy)
+	if strings.HasPrefix(h.CABundle, "~") {
+		homeDir, _ := os.UserHomeDir()
+		h.CABundle = filepath.Join(homeDir, h.CABundle[1:])
+	}
+	return h
+}
+
 // GetResource returns a resource by name
 func (c *Config) GetResource(name string) (*Resource, bool) {
 	for i := range c.Resources {
@@ -314,7 +561,7 @@ func (c *Config) Validate() error {
 
 		// Validate provider
 		switch m.Provider {
-		case ProviderAnthropic, ProviderOpenAI, ProviderOpenAICompatible, ProviderGoogle, ProviderOllama:
+		case ProviderAnthropic, ProviderOpenAI, ProviderOpenAICompatible, ProviderAzureOpenAI, ProviderDeepSeek, ProviderGoogle, ProviderOllama, ProviderLlamaCpp, ProviderPlugin:
 			// Valid
 		default:
 			return fmt.Errorf("model %q: invalid provider: %s", m.Name, m.Provider)
@@ -328,6 +575,129 @@ func (c *Config) Validate() error {
 		if m.Provider == ProviderOpenAICompatible && m.BaseURL == "" {
 			return fmt.Errorf("model %q: baseUrl is required for openai-compatible provider", m.Name)
 		}
+
+		// Validate azure-openai requires the resource endpoint
+		if m.Provider == ProviderAzureOpenAI && m.BaseURL == "" {
+			return fmt.Errorf("model %q: baseUrl (the resource endpoint) is required for azure-openai provider", m.Name)
+		}
+		if a := m.Azure; a != nil {
+			if m.Provider != ProviderAzureOpenAI {
+				return fmt.Errorf("model %q: azure options only apply to the azure-openai provider", m.Name)
+			}
+			switch a.Auth {
+			case "", AzureAuthKey, AzureAuthEntra:
+			default:
+				return fmt.Errorf("model %q: unknown azure.auth %q (expected key or entra)", m.Name, a.Auth)
+			}
+		}
+
+		// Validate pricing
+		if pr := m.Pricing; pr != nil && (pr.Input < 0 || pr.Output < 0 || pr.CacheRead < 0 || pr.CacheWrite < 0) {
+			return fmt.Errorf("model %q: pricing must not be negative", m.Name)
+		}
+
+		// Validate vertex options
+		if v := m.Vertex; v != nil {
+			if m.Provider != ProviderGoogle {
+				return fmt.Errorf("model %q: vertex options only apply to the google provider", m.Name)
+			}
+			if strings.ContainsAny(v.Project+v.Location, "/?#") {
+				return fmt.Errorf("model %q: invalid vertex.project or vertex.location", m.Name)
+			}
+		}
+
+		// Validate plugin requires a command
+		if m.Provider == ProviderPlugin && (m.Plugin == nil || m.Plugin.Command == "") {
+			return fmt.Errorf("model %q: plugin.command is required for plugin provider", m.Name)
+		}
+
+		// Validate ollama options
+		if o := m.Ollama; o != nil {
+			if m.Provider != ProviderOllama {
+				return fmt.Errorf("model %q: ollama options only apply to the ollama provider", m.Name)
+			}
+			if o.NumCtx < 0 {
+				return fmt.Errorf("model %q: ollama.numCtx must not be negative", m.Name)
+			}
+			if o.KeepAlive != "" {
+				if _, err := o.KeepAliveValue(); err != nil {
+					return fmt.Errorf("model %q: %w", m.Name, err)
+				}
+			}
+		}
+
+		// Validate llamacpp options
+		if l := m.LlamaCpp; l != nil {
+			if m.Provider != ProviderLlamaCpp {
+				return fmt.Errorf("model %q: llamacpp options only apply to the llamacpp provider", m.Name)
+			}
+			switch l.Endpoint {
+			case "", LlamaCppEndpointChat, LlamaCppEndpointCompletion:
+			default:
+				return fmt.Errorf("model %q: unknown llamacpp.endpoint %q (expected chat or completion)", m.Name, l.Endpoint)
+			}
+			switch l.ToolCalls {
+			case "", LlamaCppToolCallsNative, LlamaCppToolCallsGrammar:
+			default:
+				return fmt.Errorf("model %q: unknown llamacpp.toolCalls %q (expected native or grammar)", m.Name, l.ToolCalls)
+			}
+			if l.Endpoint == LlamaCppEndpointCompletion && l.ToolCalls == LlamaCppToolCallsNative {
+				return fmt.Errorf("model %q: llamacpp.toolCalls native needs the chat endpoint", m.Name)
+			}
+		}
+
+		if t := m.Timeouts; t != nil && (t.Connect < 0 || t.Total < 0 || t.StreamIdle < 0) {
+			return fmt.Errorf("model %q: timeouts must not be negative", m.Name)
+		}
+
+		if r := m.Reasoning; r != nil {
+			if _, ok := thinkingBudgets[r.Effort]; r.Effort != "" && !ok {
+				return fmt.Errorf("model %q: reasoning.effort must be low, medium or high, got %q", m.Name, r.Effort)
+			}
+			if r.BudgetTokens != 0 && r.BudgetTokens < MinThinkingBudget {
+				return fmt.Errorf("model %q: reasoning.budgetTokens must be at least %d", m.Name, MinThinkingBudget)
+			}
+		}
+
+		if r := m.RateLimit; r != nil && (r.RequestsPerMinute < 0 || r.TokensPerMinute < 0) {
+			return fmt.Errorf("model %q: rateLimit must not be negative", m.Name)
+		}
+
+		if m.Capabilities != nil && m.Capabilities.ContextWindow < 0 {
+			return fmt.Errorf("model %q: capabilities.contextWindow must not be negative", m.Name)
+		}
+		if m.Capabilities != nil && m.Capabilities.MaxOutputTokens < 0 {
+			return fmt.Errorf("model %q: capabilities.maxOutputTokens must not be negative", m.Name)
+		}
+		if m.Capabilities != nil {
+			switch m.Capabilities.ToolFormat {
+			case "", ToolFormatJSON, ToolFormatReAct:
+			default:
+				return fmt.Errorf("model %q: capabilities.toolFormat must be json or react, got %q", m.Name, m.Capabilities.ToolFormat)
+			}
+		}
+	}
+
+	// Validate triage pairs once all model names are known
+	for _, m := range c.Models {
+		switch m.Strategy {
+		case "":
+			if m.TriageModel != "" {
+				return fmt.Errorf("model %q: triageModel requires strategy: triage", m.Name)
+			}
+		case ModelStrategyTriage:
+			if m.TriageModel == "" {
+				return fmt.Errorf("model %q: triageModel is required for strategy: triage", m.Name)
+			}
+			if m.TriageModel == m.Name {
+				return fmt.Errorf("model %q: triageModel must be another model", m.Name)
+			}
+			if !seenModels[m.TriageModel] {
+				return fmt.Errorf("model %q: triageModel %q not found in models list", m.Name, m.TriageModel)
+			}
+		default:
+			return fmt.Errorf("model %q: invalid strategy: %s", m.Name, m.Strategy)
+		}
 	}
 
 	// Validate defaultModel references a valid model
@@ -358,6 +728,174 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Validate loop strategies
+	if err := validateLoop("loop", &c.Loop); err != nil {
+		return err
+	}
+	for _, m := range c.Models {
+		if err := validateLoop(fmt.Sprintf("model %q: loop", m.Name), m.Loop); err != nil {
+			return err
+		}
+	}
+
+	// Validate query expansion model
+	if name := c.QueryExpansion.Model; name != "" && !seenModels[name] {
+		return fmt.Errorf("queryExpansion.model %q not found in models list", name)
+	}
+
+	// Validate routing
+	if name := c.Routing.Model; name != "" && !seenModels[name] {
+		return fmt.Errorf("routing.model %q not found in models list", name)
+	}
+	if c.Routing.MaxResources < 0 {
+		return fmt.Errorf("routing.maxResources must not be negative")
+	}
+
+	// Validate ensemble judge
+	if name := c.Ensemble.Judge; name != "" && !seenModels[name] {
+		return fmt.Errorf("ensemble.judge %q not found in models list", name)
+	}
+
+	// Validate verification
+	if name := c.Verify.Model; name != "" && !seenModels[name] {
+		return fmt.Errorf("verify.model %q not found in models list", name)
+	}
+	if c.Verify.MinScore < 0 || c.Verify.MinScore > 5 {
+		return fmt.Errorf("verify.minScore must be between 1 and 5")
+	}
+
+	// Validate classification
+	if name := c.Classify.Model; name != "" && !seenModels[name] {
+		return fmt.Errorf("classify.model %q not found in models list", name)
+	}
+
+	// Validate proxies
+	if err := validateProxy(c.HTTP.Proxy); err != nil {
+		return fmt.Errorf("http.proxy: %w", err)
+	}
+	for _, m := range c.Models {
+		if m.HTTP != nil {
+			if err := validateProxy(os.ExpandEnv(m.HTTP.Proxy)); err != nil {
+				return fmt.Errorf("model %q: http.proxy: %w", m.Name, err)
+			}
+		}
+	}
+
+	// Validate budget
+	if c.Budget.MonthlyUSD < 0 {
+		return fmt.Errorf("budget.monthlyUSD must not be negative")
+	}
+
+	// Validate scheduler limits
+	if c.Scheduler.MaxConcurrent < 0 {
+		return fmt.Errorf("scheduler.maxConcurrent must not be negative")
+	}
+	for name, limit := range c.Scheduler.Providers {
+		switch ProviderType(name) {
+		case ProviderAnthropic, ProviderOpenAI, ProviderOpenAICompatible, ProviderAzureOpenAI, ProviderDeepSeek, ProviderGoogle, ProviderOllama, ProviderLlamaCpp, ProviderPlugin:
+		default:
+			if !seenModels[name] {
+				return fmt.Errorf("scheduler.providers: %q is neither a provider nor a model", name)
+			}
+		}
+		if limit < 0 {
+			return fmt.Errorf("scheduler.providers: limit for %q must not be negative", name)
+		}
+	}
+
+	// Validate answer style
+	if _, err := ParseBrevity(string(c.Output.Brevity)); err != nil {
+		return fmt.Errorf("output.brevity: %w", err)
+	}
+	if c.Output.Locale != "" && !localePattern.MatchString(c.Output.Locale) {
+		return fmt.Errorf("output.locale: %q is not a locale like en, de or pt_BR", c.Output.Locale)
+	}
+
+	// Validate answer post-processing
+	for i, step := range c.Output.PostProcess {
+		if err := step.Validate(); err != nil {
+			return fmt.Errorf("output.postProcess[%d]: %w", i, err)
+		}
+	}
+
+	// Validate thread digests
+	if name := c.Threads.SummaryModel; name != "" && !seenModels[name] {
+		return fmt.Errorf("threads.summaryModel %q not found in models list", name)
+	}
+
+	// Validate example checks
+	if c.Examples.Timeout < 0 {
+		return fmt.Errorf("examples.timeout must not be negative")
+	}
+
+	// Validate custom tools
+	customTools := make(map[string]bool)
+	for _, t := range c.Tools.Custom {
+		if !validToolName(t.Name) {
+			return fmt.Errorf("tools.custom: invalid tool name %q (use letters, digits, _ and -)", t.Name)
+		}
+		if builtinTools[t.Name] || customTools[t.Name] {
+			return fmt.Errorf("tools.custom: duplicate tool name: %s", t.Name)
+		}
+		customTools[t.Name] = true
+		if t.Command == "" {
+			return fmt.Errorf("tools.custom: %s: command is required", t.Name)
+		}
+		if t.Timeout < 0 {
+			return fmt.Errorf("tools.custom: %s: timeout must not be negative", t.Name)
+		}
+	}
+
+	// Validate response cache
+	if c.ResponseCache.TTL < 0 {
+		return fmt.Errorf("responseCache.ttl must not be negative")
+	}
+
+	// Validate search boost
+	switch c.Tools.Boost {
+	case "", BoostAuto, BoostOff, "docs", "code", "tests", "examples":
+	default:
+		return fmt.Errorf("tools.boost must be auto, off, docs, code, tests or examples, got %q", c.Tools.Boost)
+	}
+
+	// Validate grep format
+	switch c.Tools.GrepFormat {
+	case "", "default", "compact":
+	default:
+		return fmt.Errorf("tools.grepFormat must be default or compact, got %q", c.Tools.GrepFormat)
+	}
+
+	// Validate tool limits
+	for name := range c.Tools.PerTool {
+		if !builtinTools[name] && !customTools[name] {
+			return fmt.Errorf("tools.perTool: unknown tool: %s", name)
+		}
+	}
+
+	// Validate the server's public URL
+	if c.Serve.PublicURL != "" {
+		u, err := url.Parse(c.Serve.PublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("serve.publicURL must be an http or https URL, got %q", c.Serve.PublicURL)
+		}
+	}
+
+	// Validate server API keys
+	seenUsers := make(map[string]bool)
+	for _, k := range c.Serve.APIKeys {
+		if k.User == "" {
+			return fmt.Errorf("serve.apiKeys: user is required")
+		}
+		// Users starting with @ are reserved for integrations such as Slack
+		if strings.HasPrefix(k.User, "@") {
+			return fmt.Errorf("serve.apiKeys: user %s: names starting with @ are reserved", k.User)
+		}
+		if seenUsers[k.User] {
+			return fmt.Errorf("serve.apiKeys: duplicate user: %s", k.User)
+		}
+		seenUsers[k.User] = true
+	}
+
 	// Validate resources
 	seen := make(map[string]bool)
 	for _, r := range c.Resources {
@@ -381,7 +919,75 @@ func (c *Config) Validate() error {
 		default:
 			return fmt.Errorf("resource %q: invalid type: %s", r.Name, r.Type)
 		}
+
+		switch r.Fetch {
+		case "", ResourceFetchClone, ResourceFetchArchive:
+			// Valid
+		default:
+			return fmt.Errorf("resource %q: invalid fetch: %s (use clone or archive)", r.Name, r.Fetch)
+		}
+		if r.Fetch != "" && r.Type != ResourceTypeGit {
+			return fmt.Errorf("resource %q: fetch is only supported for git resources", r.Name)
+		}
+		if l := r.Links; l != nil && l.URL == LinksURLAuto && r.Type != ResourceTypeGit {
+			return fmt.Errorf("resource %q: links.url auto needs a git resource", r.Name)
+		}
+		switch r.Forge {
+		case "", ForgeGitHub, ForgeGitLab, ForgeBitbucket, ForgeGitea:
+			// Valid
+		default:
+			return fmt.Errorf("resource %q: invalid forge: %s (use github, gitlab, bitbucket or gitea)", r.Name, r.Forge)
+		}
+		autoLinks := r.Links != nil && r.Links.URL == LinksURLAuto
+		if r.Forge != "" && r.Fetch != ResourceFetchArchive && !autoLinks {
+			return fmt.Errorf("resource %q: forge is only used with fetch: archive or links.url: auto", r.Name)
+		}
+		if r.Token != "" && r.Fetch != ResourceFetchArchive {
+			return fmt.Errorf("resource %q: token is only used with fetch: archive", r.Name)
+		}
+
+		if path := r.PromptPath(); path != "" {
+			if _, err := os.Stat(path); err != nil {
+				return fmt.Errorf("resource %q: promptFile: %w", r.Name, err)
+			}
+		}
 	}
 
 	return nil
 }
+
+// validateLoop checks the strategy steps of a loop config
+func validateLoop(field string, l *LoopConfig) error {
+	if l == nil {
+		return nil
+	}
+	for _, step := range l.Strategy {
+		switch step {
+		case LoopStepHint, LoopStepBroaden, LoopStepAnswer:
+			// Valid
+		default:
+			return fmt.Errorf("%s: invalid strategy step: %s", field, step)
+		}
+	}
+	return nil
+}
+
+// validateProxy checks a proxy URL ("" for the environment's proxy)
+func validateProxy(proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+	u, err := url.Parse(os.ExpandEnv(proxy))
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q (expected http, https or socks5)", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("proxy URL %q has no host", proxy)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables that configure btcx without a config file, e.g. in
+// containers with secrets injected at runtime
+// List values are JSON arrays with the same field names as the YAML config
+// (JSON is valid YAML). Set values replace the config file's values
+const (
+	// EnvModels is a JSON array of model configs
+	EnvModels = "BTCX_MODELS"
+	// EnvDefaultModel is the default model name
+	EnvDefaultModel = "BTCX_DEFAULT_MODEL"
+	// EnvResources is a JSON array of resources
+	EnvResources = "BTCX_RESOURCES"
+	// EnvServeAddr is the address `btcx serve` listens on
+	EnvServeAddr = "BTCX_SERVE_ADDR"
+	// EnvServePublicURL is the URL clients reach `btcx serve` at
+	EnvServePublicURL = "BTCX_SERVE_PUBLIC_URL"
+	// EnvServeAPIKeys is a JSON array of {"user", "key"} objects for `btcx serve`
+	EnvServeAPIKeys = "BTCX_SERVE_API_KEYS"
+	// EnvCacheDir is the resource cache directory
+	EnvCacheDir = "BTCX_CACHE_DIR"
+	// EnvDataDir is the data directory for threads and outputs
+	EnvDataDir = "BTCX_DATA_DIR"
+	// EnvResponseCache turns the response cache on ("1", "true", "on") or off
+	EnvResponseCache = "BTCX_RESPONSE_CACHE"
+	// EnvRipgrep is the ripgrep executable searches run (see tools.ripgrep)
+	EnvRipgrep = "BTCX_RIPGREP"
+	// EnvCABundle is a PEM file of extra CAs for model requests (see http.caBundle)
+	EnvCABundle = "BTCX_CA_BUNDLE"
+	// EnvBudgetForce lets model requests through past budget.monthlyUSD ("1")
+	EnvBudgetForce = "BTCX_BUDGET_FORCE"
+	// EnvLocale is the locale of CLI messages (see output.locale)
+	EnvLocale = "BTCX_LOCALE"
+)
+
+// applyEnv overrides configuration from BTCX_* environment variables
+// The config file values each variable replaces are remembered for Save
+func applyEnv(cfg *Config) error {
+	if v := os.Getenv(EnvModels); v != "" {
+		var models []ModelConfig
+		if err := yaml.Unmarshal([]byte(v), &models); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", EnvModels, err)
+		}
+		// Keys can reference other secrets, e.g. "apiKey": "${OPENROUTER_KEY}"
+		for i := range models {
+			models[i].APIKey = os.ExpandEnv(models[i].APIKey)
+		}
+		file := cfg.Models
+		cfg.onSave(func(c *Config) { c.Models = file })
+		cfg.Models = models
+	}
+
+	if v := os.Getenv(EnvDefaultModel); v != "" {
+		file := cfg.DefaultModel
+		cfg.onSave(func(c *Config) { c.DefaultModel = file })
+		cfg.DefaultModel = v
+	}
+
+	if v := os.Getenv(EnvResources); v != "" {
+		var resources []Resource
+		if err := yaml.Unmarshal([]byte(v), &resources); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", EnvResources, err)
+		}
+		file := cfg.Resources
+		cfg.onSave(func(c *Config) { c.Resources = file })
+		cfg.Resources = resources
+	}
+
+	if v := os.Getenv(EnvServeAddr); v != "" {
+		file := cfg.Serve.Addr
+		cfg.onSave(func(c *Config) { c.Serve.Addr = file })
+		cfg.Serve.Addr = v
+	}
+
+	if v := os.Getenv(EnvServePublicURL); v != "" {
+		file := cfg.Serve.PublicURL
+		cfg.onSave(func(c *Config) { c.Serve.PublicURL = file })
+		cfg.Serve.PublicURL = v
+	}
+
+	if v := os.Getenv(EnvServeAPIKeys); v != "" {
+		var keys []APIKey
+		if err := yaml.Unmarshal([]byte(v), &keys); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", EnvServeAPIKeys, err)
+		}
+		file := cfg.Serve.APIKeys
+		cfg.onSave(func(c *Config) { c.Serve.APIKeys = file })
+		cfg.Serve.APIKeys = keys
+	}
+
+	if v := os.Getenv(EnvCacheDir); v != "" {
+		file := cfg.Cache.Path
+		cfg.onSave(func(c *Config) { c.Cache.Path = file })
+		cfg.Cache.Path = v
+	}
+
+	if v := os.Getenv(EnvRipgrep); v != "" {
+		file := cfg.Tools.Ripgrep
+		cfg.onSave(func(c *Config) { c.Tools.Ripgrep = file })
+		cfg.Tools.Ripgrep = v
+	}
+
+	if v := os.Getenv(EnvLocale); v != "" {
+		file := cfg.Output.Locale
+		cfg.onSave(func(c *Config) { c.Output.Locale = file })
+		cfg.Output.Locale = v
+	}
+
+	if v := os.Getenv(EnvCABundle); v != "" && cfg.HTTP.CABundle == "" {
+		cfg.onSave(func(c *Config) { c.HTTP.CABundle = "" })
+		cfg.HTTP.CABundle = v
+	}
+
+	switch strings.ToLower(os.Getenv(EnvBudgetForce)) {
+	case "1", "true", "on":
+		file := cfg.Budget.Force
+		cfg.onSave(func(c *Config) { c.Budget.Force = file })
+		cfg.Budget.Force = true
+	}
+
+	file := cfg.ResponseCache.Enabled
+	switch strings.ToLower(os.Getenv(EnvResponseCache)) {
+	case "1", "true", "on":
+		cfg.onSave(func(c *Config) { c.ResponseCache.Enabled = file })
+		cfg.ResponseCache.Enabled = true
+	case "0", "false", "off":
+		cfg.onSave(func(c *Config) { c.ResponseCache.Enabled = file })
+		cfg.ResponseCache.Enabled = false
+	}
+
+	return nil
+}
+
+// UseRipgrep sets the ripgrep executable searches run for this process,
+// e.g. from --shell, over tools.ripgrep and BTCX_RIPGREP; Save keeps the
+// config file's value
+func (c *Config) UseRipgrep(path string) {
+	file := c.Tools.Ripgrep
+	c.onSave(func(cfg *Config) { cfg.Tools.Ripgrep = file })
+	c.Tools.Ripgrep = path
+}
+
+// ForceBudget lets model requests through past budget.monthlyUSD for this
+// process, e.g. from --force; Save keeps the config file's value
+func (c *Config) ForceBudget() {
+	file := c.Budget.Force
+	c.onSave(func(cfg *Config) { cfg.Budget.Force = file })
+	c.Budget.Force = true
+}
+
+// onSave registers a function putting back a config file value an
+// environment variable replaced
+func (c *Config) onSave(restore func(cfg *Config)) {
+	c.envRestores = append(c.envRestores, restore)
+}
@@ -1,5 +1,14 @@
 package config
 
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // ProviderType represents the type of AI provider
 type ProviderType string
 
@@ -9,11 +18,62 @@ const (
 	ProviderOpenAICompatible ProviderType = "openai-compatible"
 	ProviderGoogle           ProviderType = "google"
 	ProviderOllama           ProviderType = "ollama"
+	ProviderPlugin           ProviderType = "plugin"
+	ProviderAzureOpenAI      ProviderType = "azure-openai"
+	ProviderDeepSeek         ProviderType = "deepseek"
+	ProviderLlamaCpp         ProviderType = "llamacpp"
+)
+
+// ModelStrategy is how a model runs an investigation
+type ModelStrategy string
+
+const (
+	// ModelStrategyTriage lets a small model run the searches and only asks
+	// the model itself for the final answer
+	ModelStrategyTriage ModelStrategy = "triage"
+)
+
+// ToolFormat is how a model without function calling writes tool calls
+type ToolFormat string
+
+const (
+	// ToolFormatJSON has the model write <tool_call> blocks of JSON
+	ToolFormatJSON ToolFormat = "json"
+	// ToolFormatReAct has the model write ReAct-style "ACTION: grep ..."
+	// lines, which small models follow more reliably
+	ToolFormatReAct ToolFormat = "react"
+)
+
+// Brevity is how long and detailed answers are
+type Brevity string
+
+const (
+	// BrevityShort asks for a direct answer of a paragraph or two
+	BrevityShort Brevity = "short"
+	// BrevityNormal is the default answer style
+	BrevityNormal Brevity = "normal"
+	// BrevityDeep asks for an exhaustive walkthrough
+	BrevityDeep Brevity = "deep"
+)
+
+// PostProcessType is the kind of an answer post-processing step
+type PostProcessType string
+
+const (
+	// PostProcessRedact replaces matches of a regular expression
+	PostProcessRedact PostProcessType = "redact"
+	// PostProcessLinks rewrites links starting with one prefix to another
+	PostProcessLinks PostProcessType = "links"
+	// PostProcessBan drops lines containing banned phrases
+	PostProcessBan PostProcessType = "ban"
 )
 
 // Default Ollama base URL
 const DefaultOllamaBaseURL = "http://localhost:11434/v1"
 
+// DefaultLlamaCppBaseURL is where llama.cpp's llama-server listens by default
+const DefaultLlamaCppBaseURL = "http://localhost:8080"
+
 // Config represents the main configuration for btcx
 type Config struct {
 	// DefaultModel is the name of the default model to use (from Models list)
@@ -36,6 +96,72 @@ type Config struct {
 
 	// Resources is the list of configured resources
 	Resources []Resource `yaml:"resources,omitempty"`
+
+	// LockFile pins git resources to the commits written by `btcx resources lock`
+	// Default: btcx.lock in the current directory
+	LockFile string `yaml:"lockFile,omitempty"`
+
+	// ResolvedLockFile is the absolute lockfile path
+	// This is not saved to the config file
+	ResolvedLockFile string `yaml:"-"`
+
+	// Webhook configures a completion webhook called after each ask
+	Webhook WebhookConfig `yaml:"webhook,omitempty"`
+
+	// Serve configures the HTTP server started by `btcx serve`
+	Serve ServeConfig `yaml:"serve,omitempty"`
+
+	// AnswerCache configures caching of answers to near-duplicate questions
+	AnswerCache AnswerCacheConfig `yaml:"answerCache,omitempty"`
+
+	// ResponseCache configures caching of identical model requests
+	ResponseCache ResponseCacheConfig `yaml:"responseCache,omitempty"`
+
+	// Loop tunes stuck-loop detection in the agent
+	Loop LoopConfig `yaml:"loop,omitempty"`
+
+	// Tools configures the agent's search tools
+	Tools ToolsConfig `yaml:"tools,omitempty"`
+
+	// Audit configures the tool-call audit log
+	Audit AuditConfig `yaml:"audit,omitempty"`
+
+	// QueryExpansion rewrites questions into search terms before searching
+	QueryExpansion QueryExpansionConfig `yaml:"queryExpansion,omitempty"`
+
+	// Examples configures checking that code examples in answers build
+	Examples ExamplesConfig `yaml:"examples,omitempty"`
+
+	// Routing configures picking resources for questions asked without -r
+	Routing RoutingConfig `yaml:"routing,omitempty"`
+
+	// Ensemble configures answering with several models (ask --ensemble)
+	Ensemble EnsembleConfig `yaml:"ensemble,omitempty"`
+
+	// Verify configures the judge that checks answers against the evidence
+	Verify VerifyConfig `yaml:"verify,omitempty"`
+
+	// Classify configures labeling questions by kind for btcx stats
+	Classify ClassifyConfig `yaml:"classify,omitempty"`
+
+	// Telemetry configures where opt-in usage reports go (btcx telemetry on)
+	Telemetry TelemetryConfig `yaml:"telemetry,omitempty"`
+
+	// Threads configures conversation threads
+	Threads ThreadsConfig `yaml:"threads,omitempty"`
+
+	// Scheduler limits concurrent model requests per provider
+	Scheduler SchedulerConfig `yaml:"scheduler,omitempty"`
+
+	// Budget limits the monthly model spend
+	Budget BudgetConfig `yaml:"budget,omitempty"`
+
+	// HTTP sets the proxy and CA bundle of model requests
+	HTTP HTTPConfig `yaml:"http,omitempty"`
+
+	// envRestores put back the config file values BTCX_* environment
+	// variables replaced, so Save doesn't write the environment's values
+	envRestores []func(cfg *Config)
 }
 
 // ModelConfig represents a named AI model configuration
@@ -49,11 +175,388 @@ type ModelConfig struct {
 	// Model is the model ID to use
 	Model string `yaml:"model"`
 
-	// BaseURL is the custom base URL (optional, for ollama or openai-compatible)
+	// BaseURL is the custom base URL (optional)
+	// Required for openai-compatible, and for azure-openai as the resource
+	// endpoint; for the other providers it points at a gateway or proxy that
+	// exposes the provider's native API
 	BaseURL string `yaml:"baseUrl,omitempty"`
 
+	// Headers are sent with every request, e.g. gateway auth or routing headers
+	// Supports $VAR / ${VAR} environment variable expansion
+	Headers map[string]string `yaml:"headers,omitempty"`
+
+	// HTTP overrides the top-level http settings for this model
+	HTTP *HTTPConfig `yaml:"http,omitempty"`
+
+	// ResolvedHTTP is http with this model's overrides applied
+	// This is not saved to the config file
+	ResolvedHTTP HTTPConfig `yaml:"-"`
+
 	// APIKey is an optional API key (prefer environment variables)
 	APIKey string `yaml:"apiKey,omitempty"`
+
+	// Loop overrides the global loop-detection settings for this model
+	// Only non-zero fields are applied
+	Loop *LoopConfig `yaml:"loop,omitempty"`
+
+	// Timeouts bound how long the model's requests may take
+	Timeouts *TimeoutsConfig `yaml:"timeouts,omitempty"`
+
+	// Reasoning sets how much a reasoning model thinks before answering
+	// (OpenAI reasoning_effort, Anthropic extended thinking)
+	Reasoning *ReasoningConfig `yaml:"reasoning,omitempty"`
+
+	// RateLimit keeps the model's requests under the provider's quota
+	RateLimit *RateLimitConfig `yaml:"rateLimit,omitempty"`
+
+	// Plugin runs an out-of-tree provider (required for the plugin provider)
+	Plugin *PluginConfig `yaml:"plugin,omitempty"`
+
+	// Ollama tunes how ollama models are loaded (ollama provider only)
+	Ollama *OllamaConfig `yaml:"ollama,omitempty"`
+
+	// LlamaCpp picks the llama-server endpoint and how tools are called
+	// (llamacpp provider only)
+	LlamaCpp *LlamaCppConfig `yaml:"llamacpp,omitempty"`
+
+	// Azure sets the deployment, API version and auth of azure-openai models
+	Azure *AzureConfig `yaml:"azure,omitempty"`
+
+	// Vertex serves a google model through Vertex AI with Google Cloud
+	// credentials instead of a Gemini API key
+	Vertex *VertexConfig `yaml:"vertex,omitempty"`
+
+	// Pricing is the model's price, used to track spend against
+	// budget.monthlyUSD; requests of models without pricing cost nothing
+	Pricing *PricingConfig `yaml:"pricing,omitempty"`
+
+	// Vision lets the read tool return images (PNG, JPEG, GIF, WebP) to the model
+	// Default: true for anthropic, openai and google; false otherwise
+	Vision *bool `yaml:"vision,omitempty"`
+
+	// Capabilities describes what the model supports, for models whose
+	// provider defaults are wrong (e.g. a local model without function calling)
+	Capabilities *CapabilitiesConfig `yaml:"capabilities,omitempty"`
+
+	// Strategy is how the model runs investigations
+	// "triage" has TriageModel run the searches and only asks this model for
+	// the final answer; Default: this model does everything
+	Strategy ModelStrategy `yaml:"strategy,omitempty"`
+
+	// TriageModel is the named model running the searches for strategy:
+	// triage, e.g. a small local model
+	TriageModel string `yaml:"triageModel,omitempty"`
+}
+
+// CapabilitiesConfig overrides what btcx assumes a model supports
+type CapabilitiesConfig struct {
+	// SupportsTools is whether the model can call functions; without it the
+	// tools are described in the prompt and calls are read from the model's
+	// replies (default: true)
+	SupportsTools *bool `yaml:"supportsTools,omitempty"`
+
+	// ToolFormat is how a model without function calling writes tool calls
+	// "json" or "react"; Default: "json"
+	ToolFormat ToolFormat `yaml:"toolFormat,omitempty"`
+
+	// SupportsStreaming is whether responses are streamed
+	// Default: true, except for openai-compatible servers
+	SupportsStreaming *bool `yaml:"supportsStreaming,omitempty"`
+
+	// ContextWindow is the model's context window in tokens; requests that
+	// wouldn't fit drop their oldest tool outputs, and ollama models load with
+	// it unless ollama.numCtx is set (default: unknown, nothing is dropped)
+	ContextWindow int `yaml:"contextWindow,omitempty"`
+
+	// MaxOutputTokens is the most tokens the model can generate in a
+	// response; answer max tokens are capped to it (default: unknown)
+	MaxOutputTokens int `yaml:"maxOutputTokens,omitempty"`
+
+	// SupportsImages is the same as vision, and wins over it
+	SupportsImages *bool `yaml:"supportsImages,omitempty"`
+}
+
+// SupportsImages reports whether images can be sent to the model
+func (m *ModelConfig) SupportsImages() bool {
+	if m.Capabilities != nil && m.Capabilities.SupportsImages != nil {
+		return *m.Capabilities.SupportsImages
+	}
+	if m.Vision != nil {
+		return *m.Vision
+	}
+	switch m.Provider {
+	case ProviderAnthropic, ProviderOpenAI, ProviderAzureOpenAI, ProviderGoogle:
+		return true
+	}
+	return false
+}
+
+// SupportsTools reports whether the model can call functions
+func (m *ModelConfig) SupportsTools() bool {
+	if m.Capabilities != nil && m.Capabilities.SupportsTools != nil {
+		return *m.Capabilities.SupportsTools
+	}
+	return true
+}
+
+// TextToolFormat returns how the model writes tool calls when it can't
+// call functions
+func (m *ModelConfig) TextToolFormat() ToolFormat {
+	if m.Capabilities == nil || m.Capabilities.ToolFormat == "" {
+		return ToolFormatJSON
+	}
+	return m.Capabilities.ToolFormat
+}
+
+// SupportsStreaming reports whether the model's responses can be streamed
+// openai-compatible servers often stream in non-standard ways, so they
+// don't unless configured to
+func (m *ModelConfig) SupportsStreaming() bool {
+	if m.Capabilities != nil && m.Capabilities.SupportsStreaming != nil {
+		return *m.Capabilities.SupportsStreaming
+	}
+	return m.Provider != ProviderOpenAICompatible
+}
+
+// ContextWindow returns the model's context window in tokens (0 if unknown)
+func (m *ModelConfig) ContextWindow() int {
+	if m.Capabilities == nil {
+		return 0
+	}
+	return m.Capabilities.ContextWindow
+}
+
+// MaxOutputTokens returns the most tokens the model can generate (0 if
+// unknown)
+func (m *ModelConfig) MaxOutputTokens() int {
+	if m.Capabilities == nil {
+		return 0
+	}
+	return m.Capabilities.MaxOutputTokens
+}
+
+// PluginConfig describes an external provider plugin process
+// The plugin speaks JSON-RPC 2.0 over stdin/stdout (see README)
+type PluginConfig struct {
+	// Command is the plugin executable
+	Command string `yaml:"command"`
+
+	// Args are passed to the command
+	Args []string `yaml:"args,omitempty"`
+
+	// Env adds environment variables for the plugin ($VAR references are expanded)
+	Env map[string]string `yaml:"env,omitempty"`
+}
+
+// OllamaConfig tunes Ollama's native API
+type OllamaConfig struct {
+	// NumCtx is the context window in tokens (default: the model's, often 2048-8192)
+	NumCtx int `yaml:"numCtx,omitempty"`
+
+	// KeepAlive is how long the model stays loaded after a request, e.g. "30m";
+	// "-1" keeps it loaded and "0" unloads it right away (default: Ollama's, 5m)
+	KeepAlive string `yaml:"keepAlive,omitempty"`
+
+	// Pull downloads the model on first use if the server doesn't have it
+	Pull bool `yaml:"pull,omitempty"`
+}
+
+// LlamaCppEndpoint is the llama-server endpoint a model is served from
+type LlamaCppEndpoint string
+
+const (
+	// LlamaCppEndpointChat is /v1/chat/completions, where the server applies
+	// the model's chat template
+	LlamaCppEndpointChat LlamaCppEndpoint = "chat"
+	// LlamaCppEndpointCompletion is the native /completion endpoint, sent the
+	// prompt rendered by the server's /apply-template
+	LlamaCppEndpointCompletion LlamaCppEndpoint = "completion"
+)
+
+// LlamaCppToolCalls is how llamacpp models call tools
+type LlamaCppToolCalls string
+
+const (
+	// LlamaCppToolCallsNative uses the server's function calling, which
+	// needs llama-server --jinja and a template with tool support
+	LlamaCppToolCallsNative LlamaCppToolCalls = "native"
+	// LlamaCppToolCallsGrammar describes the tools in the prompt and
+	// constrains the reply with a grammar to a tool call or an answer, which
+	// works with any model
+	LlamaCppToolCallsGrammar LlamaCppToolCalls = "grammar"
+)
+
+// DefaultStreamIdleTimeout is how long a stream may go without an event, in
+// seconds, before it is given up on
+const DefaultStreamIdleTimeout = 300
+
+// TimeoutsConfig bounds a model's requests, in seconds (0 uses the default)
+type TimeoutsConfig struct {
+	// Connect is the time to connect to the provider, including the TLS
+	// handshake (default: 30)
+	Connect int `yaml:"connect,omitempty"`
+
+	// Total is the time a whole request may take, from sending it to the end
+	// of its answer (default: no limit)
+	Total int `yaml:"total,omitempty"`
+
+	// StreamIdle is the time a streamed response may go without sending
+	// anything, including the wait for its first token (default: 300)
+	StreamIdle int `yaml:"streamIdle,omitempty"`
+}
+
+// RequestTimeouts returns the model's request timeouts with defaults applied
+// A zero connect timeout or total leaves them unbounded by btcx
+func (m *ModelConfig) RequestTimeouts() (connect, total, streamIdle time.Duration) {
+	streamIdle = DefaultStreamIdleTimeout * time.Second
+	if t := m.Timeouts; t != nil {
+		connect = time.Duration(t.Connect) * time.Second
+		total = time.Duration(t.Total) * time.Second
+		if t.StreamIdle > 0 {
+			streamIdle = time.Duration(t.StreamIdle) * time.Second
+		}
+	}
+	return connect, total, streamIdle
+}
+
+// Reasoning efforts
+const (
+	ReasoningEffortLow    = "low"
+	ReasoningEffortMedium = "medium"
+	ReasoningEffortHigh   = "high"
+)
+
+// MinThinkingBudget is the smallest thinking budget Anthropic accepts
+const MinThinkingBudget = 1024
+
+// thinkingBudgets are the thinking budgets of the efforts, for models with
+// extended thinking when budgetTokens is unset
+var thinkingBudgets = map[string]int{
+	ReasoningEffortLow:    2048,
+	ReasoningEffortMedium: 8192,
+	ReasoningEffortHigh:   24576,
+}
+
+// ReasoningConfig sets how much a reasoning model thinks before answering
+type ReasoningConfig struct {
+	// Effort is low, medium or high; OpenAI reasoning models take it as
+	// reasoning_effort, and it sets the thinking budget of Anthropic models
+	Effort string `yaml:"effort,omitempty"`
+
+	// BudgetTokens is the tokens Anthropic models may think for, on top of
+	// the answer's max tokens (at least 1024; default: from effort)
+	BudgetTokens int `yaml:"budgetTokens,omitempty"`
+}
+
+// ReasoningSettings returns the model's reasoning effort and thinking
+// budget ("" and 0 without reasoning settings)
+func (m *ModelConfig) ReasoningSettings() (effort string, budget int) {
+	r := m.Reasoning
+	if r == nil {
+		return "", 0
+	}
+	budget = r.BudgetTokens
+	if budget == 0 {
+		budget = thinkingBudgets[r.Effort]
+	}
+	return r.Effort, budget
+}
+
+// RateLimitConfig limits a model's requests across everything a btcx
+// process does; requests over a limit wait until the quota allows them
+type RateLimitConfig struct {
+	// RequestsPerMinute limits the requests sent (0 = unlimited)
+	RequestsPerMinute int `yaml:"requestsPerMinute,omitempty"`
+
+	// TokensPerMinute limits the prompt and output tokens (0 = unlimited)
+	// Prompts are estimated before they are sent; the difference to the
+	// reported usage is charged afterwards
+	TokensPerMinute int `yaml:"tokensPerMinute,omitempty"`
+}
+
+// LlamaCppConfig configures a model served by llama.cpp's llama-server
+type LlamaCppConfig struct {
+	// Endpoint is chat or completion (default: chat)
+	Endpoint LlamaCppEndpoint `yaml:"endpoint,omitempty"`
+
+	// ToolCalls is native or grammar
+	// Default: native for the chat endpoint; the completion endpoint always
+	// uses grammar
+	ToolCalls LlamaCppToolCalls `yaml:"toolCalls,omitempty"`
+}
+
+// GrammarToolCalls reports whether tool calls are constrained by a grammar
+func (c *LlamaCppConfig) GrammarToolCalls() bool {
+	return c.ToolCalls == LlamaCppToolCallsGrammar || c.Endpoint == LlamaCppEndpointCompletion
+}
+
+// AzureAuth is how azure-openai models authenticate
+type AzureAuth string
+
+const (
+	// AzureAuthKey sends the resource's API key (apiKey or AZURE_OPENAI_API_KEY)
+	AzureAuthKey AzureAuth = "key"
+	// AzureAuthEntra sends a Microsoft Entra ID token, from a service
+	// principal's client secret or the Azure CLI login
+	AzureAuthEntra AzureAuth = "entra"
+)
+
+// DefaultAzureAPIVersion is the Azure OpenAI API version used by default
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// AzureConfig configures an Azure OpenAI deployment
+// baseUrl is the resource endpoint, e.g. https://myresource.openai.azure.com
+type AzureConfig struct {
+	// Deployment is the deployment name (default: the model ID)
+	Deployment string `yaml:"deployment,omitempty"`
+
+	// APIVersion is the api-version query parameter (default: 2024-10-21)
+	APIVersion string `yaml:"apiVersion,omitempty"`
+
+	// Auth is key or entra (default: key)
+	Auth AzureAuth `yaml:"auth,omitempty"`
+
+	// TenantID and ClientID identify the service principal for entra auth;
+	// its secret is read from AZURE_CLIENT_SECRET
+	// Default: AZURE_TENANT_ID and AZURE_CLIENT_ID; without a secret the
+	// Azure CLI login (az account get-access-token) is used
+	TenantID string `yaml:"tenantId,omitempty"`
+	ClientID string `yaml:"clientId,omitempty"`
+}
+
+// DefaultVertexLocation is the Vertex AI region used by default
+const DefaultVertexLocation = "us-central1"
+
+// VertexConfig configures Gemini through Vertex AI
+// Requests authenticate with Application Default Credentials
+// (GOOGLE_APPLICATION_CREDENTIALS, gcloud auth application-default login, or
+// the metadata server on Google Cloud) unless CredentialsFile is set
+type VertexConfig struct {
+	// Project is the Google Cloud project ID
+	// Default: GOOGLE_CLOUD_PROJECT, or the project of CredentialsFile
+	Project string `yaml:"project,omitempty"`
+
+	// Location is the region, e.g. europe-west4, or global
+	// Default: GOOGLE_CLOUD_LOCATION, or us-central1
+	Location string `yaml:"location,omitempty"`
+
+	// CredentialsFile is a service account key (JSON) to authenticate with
+	CredentialsFile string `yaml:"credentialsFile,omitempty"`
+}
+
+// KeepAliveValue returns KeepAlive as Ollama expects it: a number of seconds
+// or a duration string (nil if unset)
+func (o *OllamaConfig) KeepAliveValue() (any, error) {
+	if o.KeepAlive == "" {
+		return nil, nil
+	}
+	if seconds, err := strconv.Atoi(o.KeepAlive); err == nil {
+		return seconds, nil
+	}
+	if _, err := time.ParseDuration(o.KeepAlive); err != nil {
+		return nil, fmt.Errorf("ollama.keepAlive must be a duration like \"30m\" or seconds, got %q", o.KeepAlive)
+	}
+	return o.KeepAlive, nil
 }
 
 // OutputConfig controls CLI output behavior
@@ -64,9 +567,32 @@ type OutputConfig struct {
 	// Markdown enables markdown rendering of output (default: true)
 	Markdown bool `yaml:"markdown"`
 
+	// Stream prints answers block by block while they are written, instead
+	// of once they are complete (default: true)
+	Stream bool `yaml:"stream"`
+
 	// ShowUsage shows token usage after response (default: true)
 	ShowUsage bool `yaml:"showUsage"`
 
+	// Language is the language answers are written in, as a code (de, ja, ...)
+	// or a name; code and identifiers stay as they are (default: English)
+	Language string `yaml:"language,omitempty"`
+
+	// Locale is the locale of CLI and TUI messages, e.g. de or pt_BR; it
+	// doesn't change the answers (see Language)
+	// Default: from LC_ALL, LC_MESSAGES or LANG, else English
+	Locale string `yaml:"locale,omitempty"`
+
+	// Brevity is the answer style: short (a paragraph or two), normal or
+	// deep (an exhaustive walkthrough); it also sets the answer's max tokens
+	// Default: normal
+	Brevity Brevity `yaml:"brevity,omitempty"`
+
+	// PostProcess is the pipeline of steps applied in order to each final
+	// answer before it is shown or stored (redaction, link rewriting,
+	// banned phrases)
+	PostProcess []PostProcessStep `yaml:"postProcess,omitempty"`
+
 	// OutputDir is the directory for truncated tool outputs
 	// Default: ~/.local/share/btcx/outputs
 	OutputDir string `yaml:"outputDir,omitempty"`
@@ -76,6 +602,29 @@ type OutputConfig struct {
 	ResolvedOutputDir string `yaml:"-"`
 }
 
+// PostProcessStep is one step of the answer post-processing pipeline
+type PostProcessStep struct {
+	// Type is redact, links or ban
+	Type PostProcessType `yaml:"type"`
+
+	// Pattern is the regular expression redact replaces (RE2 syntax)
+	Pattern string `yaml:"pattern,omitempty"`
+
+	// Replacement replaces redact matches; $1 refers to the first group
+	// Default: [REDACTED]
+	Replacement string `yaml:"replacement,omitempty"`
+
+	// From is the URL prefix links rewrites, e.g. https://pkg.go.dev/
+	From string `yaml:"from,omitempty"`
+
+	// To replaces From, e.g. https://docs.internal/go/
+	To string `yaml:"to,omitempty"`
+
+	// Phrases are the banned phrases; ban drops lines containing one
+	// (case-insensitive)
+	Phrases []string `yaml:"phrases,omitempty"`
+}
+
 // CacheConfig represents cache configuration
 type CacheConfig struct {
 	// Path is the directory to store cached resources
@@ -87,6 +636,591 @@ type CacheConfig struct {
 	ResolvedPath string `yaml:"-"`
 }
 
+// WebhookConfig represents completion webhook configuration
+type WebhookConfig struct {
+	// URL is the endpoint that receives a JSON summary after each ask
+	// Leave empty to disable the webhook
+	URL string `yaml:"url,omitempty"`
+
+	// Headers are extra HTTP headers sent with each request
+	// Values support $VAR / ${VAR} environment variable expansion
+	Headers map[string]string `yaml:"headers,omitempty"`
+
+	// Timeout is the request timeout in seconds (default: 10)
+	Timeout int `yaml:"timeout,omitempty"`
+}
+
+// AnswerCacheConfig configures the semantic answer cache
+type AnswerCacheConfig struct {
+	// Enabled turns the answer cache on (default: true)
+	Enabled bool `yaml:"enabled"`
+
+	// Threshold is the minimum question similarity (0-1) for a hit (default: 0.92)
+	Threshold float64 `yaml:"threshold,omitempty"`
+}
+
+// ResponseCacheConfig configures the provider response cache, which answers
+// byte-identical model requests from disk so re-running the same questions
+// (e.g. in CI) isn't billed twice
+type ResponseCacheConfig struct {
+	// Enabled turns the response cache on (default: false)
+	Enabled bool `yaml:"enabled"`
+
+	// TTL is how long responses are reused, in hours (default: 168)
+	TTL int `yaml:"ttl,omitempty"`
+}
+
+// ToolsConfig configures the agent's search tools
+type ToolsConfig struct {
+	// Sandbox restricts tool paths to the collection and its resources,
+	// rejecting absolute paths and symlinks that lead elsewhere (default: true)
+	Sandbox bool `yaml:"sandbox"`
+
+	// DirectoryMap adds each resource's top two directory levels and key
+	// files to the system prompt, cached per resource version, so the model
+	// needs fewer list and glob calls to find its way (default: true)
+	DirectoryMap bool `yaml:"directoryMap"`
+
+	// SemanticSearch enables the semantic_search tool, which ranks indexed
+	// chunks of the resources with BM25 and embeddings (default: false)
+	SemanticSearch bool `yaml:"semanticSearch,omitempty"`
+
+	// GitDiff enables the gitdiff tool, which diffs git resources between
+	// tags or commits (default: false; always on for ask --from/--to)
+	GitDiff bool `yaml:"gitDiff,omitempty"`
+
+	// Notes enables the note tool, a scratchpad the model keeps findings in;
+	// the thread's notes are shown to the model on every turn (default: false)
+	Notes bool `yaml:"notes,omitempty"`
+
+	// GrepFormat is the grep result format: "default" or "compact", which
+	// groups matches per file under a common directory and drops indentation
+	// to save tokens on big match sets (default: default)
+	GrepFormat string `yaml:"grepFormat,omitempty"`
+
+	// Boost has grep and glob list the matches of a kind of files first:
+	// docs, code, tests or examples (example code and tests); "auto" boosts
+	// examples for questions asking for usage or examples, "off" never boosts
+	// Default: auto
+	Boost string `yaml:"boost,omitempty"`
+
+	// Ripgrep is the ripgrep executable grep and glob run, e.g. rg.exe or a
+	// full path; "off" always uses the built-in search
+	// Default: rg from PATH, falling back to the built-in search
+	Ripgrep string `yaml:"ripgrep,omitempty"`
+
+	// Limits applies to every tool; zero fields keep the built-in defaults
+	Limits ToolLimits `yaml:"limits,omitempty"`
+
+	// PerTool overrides Limits for individual tools, keyed by tool name
+	// (grep, glob, read, list, read_output, semantic_search, or a custom tool)
+	PerTool map[string]ToolLimits `yaml:"perTool,omitempty"`
+
+	// Custom registers user-defined tools that run a command
+	Custom []CustomToolConfig `yaml:"custom,omitempty"`
+}
+
+// Boost settings besides the search scopes
+const (
+	// BoostAuto boosts examples for usage questions
+	BoostAuto = "auto"
+	// BoostOff never boosts
+	BoostOff = "off"
+)
+
+// CustomToolConfig defines a tool backed by a local command
+// The command runs without a shell in the collection directory with a minimal
+// environment. Arguments arrive as JSON on stdin and in BTCX_ARGS, and
+// "{name}" placeholders in Args are replaced with argument values
+type CustomToolConfig struct {
+	// Name is the tool name shown to the model
+	Name string `yaml:"name"`
+
+	// Description tells the model when to use the tool
+	Description string `yaml:"description"`
+
+	// Command is the executable to run (relative paths resolve from the
+	// directory of the config file declaring the tool)
+	Command string `yaml:"command"`
+
+	// Args are passed to the command after placeholder substitution
+	Args []string `yaml:"args,omitempty"`
+
+	// AllowFlagValues lets arguments that start with "-" be substituted at
+	// the start of an arg (default: rejected, so the model can't pass options)
+	AllowFlagValues bool `yaml:"allowFlagValues,omitempty"`
+
+	// Schema is the JSON schema of the tool arguments (default: no arguments)
+	Schema map[string]interface{} `yaml:"schema,omitempty"`
+
+	// Env adds environment variables ($VAR references are expanded)
+	Env map[string]string `yaml:"env,omitempty"`
+
+	// Timeout is the maximum run time in seconds (default: 30)
+	Timeout int `yaml:"timeout,omitempty"`
+
+	// configDir is the directory of the config file declaring the tool
+	// ("" for tools from the environment)
+	configDir string
+}
+
+// CommandPath returns the command to run, with a relative path resolved
+// against the directory of the config file declaring the tool
+// Bare command names are left to be looked up in PATH
+func (t *CustomToolConfig) CommandPath() string {
+	path := t.Command
+	if path == "" || !strings.ContainsAny(path, `/\`) {
+		return path
+	}
+	if path[0] == '~' {
+		homeDir, _ := os.UserHomeDir()
+		path = filepath.Join(homeDir, path[1:])
+	} else if !filepath.IsAbs(path) {
+		dir := t.configDir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		path = filepath.Join(dir, path)
+	}
+	return path
+}
+
+// ToolLimits bounds tool work and output sizes
+type ToolLimits struct {
+	// MaxOutputBytes truncates and saves outputs larger than this (default: 51200)
+	MaxOutputBytes int `yaml:"maxOutputBytes,omitempty"`
+
+	// MaxOutputLines truncates and saves outputs longer than this (default: 500)
+	MaxOutputLines int `yaml:"maxOutputLines,omitempty"`
+
+	// MaxReadBytes is the most file content returned by one read (default: 51200)
+	MaxReadBytes int `yaml:"maxReadBytes,omitempty"`
+
+	// DefaultReadLines is the number of lines read when no limit is given (default: 2000)
+	DefaultReadLines int `yaml:"defaultReadLines,omitempty"`
+
+	// MaxLineLength truncates longer lines in read and grep output (default: 2000)
+	MaxLineLength int `yaml:"maxLineLength,omitempty"`
+
+	// MaxMatches is the most grep matches returned (default: 100)
+	MaxMatches int `yaml:"maxMatches,omitempty"`
+
+	// MaxFiles is the most glob results returned (default: 100)
+	MaxFiles int `yaml:"maxFiles,omitempty"`
+}
+
+// LimitsFor returns the limits for a tool, applying its per-tool overrides
+func (c ToolsConfig) LimitsFor(name string) ToolLimits {
+	l := c.Limits
+	o, ok := c.PerTool[name]
+	if !ok {
+		return l
+	}
+	if o.MaxOutputBytes > 0 {
+		l.MaxOutputBytes = o.MaxOutputBytes
+	}
+	if o.MaxOutputLines > 0 {
+		l.MaxOutputLines = o.MaxOutputLines
+	}
+	if o.MaxReadBytes > 0 {
+		l.MaxReadBytes = o.MaxReadBytes
+	}
+	if o.DefaultReadLines > 0 {
+		l.DefaultReadLines = o.DefaultReadLines
+	}
+	if o.MaxLineLength > 0 {
+		l.MaxLineLength = o.MaxLineLength
+	}
+	if o.MaxMatches > 0 {
+		l.MaxMatches = o.MaxMatches
+	}
+	if o.MaxFiles > 0 {
+		l.MaxFiles = o.MaxFiles
+	}
+	return l
+}
+
+// QueryExpansionConfig configures the pre-search query expansion step
+type QueryExpansionConfig struct {
+	// Enabled asks the model for 3-5 search terms before searching (default: false)
+	Enabled bool `yaml:"enabled"`
+
+	// Model is the named model used for expansion, e.g. a small fast model
+	// Default: the model answering the question
+	Model string `yaml:"model,omitempty"`
+}
+
+// RoutingConfig configures automatic resource selection
+type RoutingConfig struct {
+	// Model is the named model that picks resources, e.g. a small fast model
+	// Default: the model answering the question
+	Model string `yaml:"model,omitempty"`
+
+	// MaxResources is the most resources picked for one question (default: 3)
+	MaxResources int `yaml:"maxResources,omitempty"`
+}
+
+// EnsembleConfig configures multi-model answers
+type EnsembleConfig struct {
+	// Judge is the named model that merges the models' answers
+	// Default: the model selected with -m, or the default model
+	Judge string `yaml:"judge,omitempty"`
+}
+
+// VerifyConfig configures post-answer verification by a judge model
+type VerifyConfig struct {
+	// Enabled has a judge score each fresh answer's correctness and grounding
+	// in the files the agent read (default: false)
+	Enabled bool `yaml:"enabled"`
+
+	// Model is the named judge model
+	// Default: the model answering the question
+	Model string `yaml:"model,omitempty"`
+
+	// MinScore is the lowest score (1-5) still counted as confident; lower
+	// scores add the judge's caveats to the answer (default: 4)
+	MinScore int `yaml:"minScore,omitempty"`
+}
+
+// ClassifyConfig configures question classification
+type ClassifyConfig struct {
+	// Enabled labels each question (how-to, api, debugging, comparison,
+	// other) and stores the label on the thread (default: false)
+	Enabled bool `yaml:"enabled"`
+
+	// Model is the named model that labels questions, e.g. a small fast model
+	// Default: the model answering the question
+	Model string `yaml:"model,omitempty"`
+}
+
+// PricingConfig is a model's price in USD per million tokens
+type PricingConfig struct {
+	// Input is the price of a million prompt tokens
+	Input float64 `yaml:"input"`
+
+	// Output is the price of a million generated tokens
+	Output float64 `yaml:"output"`
+
+	// CacheRead is the price of a million prompt tokens read from the
+	// provider's prompt cache (default: a tenth of Input)
+	CacheRead float64 `yaml:"cacheRead,omitempty"`
+
+	// CacheWrite is the price of a million prompt tokens written to the
+	// provider's prompt cache (default: 1.25 times Input)
+	CacheWrite float64 `yaml:"cacheWrite,omitempty"`
+}
+
+// Cost returns the price of a request's tokens in USD
+// Cached tokens are part of inputTokens and priced at the cache rates
+func (p *PricingConfig) Cost(inputTokens, outputTokens, cacheReadTokens, cacheWriteTokens int) float64 {
+	if p == nil {
+		return 0
+	}
+	cacheRead, cacheWrite := p.CacheRead, p.CacheWrite
+	if cacheRead == 0 {
+		cacheRead = p.Input * 0.1
+	}
+	if cacheWrite == 0 {
+		cacheWrite = p.Input * 1.25
+	}
+	uncached := max(inputTokens-cacheReadTokens-cacheWriteTokens, 0)
+	return (float64(uncached)*p.Input + float64(cacheReadTokens)*cacheRead +
+		float64(cacheWriteTokens)*cacheWrite + float64(outputTokens)*p.Output) / 1e6
+}
+
+// BudgetWarnShare is the share of the monthly budget at which btcx warns
+const BudgetWarnShare = 0.8
+
+// BudgetConfig limits the model spend per calendar month, summed over every
+// thread and command from the models' pricing
+type BudgetConfig struct {
+	// MonthlyUSD is the spend limit; btcx warns at 80% and refuses model
+	// requests at 100% unless run with --force (default: 0, no limit)
+	MonthlyUSD float64 `yaml:"monthlyUSD,omitempty"`
+
+	// Force lets model requests through past the limit (--force)
+	// This is not saved to the config file
+	Force bool `yaml:"-"`
+}
+
+// ThreadsConfig configures conversation threads
+type ThreadsConfig struct {
+	// SummaryModel is the named model that writes thread digests
+	// (btcx threads summarize), e.g. a small fast model
+	// Default: the default model
+	SummaryModel string `yaml:"summaryModel,omitempty"`
+
+	// SpillBytes is the message size above which a message's content and
+	// tool outputs are stored in their own file next to the thread, with
+	// only previews in the thread file; -1 keeps everything in the thread
+	// file (default: 32768)
+	SpillBytes int `yaml:"spillBytes,omitempty"`
+}
+
+// HTTPConfig configures how model requests reach providers, e.g. through a
+// corporate proxy that re-signs TLS traffic
+type HTTPConfig struct {
+	// Proxy is the proxy URL for model requests (http, https or socks5)
+	// Supports $VAR / ${VAR} environment variable expansion
+	// Default: HTTPS_PROXY / HTTP_PROXY, except hosts in NO_PROXY
+	Proxy string `yaml:"proxy,omitempty"`
+
+	// CABundle is a PEM file of extra CA certificates to trust besides the
+	// system ones (default: BTCX_CA_BUNDLE)
+	CABundle string `yaml:"caBundle,omitempty"`
+}
+
+// Merge returns h with the set fields of override applied
+func (h HTTPConfig) Merge(override *HTTPConfig) HTTPConfig {
+	if override == nil {
+		return h
+	}
+	if override.Proxy != "" {
+		h.Proxy = override.Proxy
+	}
+	if override.CABundle != "" {
+		h.CABundle = override.CABundle
+	}
+	return h
+}
+
+// SchedulerConfig limits how many model requests run at once across
+// everything a btcx process does (server asks, Slack, ensembles)
+// Requests over a limit wait, and free slots go to waiting clients in turn,
+// so one client's long run doesn't starve the others
+type SchedulerConfig struct {
+	// MaxConcurrent is the limit for providers not listed in Providers
+	// (0 = unlimited)
+	MaxConcurrent int `yaml:"maxConcurrent,omitempty"`
+
+	// Providers sets the limit per provider type (anthropic, openai, ...) or
+	// model name; a model's own limit takes precedence over its provider's
+	Providers map[string]int `yaml:"providers,omitempty"`
+}
+
+// Lane returns the scheduler lane a model's requests queue in and its limit
+// (0 = unlimited)
+func (s *SchedulerConfig) Lane(m *ModelConfig) (string, int) {
+	if limit, ok := s.Providers[m.Name]; ok {
+		return "model:" + m.Name, limit
+	}
+	if limit, ok := s.Providers[string(m.Provider)]; ok {
+		return string(m.Provider), limit
+	}
+	return string(m.Provider), s.MaxConcurrent
+}
+
+// TelemetryConfig configures opt-in usage telemetry
+// Whether it is on is a per-user choice made with `btcx telemetry on|off`
+type TelemetryConfig struct {
+	// Endpoint receives the usage events
+	// Default: the endpoint built into the release
+	Endpoint string `yaml:"endpoint,omitempty"`
+}
+
+// ExamplesConfig configures the post-answer check of code examples
+type ExamplesConfig struct {
+	// Validate type-checks Go and TypeScript examples in answers against the
+	// resources and flags the ones that don't build (default: false)
+	Validate bool `yaml:"validate"`
+
+	// Timeout is the maximum check time per example in seconds (default: 60)
+	Timeout int `yaml:"timeout,omitempty"`
+}
+
+// AuditConfig configures the append-only audit log of tool calls
+type AuditConfig struct {
+	// Enabled turns on audit logging (default: false)
+	Enabled bool `yaml:"enabled"`
+
+	// Path is the JSONL file to append to
+	// Default: ~/.local/share/btcx/audit.jsonl
+	Path string `yaml:"path,omitempty"`
+
+	// ResolvedPath is the absolute path after expanding ~ and relative paths
+	// This is not saved to the config file
+	ResolvedPath string `yaml:"-"`
+}
+
+// LoopStep is an escalation step taken when the agent appears stuck
+type LoopStep string
+
+const (
+	// LoopStepHint adds search guidance to the system prompt
+	LoopStepHint LoopStep = "hint"
+	// LoopStepBroaden reruns the last empty search with a relaxed pattern
+	LoopStepBroaden LoopStep = "broaden"
+	// LoopStepAnswer stops searching and asks for a general answer
+	LoopStepAnswer LoopStep = "answer"
+)
+
+// LoopConfig tunes how the agent detects and recovers from stuck searches
+type LoopConfig struct {
+	// MaxIterations is the maximum number of model turns per question (default: 10)
+	MaxIterations int `yaml:"maxIterations,omitempty"`
+
+	// StuckAfterEmpty is the number of consecutive tool rounds without useful
+	// results before the agent counts as stuck (default: 2)
+	StuckAfterEmpty int `yaml:"stuckAfterEmpty,omitempty"`
+
+	// ForceAfterEmpty forces completion after this many consecutive empty rounds (default: 3)
+	ForceAfterEmpty int `yaml:"forceAfterEmpty,omitempty"`
+
+	// ForceAfterSearches forces completion once this many searches have run
+	// and the agent is stuck (default: 8)
+	ForceAfterSearches int `yaml:"forceAfterSearches,omitempty"`
+
+	// Strategy is the list of steps taken, one per stuck round, in order
+	// Valid steps: hint, broaden, answer (default: [hint])
+	Strategy []LoopStep `yaml:"strategy,omitempty"`
+
+	// Hint replaces the built-in search guidance added by the hint step
+	Hint string `yaml:"hint,omitempty"`
+
+	// ToolChoice makes the model search before answering a new question and
+	// forbids tool calls once it has to answer (default: false)
+	// Off by default since some servers reject the tool_choice parameter
+	ToolChoice *bool `yaml:"toolChoice,omitempty"`
+}
+
+// UsesToolChoice reports whether the loop constrains tool calls
+func (c LoopConfig) UsesToolChoice() bool {
+	return c.ToolChoice != nil && *c.ToolChoice
+}
+
+// Merge returns c with the non-zero fields of override applied
+func (c LoopConfig) Merge(override *LoopConfig) LoopConfig {
+	if override == nil {
+		return c
+	}
+	if override.MaxIterations > 0 {
+		c.MaxIterations = override.MaxIterations
+	}
+	if override.StuckAfterEmpty > 0 {
+		c.StuckAfterEmpty = override.StuckAfterEmpty
+	}
+	if override.ForceAfterEmpty > 0 {
+		c.ForceAfterEmpty = override.ForceAfterEmpty
+	}
+	if override.ForceAfterSearches > 0 {
+		c.ForceAfterSearches = override.ForceAfterSearches
+	}
+	if len(override.Strategy) > 0 {
+		c.Strategy = override.Strategy
+	}
+	if override.Hint != "" {
+		c.Hint = override.Hint
+	}
+	if override.ToolChoice != nil {
+		c.ToolChoice = override.ToolChoice
+	}
+	return c
+}
+
+// Default address for `btcx serve`
+const DefaultServeAddr = "127.0.0.1:8080"
+
+// ServeConfig represents configuration for `btcx serve`
+type ServeConfig struct {
+	// Addr is the address to listen on (default: 127.0.0.1:8080)
+	Addr string `yaml:"addr,omitempty"`
+
+	// PublicURL is the URL clients reach the server at, e.g.
+	// https://btcx.example.com; share links are built from it
+	PublicURL string `yaml:"publicURL,omitempty"`
+
+	// TrustProxy builds share links from the X-Forwarded-Proto and
+	// X-Forwarded-Host headers when PublicURL is unset
+	// Only enable it behind a proxy that sets both
+	TrustProxy bool `yaml:"trustProxy,omitempty"`
+
+	// Slack configures the Slack Events API integration
+	Slack SlackConfig `yaml:"slack,omitempty"`
+
+	// APIKeys restricts the API to these keys (open if empty)
+	// Each key gets its own thread namespace
+	APIKeys []APIKey `yaml:"apiKeys,omitempty"`
+
+	// Limits controls concurrency, queueing and rate limiting
+	Limits ServeLimits `yaml:"limits,omitempty"`
+}
+
+// ServeLimits controls how the server degrades under load
+type ServeLimits struct {
+	// MaxConcurrent is the maximum number of asks running at once (default: 4)
+	MaxConcurrent int `yaml:"maxConcurrent,omitempty"`
+
+	// MaxQueue is the number of asks that may wait for a slot (default: 16)
+	// Requests beyond this are rejected with 429 Too Many Requests
+	MaxQueue int `yaml:"maxQueue,omitempty"`
+
+	// RequestsPerMinute limits asks per API key (or client IP) (0 = unlimited)
+	RequestsPerMinute int `yaml:"requestsPerMinute,omitempty"`
+
+	// Burst is the number of asks allowed in a burst (default: RequestsPerMinute)
+	Burst int `yaml:"burst,omitempty"`
+}
+
+// APIKey is a named API key for `btcx serve`
+type APIKey struct {
+	// User is the user name; threads are stored per user
+	User string `yaml:"user"`
+
+	// Key is the secret sent as a bearer token or X-API-Key header
+	// Supports $VAR / ${VAR} environment variable expansion
+	Key string `yaml:"key"`
+}
+
+// SlackConfig represents Slack bot configuration
+type SlackConfig struct {
+	// SigningSecret verifies requests from Slack (or set SLACK_SIGNING_SECRET)
+	SigningSecret string `yaml:"signingSecret,omitempty"`
+
+	// BotToken is the bot OAuth token used to post replies (or set SLACK_BOT_TOKEN)
+	BotToken string `yaml:"botToken,omitempty"`
+
+	// DefaultResources are used in channels without a channel mapping
+	DefaultResources []string `yaml:"defaultResources,omitempty"`
+
+	// DefaultModel is the model name used when a channel doesn't set one
+	DefaultModel string `yaml:"defaultModel,omitempty"`
+
+	// Channels maps Slack channels to resources and models
+	Channels []SlackChannel `yaml:"channels,omitempty"`
+}
+
+// SlackChannel maps a Slack channel to the resources it asks about
+type SlackChannel struct {
+	// Channel is the Slack channel ID (e.g. C0123456789)
+	Channel string `yaml:"channel"`
+
+	// Resources are the resource names to search for this channel
+	Resources []string `yaml:"resources"`
+
+	// Model is the model name to use for this channel (optional)
+	Model string `yaml:"model,omitempty"`
+}
+
+// Enabled reports whether the Slack integration is configured
+func (s *SlackConfig) Enabled() bool {
+	return s.SigningSecret != "" && s.BotToken != ""
+}
+
+// ChannelConfig returns the resources and model for a channel
+// Falls back to the default resources and model for unmapped channels
+func (s *SlackConfig) ChannelConfig(channel string) ([]string, string) {
+	for _, c := range s.Channels {
+		if c.Channel == channel {
+			model := c.Model
+			if model == "" {
+				model = s.DefaultModel
+			}
+			return c.Resources, model
+		}
+	}
+	return s.DefaultResources, s.DefaultModel
+}
+
 // ResourceType represents the type of resource
 type ResourceType string
 
@@ -95,6 +1229,25 @@ const (
 	ResourceTypeLocal ResourceType = "local"
 )
 
+// ResourceFetch is how a git resource is downloaded
+type ResourceFetch string
+
+const (
+	// ResourceFetchClone makes a shallow git clone (the default)
+	ResourceFetchClone ResourceFetch = "clone"
+	// ResourceFetchArchive downloads a tarball of the branch from the forge's
+	// API, without git history
+	ResourceFetchArchive ResourceFetch = "archive"
+)
+
+// Forges that archives can be fetched from
+const (
+	ForgeGitHub    = "github"
+	ForgeGitLab    = "gitlab"
+	ForgeBitbucket = "bitbucket"
+	ForgeGitea     = "gitea"
+)
+
 // Resource represents a documentation resource
 type Resource struct {
 	// Name is the unique identifier for this resource
@@ -109,6 +1262,19 @@ type Resource struct {
 	// Branch is the git branch to use (for git resources)
 	Branch string `yaml:"branch,omitempty"`
 
+	// Fetch is how git resources are downloaded: clone (default) or archive,
+	// a tarball from the forge's API that skips git history
+	Fetch ResourceFetch `yaml:"fetch,omitempty"`
+
+	// Forge is the service hosting the repository for archive fetches and
+	// links.url auto: github, gitlab, bitbucket or gitea (default: detected
+	// from the URL)
+	Forge string `yaml:"forge,omitempty"`
+
+	// Token authenticates archive fetches; $VAR references are expanded
+	// Default: GITHUB_TOKEN, GITLAB_TOKEN, BITBUCKET_TOKEN or GITEA_TOKEN
+	Token string `yaml:"token,omitempty"`
+
 	// Path is the local filesystem path (for local resources)
 	Path string `yaml:"path,omitempty"`
 
@@ -117,6 +1283,54 @@ type Resource struct {
 
 	// Notes are hints for the AI about this resource
 	Notes string `yaml:"notes,omitempty"`
+
+	// PromptFile is a markdown file with longer guidance for the AI about this
+	// resource (conventions, directory maps, ...), added to the system prompt
+	// ~ is expanded; relative paths are from the config file declaring the
+	// resource (the current directory for BTCX_RESOURCES)
+	PromptFile string `yaml:"promptFile,omitempty"`
+
+	// Links maps cited files to their source paths and upstream URLs
+	Links *ResourceLinks `yaml:"links,omitempty"`
+
+	// configDir is the directory of the config file declaring the resource
+	// ("" for resources from the environment or the command line)
+	configDir string
+}
+
+// LinksURLAuto builds file URLs from a git resource's URL
+const LinksURLAuto = "auto"
+
+// ResourceLinks maps the files of a resource to where users find them
+type ResourceLinks struct {
+	// URL is the template of a cited file's web URL, with {path} (in the
+	// repository), {commit}, {branch} and {line}, e.g.
+	// https://github.com/spf13/cobra/blob/{commit}/{path}#L{line}
+	// "auto" builds it from url for GitHub, GitLab, Bitbucket and Gitea
+	URL string `yaml:"url,omitempty"`
+
+	// Rewrite maps path prefixes of generated or vendored files to their
+	// sources, e.g. "dist/": "src/"; the longest matching prefix wins
+	Rewrite map[string]string `yaml:"rewrite,omitempty"`
+}
+
+// PromptPath returns the absolute path of the resource's prompt file ("" if none)
+func (r *Resource) PromptPath() string {
+	path := r.PromptFile
+	if path == "" {
+		return ""
+	}
+	if path[0] == '~' {
+		homeDir, _ := os.UserHomeDir()
+		path = filepath.Join(homeDir, path[1:])
+	} else if !filepath.IsAbs(path) {
+		dir := r.configDir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		path = filepath.Join(dir, path)
+	}
+	return path
 }
 
 // Defaults returns a Config with default values
@@ -125,11 +1339,38 @@ func Defaults() Config {
 		Output: OutputConfig{
 			Spinner:   true,
 			Markdown:  true,
+			Stream:    true,
 			ShowUsage: true,
 		},
 		Cache: CacheConfig{
 			Path: "", // Will be resolved to ~/.cache/btcx
 		},
+		AnswerCache: AnswerCacheConfig{
+			Enabled: true,
+		},
+		Loop: DefaultLoopConfig(),
+		Tools: ToolsConfig{
+			Sandbox:      true,
+			DirectoryMap: true,
+		},
+		Serve: ServeConfig{
+			Addr: DefaultServeAddr,
+			Limits: ServeLimits{
+				MaxConcurrent: 4,
+				MaxQueue:      16,
+			},
+		},
 		Resources: []Resource{},
 	}
 }
+
+// DefaultLoopConfig returns the default loop-detection settings
+func DefaultLoopConfig() LoopConfig {
+	return LoopConfig{
+		MaxIterations:      10,
+		StuckAfterEmpty:    2,
+		ForceAfterEmpty:    3,
+		ForceAfterSearches: 8,
+		Strategy:           []LoopStep{LoopStepHint},
+	}
+}
@@ -0,0 +1,273 @@
+// Package examples checks that code examples in answers build.
+//
+// Go and TypeScript code blocks are extracted from the markdown answer,
+// written to a scratch directory and type-checked against the resources
+// (go vet with a replace directive for Go modules, tsc --noEmit with path
+// mappings for npm packages). Checks run offline with a timeout and never
+// touch the resource checkouts.
+package examples
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is how long a single example may take to check
+const DefaultTimeout = 60 * time.Second
+
+// maxMessageLines caps the compiler output kept for a failing example
+const maxMessageLines = 5
+
+// Language is a language examples can be checked in
+type Language string
+
+const (
+	Go         Language = "go"
+	TypeScript Language = "ts"
+)
+
+// Block is a fenced code block from an answer
+type Block struct {
+	// Index is the 1-based position among the checkable blocks
+	Index int
+
+	// Language is the block's language
+	Language Language
+
+	// Code is the block content
+	Code string
+}
+
+// Status is the outcome of checking one example
+type Status string
+
+const (
+	StatusOK      Status = "ok"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+)
+
+// Result is the outcome of checking one block
+type Result struct {
+	Block Block
+
+	// Status is whether the example built
+	Status Status
+
+	// Message is the compiler output for failures, or why the check was skipped
+	Message string
+}
+
+// Options configures a check
+type Options struct {
+	// Dirs are the resource directories examples are checked against
+	Dirs []string
+
+	// Timeout limits each example (default: DefaultTimeout)
+	Timeout time.Duration
+}
+
+// fencePattern matches the opening line of a fenced code block
+var fencePattern = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([A-Za-z0-9_+-]*)")
+
+// Extract returns the Go and TypeScript code blocks in a markdown answer
+func Extract(markdown string) []Block {
+	var blocks []Block
+	var fence string
+	var lang Language
+	var inBlock bool
+	var code strings.Builder
+
+	scanner := bufio.NewScanner(strings.NewReader(markdown))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !inBlock {
+			m := fencePattern.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			inBlock, fence, lang = true, m[1], language(m[2])
+			code.Reset()
+			continue
+		}
+
+		if strings.HasPrefix(strings.TrimSpace(line), fence) && strings.Trim(strings.TrimSpace(line), fence[:1]) == "" {
+			if lang != "" && strings.TrimSpace(code.String()) != "" {
+				blocks = append(blocks, Block{Index: len(blocks) + 1, Language: lang, Code: code.String()})
+			}
+			inBlock = false
+			continue
+		}
+		code.WriteString(line + "\n")
+	}
+	return blocks
+}
+
+// language maps a fence info string to a checkable language ("" if not checkable)
+func language(info string) Language {
+	switch strings.ToLower(info) {
+	case "go", "golang":
+		return Go
+	case "ts", "typescript", "tsx":
+		return TypeScript
+	}
+	return ""
+}
+
+// Check type-checks each block
+func Check(ctx context.Context, blocks []Block, opts Options) []Result {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	var goc *goChecker
+	results := make([]Result, 0, len(blocks))
+	for _, b := range blocks {
+		var r Result
+		switch b.Language {
+		case Go:
+			if _, err := exec.LookPath("go"); err != nil {
+				r = Result{Status: StatusSkipped, Message: "go is not installed"}
+				break
+			}
+			if goc == nil {
+				goc = newGoChecker(ctx, opts.Dirs)
+			}
+			r = goc.check(ctx, b, opts.Timeout)
+		case TypeScript:
+			r = checkTypeScript(ctx, b, opts)
+		}
+		r.Block = b
+		results = append(results, r)
+	}
+	return results
+}
+
+// Summary formats the results as a note to append to an answer
+// It returns "" when nothing was checked
+func Summary(results []Result) string {
+	checked, failed := 0, 0
+	for _, r := range results {
+		if r.Status != StatusSkipped {
+			checked++
+		}
+		if r.Status == StatusFailed {
+			failed++
+		}
+	}
+	if checked == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	if failed == 0 {
+		sb.WriteString(fmt.Sprintf("\n\n_[Example check: %d of %d code examples build.]_", checked, checked))
+	} else {
+		sb.WriteString(fmt.Sprintf("\n\n**Example check:** %d of %d code examples don't build:\n", failed, checked))
+	}
+	for _, r := range results {
+		switch r.Status {
+		case StatusFailed:
+			sb.WriteString(fmt.Sprintf("\n- Example %d (%s):\n", r.Block.Index, r.Block.Language))
+			for _, line := range strings.Split(r.Message, "\n") {
+				sb.WriteString("  `" + strings.ReplaceAll(line, "`", "'") + "`\n")
+			}
+		case StatusSkipped:
+			if failed > 0 {
+				sb.WriteString(fmt.Sprintf("\n- Example %d (%s): not checked, %s\n", r.Block.Index, r.Block.Language, r.Message))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// run runs a checker command in dir, returning its combined output
+// The command's exit status is reported through failed; err is only set
+// when the command couldn't run at all
+func run(ctx context.Context, timeout time.Duration, dir string, env []string, name string, args ...string) (output string, failed bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.WaitDelay = time.Second
+
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", false, fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return string(out), true, nil
+		}
+		return "", false, err
+	}
+	return string(out), false, nil
+}
+
+// findUp looks for name in dir and its parents, stopping at the
+// repository root (the first directory containing .git)
+func findUp(dir, name string) string {
+	for {
+		candidate := filepath.Join(dir, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// baseEnv returns the parts of the environment checkers need
+func baseEnv(extra ...string) []string {
+	var env []string
+	for _, key := range []string{"PATH", "HOME", "LANG", "TMPDIR", "SYSTEMROOT", "TEMP", "TMP", "GOROOT", "GOPATH", "GOMODCACHE", "GOCACHE"} {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	return append(env, extra...)
+}
+
+// firstLines keeps the first few non-empty lines of output
+func firstLines(lines []string) string {
+	var kept []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(kept) == maxMessageLines {
+			kept = append(kept, "...")
+			break
+		}
+		kept = append(kept, strings.TrimSpace(line))
+	}
+	return strings.Join(kept, "\n")
+}
+
+// writeJSON writes v as indented JSON
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
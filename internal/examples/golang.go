@@ -0,0 +1,379 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// exampleModule is the module path of the scratch module examples build in
+const exampleModule = "btcx.example/check"
+
+// maxPackageDirs caps the directories scanned for packages per resource
+const maxPackageDirs = 5000
+
+var (
+	// moduleDirective and goDirective read a resource's go.mod
+	moduleDirective = regexp.MustCompile(`(?m)^module\s+(\S+)`)
+	goDirective     = regexp.MustCompile(`(?m)^go\s+(\d+(?:\.\d+)*)`)
+
+	// majorVersion matches major version path elements like "v2"
+	majorVersion = regexp.MustCompile(`^v\d+$`)
+
+	// selectorUse matches identifiers used as package qualifiers ("fmt.")
+	selectorUse = regexp.MustCompile(`(?:^|[^\w.])([a-z]\w*)\.[A-Z]`)
+
+	// importSpec matches an import spec with an optional name
+	importSpec = regexp.MustCompile(`(?m)(?:^|\s)(\w*)\s*"([^"]+)"`)
+
+	// packageClause matches a package clause at the start of a line
+	packageClause = regexp.MustCompile(`(?m)^package\s+\w+`)
+
+	// goError matches a compiler or vet diagnostic, capturing the message
+	goError = regexp.MustCompile(`^(?:vet: )?\S*\.go:\d+(?::\d+)?: (.*)$`)
+
+	// unusedError matches errors about unused variables and imports, which
+	// are expected in fragments and don't mean the example is wrong
+	unusedError = regexp.MustCompile(`declared and not used|imported and not used`)
+
+	// offlineError matches failures caused by missing dependencies rather
+	// than the example itself
+	offlineError = regexp.MustCompile(`GOPROXY|module lookup disabled|missing go\.sum entry|cannot find module providing|requires go >=|toolchain not available`)
+)
+
+// goChecker builds Go examples; it is set up once per Check
+type goChecker struct {
+	// env is the environment for go commands
+	env []string
+
+	// packages maps package names to import paths, for adding imports
+	// that fragments leave out
+	packages map[string]string
+
+	// modules are the resource module roots, by module path
+	modules map[string]string
+
+	// goVersion is the go directive for the scratch module
+	goVersion string
+}
+
+// newGoChecker finds the resources' Go modules and the standard library packages
+func newGoChecker(ctx context.Context, dirs []string) *goChecker {
+	c := &goChecker{
+		env:       goEnv(ctx),
+		packages:  make(map[string]string),
+		modules:   make(map[string]string),
+		goVersion: "1.21",
+	}
+
+	if out, err := exec.CommandContext(ctx, "go", "list", "std").Output(); err == nil {
+		for _, path := range strings.Fields(string(out)) {
+			c.addPackage(path)
+		}
+	}
+
+	for _, dir := range dirs {
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			continue
+		}
+		path := findUp(real, "go.mod")
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		m := moduleDirective.FindSubmatch(data)
+		if m == nil {
+			continue
+		}
+		modulePath := strings.Trim(string(m[1]), `"`)
+		if _, ok := c.modules[modulePath]; ok {
+			continue
+		}
+		root := filepath.Dir(path)
+		c.modules[modulePath] = root
+		if v := goDirective.FindSubmatch(data); v != nil && newerGo(string(v[1]), c.goVersion) {
+			c.goVersion = string(v[1])
+		}
+		c.addModulePackages(modulePath, root)
+	}
+	return c
+}
+
+// addPackage records an import path under its package name
+// Internal, vendored and major-version paths are left out, and the shorter
+// path wins when names collide (math/rand over crypto/rand)
+func (c *goChecker) addPackage(path string) {
+	name := filepath.Base(path)
+	if strings.Contains(path, "internal") || strings.Contains(path, "vendor") || majorVersion.MatchString(name) {
+		return
+	}
+	if existing, ok := c.packages[name]; ok && (len(existing) < len(path) || len(existing) == len(path) && existing < path) {
+		return
+	}
+	c.packages[name] = path
+}
+
+// addModulePackages records the packages of a resource module by
+// directory name, which overrides standard library names
+func (c *goChecker) addModulePackages(modulePath, root string) {
+	count := 0
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" || name == "internal" || name == "node_modules") {
+			return filepath.SkipDir
+		}
+		if count++; count > maxPackageDirs {
+			return filepath.SkipAll
+		}
+		if matches, _ := filepath.Glob(filepath.Join(path, "*.go")); len(matches) == 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		importPath := modulePath
+		if rel != "." {
+			importPath += "/" + filepath.ToSlash(rel)
+		}
+		if base := filepath.Base(importPath); !majorVersion.MatchString(base) {
+			c.packages[base] = importPath
+		}
+		return nil
+	})
+}
+
+// check builds a Go example in a scratch module that replaces the
+// resources' modules with their local checkouts
+func (c *goChecker) check(ctx context.Context, b Block, timeout time.Duration) Result {
+	dir, err := os.MkdirTemp("", "btcx-example-*")
+	if err != nil {
+		return Result{Status: StatusSkipped, Message: fmt.Sprintf("failed to create scratch directory: %v", err)}
+	}
+	defer os.RemoveAll(dir)
+
+	if err := c.writeModule(dir); err != nil {
+		return Result{Status: StatusSkipped, Message: err.Error()}
+	}
+
+	// Fragments are tried as top-level declarations first, then as
+	// statements inside a function
+	sources := c.sources(b.Code)
+	for i, src := range sources {
+		if err := os.WriteFile(filepath.Join(dir, "example.go"), []byte(src), 0644); err != nil {
+			return Result{Status: StatusSkipped, Message: fmt.Sprintf("failed to write example: %v", err)}
+		}
+
+		output, failed, err := run(ctx, timeout, dir, c.env, "go", "build", "-gcflags=-e", "-o", os.DevNull, ".")
+		if err != nil {
+			return Result{Status: StatusSkipped, Message: err.Error()}
+		}
+		if failed && offlineError.MatchString(output) {
+			return Result{Status: StatusSkipped, Message: "dependencies aren't available offline"}
+		}
+		messages := goMessages(output)
+		if len(messages) > 0 && i < len(sources)-1 && strings.Contains(output, "outside function body") {
+			continue
+		}
+		if len(messages) > 0 {
+			return Result{Status: StatusFailed, Message: firstLines(messages)}
+		}
+		if failed && !onlyUnused(output) {
+			return Result{Status: StatusFailed, Message: firstLines(strings.Split(output, "\n"))}
+		}
+		break
+	}
+
+	// Unused variables stop the compiler before vet's own checks would
+	// run, so vet only adds anything for examples that compiled
+	output, failed, err := run(ctx, timeout, dir, c.env, "go", "vet", ".")
+	if err != nil {
+		return Result{Status: StatusSkipped, Message: err.Error()}
+	}
+	if messages := goMessages(output); failed && len(messages) > 0 {
+		return Result{Status: StatusFailed, Message: firstLines(messages)}
+	}
+	return Result{Status: StatusOK}
+}
+
+// sources returns the candidate source files for an example
+func (c *goChecker) sources(code string) []string {
+	code = stripEllipses(code)
+	if packageClause.MatchString(code) {
+		return []string{code}
+	}
+
+	imports, body := splitImports(code)
+	imports += "\n" + c.missingImports(imports, body)
+	return []string{
+		"package example\n\n" + imports + "\n" + body,
+		"package example\n\n" + imports + "\nfunc _() {\n" + body + "\n}\n",
+	}
+}
+
+// missingImports returns import declarations for known packages the
+// fragment uses without importing
+func (c *goChecker) missingImports(imports, body string) string {
+	var sb strings.Builder
+	for _, m := range selectorUse.FindAllStringSubmatch(body, -1) {
+		name := m[1]
+		path, ok := c.packages[name]
+		if !ok || strings.Contains(imports, `"`+path+`"`) || importedAs(imports, name) {
+			continue
+		}
+		line := fmt.Sprintf("import %q\n", path)
+		if filepath.Base(path) != name {
+			line = fmt.Sprintf("import %s %q\n", name, path)
+		}
+		imports += line
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// importedAs reports whether imports already bind name
+func importedAs(imports, name string) bool {
+	for _, m := range importSpec.FindAllStringSubmatch(imports, -1) {
+		if m[1] == name || m[1] == "" && filepath.Base(m[2]) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// stripEllipses drops "..." placeholder lines that stand in for elided code
+func stripEllipses(code string) string {
+	var lines []string
+	for _, line := range strings.Split(code, "\n") {
+		switch strings.TrimSpace(line) {
+		case "...", "…", "// ...", "// …":
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitImports separates the import declarations of a fragment from the rest
+func splitImports(code string) (imports, body string) {
+	var imp, rest []string
+	inGroup := false
+	for _, line := range strings.Split(code, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case inGroup:
+			imp = append(imp, line)
+			if trimmed == ")" {
+				inGroup = false
+			}
+		case strings.HasPrefix(trimmed, "import ("):
+			imp = append(imp, line)
+			inGroup = true
+		case strings.HasPrefix(trimmed, "import "):
+			imp = append(imp, line)
+		default:
+			rest = append(rest, line)
+		}
+	}
+	return strings.Join(imp, "\n"), strings.Join(rest, "\n")
+}
+
+// goMessages returns the diagnostics in build output, without unused
+// variable and import errors
+func goMessages(output string) []string {
+	var messages []string
+	for _, line := range strings.Split(output, "\n") {
+		m := goError.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil || unusedError.MatchString(m[1]) {
+			continue
+		}
+		messages = append(messages, m[1])
+	}
+	return messages
+}
+
+// onlyUnused reports whether every diagnostic is an unused variable or import
+func onlyUnused(output string) bool {
+	found := false
+	for _, line := range strings.Split(output, "\n") {
+		m := goError.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		if !unusedError.MatchString(m[1]) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// writeModule writes the scratch go.mod, requiring each resource module
+// and replacing it with the local checkout
+func (c *goChecker) writeModule(dir string) error {
+	paths := make([]string, 0, len(c.modules))
+	for path := range c.modules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("module %s\n\ngo %s\n", exampleModule, c.goVersion))
+	if len(paths) > 0 {
+		sb.WriteString("\nrequire (\n")
+		for _, path := range paths {
+			sb.WriteString(fmt.Sprintf("\t%s v0.0.0-00010101000000-000000000000\n", path))
+		}
+		sb.WriteString(")\n\n")
+		for _, path := range paths {
+			sb.WriteString(fmt.Sprintf("replace %s => %q\n", path, c.modules[path]))
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write go.mod: %w", err)
+	}
+	return nil
+}
+
+// newerGo reports whether Go version a is newer than b
+func newerGo(a, b string) bool {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		var na, nb int
+		fmt.Sscanf(pa[i], "%d", &na)
+		fmt.Sscanf(pb[i], "%d", &nb)
+		if na != nb {
+			return na > nb
+		}
+	}
+	return len(pa) > len(pb)
+}
+
+// goEnv returns the environment for go commands
+// The module cache is used as the only proxy, so dependencies already
+// downloaded resolve and nothing is fetched from the network
+func goEnv(ctx context.Context) []string {
+	proxy := "off"
+	if out, err := exec.CommandContext(ctx, "go", "env", "GOMODCACHE").Output(); err == nil {
+		if cache := strings.TrimSpace(string(out)); cache != "" {
+			proxy = "file://" + filepath.ToSlash(filepath.Join(cache, "cache", "download"))
+			if !strings.HasPrefix(proxy, "file:///") {
+				proxy = "file:///" + strings.TrimPrefix(proxy, "file://")
+			}
+		}
+	}
+	return baseEnv("GOWORK=off", "GOTOOLCHAIN=local", "GOFLAGS=-mod=mod", "GOSUMDB=off", "GOPROXY="+proxy)
+}
@@ -0,0 +1,163 @@
+package examples
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// tsError matches a tsc diagnostic, capturing the code and message
+	tsError = regexp.MustCompile(`^\S+\(\d+,\d+\): error (TS\d+): (.*)$`)
+
+	// jsxTag matches JSX closing or self-closing tags
+	jsxTag = regexp.MustCompile(`</\w|/>`)
+)
+
+// tsMissingModule is the tsc error for imports that can't be resolved,
+// usually dependencies that aren't installed rather than a wrong example
+const tsMissingModule = "TS2307"
+
+// checkTypeScript type-checks a TypeScript example with tsc --noEmit,
+// mapping the resources' package names to their checkouts
+func checkTypeScript(ctx context.Context, b Block, opts Options) Result {
+	tsc := findTSC(opts.Dirs)
+	if tsc == "" {
+		return Result{Status: StatusSkipped, Message: "tsc is not installed"}
+	}
+
+	dir, err := os.MkdirTemp("", "btcx-example-*")
+	if err != nil {
+		return Result{Status: StatusSkipped, Message: fmt.Sprintf("failed to create scratch directory: %v", err)}
+	}
+	defer os.RemoveAll(dir)
+
+	file := "example.ts"
+	if jsxTag.MatchString(b.Code) {
+		file = "example.tsx"
+	}
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(stripEllipses(b.Code)), 0644); err != nil {
+		return Result{Status: StatusSkipped, Message: fmt.Sprintf("failed to write example: %v", err)}
+	}
+	if err := writeTSConfig(dir, file, opts.Dirs); err != nil {
+		return Result{Status: StatusSkipped, Message: err.Error()}
+	}
+
+	output, failed, err := run(ctx, opts.Timeout, dir, baseEnv(), tsc, "--noEmit", "-p", "tsconfig.json")
+	if err != nil {
+		return Result{Status: StatusSkipped, Message: err.Error()}
+	}
+	if !failed {
+		return Result{Status: StatusOK}
+	}
+
+	var messages []string
+	missing := false
+	for _, line := range strings.Split(output, "\n") {
+		m := tsError.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		if m[1] == tsMissingModule {
+			missing = true
+			continue
+		}
+		messages = append(messages, m[1]+": "+m[2])
+	}
+	switch {
+	case len(messages) > 0:
+		return Result{Status: StatusFailed, Message: firstLines(messages)}
+	case missing:
+		return Result{Status: StatusSkipped, Message: "dependencies aren't installed"}
+	default:
+		return Result{Status: StatusFailed, Message: firstLines(strings.Split(output, "\n"))}
+	}
+}
+
+// findTSC returns the tsc on PATH, or one installed in a resource
+func findTSC(dirs []string) string {
+	if path, err := exec.LookPath("tsc"); err == nil {
+		return path
+	}
+	for _, dir := range dirs {
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			continue
+		}
+		if path := findUp(real, filepath.Join("node_modules", ".bin", "tsc")); path != "" {
+			return path
+		}
+	}
+	return ""
+}
+
+// writeTSConfig writes a tsconfig.json that checks file and resolves each
+// resource's package name to its checkout
+func writeTSConfig(dir, file string, resourceDirs []string) error {
+	paths := make(map[string][]string)
+	var typeRoots []string
+	for _, resourceDir := range resourceDirs {
+		real, err := filepath.EvalSymlinks(resourceDir)
+		if err != nil {
+			continue
+		}
+		path := findUp(real, "package.json")
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var pkg struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(data, &pkg); err != nil || pkg.Name == "" {
+			continue
+		}
+		root := filepath.Dir(path)
+		paths[pkg.Name] = []string{root}
+		paths[pkg.Name+"/*"] = []string{filepath.Join(root, "*")}
+		if types := filepath.Join(root, "node_modules", "@types"); isDir(types) {
+			typeRoots = append(typeRoots, types)
+		}
+	}
+
+	options := map[string]interface{}{
+		"noEmit":           true,
+		"strict":           false,
+		"skipLibCheck":     true,
+		"target":           "ES2022",
+		"module":           "ESNext",
+		"moduleResolution": "node",
+		"moduleDetection":  "force",
+		"jsx":              "preserve",
+		"esModuleInterop":  true,
+		"baseUrl":          ".",
+		"paths":            paths,
+	}
+	if len(typeRoots) > 0 {
+		options["typeRoots"] = typeRoots
+	} else {
+		options["types"] = []string{}
+	}
+
+	if err := writeJSON(filepath.Join(dir, "tsconfig.json"), map[string]interface{}{
+		"compilerOptions": options,
+		"files":           []string{file},
+	}); err != nil {
+		return fmt.Errorf("failed to write tsconfig.json: %w", err)
+	}
+	return nil
+}
+
+// isDir reports whether path is a directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
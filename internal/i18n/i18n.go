@@ -0,0 +1,180 @@
+// Package i18n translates btcx's user-facing CLI and TUI messages
+//
+// Messages are looked up by key in the catalog of the active locale, which
+// falls back to English for keys it doesn't translate. English ships in
+// locales/en.json; a translation is a copy of it with the values translated,
+// either added to locales/ or dropped into the locales directory next to
+// the global config to try it without rebuilding.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultLocale is the locale of the built-in messages
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var builtin embed.FS
+
+// Catalog maps message keys to messages, which may contain fmt verbs
+type Catalog map[string]string
+
+var (
+	mu sync.RWMutex
+	// english is the fallback for keys missing from a translation
+	english = mustLoadBuiltin(DefaultLocale)
+	active  = english
+	locale  = DefaultLocale
+)
+
+// T returns the message for key in the active locale, formatted with args
+// Keys without a message are returned as is, so a missing message shows up
+// instead of an empty string
+func T(key string, args ...any) string {
+	mu.RLock()
+	msg, ok := active[key]
+	mu.RUnlock()
+	if !ok {
+		msg = key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Locale returns the active locale
+func Locale() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return locale
+}
+
+// Detect returns the locale to use: the configured one (output.locale or
+// BTCX_LOCALE) or else the one from LC_ALL, LC_MESSAGES or LANG
+func Detect(configured string) string {
+	candidates := []string{configured, os.Getenv("LC_ALL"), os.Getenv("LC_MESSAGES"), os.Getenv("LANG")}
+	for _, c := range candidates {
+		if l := Normalize(c); l != "" {
+			return l
+		}
+	}
+	return DefaultLocale
+}
+
+// Normalize turns a locale as found in LANG into the form catalogs are
+// named by, e.g. "pt-BR" and "pt_BR.UTF-8" become "pt_BR"; "C" and "POSIX"
+// become "en"
+func Normalize(l string) string {
+	if i := strings.IndexAny(l, ".@"); i >= 0 {
+		l = l[:i]
+	}
+	l = strings.ReplaceAll(strings.TrimSpace(l), "-", "_")
+	if l == "C" || l == "POSIX" {
+		return DefaultLocale
+	}
+	lang, region, ok := strings.Cut(l, "_")
+	if !ok {
+		return strings.ToLower(lang)
+	}
+	return strings.ToLower(lang) + "_" + strings.ToUpper(region)
+}
+
+// SetLocale activates the catalog for l, looking for l and then its
+// language alone ("pt_BR", then "pt") in dir and then among the built-in
+// catalogs. Locales without a catalog use English. It returns the locale
+// activated and an error only for a catalog that can't be parsed.
+func SetLocale(l, dir string) (string, error) {
+	l = Normalize(l)
+	candidates := []string{l}
+	if lang, _, ok := strings.Cut(l, "_"); ok {
+		candidates = append(candidates, lang)
+	}
+
+	for _, c := range candidates {
+		if c == "" || c == DefaultLocale {
+			break
+		}
+		catalog, err := loadCatalog(c, dir)
+		if err != nil {
+			return DefaultLocale, err
+		}
+		if catalog == nil {
+			continue
+		}
+
+		// Messages the translation lacks stay in English
+		merged := make(Catalog, len(english))
+		for k, v := range english {
+			merged[k] = v
+		}
+		for k, v := range catalog {
+			if v != "" {
+				merged[k] = v
+			}
+		}
+		activate(c, merged)
+		return c, nil
+	}
+
+	activate(DefaultLocale, english)
+	return DefaultLocale, nil
+}
+
+// Available returns the locales with a built-in catalog
+func Available() []string {
+	entries, _ := builtin.ReadDir("locales")
+	var locales []string
+	for _, e := range entries {
+		locales = append(locales, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	return locales
+}
+
+// activate makes catalog the active one
+func activate(l string, catalog Catalog) {
+	mu.Lock()
+	defer mu.Unlock()
+	locale, active = l, catalog
+}
+
+// loadCatalog reads the catalog for locale l from dir, or else from the
+// built-in catalogs; it returns nil when there is none
+func loadCatalog(l, dir string) (Catalog, error) {
+	name := l + ".json"
+	data, err := []byte(nil), os.ErrNotExist
+	if dir != "" {
+		data, err = os.ReadFile(filepath.Join(dir, name))
+	}
+	if err != nil {
+		if data, err = builtin.ReadFile("locales/" + name); err != nil {
+			return nil, nil
+		}
+	}
+
+	var catalog Catalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("failed to parse %s messages: %w", l, err)
+	}
+	return catalog, nil
+}
+
+// mustLoadBuiltin loads a built-in catalog that must exist
+func mustLoadBuiltin(l string) Catalog {
+	data, err := builtin.ReadFile("locales/" + l + ".json")
+	if err != nil {
+		panic(err)
+	}
+	var catalog Catalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		panic(fmt.Sprintf("invalid %s messages: %v", l, err))
+	}
+	return catalog
+}
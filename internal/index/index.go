@@ -0,0 +1,257 @@
+// Package index maintains a local chunk index of resource files for
+// hybrid (BM25 + hashed embedding) retrieval.
+//
+// Files are split into overlapping line windows. Each chunk stores its term
+// counts for BM25 and a small feature-hashed vector for fuzzy matching.
+// Indexes are persisted per resource and refreshed incrementally from the
+// resource manifest, so only changed files are re-chunked.
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/resource"
+)
+
+const (
+	// chunkLines is the number of lines per chunk
+	chunkLines = 40
+	// chunkStep is the distance between chunk starts (chunks overlap)
+	chunkStep = 30
+	// maxFileSize skips files larger than this
+	maxFileSize = 512 * 1024
+	// formatVersion is bumped when the on-disk layout changes
+	formatVersion = 1
+)
+
+// Chunk is an indexed window of a file
+type Chunk struct {
+	// Path is the file path relative to the resource root
+	Path string
+	// StartLine and EndLine are 1-based and inclusive
+	StartLine int
+	EndLine   int
+	// Terms maps each token to its count in the chunk
+	Terms map[string]int
+	// Length is the total number of tokens
+	Length int
+	// Vector is the normalized feature-hashed embedding
+	Vector []float32
+}
+
+// fileEntry holds the chunks of one file and the hash they were built from
+type fileEntry struct {
+	Hash   string
+	Chunks []Chunk
+}
+
+// Index is the chunk index of one resource
+type Index struct {
+	Version  int
+	Root     string
+	Manifest *resource.Manifest
+	Files    map[string]*fileEntry
+}
+
+// Chunks returns all chunks in the index
+func (idx *Index) Chunks() []*Chunk {
+	var chunks []*Chunk
+	for _, f := range idx.Files {
+		for i := range f.Chunks {
+			chunks = append(chunks, &f.Chunks[i])
+		}
+	}
+	return chunks
+}
+
+// Load returns the up-to-date index for the resource rooted at root,
+// refreshing the copy stored in dir
+func Load(ctx context.Context, dir, root string) (*Index, error) {
+	file := filepath.Join(dir, indexName(root))
+
+	prev, _ := readIndex(file)
+	if prev != nil && (prev.Version != formatVersion || prev.Root != root) {
+		prev = nil
+	}
+
+	var prevManifest *resource.Manifest
+	if prev != nil {
+		prevManifest = prev.Manifest
+	}
+	manifest, err := resource.BuildManifest(ctx, root, prevManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if prev != nil && prev.Manifest != nil && prev.Manifest.Digest == manifest.Digest {
+		return prev, nil
+	}
+
+	idx := &Index{
+		Version:  formatVersion,
+		Root:     root,
+		Manifest: manifest,
+		Files:    make(map[string]*fileEntry),
+	}
+
+	for path, entry := range manifest.Files {
+		if !indexable(path, entry.Size) {
+			continue
+		}
+		if prev != nil {
+			if old, ok := prev.Files[path]; ok && old.Hash == entry.Hash {
+				idx.Files[path] = old
+				continue
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		chunks, err := chunkFile(root, path)
+		if err != nil || len(chunks) == 0 {
+			continue
+		}
+		idx.Files[path] = &fileEntry{Hash: entry.Hash, Chunks: chunks}
+	}
+
+	if err := writeIndex(file, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// indexName returns the file name of the index for root
+func indexName(root string) string {
+	sum := sha256.Sum256([]byte(root))
+	return hex.EncodeToString(sum[:8]) + ".gob"
+}
+
+// indexable reports whether a file should be indexed
+func indexable(path string, size int64) bool {
+	if size == 0 || size > maxFileSize {
+		return false
+	}
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, ".") || part == "node_modules" || part == "vendor" {
+			return false
+		}
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".ico", ".pdf", ".zip", ".gz", ".tar",
+		".woff", ".woff2", ".ttf", ".eot", ".mp3", ".mp4", ".wasm", ".lock", ".sum":
+		return false
+	}
+	return true
+}
+
+// chunkFile splits a text file into overlapping line windows
+func chunkFile(root, path string) ([]Chunk, error) {
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip binary content
+	head := data
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), maxFileSize)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	// Include the path so file names match queries
+	pathTokens := Tokenize(path)
+
+	var chunks []Chunk
+	for start := 0; start < len(lines); start += chunkStep {
+		end := start + chunkLines
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		tokens := append(Tokenize(strings.Join(lines[start:end], "\n")), pathTokens...)
+		if len(tokens) == 0 {
+			continue
+		}
+
+		terms := make(map[string]int)
+		for _, t := range tokens {
+			terms[t]++
+		}
+		chunks = append(chunks, Chunk{
+			Path:      path,
+			StartLine: start + 1,
+			EndLine:   end,
+			Terms:     terms,
+			Length:    len(tokens),
+			Vector:    Embed(tokens),
+		})
+
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks, nil
+}
+
+// readIndex loads an index file
+func readIndex(file string) (*Index, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var idx Index
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("failed to decode index: %w", err)
+	}
+	return &idx, nil
+}
+
+// writeIndex saves an index file atomically
+func writeIndex(file string, idx *Index) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	if err := gob.NewEncoder(w).Encode(idx); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return os.Rename(tmp.Name(), file)
+}
@@ -0,0 +1,282 @@
+package index
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// embeddingDims is the size of the hashed embedding vector
+	embeddingDims = 256
+	// candidates is the number of results taken from each retriever
+	candidates = 50
+	// rrfK damps the contribution of lower ranks in reciprocal rank fusion
+	rrfK = 60
+	// BM25 parameters
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Result is a ranked chunk
+type Result struct {
+	*Chunk
+	// Resource is the index the chunk came from
+	Resource *Index
+	// Score is the fused reciprocal rank score
+	Score float64
+	// BM25Rank and VectorRank are 1-based ranks (0 if not retrieved)
+	BM25Rank   int
+	VectorRank int
+}
+
+// Tokenize splits text into lowercase tokens, also splitting camelCase
+// and snake_case identifiers into their parts
+func Tokenize(text string) []string {
+	var tokens []string
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+
+	for _, word := range words {
+		if len(word) < 2 {
+			continue
+		}
+		lower := strings.ToLower(word)
+		tokens = append(tokens, lower)
+
+		parts := splitIdentifier(word)
+		if len(parts) > 1 {
+			for _, p := range parts {
+				if len(p) >= 2 {
+					tokens = append(tokens, strings.ToLower(p))
+				}
+			}
+		}
+	}
+	return tokens
+}
+
+// splitIdentifier splits camelCase, PascalCase and snake_case words
+func splitIdentifier(word string) []string {
+	var parts []string
+	for _, piece := range strings.Split(word, "_") {
+		runes := []rune(piece)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			prev, cur := runes[i-1], runes[i]
+			next := rune(0)
+			if i+1 < len(runes) {
+				next = runes[i+1]
+			}
+			// Boundary before an uppercase letter that follows a lowercase
+			// letter, or that starts a word after an acronym (HTTPServer)
+			if unicode.IsUpper(cur) && (unicode.IsLower(prev) || (unicode.IsUpper(prev) && unicode.IsLower(next))) {
+				parts = append(parts, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			parts = append(parts, string(runes[start:]))
+		}
+	}
+	return parts
+}
+
+// Embed returns an L2-normalized feature-hashed embedding of the tokens
+// using the tokens themselves and their character trigrams, so related
+// spellings (handler/handlers/handle) land close together
+func Embed(tokens []string) []float32 {
+	vec := make([]float64, embeddingDims)
+	add := func(feature string, weight float64) {
+		h := fnv.New32a()
+		h.Write([]byte(feature))
+		sum := h.Sum32()
+		sign := 1.0
+		if sum&1 == 1 {
+			sign = -1.0
+		}
+		vec[(sum>>1)%embeddingDims] += sign * weight
+	}
+
+	for _, tok := range tokens {
+		add(tok, 1.0)
+		padded := []rune("^" + tok + "$")
+		for i := 0; i+3 <= len(padded); i++ {
+			add("#"+string(padded[i:i+3]), 0.25)
+		}
+	}
+
+	var norm float64
+	for _, v := range vec {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+
+	out := make([]float32, embeddingDims)
+	if norm == 0 {
+		return out
+	}
+	for i, v := range vec {
+		out[i] = float32(v / norm)
+	}
+	return out
+}
+
+// Search ranks chunks from the given indexes against the query with BM25
+// and embedding similarity, fused with reciprocal rank fusion
+// filter, if set, restricts results to matching chunks
+func Search(indexes []*Index, query string, limit int, filter func(*Index, *Chunk) bool) []Result {
+	queryTokens := Tokenize(query)
+	if len(queryTokens) == 0 {
+		return nil
+	}
+
+	type entry struct {
+		idx   *Index
+		chunk *Chunk
+	}
+	var corpus []entry
+	for _, idx := range indexes {
+		for _, c := range idx.Chunks() {
+			if filter == nil || filter(idx, c) {
+				corpus = append(corpus, entry{idx, c})
+			}
+		}
+	}
+	if len(corpus) == 0 {
+		return nil
+	}
+
+	// Document frequencies and average length for BM25
+	df := make(map[string]int)
+	totalLen := 0
+	unique := uniqueTokens(queryTokens)
+	for _, e := range corpus {
+		totalLen += e.chunk.Length
+		for _, t := range unique {
+			if e.chunk.Terms[t] > 0 {
+				df[t]++
+			}
+		}
+	}
+	avgLen := float64(totalLen) / float64(len(corpus))
+	n := float64(len(corpus))
+
+	bm25 := make([]float64, len(corpus))
+	vector := make([]float64, len(corpus))
+	queryVec := Embed(queryTokens)
+
+	for i, e := range corpus {
+		var score float64
+		for _, t := range unique {
+			tf := float64(e.chunk.Terms[t])
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log(1 + (n-float64(df[t])+0.5)/(float64(df[t])+0.5))
+			norm := tf + bm25K1*(1-bm25B+bm25B*float64(e.chunk.Length)/avgLen)
+			score += idf * tf * (bm25K1 + 1) / norm
+		}
+		bm25[i] = score
+
+		var dot float64
+		for j, v := range e.chunk.Vector {
+			dot += float64(v) * float64(queryVec[j])
+		}
+		vector[i] = dot
+	}
+
+	bm25Ranks := rank(bm25, candidates)
+	vectorRanks := rank(vector, candidates)
+
+	fused := make(map[int]*Result)
+	add := func(ranks []int, bm bool) {
+		for r, i := range ranks {
+			res, ok := fused[i]
+			if !ok {
+				res = &Result{Chunk: corpus[i].chunk, Resource: corpus[i].idx}
+				fused[i] = res
+			}
+			res.Score += 1.0 / float64(rrfK+r+1)
+			if bm {
+				res.BM25Rank = r + 1
+			} else {
+				res.VectorRank = r + 1
+			}
+		}
+	}
+	add(bm25Ranks, true)
+	add(vectorRanks, false)
+
+	results := make([]Result, 0, len(fused))
+	for _, res := range fused {
+		results = append(results, *res)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Path != results[j].Path {
+			return results[i].Path < results[j].Path
+		}
+		return results[i].StartLine < results[j].StartLine
+	})
+
+	return dedupeOverlaps(results, limit)
+}
+
+// rank returns the indexes of the top positive scores, best first
+func rank(scores []float64, top int) []int {
+	var ids []int
+	for i, s := range scores {
+		if s > 0 {
+			ids = append(ids, i)
+		}
+	}
+	sort.SliceStable(ids, func(a, b int) bool {
+		return scores[ids[a]] > scores[ids[b]]
+	})
+	if len(ids) > top {
+		ids = ids[:top]
+	}
+	return ids
+}
+
+// dedupeOverlaps drops chunks overlapping a better-ranked chunk of the
+// same file and returns at most limit results
+func dedupeOverlaps(results []Result, limit int) []Result {
+	var kept []Result
+	for _, r := range results {
+		overlaps := false
+		for _, k := range kept {
+			if k.Resource == r.Resource && k.Path == r.Path && r.StartLine <= k.EndLine && k.StartLine <= r.EndLine {
+				overlaps = true
+				break
+			}
+		}
+		if overlaps {
+			continue
+		}
+		kept = append(kept, r)
+		if len(kept) == limit {
+			break
+		}
+	}
+	return kept
+}
+
+// uniqueTokens returns the distinct tokens in order
+func uniqueTokens(tokens []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, t := range tokens {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -2,8 +2,11 @@ package provider
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/liushuangls/go-anthropic/v2"
 )
@@ -15,15 +18,24 @@ type AnthropicProvider struct {
 }
 
 // NewAnthropicProvider creates a new Anthropic provider
-func NewAnthropicProvider(apiKey, model string) (*AnthropicProvider, error) {
+// baseURL (including the /v1 path) is for gateways that expose the Anthropic
+// API on another host, and client (see NewHTTPClient) sends their headers;
+// both are optional
+func NewAnthropicProvider(apiKey, model, baseURL string, client *http.Client) (*AnthropicProvider, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("ANTHROPIC_API_KEY is required")
 	}
 
-	client := anthropic.NewClient(apiKey)
+	var opts []anthropic.ClientOption
+	if baseURL != "" {
+		opts = append(opts, anthropic.WithBaseURL(strings.TrimSuffix(baseURL, "/")))
+	}
+	if client != nil {
+		opts = append(opts, anthropic.WithHTTPClient(client))
+	}
 
 	return &AnthropicProvider{
-		client: client,
+		client: anthropic.NewClient(apiKey, opts...),
 		model:  model,
 	}, nil
 }
@@ -36,7 +48,7 @@ func (p *AnthropicProvider) Name() string {
 // Chat sends a chat request to Anthropic
 func (p *AnthropicProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
 	// Convert messages
-	messages := p.convertMessages(req.Messages)
+	messages := p.buildMessages(req)
 
 	// Convert tools
 	tools := p.convertTools(req.Tools)
@@ -53,17 +65,18 @@ func (p *AnthropicProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatRe
 	}
 
 	anthropicReq := anthropic.MessagesRequest{
-		Model:     anthropic.Model(model),
-		MaxTokens: maxTokens,
-		Messages:  messages,
+		Model:         anthropic.Model(model),
+		MaxTokens:     maxTokens,
+		Messages:      messages,
+		StopSequences: req.StopSequences,
 	}
+	applyThinking(&anthropicReq, req)
 
-	if req.System != "" {
-		anthropicReq.System = req.System
-	}
+	anthropicReq.MultiSystem = cachedSystem(req.System)
 
 	if len(tools) > 0 {
 		anthropicReq.Tools = tools
+		anthropicReq.ToolChoice = convertAnthropicToolChoice(req.ToolChoice)
 	}
 
 	// Make request
@@ -79,7 +92,7 @@ func (p *AnthropicProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatRe
 // StreamChat streams a chat response from Anthropic
 func (p *AnthropicProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
 	// Convert messages
-	messages := p.convertMessages(req.Messages)
+	messages := p.buildMessages(req)
 
 	// Convert tools
 	tools := p.convertTools(req.Tools)
@@ -107,9 +120,13 @@ func (p *AnthropicProvider) StreamChat(ctx context.Context, req *ChatRequest) (<
 
 		streamReq := anthropic.MessagesStreamRequest{
 			MessagesRequest: anthropic.MessagesRequest{
-				Model:     anthropic.Model(model),
-				MaxTokens: maxTokens,
-				Messages:  messages,
+				Model:         anthropic.Model(model),
+				MaxTokens:     maxTokens,
+				Messages:      messages,
+				StopSequences: req.StopSequences,
+			},
+			OnMessageStart: func(data anthropic.MessagesEventMessageStartData) {
+				usage = anthropicUsage(data.Message.Usage)
 			},
 			OnContentBlockStart: func(data anthropic.MessagesEventContentBlockStartData) {
 				if data.ContentBlock.Type == anthropic.MessagesContentTypeToolUse {
@@ -122,13 +139,20 @@ func (p *AnthropicProvider) StreamChat(ctx context.Context, req *ChatRequest) (<
 			},
 			OnContentBlockDelta: func(data anthropic.MessagesEventContentBlockDeltaData) {
 				switch data.Delta.Type {
-				case anthropic.MessagesContentTypeText:
+				case anthropic.MessagesContentTypeTextDelta, anthropic.MessagesContentTypeText:
 					if data.Delta.Text != nil && *data.Delta.Text != "" {
 						events <- StreamEvent{
 							Type:  StreamEventText,
 							Delta: *data.Delta.Text,
 						}
 					}
+				case anthropic.MessagesContentTypeThinkingDelta:
+					if t := data.Delta.MessageContentThinking; t != nil && t.Thinking != "" {
+						events <- StreamEvent{
+							Type:  StreamEventThinking,
+							Delta: t.Thinking,
+						}
+					}
 				case "input_json_delta":
 					if data.Delta.PartialJson != nil {
 						toolInput += *data.Delta.PartialJson
@@ -136,6 +160,13 @@ func (p *AnthropicProvider) StreamChat(ctx context.Context, req *ChatRequest) (<
 				}
 			},
 			OnContentBlockStop: func(data anthropic.MessagesEventContentBlockStopData, content anthropic.MessageContent) {
+				// The signature comes once the thinking is complete
+				if t := content.MessageContentThinking; content.Type == anthropic.MessagesContentTypeThinking && t != nil && t.Signature != "" {
+					events <- StreamEvent{
+						Type:      StreamEventThinking,
+						Signature: t.Signature,
+					}
+				}
 				if currentToolCall != nil {
 					currentToolCall.Arguments = json.RawMessage(toolInput)
 					events <- StreamEvent{
@@ -148,6 +179,7 @@ func (p *AnthropicProvider) StreamChat(ctx context.Context, req *ChatRequest) (<
 			},
 			OnMessageDelta: func(data anthropic.MessagesEventMessageDeltaData) {
 				usage.OutputTokens = data.Usage.OutputTokens
+				usage.TotalTokens = usage.InputTokens + usage.OutputTokens
 				stopReason = string(data.Delta.StopReason)
 			},
 			OnMessageStop: func(data anthropic.MessagesEventMessageStopData) {
@@ -165,12 +197,12 @@ func (p *AnthropicProvider) StreamChat(ctx context.Context, req *ChatRequest) (<
 			},
 		}
 
-		if req.System != "" {
-			streamReq.System = req.System
-		}
+		streamReq.MultiSystem = cachedSystem(req.System)
+		applyThinking(&streamReq.MessagesRequest, req)
 
 		if len(tools) > 0 {
 			streamReq.Tools = tools
+			streamReq.ToolChoice = convertAnthropicToolChoice(req.ToolChoice)
 		}
 
 		_, err := p.client.CreateMessagesStream(ctx, streamReq)
@@ -185,8 +217,77 @@ func (p *AnthropicProvider) StreamChat(ctx context.Context, req *ChatRequest) (<
 	return events, nil
 }
 
+// buildMessages converts a request's messages to Anthropic format, with a
+// cache breakpoint on the last message of the conversation so the next
+// iteration reads it from the prompt cache
+// The reminder changes from turn to turn, so it comes after the breakpoint
+func (p *AnthropicProvider) buildMessages(req *ChatRequest) []anthropic.Message {
+	messages := p.convertMessages(req.Messages, thinkingEnabled(req))
+	if n := len(messages); n > 0 {
+		if content := messages[n-1].Content; len(content) > 0 {
+			content[len(content)-1].SetCacheControl()
+		}
+	}
+	if req.Reminder != "" {
+		messages = append(messages, anthropic.NewUserTextMessage(req.Reminder))
+	}
+	return messages
+}
+
+// thinkingEnabled reports whether a request gets extended thinking, which
+// Anthropic doesn't allow with a forced tool choice
+func thinkingEnabled(req *ChatRequest) bool {
+	if req.ThinkingBudget <= 0 {
+		return false
+	}
+	return req.ToolChoice == nil || req.ToolChoice.Mode == ToolChoiceAuto || req.ToolChoice.Mode == ToolChoiceNone
+}
+
+// applyThinking turns on extended thinking for a request with a thinking
+// budget; the budget comes on top of the answer's max tokens
+func applyThinking(r *anthropic.MessagesRequest, req *ChatRequest) {
+	if !thinkingEnabled(req) {
+		return
+	}
+	r.Thinking = &anthropic.Thinking{
+		Type:         anthropic.ThinkingTypeEnabled,
+		BudgetTokens: req.ThinkingBudget,
+	}
+	r.MaxTokens += req.ThinkingBudget
+}
+
+// cachedSystem returns the system prompt as a cached block (nil if empty)
+// The system prompt is the same on every iteration of a question, and the
+// tools before it are cached along with it
+func cachedSystem(system string) []anthropic.MessageSystemPart {
+	if system == "" {
+		return nil
+	}
+	return []anthropic.MessageSystemPart{{
+		Type:         "text",
+		Text:         system,
+		CacheControl: &anthropic.MessageCacheControl{Type: anthropic.CacheControlTypeEphemeral},
+	}}
+}
+
+// anthropicUsage converts Anthropic's usage, where input tokens leave out
+// the tokens read from or written to the prompt cache
+// InputTokens is the whole prompt, as for other providers
+func anthropicUsage(u anthropic.MessagesUsage) Usage {
+	input := u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+	return Usage{
+		InputTokens:      input,
+		OutputTokens:     u.OutputTokens,
+		TotalTokens:      input + u.OutputTokens,
+		CacheReadTokens:  u.CacheReadInputTokens,
+		CacheWriteTokens: u.CacheCreationInputTokens,
+	}
+}
+
 // convertMessages converts our messages to Anthropic format
-func (p *AnthropicProvider) convertMessages(messages []Message) []anthropic.Message {
+// With thinking, signed thinking goes back ahead of the assistant's text and
+// tool calls, as Anthropic requires while a tool call is answered
+func (p *AnthropicProvider) convertMessages(messages []Message, thinking bool) []anthropic.Message {
 	var result []anthropic.Message
 
 	for _, msg := range messages {
@@ -196,6 +297,15 @@ func (p *AnthropicProvider) convertMessages(messages []Message) []anthropic.Mess
 
 		case "assistant":
 			var content []anthropic.MessageContent
+			if thinking && msg.ReasoningSignature != "" {
+				content = append(content, anthropic.MessageContent{
+					Type: anthropic.MessagesContentTypeThinking,
+					MessageContentThinking: &anthropic.MessageContentThinking{
+						Thinking:  msg.Reasoning,
+						Signature: msg.ReasoningSignature,
+					},
+				})
+			}
 			if msg.Content != "" {
 				content = append(content, anthropic.NewTextMessageContent(msg.Content))
 			}
@@ -208,13 +318,38 @@ func (p *AnthropicProvider) convertMessages(messages []Message) []anthropic.Mess
 			})
 
 		case "tool":
-			result = append(result, anthropic.NewToolResultsMessage(msg.ToolCallID, msg.Content, false))
+			toolResult := anthropic.NewToolResultsMessage(msg.ToolCallID, msg.Content, false)
+			// Images go inside the tool result, next to its text
+			for _, img := range msg.Images {
+				source := anthropic.NewMessageContentSource(anthropic.MessagesContentSourceTypeBase64,
+					img.MediaType, base64.StdEncoding.EncodeToString(img.Data))
+				tr := toolResult.Content[0].MessageContentToolResult
+				tr.Content = append(tr.Content, anthropic.NewImageMessageContent(source))
+			}
+			result = append(result, toolResult)
 		}
 	}
 
 	return result
 }
 
+// convertAnthropicToolChoice converts a tool choice to Anthropic format
+// (nil for the default)
+func convertAnthropicToolChoice(choice *ToolChoice) *anthropic.ToolChoice {
+	if choice == nil {
+		return nil
+	}
+	switch choice.Mode {
+	case ToolChoiceNone:
+		return &anthropic.ToolChoice{Type: "none"}
+	case ToolChoiceRequired:
+		return &anthropic.ToolChoice{Type: "any"}
+	case ToolChoiceTool:
+		return &anthropic.ToolChoice{Type: "tool", Name: choice.Name}
+	}
+	return nil
+}
+
 // convertTools converts our tools to Anthropic format
 func (p *AnthropicProvider) convertTools(tools []Tool) []anthropic.ToolDefinition {
 	var result []anthropic.ToolDefinition
@@ -235,11 +370,7 @@ func (p *AnthropicProvider) convertTools(tools []Tool) []anthropic.ToolDefinitio
 func (p *AnthropicProvider) convertResponse(resp *anthropic.MessagesResponse) *ChatResponse {
 	result := &ChatResponse{
 		StopReason: string(resp.StopReason),
-		Usage: Usage{
-			InputTokens:  resp.Usage.InputTokens,
-			OutputTokens: resp.Usage.OutputTokens,
-			TotalTokens:  resp.Usage.InputTokens + resp.Usage.OutputTokens,
-		},
+		Usage:      anthropicUsage(resp.Usage),
 	}
 
 	for _, block := range resp.Content {
@@ -248,6 +379,11 @@ func (p *AnthropicProvider) convertResponse(resp *anthropic.MessagesResponse) *C
 			if block.Text != nil {
 				result.Content += *block.Text
 			}
+		case anthropic.MessagesContentTypeThinking:
+			if t := block.MessageContentThinking; t != nil {
+				result.Reasoning += t.Thinking
+				result.ReasoningSignature = t.Signature
+			}
 		case anthropic.MessagesContentTypeToolUse:
 			if block.ID != "" && block.Name != "" {
 				result.ToolCalls = append(result.ToolCalls, ToolCall{
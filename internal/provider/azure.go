@@ -0,0 +1,207 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// azureScope is the Entra ID scope of Azure OpenAI
+const azureScope = "https://cognitiveservices.azure.com/.default"
+
+// azureTokenMargin is how long before expiry an Entra ID token is renewed
+const azureTokenMargin = 5 * time.Minute
+
+// NewAzureOpenAIProvider creates a provider for an Azure OpenAI deployment
+// endpoint is the resource endpoint (https://<resource>.openai.azure.com);
+// requests go to its deployment URL with the api-version query parameter
+func NewAzureOpenAIProvider(apiKey, model, endpoint string, client *http.Client, azure *config.AzureConfig) (*OpenAIProvider, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("baseUrl (the resource endpoint) is required")
+	}
+	if azure == nil {
+		azure = &config.AzureConfig{}
+	}
+	deployment := azure.Deployment
+	if deployment == "" {
+		deployment = model
+	}
+	apiVersion := azure.APIVersion
+	if apiVersion == "" {
+		apiVersion = config.DefaultAzureAPIVersion
+	}
+
+	baseURL := strings.TrimRight(endpoint, "/") + "/openai/deployments/" + url.PathEscape(deployment) + "/"
+	opts := []option.RequestOption{
+		option.WithBaseURL(baseURL),
+		option.WithQueryAdd("api-version", apiVersion),
+		// Azure authenticates with its own header, not OPENAI_API_KEY
+		option.WithHeaderDel("Authorization"),
+	}
+	if client != nil {
+		opts = append(opts, option.WithHTTPClient(client))
+	}
+
+	switch azure.Auth {
+	case "", config.AzureAuthKey:
+		if apiKey == "" {
+			return nil, fmt.Errorf("AZURE_OPENAI_API_KEY is required (or set azure.auth: entra)")
+		}
+		opts = append(opts, option.WithHeader("Api-Key", apiKey))
+	case config.AzureAuthEntra:
+		tokens := newAzureTokenSource(azure, withoutHeaders(client))
+		opts = append(opts, option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			token, err := tokens.token(req.Context())
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			return next(req)
+		}))
+	default:
+		return nil, fmt.Errorf("unknown azure.auth %q", azure.Auth)
+	}
+
+	return &OpenAIProvider{
+		client:  openai.NewClient(opts...),
+		model:   model,
+		baseURL: baseURL,
+		name:    string(config.ProviderAzureOpenAI),
+	}, nil
+}
+
+// azureTokenSource fetches Microsoft Entra ID tokens for Azure OpenAI and
+// reuses them until shortly before they expire
+// A service principal's client secret is used when AZURE_CLIENT_SECRET is
+// set, otherwise the Azure CLI login
+type azureTokenSource struct {
+	tenantID string
+	clientID string
+	secret   string
+	// client makes token requests, through the model's proxy
+	client *http.Client
+
+	mu      sync.Mutex
+	current string
+	expires time.Time
+}
+
+// newAzureTokenSource creates a token source for the config's service
+// principal, falling back to the AZURE_* environment variables
+func newAzureTokenSource(azure *config.AzureConfig, client *http.Client) *azureTokenSource {
+	s := &azureTokenSource{
+		tenantID: azure.TenantID,
+		clientID: azure.ClientID,
+		secret:   os.Getenv("AZURE_CLIENT_SECRET"),
+		client:   client,
+	}
+	if s.tenantID == "" {
+		s.tenantID = os.Getenv("AZURE_TENANT_ID")
+	}
+	if s.clientID == "" {
+		s.clientID = os.Getenv("AZURE_CLIENT_ID")
+	}
+	return s
+}
+
+// token returns a valid access token, fetching a new one if needed
+func (s *azureTokenSource) token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != "" && time.Until(s.expires) > azureTokenMargin {
+		return s.current, nil
+	}
+
+	var token string
+	var expires time.Time
+	var err error
+	if s.secret != "" {
+		token, expires, err = s.clientSecretToken(ctx)
+	} else {
+		token, expires, err = s.cliToken(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+	s.current, s.expires = token, expires
+	return token, nil
+}
+
+// clientSecretToken gets a token with the OAuth client credentials flow
+func (s *azureTokenSource) clientSecretToken(ctx context.Context) (string, time.Time, error) {
+	if s.tenantID == "" || s.clientID == "" {
+		return "", time.Time{}, fmt.Errorf("entra auth with AZURE_CLIENT_SECRET needs a tenant and client ID (azure.tenantId/clientId or AZURE_TENANT_ID/AZURE_CLIENT_ID)")
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {s.clientID},
+		"client_secret": {s.secret},
+		"scope":         {azureScope},
+	}
+	endpoint := "https://login.microsoftonline.com/" + url.PathEscape(s.tenantID) + "/oauth2/v2.0/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to get Entra ID token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to parse Entra ID token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("failed to get Entra ID token (%s): %s", resp.Status, body.ErrorDescription)
+	}
+	return body.AccessToken, time.Now().Add(time.Duration(body.ExpiresIn) * time.Second), nil
+}
+
+// cliToken gets a token from the Azure CLI's login
+func (s *azureTokenSource) cliToken(ctx context.Context) (string, time.Time, error) {
+	args := []string{"account", "get-access-token", "--scope", azureScope, "--output", "json"}
+	if s.tenantID != "" {
+		args = append(args, "--tenant", s.tenantID)
+	}
+	out, err := exec.CommandContext(ctx, "az", args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			err = fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", time.Time{}, fmt.Errorf("failed to get Entra ID token from the Azure CLI (run az login, or set AZURE_CLIENT_SECRET): %w", err)
+	}
+
+	var body struct {
+		AccessToken string `json:"accessToken"`
+		// ExpiresOn is the Unix expiry time in newer CLI versions
+		ExpiresOn int64 `json:"expires_on"`
+	}
+	if err := json.Unmarshal(out, &body); err != nil || body.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("failed to parse Azure CLI token output")
+	}
+	expires := time.Now().Add(30 * time.Minute)
+	if body.ExpiresOn > 0 {
+		expires = time.Unix(body.ExpiresOn, 0)
+	}
+	return body.AccessToken, expires, nil
+}
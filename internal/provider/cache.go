@@ -0,0 +1,241 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultResponseCacheTTL is how long cached responses are reused
+const DefaultResponseCacheTTL = 7 * 24 * time.Hour
+
+// savedOutput matches the note with the path of a truncated output the tool
+// registry adds; the path is in the thread's directory, so it's left out of
+// cache keys
+var savedOutput = regexp.MustCompile(`\[Full output saved to: [^\]]*\]`)
+
+// outputID matches the ids of saved outputs, e.g. grep-1a2b3c4d.txt, in the
+// notes of truncated outputs and in read_output calls
+// They are random, so cache keys number them in order of appearance
+var outputID = regexp.MustCompile(`\b[\w-]+-[0-9a-f]{8}\.txt\b`)
+
+// cachedProvider answers repeated identical requests from disk
+type cachedProvider struct {
+	Provider
+	scope string
+	dir   string
+	ttl   time.Duration
+}
+
+// cachedResponse is a response saved in the cache
+type cachedResponse struct {
+	Scope    string        `json:"scope"`
+	Model    string        `json:"model"`
+	Created  time.Time     `json:"created"`
+	Response *ChatResponse `json:"response"`
+}
+
+// NewCached wraps a provider so requests identical to one made within ttl
+// are answered from dir instead of the model
+// scope tells apart endpoints serving the same model names, e.g. the
+// provider type and base URL; cached answers report no token usage, since
+// nothing was billed
+func NewCached(p Provider, scope, dir string, ttl time.Duration) Provider {
+	if ttl <= 0 {
+		ttl = DefaultResponseCacheTTL
+	}
+	pruneResponses(dir, ttl)
+	return &cachedProvider{Provider: p, scope: scope, dir: dir, ttl: ttl}
+}
+
+// Chat returns the cached response for the request, or asks the model and
+// caches its response
+func (c *cachedProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	key, err := c.key(req)
+	if err != nil {
+		return c.Provider.Chat(ctx, req)
+	}
+	if resp := c.load(key); resp != nil {
+		return resp, nil
+	}
+
+	resp, err := c.Provider.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, req.Model, resp)
+	return resp, nil
+}
+
+// StreamChat replays the cached response for the request, or streams the
+// model's response and caches it once complete
+func (c *cachedProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
+	key, err := c.key(req)
+	if err != nil {
+		return c.Provider.StreamChat(ctx, req)
+	}
+	if resp := c.load(key); resp != nil {
+		// Cached responses cost nothing
+		return replay(resp, &Usage{}), nil
+	}
+
+	events, err := c.Provider.StreamChat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+
+		resp := &ChatResponse{}
+		failed := false
+		for event := range events {
+			collect(resp, event)
+			switch event.Type {
+			case StreamEventDone:
+				if !failed {
+					c.store(key, req.Model, resp)
+				}
+			case StreamEventError:
+				failed = true
+			}
+
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// replay streams a stored response, ending with usage
+func replay(resp *ChatResponse, usage *Usage) <-chan StreamEvent {
+	events := make(chan StreamEvent, len(resp.ToolCalls)+3)
+	if resp.Reasoning != "" {
+		events <- StreamEvent{Type: StreamEventReasoning, Delta: resp.Reasoning, Signature: resp.ReasoningSignature}
+	}
+	if resp.Content != "" {
+		events <- StreamEvent{Type: StreamEventText, Delta: resp.Content}
+	}
+	for i := range resp.ToolCalls {
+		events <- StreamEvent{Type: StreamEventToolCall, ToolCall: &resp.ToolCalls[i]}
+	}
+	events <- StreamEvent{Type: StreamEventDone, StopReason: resp.StopReason, Usage: usage}
+	close(events)
+	return events
+}
+
+// key hashes everything that affects the response
+func (c *cachedProvider) key(req *ChatRequest) (string, error) {
+	data, err := json.Marshal(struct {
+		Scope   string
+		Request *ChatRequest
+	}{c.scope, req})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(normalizeOutputIDs(savedOutput.ReplaceAllString(string(data), ""))))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// normalizeOutputIDs replaces each saved output id with output-1, output-2,
+// ... in order of appearance, so a read_output call matches whatever id its
+// output got
+func normalizeOutputIDs(s string) string {
+	ids := make(map[string]string)
+	return outputID.ReplaceAllStringFunc(s, func(id string) string {
+		n, ok := ids[id]
+		if !ok {
+			n = fmt.Sprintf("output-%d", len(ids)+1)
+			ids[id] = n
+		}
+		return n
+	})
+}
+
+// load returns the cached response for key (nil if missing or expired)
+func (c *cachedProvider) load(key string) *ChatResponse {
+	path := filepath.Join(c.dir, key+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var entry cachedResponse
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Response == nil {
+		return nil
+	}
+	if time.Since(entry.Created) > c.ttl {
+		os.Remove(path)
+		return nil
+	}
+
+	resp := *entry.Response
+	resp.Usage = Usage{}
+	return &resp
+}
+
+// store saves a response under key
+// Failures only cost a cache miss later, so they are reported as warnings
+func (c *cachedProvider) store(key, model string, resp *ChatResponse) {
+	if err := c.write(key, model, resp); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to cache response: %v\n", err)
+	}
+}
+
+// write saves a response atomically, so concurrent runs never read half a file
+func (c *cachedProvider) write(key, model string, resp *ChatResponse) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cachedResponse{
+		Scope:    c.scope,
+		Model:    model,
+		Created:  time.Now(),
+		Response: resp,
+	})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(c.dir, key+".json"))
+}
+
+// pruneResponses removes cached responses older than ttl
+func pruneResponses(dir string, ttl time.Duration) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		if info, err := e.Info(); err == nil && time.Since(info.ModTime()) > ttl {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/packages/respjson"
+)
+
+// DefaultDeepSeekBaseURL is the DeepSeek API endpoint
+const DefaultDeepSeekBaseURL = "https://api.deepseek.com"
+
+// NewDeepSeekProvider creates a provider for the DeepSeek API
+// DeepSeek speaks the OpenAI API, except that reasoning models
+// (deepseek-reasoner) return their thinking in reasoning_content next to the
+// answer; it is kept out of the answer, and sent back only while the model
+// is still calling tools for the same question, as the API requires
+func NewDeepSeekProvider(apiKey, model, baseURL string, client *http.Client) (*OpenAIProvider, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("DEEPSEEK_API_KEY is required")
+	}
+	if baseURL == "" {
+		baseURL = DefaultDeepSeekBaseURL
+	}
+
+	opts := []option.RequestOption{
+		option.WithAPIKey(apiKey),
+		option.WithBaseURL(baseURL),
+	}
+	if client != nil {
+		opts = append(opts, option.WithHTTPClient(client))
+	}
+
+	return &OpenAIProvider{
+		client:   openai.NewClient(opts...),
+		model:    model,
+		baseURL:  baseURL,
+		name:     string(config.ProviderDeepSeek),
+		deepSeek: true,
+	}, nil
+}
+
+// reasoningContent returns the reasoning_content field of a DeepSeek
+// message or delta ("" if there is none)
+func reasoningContent(extra map[string]respjson.Field) string {
+	field, ok := extra["reasoning_content"]
+	if !ok || !field.Valid() {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal([]byte(field.Raw()), &text); err != nil {
+		return ""
+	}
+	return text
+}
+
+// lastQuestion returns the index of the last user message (-1 if none)
+// DeepSeek takes back the reasoning of the assistant messages after it
+func lastQuestion(messages []Message) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/config"
+)
+
+// DefaultLocalPorts are the ports local model servers listen on by default:
+// LM Studio, llama.cpp's llama-server and Ollama
+var DefaultLocalPorts = []int{1234, 8080, 11434}
+
+// probeTimeout bounds each request to a local server
+const probeTimeout = 2 * time.Second
+
+// LocalServer is a model server found on localhost
+type LocalServer struct {
+	// Kind is the server software ("ollama", "lmstudio", "llama.cpp" or
+	// "openai-compatible" if unknown)
+	Kind string
+
+	// BaseURL is the OpenAI-compatible API root
+	BaseURL string
+
+	// Models are the model IDs the server offers
+	Models []string
+}
+
+// DiscoverLocal probes localhost ports for OpenAI-compatible model servers
+// Ports that don't answer, or answer with something else, are skipped
+func DiscoverLocal(ctx context.Context, ports []int) []LocalServer {
+	client := &http.Client{Timeout: probeTimeout}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var servers []LocalServer
+	for _, port := range ports {
+		wg.Add(1)
+		go func(port int) {
+			defer wg.Done()
+			server, ok := probeLocal(ctx, client, port)
+			if !ok {
+				return
+			}
+			mu.Lock()
+			servers = append(servers, server)
+			mu.Unlock()
+		}(port)
+	}
+	wg.Wait()
+
+	sort.Slice(servers, func(i, j int) bool { return servers[i].BaseURL < servers[j].BaseURL })
+	return servers
+}
+
+// probeLocal lists the models of the server on a port
+func probeLocal(ctx context.Context, client *http.Client, port int) (LocalServer, bool) {
+	root := fmt.Sprintf("http://127.0.0.1:%d", port)
+
+	var list struct {
+		Data []struct {
+			ID      string `json:"id"`
+			OwnedBy string `json:"owned_by"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, client, root+"/v1/models", &list); err != nil || list.Data == nil {
+		return LocalServer{}, false
+	}
+
+	server := LocalServer{Kind: "openai-compatible", BaseURL: root + "/v1"}
+	for _, m := range list.Data {
+		server.Models = append(server.Models, m.ID)
+		if m.OwnedBy == "llamacpp" {
+			server.Kind = "llama.cpp"
+		}
+	}
+	sort.Strings(server.Models)
+
+	// Ollama and LM Studio also answer on their native APIs
+	var version struct {
+		Version string `json:"version"`
+	}
+	if err := getJSON(ctx, client, root+"/api/version", &version); err == nil && version.Version != "" {
+		server.Kind = "ollama"
+	} else if err := getJSON(ctx, client, root+"/api/v0/models", &json.RawMessage{}); err == nil && server.Kind == "openai-compatible" {
+		server.Kind = "lmstudio"
+	}
+
+	return server, true
+}
+
+// getJSON fetches a URL and decodes the JSON response
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// ModelConfigs suggests a model config for each of the server's models
+// Ollama and llama.cpp models use their own providers; others use
+// openai-compatible with a placeholder key, since local servers don't check it
+func (s LocalServer) ModelConfigs() []config.ModelConfig {
+	configs := make([]config.ModelConfig, 0, len(s.Models))
+	for _, model := range s.Models {
+		m := config.ModelConfig{
+			Name:     localModelName(model),
+			Provider: config.ProviderOpenAICompatible,
+			Model:    model,
+			BaseURL:  s.BaseURL,
+			APIKey:   "local",
+		}
+		switch s.Kind {
+		case "ollama":
+			m.Provider = config.ProviderOllama
+			m.APIKey = ""
+			if m.BaseURL == config.DefaultOllamaBaseURL || m.BaseURL == "http://127.0.0.1:11434/v1" {
+				m.BaseURL = ""
+			}
+		case "llama.cpp":
+			m.Provider = config.ProviderLlamaCpp
+			m.APIKey = ""
+			m.BaseURL = strings.TrimSuffix(m.BaseURL, "/v1")
+			if m.BaseURL == config.DefaultLlamaCppBaseURL || m.BaseURL == "http://127.0.0.1:8080" {
+				m.BaseURL = ""
+			}
+		}
+		configs = append(configs, m)
+	}
+	return configs
+}
+
+// localModelName turns a model ID into a config name, e.g.
+// "qwen2.5-coder:14b" -> "qwen2.5-coder-14b", "models/llama.gguf" -> "llama"
+func localModelName(id string) string {
+	name := id
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(name, ".gguf")
+	name = strings.TrimSuffix(name, ":latest")
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '-'
+		}
+	}, name)
+	if name = strings.Trim(name, "-"); name == "" {
+		return "local"
+	}
+	return name
+}
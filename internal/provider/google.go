@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"sync"
 
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
@@ -11,28 +13,55 @@ import (
 
 // GoogleProvider implements the Provider interface for Google AI
 type GoogleProvider struct {
-	client *genai.Client
-	model  string
+	apiKey     string
+	model      string
+	baseURL    string
+	httpClient *http.Client
+
+	// The client dials on creation, so it is created lazily on first use
+	clientOnce sync.Once
+	client     *genai.Client
+	clientErr  error
 }
 
 // NewGoogleProvider creates a new Google AI provider
-func NewGoogleProvider(apiKey, model string) (*GoogleProvider, error) {
+// The underlying client is not created until the first request
+// baseURL is for gateways that expose the Gemini API on another host, and
+// client (see NewHTTPClient) sends their headers; both are optional
+func NewGoogleProvider(apiKey, model, baseURL string, client *http.Client) (*GoogleProvider, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("GOOGLE_API_KEY is required")
 	}
 
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Google AI client: %w", err)
-	}
-
 	return &GoogleProvider{
-		client: client,
-		model:  model,
+		apiKey:     apiKey,
+		model:      model,
+		baseURL:    baseURL,
+		httpClient: client,
 	}, nil
 }
 
+// getClient returns the Google AI client, creating it on first use
+func (p *GoogleProvider) getClient() (*genai.Client, error) {
+	p.clientOnce.Do(func() {
+		opts := []option.ClientOption{option.WithAPIKey(p.apiKey)}
+		if p.baseURL != "" {
+			opts = append(opts, option.WithEndpoint(p.baseURL))
+		}
+		if p.httpClient != nil {
+			// A custom HTTP client replaces the client's own auth, so the
+			// key is sent as a header along with the configured ones
+			client := withHeaders(p.httpClient, map[string]string{"x-goog-api-key": p.apiKey})
+			opts = append(opts, option.WithHTTPClient(client))
+		}
+		p.client, p.clientErr = genai.NewClient(context.Background(), opts...)
+		if p.clientErr != nil {
+			p.clientErr = fmt.Errorf("failed to create Google AI client: %w", p.clientErr)
+		}
+	})
+	return p.client, p.clientErr
+}
+
 // Name returns the provider name
 func (p *GoogleProvider) Name() string {
 	return "google"
@@ -40,10 +69,16 @@ func (p *GoogleProvider) Name() string {
 
 // Chat sends a chat request to Google AI
 func (p *GoogleProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
-	model := p.client.GenerativeModel(req.Model)
-	if req.Model == "" {
-		model = p.client.GenerativeModel(p.model)
+	client, err := p.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	modelName := req.Model
+	if modelName == "" {
+		modelName = p.model
 	}
+	model := client.GenerativeModel(modelName)
 
 	// Configure model
 	if req.System != "" {
@@ -52,9 +87,14 @@ func (p *GoogleProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatRespo
 		}
 	}
 
+	if len(req.StopSequences) > 0 {
+		model.StopSequences = req.StopSequences
+	}
+
 	// Add tools
 	if len(req.Tools) > 0 {
 		model.Tools = p.convertTools(req.Tools)
+		model.ToolConfig = convertGoogleToolChoice(req.ToolChoice)
 	}
 
 	// Start chat session
@@ -74,15 +114,8 @@ func (p *GoogleProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatRespo
 		// Handle tool results
 		if req.Messages[i].Role == "tool" {
 			lastContent = &genai.Content{
-				Parts: []genai.Part{
-					genai.FunctionResponse{
-						Name: req.Messages[i].ToolCallID, // Use tool call ID as function name
-						Response: map[string]any{
-							"result": req.Messages[i].Content,
-						},
-					},
-				},
-				Role: "user",
+				Parts: toolResultParts(req.Messages[i]),
+				Role:  "user",
 			}
 			break
 		}
@@ -91,6 +124,9 @@ func (p *GoogleProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatRespo
 	if lastContent == nil {
 		return nil, fmt.Errorf("no user message found")
 	}
+	if req.Reminder != "" {
+		lastContent.Parts = append(lastContent.Parts, genai.Text(req.Reminder))
+	}
 
 	// Send message
 	resp, err := cs.SendMessage(ctx, lastContent.Parts...)
@@ -104,11 +140,17 @@ func (p *GoogleProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatRespo
 
 // StreamChat streams a chat response from Google AI
 func (p *GoogleProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
-	model := p.client.GenerativeModel(req.Model)
-	if req.Model == "" {
-		model = p.client.GenerativeModel(p.model)
+	client, err := p.getClient()
+	if err != nil {
+		return nil, err
 	}
 
+	modelName := req.Model
+	if modelName == "" {
+		modelName = p.model
+	}
+	model := client.GenerativeModel(modelName)
+
 	// Configure model
 	if req.System != "" {
 		model.SystemInstruction = &genai.Content{
@@ -116,9 +158,14 @@ func (p *GoogleProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-ch
 		}
 	}
 
+	if len(req.StopSequences) > 0 {
+		model.StopSequences = req.StopSequences
+	}
+
 	// Add tools
 	if len(req.Tools) > 0 {
 		model.Tools = p.convertTools(req.Tools)
+		model.ToolConfig = convertGoogleToolChoice(req.ToolChoice)
 	}
 
 	// Start chat session
@@ -138,15 +185,8 @@ func (p *GoogleProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-ch
 		// Handle tool results
 		if req.Messages[i].Role == "tool" {
 			lastContent = &genai.Content{
-				Parts: []genai.Part{
-					genai.FunctionResponse{
-						Name: req.Messages[i].ToolCallID,
-						Response: map[string]any{
-							"result": req.Messages[i].Content,
-						},
-					},
-				},
-				Role: "user",
+				Parts: toolResultParts(req.Messages[i]),
+				Role:  "user",
 			}
 			break
 		}
@@ -155,6 +195,9 @@ func (p *GoogleProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-ch
 	if lastContent == nil {
 		return nil, fmt.Errorf("no user message found")
 	}
+	if req.Reminder != "" {
+		lastContent.Parts = append(lastContent.Parts, genai.Text(req.Reminder))
+	}
 
 	events := make(chan StreamEvent)
 
@@ -253,15 +296,8 @@ func (p *GoogleProvider) convertHistory(messages []Message) []*genai.Content {
 
 		case "tool":
 			history = append(history, &genai.Content{
-				Parts: []genai.Part{
-					genai.FunctionResponse{
-						Name: msg.ToolCallID,
-						Response: map[string]any{
-							"result": msg.Content,
-						},
-					},
-				},
-				Role: "user",
+				Parts: toolResultParts(msg),
+				Role:  "user",
 			})
 		}
 	}
@@ -269,6 +305,25 @@ func (p *GoogleProvider) convertHistory(messages []Message) []*genai.Content {
 	return history
 }
 
+// convertGoogleToolChoice converts a tool choice to Google AI format
+// (nil for the default)
+func convertGoogleToolChoice(choice *ToolChoice) *genai.ToolConfig {
+	if choice == nil {
+		return nil
+	}
+	config := &genai.FunctionCallingConfig{Mode: genai.FunctionCallingAuto}
+	switch choice.Mode {
+	case ToolChoiceNone:
+		config.Mode = genai.FunctionCallingNone
+	case ToolChoiceRequired:
+		config.Mode = genai.FunctionCallingAny
+	case ToolChoiceTool:
+		config.Mode = genai.FunctionCallingAny
+		config.AllowedFunctionNames = []string{choice.Name}
+	}
+	return &genai.ToolConfig{FunctionCallingConfig: config}
+}
+
 // convertTools converts our tools to Google AI format
 func (p *GoogleProvider) convertTools(tools []Tool) []*genai.Tool {
 	var funcs []*genai.FunctionDeclaration
@@ -377,3 +432,20 @@ func (p *GoogleProvider) convertResponse(resp *genai.GenerateContentResponse) *C
 
 	return result
 }
+
+// toolResultParts converts a tool result to a function response, followed by
+// any images the tool returned
+func toolResultParts(msg Message) []genai.Part {
+	parts := []genai.Part{
+		genai.FunctionResponse{
+			Name: msg.ToolCallID, // Use tool call ID as function name
+			Response: map[string]any{
+				"result": msg.Content,
+			},
+		},
+	}
+	for _, img := range msg.Images {
+		parts = append(parts, genai.Blob{MIMEType: img.MediaType, Data: img.Data})
+	}
+	return parts
+}
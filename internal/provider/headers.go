@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/config"
+)
+
+// headerTransport adds fixed headers to every request, e.g. for LLM gateways
+// that require their own auth or routing headers
+type headerTransport struct {
+	headers map[string]string
+	base    http.RoundTripper
+}
+
+// RoundTrip sets the headers on a copy of the request and sends it
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// NewHTTPClient returns the HTTP client for a model's requests: headers are
+// sent with every request, through the configured proxy (default: the
+// HTTPS_PROXY environment) and trusting the CA bundle besides the system CAs
+// A non-zero connectTimeout bounds dialing and the TLS handshake
+// It returns nil when nothing differs from the default, so callers keep
+// their SDK's default client
+func NewHTTPClient(headers map[string]string, httpCfg config.HTTPConfig, connectTimeout time.Duration) (*http.Client, error) {
+	if len(headers) == 0 && httpCfg.Proxy == "" && httpCfg.CABundle == "" && connectTimeout == 0 {
+		return nil, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if connectTimeout > 0 {
+		dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+		transport.DialContext = dialer.DialContext
+		transport.TLSHandshakeTimeout = connectTimeout
+	}
+	transport.Proxy = http.ProxyFromEnvironment
+	if httpCfg.Proxy != "" {
+		proxyURL, err := url.Parse(httpCfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if httpCfg.CABundle != "" {
+		pool, err := caPool(httpCfg.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	var rt http.RoundTripper = transport
+	if len(headers) > 0 {
+		rt = &headerTransport{headers: headers, base: transport}
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+// Endpoint returns the base URL of a model's requests ("" for plugins,
+// which don't make HTTP requests themselves)
+func Endpoint(m *config.ModelConfig) string {
+	if m.BaseURL != "" {
+		return m.BaseURL
+	}
+	switch m.Provider {
+	case config.ProviderAnthropic:
+		return "https://api.anthropic.com"
+	case config.ProviderOpenAI:
+		return "https://api.openai.com/v1"
+	case config.ProviderDeepSeek:
+		return DefaultDeepSeekBaseURL
+	case config.ProviderGoogle:
+		if m.Vertex != nil {
+			return vertexEndpoint(vertexLocation(m.Vertex))
+		}
+		return "https://generativelanguage.googleapis.com"
+	case config.ProviderOllama:
+		return config.DefaultOllamaBaseURL
+	case config.ProviderLlamaCpp:
+		return config.DefaultLlamaCppBaseURL
+	}
+	return ""
+}
+
+// ProxyFor returns the proxy that requests to endpoint go through with
+// httpCfg, from its proxy or the environment ("" when they connect
+// directly), and checks that its CA bundle loads
+func ProxyFor(endpoint string, httpCfg config.HTTPConfig) (string, error) {
+	if httpCfg.CABundle != "" {
+		if _, err := caPool(httpCfg.CABundle); err != nil {
+			return "", err
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint: %w", err)
+	}
+	proxy := http.ProxyFromEnvironment
+	if httpCfg.Proxy != "" {
+		proxyURL, err := url.Parse(httpCfg.Proxy)
+		if err != nil {
+			return "", fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+	proxyURL, err := proxy(req)
+	if err != nil || proxyURL == nil {
+		return "", err
+	}
+	return proxyURL.Redacted(), nil
+}
+
+// caPool returns the system CAs plus the certificates of a PEM file
+func caPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in CA bundle %s", path)
+	}
+	return pool, nil
+}
+
+// withoutHeaders returns client without the headers added by NewHTTPClient,
+// for requests to other hosts than the model's (e.g. OAuth token endpoints)
+func withoutHeaders(client *http.Client) *http.Client {
+	if client == nil {
+		return http.DefaultClient
+	}
+	if t, ok := client.Transport.(*headerTransport); ok {
+		copied := *client
+		copied.Transport = t.base
+		return &copied
+	}
+	return client
+}
+
+// withHeaders returns a client sending extra headers on top of client's
+func withHeaders(client *http.Client, headers map[string]string) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	copied := *client
+	copied.Transport = &headerTransport{headers: headers, base: base}
+	return &copied
+}
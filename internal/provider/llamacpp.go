@@ -0,0 +1,636 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/config"
+)
+
+// maxLlamaCppLine caps a single line of llama-server's event stream
+const maxLlamaCppLine = 4 * 1024 * 1024
+
+// LlamaCppProvider implements the Provider interface for llama.cpp's
+// llama-server, on its OpenAI-style /v1/chat/completions or its native
+// /completion endpoint
+// With grammar tool calls the tools are described in the prompt and the
+// reply is constrained to JSON naming either tool calls or the answer, so
+// models without function calling in their chat template can drive tools
+type LlamaCppProvider struct {
+	client  *http.Client
+	apiKey  string
+	model   string
+	baseURL string
+	options *config.LlamaCppConfig
+}
+
+// NewLlamaCppProvider creates a new llama.cpp provider
+// client (see NewHTTPClient) and options are optional; apiKey is only
+// needed when llama-server runs with --api-key
+func NewLlamaCppProvider(apiKey, model, baseURL string, client *http.Client, options *config.LlamaCppConfig) (*LlamaCppProvider, error) {
+	if baseURL == "" {
+		baseURL = config.DefaultLlamaCppBaseURL
+	}
+	if options == nil {
+		options = &config.LlamaCppConfig{}
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &LlamaCppProvider{
+		client: client,
+		apiKey: apiKey,
+		model:  model,
+		// Configs written for openai-compatible end in /v1, which is dropped
+		baseURL: strings.TrimSuffix(strings.TrimRight(baseURL, "/"), "/v1"),
+		options: options,
+	}, nil
+}
+
+// Name returns the provider name
+func (p *LlamaCppProvider) Name() string {
+	return "llamacpp"
+}
+
+// llamaMessage is a chat message, or the delta of a streamed one
+type llamaMessage struct {
+	Role             string          `json:"role,omitempty"`
+	Content          string          `json:"content"`
+	ReasoningContent string          `json:"reasoning_content,omitempty"`
+	ToolCalls        []llamaToolCall `json:"tool_calls,omitempty"`
+	ToolCallID       string          `json:"tool_call_id,omitempty"`
+}
+
+// llamaToolCall is a tool call, or a fragment of one when streaming
+type llamaToolCall struct {
+	Index    int    `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// llamaTool is a tool definition for native tool calls
+type llamaTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description"`
+		Parameters  map[string]interface{} `json:"parameters"`
+	} `json:"function"`
+}
+
+// llamaChatRequest is the body of /v1/chat/completions
+type llamaChatRequest struct {
+	Model          string          `json:"model,omitempty"`
+	Messages       []llamaMessage  `json:"messages"`
+	Tools          []llamaTool     `json:"tools,omitempty"`
+	ToolChoice     any             `json:"tool_choice,omitempty"`
+	Stream         bool            `json:"stream"`
+	StreamOptions  map[string]bool `json:"stream_options,omitempty"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`
+	Stop           []string        `json:"stop,omitempty"`
+	ResponseFormat map[string]any  `json:"response_format,omitempty"`
+	CachePrompt    bool            `json:"cache_prompt"`
+}
+
+// llamaChatResponse is a response, or one event of a streamed response
+type llamaChatResponse struct {
+	Choices []struct {
+		Message      llamaMessage `json:"message"`
+		Delta        llamaMessage `json:"delta"`
+		FinishReason string       `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	Error *llamaErrorBody `json:"error"`
+}
+
+// llamaCompletionRequest is the body of the native /completion
+type llamaCompletionRequest struct {
+	Prompt      string         `json:"prompt"`
+	NPredict    int            `json:"n_predict,omitempty"`
+	Stop        []string       `json:"stop,omitempty"`
+	Stream      bool           `json:"stream"`
+	JSONSchema  map[string]any `json:"json_schema,omitempty"`
+	CachePrompt bool           `json:"cache_prompt"`
+}
+
+// llamaCompletionResponse is the response of /completion
+type llamaCompletionResponse struct {
+	Content         string `json:"content"`
+	StopType        string `json:"stop_type"`
+	TokensEvaluated int    `json:"tokens_evaluated"`
+	TokensPredicted int    `json:"tokens_predicted"`
+}
+
+// llamaErrorBody is the error llama-server returns
+type llamaErrorBody struct {
+	Message string `json:"message"`
+}
+
+// Chat sends a chat request to llama-server
+func (p *LlamaCppProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if p.options.Endpoint == config.LlamaCppEndpointCompletion {
+		return p.complete(ctx, req)
+	}
+
+	body, err := p.post(ctx, "/v1/chat/completions", p.buildChatRequest(req, false))
+	if err != nil {
+		return nil, fmt.Errorf("llamacpp request failed: %w", err)
+	}
+	defer body.Close()
+
+	var resp llamaChatResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to parse llamacpp response: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("llamacpp request failed: %s", resp.Error.Message)
+	}
+
+	result := &ChatResponse{}
+	if len(resp.Choices) > 0 {
+		choice := resp.Choices[0]
+		result.Content = choice.Message.Content
+		result.Reasoning = choice.Message.ReasoningContent
+		result.StopReason = choice.FinishReason
+		for _, tc := range choice.Message.ToolCalls {
+			result.ToolCalls = append(result.ToolCalls, llamaToolCallResult(tc))
+		}
+	}
+	if resp.Usage != nil {
+		result.Usage = Usage{
+			InputTokens:  resp.Usage.PromptTokens,
+			OutputTokens: resp.Usage.CompletionTokens,
+			TotalTokens:  resp.Usage.PromptTokens + resp.Usage.CompletionTokens,
+		}
+	}
+	if p.grammar(req) {
+		parseGrammarReply(result)
+	}
+	return result, nil
+}
+
+// StreamChat streams a chat response from llama-server
+// Grammar-constrained replies are JSON until they are parsed, so they are
+// sent whole once done
+func (p *LlamaCppProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
+	if p.grammar(req) || p.options.Endpoint == config.LlamaCppEndpointCompletion {
+		resp, err := p.Chat(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return replay(resp, &resp.Usage), nil
+	}
+
+	body, err := p.post(ctx, "/v1/chat/completions", p.buildChatRequest(req, true))
+	if err != nil {
+		return nil, fmt.Errorf("llamacpp stream request failed: %w", err)
+	}
+
+	events := make(chan StreamEvent)
+
+	go func() {
+		defer close(events)
+		defer body.Close()
+
+		// Tool calls arrive in fragments, keyed by their index
+		var calls []llamaToolCall
+		var usage Usage
+		var stopReason string
+
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 64*1024), maxLlamaCppLine)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "data:")
+			if !ok {
+				continue
+			}
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				break
+			}
+
+			var chunk llamaChatResponse
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				events <- StreamEvent{Type: StreamEventError, Error: fmt.Errorf("failed to parse llamacpp stream: %w", err)}
+				return
+			}
+			if chunk.Error != nil {
+				events <- StreamEvent{Type: StreamEventError, Error: fmt.Errorf("llamacpp stream failed: %s", chunk.Error.Message)}
+				return
+			}
+			if chunk.Usage != nil {
+				usage = Usage{
+					InputTokens:  chunk.Usage.PromptTokens,
+					OutputTokens: chunk.Usage.CompletionTokens,
+					TotalTokens:  chunk.Usage.PromptTokens + chunk.Usage.CompletionTokens,
+				}
+			}
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+
+			choice := chunk.Choices[0]
+			if choice.Delta.ReasoningContent != "" {
+				events <- StreamEvent{Type: StreamEventReasoning, Delta: choice.Delta.ReasoningContent}
+			}
+			if choice.Delta.Content != "" {
+				events <- StreamEvent{Type: StreamEventText, Delta: choice.Delta.Content}
+			}
+			for _, tc := range choice.Delta.ToolCalls {
+				if tc.Index < 0 {
+					continue
+				}
+				for len(calls) <= tc.Index {
+					calls = append(calls, llamaToolCall{})
+				}
+				call := &calls[tc.Index]
+				if tc.ID != "" {
+					call.ID = tc.ID
+				}
+				if tc.Function.Name != "" {
+					call.Function.Name = tc.Function.Name
+				}
+				call.Function.Arguments += tc.Function.Arguments
+			}
+			if choice.FinishReason != "" {
+				stopReason = choice.FinishReason
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			events <- StreamEvent{Type: StreamEventError, Error: err}
+			return
+		}
+
+		for _, tc := range calls {
+			call := llamaToolCallResult(tc)
+			events <- StreamEvent{Type: StreamEventToolCall, ToolCall: &call}
+		}
+		events <- StreamEvent{Type: StreamEventDone, StopReason: stopReason, Usage: &usage}
+	}()
+
+	return events, nil
+}
+
+// complete sends a request to the native /completion endpoint, with the
+// prompt rendered by the server's chat template
+func (p *LlamaCppProvider) complete(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	body, err := p.post(ctx, "/apply-template", map[string]any{"messages": p.convertMessages(req)})
+	if err != nil {
+		return nil, fmt.Errorf("llamacpp apply-template failed: %w", err)
+	}
+	var tmpl struct {
+		Prompt string `json:"prompt"`
+	}
+	err = json.NewDecoder(body).Decode(&tmpl)
+	body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse llamacpp apply-template response: %w", err)
+	}
+
+	completion := &llamaCompletionRequest{
+		Prompt:      tmpl.Prompt,
+		NPredict:    req.MaxTokens,
+		Stop:        req.StopSequences,
+		CachePrompt: true,
+	}
+	if p.grammar(req) {
+		completion.JSONSchema = replySchema(req)
+	}
+
+	body, err = p.post(ctx, "/completion", completion)
+	if err != nil {
+		return nil, fmt.Errorf("llamacpp request failed: %w", err)
+	}
+	defer body.Close()
+
+	var resp llamaCompletionResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to parse llamacpp response: %w", err)
+	}
+
+	result := &ChatResponse{
+		Content:    resp.Content,
+		StopReason: "stop",
+		Usage: Usage{
+			InputTokens:  resp.TokensEvaluated,
+			OutputTokens: resp.TokensPredicted,
+			TotalTokens:  resp.TokensEvaluated + resp.TokensPredicted,
+		},
+	}
+	if resp.StopType == "limit" {
+		result.StopReason = "length"
+	}
+	if p.grammar(req) {
+		parseGrammarReply(result)
+	}
+	return result, nil
+}
+
+// grammar reports whether a request's reply is constrained by a grammar
+func (p *LlamaCppProvider) grammar(req *ChatRequest) bool {
+	return p.options.GrammarToolCalls() && len(req.Tools) > 0
+}
+
+// buildChatRequest converts a chat request to /v1/chat/completions
+func (p *LlamaCppProvider) buildChatRequest(req *ChatRequest, stream bool) *llamaChatRequest {
+	model := req.Model
+	if model == "" {
+		model = p.model
+	}
+
+	chatReq := &llamaChatRequest{
+		Model:       model,
+		Messages:    p.convertMessages(req),
+		Stream:      stream,
+		MaxTokens:   req.MaxTokens,
+		Stop:        req.StopSequences,
+		CachePrompt: true,
+	}
+	if stream {
+		chatReq.StreamOptions = map[string]bool{"include_usage": true}
+	}
+
+	if p.grammar(req) {
+		chatReq.ResponseFormat = map[string]any{
+			"type":        "json_schema",
+			"json_schema": map[string]any{"name": "reply", "schema": replySchema(req)},
+		}
+		return chatReq
+	}
+
+	for _, tool := range req.Tools {
+		var t llamaTool
+		t.Type = "function"
+		t.Function.Name = tool.Name
+		t.Function.Description = tool.Description
+		t.Function.Parameters = tool.Parameters
+		chatReq.Tools = append(chatReq.Tools, t)
+	}
+	if req.ToolChoice != nil && len(chatReq.Tools) > 0 {
+		switch req.ToolChoice.Mode {
+		case ToolChoiceNone, ToolChoiceAuto, ToolChoiceRequired:
+			chatReq.ToolChoice = string(req.ToolChoice.Mode)
+		case ToolChoiceTool:
+			chatReq.ToolChoice = map[string]any{
+				"type":     "function",
+				"function": map[string]string{"name": req.ToolChoice.Name},
+			}
+		}
+	}
+	return chatReq
+}
+
+// convertMessages converts our messages to llama-server's chat format
+// With grammar tool calls, calls and results become plain turns in the
+// JSON the grammar allows, since the chat template may not know tools
+func (p *LlamaCppProvider) convertMessages(req *ChatRequest) []llamaMessage {
+	grammar := p.grammar(req)
+
+	system := req.System
+	if grammar {
+		system += grammarToolsHint(req.Tools)
+	}
+
+	var result []llamaMessage
+	if system != "" {
+		result = append(result, llamaMessage{Role: "system", Content: system})
+	}
+
+	names := make(map[string]string)
+	results := false
+	for _, msg := range withReminder(req) {
+		switch msg.Role {
+		case "user":
+			result = append(result, llamaMessage{Role: "user", Content: msg.Content})
+			results = false
+
+		case "assistant":
+			if grammar {
+				var reply any = map[string]string{"answer": msg.Content}
+				if len(msg.ToolCalls) > 0 {
+					calls := make([]map[string]any, 0, len(msg.ToolCalls))
+					for _, tc := range msg.ToolCalls {
+						names[tc.ID] = tc.Name
+						calls = append(calls, map[string]any{"name": tc.Name, "arguments": llamaArguments(tc.Arguments)})
+					}
+					reply = map[string]any{"tool_calls": calls}
+				}
+				data, _ := json.Marshal(reply)
+				result = append(result, llamaMessage{Role: "assistant", Content: string(data)})
+				results = false
+				continue
+			}
+
+			llamaMsg := llamaMessage{Role: "assistant", Content: msg.Content}
+			for _, tc := range msg.ToolCalls {
+				var call llamaToolCall
+				call.ID = tc.ID
+				call.Type = "function"
+				call.Function.Name = tc.Name
+				call.Function.Arguments = string(llamaArguments(tc.Arguments))
+				llamaMsg.ToolCalls = append(llamaMsg.ToolCalls, call)
+			}
+			result = append(result, llamaMsg)
+
+		case "tool":
+			if !grammar {
+				result = append(result, llamaMessage{Role: "tool", Content: msg.Content, ToolCallID: msg.ToolCallID})
+				continue
+			}
+			// Results of one turn go in one message
+			text := fmt.Sprintf("Result of %s:\n%s", names[msg.ToolCallID], msg.Content)
+			if n := len(result); results && n > 0 {
+				result[n-1].Content += "\n\n" + text
+				continue
+			}
+			result = append(result, llamaMessage{Role: "user", Content: text})
+			results = true
+		}
+	}
+
+	return result
+}
+
+// llamaArguments returns tool call arguments, with empty ones as {}
+func llamaArguments(args json.RawMessage) json.RawMessage {
+	if len(bytes.TrimSpace(args)) == 0 || string(args) == "null" {
+		return json.RawMessage("{}")
+	}
+	return args
+}
+
+// llamaToolCallResult converts a tool call from llama-server
+// Missing call IDs are generated
+func llamaToolCallResult(tc llamaToolCall) ToolCall {
+	id := tc.ID
+	if id == "" {
+		id = ollamaCallID()
+	}
+	return ToolCall{ID: id, Name: tc.Function.Name, Arguments: llamaArguments(json.RawMessage(tc.Function.Arguments))}
+}
+
+// grammarToolsHint describes the tools and the reply format for grammar
+// tool calls
+func grammarToolsHint(tools []Tool) string {
+	var sb strings.Builder
+	sb.WriteString(`
+
+## Calling Tools
+
+Reply with JSON only. To call tools, reply with their names and arguments; their results come back in the
+next message:
+
+{"tool_calls": [{"name": "grep", "arguments": {"pattern": "func NewCommand"}}]}
+
+Once you have what you need, reply with your answer in Markdown:
+
+{"answer": "..."}
+
+Available tools:
+`)
+	sb.WriteString(ToolList(tools, nil))
+	return sb.String()
+}
+
+// replySchema returns the JSON schema a grammar-constrained reply follows:
+// tool calls with each tool's arguments, or the answer, as the request's
+// tool choice allows
+// llama-server turns the schema into a grammar
+func replySchema(req *ChatRequest) map[string]any {
+	answer := map[string]any{
+		"type":                 "object",
+		"properties":           map[string]any{"answer": map[string]any{"type": "string"}},
+		"required":             []string{"answer"},
+		"additionalProperties": false,
+	}
+
+	var calls []any
+	for _, t := range req.Tools {
+		if req.ToolChoice != nil && req.ToolChoice.Mode == ToolChoiceTool && t.Name != req.ToolChoice.Name {
+			continue
+		}
+		params := t.Parameters
+		if params == nil {
+			params = map[string]interface{}{"type": "object"}
+		}
+		calls = append(calls, map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"name":      map[string]any{"const": t.Name},
+				"arguments": params,
+			},
+			"required":             []string{"name", "arguments"},
+			"additionalProperties": false,
+		})
+	}
+	toolCalls := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"tool_calls": map[string]any{
+				"type":     "array",
+				"items":    map[string]any{"oneOf": calls},
+				"minItems": 1,
+			},
+		},
+		"required":             []string{"tool_calls"},
+		"additionalProperties": false,
+	}
+
+	if req.ToolChoice != nil {
+		switch req.ToolChoice.Mode {
+		case ToolChoiceNone:
+			return answer
+		case ToolChoiceRequired, ToolChoiceTool:
+			if len(calls) > 0 {
+				return toolCalls
+			}
+		}
+	}
+	if len(calls) == 0 {
+		return answer
+	}
+	return map[string]any{"oneOf": []any{toolCalls, answer}}
+}
+
+// parseGrammarReply moves the tool calls or the answer of a
+// grammar-constrained reply to the response
+// Replies that aren't the expected JSON (e.g. cut off at max tokens) are
+// left as text
+func parseGrammarReply(resp *ChatResponse) {
+	var reply struct {
+		Answer    *string `json:"answer"`
+		ToolCalls []struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		} `json:"tool_calls"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(resp.Content)), &reply); err != nil {
+		return
+	}
+
+	if len(reply.ToolCalls) > 0 {
+		resp.Content = ""
+		for _, tc := range reply.ToolCalls {
+			resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: ollamaCallID(), Name: tc.Name, Arguments: llamaArguments(tc.Arguments)})
+		}
+		resp.StopReason = "tool_calls"
+		return
+	}
+	if reply.Answer != nil {
+		resp.Content = *reply.Answer
+	}
+}
+
+// post sends a JSON request to the server and returns the response body
+func (p *LlamaCppProvider) post(ctx context.Context, path string, body any) (io.ReadCloser, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, llamaCppError(resp)
+	}
+	return resp.Body, nil
+}
+
+// llamaCppError turns an error response into an error with the server's
+// message
+func llamaCppError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var body struct {
+		Error *llamaErrorBody `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error != nil && body.Error.Message != "" {
+		return fmt.Errorf("%s: %s", resp.Status, body.Error.Message)
+	}
+	return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+}
@@ -0,0 +1,88 @@
+package provider
+
+import "context"
+
+// Meter decides whether model requests may be made and records their usage
+type Meter interface {
+	// Allow returns an error when no more requests may be made
+	Allow() error
+
+	// Record adds the usage of a response
+	Record(usage Usage)
+}
+
+// meteredProvider checks and records every request with a meter
+type meteredProvider struct {
+	Provider
+	meter Meter
+}
+
+// NewMetered wraps a provider so requests are refused when the meter says
+// so, and the usage of responses is recorded
+func NewMetered(p Provider, m Meter) Provider {
+	return &meteredProvider{Provider: p, meter: m}
+}
+
+// Chat sends the request if the meter allows it and records its usage
+func (p *meteredProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if err := p.meter.Allow(); err != nil {
+		return nil, err
+	}
+	resp, err := p.Provider.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	p.meter.Record(resp.Usage)
+	return resp, nil
+}
+
+// StreamChat starts the stream if the meter allows it and records its
+// usage once done
+// A stream that fails or is cancelled is still paid for, so the usage seen
+// is recorded; without any, it's estimated from the request and the text
+// received
+func (p *meteredProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
+	if err := p.meter.Allow(); err != nil {
+		return nil, err
+	}
+	events, err := p.Provider.StreamChat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+		var usage *Usage
+		received := 0
+		done := false
+		record := func() {
+			done = true
+			if usage == nil {
+				usage = &Usage{InputTokens: estimateTokens(req), OutputTokens: received / 4}
+			}
+			p.meter.Record(*usage)
+		}
+		defer func() {
+			if !done {
+				record()
+			}
+		}()
+
+		for event := range events {
+			if event.Usage != nil {
+				usage = event.Usage
+			}
+			received += len(event.Delta)
+			if event.Type == StreamEventDone {
+				record()
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
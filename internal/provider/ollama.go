@@ -1,105 +1,150 @@
 package provider
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
 
 	"github.com/nickcecere/btcx/internal/config"
-	"github.com/sashabaranov/go-openai"
 )
 
-// OllamaProvider implements the Provider interface for Ollama
+// maxOllamaLine caps a single line of Ollama's streamed NDJSON
+const maxOllamaLine = 4 * 1024 * 1024
+
+// OllamaProvider implements the Provider interface for Ollama's native API
+// (/api/chat), which unlike the OpenAI-compatible one accepts num_ctx and
+// keep_alive and reports token counts when streaming
 type OllamaProvider struct {
-	client  *openai.Client
+	client  *http.Client
 	model   string
 	baseURL string
+	options *config.OllamaConfig
+
+	// pulled checks once that the model is available, pulling it if allowed
+	pulled  sync.Once
+	pullErr error
 }
 
 // NewOllamaProvider creates a new Ollama provider
-func NewOllamaProvider(model, baseURL string) (*OllamaProvider, error) {
+// client (see NewHTTPClient) sends gateway headers and options tune model
+// loading (both optional)
+func NewOllamaProvider(model, baseURL string, client *http.Client, options *config.OllamaConfig) (*OllamaProvider, error) {
 	if baseURL == "" {
 		baseURL = config.DefaultOllamaBaseURL
 	}
+	if options == nil {
+		options = &config.OllamaConfig{}
+	}
 
-	// Ollama doesn't require an API key, but the OpenAI client needs something
-	cfg := openai.DefaultConfig("ollama")
-	cfg.BaseURL = baseURL
-
-	client := openai.NewClientWithConfig(cfg)
+	if client == nil {
+		client = http.DefaultClient
+	}
 
 	return &OllamaProvider{
 		client:  client,
 		model:   model,
-		baseURL: baseURL,
+		baseURL: ollamaAPIURL(baseURL),
+		options: options,
 	}, nil
 }
 
+// ollamaAPIURL returns the server root for the native API
+// Configs written for the OpenAI-compatible API end in /v1, which is dropped
+func ollamaAPIURL(baseURL string) string {
+	return strings.TrimSuffix(strings.TrimRight(baseURL, "/"), "/v1")
+}
+
 // Name returns the provider name
 func (p *OllamaProvider) Name() string {
 	return "ollama"
 }
 
-// Chat sends a chat request to Ollama
-func (p *OllamaProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
-	// Convert messages
-	messages := p.convertMessages(req)
+// ollamaChatRequest is the body of /api/chat
+type ollamaChatRequest struct {
+	Model     string          `json:"model"`
+	Messages  []ollamaMessage `json:"messages"`
+	Tools     []ollamaTool    `json:"tools,omitempty"`
+	Stream    bool            `json:"stream"`
+	KeepAlive any             `json:"keep_alive,omitempty"`
+	Options   map[string]any  `json:"options,omitempty"`
+}
 
-	// Convert tools
-	tools := p.convertTools(req.Tools)
+// ollamaMessage is a chat message in the native API
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
 
-	// Build request
-	model := req.Model
-	if model == "" {
-		model = p.model
-	}
+// ollamaToolCall is a tool call in the native API
+// Arguments are a JSON object, not a string as in the OpenAI API
+type ollamaToolCall struct {
+	ID       string `json:"id,omitempty"`
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
 
-	ollamaReq := openai.ChatCompletionRequest{
-		Model:    model,
-		Messages: messages,
-	}
+// ollamaTool is a tool definition in the native API
+type ollamaTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description"`
+		Parameters  map[string]interface{} `json:"parameters"`
+	} `json:"function"`
+}
 
-	if len(tools) > 0 {
-		ollamaReq.Tools = tools
-	}
+// ollamaChatResponse is a response, or one line of a streamed response
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
 
-	// Make request
-	resp, err := p.client.CreateChatCompletion(ctx, ollamaReq)
+// Chat sends a chat request to Ollama
+func (p *OllamaProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	body, err := p.post(ctx, "/api/chat", p.buildRequest(req, false))
 	if err != nil {
 		return nil, fmt.Errorf("ollama request failed: %w", err)
 	}
+	defer body.Close()
 
-	// Convert response
-	return p.convertResponse(&resp), nil
-}
-
-// StreamChat streams a chat response from Ollama
-func (p *OllamaProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
-	// Convert messages
-	messages := p.convertMessages(req)
-
-	// Convert tools
-	tools := p.convertTools(req.Tools)
-
-	// Build request
-	model := req.Model
-	if model == "" {
-		model = p.model
+	var resp ollamaChatResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to parse ollama response: %w", err)
 	}
-
-	ollamaReq := openai.ChatCompletionRequest{
-		Model:    model,
-		Messages: messages,
-		Stream:   true,
+	if resp.Error != "" {
+		return nil, fmt.Errorf("ollama request failed: %s", resp.Error)
 	}
 
-	if len(tools) > 0 {
-		ollamaReq.Tools = tools
-	}
+	return &ChatResponse{
+		Content:    resp.Message.Content,
+		ToolCalls:  convertOllamaToolCalls(resp.Message.ToolCalls),
+		StopReason: resp.DoneReason,
+		Usage:      ollamaUsage(&resp),
+	}, nil
+}
 
-	stream, err := p.client.CreateChatCompletionStream(ctx, ollamaReq)
+// StreamChat streams a chat response from Ollama
+func (p *OllamaProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
+	body, err := p.post(ctx, "/api/chat", p.buildRequest(req, true))
 	if err != nil {
 		return nil, fmt.Errorf("ollama stream request failed: %w", err)
 	}
@@ -108,175 +153,345 @@ func (p *OllamaProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-ch
 
 	go func() {
 		defer close(events)
-		defer stream.Close()
-
-		toolCalls := make(map[int]*ToolCall)
-		var stopReason string
-
-		for {
-			resp, err := stream.Recv()
-			if errors.Is(err, io.EOF) {
-				// Send done event
-				events <- StreamEvent{
-					Type:       StreamEventDone,
-					StopReason: stopReason,
-				}
-				break
-			}
-			if err != nil {
-				events <- StreamEvent{
-					Type:  StreamEventError,
-					Error: err,
-				}
-				break
-			}
-
-			if len(resp.Choices) == 0 {
+		defer body.Close()
+
+		// Each line is a JSON object; the last one has done set and the usage
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 64*1024), maxOllamaLine)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
 				continue
 			}
 
-			choice := resp.Choices[0]
-
-			// Check finish reason
-			if choice.FinishReason != "" {
-				stopReason = string(choice.FinishReason)
+			var chunk ollamaChatResponse
+			if err := json.Unmarshal(line, &chunk); err != nil {
+				events <- StreamEvent{Type: StreamEventError, Error: fmt.Errorf("failed to parse ollama stream: %w", err)}
+				return
 			}
-
-			// Handle content delta
-			if choice.Delta.Content != "" {
-				events <- StreamEvent{
-					Type:  StreamEventText,
-					Delta: choice.Delta.Content,
-				}
+			if chunk.Error != "" {
+				events <- StreamEvent{Type: StreamEventError, Error: fmt.Errorf("ollama stream failed: %s", chunk.Error)}
+				return
 			}
 
-			// Handle tool calls
-			for _, tc := range choice.Delta.ToolCalls {
-				idx := tc.Index
-				if idx == nil {
-					continue
-				}
+			if chunk.Message.Content != "" {
+				events <- StreamEvent{Type: StreamEventText, Delta: chunk.Message.Content}
+			}
 
-				if _, exists := toolCalls[*idx]; !exists {
-					toolCalls[*idx] = &ToolCall{
-						ID:        tc.ID,
-						Name:      tc.Function.Name,
-						Arguments: json.RawMessage(""),
-					}
-				}
+			// Tool calls arrive whole, not in fragments
+			for _, tc := range convertOllamaToolCalls(chunk.Message.ToolCalls) {
+				events <- StreamEvent{Type: StreamEventToolCall, ToolCall: &tc}
+			}
 
-				// Accumulate arguments
-				if tc.Function.Arguments != "" {
-					existing := string(toolCalls[*idx].Arguments)
-					toolCalls[*idx].Arguments = json.RawMessage(existing + tc.Function.Arguments)
-				}
+			if chunk.Done {
+				usage := ollamaUsage(&chunk)
+				events <- StreamEvent{Type: StreamEventDone, StopReason: chunk.DoneReason, Usage: &usage}
+				return
 			}
 		}
 
-		// Emit tool calls at the end
-		for _, tc := range toolCalls {
-			if tc.ID != "" && tc.Name != "" {
-				events <- StreamEvent{
-					Type:     StreamEventToolCall,
-					ToolCall: tc,
-				}
-			}
+		err := scanner.Err()
+		if err == nil {
+			err = fmt.Errorf("ollama stream ended before the response was done")
 		}
+		events <- StreamEvent{Type: StreamEventError, Error: err}
 	}()
 
 	return events, nil
 }
 
-// convertMessages converts our messages to OpenAI format (Ollama uses OpenAI-compatible API)
-func (p *OllamaProvider) convertMessages(req *ChatRequest) []openai.ChatCompletionMessage {
-	var result []openai.ChatCompletionMessage
+// buildRequest converts a chat request to the native API
+func (p *OllamaProvider) buildRequest(req *ChatRequest, stream bool) *ollamaChatRequest {
+	model := req.Model
+	if model == "" {
+		model = p.model
+	}
+
+	ollamaReq := &ollamaChatRequest{
+		Model:    model,
+		Messages: p.convertMessages(req),
+		Tools:    p.convertTools(ollamaTools(req)),
+		Stream:   stream,
+	}
+
+	// Validated with the config, so errors can't happen here
+	ollamaReq.KeepAlive, _ = p.options.KeepAliveValue()
+
+	options := map[string]any{}
+	if p.options.NumCtx > 0 {
+		options["num_ctx"] = p.options.NumCtx
+	}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+	if len(req.StopSequences) > 0 {
+		options["stop"] = req.StopSequences
+	}
+	if len(options) > 0 {
+		ollamaReq.Options = options
+	}
+
+	return ollamaReq
+}
+
+// convertMessages converts our messages to the native API
+func (p *OllamaProvider) convertMessages(req *ChatRequest) []ollamaMessage {
+	var result []ollamaMessage
 
 	// Add system message if provided
 	if req.System != "" {
-		result = append(result, openai.ChatCompletionMessage{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: req.System,
-		})
+		result = append(result, ollamaMessage{Role: "system", Content: req.System})
 	}
 
-	for _, msg := range req.Messages {
+	// Tool results name their tool rather than the call ID
+	toolNames := make(map[string]string)
+
+	// Images go to the model in a user message after the tool results
+	var images []string
+	var captions []string
+
+	messages := withReminder(req)
+	for i, msg := range messages {
 		switch msg.Role {
 		case "user":
-			result = append(result, openai.ChatCompletionMessage{
-				Role:    openai.ChatMessageRoleUser,
-				Content: msg.Content,
-			})
+			result = append(result, ollamaMessage{Role: "user", Content: msg.Content})
 
 		case "assistant":
-			oaiMsg := openai.ChatCompletionMessage{
-				Role:    openai.ChatMessageRoleAssistant,
-				Content: msg.Content,
-			}
+			ollamaMsg := ollamaMessage{Role: "assistant", Content: msg.Content}
 			for _, tc := range msg.ToolCalls {
-				oaiMsg.ToolCalls = append(oaiMsg.ToolCalls, openai.ToolCall{
-					ID:   tc.ID,
-					Type: openai.ToolTypeFunction,
-					Function: openai.FunctionCall{
-						Name:      tc.Name,
-						Arguments: string(tc.Arguments),
-					},
-				})
+				toolNames[tc.ID] = tc.Name
+
+				var call ollamaToolCall
+				call.ID = tc.ID
+				call.Function.Name = tc.Name
+				call.Function.Arguments = tc.Arguments
+				if len(bytes.TrimSpace(call.Function.Arguments)) == 0 {
+					call.Function.Arguments = json.RawMessage("{}")
+				}
+				ollamaMsg.ToolCalls = append(ollamaMsg.ToolCalls, call)
 			}
-			result = append(result, oaiMsg)
+			result = append(result, ollamaMsg)
 
 		case "tool":
-			result = append(result, openai.ChatCompletionMessage{
-				Role:       openai.ChatMessageRoleTool,
-				Content:    msg.Content,
-				ToolCallID: msg.ToolCallID,
+			result = append(result, ollamaMessage{
+				Role:     "tool",
+				Content:  msg.Content,
+				ToolName: toolNames[msg.ToolCallID],
 			})
+			for _, img := range msg.Images {
+				images = append(images, base64.StdEncoding.EncodeToString(img.Data))
+				captions = append(captions, imageCaption(msg))
+			}
+			if len(images) > 0 && !nextIsTool(messages, i) {
+				result = append(result, ollamaMessage{
+					Role:    "user",
+					Content: strings.Join(captions, "\n"),
+					Images:  images,
+				})
+				images, captions = nil, nil
+			}
 		}
 	}
 
 	return result
 }
 
-// convertTools converts our tools to OpenAI format
-func (p *OllamaProvider) convertTools(tools []Tool) []openai.Tool {
-	var result []openai.Tool
+// ollamaTools returns the tools offered to the model
+// The native API has no tool choice, so forbidding tools leaves them out and
+// naming one offers only that tool
+func ollamaTools(req *ChatRequest) []Tool {
+	if req.ToolChoice == nil {
+		return req.Tools
+	}
+	switch req.ToolChoice.Mode {
+	case ToolChoiceNone:
+		return nil
+	case ToolChoiceTool:
+		for _, t := range req.Tools {
+			if t.Name == req.ToolChoice.Name {
+				return []Tool{t}
+			}
+		}
+	}
+	return req.Tools
+}
+
+// convertTools converts our tools to the native API
+func (p *OllamaProvider) convertTools(tools []Tool) []ollamaTool {
+	var result []ollamaTool
 
 	for _, tool := range tools {
-		result = append(result, openai.Tool{
-			Type: openai.ToolTypeFunction,
-			Function: &openai.FunctionDefinition{
-				Name:        tool.Name,
-				Description: tool.Description,
-				Parameters:  tool.Parameters,
-			},
-		})
+		var t ollamaTool
+		t.Type = "function"
+		t.Function.Name = tool.Name
+		t.Function.Description = tool.Description
+		t.Function.Parameters = tool.Parameters
+		result = append(result, t)
 	}
 
 	return result
 }
 
-// convertResponse converts an OpenAI response to our format
-func (p *OllamaProvider) convertResponse(resp *openai.ChatCompletionResponse) *ChatResponse {
-	result := &ChatResponse{
-		Usage: Usage{
-			InputTokens:  resp.Usage.PromptTokens,
-			OutputTokens: resp.Usage.CompletionTokens,
-			TotalTokens:  resp.Usage.TotalTokens,
-		},
-	}
-
-	if len(resp.Choices) > 0 {
-		choice := resp.Choices[0]
-		result.Content = choice.Message.Content
-		result.StopReason = string(choice.FinishReason)
-
-		for _, tc := range choice.Message.ToolCalls {
-			result.ToolCalls = append(result.ToolCalls, ToolCall{
-				ID:        tc.ID,
-				Name:      tc.Function.Name,
-				Arguments: json.RawMessage(tc.Function.Arguments),
-			})
+// convertOllamaToolCalls converts tool calls from the native API
+// Older Ollama versions don't send call IDs, so missing ones are generated
+func convertOllamaToolCalls(calls []ollamaToolCall) []ToolCall {
+	var result []ToolCall
+	for _, tc := range calls {
+		id := tc.ID
+		if id == "" {
+			id = ollamaCallID()
+		}
+		args := tc.Function.Arguments
+		if len(bytes.TrimSpace(args)) == 0 || string(args) == "null" {
+			args = json.RawMessage("{}")
 		}
+		result = append(result, ToolCall{ID: id, Name: tc.Function.Name, Arguments: args})
 	}
-
 	return result
 }
+
+// ollamaCallID returns a random tool call ID
+func ollamaCallID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "call_" + hex.EncodeToString(b)
+}
+
+// ollamaUsage returns the token counts of a finished response
+func ollamaUsage(resp *ollamaChatResponse) Usage {
+	return Usage{
+		InputTokens:  resp.PromptEvalCount,
+		OutputTokens: resp.EvalCount,
+		TotalTokens:  resp.PromptEvalCount + resp.EvalCount,
+	}
+}
+
+// post sends a JSON request to the server and returns the response body
+// The model is pulled first if ollama.pull is set and the server lacks it
+func (p *OllamaProvider) post(ctx context.Context, path string, body any) (io.ReadCloser, error) {
+	if p.options.Pull {
+		p.pulled.Do(func() { p.pullErr = p.ensureModel(ctx) })
+		if p.pullErr != nil {
+			return nil, p.pullErr
+		}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, ollamaError(resp)
+	}
+	return resp.Body, nil
+}
+
+// ollamaError turns an error response into an error with Ollama's message
+func ollamaError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		return fmt.Errorf("%s: %s", resp.Status, body.Error)
+	}
+	return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+}
+
+// ensureModel pulls the model if the server doesn't have it, showing the
+// download progress on stderr
+func (p *OllamaProvider) ensureModel(ctx context.Context) error {
+	data, _ := json.Marshal(map[string]string{"model": p.model})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/show", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach ollama: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to check ollama model %s: %s", p.model, resp.Status)
+	}
+
+	return p.pull(ctx)
+}
+
+// pull downloads the model, printing progress on stderr
+func (p *OllamaProvider) pull(ctx context.Context) error {
+	data, _ := json.Marshal(map[string]any{"model": p.model, "stream": true})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/pull", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to pull %s: %w", p.model, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to pull %s: %w", p.model, ollamaError(resp))
+	}
+
+	var progress struct {
+		Status    string `json:"status"`
+		Total     int64  `json:"total"`
+		Completed int64  `json:"completed"`
+		Error     string `json:"error"`
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if err := json.Unmarshal(scanner.Bytes(), &progress); err != nil {
+			continue
+		}
+		if progress.Error != "" {
+			fmt.Fprintln(os.Stderr)
+			return fmt.Errorf("failed to pull %s: %s", p.model, progress.Error)
+		}
+		if progress.Total > 0 {
+			fmt.Fprintf(os.Stderr, "\rPulling %s: %s %d%% of %s   ", p.model, progress.Status,
+				progress.Completed*100/progress.Total, formatBytes(progress.Total))
+		} else {
+			fmt.Fprintf(os.Stderr, "\rPulling %s: %s   ", p.model, progress.Status)
+		}
+		progress.Total, progress.Completed = 0, 0
+	}
+	fmt.Fprintln(os.Stderr)
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to pull %s: %w", p.model, err)
+	}
+	if progress.Status != "success" {
+		return fmt.Errorf("failed to pull %s: download ended with %q", p.model, progress.Status)
+	}
+	return nil
+}
+
+// formatBytes formats a download size, e.g. "4.7 GB"
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	default:
+		return fmt.Sprintf("%d KB", n/1024)
+	}
+}
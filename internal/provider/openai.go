@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
@@ -15,10 +16,17 @@ type OpenAIProvider struct {
 	client  openai.Client
 	model   string
 	baseURL string
+	// name overrides the provider name (azure-openai)
+	name string
+	// deepSeek sends max_tokens and the reasoning of the current question,
+	// as the DeepSeek API expects
+	deepSeek bool
 }
 
 // NewOpenAIProvider creates a new OpenAI provider
-func NewOpenAIProvider(apiKey, model, baseURL string) (*OpenAIProvider, error) {
+// client (see NewHTTPClient) sends gateway headers and sets the proxy
+// (optional)
+func NewOpenAIProvider(apiKey, model, baseURL string, client *http.Client) (*OpenAIProvider, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("API key is required")
 	}
@@ -30,11 +38,12 @@ func NewOpenAIProvider(apiKey, model, baseURL string) (*OpenAIProvider, error) {
 	if baseURL != "" {
 		opts = append(opts, option.WithBaseURL(baseURL))
 	}
-
-	client := openai.NewClient(opts...)
+	if client != nil {
+		opts = append(opts, option.WithHTTPClient(client))
+	}
 
 	return &OpenAIProvider{
-		client:  client,
+		client:  openai.NewClient(opts...),
 		model:   model,
 		baseURL: baseURL,
 	}, nil
@@ -42,6 +51,9 @@ func NewOpenAIProvider(apiKey, model, baseURL string) (*OpenAIProvider, error) {
 
 // Name returns the provider name
 func (p *OpenAIProvider) Name() string {
+	if p.name != "" {
+		return p.name
+	}
 	if p.baseURL != "" {
 		return "openai-compatible"
 	}
@@ -68,11 +80,26 @@ func (p *OpenAIProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatRespo
 	}
 
 	if req.MaxTokens > 0 {
-		params.MaxCompletionTokens = openai.Int(int64(req.MaxTokens))
+		if p.deepSeek {
+			params.MaxTokens = openai.Int(int64(req.MaxTokens))
+		} else {
+			params.MaxCompletionTokens = openai.Int(int64(req.MaxTokens))
+		}
+	}
+
+	if len(req.StopSequences) > 0 {
+		params.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: req.StopSequences}
+	}
+
+	if req.ReasoningEffort != "" {
+		params.ReasoningEffort = shared.ReasoningEffort(req.ReasoningEffort)
 	}
 
 	if len(tools) > 0 {
 		params.Tools = tools
+		if req.ToolChoice != nil {
+			params.ToolChoice = convertOpenAIToolChoice(req.ToolChoice)
+		}
 	}
 
 	// Make request
@@ -105,11 +132,26 @@ func (p *OpenAIProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-ch
 	}
 
 	if req.MaxTokens > 0 {
-		params.MaxCompletionTokens = openai.Int(int64(req.MaxTokens))
+		if p.deepSeek {
+			params.MaxTokens = openai.Int(int64(req.MaxTokens))
+		} else {
+			params.MaxCompletionTokens = openai.Int(int64(req.MaxTokens))
+		}
+	}
+
+	if len(req.StopSequences) > 0 {
+		params.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: req.StopSequences}
+	}
+
+	if req.ReasoningEffort != "" {
+		params.ReasoningEffort = shared.ReasoningEffort(req.ReasoningEffort)
 	}
 
 	if len(tools) > 0 {
 		params.Tools = tools
+		if req.ToolChoice != nil {
+			params.ToolChoice = convertOpenAIToolChoice(req.ToolChoice)
+		}
 	}
 
 	// Create streaming request
@@ -126,6 +168,17 @@ func (p *OpenAIProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-ch
 			chunk := stream.Current()
 			acc.AddChunk(chunk)
 
+			// Reasoning models (DeepSeek and compatible servers) stream
+			// their thinking separately from the answer
+			if len(chunk.Choices) > 0 {
+				if reasoning := reasoningContent(chunk.Choices[0].Delta.JSON.ExtraFields); reasoning != "" {
+					events <- StreamEvent{
+						Type:  StreamEventReasoning,
+						Delta: reasoning,
+					}
+				}
+			}
+
 			// Handle content deltas
 			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
 				events <- StreamEvent{
@@ -187,7 +240,17 @@ func (p *OpenAIProvider) convertMessages(req *ChatRequest) []openai.ChatCompleti
 		result = append(result, openai.SystemMessage(req.System))
 	}
 
-	for _, msg := range req.Messages {
+	// Tool messages are text only, so their images follow in a user message
+	var images []openai.ChatCompletionContentPartUnionParam
+
+	// DeepSeek only takes back the reasoning of the current question
+	question := len(req.Messages)
+	if p.deepSeek {
+		question = lastQuestion(req.Messages)
+	}
+
+	messages := withReminder(req)
+	for i, msg := range messages {
 		switch msg.Role {
 		case "user":
 			result = append(result, openai.UserMessage(msg.Content))
@@ -216,15 +279,36 @@ func (p *OpenAIProvider) convertMessages(req *ChatRequest) []openai.ChatCompleti
 			} else {
 				result = append(result, openai.AssistantMessage(msg.Content))
 			}
+			if i > question && msg.Reasoning != "" {
+				result[len(result)-1].OfAssistant.SetExtraFields(map[string]any{"reasoning_content": msg.Reasoning})
+			}
 
 		case "tool":
 			result = append(result, openai.ToolMessage(msg.Content, msg.ToolCallID))
+			for _, img := range msg.Images {
+				images = append(images, openai.TextContentPart(imageCaption(msg)),
+					openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: img.DataURL()}))
+			}
+			if len(images) > 0 && !nextIsTool(messages, i) {
+				result = append(result, openai.UserMessage(images))
+				images = nil
+			}
 		}
 	}
 
 	return result
 }
 
+// convertOpenAIToolChoice converts a tool choice to OpenAI format
+func convertOpenAIToolChoice(choice *ToolChoice) openai.ChatCompletionToolChoiceOptionUnionParam {
+	if choice.Mode == ToolChoiceTool {
+		return openai.ToolChoiceOptionFunctionToolChoice(openai.ChatCompletionNamedToolChoiceFunctionParam{
+			Name: choice.Name,
+		})
+	}
+	return openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: openai.String(string(choice.Mode))}
+}
+
 // convertTools converts our tools to OpenAI format
 func (p *OpenAIProvider) convertTools(tools []Tool) []openai.ChatCompletionToolUnionParam {
 	var result []openai.ChatCompletionToolUnionParam
@@ -253,6 +337,7 @@ func (p *OpenAIProvider) convertResponse(resp *openai.ChatCompletion) *ChatRespo
 	if len(resp.Choices) > 0 {
 		choice := resp.Choices[0]
 		result.Content = choice.Message.Content
+		result.Reasoning = reasoningContent(choice.Message.JSON.ExtraFields)
 		result.StopReason = string(choice.FinishReason)
 
 		for _, tc := range choice.Message.ToolCalls {
@@ -0,0 +1,513 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/nickcecere/btcx/internal/config"
+)
+
+// PluginProtocolVersion is the plugin protocol version btcx speaks
+const PluginProtocolVersion = 1
+
+// JSON-RPC error codes used by the plugin protocol
+const (
+	rpcMethodNotFound = -32601
+)
+
+// PluginProvider runs an out-of-tree provider as a child process speaking
+// newline-delimited JSON-RPC 2.0 over stdin/stdout. The process is started
+// on first use and restarted if it exits
+type PluginProvider struct {
+	cfg    config.PluginConfig
+	model  string
+	apiKey string
+
+	mu       sync.Mutex
+	proc     *pluginProcess
+	name     string
+	noStream bool
+}
+
+// pluginProcess is a running plugin and its in-flight requests
+type pluginProcess struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]*pluginCall
+	err     error
+}
+
+// pluginCall is an in-flight request
+type pluginCall struct {
+	done   chan rpcResponse
+	events chan StreamEvent
+	// gone is closed when the caller stops waiting
+	gone chan struct{}
+}
+
+// rpcRequest is a JSON-RPC request sent to the plugin
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int64       `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// rpcResponse is a JSON-RPC response or notification from the plugin
+type rpcResponse struct {
+	ID     *int64          `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC error object
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("plugin error %d: %s", e.Code, e.Message)
+}
+
+// pluginInitParams are sent with the initialize request
+type pluginInitParams struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	Model           string `json:"model"`
+	APIKey          string `json:"api_key,omitempty"`
+}
+
+// pluginInitResult is the plugin's reply to initialize
+type pluginInitResult struct {
+	Name            string `json:"name"`
+	ProtocolVersion int    `json:"protocol_version"`
+}
+
+// pluginChatParams are the params of chat and chat.stream
+type pluginChatParams struct {
+	Model      string       `json:"model"`
+	System     string       `json:"system,omitempty"`
+	Messages   []Message    `json:"messages"`
+	Tools      []pluginTool `json:"tools,omitempty"`
+	ToolChoice *ToolChoice  `json:"tool_choice,omitempty"`
+	MaxTokens  int          `json:"max_tokens,omitempty"`
+	Stop       []string     `json:"stop,omitempty"`
+
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+	ThinkingBudget  int    `json:"thinking_budget,omitempty"`
+}
+
+// pluginTool is a tool definition on the wire
+type pluginTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// pluginChatResult is the result of chat and chat.stream
+type pluginChatResult struct {
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	StopReason string     `json:"stop_reason,omitempty"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// pluginStreamEvent is the params of a stream.event notification
+type pluginStreamEvent struct {
+	ID       int64     `json:"id"`
+	Type     string    `json:"type"`
+	Delta    string    `json:"delta,omitempty"`
+	ToolCall *ToolCall `json:"tool_call,omitempty"`
+}
+
+// NewPluginProvider creates a provider backed by a plugin process
+func NewPluginProvider(cfg *config.PluginConfig, model, apiKey string) (*PluginProvider, error) {
+	if cfg == nil || cfg.Command == "" {
+		return nil, fmt.Errorf("plugin command is required")
+	}
+	return &PluginProvider{
+		cfg:    *cfg,
+		model:  model,
+		apiKey: apiKey,
+		name:   "plugin",
+	}, nil
+}
+
+// Name returns the provider name reported by the plugin
+func (p *PluginProvider) Name() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.name
+}
+
+// Chat sends a chat request to the plugin
+func (p *PluginProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	proc, err := p.process(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := proc.call(ctx, "chat", p.chatParams(req), nil)
+	if err != nil {
+		return nil, fmt.Errorf("plugin request failed: %w", err)
+	}
+	return decodePluginResult(raw)
+}
+
+// StreamChat streams a chat response from the plugin
+// Plugins without chat.stream fall back to a single chat call
+func (p *PluginProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
+	proc, err := p.process(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	noStream := p.noStream
+	p.mu.Unlock()
+
+	ch := make(chan StreamEvent, 100)
+	go func() {
+		defer close(ch)
+
+		var raw json.RawMessage
+		var err error
+		if !noStream {
+			raw, err = proc.call(ctx, "chat.stream", p.chatParams(req), ch)
+			if rerr, ok := err.(*rpcError); ok && rerr.Code == rpcMethodNotFound {
+				p.mu.Lock()
+				p.noStream = true
+				p.mu.Unlock()
+				noStream = true
+			}
+		}
+		if noStream {
+			raw, err = proc.call(ctx, "chat", p.chatParams(req), nil)
+		}
+		if err != nil {
+			ch <- StreamEvent{Type: StreamEventError, Error: fmt.Errorf("plugin request failed: %w", err)}
+			return
+		}
+
+		resp, err := decodePluginResult(raw)
+		if err != nil {
+			ch <- StreamEvent{Type: StreamEventError, Error: err}
+			return
+		}
+
+		// Without streaming, deliver the whole response as events
+		if noStream {
+			if resp.Content != "" {
+				ch <- StreamEvent{Type: StreamEventText, Delta: resp.Content}
+			}
+			for i := range resp.ToolCalls {
+				ch <- StreamEvent{Type: StreamEventToolCall, ToolCall: &resp.ToolCalls[i]}
+			}
+		}
+
+		ch <- StreamEvent{Type: StreamEventDone, Usage: &resp.Usage, StopReason: resp.StopReason}
+	}()
+
+	return ch, nil
+}
+
+// chatParams converts a chat request to the wire format
+func (p *PluginProvider) chatParams(req *ChatRequest) *pluginChatParams {
+	model := req.Model
+	if model == "" {
+		model = p.model
+	}
+
+	params := &pluginChatParams{
+		Model:     model,
+		System:    req.System,
+		Messages:  withReminder(req),
+		MaxTokens: req.MaxTokens,
+		Stop:      req.StopSequences,
+
+		ReasoningEffort: req.ReasoningEffort,
+		ThinkingBudget:  req.ThinkingBudget,
+	}
+	if len(req.Tools) > 0 {
+		params.ToolChoice = req.ToolChoice
+	}
+	for _, t := range req.Tools {
+		params.Tools = append(params.Tools, pluginTool{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  t.Parameters,
+		})
+	}
+	return params
+}
+
+// decodePluginResult converts a chat result to a ChatResponse
+func decodePluginResult(raw json.RawMessage) (*ChatResponse, error) {
+	var result pluginChatResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("invalid plugin response: %w", err)
+	}
+
+	return &ChatResponse{
+		Content:    result.Content,
+		ToolCalls:  result.ToolCalls,
+		StopReason: result.StopReason,
+		Usage: Usage{
+			InputTokens:  result.Usage.InputTokens,
+			OutputTokens: result.Usage.OutputTokens,
+			TotalTokens:  result.Usage.InputTokens + result.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+// process returns the running plugin, starting it if needed
+func (p *PluginProvider) process(ctx context.Context) (*pluginProcess, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.proc != nil && p.proc.alive() {
+		return p.proc, nil
+	}
+
+	proc, err := startPlugin(p.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := proc.call(ctx, "initialize", &pluginInitParams{
+		ProtocolVersion: PluginProtocolVersion,
+		Model:           p.model,
+		APIKey:          p.apiKey,
+	}, nil)
+	if err != nil {
+		proc.kill()
+		return nil, fmt.Errorf("plugin %s failed to initialize: %w", p.cfg.Command, err)
+	}
+
+	var init pluginInitResult
+	if err := json.Unmarshal(raw, &init); err != nil {
+		proc.kill()
+		return nil, fmt.Errorf("plugin %s: invalid initialize response: %w", p.cfg.Command, err)
+	}
+	if init.ProtocolVersion != PluginProtocolVersion {
+		proc.kill()
+		return nil, fmt.Errorf("plugin %s speaks protocol version %d, btcx needs %d", p.cfg.Command, init.ProtocolVersion, PluginProtocolVersion)
+	}
+	if init.Name != "" {
+		p.name = init.Name
+	}
+
+	p.proc = proc
+	return proc, nil
+}
+
+// startPlugin launches the plugin process
+func startPlugin(cfg config.PluginConfig) (*pluginProcess, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Env = os.Environ()
+	for key, value := range cfg.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	// Plugin logs go to our stderr
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start plugin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start plugin: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", cfg.Command, err)
+	}
+
+	proc := &pluginProcess{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int64]*pluginCall),
+	}
+	go proc.read(stdout)
+	return proc, nil
+}
+
+// read dispatches responses and notifications until the plugin exits
+func (pp *pluginProcess) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		var msg rpcResponse
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+
+		// Streaming notifications carry the request id in their params
+		if msg.ID == nil {
+			if msg.Method == "stream.event" {
+				pp.dispatchEvent(msg.Params)
+			}
+			continue
+		}
+
+		pp.mu.Lock()
+		call, ok := pp.pending[*msg.ID]
+		delete(pp.pending, *msg.ID)
+		pp.mu.Unlock()
+		if ok {
+			call.done <- msg
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	pp.cmd.Wait()
+
+	pp.mu.Lock()
+	pp.err = fmt.Errorf("plugin exited: %w", err)
+	for id, call := range pp.pending {
+		call.done <- rpcResponse{Error: &rpcError{Code: -32000, Message: pp.err.Error()}}
+		delete(pp.pending, id)
+	}
+	pp.mu.Unlock()
+}
+
+// dispatchEvent forwards a stream.event notification to its caller
+func (pp *pluginProcess) dispatchEvent(params json.RawMessage) {
+	var ev pluginStreamEvent
+	if err := json.Unmarshal(params, &ev); err != nil {
+		return
+	}
+
+	pp.mu.Lock()
+	call, ok := pp.pending[ev.ID]
+	pp.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	var event StreamEvent
+	switch ev.Type {
+	case "text":
+		event = StreamEvent{Type: StreamEventText, Delta: ev.Delta}
+	case "tool_call":
+		if ev.ToolCall == nil {
+			return
+		}
+		event = StreamEvent{Type: StreamEventToolCall, ToolCall: ev.ToolCall}
+	default:
+		return
+	}
+
+	select {
+	case call.events <- event:
+	case <-call.gone:
+	}
+}
+
+// call sends a request and waits for its response
+// Stream events for the request are forwarded to events, if set
+func (pp *pluginProcess) call(ctx context.Context, method string, params interface{}, events chan<- StreamEvent) (json.RawMessage, error) {
+	call := &pluginCall{
+		done:   make(chan rpcResponse, 1),
+		events: make(chan StreamEvent, 16),
+		gone:   make(chan struct{}),
+	}
+	defer close(call.gone)
+
+	pp.mu.Lock()
+	if pp.err != nil {
+		err := pp.err
+		pp.mu.Unlock()
+		return nil, err
+	}
+	pp.nextID++
+	id := pp.nextID
+	pp.pending[id] = call
+	pp.mu.Unlock()
+
+	data, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err != nil {
+		pp.forget(id)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	pp.writeMu.Lock()
+	_, err = pp.stdin.Write(append(data, '\n'))
+	pp.writeMu.Unlock()
+	if err != nil {
+		pp.forget(id)
+		return nil, fmt.Errorf("failed to write to plugin: %w", err)
+	}
+
+	forward := func(ev StreamEvent) {
+		if events != nil {
+			events <- ev
+		}
+	}
+
+	for {
+		select {
+		case ev := <-call.events:
+			forward(ev)
+		case resp := <-call.done:
+			// Events are sent before the response, so any left are buffered
+			for {
+				select {
+				case ev := <-call.events:
+					forward(ev)
+					continue
+				default:
+				}
+				break
+			}
+			if resp.Error != nil {
+				return nil, resp.Error
+			}
+			return resp.Result, nil
+		case <-ctx.Done():
+			pp.forget(id)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// forget drops a pending request
+func (pp *pluginProcess) forget(id int64) {
+	pp.mu.Lock()
+	delete(pp.pending, id)
+	pp.mu.Unlock()
+}
+
+// alive reports whether the plugin is still running
+func (pp *pluginProcess) alive() bool {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	return pp.err == nil
+}
+
+// kill stops the plugin
+func (pp *pluginProcess) kill() {
+	pp.stdin.Close()
+	if pp.cmd.Process != nil {
+		pp.cmd.Process.Kill()
+	}
+}
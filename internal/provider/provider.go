@@ -2,8 +2,11 @@ package provider
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/nickcecere/btcx/internal/config"
 )
@@ -34,8 +37,63 @@ type ChatRequest struct {
 	// Tools are the available tools
 	Tools []Tool
 
+	// ToolChoice sets whether the model may, must or must not call tools
+	// (nil lets the model decide)
+	ToolChoice *ToolChoice
+
 	// MaxTokens is the maximum number of tokens to generate
 	MaxTokens int
+
+	// StopSequences end the response when the model generates one of them
+	StopSequences []string
+
+	// Reminder is a note for this request only, sent as a final user turn
+	// Per-turn guidance goes here rather than into System, so the system
+	// prompt stays the same (and cacheable) across turns
+	Reminder string
+
+	// ReasoningEffort is how hard a reasoning model thinks before answering:
+	// low, medium or high ("" leaves it to the model); sent as OpenAI's
+	// reasoning_effort
+	ReasoningEffort string
+
+	// ThinkingBudget is the tokens a model may spend on extended thinking,
+	// on top of MaxTokens (0 disables it); sent as Anthropic's thinking
+	ThinkingBudget int
+}
+
+// withReminder returns the request's messages, followed by its reminder as a
+// user turn if it has one
+func withReminder(req *ChatRequest) []Message {
+	if req.Reminder == "" {
+		return req.Messages
+	}
+	messages := make([]Message, len(req.Messages), len(req.Messages)+1)
+	copy(messages, req.Messages)
+	return append(messages, Message{Role: "user", Content: req.Reminder})
+}
+
+// ToolChoiceMode is how the model has to use the tools it is given
+type ToolChoiceMode string
+
+const (
+	// ToolChoiceAuto lets the model decide whether to call tools
+	ToolChoiceAuto ToolChoiceMode = "auto"
+	// ToolChoiceNone forbids tool calls
+	ToolChoiceNone ToolChoiceMode = "none"
+	// ToolChoiceRequired makes the model call at least one tool
+	ToolChoiceRequired ToolChoiceMode = "required"
+	// ToolChoiceTool makes the model call the named tool
+	ToolChoiceTool ToolChoiceMode = "tool"
+)
+
+// ToolChoice constrains the model's tool calls for one request
+type ToolChoice struct {
+	// Mode is how tools have to be used
+	Mode ToolChoiceMode `json:"mode"`
+
+	// Name is the tool to call with ToolChoiceTool
+	Name string `json:"name,omitempty"`
 }
 
 // Message represents a chat message
@@ -51,6 +109,48 @@ type Message struct {
 
 	// ToolCallID is the ID of the tool call this message is responding to
 	ToolCallID string `json:"tool_call_id,omitempty"`
+
+	// Images are attached to a tool result, e.g. a diagram the model read
+	// Only set for models that accept images
+	Images []Image `json:"images,omitempty"`
+
+	// Reasoning is the thinking a reasoning model did before an assistant
+	// message; providers that don't take it back ignore it
+	Reasoning string `json:"reasoning,omitempty"`
+
+	// ReasoningSignature verifies Reasoning for Anthropic, which needs the
+	// thinking back unchanged to continue a tool call
+	ReasoningSignature string `json:"reasoning_signature,omitempty"`
+}
+
+// Image is an image sent to a multimodal model, as a tool returns it and a
+// thread stores it
+type Image struct {
+	// MediaType is the MIME type, e.g. "image/png"
+	MediaType string `json:"media_type"`
+
+	// Data is the raw image (base64 in JSON)
+	// Threads store it in a file of its own (see storage.ToolResult), so it
+	// is empty in thread files
+	Data []byte `json:"data,omitempty"`
+}
+
+// DataURL returns the image as a data: URL
+func (i Image) DataURL() string {
+	return "data:" + i.MediaType + ";base64," + base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// imageCaption labels the images of a tool message for providers whose tool
+// messages can't carry images, so the model can match them to the tool call
+func imageCaption(msg Message) string {
+	return fmt.Sprintf("Image returned by tool call %s:", msg.ToolCallID)
+}
+
+// nextIsTool reports whether the message after i is another tool result
+// Images are sent once the run of tool results ends, since providers expect
+// tool results to directly follow the assistant's tool calls
+func nextIsTool(messages []Message, i int) bool {
+	return i+1 < len(messages) && messages[i+1].Role == "tool"
 }
 
 // ToolCall represents a tool invocation by the assistant
@@ -77,11 +177,33 @@ type Tool struct {
 	Parameters map[string]interface{}
 }
 
+// ToolList lists tools with their descriptions and argument schemas, for
+// prompts of models that are told about tools in text
+// label names each tool (nil for its name)
+func ToolList(tools []Tool, label func(Tool) string) string {
+	var sb strings.Builder
+	for _, t := range tools {
+		name := t.Name
+		if label != nil {
+			name = label(t)
+		}
+		params, _ := json.Marshal(t.Parameters)
+		fmt.Fprintf(&sb, "\n### %s\n\n%s\n\nArguments (JSON schema): %s\n", name, t.Description, params)
+	}
+	return sb.String()
+}
+
 // ChatResponse represents a chat response
 type ChatResponse struct {
 	// Content is the text content of the response
 	Content string
 
+	// Reasoning is the thinking of a reasoning model, kept out of Content
+	Reasoning string
+
+	// ReasoningSignature verifies Reasoning (Anthropic extended thinking)
+	ReasoningSignature string
+
 	// ToolCalls are any tool calls made by the assistant
 	ToolCalls []ToolCall
 
@@ -97,6 +219,11 @@ type Usage struct {
 	InputTokens  int
 	OutputTokens int
 	TotalTokens  int
+
+	// CacheReadTokens and CacheWriteTokens are the part of InputTokens read
+	// from and written to the provider's prompt cache
+	CacheReadTokens  int
+	CacheWriteTokens int
 }
 
 // StreamEvent represents a streaming event
@@ -104,9 +231,13 @@ type StreamEvent struct {
 	// Type is the event type
 	Type StreamEventType
 
-	// Delta is the content delta for text events
+	// Delta is the content delta for text and reasoning events
 	Delta string
 
+	// Signature is the signature of the thinking so far, sent with a
+	// reasoning event once a thinking block is complete
+	Signature string
+
 	// ToolCall is the tool call for tool events
 	ToolCall *ToolCall
 
@@ -127,6 +258,14 @@ const (
 	// StreamEventText is a text delta
 	StreamEventText StreamEventType = "text"
 
+	// StreamEventReasoning is a delta of a reasoning model's thinking, which
+	// isn't part of the answer
+	StreamEventReasoning StreamEventType = "reasoning"
+
+	// StreamEventThinking is StreamEventReasoning by the name of Anthropic's
+	// extended thinking; thinking is always sent as a reasoning event
+	StreamEventThinking = StreamEventReasoning
+
 	// StreamEventToolCall is a tool call starting
 	StreamEventToolCall StreamEventType = "tool_call"
 
@@ -142,35 +281,76 @@ const (
 
 // New creates a new provider based on the configuration (legacy)
 func New(cfg *config.Config) (Provider, error) {
+	client, err := NewHTTPClient(nil, cfg.HTTP, 0)
+	if err != nil {
+		return nil, err
+	}
 	switch cfg.Provider {
 	case config.ProviderAnthropic:
-		return NewAnthropicProvider(cfg.APIKey, cfg.Model)
+		return NewAnthropicProvider(cfg.APIKey, cfg.Model, "", client)
 	case config.ProviderOpenAI:
-		return NewOpenAIProvider(cfg.APIKey, cfg.Model, "")
+		return NewOpenAIProvider(cfg.APIKey, cfg.Model, "", client)
 	case config.ProviderOpenAICompatible:
-		return NewOpenAIProvider(cfg.APIKey, cfg.Model, cfg.BaseURL)
+		return NewOpenAIProvider(cfg.APIKey, cfg.Model, cfg.BaseURL, client)
 	case config.ProviderGoogle:
-		return NewGoogleProvider(cfg.APIKey, cfg.Model)
+		return NewGoogleProvider(cfg.APIKey, cfg.Model, "", client)
 	case config.ProviderOllama:
-		return NewOllamaProvider(cfg.Model, cfg.BaseURL)
+		return NewOllamaProvider(cfg.Model, cfg.BaseURL, client, nil)
 	default:
 		return nil, fmt.Errorf("unknown provider: %s", cfg.Provider)
 	}
 }
 
 // NewFromModelConfig creates a new provider from a ModelConfig
+// Headers, proxy, CA bundle and connect timeout apply to every HTTP provider;
+// the total and stream idle timeouts apply to every provider
 func NewFromModelConfig(m *config.ModelConfig) (Provider, error) {
+	connect, total, streamIdle := m.RequestTimeouts()
+	client, err := NewHTTPClient(m.Headers, m.ResolvedHTTP, connect)
+	if err != nil {
+		return nil, fmt.Errorf("model %q: %w", m.Name, err)
+	}
+	p, err := newModelProvider(m, client)
+	if err != nil {
+		return nil, err
+	}
+	return NewTimed(p, total, streamIdle), nil
+}
+
+// newModelProvider creates the provider of a model's provider type
+func newModelProvider(m *config.ModelConfig, client *http.Client) (Provider, error) {
 	switch m.Provider {
 	case config.ProviderAnthropic:
-		return NewAnthropicProvider(m.APIKey, m.Model)
+		return NewAnthropicProvider(m.APIKey, m.Model, m.BaseURL, client)
 	case config.ProviderOpenAI:
-		return NewOpenAIProvider(m.APIKey, m.Model, "")
+		return NewOpenAIProvider(m.APIKey, m.Model, m.BaseURL, client)
 	case config.ProviderOpenAICompatible:
-		return NewOpenAIProvider(m.APIKey, m.Model, m.BaseURL)
+		return NewOpenAIProvider(m.APIKey, m.Model, m.BaseURL, client)
+	case config.ProviderAzureOpenAI:
+		return NewAzureOpenAIProvider(m.APIKey, m.Model, m.BaseURL, client, m.Azure)
+	case config.ProviderDeepSeek:
+		return NewDeepSeekProvider(m.APIKey, m.Model, m.BaseURL, client)
 	case config.ProviderGoogle:
-		return NewGoogleProvider(m.APIKey, m.Model)
+		if m.Vertex != nil {
+			return NewVertexProvider(m.Model, m.BaseURL, client, m.Vertex)
+		}
+		return NewGoogleProvider(m.APIKey, m.Model, m.BaseURL, client)
 	case config.ProviderOllama:
-		return NewOllamaProvider(m.Model, m.BaseURL)
+		// Load the model with the context window it is configured to have
+		options := m.Ollama
+		if window := m.ContextWindow(); window > 0 && (options == nil || options.NumCtx == 0) {
+			copied := config.OllamaConfig{}
+			if options != nil {
+				copied = *options
+			}
+			copied.NumCtx = window
+			options = &copied
+		}
+		return NewOllamaProvider(m.Model, m.BaseURL, client, options)
+	case config.ProviderLlamaCpp:
+		return NewLlamaCppProvider(m.APIKey, m.Model, m.BaseURL, client, m.LlamaCpp)
+	case config.ProviderPlugin:
+		return NewPluginProvider(m.Plugin, m.Model, m.APIKey)
 	default:
 		return nil, fmt.Errorf("unknown provider: %s", m.Provider)
 	}
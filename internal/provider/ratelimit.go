@@ -0,0 +1,169 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter keeps a model's requests and tokens per minute under its
+// provider's quota with two token buckets, each holding a minute's worth
+type RateLimiter struct {
+	mu       sync.Mutex
+	requests *rate.Limiter // nil when requests are unlimited
+	tokens   *rate.Limiter // nil when tokens are unlimited
+}
+
+// rateLimiters are shared by every provider of the process, per model
+var (
+	rateLimitersMu sync.Mutex
+	rateLimiters   = make(map[string]*RateLimiter)
+)
+
+// SharedRateLimiter returns the process-wide rate limiter of a model with
+// the given limits (0 = unlimited), or nil if both are unlimited
+// Changed limits apply to the existing buckets
+func SharedRateLimiter(model string, requestsPerMinute, tokensPerMinute int) *RateLimiter {
+	if requestsPerMinute <= 0 && tokensPerMinute <= 0 {
+		return nil
+	}
+
+	rateLimitersMu.Lock()
+	defer rateLimitersMu.Unlock()
+	l, ok := rateLimiters[model]
+	if !ok {
+		l = &RateLimiter{}
+		rateLimiters[model] = l
+	}
+	l.mu.Lock()
+	l.requests = perMinute(l.requests, requestsPerMinute)
+	l.tokens = perMinute(l.tokens, tokensPerMinute)
+	l.mu.Unlock()
+	return l
+}
+
+// perMinute returns a bucket refilling n per minute and holding n, reusing
+// an existing one (nil if n is 0)
+func perMinute(l *rate.Limiter, n int) *rate.Limiter {
+	if n <= 0 {
+		return nil
+	}
+	limit := rate.Limit(float64(n) / time.Minute.Seconds())
+	if l == nil {
+		return rate.NewLimiter(limit, n)
+	}
+	if l.Limit() != limit || l.Burst() != n {
+		l.SetLimit(limit)
+		l.SetBurst(n)
+	}
+	return l
+}
+
+// wait blocks until the request and its estimated prompt tokens fit in the
+// buckets
+func (l *RateLimiter) wait(ctx context.Context, estimate int) error {
+	requests, tokens := l.buckets()
+	if requests != nil {
+		if err := requests.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if tokens != nil && estimate > 0 {
+		// A prompt over a minute's worth waits for a full bucket
+		if err := tokens.WaitN(ctx, min(estimate, tokens.Burst())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// charge takes the tokens a response used beyond the estimate from the
+// bucket, delaying later requests
+func (l *RateLimiter) charge(estimate int, usage Usage) {
+	_, tokens := l.buckets()
+	if tokens == nil {
+		return
+	}
+	if extra := usage.InputTokens + usage.OutputTokens - estimate; extra > 0 {
+		tokens.ReserveN(time.Now(), min(extra, tokens.Burst()))
+	}
+}
+
+// buckets returns the request and token buckets (nil when unlimited)
+func (l *RateLimiter) buckets() (requests, tokens *rate.Limiter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.requests, l.tokens
+}
+
+// estimateTokens approximates the prompt tokens of a request from the size
+// of its messages, system prompt and tools
+func estimateTokens(req *ChatRequest) int {
+	data, err := json.Marshal(struct {
+		System   string
+		Messages []Message
+		Tools    []Tool
+	}{req.System, req.Messages, req.Tools})
+	if err != nil {
+		return 0
+	}
+	return len(data) / 4
+}
+
+// rateLimitedProvider waits for the rate limiter before each request
+type rateLimitedProvider struct {
+	Provider
+	limiter *RateLimiter
+}
+
+// NewRateLimited wraps a provider so requests wait until they fit in the
+// limiter's buckets, and the tokens of responses are charged to it
+func NewRateLimited(p Provider, l *RateLimiter) Provider {
+	return &rateLimitedProvider{Provider: p, limiter: l}
+}
+
+// Chat sends the request once the limits allow it
+func (p *rateLimitedProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	estimate := estimateTokens(req)
+	if err := p.limiter.wait(ctx, estimate); err != nil {
+		return nil, err
+	}
+	resp, err := p.Provider.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	p.limiter.charge(estimate, resp.Usage)
+	return resp, nil
+}
+
+// StreamChat starts the stream once the limits allow it and charges its
+// usage once done
+func (p *rateLimitedProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
+	estimate := estimateTokens(req)
+	if err := p.limiter.wait(ctx, estimate); err != nil {
+		return nil, err
+	}
+	events, err := p.Provider.StreamChat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+		for event := range events {
+			if event.Type == StreamEventDone && event.Usage != nil {
+				p.limiter.charge(estimate, *event.Usage)
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
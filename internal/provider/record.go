@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"context"
+	"errors"
+)
+
+// Exchange is a request to a model and its response, as recorded by
+// btcx ask --record
+type Exchange struct {
+	// Model is the name of the model that got the request
+	Model string `json:"model"`
+
+	// Request is the request as sent
+	Request *ChatRequest `json:"request"`
+
+	// Response is the response, assembled from the events of a stream
+	Response *ChatResponse `json:"response,omitempty"`
+
+	// Error is the request's error, if it failed
+	Error string `json:"error,omitempty"`
+}
+
+// recordedProvider passes each request and its response to a recorder
+type recordedProvider struct {
+	Provider
+	model  string
+	record func(Exchange)
+}
+
+// NewRecorded wraps a provider so each exchange with model is passed to record
+func NewRecorded(p Provider, model string, record func(Exchange)) Provider {
+	return &recordedProvider{Provider: p, model: model, record: record}
+}
+
+// Chat sends the request and records it with its response
+func (p *recordedProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	resp, err := p.Provider.Chat(ctx, req)
+	exchange := Exchange{Model: p.model, Request: req, Response: resp}
+	if err != nil {
+		exchange.Error = err.Error()
+	}
+	p.record(exchange)
+	return resp, err
+}
+
+// StreamChat passes the stream on and records it once it ends
+func (p *recordedProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
+	events, err := p.Provider.StreamChat(ctx, req)
+	if err != nil {
+		p.record(Exchange{Model: p.model, Request: req, Error: err.Error()})
+		return nil, err
+	}
+
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+
+		exchange := Exchange{Model: p.model, Request: req, Response: &ChatResponse{}}
+		defer func() { p.record(exchange) }()
+		for event := range events {
+			collect(exchange.Response, event)
+			if event.Type == StreamEventError && event.Error != nil {
+				exchange.Error = event.Error.Error()
+			}
+
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				go drain(events)
+				exchange.Error = ctx.Err().Error()
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// replayedProvider answers requests with recorded responses
+type replayedProvider struct {
+	name string
+	next func(req *ChatRequest) (Exchange, error)
+}
+
+// NewReplayed creates a provider that answers each request with the
+// exchange next returns for it, without contacting a model
+func NewReplayed(name string, next func(req *ChatRequest) (Exchange, error)) Provider {
+	return &replayedProvider{name: name, next: next}
+}
+
+// Name returns the name of the recorded provider
+func (p *replayedProvider) Name() string {
+	return p.name
+}
+
+// Chat returns the recorded response
+func (p *replayedProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	exchange, err := p.next(req)
+	if err != nil {
+		return nil, err
+	}
+	if exchange.Error != "" {
+		return nil, errors.New(exchange.Error)
+	}
+	resp := *exchange.Response
+	return &resp, nil
+}
+
+// StreamChat streams the recorded response
+func (p *replayedProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
+	exchange, err := p.next(req)
+	if err != nil {
+		return nil, err
+	}
+	if exchange.Error != "" {
+		events := make(chan StreamEvent, 1)
+		events <- StreamEvent{Type: StreamEventError, Error: errors.New(exchange.Error)}
+		close(events)
+		return events, nil
+	}
+	usage := exchange.Response.Usage
+	return replay(exchange.Response, &usage), nil
+}
+
+// collect adds a stream event to the response assembled from the stream
+func collect(resp *ChatResponse, event StreamEvent) {
+	switch event.Type {
+	case StreamEventText:
+		resp.Content += event.Delta
+	case StreamEventReasoning:
+		resp.Reasoning += event.Delta
+		if event.Signature != "" {
+			resp.ReasoningSignature = event.Signature
+		}
+	case StreamEventToolCall:
+		if event.ToolCall != nil {
+			resp.ToolCalls = append(resp.ToolCalls, *event.ToolCall)
+		}
+	case StreamEventDone:
+		if event.Usage != nil {
+			resp.Usage = *event.Usage
+		}
+		resp.StopReason = event.StopReason
+	}
+}
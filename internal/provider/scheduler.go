@@ -0,0 +1,218 @@
+package provider
+
+import (
+	"context"
+	"sync"
+)
+
+// clientKey is the context key of the client a request is made for
+type clientKey struct{}
+
+// WithClient returns a context whose model requests are queued as the given
+// client's (e.g. a server user), for fair scheduling
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// clientFrom returns the client a request is made for ("" if unset)
+func clientFrom(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey{}).(string)
+	return client
+}
+
+// Scheduler limits concurrent model requests per lane (a provider type or
+// model), handing free slots to waiting clients in turn
+type Scheduler struct {
+	mu    sync.Mutex
+	lanes map[string]*lane
+}
+
+// lane is one limit and the requests waiting for it
+type lane struct {
+	limit   int
+	running int
+	// waiting holds each client's waiting requests in arrival order
+	waiting map[string][]chan struct{}
+	// turns lists clients with waiting requests; the first gets the next slot
+	turns []string
+}
+
+// scheduler is shared by every provider of the process
+var scheduler = &Scheduler{lanes: make(map[string]*lane)}
+
+// SharedScheduler returns the process-wide scheduler
+func SharedScheduler() *Scheduler {
+	return scheduler
+}
+
+// SetLimit sets the number of concurrent requests of a lane (0 = unlimited)
+// Requests already running or waiting keep their place
+func (s *Scheduler) SetLimit(key string, limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := s.lane(key)
+	l.limit = limit
+	for l.free() && len(l.turns) > 0 {
+		l.grant()
+	}
+}
+
+// lane returns the lane for key, creating it; callers hold s.mu
+func (s *Scheduler) lane(key string) *lane {
+	l, ok := s.lanes[key]
+	if !ok {
+		l = &lane{waiting: make(map[string][]chan struct{})}
+		s.lanes[key] = l
+	}
+	return l
+}
+
+// acquire waits for a slot in a lane for the context's client
+// The returned function must be called to release the slot
+func (s *Scheduler) acquire(ctx context.Context, key string) (func(), error) {
+	s.mu.Lock()
+	l := s.lane(key)
+	if l.free() && len(l.turns) == 0 {
+		l.running++
+		s.mu.Unlock()
+		return s.releaser(l), nil
+	}
+
+	client := clientFrom(ctx)
+	ready := make(chan struct{})
+	if len(l.waiting[client]) == 0 {
+		l.turns = append(l.turns, client)
+	}
+	l.waiting[client] = append(l.waiting[client], ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return s.releaser(l), nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-ready:
+			// Granted while giving up: pass the slot on
+			l.release()
+		default:
+			l.remove(client, ready)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// releaser returns a function releasing a slot of l once
+func (s *Scheduler) releaser(l *lane) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			l.release()
+			s.mu.Unlock()
+		})
+	}
+}
+
+// free reports whether the lane has a slot for another request
+func (l *lane) free() bool {
+	return l.limit <= 0 || l.running < l.limit
+}
+
+// release frees a slot, handing it to the next waiting request
+func (l *lane) release() {
+	l.running--
+	if l.free() && len(l.turns) > 0 {
+		l.grant()
+	}
+}
+
+// grant starts the first waiting request of the client whose turn it is,
+// then moves that client to the back of the line
+func (l *lane) grant() {
+	client := l.turns[0]
+	l.turns = l.turns[1:]
+	queue := l.waiting[client]
+	ready := queue[0]
+	if len(queue) > 1 {
+		l.waiting[client] = queue[1:]
+		l.turns = append(l.turns, client)
+	} else {
+		delete(l.waiting, client)
+	}
+	l.running++
+	close(ready)
+}
+
+// remove drops a request that stopped waiting
+func (l *lane) remove(client string, ready chan struct{}) {
+	queue := l.waiting[client]
+	for i, c := range queue {
+		if c == ready {
+			queue = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) > 0 {
+		l.waiting[client] = queue
+		return
+	}
+	delete(l.waiting, client)
+	for i, c := range l.turns {
+		if c == client {
+			l.turns = append(l.turns[:i:i], l.turns[i+1:]...)
+			break
+		}
+	}
+}
+
+// scheduledProvider waits for a scheduler slot before each request
+type scheduledProvider struct {
+	Provider
+	scheduler *Scheduler
+	key       string
+}
+
+// NewScheduled wraps a provider so its requests take a slot of the given
+// lane of s; streamed requests hold it until the stream ends
+func NewScheduled(p Provider, s *Scheduler, key string) Provider {
+	return &scheduledProvider{Provider: p, scheduler: s, key: key}
+}
+
+// Chat sends the request once a slot is free
+func (p *scheduledProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	release, err := p.scheduler.acquire(ctx, p.key)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return p.Provider.Chat(ctx, req)
+}
+
+// StreamChat starts the stream once a slot is free
+func (p *scheduledProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
+	release, err := p.scheduler.acquire(ctx, p.key)
+	if err != nil {
+		return nil, err
+	}
+	events, err := p.Provider.StreamChat(ctx, req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+		defer release()
+		for event := range events {
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
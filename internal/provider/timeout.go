@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// timedProvider bounds requests with a deadline and gives up on streams
+// that stop sending events, so a hung provider can't stall the agent loop
+type timedProvider struct {
+	Provider
+	total      time.Duration
+	streamIdle time.Duration
+}
+
+// NewTimed wraps a provider so each request is canceled after total and
+// each stream once it goes streamIdle without an event; zero disables either
+func NewTimed(p Provider, total, streamIdle time.Duration) Provider {
+	if total <= 0 && streamIdle <= 0 {
+		return p
+	}
+	return &timedProvider{Provider: p, total: total, streamIdle: streamIdle}
+}
+
+// Chat sends the request with the total timeout as its deadline
+func (p *timedProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	reqCtx, cancel := p.withTotal(ctx)
+	defer cancel()
+
+	resp, err := p.Provider.Chat(reqCtx, req)
+	if err != nil {
+		return nil, p.timeoutError(ctx, reqCtx, err)
+	}
+	return resp, nil
+}
+
+// StreamChat starts the stream with the total timeout as its deadline and
+// ends it with an error when no event arrives for the idle timeout
+func (p *timedProvider) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
+	reqCtx, cancel := p.withTotal(ctx)
+	events, err := p.Provider.StreamChat(reqCtx, req)
+	if err != nil {
+		err = p.timeoutError(ctx, reqCtx, err)
+		cancel()
+		return nil, err
+	}
+
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+		defer cancel()
+
+		var idle <-chan time.Time
+		var timer *time.Timer
+		if p.streamIdle > 0 {
+			timer = time.NewTimer(p.streamIdle)
+			defer timer.Stop()
+			idle = timer.C
+		}
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if event.Type == StreamEventError && event.Error != nil {
+					event.Error = p.timeoutError(ctx, reqCtx, event.Error)
+				}
+				if timer != nil {
+					timer.Reset(p.streamIdle)
+				}
+				if !p.send(ctx, out, event) {
+					go drain(events)
+					return
+				}
+			case <-idle:
+				go drain(events)
+				p.send(ctx, out, StreamEvent{
+					Type:  StreamEventError,
+					Error: fmt.Errorf("no response from the model for %s (timeouts.streamIdle)", p.streamIdle),
+				})
+				return
+			case <-reqCtx.Done():
+				go drain(events)
+				if ctx.Err() == nil {
+					p.send(ctx, out, StreamEvent{Type: StreamEventError, Error: p.timeoutError(ctx, reqCtx, reqCtx.Err())})
+				}
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// withTotal returns ctx with the total timeout applied
+func (p *timedProvider) withTotal(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.total <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.total)
+}
+
+// timeoutError explains err when it was caused by one of the timeouts
+// rather than the caller canceling ctx
+func (p *timedProvider) timeoutError(ctx, reqCtx context.Context, err error) error {
+	if ctx.Err() != nil || !errors.Is(reqCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("request timed out after %s (timeouts.total): %w", p.total, err)
+}
+
+// send passes an event on unless the caller has gone away
+func (p *timedProvider) send(ctx context.Context, out chan<- StreamEvent, event StreamEvent) bool {
+	select {
+	case out <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// drain discards the rest of a stream so its sender can finish
+func drain(events <-chan StreamEvent) {
+	for range events {
+	}
+}
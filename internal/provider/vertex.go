@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// vertexScope is the OAuth scope of Vertex AI
+const vertexScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// NewVertexProvider creates a provider for Gemini models on Vertex AI
+// The Gemini SDK only speaks the Gemini API, so requests go to Vertex AI's
+// OpenAI-compatible endpoint with an OAuth token from Google Cloud
+// credentials; baseURL replaces the regional endpoint (e.g. for Private
+// Service Connect)
+func NewVertexProvider(model, baseURL string, client *http.Client, vertex *config.VertexConfig) (*OpenAIProvider, error) {
+	credentialsFile := expandHome(vertex.CredentialsFile)
+
+	project := vertex.Project
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	if project == "" && credentialsFile != "" {
+		project = credentialsProject(credentialsFile)
+	}
+	if project == "" {
+		return nil, fmt.Errorf("vertex.project is required (or set GOOGLE_CLOUD_PROJECT)")
+	}
+	location := vertexLocation(vertex)
+
+	endpoint := strings.TrimRight(baseURL, "/")
+	if endpoint == "" {
+		endpoint = vertexEndpoint(location)
+	}
+	apiURL := endpoint + "/v1/projects/" + url.PathEscape(project) + "/locations/" + url.PathEscape(location) + "/endpoints/openapi/"
+
+	// Vertex AI names Gemini models by publisher
+	if !strings.Contains(model, "/") {
+		model = "google/" + model
+	}
+
+	tokens := &vertexTokenSource{credentialsFile: credentialsFile, client: withoutHeaders(client)}
+	opts := []option.RequestOption{
+		option.WithBaseURL(apiURL),
+		option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			token, err := tokens.token(req.Context())
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			return next(req)
+		}),
+	}
+	if client != nil {
+		opts = append(opts, option.WithHTTPClient(client))
+	}
+
+	return &OpenAIProvider{
+		client:  openai.NewClient(opts...),
+		model:   model,
+		baseURL: apiURL,
+		name:    "vertex",
+	}, nil
+}
+
+// vertexTokenSource finds Google Cloud credentials on first use and returns
+// their (cached, refreshed) access tokens
+type vertexTokenSource struct {
+	credentialsFile string
+	// client makes token requests, through the model's proxy
+	client *http.Client
+
+	once   sync.Once
+	source oauth2.TokenSource
+	err    error
+}
+
+// token returns a valid access token
+func (s *vertexTokenSource) token(ctx context.Context) (string, error) {
+	s.once.Do(func() {
+		// The credentials outlive the request, so they get their own context
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, s.client)
+		var creds *google.Credentials
+		if s.credentialsFile != "" {
+			data, err := os.ReadFile(s.credentialsFile)
+			if err != nil {
+				s.err = fmt.Errorf("failed to read vertex.credentialsFile: %w", err)
+				return
+			}
+			creds, s.err = google.CredentialsFromJSON(ctx, data, vertexScope)
+		} else {
+			creds, s.err = google.FindDefaultCredentials(ctx, vertexScope)
+		}
+		if s.err != nil {
+			s.err = fmt.Errorf("failed to find Google Cloud credentials (set vertex.credentialsFile or GOOGLE_APPLICATION_CREDENTIALS, or run gcloud auth application-default login): %w", s.err)
+			return
+		}
+		s.source = creds.TokenSource
+	})
+	if s.err != nil {
+		return "", s.err
+	}
+
+	token, err := s.source.Token()
+	if err != nil {
+		return "", fmt.Errorf("failed to get Google Cloud access token: %w", err)
+	}
+	return token.AccessToken, nil
+}
+
+// credentialsProject returns the project of a credentials file ("" if unknown)
+func credentialsProject(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var file struct {
+		ProjectID string `json:"project_id"`
+	}
+	if json.Unmarshal(data, &file) != nil {
+		return ""
+	}
+	return file.ProjectID
+}
+
+// expandHome expands a leading ~ in a path
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// vertexLocation returns the region of Vertex AI requests
+func vertexLocation(vertex *config.VertexConfig) string {
+	if vertex.Location != "" {
+		return vertex.Location
+	}
+	if location := os.Getenv("GOOGLE_CLOUD_LOCATION"); location != "" {
+		return location
+	}
+	return config.DefaultVertexLocation
+}
+
+// vertexEndpoint returns the Vertex AI endpoint serving a region
+func vertexEndpoint(location string) string {
+	if location == "global" {
+		return "https://aiplatform.googleapis.com"
+	}
+	return "https://" + location + "-aiplatform.googleapis.com"
+}
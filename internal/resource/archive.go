@@ -0,0 +1,294 @@
+package resource
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/nickcecere/btcx/internal/config"
+)
+
+// archiveInfo records which commit an archive resource was extracted from
+type archiveInfo struct {
+	URL     string    `json:"url"`
+	Branch  string    `json:"branch,omitempty"`
+	Commit  string    `json:"commit"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// ArchivesDir returns the directory where the commits of archive resources
+// are recorded
+func (m *Manager) ArchivesDir() string {
+	return filepath.Join(m.cacheDir, "archives")
+}
+
+// archiveCommit returns the commit an archive resource was extracted from
+// ("" if it isn't cached or was extracted from another URL or branch)
+func (m *Manager) archiveCommit(r *config.Resource) string {
+	if _, err := os.Stat(m.ResourcePath(r.Name)); err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(m.ArchivesDir(), r.Name+".json"))
+	if err != nil {
+		return ""
+	}
+	var info archiveInfo
+	if err := json.Unmarshal(data, &info); err != nil || info.URL != r.URL || info.Branch != r.Branch {
+		return ""
+	}
+	return info.Commit
+}
+
+// ensureArchive ensures an archive resource holds the latest commit of its
+// branch, or its locked commit
+func (m *Manager) ensureArchive(ctx context.Context, r *config.Resource) (string, error) {
+	path := m.ResourcePath(r.Name)
+
+	lock, err := m.acquire(ctx, "resource-"+r.Name)
+	if err != nil {
+		return path, err
+	}
+	defer lock.release()
+
+	commit, err := m.lockedCommit(r)
+	if err != nil {
+		return path, err
+	}
+	_, err = m.fetchArchive(ctx, r, commit)
+	return path, err
+}
+
+// fetchArchive downloads and extracts the tarball of commit, or of the
+// branch's latest commit if commit is "", unless it is already cached
+// It returns the extracted commit; callers hold the resource lock
+func (m *Manager) fetchArchive(ctx context.Context, r *config.Resource, commit string) (string, error) {
+	forge, err := newForgeClient(r)
+	if err != nil {
+		return "", err
+	}
+
+	if commit == "" {
+		if commit, err = forge.resolve(ctx, r.Branch); err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", branchLabel(r), err)
+		}
+		if !plumbing.IsHash(commit) {
+			return "", fmt.Errorf("failed to resolve %s: %s returned %q, not a commit", branchLabel(r), forge.forge, commit)
+		}
+	}
+	if m.archiveCommit(r) == commit {
+		return commit, nil
+	}
+
+	if err := os.MkdirAll(m.ArchivesDir(), 0755); err != nil {
+		return "", fmt.Errorf("failed to create archives directory: %w", err)
+	}
+	if err := os.MkdirAll(m.ResourcesDir(), 0755); err != nil {
+		return "", fmt.Errorf("failed to create resources directory: %w", err)
+	}
+
+	// Extract next to the archive records, so a failed download never
+	// leaves a half-extracted resource behind
+	tmp, err := os.MkdirTemp(m.ArchivesDir(), r.Name+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	body, err := forge.get(ctx, forge.archiveURL(commit), "application/octet-stream")
+	if err != nil {
+		return "", fmt.Errorf("failed to download archive: %w", err)
+	}
+	err = extractTarball(body, tmp)
+	body.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to extract archive: %w", err)
+	}
+
+	path := m.ResourcePath(r.Name)
+	if err := os.RemoveAll(path); err != nil {
+		return "", fmt.Errorf("failed to remove previous copy: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("failed to move archive into place: %w", err)
+	}
+
+	data, err := json.Marshal(archiveInfo{URL: r.URL, Branch: r.Branch, Commit: commit, Fetched: time.Now()})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal archive info: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(m.ArchivesDir(), r.Name+".json"), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write archive info: %w", err)
+	}
+	return commit, nil
+}
+
+// extractTarball extracts a gzipped tarball into dest, dropping the single
+// top-level directory forges wrap the repository in
+// Everything is written through an os.Root, so no entry can land outside dest,
+// even through links created by earlier entries; links that resolve outside
+// dest once the archive is extracted are removed
+func extractTarball(r io.Reader, dest string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	realDest, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return err
+	}
+
+	var links []string
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		_, name, _ := strings.Cut(strings.TrimPrefix(hdr.Name, "./"), "/")
+		if name == "" {
+			continue
+		}
+		name = filepath.FromSlash(name)
+		if !within(dest, filepath.Join(dest, name)) {
+			return fmt.Errorf("archive entry %s is outside the repository", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := root.MkdirAll(name, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := root.MkdirAll(filepath.Dir(name), 0755); err != nil {
+				return err
+			}
+			if err := writeFile(root, name, tr, hdr.FileInfo().Mode().Perm()|0600); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			// Links may only point within the repository
+			if filepath.IsAbs(hdr.Linkname) || !within(dest, filepath.Join(dest, filepath.Dir(name), hdr.Linkname)) {
+				continue
+			}
+			if err := root.MkdirAll(filepath.Dir(name), 0755); err != nil {
+				return err
+			}
+			if err := root.Symlink(hdr.Linkname, name); err != nil {
+				return err
+			}
+			if err := removeEscaping(root, realDest, name); err != nil {
+				return err
+			}
+			links = append(links, name)
+		default:
+			// Global headers, hard links to submodules and the like
+		}
+	}
+
+	// Links dangling when they were created may lead outside through
+	// entries extracted after them
+	for _, name := range links {
+		if err := removeEscaping(root, realDest, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeEscaping removes the link name if it resolves outside realDest
+// A chain of links can pass the lexical check on each link and still lead
+// outside, e.g. a -> . followed by a/a/esc -> ../..
+func removeEscaping(root *os.Root, realDest, name string) error {
+	resolved, err := filepath.EvalSymlinks(filepath.Join(realDest, name))
+	if err != nil || within(realDest, resolved) {
+		return nil
+	}
+	if err := root.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// writeFile writes the contents of r to a new file at name within root
+func writeFile(root *os.Root, name string, r io.Reader, mode os.FileMode) error {
+	f, err := root.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// within reports whether path is dir or inside it
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// verifyArchive checks the forge and cached copy of an archive resource and
+// returns the cached path, or "" if it isn't cached
+func (m *Manager) verifyArchive(ctx context.Context, r *config.Resource, report *Report) string {
+	var remote string
+	forge, err := newForgeClient(r)
+	if err != nil {
+		report.add("url", CheckFail, "%v", err)
+	} else if remote, err = forge.resolve(ctx, r.Branch); err != nil {
+		report.add("url", CheckFail, "%v", err)
+	} else {
+		report.add("url", CheckOK, "%s (%s archive)", r.URL, forge.forge)
+		report.add("branch", CheckOK, "%s at %s", branchLabel(r), shortHash(plumbing.NewHash(remote)))
+	}
+
+	locked, err := m.lockedCommit(r)
+	if err != nil {
+		report.add("lock", CheckFail, "%v", err)
+	} else if locked != "" {
+		report.add("lock", CheckOK, "pinned to %s", shortHash(plumbing.NewHash(locked)))
+	}
+
+	cached := m.archiveCommit(r)
+	if cached == "" {
+		report.add("cache", CheckWarn, "not cached yet (run btcx resources fetch %s)", r.Name)
+		return ""
+	}
+
+	hash := plumbing.NewHash(cached)
+	switch {
+	case locked != "" && cached != locked:
+		report.add("cache", CheckWarn, "cached at %s, not the locked commit (run btcx resources fetch %s)",
+			shortHash(hash), r.Name)
+	case locked != "":
+		report.add("cache", CheckOK, "at locked commit %s", shortHash(hash))
+	case remote == "":
+		report.add("cache", CheckOK, "cached at %s", shortHash(hash))
+	case cached != remote:
+		report.add("cache", CheckWarn, "stale: cached at %s, remote at %s (run btcx resources fetch %s)",
+			shortHash(hash), shortHash(plumbing.NewHash(remote)), r.Name)
+	default:
+		report.add("cache", CheckOK, "up to date at %s", shortHash(hash))
+	}
+
+	return m.ResourcePath(r.Name)
+}
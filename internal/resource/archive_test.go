@@ -0,0 +1,92 @@
+package resource
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tarEntry is one entry of a test tarball
+type tarEntry struct {
+	name, link, body string
+}
+
+// tarball builds a gzipped tarball from entries
+func tarball(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.body))}
+		if e.link != "" {
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = e.link
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if e.body != "" {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestExtractTarball(t *testing.T) {
+	dest := t.TempDir()
+	err := extractTarball(tarball(t, []tarEntry{
+		{name: "repo/README.md", body: "hello"},
+		{name: "repo/docs/guide.md", body: "guide"},
+		{name: "repo/docs/readme", link: "../README.md"},
+	}), dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, "docs", "readme"))
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("docs/readme = %q, %v; want the README through the link", data, err)
+	}
+}
+
+func TestExtractTarballSymlinkChain(t *testing.T) {
+	parent := t.TempDir()
+	dest := filepath.Join(parent, "a", "b")
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each link passes a lexical check on its own; together they lead
+	// two levels above dest; the escaping one is dropped, so the file
+	// after it lands in a plain directory within dest
+	err := extractTarball(tarball(t, []tarEntry{
+		{name: "repo/a", link: "."},
+		{name: "repo/a/a/esc", link: "../.."},
+		{name: "repo/esc/PWNED", body: "pwned"},
+	}), dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{filepath.Join(parent, "PWNED"), filepath.Join(parent, "a", "PWNED")} {
+		if _, err := os.Stat(path); err == nil {
+			t.Fatalf("%s was written outside dest", path)
+		}
+	}
+	if info, err := os.Lstat(filepath.Join(dest, "esc")); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("dest/esc = %v, %v; want the escaping link dropped", info, err)
+	}
+}
@@ -2,18 +2,26 @@ package resource
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/nickcecere/btcx/internal/config"
 )
 
+// CollectionTTL is how long a collection is kept after its last use
+// Collections only hold symlinks, so they are cheap to recreate
+const CollectionTTL = 7 * 24 * time.Hour
+
 // Collection represents a set of resources grouped together for searching
 type Collection struct {
-	// Name is the unique identifier for this collection (e.g., "svelte+react")
+	// Name is the unique identifier for this collection: the resource names
+	// and a hash of their sources (e.g., "react+svelte-3f2a9c1e")
 	Name string
 
 	// Path is the directory containing symlinks to resources
@@ -21,6 +29,9 @@ type Collection struct {
 
 	// Resources are the resources in this collection
 	Resources []CollectionResource
+
+	// manifestsDir is where resource manifests are persisted
+	manifestsDir string
 }
 
 // CollectionResource represents a resource within a collection
@@ -31,8 +42,27 @@ type CollectionResource struct {
 	// Path is the actual path to the resource (resolved from symlink)
 	Path string
 
+	// Root is the resource's checkout or local directory, without its
+	// searchPath ("" if unknown)
+	Root string
+
+	// SearchPath is the subdirectory of Root that Path points to ("" for
+	// the whole resource)
+	SearchPath string
+
 	// Notes are hints for the AI about this resource
 	Notes string
+
+	// PromptFile is the absolute path of the resource's guidance file ("" if none)
+	PromptFile string
+
+	// Links maps cited files to their source paths and URLs (nil if the
+	// resource doesn't configure links)
+	Links *Links
+
+	// Commit is the commit an archive resource was extracted from ("" for
+	// other resources, whose version is read from their checkout)
+	Commit string
 }
 
 // EnsureCollection ensures a collection exists with the given resources
@@ -41,19 +71,9 @@ func (m *Manager) EnsureCollection(ctx context.Context, resources []*config.Reso
 		return nil, fmt.Errorf("at least one resource is required")
 	}
 
-	// Generate collection name from sorted resource names
-	names := make([]string, len(resources))
-	for i, r := range resources {
-		names[i] = r.Name
-	}
-	sort.Strings(names)
-	collectionName := strings.Join(names, "+")
-
-	// Collection path
-	collectionPath := filepath.Join(m.CollectionsDir(), collectionName)
-
 	// Ensure resources are available and get their paths
 	resourcePaths := make(map[string]string)
+	resourceRoots := make(map[string]string)
 	for _, r := range resources {
 		path, err := m.Ensure(ctx, r)
 		if err != nil {
@@ -67,9 +87,24 @@ func (m *Manager) EnsureCollection(ctx context.Context, resources []*config.Reso
 		}
 
 		resourcePaths[r.Name] = workingPath
-		_ = path // Silence unused variable warning
+		resourceRoots[r.Name] = path
 	}
 
+	// Name the collection after its contents, so a changed searchPath, path or
+	// pin gets its own collection and an unchanged one is reused as is
+	collectionName := m.collectionName(resources, resourcePaths)
+	collectionPath := filepath.Join(m.CollectionsDir(), collectionName)
+
+	// Drop collections that weren't used lately
+	m.pruneCollections(collectionName, CollectionTTL)
+
+	// Serialize symlink updates with other btcx processes
+	lock, err := m.acquire(ctx, "collection-"+collectionName)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.release()
+
 	// Create collection directory
 	if err := os.MkdirAll(collectionPath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create collection directory: %w", err)
@@ -80,34 +115,123 @@ func (m *Manager) EnsureCollection(ctx context.Context, resources []*config.Reso
 		Name:      collectionName,
 		Path:      collectionPath,
 		Resources: make([]CollectionResource, 0, len(resources)),
+
+		manifestsDir: m.ManifestsDir(),
 	}
 
 	for _, r := range resources {
 		linkPath := filepath.Join(collectionPath, r.Name)
 		targetPath := resourcePaths[r.Name]
 
-		// Remove existing symlink if it exists
-		if _, err := os.Lstat(linkPath); err == nil {
-			if err := os.Remove(linkPath); err != nil {
-				return nil, fmt.Errorf("failed to remove existing symlink: %w", err)
+		// Keep a symlink that already points at the resource
+		if current, err := os.Readlink(linkPath); err != nil || current != targetPath {
+			// Remove existing symlink if it exists
+			if _, err := os.Lstat(linkPath); err == nil {
+				if err := os.Remove(linkPath); err != nil {
+					return nil, fmt.Errorf("failed to remove existing symlink: %w", err)
+				}
+			}
+
+			// Create symlink
+			if err := os.Symlink(targetPath, linkPath); err != nil {
+				return nil, fmt.Errorf("failed to create symlink: %w", err)
 			}
 		}
 
-		// Create symlink
-		if err := os.Symlink(targetPath, linkPath); err != nil {
-			return nil, fmt.Errorf("failed to create symlink: %w", err)
+		links, err := newLinks(r, m.checkedOutCommit(r, resourceRoots[r.Name]))
+		if err != nil {
+			return nil, fmt.Errorf("resource %q: %w", r.Name, err)
+		}
+
+		// Archives have no .git to read their version from
+		var commit string
+		if r.Type == config.ResourceTypeGit && r.Fetch == config.ResourceFetchArchive {
+			commit = m.archiveCommit(r)
 		}
 
 		collection.Resources = append(collection.Resources, CollectionResource{
-			Name:  r.Name,
-			Path:  targetPath,
-			Notes: r.Notes,
+			Name:       r.Name,
+			Path:       targetPath,
+			Root:       resourceRoots[r.Name],
+			SearchPath: r.SearchPath,
+			Notes:      r.Notes,
+			PromptFile: r.PromptPath(),
+			Links:      links,
+			Commit:     commit,
 		})
 	}
 
+	// Mark the collection as used so it isn't pruned
+	now := time.Now()
+	if err := os.Chtimes(collectionPath, now, now); err != nil {
+		return nil, fmt.Errorf("failed to update collection: %w", err)
+	}
+
 	return collection, nil
 }
 
+// checkedOutCommit returns the commit of a git resource's files, for links
+// ("" if unknown, or the resource doesn't link to its files)
+func (m *Manager) checkedOutCommit(r *config.Resource, root string) string {
+	if r.Links == nil || r.Type != config.ResourceTypeGit {
+		return ""
+	}
+	if r.Fetch == config.ResourceFetchArchive {
+		return m.archiveCommit(r)
+	}
+	return Version(root)
+}
+
+// collectionName names a collection after its sorted resource names and a
+// hash of each resource's source, search path and pinned commit
+func (m *Manager) collectionName(resources []*config.Resource, paths map[string]string) string {
+	sorted := make([]*config.Resource, len(resources))
+	copy(sorted, resources)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	names := make([]string, len(sorted))
+	h := sha256.New()
+	for i, r := range sorted {
+		names[i] = r.Name
+		commit, _ := m.lockedCommit(r)
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s\x00%s\x00%s\n",
+			r.Name, r.Type, r.URL, r.Branch, paths[r.Name], r.SearchPath, commit)
+	}
+	return strings.Join(names, "+") + "-" + hex.EncodeToString(h.Sum(nil))[:8]
+}
+
+// pruneCollections removes collections not used within ttl, except keep
+// Failures are ignored; pruning is retried on the next use
+func (m *Manager) pruneCollections(keep string, ttl time.Duration) {
+	entries, err := os.ReadDir(m.CollectionsDir())
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-ttl)
+	stale := func(path string) bool {
+		info, err := os.Stat(path)
+		return err == nil && info.ModTime().Before(cutoff)
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(m.CollectionsDir(), entry.Name())
+		if !entry.IsDir() || entry.Name() == keep || !stale(path) {
+			continue
+		}
+
+		lock, err := m.acquire(context.Background(), "collection-"+entry.Name())
+		if err != nil {
+			continue
+		}
+		// Another process may have used it while we waited for the lock
+		if stale(path) {
+			_ = os.RemoveAll(path)
+		}
+		lock.release()
+	}
+}
+
 // GetCollection retrieves an existing collection by name
 func (m *Manager) GetCollection(name string) (*Collection, error) {
 	collectionPath := filepath.Join(m.CollectionsDir(), name)
@@ -134,6 +258,8 @@ func (m *Manager) GetCollection(name string) (*Collection, error) {
 		Name:      name,
 		Path:      collectionPath,
 		Resources: make([]CollectionResource, 0, len(entries)),
+
+		manifestsDir: m.ManifestsDir(),
 	}
 
 	for _, entry := range entries {
@@ -180,6 +306,12 @@ func (m *Manager) ListCollections() ([]string, error) {
 
 // RemoveCollection removes a collection
 func (m *Manager) RemoveCollection(name string) error {
+	lock, err := m.acquire(context.Background(), "collection-"+name)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
 	collectionPath := filepath.Join(m.CollectionsDir(), name)
 	if err := os.RemoveAll(collectionPath); err != nil {
 		return fmt.Errorf("failed to remove collection: %w", err)
@@ -0,0 +1,273 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// DiffOptions selects what to compare
+type DiffOptions struct {
+	// From is the older revision (tag, branch or commit)
+	From string
+
+	// To is the newer revision (defaults to the checked out commit)
+	To string
+
+	// Path restricts the diff to files under this path, relative to dir
+	Path string
+}
+
+// FileDiff summarizes the changes to one file
+type FileDiff struct {
+	// Path is the file path relative to the diffed directory
+	Path string
+
+	// Added is the number of added lines
+	Added int
+
+	// Deleted is the number of deleted lines
+	Deleted int
+}
+
+// DiffResult is the comparison of two revisions
+type DiffResult struct {
+	// From and To are the resolved commits
+	From, To plumbing.Hash
+
+	// Files lists the changed files
+	Files []FileDiff
+
+	// Patch is the unified diff, with paths relative to the diffed directory
+	Patch string
+}
+
+// Diff compares two revisions of the git repository containing dir
+// Only files under dir (and opts.Path) are included. Revisions missing from a
+// shallow cached clone are fetched from its origin first
+func Diff(ctx context.Context, dir string, opts DiffOptions) (*DiffResult, error) {
+	if opts.From == "" {
+		return nil, fmt.Errorf("a from revision is required")
+	}
+
+	// Collection entries are symlinks, possibly into a subdirectory of the clone
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+
+	repo, err := git.PlainOpenWithOptions(real, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a git repository", filepath.Base(dir))
+	}
+
+	// Diff paths are relative to the repository root, so find where dir sits
+	prefix, err := repoPrefix(repo, real)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Path != "" {
+		prefix = path.Join(prefix, filepath.ToSlash(opts.Path))
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "." {
+		prefix = ""
+	}
+
+	from, err := resolveRevision(ctx, repo, opts.From)
+	if err != nil {
+		return nil, err
+	}
+	toRev := opts.To
+	if toRev == "" {
+		toRev = "HEAD"
+	}
+	to, err := resolveRevision(ctx, repo, toRev)
+	if err != nil {
+		return nil, err
+	}
+
+	fromTree, err := commitTree(repo, from)
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := commitTree(repo, to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTreeWithOptions(ctx, fromTree, toTree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s..%s: %w", opts.From, toRev, err)
+	}
+
+	var selected object.Changes
+	for _, c := range changes {
+		if underPrefix(c.From.Name, prefix) || underPrefix(c.To.Name, prefix) {
+			selected = append(selected, c)
+		}
+	}
+
+	patch, err := selected.PatchContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s..%s: %w", opts.From, toRev, err)
+	}
+
+	result := &DiffResult{From: from, To: to}
+	for _, stat := range patch.Stats() {
+		result.Files = append(result.Files, FileDiff{
+			Path:    trimPrefix(stat.Name, prefix),
+			Added:   stat.Addition,
+			Deleted: stat.Deletion,
+		})
+	}
+
+	// Rewrite "a/<prefix>/file" headers so paths match the resource layout
+	result.Patch = patch.String()
+	if prefix != "" {
+		result.Patch = strings.NewReplacer("a/"+prefix+"/", "a/", "b/"+prefix+"/", "b/").Replace(result.Patch)
+	}
+	return result, nil
+}
+
+// repoPrefix returns dir relative to the repository root, in slash form
+func repoPrefix(repo *git.Repository, dir string) (string, error) {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	root, err := filepath.EvalSymlinks(worktree.Filesystem.Root())
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve repository root: %w", err)
+	}
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+
+	rel, err := filepath.Rel(root, real)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// resolveRevision resolves a tag, branch or commit, fetching it into shallow
+// clones that don't have it yet
+// Tags are also tried with and without a "v" prefix ("5.0.0" finds "v5.0.0")
+func resolveRevision(ctx context.Context, repo *git.Repository, rev string) (plumbing.Hash, error) {
+	// Alternate spellings are only tried as tags, so "9" can't match a
+	// commit whose hash happens to start with 9
+	names := []string{rev, "refs/tags/" + rev, "refs/remotes/origin/" + rev}
+	tags := []string{rev}
+	if strings.HasPrefix(rev, "v") {
+		tags = append(tags, strings.TrimPrefix(rev, "v"))
+	} else if rev != "HEAD" && !plumbing.IsHash(rev) {
+		tags = append(tags, "v"+rev)
+	}
+	for _, tag := range tags[1:] {
+		names = append(names, "refs/tags/"+tag)
+	}
+
+	lookup := func() (plumbing.Hash, bool) {
+		for _, name := range names {
+			if hash, err := repo.ResolveRevision(plumbing.Revision(name)); err == nil {
+				return *hash, true
+			}
+		}
+		return plumbing.ZeroHash, false
+	}
+
+	if hash, ok := lookup(); ok {
+		return hash, nil
+	}
+
+	if !shallow(repo) {
+		return plumbing.ZeroHash, fmt.Errorf("revision %q not found", rev)
+	}
+
+	// Cached clones are shallow and single-branch; fetch just this revision
+	var specs []gitconfig.RefSpec
+	if plumbing.IsHash(rev) {
+		specs = append(specs, gitconfig.RefSpec(rev+":refs/btcx/diff/"+rev))
+	} else {
+		for _, tag := range tags {
+			specs = append(specs, gitconfig.RefSpec("+refs/tags/"+tag+":refs/tags/"+tag))
+		}
+		specs = append(specs, gitconfig.RefSpec("+refs/heads/"+rev+":refs/remotes/origin/"+rev))
+	}
+	for _, spec := range specs {
+		err := repo.FetchContext(ctx, &git.FetchOptions{
+			RemoteName: "origin",
+			RefSpecs:   []gitconfig.RefSpec{spec},
+			Depth:      1,
+			Tags:       git.NoTags,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			if ctx.Err() != nil {
+				return plumbing.ZeroHash, ctx.Err()
+			}
+			continue
+		}
+		if plumbing.IsHash(rev) {
+			return plumbing.NewHash(rev), nil
+		}
+		if hash, ok := lookup(); ok {
+			return hash, nil
+		}
+	}
+
+	return plumbing.ZeroHash, fmt.Errorf("revision %q not found locally or on the remote", rev)
+}
+
+// shallow reports whether the repository is a shallow clone
+func shallow(repo *git.Repository) bool {
+	commits, err := repo.Storer.Shallow()
+	return err == nil && len(commits) > 0
+}
+
+// commitTree returns the tree of a commit (tags are peeled)
+func commitTree(repo *git.Repository, hash plumbing.Hash) (*object.Tree, error) {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		if tag, tagErr := repo.TagObject(hash); tagErr == nil {
+			commit, err = tag.Commit()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", shortHash(hash), err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree of %s: %w", shortHash(hash), err)
+	}
+	return tree, nil
+}
+
+// underPrefix reports whether a slash path is inside prefix ("" matches all)
+func underPrefix(name, prefix string) bool {
+	if name == "" {
+		return false
+	}
+	return prefix == "" || name == prefix || strings.HasPrefix(name, prefix+"/")
+}
+
+// trimPrefix makes a repository path relative to prefix
+func trimPrefix(name, prefix string) string {
+	if prefix == "" {
+		return name
+	}
+	if name == prefix {
+		return path.Base(name)
+	}
+	return strings.TrimPrefix(name, prefix+"/")
+}
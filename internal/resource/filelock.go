@@ -0,0 +1,58 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockPollInterval is how often a busy lock is retried
+const lockPollInterval = 100 * time.Millisecond
+
+// fileLock is an exclusive advisory lock shared between btcx processes
+type fileLock struct {
+	f *os.File
+}
+
+// LocksDir returns the directory holding cache lock files
+func (m *Manager) LocksDir() string {
+	return filepath.Join(m.cacheDir, "locks")
+}
+
+// acquire takes the named cache lock, waiting until it is free or ctx is done
+func (m *Manager) acquire(ctx context.Context, name string) (*fileLock, error) {
+	if err := os.MkdirAll(m.LocksDir(), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create locks directory: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(m.LocksDir(), name+".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", name, err)
+		}
+		if ok {
+			return &fileLock{f: f}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, fmt.Errorf("waiting for lock %s: %w", name, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// release frees the lock
+func (l *fileLock) release() {
+	unlock(l.f)
+	l.f.Close()
+}
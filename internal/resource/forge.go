@@ -0,0 +1,332 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/config"
+)
+
+// forgeTokenEnv is the environment variable each forge's token is read from
+// when the resource doesn't set one
+var forgeTokenEnv = map[string]string{
+	config.ForgeGitHub:    "GITHUB_TOKEN",
+	config.ForgeGitLab:    "GITLAB_TOKEN",
+	config.ForgeBitbucket: "BITBUCKET_TOKEN",
+	config.ForgeGitea:     "GITEA_TOKEN",
+}
+
+// repoURL is a repository URL reduced to its host and path
+type repoURL struct {
+	// Scheme is http or https
+	Scheme string
+	// Host is the host and port, e.g. "gitlab.example.com"
+	Host string
+	// Path is the repository path without .git, e.g. "group/sub/project"
+	Path string
+}
+
+// parseRepoURL normalizes clone and browser URLs of a repository: https and
+// ssh URLs, scp-style git@host:owner/repo, a missing scheme, a .git suffix
+// and pages within the repository such as /-/tree/main (GitLab), /tree/main
+// (GitHub) or /src/main (Bitbucket and Gitea)
+func parseRepoURL(raw, forge string) (*repoURL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") && !strings.Contains(raw, ":") {
+		// host/owner/repo
+		raw = "https://" + raw
+	}
+	if !strings.Contains(raw, "://") {
+		// scp-style: git@host:owner/repo.git
+		at := strings.Index(raw, "@")
+		colon := strings.Index(raw, ":")
+		if colon < 0 || (strings.Contains(raw, "/") && strings.Index(raw, "/") < colon) {
+			return nil, fmt.Errorf("not a repository URL: %s", raw)
+		}
+		raw = "ssh://" + raw[at+1:colon] + "/" + raw[colon+1:]
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("repository URL has no host: %s", raw)
+	}
+
+	repo := &repoURL{Scheme: "https", Host: u.Host}
+	switch u.Scheme {
+	case "http", "https":
+		repo.Scheme = u.Scheme
+	default:
+		// ssh and git URLs use the forge's web port
+		repo.Host = u.Hostname()
+	}
+
+	path := strings.Trim(u.Path, "/")
+	if before, _, found := strings.Cut(path, "/-/"); found {
+		path = before
+	}
+	if forge == "" {
+		forge = detectForge(repo.Host)
+	}
+	if forge != config.ForgeGitLab {
+		// Only GitLab nests repositories in groups; the rest is a page
+		if parts := strings.SplitN(path, "/", 3); len(parts) == 3 {
+			path = parts[0] + "/" + parts[1]
+		}
+	}
+	path = strings.TrimSuffix(path, ".git")
+	if strings.Count(path, "/") < 1 {
+		return nil, fmt.Errorf("repository URL has no owner and name: %s", raw)
+	}
+	repo.Path = path
+	return repo, nil
+}
+
+// detectForge guesses the forge hosting a repository from its host ("" if
+// unknown)
+func detectForge(host string) string {
+	host = strings.ToLower(host)
+	switch {
+	case host == "github.com" || strings.Contains(host, "github"):
+		return config.ForgeGitHub
+	case host == "gitlab.com" || strings.Contains(host, "gitlab"):
+		return config.ForgeGitLab
+	case host == "bitbucket.org":
+		return config.ForgeBitbucket
+	case host == "codeberg.org" || strings.Contains(host, "gitea") || strings.Contains(host, "forgejo"):
+		return config.ForgeGitea
+	default:
+		return ""
+	}
+}
+
+// cloneURL returns the URL to clone a git resource from
+// Browser URLs of known forges, such as a GitLab /-/tree/main page, are
+// turned into the repository's URL; anything else is used as is
+func cloneURL(r *config.Resource) string {
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || detectForge(u.Host) == "" {
+		return r.URL
+	}
+	repo, err := parseRepoURL(r.URL, r.Forge)
+	if err != nil {
+		return r.URL
+	}
+	return repo.Scheme + "://" + repo.Host + "/" + repo.Path + ".git"
+}
+
+// forgeClient talks to the API of the forge hosting a repository
+type forgeClient struct {
+	forge string
+	repo  *repoURL
+	token string
+	http  *http.Client
+}
+
+// newForgeClient returns a client for the forge hosting a git resource
+func newForgeClient(r *config.Resource) (*forgeClient, error) {
+	forge := r.Forge
+	repo, err := parseRepoURL(r.URL, forge)
+	if err != nil {
+		return nil, err
+	}
+	if forge == "" {
+		if forge = detectForge(repo.Host); forge == "" {
+			return nil, fmt.Errorf("can't tell which forge hosts %s; set forge to github, gitlab, bitbucket or gitea", repo.Host)
+		}
+	}
+
+	token := os.ExpandEnv(r.Token)
+	if token == "" {
+		token = os.Getenv(forgeTokenEnv[forge])
+	}
+	return &forgeClient{forge: forge, repo: repo, token: token, http: http.DefaultClient}, nil
+}
+
+// apiBase returns the root of the forge's REST API
+func (f *forgeClient) apiBase() string {
+	web := f.repo.Scheme + "://" + f.repo.Host
+	switch f.forge {
+	case config.ForgeGitHub:
+		if f.repo.Host == "github.com" {
+			return "https://api.github.com"
+		}
+		// GitHub Enterprise Server
+		return web + "/api/v3"
+	case config.ForgeGitLab:
+		return web + "/api/v4"
+	case config.ForgeBitbucket:
+		return "https://api.bitbucket.org/2.0"
+	default:
+		return web + "/api/v1"
+	}
+}
+
+// repoAPI returns the API URL of the repository
+func (f *forgeClient) repoAPI() string {
+	switch f.forge {
+	case config.ForgeGitLab:
+		return f.apiBase() + "/projects/" + url.PathEscape(f.repo.Path)
+	case config.ForgeBitbucket:
+		return f.apiBase() + "/repositories/" + f.repo.Path
+	default:
+		return f.apiBase() + "/repos/" + f.repo.Path
+	}
+}
+
+// resolve returns the commit a branch points to, or the default branch's
+// commit if branch is ""
+func (f *forgeClient) resolve(ctx context.Context, branch string) (string, error) {
+	if f.forge == config.ForgeGitHub {
+		// GitHub resolves HEAD and answers with the bare SHA
+		ref := branch
+		if ref == "" {
+			ref = "HEAD"
+		}
+		body, err := f.get(ctx, f.repoAPI()+"/commits/"+url.PathEscape(ref), "application/vnd.github.sha")
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		sha, err := io.ReadAll(io.LimitReader(body, 1024))
+		if err != nil {
+			return "", fmt.Errorf("failed to read commit: %w", err)
+		}
+		return strings.TrimSpace(string(sha)), nil
+	}
+
+	if branch == "" {
+		var err error
+		if branch, err = f.defaultBranch(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	var commit struct {
+		ID   string `json:"id"`   // GitLab
+		Hash string `json:"hash"` // Bitbucket
+		SHA  string `json:"sha"`  // Gitea
+	}
+	endpoint := f.repoAPI() + "/commit/" + url.PathEscape(branch)
+	switch f.forge {
+	case config.ForgeGitLab:
+		endpoint = f.repoAPI() + "/repository/commits/" + url.PathEscape(branch)
+	case config.ForgeGitea:
+		endpoint = f.repoAPI() + "/git/commits/" + url.PathEscape(branch)
+	}
+	if err := f.getJSON(ctx, endpoint, &commit); err != nil {
+		return "", err
+	}
+
+	for _, sha := range []string{commit.ID, commit.Hash, commit.SHA} {
+		if sha != "" {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("%s returned no commit for %s", f.forge, branch)
+}
+
+// defaultBranch returns the name of the repository's default branch
+func (f *forgeClient) defaultBranch(ctx context.Context) (string, error) {
+	var repo struct {
+		DefaultBranch string `json:"default_branch"` // GitLab and Gitea
+		MainBranch    struct {
+			Name string `json:"name"`
+		} `json:"mainbranch"` // Bitbucket
+	}
+	if err := f.getJSON(ctx, f.repoAPI(), &repo); err != nil {
+		return "", err
+	}
+	if repo.DefaultBranch != "" {
+		return repo.DefaultBranch, nil
+	}
+	if repo.MainBranch.Name != "" {
+		return repo.MainBranch.Name, nil
+	}
+	return "", fmt.Errorf("%s reports no default branch for %s", f.forge, f.repo.Path)
+}
+
+// archiveURL returns the URL of a gzipped tarball of the repository at commit
+func (f *forgeClient) archiveURL(commit string) string {
+	switch f.forge {
+	case config.ForgeGitHub:
+		return f.repoAPI() + "/tarball/" + commit
+	case config.ForgeGitLab:
+		return f.repoAPI() + "/repository/archive.tar.gz?sha=" + url.QueryEscape(commit)
+	case config.ForgeBitbucket:
+		// Archives are served by the website, not the API
+		return f.repo.Scheme + "://" + f.repo.Host + "/" + f.repo.Path + "/get/" + commit + ".tar.gz"
+	default:
+		return f.repoAPI() + "/archive/" + commit + ".tar.gz"
+	}
+}
+
+// getJSON fetches an API endpoint and decodes its JSON response into v
+func (f *forgeClient) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	body, err := f.get(ctx, endpoint, "application/json")
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", f.forge, err)
+	}
+	return nil
+}
+
+// get sends an authenticated GET request and returns the response body,
+// turning error statuses into errors
+func (f *forgeClient) get(ctx context.Context, endpoint, accept string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", "btcx")
+	f.authorize(req)
+
+	resp, err := f.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", f.repo.Host, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("%s returned %s for %s: %s", f.forge, resp.Status, f.repo.Path, strings.TrimSpace(string(detail)))
+		if (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusNotFound) && f.token == "" {
+			err = fmt.Errorf("%w (private repositories need a token: set token or %s)", err, forgeTokenEnv[f.forge])
+		}
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// authorize adds the token to a request the way the forge expects it
+func (f *forgeClient) authorize(req *http.Request) {
+	if f.token == "" {
+		return
+	}
+	switch f.forge {
+	case config.ForgeGitLab:
+		req.Header.Set("PRIVATE-TOKEN", f.token)
+	case config.ForgeGitea:
+		req.Header.Set("Authorization", "token "+f.token)
+	case config.ForgeBitbucket:
+		// App passwords are given as user:password
+		if user, password, ok := strings.Cut(f.token, ":"); ok {
+			req.SetBasicAuth(user, password)
+			return
+		}
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	default:
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+}
@@ -14,14 +14,66 @@ import (
 func (m *Manager) ensureGit(ctx context.Context, r *config.Resource) (string, error) {
 	path := m.ResourcePath(r.Name)
 
+	// Keep other btcx processes from cloning or pulling the same copy
+	lock, err := m.acquire(ctx, "resource-"+r.Name)
+	if err != nil {
+		return path, err
+	}
+	defer lock.release()
+
+	commit, err := m.lockedCommit(r)
+	if err != nil {
+		return path, err
+	}
+
+	// A copy downloaded as an archive has no history to pull
+	if _, err := os.Stat(path); err == nil && !isRepo(path) {
+		if err := os.RemoveAll(path); err != nil {
+			return path, fmt.Errorf("failed to remove archive copy: %w", err)
+		}
+	}
+
 	// Check if already cloned
 	if _, err := os.Stat(path); err == nil {
-		// Already exists, try to pull
-		return path, m.pullGit(ctx, path, r)
+		// Locked resources stay on their pinned commit
+		if commit != "" {
+			return path, m.checkoutGit(ctx, path, r, commit)
+		}
+
+		// A copy left on a locked commit can't be pulled; clone it afresh
+		if !detached(path) {
+			// Already exists, try to pull
+			return path, m.pullGit(ctx, path, r)
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return path, fmt.Errorf("failed to remove locked copy: %w", err)
+		}
 	}
 
 	// Clone the repository
-	return path, m.cloneGit(ctx, path, r)
+	if err := m.cloneGit(ctx, path, r); err != nil {
+		return path, err
+	}
+	if commit != "" {
+		return path, m.checkoutGit(ctx, path, r, commit)
+	}
+	return path, nil
+}
+
+// isRepo reports whether path is a git repository
+func isRepo(path string) bool {
+	_, err := git.PlainOpen(path)
+	return err == nil
+}
+
+// detached reports whether the repository at path has a detached HEAD
+func detached(path string) bool {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return false
+	}
+	head, err := repo.Head()
+	return err == nil && head.Name() == plumbing.HEAD
 }
 
 // cloneGit clones a git repository
@@ -32,7 +84,7 @@ func (m *Manager) cloneGit(ctx context.Context, path string, r *config.Resource)
 	}
 
 	opts := &git.CloneOptions{
-		URL:      r.URL,
+		URL:      cloneURL(r),
 		Progress: nil, // TODO: Add progress reporting
 		Depth:    1,   // Shallow clone for speed
 	}
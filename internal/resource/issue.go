@@ -0,0 +1,376 @@
+package resource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nickcecere/btcx/internal/config"
+)
+
+// Issue size limits keep a long discussion from crowding out the evidence
+const (
+	// maxIssueComments is the most comments fetched
+	maxIssueComments = 300
+
+	// maxIssueCommentChars is the most characters kept of one comment
+	maxIssueCommentChars = 4000
+
+	// maxIssueChars is the most characters of comments kept in total; the
+	// earliest and latest comments are kept
+	maxIssueChars = 40000
+)
+
+// Issue is a GitHub issue, pull request or discussion with its comments
+type Issue struct {
+	// Kind is issue, pull request or discussion
+	Kind string
+
+	// Repo is the repository, e.g. "spf13/cobra"
+	Repo string
+
+	// Number is the issue number
+	Number int
+
+	// URL is the issue's web page
+	URL string
+
+	// Title is the issue title
+	Title string
+
+	// State is open or closed ("" for discussions)
+	State string
+
+	// Author is the login of who opened it
+	Author string
+
+	// Body is the opening post
+	Body string
+
+	// Comments are the comments, oldest first
+	Comments []IssueComment
+}
+
+// IssueComment is a comment on an issue
+type IssueComment struct {
+	Author  string
+	Body    string
+	Created time.Time
+}
+
+// issueURL is a parsed link to an issue, pull request or discussion
+type issueURL struct {
+	client *forgeClient
+	kind   string
+	number int
+}
+
+// parseIssueURL parses a GitHub (or GitHub Enterprise) link such as
+// https://github.com/owner/repo/issues/123, .../pull/123 or
+// .../discussions/123
+// The token of resources on the same host authenticates it (see issueToken)
+func parseIssueURL(raw string, resources []config.Resource) (*issueURL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("not an issue URL: %s", raw)
+	}
+	if detectForge(u.Host) != config.ForgeGitHub {
+		return nil, fmt.Errorf("%s isn't a GitHub issue; only GitHub issues, pull requests and discussions can be fetched", raw)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 4 {
+		return nil, fmt.Errorf("not an issue URL: %s (expected .../owner/repo/issues/123)", raw)
+	}
+	var kind string
+	switch parts[2] {
+	case "issues":
+		kind = "issue"
+	case "pull":
+		kind = "pull request"
+	case "discussions":
+		kind = "discussion"
+	default:
+		return nil, fmt.Errorf("not an issue URL: %s (expected issues, pull or discussions)", raw)
+	}
+	number, err := strconv.Atoi(parts[3])
+	if err != nil || number <= 0 {
+		return nil, fmt.Errorf("not an issue URL: %s (no issue number)", raw)
+	}
+
+	client, err := newForgeClient(&config.Resource{
+		URL:   u.Scheme + "://" + u.Host + "/" + parts[0] + "/" + parts[1],
+		Forge: config.ForgeGitHub,
+	})
+	if err != nil {
+		return nil, err
+	}
+	client.token = issueToken(u, resources)
+	return &issueURL{client: client, kind: kind, number: number}, nil
+}
+
+// issueToken returns the token to fetch an issue with ("" for none)
+// GITHUB_TOKEN only goes to github.com; any other host (which merely looks
+// like GitHub) gets a token only over https, and only one configured
+// explicitly on a resource of that host
+func issueToken(u *url.URL, resources []config.Resource) string {
+	host := strings.ToLower(u.Host)
+	if host == "github.com" {
+		return os.Getenv(forgeTokenEnv[config.ForgeGitHub])
+	}
+	if u.Scheme != "https" {
+		return ""
+	}
+	for _, r := range resources {
+		if r.Token == "" {
+			continue
+		}
+		if repo, err := parseRepoURL(r.URL, r.Forge); err == nil && repo.Scheme == "https" && strings.ToLower(repo.Host) == host {
+			return os.ExpandEnv(r.Token)
+		}
+	}
+	return ""
+}
+
+// FetchIssue fetches a GitHub issue, pull request or discussion and its
+// comments from its web URL, using GITHUB_TOKEN on github.com and the token
+// of a configured resource on other hosts (discussions need one)
+func FetchIssue(ctx context.Context, rawURL string, resources []config.Resource) (*Issue, error) {
+	ref, err := parseIssueURL(rawURL, resources)
+	if err != nil {
+		return nil, err
+	}
+	if ref.kind == "discussion" {
+		return ref.fetchDiscussion(ctx)
+	}
+	return ref.fetchIssue(ctx)
+}
+
+// githubUser is the author of an issue or comment in GitHub's APIs
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+// fetchIssue fetches an issue or pull request through the REST API, which
+// serves both as issues
+func (r *issueURL) fetchIssue(ctx context.Context) (*Issue, error) {
+	f := r.client
+	endpoint := f.repoAPI() + "/issues/" + strconv.Itoa(r.number)
+
+	var raw struct {
+		Title   string     `json:"title"`
+		Body    string     `json:"body"`
+		State   string     `json:"state"`
+		HTMLURL string     `json:"html_url"`
+		User    githubUser `json:"user"`
+	}
+	if err := f.getJSON(ctx, endpoint, &raw); err != nil {
+		return nil, err
+	}
+
+	issue := &Issue{
+		Kind:   r.kind,
+		Repo:   f.repo.Path,
+		Number: r.number,
+		URL:    raw.HTMLURL,
+		Title:  raw.Title,
+		State:  raw.State,
+		Author: raw.User.Login,
+		Body:   raw.Body,
+	}
+
+	for page := 1; len(issue.Comments) < maxIssueComments; page++ {
+		var comments []struct {
+			Body      string     `json:"body"`
+			User      githubUser `json:"user"`
+			CreatedAt time.Time  `json:"created_at"`
+		}
+		if err := f.getJSON(ctx, fmt.Sprintf("%s/comments?per_page=100&page=%d", endpoint, page), &comments); err != nil {
+			return nil, err
+		}
+		for _, c := range comments {
+			issue.Comments = append(issue.Comments, IssueComment{Author: c.User.Login, Body: c.Body, Created: c.CreatedAt})
+		}
+		if len(comments) < 100 {
+			break
+		}
+	}
+	return issue, nil
+}
+
+// discussionQuery fetches a discussion and its first comments; discussions
+// are only in GitHub's GraphQL API
+const discussionQuery = `query($owner: String!, $name: String!, $number: Int!) {
+  repository(owner: $owner, name: $name) {
+    discussion(number: $number) {
+      title url body
+      author { login }
+      comments(first: 100) {
+        nodes { body createdAt author { login } }
+      }
+    }
+  }
+}`
+
+// fetchDiscussion fetches a discussion through the GraphQL API
+func (r *issueURL) fetchDiscussion(ctx context.Context) (*Issue, error) {
+	f := r.client
+	if f.token == "" {
+		if f.repo.Host != "github.com" {
+			return nil, fmt.Errorf("GitHub's API for discussions needs a token: set token on a resource from %s (https only)", f.repo.Host)
+		}
+		return nil, fmt.Errorf("GitHub's API for discussions needs a token: set %s", forgeTokenEnv[config.ForgeGitHub])
+	}
+
+	owner, name, _ := strings.Cut(f.repo.Path, "/")
+	payload, err := json.Marshal(map[string]interface{}{
+		"query":     discussionQuery,
+		"variables": map[string]interface{}{"owner": owner, "name": name, "number": r.number},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode query: %w", err)
+	}
+
+	// GitHub Enterprise serves GraphQL beside the REST API, not under it
+	endpoint := "https://api.github.com/graphql"
+	if f.repo.Host != "github.com" {
+		endpoint = f.repo.Scheme + "://" + f.repo.Host + "/api/graphql"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "btcx")
+	f.authorize(req)
+
+	resp, err := f.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", f.repo.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("github returned %s for %s: %s", resp.Status, f.repo.Path, strings.TrimSpace(string(detail)))
+	}
+
+	var result struct {
+		Data struct {
+			Repository struct {
+				Discussion *struct {
+					Title    string     `json:"title"`
+					URL      string     `json:"url"`
+					Body     string     `json:"body"`
+					Author   githubUser `json:"author"`
+					Comments struct {
+						Nodes []struct {
+							Body      string     `json:"body"`
+							CreatedAt time.Time  `json:"createdAt"`
+							Author    githubUser `json:"author"`
+						} `json:"nodes"`
+					} `json:"comments"`
+				} `json:"discussion"`
+			} `json:"repository"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode github response: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("github: %s", result.Errors[0].Message)
+	}
+	d := result.Data.Repository.Discussion
+	if d == nil {
+		return nil, fmt.Errorf("discussion %d not found in %s", r.number, f.repo.Path)
+	}
+
+	issue := &Issue{
+		Kind:   r.kind,
+		Repo:   f.repo.Path,
+		Number: r.number,
+		URL:    d.URL,
+		Title:  d.Title,
+		Author: d.Author.Login,
+		Body:   d.Body,
+	}
+	for _, c := range d.Comments.Nodes {
+		issue.Comments = append(issue.Comments, IssueComment{Author: c.Author.Login, Body: c.Body, Created: c.CreatedAt})
+	}
+	return issue, nil
+}
+
+// Markdown renders the issue and its comments for a prompt; long comments
+// are cut, and when the comments are too long in total the middle ones are
+// left out
+func (i *Issue) Markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## %s %s#%d: %s\n\n", capitalize(i.Kind), i.Repo, i.Number, i.Title)
+	fmt.Fprintf(&sb, "URL: %s\n", i.URL)
+	if i.State != "" {
+		fmt.Fprintf(&sb, "State: %s\n", i.State)
+	}
+	fmt.Fprintf(&sb, "Opened by: %s\n\n", i.Author)
+	sb.WriteString(cutText(i.Body, maxIssueCommentChars))
+	sb.WriteString("\n")
+
+	// Keep comments from both ends: the report and the latest findings
+	comments := make([]string, len(i.Comments))
+	for n, c := range i.Comments {
+		comments[n] = fmt.Sprintf("\n### Comment by %s (%s)\n\n%s\n", c.Author, c.Created.Format("2006-01-02"), cutText(c.Body, maxIssueCommentChars))
+	}
+	first, last := 0, len(comments)
+	total := 0
+	for first < last {
+		if total+len(comments[first]) > maxIssueChars {
+			break
+		}
+		total += len(comments[first])
+		first++
+		if first < last && total+len(comments[last-1]) <= maxIssueChars {
+			total += len(comments[last-1])
+			last--
+		}
+	}
+	for _, c := range comments[:first] {
+		sb.WriteString(c)
+	}
+	if omitted := last - first; omitted > 0 {
+		fmt.Fprintf(&sb, "\n[%d comments omitted]\n", omitted)
+	}
+	for _, c := range comments[last:] {
+		sb.WriteString(c)
+	}
+	return sb.String()
+}
+
+// cutText shortens text to at most max characters, noting the cut
+func cutText(text string, max int) string {
+	text = strings.TrimSpace(text)
+	if len(text) <= max {
+		return text
+	}
+	for max > 0 && !utf8.RuneStart(text[max]) {
+		max--
+	}
+	return text[:max] + "\n[...]"
+}
+
+// capitalize upper-cases the first letter of s
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,35 @@
+package resource
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/nickcecere/btcx/internal/config"
+)
+
+func TestIssueToken(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "env-token")
+	resources := []config.Resource{
+		{Name: "internal", URL: "https://github.example.com/org/repo", Token: "ghe-token"},
+		{Name: "plain", URL: "http://github.plain.example/org/repo", Token: "plain-token"},
+	}
+
+	tests := []struct {
+		url, want string
+	}{
+		{"https://github.com/spf13/cobra/issues/1", "env-token"},
+		{"https://github.example.com/org/repo/issues/1", "ghe-token"},
+		{"http://github.example.com/org/repo/issues/1", ""},
+		{"http://github.plain.example/org/repo/issues/1", ""},
+		{"https://github.attacker.example/org/repo/issues/1", ""},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := issueToken(u, resources); got != tt.want {
+			t.Errorf("issueToken(%s) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package resource
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxLicenseBytes caps how much of a license file is read
+const maxLicenseBytes = 64 * 1024
+
+// licenseFiles are the file names checked for a license, in order
+var licenseFiles = []string{
+	"LICENSE", "LICENSE.md", "LICENSE.txt", "LICENCE", "LICENCE.md", "LICENCE.txt",
+	"COPYING", "COPYING.md", "COPYING.txt", "LICENSE-MIT", "LICENSE-APACHE",
+}
+
+// License is the license a resource is distributed under
+type License struct {
+	// SPDX is the SPDX identifier, e.g. "MIT" or "Apache-2.0"; dual-licensed
+	// resources are joined with " OR ", unrecognized ones are "NOASSERTION"
+	SPDX string `json:"spdx"`
+
+	// File is the license file, relative to the resource's directory
+	File string `json:"file"`
+
+	// Copyright are the copyright lines of the license file
+	Copyright []string `json:"copyright,omitempty"`
+}
+
+// Attribution formats the license for a citation, e.g.
+// "MIT, Copyright (c) 2013 Steve Francia"
+func (l *License) Attribution() string {
+	if len(l.Copyright) == 0 {
+		return l.SPDX
+	}
+	return l.SPDX + ", " + strings.Join(l.Copyright, "; ")
+}
+
+// License detects the resource's license
+// The nearest license file wins, from the search path up to the resource root,
+// so packages in a monorepo keep their own license
+func (r CollectionResource) License() *License {
+	dir := r.Path
+	for {
+		if l := licenseIn(dir); l != nil {
+			if rel, err := filepath.Rel(r.Path, filepath.Join(dir, l.File)); err == nil {
+				l.File = filepath.ToSlash(rel)
+			}
+			return l
+		}
+		parent := filepath.Dir(dir)
+		if r.Root == "" || dir == r.Root || parent == dir || !strings.HasPrefix(parent, r.Root) {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// licenseIn reads the license files in dir
+func licenseIn(dir string) *License {
+	var license *License
+	var ids []string
+	for _, name := range licenseFiles {
+		text, err := readLicense(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+
+		id := identifyLicense(text)
+		if license == nil {
+			license = &License{File: name, Copyright: copyrightLines(text)}
+		}
+		if !containsString(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if license == nil {
+		return nil
+	}
+
+	// Recognized licenses take precedence over unrecognized extra files
+	if len(ids) > 1 {
+		known := ids[:0]
+		for _, id := range ids {
+			if id != "NOASSERTION" {
+				known = append(known, id)
+			}
+		}
+		ids = known
+	}
+	license.SPDX = strings.Join(ids, " OR ")
+	return license
+}
+
+// readLicense reads the start of a license file
+func readLicense(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxLicenseBytes))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// spdxPattern matches an SPDX identifier header in a license file
+var spdxPattern = regexp.MustCompile(`SPDX-License-Identifier:\s*([A-Za-z0-9.+-]+(?:\s+(?:OR|AND|WITH)\s+[A-Za-z0-9.+-]+)*)`)
+
+// licenseSignatures identify licenses by phrases from their text
+// GNU licenses mention each other, so they are matched by their title
+var licenseSignatures = []struct {
+	id      string
+	phrases []string
+}{
+	{"AGPL-3.0", []string{"gnu affero general public license version 3"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license version 2.1"}},
+	{"GPL-3.0", []string{"gnu general public license version 3"}},
+	{"GPL-2.0", []string{"gnu general public license version 2"}},
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"MPL-2.0", []string{"mozilla public license", "2.0"}},
+	{"EPL-2.0", []string{"eclipse public license", "2.0"}},
+	{"BSL-1.0", []string{"boost software license"}},
+	{"CC0-1.0", []string{"cc0 1.0"}},
+	{"Unlicense", []string{"free and unencumbered software released into the public domain"}},
+	{"Zlib", []string{"provided 'as-is'", "altered source versions must be plainly marked"}},
+	{"ISC", []string{"permission to use, copy, modify, and", "distribute this software for any purpose with or without fee"}},
+	{"MIT", []string{"permission is hereby granted, free of charge"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "names of its contributors"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+}
+
+// identifyLicense returns the SPDX identifier of a license text
+func identifyLicense(text string) string {
+	if m := spdxPattern.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+
+	normalized := strings.Join(strings.Fields(strings.ToLower(text)), " ")
+	for _, sig := range licenseSignatures {
+		matched := true
+		for _, phrase := range sig.phrases {
+			if !strings.Contains(normalized, phrase) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return sig.id
+		}
+	}
+	return "NOASSERTION"
+}
+
+// copyrightLines returns the copyright notices of a license text
+// Template placeholders and the license authors' own notices are skipped
+func copyrightLines(text string) []string {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		lower := strings.ToLower(line)
+		if !strings.HasPrefix(lower, "copyright ") && !strings.HasPrefix(lower, "copyright©") && !strings.HasPrefix(lower, "©") {
+			continue
+		}
+		if strings.ContainsAny(line, "[]{}<>") && (strings.Contains(lower, "yyyy") || strings.Contains(lower, "name of")) {
+			continue
+		}
+		if strings.Contains(lower, "free software foundation") || strings.Contains(lower, "copyright notice") ||
+			strings.Contains(lower, "copyright holder") || strings.Contains(lower, "copyright owner") {
+			continue
+		}
+		if len(line) > 200 {
+			line = strings.ToValidUTF8(line[:200], "")
+		}
+		lines = append(lines, line)
+		if len(lines) == 3 {
+			break
+		}
+	}
+	return lines
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
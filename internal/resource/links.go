@@ -0,0 +1,137 @@
+package resource
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/config"
+)
+
+// Links maps the files of a collection resource to their paths in the
+// resource and their upstream web URLs
+type Links struct {
+	// url is the file URL template with {commit} and {branch} filled in
+	url string
+
+	// anchor formats a line range for URLs built by links.url auto
+	anchor func(start, end int) string
+
+	// rewrite maps directory prefixes of generated files to their sources,
+	// longest first, without leading or trailing slashes
+	rewrite [][2]string
+}
+
+// forgeFileURLs are the file URL templates and line anchors of each forge,
+// after the repository's base URL
+var forgeFileURLs = map[string]struct {
+	path   string
+	anchor func(start, end int) string
+}{
+	config.ForgeGitHub: {"/blob/{commit}/{path}", func(start, end int) string {
+		return fmt.Sprintf("#L%d-L%d", start, end)
+	}},
+	config.ForgeGitLab: {"/-/blob/{commit}/{path}", func(start, end int) string {
+		return fmt.Sprintf("#L%d-%d", start, end)
+	}},
+	config.ForgeBitbucket: {"/src/{commit}/{path}", func(start, end int) string {
+		return fmt.Sprintf("#lines-%d:%d", start, end)
+	}},
+	config.ForgeGitea: {"/src/commit/{commit}/{path}", func(start, end int) string {
+		return fmt.Sprintf("#L%d-L%d", start, end)
+	}},
+}
+
+// newLinks resolves a resource's links for the commit checked out
+// It returns nil when the resource doesn't configure links
+func newLinks(r *config.Resource, commit string) (*Links, error) {
+	if r.Links == nil {
+		return nil, nil
+	}
+
+	links := &Links{url: r.Links.URL}
+	for from, to := range r.Links.Rewrite {
+		links.rewrite = append(links.rewrite, [2]string{strings.Trim(from, "/"), strings.Trim(to, "/")})
+	}
+	sort.Slice(links.rewrite, func(i, j int) bool {
+		return len(links.rewrite[i][0]) > len(links.rewrite[j][0])
+	})
+
+	if links.url == config.LinksURLAuto {
+		repo, err := parseRepoURL(r.URL, r.Forge)
+		if err != nil {
+			return nil, err
+		}
+		forge := r.Forge
+		if forge == "" {
+			forge = detectForge(repo.Host)
+		}
+		f, ok := forgeFileURLs[forge]
+		if !ok {
+			return nil, fmt.Errorf("links.url auto: unknown forge for %s (set forge)", repo.Host)
+		}
+		links.url = repo.Scheme + "://" + repo.Host + "/" + repo.Path + f.path
+		links.anchor = f.anchor
+	}
+
+	// Without a known commit, links point at the branch
+	ref := commit
+	if ref == "" {
+		ref = r.Branch
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	links.url = strings.NewReplacer("{commit}", ref, "{branch}", r.Branch).Replace(links.url)
+	return links, nil
+}
+
+// SourcePath returns the path in the resource's repository or directory of
+// a path relative to the resource's collection directory: its searchPath
+// is prefixed and links.rewrite applied
+// Rewrite prefixes are whole directories, so dist matches dist/app.js but
+// not distro/app.js
+func (r CollectionResource) SourcePath(rel string) string {
+	p := path.Join(strings.Trim(r.SearchPath, "/"), rel)
+	if r.Links == nil {
+		return p
+	}
+	for _, rw := range r.Links.rewrite {
+		switch {
+		case rw[0] == "" || p == rw[0]:
+			return path.Join(rw[1], strings.TrimPrefix(p, rw[0]))
+		case strings.HasPrefix(p, rw[0]+"/"):
+			return path.Join(rw[1], p[len(rw[0])+1:])
+		}
+	}
+	return p
+}
+
+// FileURL returns the upstream web URL of a source path, pointing at the
+// lines start to end when start is set ("" without links.url)
+func (r CollectionResource) FileURL(sourcePath string, start, end int) string {
+	if r.Links == nil || r.Links.url == "" {
+		return ""
+	}
+	if end < start {
+		end = start
+	}
+
+	line := start
+	if line == 0 {
+		line = 1
+	}
+	// Each segment is escaped, keeping the slashes between them
+	segments := strings.Split(sourcePath, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	u := strings.NewReplacer("{path}", strings.Join(segments, "/"), "{line}", strconv.Itoa(line)).Replace(r.Links.url)
+	if r.Links.anchor != nil && start > 0 {
+		u += r.Links.anchor(start, end)
+	}
+	return u
+}
@@ -0,0 +1,237 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/nickcecere/btcx/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// lockHeader is written at the top of generated lockfiles
+const lockHeader = "# Generated by btcx resources lock. Commit this file for reproducible answers.\n"
+
+// lockedRef is the ref locked commits are fetched into
+const lockedRef = "refs/btcx/locked"
+
+// Lock pins git resources to exact commits
+type Lock struct {
+	Resources map[string]LockedResource `yaml:"resources"`
+}
+
+// LockedResource is the pinned commit of one git resource
+type LockedResource struct {
+	URL    string `yaml:"url"`
+	Branch string `yaml:"branch,omitempty"`
+	Commit string `yaml:"commit"`
+}
+
+// LoadLock reads a lockfile, returning nil if it doesn't exist
+func LoadLock(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read lockfile: %w", err)
+	}
+
+	var lock Lock
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile %s: %w", path, err)
+	}
+	for name, r := range lock.Resources {
+		if !plumbing.IsHash(r.Commit) {
+			return nil, fmt.Errorf("lockfile %s: invalid commit for %s: %q", path, name, r.Commit)
+		}
+	}
+	return &lock, nil
+}
+
+// SaveLock writes a lockfile
+func SaveLock(path string, lock *Lock) error {
+	data, err := yaml.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lockfile: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create lockfile directory: %w", err)
+	}
+	if err := os.WriteFile(path, append([]byte(lockHeader), data...), 0644); err != nil {
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+	return nil
+}
+
+// Names returns the locked resource names in order
+func (l *Lock) Names() []string {
+	names := make([]string, 0, len(l.Resources))
+	for name := range l.Resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithLockFile makes Ensure check out the commits pinned in the lockfile at
+// path, if it exists. The file is read on first use
+func (m *Manager) WithLockFile(path string) *Manager {
+	m.lockFile = path
+	return m
+}
+
+// lockedCommit returns the commit pinned for a git resource, or "" if the
+// resource isn't locked
+func (m *Manager) lockedCommit(r *config.Resource) (string, error) {
+	if m.lockFile == "" {
+		return "", nil
+	}
+
+	m.lockOnce.Do(func() {
+		m.lock, m.lockErr = LoadLock(m.lockFile)
+	})
+	if m.lockErr != nil {
+		return "", m.lockErr
+	}
+	if m.lock == nil {
+		return "", nil
+	}
+
+	locked, ok := m.lock.Resources[r.Name]
+	if !ok {
+		return "", nil
+	}
+	if locked.URL != r.URL {
+		return "", fmt.Errorf("lockfile pins %s to %s but the config uses %s (run btcx resources lock to update it)", r.Name, locked.URL, r.URL)
+	}
+	return locked.Commit, nil
+}
+
+// Lock resolves the checked-out commit of each git resource, cloning those
+// that aren't cached yet. With update, resources are pulled first so the
+// lock pins the latest commits
+func (m *Manager) Lock(ctx context.Context, resources []config.Resource, update bool) (*Lock, error) {
+	lock := &Lock{Resources: make(map[string]LockedResource)}
+
+	for i := range resources {
+		r := &resources[i]
+		if r.Type != config.ResourceTypeGit {
+			continue
+		}
+
+		commit, err := m.resolveCommit(ctx, r, update)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock %s: %w", r.Name, err)
+		}
+		lock.Resources[r.Name] = LockedResource{
+			URL:    r.URL,
+			Branch: r.Branch,
+			Commit: commit,
+		}
+	}
+
+	return lock, nil
+}
+
+// resolveCommit returns the commit checked out for a git resource, cloning
+// it if needed and pulling first when update is set
+// Archive resources report the commit their archive was downloaded from
+func (m *Manager) resolveCommit(ctx context.Context, r *config.Resource, update bool) (string, error) {
+	path := m.ResourcePath(r.Name)
+
+	lock, err := m.acquire(ctx, "resource-"+r.Name)
+	if err != nil {
+		return "", err
+	}
+	defer lock.release()
+
+	if r.Fetch == config.ResourceFetchArchive {
+		if commit := m.archiveCommit(r); commit != "" && !update {
+			return commit, nil
+		}
+		return m.fetchArchive(ctx, r, "")
+	}
+
+	_, statErr := os.Stat(path)
+	switch {
+	case statErr != nil:
+		if err := m.cloneGit(ctx, path, r); err != nil {
+			return "", err
+		}
+	case update && detached(path):
+		// Copies on a previously locked commit are re-cloned to get the latest
+		if err := os.RemoveAll(path); err != nil {
+			return "", fmt.Errorf("failed to remove locked copy: %w", err)
+		}
+		if err := m.cloneGit(ctx, path, r); err != nil {
+			return "", err
+		}
+	case update:
+		if err := m.pullGit(ctx, path, r); err != nil {
+			return "", err
+		}
+	}
+
+	commit := Version(path)
+	if commit == "" {
+		return "", fmt.Errorf("no commit checked out")
+	}
+	return commit, nil
+}
+
+// checkoutGit checks out a locked commit. A shallow clone that lacks it
+// fetches just that commit, or falls back to a full clone when the server
+// doesn't allow fetching commits by SHA
+func (m *Manager) checkoutGit(ctx context.Context, path string, r *config.Resource, commit string) error {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	hash := plumbing.NewHash(commit)
+	if head, err := repo.Head(); err == nil && head.Hash() == hash {
+		return nil
+	}
+
+	if _, err := repo.CommitObject(hash); err != nil {
+		err = repo.FetchContext(ctx, &git.FetchOptions{
+			RemoteName: "origin",
+			RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(commit + ":" + lockedRef)},
+			Depth:      1,
+		})
+		if err != nil && err != git.NoErrAlreadyUpToDate {
+			if repo, err = m.cloneFull(ctx, path, r); err != nil {
+				return fmt.Errorf("failed to fetch locked commit %s: %w", shortHash(hash), err)
+			}
+		}
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: hash, Force: true}); err != nil {
+		return fmt.Errorf("failed to check out locked commit %s: %w", shortHash(hash), err)
+	}
+	return nil
+}
+
+// cloneFull replaces the cached copy with a clone of the full history
+func (m *Manager) cloneFull(ctx context.Context, path string, r *config.Resource) (*git.Repository, error) {
+	if err := os.RemoveAll(path); err != nil {
+		return nil, fmt.Errorf("failed to remove shallow copy: %w", err)
+	}
+
+	repo, err := git.PlainCloneContext(ctx, path, false, &git.CloneOptions{URL: cloneURL(r)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone repository: %w", err)
+	}
+	return repo, nil
+}
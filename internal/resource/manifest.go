@@ -0,0 +1,335 @@
+package resource
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileEntry is the recorded state of a single file in a manifest
+type FileEntry struct {
+	// Size is the file size in bytes
+	Size int64 `json:"size"`
+
+	// ModTime is the file modification time
+	ModTime time.Time `json:"modTime"`
+
+	// Hash is the hex-encoded SHA-256 of the file contents
+	Hash string `json:"hash"`
+}
+
+// Manifest records a content hash for every file in a resource
+type Manifest struct {
+	// Root is the directory the manifest was built from
+	Root string `json:"root"`
+
+	// Files maps slash-separated paths relative to Root to their entries
+	Files map[string]FileEntry `json:"files"`
+
+	// Digest is a hash over all file paths and hashes
+	Digest string `json:"digest"`
+
+	// Built is when the manifest was last refreshed
+	Built time.Time `json:"built"`
+}
+
+// Changes lists the files that differ between two manifests
+type Changes struct {
+	Added    []string
+	Modified []string
+	Removed  []string
+}
+
+// Empty reports whether there are no changes
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Modified) == 0 && len(c.Removed) == 0
+}
+
+// Hash returns the content hash of a file, or "" if it is not in the manifest
+func (m *Manifest) Hash(path string) string {
+	if m == nil {
+		return ""
+	}
+	return m.Files[filepath.ToSlash(path)].Hash
+}
+
+// Diff returns the changes from old to m
+// A nil old manifest reports every file as added
+func (m *Manifest) Diff(old *Manifest) Changes {
+	var changes Changes
+	for path, entry := range m.Files {
+		prev, ok := old.entry(path)
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, path)
+		case prev.Hash != entry.Hash:
+			changes.Modified = append(changes.Modified, path)
+		}
+	}
+	if old != nil {
+		for path := range old.Files {
+			if _, ok := m.Files[path]; !ok {
+				changes.Removed = append(changes.Removed, path)
+			}
+		}
+	}
+
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Removed)
+	return changes
+}
+
+// entry looks up a file entry, tolerating a nil manifest
+func (m *Manifest) entry(path string) (FileEntry, bool) {
+	if m == nil {
+		return FileEntry{}, false
+	}
+	e, ok := m.Files[path]
+	return e, ok
+}
+
+// BuildManifest hashes every file under root in parallel
+// Files whose size and modification time match prev reuse its hash, so
+// refreshing an unchanged resource only costs a directory walk
+func BuildManifest(ctx context.Context, root string, prev *Manifest) (*Manifest, error) {
+	if prev != nil && prev.Root != root {
+		prev = nil
+	}
+
+	type job struct {
+		path  string
+		entry FileEntry
+	}
+
+	var (
+		mu       sync.Mutex
+		files    = make(map[string]FileEntry)
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	jobs := make(chan job)
+
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				hash, err := hashFile(filepath.Join(root, filepath.FromSlash(j.path)))
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil && !os.IsNotExist(err) {
+						firstErr = err
+					}
+				} else {
+					j.entry.Hash = hash
+					files[j.path] = j.entry
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	walkErr := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		entry := FileEntry{Size: info.Size(), ModTime: info.ModTime()}
+		if old, ok := prev.entry(rel); ok && old.Size == entry.Size && old.ModTime.Equal(entry.ModTime) {
+			entry.Hash = old.Hash
+			mu.Lock()
+			files[rel] = entry
+			mu.Unlock()
+			return nil
+		}
+
+		jobs <- job{path: rel, entry: entry}
+		return nil
+	})
+
+	close(jobs)
+	wg.Wait()
+
+	if walkErr != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", root, walkErr)
+	}
+	if firstErr != nil {
+		return nil, fmt.Errorf("failed to hash files: %w", firstErr)
+	}
+
+	return &Manifest{
+		Root:   root,
+		Files:  files,
+		Digest: manifestDigest(files),
+		Built:  time.Now(),
+	}, nil
+}
+
+// hashFile returns the hex-encoded SHA-256 of a file
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// manifestDigest hashes the sorted file paths and content hashes
+func manifestDigest(files map[string]FileEntry) string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, path := range paths {
+		fmt.Fprintf(h, "%s\x00%s\n", path, files[path].Hash)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ManifestsDir returns the directory where resource manifests are stored
+func (m *Manager) ManifestsDir() string {
+	return filepath.Join(m.cacheDir, "manifests")
+}
+
+// Manifest refreshes and returns the manifest for a resource rooted at path
+// The previous manifest is loaded from disk so only changed files are hashed
+func (m *Manager) Manifest(ctx context.Context, name, path string) (*Manifest, error) {
+	return refreshManifest(ctx, m.ManifestsDir(), name, path)
+}
+
+// refreshManifest loads, rebuilds and saves a manifest in dir
+func refreshManifest(ctx context.Context, dir, name, path string) (*Manifest, error) {
+	file := filepath.Join(dir, name+".json")
+
+	prev, _ := loadManifest(file)
+
+	manifest, err := BuildManifest(ctx, path, prev)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveManifest(file, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// loadManifest reads a manifest file
+func loadManifest(file string) (*Manifest, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// saveManifest writes a manifest file atomically
+func saveManifest(file string, manifest *Manifest) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create manifests directory: %w", err)
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	// Write to a unique temp file so concurrent refreshes don't collide
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Manifests refreshes the manifest of every resource in the collection
+func (c *Collection) Manifests(ctx context.Context) (map[string]*Manifest, error) {
+	manifests := make(map[string]*Manifest, len(c.Resources))
+	for _, r := range c.Resources {
+		var (
+			manifest *Manifest
+			err      error
+		)
+		if c.manifestsDir != "" {
+			manifest, err = refreshManifest(ctx, c.manifestsDir, r.Name, r.Path)
+		} else {
+			manifest, err = BuildManifest(ctx, r.Path, nil)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to build manifest for %q: %w", r.Name, err)
+		}
+		manifests[r.Name] = manifest
+	}
+	return manifests, nil
+}
+
+// ManifestHasher returns a function mapping a collection-relative path
+// ("<resource>/<file>") to its current content hash, or "" if unknown
+func ManifestHasher(manifests map[string]*Manifest) func(path string) string {
+	return func(path string) string {
+		path = filepath.ToSlash(filepath.Clean(path))
+		for name, manifest := range manifests {
+			if rel, ok := strings.CutPrefix(path, name+"/"); ok {
+				return manifest.Hash(rel)
+			}
+		}
+		return ""
+	}
+}
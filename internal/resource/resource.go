@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/nickcecere/btcx/internal/config"
 )
@@ -12,6 +13,12 @@ import (
 // Manager handles resource operations
 type Manager struct {
 	cacheDir string
+
+	// lockFile pins git resources to commits (see WithLockFile)
+	lockFile string
+	lockOnce sync.Once
+	lock     *Lock
+	lockErr  error
 }
 
 // NewManager creates a new resource manager
@@ -37,11 +44,15 @@ func (m *Manager) ResourcePath(name string) string {
 }
 
 // Ensure ensures a resource is available locally
-// For git resources, it clones or pulls the repository
+// For git resources, it clones or pulls the repository, or downloads its
+// archive when the resource is fetched as one
 // For local resources, it validates the path exists
 func (m *Manager) Ensure(ctx context.Context, r *config.Resource) (string, error) {
 	switch r.Type {
 	case config.ResourceTypeGit:
+		if r.Fetch == config.ResourceFetchArchive {
+			return m.ensureArchive(ctx, r)
+		}
 		return m.ensureGit(ctx, r)
 	case config.ResourceTypeLocal:
 		return m.ensureLocal(r)
@@ -95,10 +106,25 @@ func (m *Manager) GetWorkingPath(r *config.Resource) (string, error) {
 
 // Clear removes a cached resource
 func (m *Manager) Clear(name string) error {
+	lock, err := m.acquire(context.Background(), "resource-"+name)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
 	path := m.ResourcePath(name)
 	if err := os.RemoveAll(path); err != nil {
 		return fmt.Errorf("failed to remove resource: %w", err)
 	}
+	if err := os.Remove(filepath.Join(m.ManifestsDir(), name+".json")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove manifest: %w", err)
+	}
+	if err := os.Remove(filepath.Join(m.SummariesDir(), name+".md")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove summary: %w", err)
+	}
+	if err := os.Remove(filepath.Join(m.ArchivesDir(), name+".json")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove archive info: %w", err)
+	}
 	return nil
 }
 
@@ -110,9 +136,26 @@ func (m *Manager) ClearAll() error {
 	if err := os.RemoveAll(m.CollectionsDir()); err != nil {
 		return fmt.Errorf("failed to remove collections directory: %w", err)
 	}
+	if err := os.RemoveAll(m.ManifestsDir()); err != nil {
+		return fmt.Errorf("failed to remove manifests directory: %w", err)
+	}
+	if err := os.RemoveAll(filepath.Join(m.cacheDir, "index")); err != nil {
+		return fmt.Errorf("failed to remove index directory: %w", err)
+	}
+	if err := os.RemoveAll(m.SummariesDir()); err != nil {
+		return fmt.Errorf("failed to remove summaries directory: %w", err)
+	}
+	if err := os.RemoveAll(m.ArchivesDir()); err != nil {
+		return fmt.Errorf("failed to remove archives directory: %w", err)
+	}
 	return nil
 }
 
+// SummariesDir returns the directory where generated resource overviews are stored
+func (m *Manager) SummariesDir() string {
+	return filepath.Join(m.cacheDir, "summaries")
+}
+
 // List returns the names of all cached resources
 func (m *Manager) List() ([]string, error) {
 	entries, err := os.ReadDir(m.ResourcesDir())
@@ -0,0 +1,253 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/nickcecere/btcx/internal/config"
+)
+
+// CheckStatus is the outcome of a single verification check
+type CheckStatus string
+
+const (
+	CheckOK   CheckStatus = "ok"
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+)
+
+// Check is the result of one verification check
+type Check struct {
+	Name   string      `json:"name"`
+	Status CheckStatus `json:"status"`
+	Detail string      `json:"detail"`
+}
+
+// Report collects the checks run against a resource
+type Report struct {
+	Resource string  `json:"resource"`
+	Checks   []Check `json:"checks"`
+}
+
+// Failed reports whether any check failed
+func (r *Report) Failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == CheckFail {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) add(name string, status CheckStatus, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+// Verify checks that a resource is usable without fetching it: the git
+// remote is reachable and has the branch, local paths and the searchPath
+// exist, and the cached copy is current. It also estimates the size
+func (m *Manager) Verify(ctx context.Context, r *config.Resource) *Report {
+	report := &Report{Resource: r.Name}
+
+	var root string
+	switch r.Type {
+	case config.ResourceTypeGit:
+		if r.Fetch == config.ResourceFetchArchive {
+			root = m.verifyArchive(ctx, r, report)
+			break
+		}
+		root = m.verifyGit(ctx, r, report)
+	case config.ResourceTypeLocal:
+		path, err := m.ensureLocal(r)
+		if err != nil {
+			report.add("path", CheckFail, "%v", err)
+			return report
+		}
+		report.add("path", CheckOK, "%s", path)
+		root = path
+	default:
+		report.add("type", CheckFail, "unknown resource type: %s", r.Type)
+		return report
+	}
+
+	if root == "" {
+		return report
+	}
+
+	searchRoot := root
+	if r.SearchPath != "" {
+		searchRoot = filepath.Join(root, r.SearchPath)
+		info, err := os.Stat(searchRoot)
+		switch {
+		case err != nil:
+			report.add("searchPath", CheckFail, "%s does not exist", r.SearchPath)
+			return report
+		case !info.IsDir():
+			report.add("searchPath", CheckFail, "%s is not a directory", r.SearchPath)
+			return report
+		default:
+			report.add("searchPath", CheckOK, "%s", r.SearchPath)
+		}
+	}
+
+	files, size, err := dirSize(ctx, searchRoot)
+	if err != nil {
+		report.add("size", CheckWarn, "failed to measure: %v", err)
+	} else {
+		report.add("size", CheckOK, "%s in %d files", formatBytes(size), files)
+	}
+
+	return report
+}
+
+// verifyGit checks the remote and cached copy of a git resource and returns
+// the cached path, or "" if it isn't cached
+func (m *Manager) verifyGit(ctx context.Context, r *config.Resource, report *Report) string {
+	remote := git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{
+		Name: "origin",
+		URLs: []string{cloneURL(r)},
+	})
+
+	var remoteHash plumbing.Hash
+	refs, err := remote.ListContext(ctx, &git.ListOptions{})
+	if err != nil {
+		report.add("url", CheckFail, "%s is not reachable: %v", r.URL, err)
+	} else {
+		report.add("url", CheckOK, "%s", r.URL)
+
+		want := plumbing.HEAD
+		if r.Branch != "" {
+			want = plumbing.NewBranchReferenceName(r.Branch)
+		}
+		remoteHash = findRef(refs, want)
+		switch {
+		case !remoteHash.IsZero():
+			report.add("branch", CheckOK, "%s at %s", branchLabel(r), shortHash(remoteHash))
+		case r.Branch != "":
+			report.add("branch", CheckFail, "branch %q not found on remote", r.Branch)
+		default:
+			report.add("branch", CheckWarn, "remote has no default branch")
+		}
+	}
+
+	locked, err := m.lockedCommit(r)
+	if err != nil {
+		report.add("lock", CheckFail, "%v", err)
+	} else if locked != "" {
+		report.add("lock", CheckOK, "pinned to %s", shortHash(plumbing.NewHash(locked)))
+	}
+
+	path := m.ResourcePath(r.Name)
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		report.add("cache", CheckWarn, "not cached yet (run btcx resources fetch %s)", r.Name)
+		return ""
+	}
+
+	head, err := repo.Head()
+	switch {
+	case err != nil:
+		report.add("cache", CheckWarn, "cached copy has no HEAD: %v", err)
+	case locked != "" && head.Hash().String() != locked:
+		report.add("cache", CheckWarn, "cached at %s, not the locked commit (run btcx resources fetch %s)",
+			shortHash(head.Hash()), r.Name)
+	case locked != "":
+		report.add("cache", CheckOK, "at locked commit %s", shortHash(head.Hash()))
+	case remoteHash.IsZero():
+		report.add("cache", CheckOK, "cached at %s", shortHash(head.Hash()))
+	case head.Hash() != remoteHash:
+		report.add("cache", CheckWarn, "stale: cached at %s, remote at %s (run btcx resources fetch %s)",
+			shortHash(head.Hash()), shortHash(remoteHash), r.Name)
+	default:
+		report.add("cache", CheckOK, "up to date at %s", shortHash(head.Hash()))
+	}
+
+	return path
+}
+
+// findRef returns the hash a reference resolves to in a remote listing,
+// following symbolic references such as HEAD
+func findRef(refs []*plumbing.Reference, name plumbing.ReferenceName) plumbing.Hash {
+	for i := 0; i < 5; i++ {
+		var found *plumbing.Reference
+		for _, ref := range refs {
+			if ref.Name() == name {
+				found = ref
+				break
+			}
+		}
+		if found == nil {
+			return plumbing.ZeroHash
+		}
+		if found.Type() == plumbing.HashReference {
+			return found.Hash()
+		}
+		name = found.Target()
+	}
+	return plumbing.ZeroHash
+}
+
+// branchLabel names the branch a resource tracks
+func branchLabel(r *config.Resource) string {
+	if r.Branch == "" {
+		return "default branch"
+	}
+	return r.Branch
+}
+
+// shortHash abbreviates a commit hash
+func shortHash(h plumbing.Hash) string {
+	return h.String()[:7]
+}
+
+// dirSize counts the files and bytes under root, skipping .git
+func dirSize(ctx context.Context, root string) (int, int64, error) {
+	var files int
+	var size int64
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files++
+		size += info.Size()
+		return nil
+	})
+	return files, size, err
+}
+
+// formatBytes formats a byte count for display
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
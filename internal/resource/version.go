@@ -0,0 +1,73 @@
+package resource
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// Version returns the git commit checked out at path (or any parent
+// directory), or "" if the path is not inside a git repository
+func Version(path string) string {
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return ""
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return ""
+	}
+
+	return head.Hash().String()
+}
+
+// Versions returns the version of each resource in the collection
+// Archive resources have the commit they were extracted from
+// Resources outside git fall back to their manifest digest, if available,
+// and otherwise map to ""
+func (c *Collection) Versions(manifests map[string]*Manifest) map[string]string {
+	versions := make(map[string]string, len(c.Resources))
+	for _, r := range c.Resources {
+		version := r.Commit
+		if version == "" {
+			version = Version(r.Path)
+		}
+		if version == "" && manifests[r.Name] != nil {
+			version = "sha256:" + manifests[r.Name].Digest
+		}
+		versions[r.Name] = version
+	}
+	return versions
+}
+
+// LastModified returns the latest modification time of the files under
+// root, skipping .git (zero if there are none)
+func LastModified(ctx context.Context, root string) (time.Time, error) {
+	var latest time.Time
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return latest, err
+}
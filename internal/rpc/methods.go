@@ -0,0 +1,300 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/agent"
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/provider"
+	"github.com/nickcecere/btcx/internal/storage"
+)
+
+// AskParams are the params of ask
+type AskParams struct {
+	// Resources are the resource names to search
+	Resources []string `json:"resources"`
+
+	// Question is the question to ask
+	Question string `json:"question"`
+
+	// Model is the model name to use (optional, defaults to config default)
+	Model string `json:"model,omitempty"`
+
+	// ThreadID continues an existing thread (optional)
+	ThreadID string `json:"thread_id,omitempty"`
+
+	// NoAnswerCache bypasses the answer cache (optional)
+	NoAnswerCache bool `json:"no_answer_cache,omitempty"`
+}
+
+// AskResult is the result of ask
+type AskResult struct {
+	Answer    string     `json:"answer"`
+	ThreadID  string     `json:"thread_id"`
+	Citations []Citation `json:"citations"`
+	Usage     Usage      `json:"usage"`
+	Model     string     `json:"model"`
+	Resources []string   `json:"resources"`
+	Cached    bool       `json:"cached,omitempty"`
+}
+
+// Citation is a cited file, with its absolute path for editors to open
+type Citation struct {
+	agent.Citation
+	File string `json:"file,omitempty"`
+}
+
+// Usage is the tokens an answer took
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+
+	// CacheReadTokens and CacheWriteTokens are the input tokens read from and
+	// written to the provider's prompt cache
+	CacheReadTokens  int `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
+}
+
+// StreamEvent is the params of a stream.event notification, sent while an
+// ask runs
+//
+// Types:
+//
+//	text        delta is answer text as it is generated
+//	tool_start  tool is a tool call the agent started
+//	tool_finish tool is a tool call that completed
+//	usage       usage is the tokens of one model call
+type StreamEvent struct {
+	// ID is the ID of the ask request
+	ID    json.RawMessage `json:"id"`
+	Type  string          `json:"type"`
+	Delta string          `json:"delta,omitempty"`
+	Tool  *ToolEvent      `json:"tool,omitempty"`
+	Usage *Usage          `json:"usage,omitempty"`
+}
+
+// ToolEvent is a tool call in stream events
+type ToolEvent struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// ask answers a question, streaming stream.event notifications for the
+// request (none for ask notifications without an ID)
+func (s *Server) ask(ctx context.Context, id, params json.RawMessage) (*AskResult, error) {
+	var p AskParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, invalidParams("invalid ask params: %v", err)
+	}
+	if p.Question == "" {
+		return nil, invalidParams("question is required")
+	}
+	if len(p.Resources) == 0 {
+		return nil, invalidParams("at least one resource is required")
+	}
+
+	modelCfg, err := s.cfg.GetModelConfig(p.Model)
+	if err != nil {
+		return nil, invalidParams("failed to get model: %v", err)
+	}
+	var configResources []*config.Resource
+	for _, name := range p.Resources {
+		r, ok := s.cfg.GetResource(name)
+		if !ok {
+			return nil, invalidParams("resource %q not found in config", name)
+		}
+		configResources = append(configResources, r)
+	}
+
+	s.prepareMu.Lock()
+	collection, err := s.mgr.EnsureCollection(ctx, configResources)
+	s.prepareMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare resources: %w", err)
+	}
+
+	a, err := agent.New(agent.Options{
+		Config:        s.cfg,
+		ModelConfig:   modelCfg,
+		Collection:    collection,
+		DataDir:       s.paths.DataDir,
+		NoAnswerCache: p.NoAnswerCache,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create agent: %w", err)
+	}
+	if p.ThreadID != "" {
+		thread, err := a.Storage.LoadThread(p.ThreadID)
+		if err != nil {
+			return nil, invalidParams("%v", err)
+		}
+		a.ContinueThread(thread)
+	}
+
+	var callback agent.StreamCallback
+	if id != nil {
+		callback = s.streamCallback(id)
+	}
+	resp, err := a.AskWithCallback(ctx, p.Question, callback)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get response: %w", err)
+	}
+
+	result := &AskResult{
+		Answer:    resp.Content,
+		ThreadID:  a.Thread.ID,
+		Citations: []Citation{},
+		Usage: Usage{
+			InputTokens:      resp.Usage.InputTokens,
+			OutputTokens:     resp.Usage.OutputTokens,
+			CacheReadTokens:  resp.Usage.CacheReadTokens,
+			CacheWriteTokens: resp.Usage.CacheWriteTokens,
+		},
+		Model:     modelCfg.Name,
+		Resources: a.Thread.Resources,
+		Cached:    resp.Cached != nil,
+	}
+	for _, c := range a.AttributedCitations(resp.ToolCalls) {
+		citation := Citation{Citation: c, File: c.LocalPath}
+		if citation.File == "" && !filepath.IsAbs(c.Path) {
+			citation.File = filepath.Join(collection.Path, filepath.FromSlash(c.Path))
+		}
+		result.Citations = append(result.Citations, citation)
+	}
+	return result, nil
+}
+
+// streamCallback sends an ask's progress as stream.event notifications
+func (s *Server) streamCallback(id json.RawMessage) agent.StreamCallback {
+	// Tool calls are reported by the model stream and again when they run
+	started := make(map[string]bool)
+	return func(event provider.StreamEvent) {
+		switch event.Type {
+		case provider.StreamEventText:
+			s.notify("stream.event", StreamEvent{ID: id, Type: "text", Delta: event.Delta})
+		case provider.StreamEventToolCall:
+			if tc := event.ToolCall; tc != nil && !started[tc.ID+tc.Name+string(tc.Arguments)] {
+				started[tc.ID+tc.Name+string(tc.Arguments)] = true
+				s.notify("stream.event", StreamEvent{ID: id, Type: "tool_start", Tool: newToolEvent(tc)})
+			}
+		case provider.StreamEventToolResult:
+			if event.ToolCall != nil {
+				s.notify("stream.event", StreamEvent{ID: id, Type: "tool_finish", Tool: newToolEvent(event.ToolCall)})
+			}
+		case provider.StreamEventDone:
+			if event.Usage != nil {
+				s.notify("stream.event", StreamEvent{ID: id, Type: "usage", Usage: &Usage{
+					InputTokens:      event.Usage.InputTokens,
+					OutputTokens:     event.Usage.OutputTokens,
+					CacheReadTokens:  event.Usage.CacheReadTokens,
+					CacheWriteTokens: event.Usage.CacheWriteTokens,
+				}})
+			}
+		}
+	}
+}
+
+// newToolEvent builds a tool event payload
+func newToolEvent(tc *provider.ToolCall) *ToolEvent {
+	return &ToolEvent{ID: tc.ID, Name: tc.Name, Arguments: tc.Arguments}
+}
+
+// ResourceInfo describes a configured resource
+type ResourceInfo struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Notes string `json:"notes,omitempty"`
+}
+
+// listResources lists configured resources
+func (s *Server) listResources() []ResourceInfo {
+	result := make([]ResourceInfo, 0, len(s.cfg.Resources))
+	for _, res := range s.cfg.Resources {
+		result = append(result, ResourceInfo{Name: res.Name, Type: string(res.Type), Notes: res.Notes})
+	}
+	return result
+}
+
+// ModelInfo describes a configured model
+type ModelInfo struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Default  bool   `json:"default"`
+}
+
+// listModels lists configured models
+func (s *Server) listModels() []ModelInfo {
+	defaultModel, _ := s.cfg.GetModelConfig("")
+	result := make([]ModelInfo, 0, len(s.cfg.Models))
+	for _, m := range s.cfg.Models {
+		result = append(result, ModelInfo{
+			Name:     m.Name,
+			Provider: string(m.Provider),
+			Model:    m.Model,
+			Default:  defaultModel != nil && defaultModel.Name == m.Name,
+		})
+	}
+	return result
+}
+
+// ListThreadsParams are the optional params of listThreads
+type ListThreadsParams struct {
+	// Limit is the most threads to return, newest first (0 for all)
+	Limit int `json:"limit,omitempty"`
+
+	// Resource only lists threads that searched this resource
+	Resource string `json:"resource,omitempty"`
+}
+
+// ThreadSummary describes a thread in listThreads results
+type ThreadSummary struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Updated   time.Time `json:"updated"`
+	Resources []string  `json:"resources"`
+	Model     string    `json:"model"`
+	Summary   string    `json:"summary,omitempty"`
+}
+
+// listThreads lists saved threads, newest first
+func (s *Server) listThreads(params json.RawMessage) ([]ThreadSummary, error) {
+	var p ListThreadsParams
+	if len(params) > 0 && string(params) != "null" {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams("invalid listThreads params: %v", err)
+		}
+	}
+
+	threads, err := storage.NewStorage(s.paths.DataDir).ListThreads()
+	if err != nil {
+		return nil, err
+	}
+	result := []ThreadSummary{}
+	for _, t := range threads {
+		if p.Resource != "" && !slices.Contains(t.Resources, p.Resource) {
+			continue
+		}
+		summary := ThreadSummary{
+			ID:        t.ID,
+			Title:     t.Title,
+			Updated:   t.Updated,
+			Resources: t.Resources,
+			Model:     t.Model,
+		}
+		if t.Digest != nil {
+			summary.Summary = t.Digest.Summary
+		}
+		result = append(result, summary)
+		if p.Limit > 0 && len(result) == p.Limit {
+			break
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,294 @@
+// Package rpc serves btcx over stdin/stdout with JSON-RPC 2.0, so editor
+// extensions can keep one btcx process running and ask it many questions
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/resource"
+)
+
+// ProtocolVersion is the version of the editor protocol
+const ProtocolVersion = 1
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+	// codeRequestCancelled is LSP's code for requests ended by a cancel
+	codeRequestCancelled = -32800
+)
+
+// Server answers JSON-RPC requests read from one stream on another
+type Server struct {
+	cfg   *config.Config
+	paths *config.Paths
+	mgr   *resource.Manager
+
+	// prepareMu serializes collection preparation
+	prepareMu sync.Mutex
+
+	// conn writes messages in the framing the client uses
+	conn *conn
+
+	mu sync.Mutex
+	// running are the cancel functions of in-flight asks by request ID
+	running map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// request is a JSON-RPC request or notification from the client
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// response is a JSON-RPC response sent to the client
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// notification is a JSON-RPC notification sent to the client
+type notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// rpcError is a JSON-RPC error object
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// invalidParams returns an invalid params error
+func invalidParams(format string, args ...any) error {
+	return &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// New creates a server for the config
+func New(cfg *config.Config, paths *config.Paths) *Server {
+	return &Server{
+		cfg:     cfg,
+		paths:   paths,
+		mgr:     resource.NewManager(cfg.Cache.ResolvedPath).WithLockFile(cfg.ResolvedLockFile),
+		running: make(map[string]context.CancelFunc),
+	}
+}
+
+// Serve reads requests from in until it is closed or ctx is done, and
+// writes responses and notifications to out
+// Messages are framed with Content-Length headers as in LSP, or one per
+// line; replies use the framing of the first message
+func (s *Server) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r := bufio.NewReader(in)
+	s.conn = &conn{w: out}
+	if first, err := r.Peek(1); err == nil && (first[0] == 'C' || first[0] == 'c') {
+		s.conn.headers = true
+	}
+
+	var err error
+	for {
+		var data []byte
+		data, err = s.conn.read(r)
+		if len(strings.TrimSpace(string(data))) > 0 {
+			s.handle(ctx, data)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// Closed input ends the session and cancels running asks
+	cancel()
+	s.wg.Wait()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// handle dispatches one message
+// Asks run in the background, so cancel requests are read while they run
+func (s *Server) handle(ctx context.Context, data []byte) {
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		s.reply(json.RawMessage("null"), nil, &rpcError{Code: codeParseError, Message: err.Error()})
+		return
+	}
+	if req.Method == "" {
+		s.reply(req.ID, nil, &rpcError{Code: codeInvalidRequest, Message: "method is required"})
+		return
+	}
+
+	switch req.Method {
+	case "ask":
+		askCtx, cancel := context.WithCancel(ctx)
+		key := string(req.ID)
+		if req.ID != nil {
+			s.mu.Lock()
+			s.running[key] = cancel
+			s.mu.Unlock()
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer cancel()
+			result, err := s.ask(askCtx, req.ID, req.Params)
+			if req.ID != nil {
+				s.mu.Lock()
+				delete(s.running, key)
+				s.mu.Unlock()
+			}
+			if err != nil && askCtx.Err() != nil && ctx.Err() == nil {
+				err = &rpcError{Code: codeRequestCancelled, Message: "request cancelled"}
+			}
+			s.reply(req.ID, result, err)
+		}()
+	case "cancel", "$/cancelRequest":
+		result, err := s.cancel(req.Params)
+		s.reply(req.ID, result, err)
+	case "initialize":
+		s.reply(req.ID, map[string]any{
+			"name":             "btcx",
+			"protocol_version": ProtocolVersion,
+			"methods":          []string{"ask", "cancel", "listResources", "listModels", "listThreads"},
+		}, nil)
+	case "listResources":
+		s.reply(req.ID, s.listResources(), nil)
+	case "listModels":
+		s.reply(req.ID, s.listModels(), nil)
+	case "listThreads":
+		result, err := s.listThreads(req.Params)
+		s.reply(req.ID, result, err)
+	default:
+		// Other LSP notifications ($/...) don't need a reply
+		if !strings.HasPrefix(req.Method, "$/") {
+			s.reply(req.ID, nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("unknown method %q", req.Method)})
+		}
+	}
+}
+
+// cancelParams are the params of cancel
+type cancelParams struct {
+	// ID is the ID of the ask request to cancel
+	ID json.RawMessage `json:"id"`
+}
+
+// cancel stops an in-flight ask; its request then fails with
+// codeRequestCancelled
+func (s *Server) cancel(params json.RawMessage) (any, error) {
+	var p cancelParams
+	if err := json.Unmarshal(params, &p); err != nil || p.ID == nil {
+		return nil, invalidParams("cancel needs the id of the request to cancel")
+	}
+	s.mu.Lock()
+	cancel, ok := s.running[string(p.ID)]
+	s.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return map[string]bool{"cancelled": ok}, nil
+}
+
+// reply sends the response to a request; notifications (no ID) get none
+func (s *Server) reply(id json.RawMessage, result any, err error) {
+	if id == nil {
+		return
+	}
+	resp := response{JSONRPC: "2.0", ID: id, Result: result}
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		resp.Result, resp.Error = nil, rpcErr
+	} else if result == nil {
+		resp.Result = struct{}{}
+	}
+	s.conn.write(resp)
+}
+
+// notify sends a notification
+func (s *Server) notify(method string, params any) {
+	s.conn.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// conn reads and writes framed messages
+type conn struct {
+	// headers frames messages with Content-Length headers instead of lines
+	headers bool
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// read reads the next message
+func (c *conn) read(r *bufio.Reader) ([]byte, error) {
+	if !c.headers {
+		return r.ReadBytes('\n')
+	}
+
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("message without Content-Length header")
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// write sends a message
+func (c *conn) write(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.headers {
+		fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+		return
+	}
+	c.w.Write(append(data, '\n'))
+}
@@ -0,0 +1,79 @@
+package search
+
+// GrepBoosted runs Grep with the matches in files of the boost scope listed
+// first. The boosted files are searched on their own, so they aren't lost
+// to the match limit when the rest of the tree has many matches. Searches
+// already restricted to a scope, or without a boost, run as is.
+func GrepBoosted(root, pattern string, opts GrepOptions, boost Scope) ([]Match, error) {
+	if boost == ScopeAll || opts.Scope != ScopeAll {
+		return Grep(root, pattern, opts)
+	}
+	if opts.MaxMatches == 0 {
+		opts.MaxMatches = DefaultGrepOptions().MaxMatches
+	}
+
+	boosted := opts
+	boosted.Scope = boost
+	matches, err := Grep(root, pattern, boosted)
+	if err != nil || len(matches) >= opts.MaxMatches {
+		return matches, err
+	}
+
+	rest, err := Grep(root, pattern, opts)
+	if err != nil {
+		return nil, err
+	}
+	type line struct {
+		path string
+		num  int
+	}
+	seen := make(map[line]bool, len(matches))
+	for _, m := range matches {
+		seen[line{m.Path, m.LineNum}] = true
+	}
+	for _, m := range rest {
+		if len(matches) >= opts.MaxMatches {
+			break
+		}
+		if !seen[line{m.Path, m.LineNum}] {
+			matches = append(matches, m)
+		}
+	}
+	return matches, nil
+}
+
+// GlobBoosted runs Glob with the files of the boost scope listed first,
+// like GrepBoosted
+func GlobBoosted(root, pattern string, opts GlobOptions, boost Scope) ([]FileInfo, error) {
+	if boost == ScopeAll || opts.Scope != ScopeAll {
+		return Glob(root, pattern, opts)
+	}
+	if opts.MaxFiles == 0 {
+		opts.MaxFiles = DefaultGlobOptions().MaxFiles
+	}
+
+	boosted := opts
+	boosted.Scope = boost
+	files, err := Glob(root, pattern, boosted)
+	if err != nil || len(files) >= opts.MaxFiles {
+		return files, err
+	}
+
+	rest, err := Glob(root, pattern, opts)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		seen[f.Path] = true
+	}
+	for _, f := range rest {
+		if len(files) >= opts.MaxFiles {
+			break
+		}
+		if !seen[f.Path] {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
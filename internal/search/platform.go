@@ -0,0 +1,245 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RipgrepOff is the ripgrep setting that always uses the built-in search
+const RipgrepOff = "off"
+
+// ripgrep is the executable grep and glob shell out to
+var ripgrep struct {
+	sync.Mutex
+
+	// setting is the configured executable ("" looks up rg in PATH)
+	setting string
+
+	// info describes the executable found for setting, nil until it has
+	// been detected
+	info *RipgrepInfo
+}
+
+// Searches that can run on ripgrep
+const (
+	ripgrepGrep = "grep"
+	ripgrepGlob = "glob"
+)
+
+// ripgrepFlags are the flags each search passes to ripgrep
+// --field-match-separator needs ripgrep 13; older versions reject it and
+// print nothing, so grep falls back to the built-in search on them
+var ripgrepFlags = map[string][]string{
+	ripgrepGrep: {"--hidden", "--follow", "--null", "--field-match-separator", "--no-heading", "--color", "--regexp", "--glob"},
+	ripgrepGlob: {"--files", "--hidden", "--follow", "--glob"},
+}
+
+// ripgrepDetectTimeout bounds rg --version and rg --help
+const ripgrepDetectTimeout = 5 * time.Second
+
+// RipgrepInfo describes the ripgrep executable searches run
+type RipgrepInfo struct {
+	// Setting is the configured executable ("" for rg from PATH)
+	Setting string
+
+	// Path is the executable found ("" if there is none)
+	Path string
+
+	// Version is the version it reports, e.g. "14.1.1"
+	Version string
+
+	// Missing lists the flags it doesn't support, by search
+	// Searches with missing flags use the built-in search
+	Missing map[string][]string
+
+	// Err is why ripgrep can't be used at all (nil if it can, or if it's
+	// off or not installed)
+	Err error
+}
+
+// Supports reports whether a search ("grep" or "glob") runs on ripgrep
+func (i *RipgrepInfo) Supports(search string) bool {
+	return i.Path != "" && i.Err == nil && len(i.Missing[search]) == 0
+}
+
+// SetRipgrep sets the ripgrep executable, e.g. rg.exe or a full path
+// "" looks up rg in PATH (rg.exe on Windows); RipgrepOff uses the built-in
+// search
+func SetRipgrep(setting string) {
+	ripgrep.Lock()
+	defer ripgrep.Unlock()
+	if setting != ripgrep.setting {
+		ripgrep.setting = setting
+		ripgrep.info = nil
+	}
+}
+
+// Ripgrep returns the ripgrep executable searches run and the features it
+// supports, detecting them on first use
+// Problems with a configured executable and missing features are reported
+// once
+func Ripgrep() *RipgrepInfo {
+	ripgrep.Lock()
+	defer ripgrep.Unlock()
+	if ripgrep.info != nil {
+		return ripgrep.info
+	}
+
+	info := &RipgrepInfo{Setting: ripgrep.setting}
+	ripgrep.info = info
+	switch ripgrep.setting {
+	case RipgrepOff:
+		return info
+	case "":
+		// LookPath tries PATHEXT extensions on Windows, so rg finds rg.exe
+		info.Path, _ = exec.LookPath("rg")
+	default:
+		var err error
+		if info.Path, err = exec.LookPath(ripgrep.setting); err != nil {
+			info.Err = err
+			fmt.Fprintf(os.Stderr, "Warning: ripgrep %q not found, using the built-in search: %v\n", ripgrep.setting, err)
+			return info
+		}
+	}
+	if info.Path == "" {
+		return info
+	}
+
+	detectRipgrep(info)
+	if info.Err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: can't run ripgrep %s, using the built-in search: %v\n", info.Path, info.Err)
+	}
+	for _, search := range []string{ripgrepGrep, ripgrepGlob} {
+		if missing := info.Missing[search]; len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: ripgrep %s lacks %s, %s uses the built-in search (upgrade to ripgrep 13 or later)\n",
+				info.Version, strings.Join(missing, ", "), search)
+		}
+	}
+	return info
+}
+
+// detectRipgrep fills in the version of info.Path and the flags it lacks
+func detectRipgrep(info *RipgrepInfo) {
+	ctx, cancel := context.WithTimeout(context.Background(), ripgrepDetectTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, info.Path, "--version").Output()
+	if err != nil {
+		info.Err = fmt.Errorf("rg --version failed: %w", err)
+		return
+	}
+	// "ripgrep 14.1.1 (rev f08e57bec0)"
+	if fields := strings.Fields(string(out)); len(fields) >= 2 && fields[0] == "ripgrep" {
+		info.Version = fields[1]
+	}
+
+	help, err := exec.CommandContext(ctx, info.Path, "--help").Output()
+	if err != nil {
+		info.Err = fmt.Errorf("rg --help failed: %w", err)
+		return
+	}
+	for search, flags := range ripgrepFlags {
+		for _, flag := range flags {
+			if !strings.Contains(string(help), flag) {
+				if info.Missing == nil {
+					info.Missing = make(map[string][]string)
+				}
+				info.Missing[search] = append(info.Missing[search], flag)
+			}
+		}
+	}
+}
+
+// ripgrepFor returns the ripgrep executable to run for a search ("" to use
+// the built-in search)
+func ripgrepFor(search string) string {
+	if info := Ripgrep(); info.Supports(search) {
+		return info.Path
+	}
+	return ""
+}
+
+// walk walks the tree under root like filepath.WalkDir, but follows
+// symlinks to directories as ripgrep --follow does, since collections link
+// to their resources
+// Paths are reported through the links, and fn sees a linked directory as a
+// directory named like the link, so it can skip hidden or ignored ones as
+// it does plain directories; each linked directory is walked once, so link
+// cycles end
+func walk(root string, fn fs.WalkDirFunc) error {
+	visited := make(map[string]bool)
+	stopped := false
+
+	var walkLinked func(shown string, linked bool) error
+	walkLinked = func(shown string, linked bool) error {
+		real, err := filepath.EvalSymlinks(shown)
+		if err != nil {
+			return fn(shown, nil, err)
+		}
+		if visited[real] {
+			return nil
+		}
+		visited[real] = true
+
+		return filepath.WalkDir(real, func(path string, d fs.DirEntry, err error) error {
+			if rel, relErr := filepath.Rel(real, path); relErr == nil {
+				path = filepath.Join(shown, rel)
+			}
+			// fn has seen a linked directory as the link already
+			if linked && path == shown && err == nil {
+				return nil
+			}
+			if err == nil && d.Type()&fs.ModeSymlink != 0 {
+				if info, statErr := os.Stat(path); statErr == nil && info.IsDir() {
+					err := fn(path, fs.FileInfoToDirEntry(info), nil)
+					if err == nil {
+						err = walkLinked(path, true)
+					}
+					switch {
+					case err == filepath.SkipDir:
+						return nil
+					case err == filepath.SkipAll:
+						stopped = true
+					}
+					if stopped {
+						return filepath.SkipAll
+					}
+					return err
+				}
+			}
+			err = fn(path, d, err)
+			if err == filepath.SkipAll {
+				stopped = true
+			}
+			return err
+		})
+	}
+	return walkLinked(root, false)
+}
+
+// slashRel returns path relative to root with forward slashes, the form
+// gitignore rules, include globs and scopes are matched against on every
+// platform
+func slashRel(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// absRoot returns root as an absolute, cleaned path, so paths reported by
+// ripgrep don't depend on the working directory
+func absRoot(root string) string {
+	if abs, err := filepath.Abs(root); err == nil {
+		return abs
+	}
+	return filepath.Clean(root)
+}
@@ -2,6 +2,9 @@ package search
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -10,19 +13,10 @@ import (
 	"time"
 )
 
-// ripgrepAvailable caches whether ripgrep is available
-var ripgrepAvailable *bool
-
-// RipgrepAvailable checks if ripgrep (rg) is available in PATH
+// RipgrepAvailable checks if ripgrep is available (see SetRipgrep)
 func RipgrepAvailable() bool {
-	if ripgrepAvailable != nil {
-		return *ripgrepAvailable
-	}
-
-	_, err := exec.LookPath("rg")
-	available := err == nil
-	ripgrepAvailable = &available
-	return available
+	info := Ripgrep()
+	return info.Path != "" && info.Err == nil
 }
 
 // RipgrepGrep searches for a pattern using ripgrep
@@ -33,6 +27,10 @@ func RipgrepGrep(root, pattern string, opts GrepOptions) ([]Match, error) {
 	if opts.MaxLineLength == 0 {
 		opts.MaxLineLength = DefaultGrepOptions().MaxLineLength
 	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultGrepOptions().Timeout
+	}
+	root = absRoot(root)
 
 	// Build ripgrep command
 	args := []string{
@@ -40,7 +38,8 @@ func RipgrepGrep(root, pattern string, opts GrepOptions) ([]Match, error) {
 		"-H",                        // Include filename
 		"--hidden",                  // Search hidden files
 		"--follow",                  // Follow symlinks
-		"--field-match-separator=|", // Use | as separator for easy parsing
+		"--null",                    // End paths with NUL, since they can contain | or :
+		"--field-match-separator=|", // Use | between line number and text
 		"--no-heading",              // Don't group by file
 		"--color=never",             // No color codes
 		"--regexp", pattern,
@@ -51,11 +50,13 @@ func RipgrepGrep(root, pattern string, opts GrepOptions) ([]Match, error) {
 		args = append(args, "--glob", opts.Include)
 	}
 
-	// Add root path
+	// Add root path; output paths are then absolute
 	args = append(args, root)
 
-	cmd := exec.Command("rg", args...)
-	cmd.Dir = root
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ripgrepFor(ripgrepGrep), args...)
 
 	// Get stdout pipe
 	stdout, err := cmd.StdoutPipe()
@@ -70,24 +71,30 @@ func RipgrepGrep(root, pattern string, opts GrepOptions) ([]Match, error) {
 	var matches []Match
 	scanner := bufio.NewScanner(stdout)
 
-	// Parse ripgrep output: filepath|linenum|content
+	// Parse ripgrep output: filepath NUL linenum|content
 	for scanner.Scan() {
 		line := scanner.Text()
 		if line == "" {
 			continue
 		}
 
-		parts := strings.SplitN(line, "|", 3)
-		if len(parts) < 3 {
+		filePath, rest, ok := strings.Cut(line, "\x00")
+		if !ok {
 			continue
 		}
-
-		filePath := parts[0]
-		lineNum, err := strconv.Atoi(parts[1])
+		num, lineText, ok := strings.Cut(rest, "|")
+		if !ok {
+			continue
+		}
+		lineNum, err := strconv.Atoi(num)
 		if err != nil {
 			continue
 		}
-		lineText := parts[2]
+
+		// Apply scope filter
+		if !opts.Scope.matchAbs(root, filePath) {
+			continue
+		}
 
 		// Truncate long lines
 		if len(lineText) > opts.MaxLineLength {
@@ -113,9 +120,18 @@ func RipgrepGrep(root, pattern string, opts GrepOptions) ([]Match, error) {
 		}
 	}
 
+	// Stop ripgrep if it still has output we won't read
+	if len(matches) >= opts.MaxMatches {
+		cancel()
+	}
+
 	// Wait for command to finish (ignore exit code - rg returns 1 for no matches)
 	cmd.Wait()
 
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w after %s", ErrGrepTimeout, opts.Timeout)
+	}
+
 	// Sort by modification time (newest first)
 	sortMatchesByTime(matches)
 
@@ -127,6 +143,7 @@ func RipgrepGlob(root, pattern string, opts GlobOptions) ([]FileInfo, error) {
 	if opts.MaxFiles == 0 {
 		opts.MaxFiles = DefaultGlobOptions().MaxFiles
 	}
+	root = absRoot(root)
 
 	// Build ripgrep command for listing files
 	args := []string{
@@ -136,11 +153,10 @@ func RipgrepGlob(root, pattern string, opts GlobOptions) ([]FileInfo, error) {
 		"--glob", pattern,
 	}
 
-	// Add root path
+	// Add root path; output paths are then absolute
 	args = append(args, root)
 
-	cmd := exec.Command("rg", args...)
-	cmd.Dir = root
+	cmd := exec.Command(ripgrepFor(ripgrepGlob), args...)
 
 	// Get stdout pipe
 	stdout, err := cmd.StdoutPipe()
@@ -166,6 +182,11 @@ func RipgrepGlob(root, pattern string, opts GlobOptions) ([]FileInfo, error) {
 			filePath = filepath.Join(root, filePath)
 		}
 
+		// Apply scope filter
+		if !opts.Scope.matchAbs(root, filePath) {
+			continue
+		}
+
 		// Get file modification time
 		var modTime time.Time
 		if info, err := os.Stat(filePath); err == nil {
@@ -0,0 +1,131 @@
+package search
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Scope restricts a search to one kind of file
+type Scope string
+
+const (
+	// ScopeAll searches every file
+	ScopeAll Scope = ""
+	// ScopeDocs searches documentation (md, mdx, rst, ...)
+	ScopeDocs Scope = "docs"
+	// ScopeCode searches source files that aren't docs or tests
+	ScopeCode Scope = "code"
+	// ScopeTests searches test files and test directories
+	ScopeTests Scope = "tests"
+	// ScopeExamples searches example code and tests: the files that show
+	// how code is used
+	ScopeExamples Scope = "examples"
+)
+
+// Scopes lists the scopes that can be selected
+var Scopes = []Scope{ScopeDocs, ScopeCode, ScopeTests, ScopeExamples}
+
+// docExtensions are the extensions of documentation files
+var docExtensions = map[string]bool{
+	".md": true, ".mdx": true, ".markdown": true, ".rst": true,
+	".adoc": true, ".asciidoc": true, ".org": true, ".rdoc": true,
+}
+
+// testDirs are directory names that only hold tests
+var testDirs = map[string]bool{
+	"test": true, "tests": true, "__tests__": true, "spec": true, "specs": true,
+	"testdata": true, "e2e": true, "__mocks__": true,
+}
+
+// exampleDirs are directory names that hold examples
+var exampleDirs = map[string]bool{
+	"example": true, "examples": true, "_examples": true, "sample": true, "samples": true,
+	"demo": true, "demos": true, "cookbook": true, "recipes": true, "playground": true,
+}
+
+// testSuffixes mark test files by the end of their name (without extension)
+var testSuffixes = []string{"_test", "_spec", ".test", ".spec", "Test", "Tests", "Spec"}
+
+// ParseScope parses a scope name ("" and "all" mean no restriction)
+func ParseScope(s string) (Scope, error) {
+	switch Scope(strings.ToLower(strings.TrimSpace(s))) {
+	case "", "all":
+		return ScopeAll, nil
+	case ScopeDocs, "doc":
+		return ScopeDocs, nil
+	case ScopeCode, "src", "source":
+		return ScopeCode, nil
+	case ScopeTests, "test":
+		return ScopeTests, nil
+	case ScopeExamples, "example", "usage":
+		return ScopeExamples, nil
+	}
+	return ScopeAll, fmt.Errorf("unknown scope %q (expected docs, code, tests or examples)", s)
+}
+
+// Classify returns the scope a file belongs to
+// rel is the path relative to the search root, so directories above the
+// root (like a cache directory named "test") don't count
+func Classify(rel string) Scope {
+	rel = filepath.ToSlash(rel)
+	base := path.Base(rel)
+	ext := strings.ToLower(path.Ext(base))
+	if docExtensions[ext] {
+		return ScopeDocs
+	}
+
+	name := strings.TrimSuffix(base, path.Ext(base))
+	if strings.HasPrefix(name, "test_") {
+		return ScopeTests
+	}
+	for _, suffix := range testSuffixes {
+		if strings.HasSuffix(name, suffix) && name != suffix {
+			return ScopeTests
+		}
+	}
+	for _, dir := range strings.Split(path.Dir(rel), "/") {
+		if testDirs[dir] {
+			return ScopeTests
+		}
+	}
+	return ScopeCode
+}
+
+// IsExample reports whether the file at rel is example code: under an
+// examples directory, or named like example_test.go or button.example.tsx
+func IsExample(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	base := strings.ToLower(path.Base(rel))
+	name := strings.TrimSuffix(base, path.Ext(base))
+	if strings.HasPrefix(name, "example") || strings.HasSuffix(name, "example") || strings.HasSuffix(name, "examples") {
+		return true
+	}
+	for _, dir := range strings.Split(path.Dir(rel), "/") {
+		if exampleDirs[strings.ToLower(dir)] {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the file at rel (relative to the search root) is in scope
+// Examples include tests, which show usage too
+func (s Scope) Match(rel string) bool {
+	switch s {
+	case ScopeAll:
+		return true
+	case ScopeExamples:
+		return IsExample(rel) || Classify(rel) == ScopeTests
+	}
+	return Classify(rel) == s
+}
+
+// matchAbs reports whether an absolute file path under root is in scope
+func (s Scope) matchAbs(root, file string) bool {
+	if s == ScopeAll {
+		return true
+	}
+	return s.Match(slashRel(root, file))
+}
@@ -2,6 +2,8 @@ package search
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -38,21 +40,34 @@ type GrepOptions struct {
 
 	// MaxLineLength is the maximum line length before truncation
 	MaxLineLength int
+
+	// Scope restricts the search to docs, code or tests
+	Scope Scope
+
+	// Timeout aborts searches that take longer than this
+	Timeout time.Duration
 }
 
+// grepDeadlineLines is how often grepFile checks the deadline, in lines
+const grepDeadlineLines = 1024
+
+// ErrGrepTimeout is returned when a search runs past its timeout
+var ErrGrepTimeout = errors.New("search timed out")
+
 // DefaultGrepOptions returns the default grep options
 func DefaultGrepOptions() GrepOptions {
 	return GrepOptions{
 		MaxMatches:    100,
 		MaxLineLength: 2000,
+		Timeout:       30 * time.Second,
 	}
 }
 
 // Grep searches for a pattern in files under the given root directory
-// Uses ripgrep if available, otherwise falls back to Go implementation
+// Uses ripgrep if available and new enough, otherwise falls back to Go implementation
 func Grep(root, pattern string, opts GrepOptions) ([]Match, error) {
 	// Try ripgrep first
-	if RipgrepAvailable() {
+	if Ripgrep().Supports(ripgrepGrep) {
 		return RipgrepGrep(root, pattern, opts)
 	}
 
@@ -73,19 +88,26 @@ func goGrep(root, pattern string, opts GrepOptions) ([]Match, error) {
 	if opts.MaxLineLength == 0 {
 		opts.MaxLineLength = DefaultGrepOptions().MaxLineLength
 	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultGrepOptions().Timeout
+	}
+	deadline := time.Now().Add(opts.Timeout)
 
 	// Load gitignore patterns
 	ignorer := loadGitignore(root)
 
 	var matches []Match
 
-	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+	err = walk(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return nil // Skip errors
 		}
+		if time.Now().After(deadline) {
+			return ErrGrepTimeout
+		}
 
-		// Get relative path for gitignore matching
-		relPath, _ := filepath.Rel(root, path)
+		// Get relative path for include and scope matching
+		relPath := slashRel(root, path)
 
 		// Skip hidden directories (except root)
 		if d.IsDir() {
@@ -93,7 +115,7 @@ func goGrep(root, pattern string, opts GrepOptions) ([]Match, error) {
 				return filepath.SkipDir
 			}
 			// Check gitignore for directories
-			if ignorer != nil && ignorer.Match(relPath+"/", true) {
+			if ignorer != nil && ignorer.Match(path, true) {
 				return filepath.SkipDir
 			}
 			return nil
@@ -105,7 +127,7 @@ func goGrep(root, pattern string, opts GrepOptions) ([]Match, error) {
 		}
 
 		// Check gitignore
-		if ignorer != nil && ignorer.Match(relPath, false) {
+		if ignorer != nil && ignorer.Match(path, false) {
 			return nil
 		}
 
@@ -121,13 +143,21 @@ func goGrep(root, pattern string, opts GrepOptions) ([]Match, error) {
 			}
 		}
 
+		// Apply scope filter
+		if !opts.Scope.Match(relPath) {
+			return nil
+		}
+
 		// Skip binary files
 		if isBinaryFile(path) {
 			return nil
 		}
 
 		// Search file
-		fileMatches, err := grepFile(path, re, opts.MaxLineLength)
+		fileMatches, err := grepFile(path, re, opts.MaxLineLength, deadline)
+		if errors.Is(err, ErrGrepTimeout) {
+			return err
+		}
 		if err != nil {
 			return nil // Skip errors
 		}
@@ -142,6 +172,9 @@ func goGrep(root, pattern string, opts GrepOptions) ([]Match, error) {
 		return nil
 	})
 
+	if errors.Is(err, ErrGrepTimeout) {
+		return nil, fmt.Errorf("%w after %s", ErrGrepTimeout, opts.Timeout)
+	}
 	if err != nil && err != filepath.SkipAll {
 		return nil, err
 	}
@@ -160,7 +193,8 @@ func goGrep(root, pattern string, opts GrepOptions) ([]Match, error) {
 }
 
 // grepFile searches for a pattern in a single file
-func grepFile(path string, re *regexp.Regexp, maxLineLength int) ([]Match, error) {
+// Long files check the deadline as they go, so one huge file can't stall the search
+func grepFile(path string, re *regexp.Regexp, maxLineLength int, deadline time.Time) ([]Match, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -180,6 +214,10 @@ func grepFile(path string, re *regexp.Regexp, maxLineLength int) ([]Match, error
 		lineNum++
 		line := scanner.Text()
 
+		if lineNum%grepDeadlineLines == 0 && time.Now().After(deadline) {
+			return nil, ErrGrepTimeout
+		}
+
 		if re.MatchString(line) {
 			// Truncate long lines
 			displayLine := line
@@ -203,6 +241,9 @@ func grepFile(path string, re *regexp.Regexp, maxLineLength int) ([]Match, error
 type GlobOptions struct {
 	// MaxFiles is the maximum number of files to return
 	MaxFiles int
+
+	// Scope restricts the results to docs, code or tests
+	Scope Scope
 }
 
 // DefaultGlobOptions returns the default glob options
@@ -213,10 +254,10 @@ func DefaultGlobOptions() GlobOptions {
 }
 
 // Glob finds files matching a pattern under the given root directory
-// Uses ripgrep if available, otherwise falls back to Go implementation
+// Uses ripgrep if available and new enough, otherwise falls back to Go implementation
 func Glob(root, pattern string, opts GlobOptions) ([]FileInfo, error) {
 	// Try ripgrep first
-	if RipgrepAvailable() {
+	if Ripgrep().Supports(ripgrepGlob) {
 		return RipgrepGlob(root, pattern, opts)
 	}
 
@@ -235,13 +276,13 @@ func goGlob(root, pattern string, opts GlobOptions) ([]FileInfo, error) {
 
 	var files []FileInfo
 
-	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+	err := walk(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return nil // Skip errors
 		}
 
-		// Get relative path for pattern matching
-		relPath, _ := filepath.Rel(root, path)
+		// Get relative path for pattern and scope matching
+		relPath := slashRel(root, path)
 
 		// Skip hidden directories (except root)
 		if d.IsDir() {
@@ -249,7 +290,7 @@ func goGlob(root, pattern string, opts GlobOptions) ([]FileInfo, error) {
 				return filepath.SkipDir
 			}
 			// Check gitignore for directories
-			if ignorer != nil && ignorer.Match(relPath+"/", true) {
+			if ignorer != nil && ignorer.Match(path, true) {
 				return filepath.SkipDir
 			}
 			return nil
@@ -261,7 +302,7 @@ func goGlob(root, pattern string, opts GlobOptions) ([]FileInfo, error) {
 		}
 
 		// Check gitignore
-		if ignorer != nil && ignorer.Match(relPath, false) {
+		if ignorer != nil && ignorer.Match(path, false) {
 			return nil
 		}
 
@@ -274,7 +315,7 @@ func goGlob(root, pattern string, opts GlobOptions) ([]FileInfo, error) {
 			matched, _ = doublestar.Match(pattern, relPath)
 		}
 
-		if matched {
+		if matched && opts.Scope.Match(relPath) {
 			info, err := d.Info()
 			if err != nil {
 				return nil
@@ -312,6 +353,7 @@ func goGlob(root, pattern string, opts GlobOptions) ([]FileInfo, error) {
 }
 
 // loadGitignore loads .gitignore patterns from the root directory
+// The matcher takes paths under root (as walked), not relative ones
 func loadGitignore(root string) gitignore.IgnoreMatcher {
 	gitignorePath := filepath.Join(root, ".gitignore")
 	ignorer, err := gitignore.NewGitIgnore(gitignorePath)
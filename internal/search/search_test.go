@@ -0,0 +1,200 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// searchTree builds a resource with nested, linked and awkwardly named files
+// and returns its root
+func searchTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"README.md":                "# Widgets\nNewWidget builds a widget\n",
+		"src/widget.go":            "package widget\n\nfunc NewWidget() {}\n",
+		"src/internal/util.go":     "package internal\n\n// NewWidget helpers\n",
+		"docs/guide with space.md": "Call NewWidget first\n",
+		"vendor/lib/lib.go":        "package lib\n\nfunc NewWidget() {}\n",
+	}
+	// | and : separate ripgrep's output fields, and Windows forbids them
+	if runtime.GOOS != "windows" {
+		files["docs/a|b:c.md"] = "NewWidget: fields\n"
+	}
+	for name, body := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Collections link to their resources
+	if err := os.Symlink(filepath.Join(root, "vendor", "lib"), filepath.Join(root, "linked")); err != nil {
+		t.Skipf("can't create symlinks: %v", err)
+	}
+	return root
+}
+
+// withRipgrep runs searches with setting until the test ends
+func withRipgrep(t *testing.T, setting string) {
+	t.Helper()
+	SetRipgrep(setting)
+	t.Cleanup(func() { SetRipgrep("") })
+}
+
+// matchPaths lists the files and lines of matches relative to root, sorted
+func matchPaths(root string, matches []Match) []string {
+	var paths []string
+	for _, m := range matches {
+		paths = append(paths, slashRel(root, m.Path)+":"+strconv.Itoa(m.LineNum))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// filePaths lists files relative to root, sorted
+func filePaths(root string, files []FileInfo) []string {
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, slashRel(root, f.Path))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBuiltinSearch(t *testing.T) {
+	root := searchTree(t)
+	withRipgrep(t, RipgrepOff)
+
+	matches, err := Grep(root, "NewWidget", GrepOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"README.md:2", "docs/guide with space.md:1", "linked/lib.go:3", "src/internal/util.go:3", "src/widget.go:3", "vendor/lib/lib.go:3"}
+	if runtime.GOOS != "windows" {
+		want = append(want, "docs/a|b:c.md:1")
+		sort.Strings(want)
+	}
+	if got := matchPaths(root, matches); !equal(got, want) {
+		t.Errorf("grep found %v, want %v", got, want)
+	}
+
+	files, err := Glob(root, "src/**/*.go", GlobOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := filePaths(root, files); !equal(got, []string{"src/internal/util.go", "src/widget.go"}) {
+		t.Errorf("glob found %v", got)
+	}
+
+	matches, err = Grep(root, "NewWidget", GrepOptions{Include: "*.md", Scope: ScopeDocs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range matches {
+		if filepath.Ext(m.Path) != ".md" {
+			t.Errorf("include *.md matched %s", m.Path)
+		}
+	}
+}
+
+func TestRipgrepMatchesBuiltin(t *testing.T) {
+	if !RipgrepAvailable() {
+		t.Skip("ripgrep isn't installed")
+	}
+	root := searchTree(t)
+
+	for _, opts := range []GrepOptions{{}, {Include: "*.go"}, {Include: "src/**/*.go"}, {Scope: ScopeCode}} {
+		builtin, err := goGrep(root, "NewWidget", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rg, err := RipgrepGrep(root, "NewWidget", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := matchPaths(root, rg), matchPaths(root, builtin); !equal(got, want) {
+			t.Errorf("grep %+v: ripgrep found %v, the built-in search %v", opts, got, want)
+		}
+	}
+
+	for _, pattern := range []string{"*.go", "**/*.md", "src/**/*.go"} {
+		builtin, err := goGlob(root, pattern, GlobOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rg, err := RipgrepGlob(root, pattern, GlobOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := filePaths(root, rg), filePaths(root, builtin); !equal(got, want) {
+			t.Errorf("glob %s: ripgrep found %v, the built-in search %v", pattern, got, want)
+		}
+	}
+}
+
+func TestMissingRipgrepFallsBack(t *testing.T) {
+	root := searchTree(t)
+	withRipgrep(t, filepath.Join(t.TempDir(), "rg-missing"))
+
+	if info := Ripgrep(); info.Path != "" || info.Err == nil {
+		t.Fatalf("missing ripgrep detected as %+v", info)
+	}
+	matches, err := Grep(root, "func NewWidget", GrepOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := matchPaths(root, matches); !equal(got, []string{"linked/lib.go:3", "src/widget.go:3", "vendor/lib/lib.go:3"}) {
+		t.Errorf("grep found %v", got)
+	}
+}
+
+func TestLinkedDirectoriesFiltered(t *testing.T) {
+	root, target := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(target, "lib.go"), []byte("func NewWidget() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("ignored/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range []string{"lib", "ignored", ".hidden"} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skipf("can't create symlinks: %v", err)
+		}
+	}
+	withRipgrep(t, RipgrepOff)
+
+	matches, err := Grep(root, "NewWidget", GrepOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := matchPaths(root, matches); !equal(got, []string{"lib/lib.go:1"}) {
+		t.Errorf("grep found %v", got)
+	}
+	files, err := Glob(root, "**/*.go", GlobOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := filePaths(root, files); !equal(got, []string{"lib/lib.go"}) {
+		t.Errorf("glob found %v", got)
+	}
+}
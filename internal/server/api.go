@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/storage"
+)
+
+// ResourceInfo describes a configured resource
+type ResourceInfo struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Notes string `json:"notes,omitempty"`
+}
+
+// ModelListInfo describes a configured model
+type ModelListInfo struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Default  bool   `json:"default"`
+}
+
+// ThreadSummary describes a thread in list responses
+type ThreadSummary struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Updated   time.Time `json:"updated"`
+	Resources []string  `json:"resources"`
+	Model     string    `json:"model"`
+	Summary   string    `json:"summary,omitempty"`
+}
+
+// ThreadMessage is a user or assistant message in thread responses
+type ThreadMessage struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ThreadDetail is the response of GET /api/threads/{id}
+type ThreadDetail struct {
+	ThreadSummary
+	Messages []ThreadMessage `json:"messages"`
+}
+
+// handleResources lists configured resources
+func (s *Server) handleResources(w http.ResponseWriter, r *http.Request) {
+	result := make([]ResourceInfo, 0, len(s.cfg.Resources))
+	for _, res := range s.cfg.Resources {
+		result = append(result, ResourceInfo{
+			Name:  res.Name,
+			Type:  string(res.Type),
+			Notes: res.Notes,
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleModels lists configured models
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	defaultModel, _ := s.cfg.GetModelConfig("")
+
+	result := make([]ModelListInfo, 0, len(s.cfg.Models))
+	for _, m := range s.cfg.Models {
+		result = append(result, ModelListInfo{
+			Name:     m.Name,
+			Provider: string(m.Provider),
+			Model:    m.Model,
+			Default:  defaultModel != nil && defaultModel.Name == m.Name,
+		})
+	}
+	if len(result) == 0 && defaultModel != nil {
+		// Legacy flat config
+		result = append(result, ModelListInfo{
+			Name:     defaultModel.Name,
+			Provider: string(defaultModel.Provider),
+			Model:    defaultModel.Model,
+			Default:  true,
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleThreads lists saved threads, newest first
+func (s *Server) handleThreads(w http.ResponseWriter, r *http.Request) {
+	threads, err := s.storage(r.Context()).ListThreads()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := make([]ThreadSummary, 0, len(threads))
+	for _, t := range threads {
+		result = append(result, newThreadSummary(t))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleThread returns a single thread with its user and assistant messages
+func (s *Server) handleThread(w http.ResponseWriter, r *http.Request) {
+	thread, err := s.storage(r.Context()).LoadThread(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	detail := ThreadDetail{
+		ThreadSummary: newThreadSummary(thread),
+		Messages:      []ThreadMessage{},
+	}
+	for _, msg := range thread.Messages {
+		// Skip tool results and intermediate assistant tool-call turns
+		if msg.Role == "tool" || msg.Content == "" {
+			continue
+		}
+		detail.Messages = append(detail.Messages, ThreadMessage{
+			Role:      msg.Role,
+			Content:   msg.Content,
+			Timestamp: msg.Timestamp,
+		})
+	}
+	writeJSON(w, http.StatusOK, detail)
+}
+
+// storage returns the thread storage for the request's user
+func (s *Server) storage(ctx context.Context) *storage.Storage {
+	return storage.NewStorage(s.paths.DataDir).WithNamespace(userFromContext(ctx))
+}
+
+// newThreadSummary builds a thread summary
+func newThreadSummary(t *storage.Thread) ThreadSummary {
+	summary := ThreadSummary{
+		ID:        t.ID,
+		Title:     t.Title,
+		Updated:   t.Updated,
+		Resources: t.Resources,
+		Model:     t.Model,
+	}
+	if t.Digest != nil {
+		summary.Summary = t.Digest.Summary
+	}
+	return summary
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// contextKey is the type for request context keys
+type contextKey string
+
+// userContextKey holds the authenticated user name
+const userContextKey contextKey = "user"
+
+// slackUser is the user Slack conversations are asked as; API key users
+// can't start with @, so no API key user shares its threads
+const slackUser = "@slack"
+
+// withUser returns a context carrying the authenticated user
+func withUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+// userFromContext returns the authenticated user ("" if auth is disabled)
+func userFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(userContextKey).(string)
+	return user
+}
+
+// requireAuth wraps a handler with API key authentication
+// If no API keys are configured, requests pass through unauthenticated
+func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(s.cfg.Serve.APIKeys) == 0 {
+			next(w, r)
+			return
+		}
+
+		user, ok := s.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="btcx"`)
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid API key"))
+			return
+		}
+
+		next(w, r.WithContext(withUser(r.Context(), user)))
+	}
+}
+
+// authenticate returns the user for the request's API key
+// Keys are read from "Authorization: Bearer <key>" or "X-API-Key: <key>"
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	key := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); key == "" && auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			key = strings.TrimSpace(token)
+		}
+	}
+	if key == "" {
+		return "", false
+	}
+
+	for _, k := range s.cfg.Serve.APIKeys {
+		if k.Key != "" && subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) == 1 {
+			return k.User, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,325 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/nickcecere/btcx/internal/agent"
+	"github.com/nickcecere/btcx/internal/storage"
+	"github.com/nickcecere/btcx/internal/tool"
+)
+
+// errOutsideCollection hides where a rejected path points
+var errOutsideCollection = errors.New("path is outside the collection")
+
+// maxViewBytes limits the size of files the file browser shows
+const maxViewBytes = 2 << 20 // 2MB
+
+// filePage renders a highlighted file
+var filePage = template.Must(template.New("file").Parse(`<!doctype html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <meta name="robots" content="noindex">
+  <title>{{.Path}} - btcx</title>
+  <style>
+    body { margin: 0; padding: 16px 24px; background: #0f1115; color: #e6e6e6;
+      font: 14px/1.5 -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; }
+    h1 { font-size: 16px; margin: 8px 0 4px; font-family: ui-monospace, SFMono-Regular, Menlo, monospace; }
+    .meta { color: #8b93a1; font-size: 12px; margin-bottom: 16px; }
+    pre { padding: 10px; border-radius: 6px; overflow-x: auto; font-size: 13px;
+      font-family: ui-monospace, SFMono-Regular, Menlo, monospace; }
+    pre a { color: inherit; text-decoration: none; }
+    :target { outline: 1px solid #27a4f2; }
+  </style>
+</head>
+<body>
+  <h1>{{.Path}}</h1>
+  <div class="meta">{{.Lines}} lines{{if .Start}} · cited lines {{.Start}}{{if gt .End .Start}}-{{.End}}{{end}}{{end}}</div>
+  {{.Code}}
+</body>
+</html>
+`))
+
+// fileView is a file to show and the lines to highlight
+type fileView struct {
+	// Path is the file path shown in the page title
+	Path string
+
+	// Start and End are the highlighted lines (0 for none)
+	Start, End int
+}
+
+// handleCollectionFile shows a file of a collection
+// The first path element is the resource, as in citation paths
+func (s *Server) handleCollectionFile(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		http.NotFound(w, r)
+		return
+	}
+	collection, err := s.mgr.GetCollection(name)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	rel := r.PathValue("path")
+	path, err := tool.NewSandbox(collection.Path).Resolve(collection.Path, filepath.FromSlash(rel))
+	if err != nil {
+		writeError(w, http.StatusForbidden, errOutsideCollection)
+		return
+	}
+	s.serveFile(w, r, path, rel)
+}
+
+// handleSharedFile shows a file cited in a shared thread
+// Like the share page it needs no API key, so only cited files are served
+func (s *Server) handleSharedFile(w http.ResponseWriter, r *http.Request) {
+	_, thread, err := storage.NewStorage(s.paths.DataDir).LoadShare(r.PathValue("token"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	rel := r.PathValue("path")
+	if !citedInThread(thread, rel) {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Resolve against the resource itself; the thread's collection may be gone
+	name, inner, _ := strings.Cut(rel, "/")
+	res, ok := s.cfg.GetResource(name)
+	if !ok || !slices.Contains(thread.Resources, name) {
+		http.NotFound(w, r)
+		return
+	}
+	root, err := s.mgr.GetWorkingPath(res)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	path, err := resolveInside(root, filepath.FromSlash(inner))
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusForbidden, errOutsideCollection)
+		return
+	}
+	s.serveFile(w, r, path, rel)
+}
+
+// resolveInside resolves rel under root with all symlinks followed, and
+// rejects paths that end up outside root
+// Unlike the tools' sandbox it allows no other roots, so a link in the
+// resource can't expose the rest of the disk
+func resolveInside(root, rel string) (string, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(realRoot, rel))
+	if err != nil {
+		return "", err
+	}
+	inner, err := filepath.Rel(realRoot, path)
+	if err != nil || inner == ".." || strings.HasPrefix(inner, ".."+string(filepath.Separator)) {
+		return "", errOutsideCollection
+	}
+	return path, nil
+}
+
+// serveFile writes a text file as a highlighted page, or as plain text
+// with ?raw=1; ?lines=10-20 highlights the cited lines
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request, path, rel string) {
+	view := fileView{Path: rel}
+	if lines := r.URL.Query().Get("lines"); lines != "" {
+		start, end, err := parseLineRange(lines)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		view.Start, view.End = start, end
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	if info.Size() > maxViewBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("file is larger than %d bytes", maxViewBytes))
+		return
+	}
+	if binary, err := tool.IsBinaryContent(path); err != nil || binary {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("not a text file: %s", rel))
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to read file: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	if r.URL.Query().Get("raw") == "1" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write(content)
+		return
+	}
+
+	page, err := renderFile(view, string(content))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(page)
+}
+
+// renderFile highlights a file's source and renders it as a page
+// Line numbers link to #L<n> anchors
+func renderFile(view fileView, source string) ([]byte, error) {
+	lexer := lexers.Match(filepath.Base(view.Path))
+	if lexer == nil {
+		lexer = lexers.Analyse(source)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	options := []chromahtml.Option{
+		chromahtml.WithLineNumbers(true),
+		chromahtml.WithLinkableLineNumbers(true, "L"),
+		chromahtml.TabWidth(4),
+	}
+	if view.Start > 0 {
+		options = append(options, chromahtml.HighlightLines([][2]int{{view.Start, view.End}}))
+	}
+
+	iterator, err := lexer.Tokenise(nil, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to highlight file: %w", err)
+	}
+	var code bytes.Buffer
+	if err := chromahtml.New(options...).Format(&code, styles.Get("github-dark"), iterator); err != nil {
+		return nil, fmt.Errorf("failed to highlight file: %w", err)
+	}
+
+	var buf bytes.Buffer
+	err = filePage.Execute(&buf, map[string]any{
+		"Path":  view.Path,
+		"Lines": strings.Count(strings.TrimSuffix(source, "\n"), "\n") + 1,
+		"Start": view.Start,
+		"End":   view.End,
+		"Code":  template.HTML(code.String()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render file: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// parseLineRange parses "10" or "10-20"
+func parseLineRange(s string) (int, int, error) {
+	first, last, ranged := strings.Cut(s, "-")
+	start, err := strconv.Atoi(first)
+	if err != nil || start < 1 {
+		return 0, 0, fmt.Errorf("invalid lines %q (expected e.g. 10 or 10-20)", s)
+	}
+	if !ranged {
+		return start, start, nil
+	}
+	end, err := strconv.Atoi(last)
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("invalid lines %q (expected e.g. 10 or 10-20)", s)
+	}
+	return start, end, nil
+}
+
+// fileURL returns the file browser link of a citation under prefix (e.g.
+// "/share/<token>/files"), or "" for paths outside the collection
+func fileURL(prefix string, c agent.Citation) string {
+	if c.Path == "" || filepath.IsAbs(filepath.FromSlash(c.Path)) || strings.HasPrefix(c.Path, "../") {
+		return ""
+	}
+
+	segments := strings.Split(c.Path, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	link := prefix + "/" + strings.Join(segments, "/")
+	if c.StartLine > 0 {
+		end := c.EndLine
+		if end < c.StartLine {
+			end = c.StartLine
+		}
+		link += fmt.Sprintf("?lines=%d-%d#L%d", c.StartLine, end, c.StartLine)
+	}
+	return link
+}
+
+// threadCitations returns the files each answer of a thread cited, keyed
+// by the answer's message index
+func threadCitations(thread *storage.Thread) map[int][]agent.Citation {
+	cited := make(map[int][]agent.Citation)
+	var toolCalls []storage.ToolCall
+	for i, msg := range thread.Messages {
+		switch {
+		case msg.Role == "user":
+			toolCalls = nil
+		case msg.Role == "tool":
+			// Threads saved before calls were marked failed only have the
+			// failure in the result
+			if len(msg.ToolResults) > 0 && msg.ToolResults[0].Failed() {
+				for j := range toolCalls {
+					if toolCalls[j].ID == msg.ToolCallID {
+						toolCalls[j].Failed = true
+					}
+				}
+			}
+		case msg.Role == "assistant":
+			toolCalls = append(toolCalls, msg.ToolCalls...)
+			if msg.Content != "" {
+				cited[i] = agent.Citations(toolCalls)
+				toolCalls = nil
+			}
+		}
+	}
+	return cited
+}
+
+// citedInThread reports whether any answer of a thread cited the path
+func citedInThread(thread *storage.Thread, path string) bool {
+	for _, citations := range threadCitations(thread) {
+		for _, c := range citations {
+			if c.Path == path {
+				return true
+			}
+		}
+	}
+	return false
+}
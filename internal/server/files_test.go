@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nickcecere/btcx/internal/storage"
+)
+
+func TestResolveInsideRejectsEscapingSymlinks(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "src", "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skipf("can't create symlinks: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "src"), filepath.Join(root, "inner")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rel := range []string{"link/secret", "../" + filepath.Base(outside) + "/secret"} {
+		if path, err := resolveInside(root, filepath.FromSlash(rel)); err == nil {
+			t.Errorf("%s resolved to %s", rel, path)
+		}
+	}
+	for _, rel := range []string{"src/main.go", "inner/main.go"} {
+		if _, err := resolveInside(root, filepath.FromSlash(rel)); err != nil {
+			t.Errorf("%s: %v", rel, err)
+		}
+	}
+}
+
+func TestFailedReadsAreNotCited(t *testing.T) {
+	read := func(id, path string) storage.ToolCall {
+		args, _ := json.Marshal(map[string]string{"filePath": path})
+		return storage.ToolCall{ID: id, Name: "read", Arguments: args}
+	}
+	thread := &storage.Thread{Messages: []storage.Message{
+		{Role: "user", Content: "question"},
+		{Role: "assistant", ToolCalls: []storage.ToolCall{read("1", "res/link/etc/passwd"), read("2", "res/main.go")}},
+		{Role: "tool", ToolCallID: "1", ToolResults: []storage.ToolResult{{ToolCallID: "1", Output: "Error: path is outside the sandbox"}}},
+		{Role: "tool", ToolCallID: "2", ToolResults: []storage.ToolResult{{ToolCallID: "2", Output: "package main"}}},
+		{Role: "assistant", Content: "answer"},
+	}}
+
+	if citedInThread(thread, "res/link/etc/passwd") {
+		t.Error("a failed read was cited")
+	}
+	if !citedInThread(thread, "res/main.go") {
+		t.Error("a successful read wasn't cited")
+	}
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/provider"
+	"golang.org/x/time/rate"
+)
+
+// errBusy is returned when the ask queue is full
+var errBusy = errors.New("server is busy, try again later")
+
+// askLimiter bounds the number of running and queued asks
+type askLimiter struct {
+	slots   chan struct{}
+	mu      sync.Mutex
+	pending int
+	max     int
+}
+
+// newAskLimiter creates a limiter allowing maxConcurrent running asks
+// and maxQueue waiting asks
+func newAskLimiter(maxConcurrent, maxQueue int) *askLimiter {
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+	if maxQueue < 0 {
+		maxQueue = 0
+	}
+	return &askLimiter{
+		slots: make(chan struct{}, maxConcurrent),
+		max:   maxConcurrent + maxQueue,
+	}
+}
+
+// acquire waits for a slot, returning errBusy if the queue is full
+// The returned function must be called to release the slot
+func (l *askLimiter) acquire(ctx context.Context) (func(), error) {
+	l.mu.Lock()
+	if l.pending >= l.max {
+		l.mu.Unlock()
+		return nil, errBusy
+	}
+	l.pending++
+	l.mu.Unlock()
+
+	done := func() {
+		l.mu.Lock()
+		l.pending--
+		l.mu.Unlock()
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return func() {
+			<-l.slots
+			done()
+		}, nil
+	case <-ctx.Done():
+		done()
+		return nil, ctx.Err()
+	}
+}
+
+// rateLimiter applies a token bucket per client key
+type rateLimiter struct {
+	mu    sync.Mutex
+	limit rate.Limit
+	burst int
+
+	// idle is how long an empty bucket takes to fill up; a client idle
+	// that long has a full bucket, like a new one, so its bucket is dropped
+	idle time.Duration
+
+	limiters map[string]*clientLimiter
+	swept    time.Time
+}
+
+// clientLimiter is the token bucket of one client
+type clientLimiter struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+// newRateLimiter creates a per-key rate limiter, or nil if unlimited
+func newRateLimiter(limits config.ServeLimits) *rateLimiter {
+	if limits.RequestsPerMinute <= 0 {
+		return nil
+	}
+	burst := limits.Burst
+	if burst <= 0 {
+		burst = limits.RequestsPerMinute
+	}
+	every := time.Minute / time.Duration(limits.RequestsPerMinute)
+	return &rateLimiter{
+		limit:    rate.Every(every),
+		burst:    burst,
+		idle:     time.Duration(burst) * every,
+		limiters: make(map[string]*clientLimiter),
+	}
+}
+
+// reserve reports whether the key may make a request now, and if not,
+// how long until it may
+func (rl *rateLimiter) reserve(key string) (bool, time.Duration) {
+	now := time.Now()
+	rl.mu.Lock()
+	rl.sweep(now)
+	c, ok := rl.limiters[key]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(rl.limit, rl.burst)}
+		rl.limiters[key] = c
+	}
+	c.seen = now
+	rl.mu.Unlock()
+
+	r := c.limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// sweep drops the buckets of clients idle long enough for them to be full,
+// so clients that come and go don't grow the map; it runs at most once per
+// idle period
+func (rl *rateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.swept) < rl.idle {
+		return
+	}
+	rl.swept = now
+	for key, c := range rl.limiters {
+		if now.Sub(c.seen) >= rl.idle {
+			delete(rl.limiters, key)
+		}
+	}
+}
+
+// limitAsk wraps an ask handler with per-key rate limiting and the
+// concurrency limiter, rejecting overflow with 429 Too Many Requests
+func (s *Server) limitAsk(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := clientKey(r)
+		if s.rates != nil {
+			if ok, delay := s.rates.reserve(key); !ok {
+				tooManyRequests(w, delay, errors.New("rate limit exceeded"))
+				return
+			}
+		}
+
+		release, err := s.limiter.acquire(r.Context())
+		if err != nil {
+			tooManyRequests(w, 5*time.Second, err)
+			return
+		}
+		defer release()
+
+		// Model requests of different clients take turns when the
+		// scheduler limits them
+		next(w, r.WithContext(provider.WithClient(r.Context(), key)))
+	}
+}
+
+// clientKey identifies the client for rate limiting: the authenticated
+// user if auth is enabled, otherwise the remote IP
+func clientKey(r *http.Request) string {
+	if user := userFromContext(r.Context()); user != "" {
+		return "user:" + user
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// tooManyRequests writes a 429 response with a Retry-After header
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration, err error) {
+	secs := int(retryAfter.Round(time.Second) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	writeError(w, http.StatusTooManyRequests, err)
+}
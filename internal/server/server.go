@@ -0,0 +1,292 @@
+// Package server implements the HTTP API started by `btcx serve`.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/agent"
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/resource"
+)
+
+// maxBodyBytes limits the size of request bodies
+const maxBodyBytes = 1 << 20 // 1MB
+
+// Server serves the btcx HTTP API
+type Server struct {
+	cfg   *config.Config
+	paths *config.Paths
+	mgr   *resource.Manager
+	mux   *http.ServeMux
+
+	// prepareMu serializes collection preparation, which rewrites symlinks
+	prepareMu sync.Mutex
+
+	// limiter bounds concurrent and queued asks
+	limiter *askLimiter
+
+	// rates applies per-client rate limits (nil if unlimited)
+	rates *rateLimiter
+}
+
+// New creates a new server
+func New(cfg *config.Config, paths *config.Paths) *Server {
+	s := &Server{
+		cfg:   cfg,
+		paths: paths,
+		mgr:   resource.NewManager(cfg.Cache.ResolvedPath).WithLockFile(cfg.ResolvedLockFile),
+		mux:   http.NewServeMux(),
+
+		limiter: newAskLimiter(cfg.Serve.Limits.MaxConcurrent, cfg.Serve.Limits.MaxQueue),
+		rates:   newRateLimiter(cfg.Serve.Limits),
+	}
+	s.routes()
+	return s
+}
+
+// routes registers all HTTP handlers
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	s.mux.HandleFunc("POST /api/ask", s.requireAuth(s.limitAsk(s.handleAsk)))
+	s.mux.HandleFunc("POST /api/ask/stream", s.requireAuth(s.limitAsk(s.handleAskStream)))
+	s.mux.HandleFunc("GET /api/resources", s.requireAuth(s.handleResources))
+	s.mux.HandleFunc("GET /api/models", s.requireAuth(s.handleModels))
+	s.mux.HandleFunc("GET /api/threads", s.requireAuth(s.handleThreads))
+	s.mux.HandleFunc("GET /api/threads/{id}", s.requireAuth(s.handleThread))
+	s.mux.HandleFunc("POST /api/threads/{id}/share", s.requireAuth(s.handleShareThread))
+	s.mux.HandleFunc("GET /collections/{name}/files/{path...}", s.requireAuth(s.handleCollectionFile))
+	s.mux.HandleFunc("GET /share/{token}", s.handleSharedThread)
+	s.mux.HandleFunc("GET /share/{token}/files/{path...}", s.handleSharedFile)
+	s.mux.Handle("GET /", webHandler())
+
+	if s.cfg.Serve.Slack.Enabled() {
+		slack := newSlackHandler(s, s.cfg.Serve.Slack)
+		s.mux.HandleFunc("POST /slack/events", slack.handleEvents)
+		s.mux.HandleFunc("POST /slack/commands", slack.handleCommand)
+	}
+}
+
+// Handler returns the HTTP handler for the server
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// ListenAndServe serves HTTP on the configured address until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.cfg.Serve.Addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+// AskRequest is the body of POST /api/ask
+type AskRequest struct {
+	// Resources are the resource names to search
+	Resources []string `json:"resources"`
+
+	// Question is the question to ask
+	Question string `json:"question"`
+
+	// Model is the model name to use (optional, defaults to config default)
+	Model string `json:"model,omitempty"`
+
+	// ThreadID continues an existing thread (optional)
+	ThreadID string `json:"thread_id,omitempty"`
+
+	// NoAnswerCache bypasses the answer cache (optional)
+	NoAnswerCache bool `json:"no_answer_cache,omitempty"`
+}
+
+// AskResponse is the response of POST /api/ask
+type AskResponse struct {
+	Answer    string           `json:"answer"`
+	ThreadID  string           `json:"thread_id"`
+	Citations []agent.Citation `json:"citations"`
+	Usage     UsageInfo        `json:"usage"`
+	Model     ModelInfo        `json:"model"`
+	Resources []string         `json:"resources"`
+	Cached    bool             `json:"cached,omitempty"`
+
+	// Collection names the collection searched; cited files can be viewed
+	// at /collections/{collection}/files/{path}
+	Collection string `json:"collection,omitempty"`
+}
+
+// UsageInfo represents token usage in API responses
+type UsageInfo struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+
+	// CacheReadTokens and CacheWriteTokens are the input tokens read from and
+	// written to the provider's prompt cache
+	CacheReadTokens  int `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
+}
+
+// ModelInfo represents model info in API responses
+type ModelInfo struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// handleHealth reports that the server is up
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleAsk answers a question synchronously
+func (s *Server) handleAsk(w http.ResponseWriter, r *http.Request) {
+	var req AskRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	resp, a, err := s.ask(r.Context(), &req, nil)
+	if err != nil {
+		writeError(w, statusForError(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAskResponse(resp, a))
+}
+
+// newAskResponse builds the API response for a completed ask
+func newAskResponse(resp *agent.Response, a *agent.Agent) *AskResponse {
+	return &AskResponse{
+		Answer:    resp.Content,
+		ThreadID:  a.Thread.ID,
+		Citations: a.AttributedCitations(resp.ToolCalls),
+		Usage: UsageInfo{
+			InputTokens:      resp.Usage.InputTokens,
+			OutputTokens:     resp.Usage.OutputTokens,
+			CacheReadTokens:  resp.Usage.CacheReadTokens,
+			CacheWriteTokens: resp.Usage.CacheWriteTokens,
+		},
+		Model: ModelInfo{
+			Name:     a.ModelConfig.Name,
+			Provider: string(a.ModelConfig.Provider),
+			Model:    a.ModelConfig.Model,
+		},
+		Resources:  a.Thread.Resources,
+		Cached:     resp.Cached != nil,
+		Collection: a.Collection.Name,
+	}
+}
+
+// requestError is an error caused by invalid client input
+type requestError struct {
+	err error
+}
+
+func (e *requestError) Error() string { return e.err.Error() }
+func (e *requestError) Unwrap() error { return e.err }
+
+// badRequest wraps an error as a client error
+func badRequest(format string, args ...any) error {
+	return &requestError{err: fmt.Errorf(format, args...)}
+}
+
+// statusForError maps an error to an HTTP status code
+func statusForError(err error) int {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// ask resolves the request, prepares the collection and runs the agent
+func (s *Server) ask(ctx context.Context, req *AskRequest, callback agent.StreamCallback) (*agent.Response, *agent.Agent, error) {
+	if req.Question == "" {
+		return nil, nil, badRequest("question is required")
+	}
+	if len(req.Resources) == 0 {
+		return nil, nil, badRequest("at least one resource is required")
+	}
+
+	modelCfg, err := s.cfg.GetModelConfig(req.Model)
+	if err != nil {
+		return nil, nil, badRequest("failed to get model: %v", err)
+	}
+
+	var configResources []*config.Resource
+	for _, name := range req.Resources {
+		r, ok := s.cfg.GetResource(name)
+		if !ok {
+			return nil, nil, badRequest("resource %q not found in config", name)
+		}
+		configResources = append(configResources, r)
+	}
+
+	s.prepareMu.Lock()
+	collection, err := s.mgr.EnsureCollection(ctx, configResources)
+	s.prepareMu.Unlock()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to prepare resources: %w", err)
+	}
+
+	a, err := agent.New(agent.Options{
+		Config:        s.cfg,
+		ModelConfig:   modelCfg,
+		Collection:    collection,
+		DataDir:       s.paths.DataDir,
+		Namespace:     userFromContext(ctx),
+		NoAnswerCache: req.NoAnswerCache,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create agent: %w", err)
+	}
+
+	if req.ThreadID != "" {
+		thread, err := a.Storage.LoadThread(req.ThreadID)
+		if err != nil {
+			return nil, nil, badRequest("%v", err)
+		}
+		a.ContinueThread(thread)
+	}
+
+	resp, err := a.AskWithCallback(ctx, req.Question, callback)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get response: %w", err)
+	}
+
+	return resp, a, nil
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/storage"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// ShareResponse is the response of POST /api/threads/{id}/share
+type ShareResponse struct {
+	Token   string    `json:"token"`
+	URL     string    `json:"url"`
+	Created time.Time `json:"created"`
+}
+
+// shareMarkdown renders answers on share pages
+// Raw HTML in answers is escaped (goldmark's default)
+var shareMarkdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// sharePage renders a shared thread
+var sharePage = template.Must(template.New("share").Parse(`<!doctype html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <meta name="robots" content="noindex">
+  <title>{{.Title}} - btcx</title>
+  <style>
+    body { margin: 0 auto; max-width: 900px; padding: 16px 24px; background: #0f1115; color: #e6e6e6;
+      font: 14px/1.5 -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; }
+    h1 { font-size: 20px; margin: 8px 0 4px; }
+    .meta { color: #8b93a1; font-size: 12px; margin-bottom: 16px; }
+    .message { margin: 0 0 16px; padding: 12px 16px; border-radius: 8px; }
+    .message.user { background: #1d2636; white-space: pre-wrap; }
+    .message.assistant { background: #171a21; border: 1px solid #2a2f3a; }
+    .role { color: #8b93a1; font-size: 12px; margin-bottom: 4px; white-space: normal; }
+    pre { background: #0b0d10; padding: 10px; border-radius: 6px; overflow-x: auto; }
+    code { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: 13px; }
+    :not(pre) > code { background: #0b0d10; padding: 1px 4px; border-radius: 4px; }
+    table { border-collapse: collapse; }
+    th, td { border: 1px solid #2a2f3a; padding: 4px 8px; }
+    a { color: #27a4f2; }
+    .sources { color: #8b93a1; font-size: 12px; margin-top: 8px; }
+  </style>
+</head>
+<body>
+  <h1>{{.Title}}</h1>
+  <div class="meta">{{range $i, $r := .Resources}}{{if $i}}, {{end}}{{$r}}{{end}} · {{.Model}} · {{.Updated.Format "2006-01-02 15:04"}}</div>
+  {{range .Messages}}
+  <div class="message {{.Role}}"><div class="role">{{if eq .Role "user"}}Question{{else}}btcx{{end}}</div>{{.Body}}
+    {{- if .Sources}}<div class="sources">Sources: {{range $i, $src := .Sources}}{{if $i}}, {{end}}{{if $src.URL}}<a href="{{$src.URL}}">{{$src.Path}}</a>{{else}}{{$src.Path}}{{end}}{{end}}</div>{{end -}}
+  </div>
+  {{end}}
+</body>
+</html>
+`))
+
+// shareMessage is a rendered message on a share page
+type shareMessage struct {
+	Role    string
+	Body    template.HTML
+	Sources []shareSource
+}
+
+// shareSource is a file an answer cited, linked to the file browser
+type shareSource struct {
+	Path string
+	URL  string
+}
+
+// handleShareThread creates a read-only link to one of the user's threads
+func (s *Server) handleShareThread(w http.ResponseWriter, r *http.Request) {
+	share, err := s.storage(r.Context()).CreateShare(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, ShareResponse{
+		Token:   share.Token,
+		URL:     s.baseURL(r) + "/share/" + share.Token,
+		Created: share.Created,
+	})
+}
+
+// handleSharedThread renders a shared thread as a read-only page
+// The token is the only credential, so no API key is needed
+func (s *Server) handleSharedThread(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	_, thread, err := storage.NewStorage(s.paths.DataDir).LoadShare(token)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	cited := threadCitations(thread)
+	messages := make([]shareMessage, 0, len(thread.Messages))
+	for i, msg := range thread.Messages {
+		// Skip tool results and intermediate assistant tool-call turns
+		if msg.Role == "tool" || msg.Content == "" {
+			continue
+		}
+		body := template.HTML(template.HTMLEscapeString(msg.Content))
+		if msg.Role == "assistant" {
+			var buf bytes.Buffer
+			if err := shareMarkdown.Convert([]byte(msg.Content), &buf); err == nil {
+				body = template.HTML(buf.String())
+			}
+		}
+		var sources []shareSource
+		for _, c := range cited[i] {
+			sources = append(sources, shareSource{Path: c.Path, URL: fileURL("/share/"+url.PathEscape(token)+"/files", c)})
+		}
+		messages = append(messages, shareMessage{Role: msg.Role, Body: body, Sources: sources})
+	}
+
+	var buf bytes.Buffer
+	err = sharePage.Execute(&buf, map[string]any{
+		"Title":     thread.Title,
+		"Resources": thread.Resources,
+		"Model":     thread.Model,
+		"Updated":   thread.Updated,
+		"Messages":  messages,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src https: data:")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// baseURL returns the URL links to the server start with: serve.publicURL,
+// or the scheme and host the request was made to
+// Forwarded headers can be set by any client, so they are only used with
+// serve.trustProxy
+func (s *Server) baseURL(r *http.Request) string {
+	if s.cfg.Serve.PublicURL != "" {
+		return strings.TrimSuffix(s.cfg.Serve.PublicURL, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+	if s.cfg.Serve.TrustProxy {
+		if r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" {
+			host = fwd
+		}
+	}
+	return scheme + "://" + host
+}
@@ -0,0 +1,434 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nickcecere/btcx/internal/agent"
+	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/provider"
+)
+
+const (
+	// slackAPIURL is the base URL for the Slack Web API
+	slackAPIURL = "https://slack.com/api"
+
+	// slackMaxTimestampAge rejects requests older than this (replay protection)
+	slackMaxTimestampAge = 5 * time.Minute
+
+	// slackMaxTextLength keeps messages under Slack's 40k character limit
+	slackMaxTextLength = 39000
+
+	// slackAskTimeout bounds how long a single Slack ask may run
+	slackAskTimeout = 5 * time.Minute
+
+	// slackThreadIdle is how long a Slack thread without mentions keeps its
+	// conversation; later mentions start a new one
+	slackThreadIdle = 24 * time.Hour
+)
+
+// slackMentionRegex matches user mentions like <@U0123ABC>
+var slackMentionRegex = regexp.MustCompile(`<@[A-Z0-9]+(\|[^>]*)?>`)
+
+// slackHandler handles Slack Events API and slash command requests
+type slackHandler struct {
+	server *Server
+	cfg    config.SlackConfig
+	client *http.Client
+
+	// threads maps "channel:thread_ts" to btcx thread IDs so replies in a
+	// Slack thread continue the same conversation
+	mu      sync.Mutex
+	threads map[string]*slackThread
+	swept   time.Time
+}
+
+// slackThread is the conversation of one Slack thread
+type slackThread struct {
+	id   string
+	seen time.Time
+}
+
+// newSlackHandler creates a new Slack handler
+func newSlackHandler(s *Server, cfg config.SlackConfig) *slackHandler {
+	return &slackHandler{
+		server:  s,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		threads: make(map[string]*slackThread),
+	}
+}
+
+// slackEnvelope is the outer payload of an Events API request
+type slackEnvelope struct {
+	Type      string     `json:"type"`
+	Challenge string     `json:"challenge"`
+	Event     slackEvent `json:"event"`
+}
+
+// slackEvent is an inner Events API event
+type slackEvent struct {
+	Type     string `json:"type"`
+	User     string `json:"user"`
+	BotID    string `json:"bot_id"`
+	Text     string `json:"text"`
+	Channel  string `json:"channel"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts"`
+}
+
+// handleEvents handles POST /slack/events
+func (h *slackHandler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	body, err := h.readVerified(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	var env slackEnvelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid event payload: %w", err))
+		return
+	}
+
+	switch env.Type {
+	case "url_verification":
+		writeJSON(w, http.StatusOK, map[string]string{"challenge": env.Challenge})
+		return
+	case "event_callback":
+		// Slack retries events that aren't acknowledged within 3 seconds;
+		// we always acknowledge immediately, so retries are duplicates
+		if r.Header.Get("X-Slack-Retry-Num") != "" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if env.Event.Type == "app_mention" && env.Event.BotID == "" {
+			go h.handleMention(env.Event)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleMention answers an app mention in the message's thread
+func (h *slackHandler) handleMention(ev slackEvent) {
+	threadTS := ev.ThreadTS
+	if threadTS == "" {
+		threadTS = ev.TS
+	}
+
+	question := strings.TrimSpace(slackMentionRegex.ReplaceAllString(ev.Text, ""))
+	if question == "" {
+		h.postReply(ev.Channel, threadTS, "Ask me a question about this channel's resources, e.g. `@btcx how do I create a subcommand?`")
+		return
+	}
+
+	h.answer(ev.Channel, threadTS, question)
+}
+
+// handleCommand handles POST /slack/commands (slash commands)
+func (h *slackHandler) handleCommand(w http.ResponseWriter, r *http.Request) {
+	body, err := h.readVerified(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid command payload: %w", err))
+		return
+	}
+
+	question := strings.TrimSpace(form.Get("text"))
+	channel := form.Get("channel_id")
+	user := form.Get("user_id")
+
+	if question == "" {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"response_type": "ephemeral",
+			"text":          fmt.Sprintf("Usage: `%s <question>`", form.Get("command")),
+		})
+		return
+	}
+
+	resources, _ := h.cfg.ChannelConfig(channel)
+	if len(resources) == 0 {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"response_type": "ephemeral",
+			"text":          "No resources are configured for this channel.",
+		})
+		return
+	}
+
+	go func() {
+		// Post the question as a top-level message and answer in its thread
+		ts, err := h.postMessage(channel, "", fmt.Sprintf("<@%s> asked: %s", user, question))
+		if err != nil {
+			log.Printf("slack: failed to post question: %v", err)
+			return
+		}
+		h.answer(channel, ts, question)
+	}()
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"response_type": "ephemeral",
+		"text":          fmt.Sprintf("Searching %s...", strings.Join(resources, ", ")),
+	})
+}
+
+// answer runs an ask for the channel and replies in the given thread
+func (h *slackHandler) answer(channel, threadTS, question string) {
+	resources, model := h.cfg.ChannelConfig(channel)
+	if len(resources) == 0 {
+		h.postReply(channel, threadTS, "No resources are configured for this channel.")
+		return
+	}
+
+	key := channel + ":" + threadTS
+	req := &AskRequest{
+		Resources: resources,
+		Question:  question,
+		Model:     model,
+	}
+	req.ThreadID = h.thread(key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), slackAskTimeout)
+	defer cancel()
+
+	// Slack conversations are kept in their own thread namespace
+	ctx = withUser(ctx, slackUser)
+	// but each channel waits for model requests in its own queue
+	ctx = provider.WithClient(ctx, "slack:"+channel)
+
+	release, err := h.server.limiter.acquire(ctx)
+	if err != nil {
+		h.postReply(channel, threadTS, fmt.Sprintf(":hourglass: %v", err))
+		return
+	}
+	defer release()
+
+	resp, a, err := h.server.ask(ctx, req, nil)
+	if err != nil {
+		log.Printf("slack: ask failed: %v", err)
+		h.postReply(channel, threadTS, fmt.Sprintf(":warning: %v", err))
+		return
+	}
+
+	h.remember(key, a.Thread.ID)
+	h.postReply(channel, threadTS, formatSlackAnswer(resp.Content, a.AttributedCitations(resp.ToolCalls)))
+}
+
+// thread returns the btcx thread ID of a Slack thread, or "" if it has
+// none or has been idle too long
+func (h *slackHandler) thread(key string) string {
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sweep(now)
+	if t, ok := h.threads[key]; ok && now.Sub(t.seen) < slackThreadIdle {
+		return t.id
+	}
+	return ""
+}
+
+// remember records the btcx thread ID of a Slack thread
+func (h *slackHandler) remember(key, id string) {
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sweep(now)
+	h.threads[key] = &slackThread{id: id, seen: now}
+}
+
+// sweep drops the Slack threads idle longer than slackThreadIdle, so the
+// map doesn't grow with every thread ever answered; it runs at most once
+// per idle period
+func (h *slackHandler) sweep(now time.Time) {
+	if now.Sub(h.swept) < slackThreadIdle {
+		return
+	}
+	h.swept = now
+	for key, t := range h.threads {
+		if now.Sub(t.seen) >= slackThreadIdle {
+			delete(h.threads, key)
+		}
+	}
+}
+
+// postReply posts a threaded reply, logging failures
+func (h *slackHandler) postReply(channel, threadTS, text string) {
+	if _, err := h.postMessage(channel, threadTS, text); err != nil {
+		log.Printf("slack: failed to post reply: %v", err)
+	}
+}
+
+// postMessage calls chat.postMessage and returns the message timestamp
+func (h *slackHandler) postMessage(channel, threadTS, text string) (string, error) {
+	if len(text) > slackMaxTextLength {
+		// Cut at a rune boundary, so the text stays valid UTF-8
+		cut := slackMaxTextLength
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut] + "\n…(truncated)"
+	}
+
+	payload := map[string]any{
+		"channel": channel,
+		"text":    text,
+		"mrkdwn":  true,
+	}
+	if threadTS != "" {
+		payload["thread_ts"] = threadTS
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, slackAPIURL+"/chat.postMessage", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+h.cfg.BotToken)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		TS    string `json:"ts"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid slack response: %w", err)
+	}
+	if !result.OK {
+		return "", fmt.Errorf("slack error: %s", result.Error)
+	}
+
+	return result.TS, nil
+}
+
+// readVerified reads the request body and verifies the Slack signature
+// See https://api.slack.com/authentication/verifying-requests-from-slack
+func (h *slackHandler) readVerified(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	signature := r.Header.Get("X-Slack-Signature")
+	if timestamp == "" || signature == "" {
+		return nil, fmt.Errorf("missing slack signature")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid slack timestamp")
+	}
+	if age := time.Since(time.Unix(ts, 0)); age > slackMaxTimestampAge || age < -slackMaxTimestampAge {
+		return nil, fmt.Errorf("stale slack request")
+	}
+
+	mac := hmac.New(sha256.New, []byte(h.cfg.SigningSecret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, fmt.Errorf("invalid slack signature")
+	}
+
+	return body, nil
+}
+
+// Markdown to Slack mrkdwn conversions
+var (
+	mdHeadingRegex = regexp.MustCompile(`^#{1,6}\s+(.+)$`)
+	mdBoldRegex    = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	mdLinkRegex    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdBulletRegex  = regexp.MustCompile(`^(\s*)[-*]\s+`)
+)
+
+// formatSlackAnswer renders an answer and its citations as Slack mrkdwn
+func formatSlackAnswer(content string, citations []agent.Citation) string {
+	var sb strings.Builder
+	sb.WriteString(markdownToMrkdwn(content))
+
+	if len(citations) > 0 {
+		sb.WriteString("\n\n*Sources*\n")
+		for _, c := range citations {
+			label := fmt.Sprintf("`%s`", c.Label())
+			if c.URL != "" {
+				label = fmt.Sprintf("<%s|%s>", c.URL, c.Label())
+			}
+			if c.StartLine > 0 && c.EndLine > 0 {
+				sb.WriteString(fmt.Sprintf("• %s (lines %d-%d)\n", label, c.StartLine, c.EndLine))
+			} else {
+				sb.WriteString(fmt.Sprintf("• %s\n", label))
+			}
+		}
+	}
+	if notes := agent.LicenseNotes(citations); len(notes) > 0 {
+		sb.WriteString("\n*Licenses*\n")
+		for _, note := range notes {
+			sb.WriteString("• " + note + "\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// markdownToMrkdwn converts common Markdown constructs to Slack mrkdwn
+// Code blocks are passed through unchanged (minus the language tag)
+func markdownToMrkdwn(content string) string {
+	lines := strings.Split(content, "\n")
+	inCode := false
+
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if !inCode {
+				// Slack doesn't support language tags on code fences
+				lines[i] = "```"
+			}
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+
+		if m := mdHeadingRegex.FindStringSubmatch(line); m != nil {
+			line = "*" + strings.Trim(m[1], "*") + "*"
+		}
+		line = mdBoldRegex.ReplaceAllString(line, "*$1$2*")
+		line = mdLinkRegex.ReplaceAllString(line, "<$2|$1>")
+		line = mdBulletRegex.ReplaceAllString(line, "$1• ")
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/nickcecere/btcx/internal/provider"
+)
+
+// sseWriter writes Server-Sent Events to a response
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// newSSEWriter prepares the response for an event stream
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming not supported")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &sseWriter{w: w, flusher: flusher}, nil
+}
+
+// send writes a single named event with a JSON payload
+func (s *sseWriter) send(event string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+	s.flusher.Flush()
+}
+
+// handleAskStream answers a question from a JSON body and streams the
+// response as SSE (see streamAsk for the events)
+func (s *Server) handleAskStream(w http.ResponseWriter, r *http.Request) {
+	var req AskRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	s.streamAsk(w, r, &req)
+}
+
+// ToolEvent is the payload of tool_start and tool_finish events
+type ToolEvent struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// streamAsk answers a question and streams the response as SSE
+//
+// Events:
+//
+//	text        {"delta": "..."}                 answer text as it is generated
+//	tool_start  ToolEvent                        the agent started a tool call
+//	tool_finish ToolEvent                        the tool call completed
+//	usage       UsageInfo                        tokens used by one model call
+//	status      {"tool": "grep"}                 the agent started using a tool (same as tool_start)
+//	done        AskResponse                      the final answer
+//	error       {"error": "..."}                 the ask failed
+func (s *Server) streamAsk(w http.ResponseWriter, r *http.Request, req *AskRequest) {
+	sse, err := newSSEWriter(w)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Tool calls are reported by the model stream and again when they run
+	started := make(map[string]bool)
+	callback := func(event provider.StreamEvent) {
+		switch event.Type {
+		case provider.StreamEventText:
+			sse.send("text", map[string]string{"delta": event.Delta})
+		case provider.StreamEventToolCall:
+			if tc := event.ToolCall; tc != nil && !started[tc.ID+tc.Name+string(tc.Arguments)] {
+				started[tc.ID+tc.Name+string(tc.Arguments)] = true
+				sse.send("tool_start", newToolEvent(event.ToolCall))
+				sse.send("status", map[string]string{"tool": event.ToolCall.Name})
+			}
+		case provider.StreamEventToolResult:
+			if event.ToolCall != nil {
+				sse.send("tool_finish", newToolEvent(event.ToolCall))
+			}
+		case provider.StreamEventDone:
+			if event.Usage != nil {
+				sse.send("usage", UsageInfo{
+					InputTokens:      event.Usage.InputTokens,
+					OutputTokens:     event.Usage.OutputTokens,
+					CacheReadTokens:  event.Usage.CacheReadTokens,
+					CacheWriteTokens: event.Usage.CacheWriteTokens,
+				})
+			}
+		}
+	}
+
+	resp, a, err := s.ask(r.Context(), req, callback)
+	if err != nil {
+		sse.send("error", map[string]string{"error": err.Error()})
+		return
+	}
+
+	sse.send("done", newAskResponse(resp, a))
+}
+
+// newToolEvent builds a tool event payload
+func newToolEvent(tc *provider.ToolCall) ToolEvent {
+	return ToolEvent{ID: tc.ID, Name: tc.Name, Arguments: tc.Arguments}
+}
@@ -0,0 +1,21 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// webFS contains the static assets for the web chat UI
+//
+//go:embed web
+var webFS embed.FS
+
+// webHandler serves the embedded web UI
+func webHandler() http.Handler {
+	sub, err := fs.Sub(webFS, "web")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServerFS(sub)
+}
@@ -0,0 +1,389 @@
+// btcx web chat UI
+(function () {
+  "use strict";
+
+  const $ = (id) => document.getElementById(id);
+
+  const state = {
+    threadId: null,
+    busy: false,
+  };
+
+  // --- API helpers -----------------------------------------------------------
+
+  // authHeaders returns the API key header if one has been entered
+  function authHeaders() {
+    const key = localStorage.getItem("btcx.apiKey");
+    return key ? { Authorization: "Bearer " + key } : {};
+  }
+
+  // authFetch performs a request, prompting for an API key on 401
+  async function authFetch(path, options) {
+    options = options || {};
+    for (let attempt = 0; attempt < 2; attempt++) {
+      const resp = await fetch(path, {
+        ...options,
+        headers: { ...(options.headers || {}), ...authHeaders() },
+      });
+      if (resp.status !== 401 || attempt > 0) return resp;
+
+      const key = window.prompt("This server requires an API key:");
+      if (!key) return resp;
+      localStorage.setItem("btcx.apiKey", key.trim());
+    }
+  }
+
+  async function api(path, options) {
+    const resp = await authFetch(path, options);
+    const data = await resp.json();
+    if (!resp.ok) {
+      throw new Error(data.error || resp.statusText);
+    }
+    return data;
+  }
+
+  // streamAsk posts a question and dispatches SSE events to handlers
+  async function streamAsk(body, handlers) {
+    const resp = await authFetch("api/ask/stream", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify(body),
+    });
+    if (!resp.ok) {
+      const data = await resp.json().catch(() => ({}));
+      throw new Error(data.error || resp.statusText);
+    }
+
+    const reader = resp.body.getReader();
+    const decoder = new TextDecoder();
+    let buffer = "";
+
+    for (;;) {
+      const { value, done } = await reader.read();
+      if (done) break;
+      buffer += decoder.decode(value, { stream: true });
+
+      let idx;
+      while ((idx = buffer.indexOf("\n\n")) >= 0) {
+        const raw = buffer.slice(0, idx);
+        buffer = buffer.slice(idx + 2);
+
+        let event = "message";
+        let data = "";
+        for (const line of raw.split("\n")) {
+          if (line.startsWith("event: ")) event = line.slice(7);
+          else if (line.startsWith("data: ")) data += line.slice(6);
+        }
+        const handler = handlers[event];
+        if (handler) handler(data ? JSON.parse(data) : null);
+      }
+    }
+  }
+
+  // --- Markdown rendering ----------------------------------------------------
+
+  function escapeHTML(s) {
+    return s.replace(/[&<>"']/g, (c) => ({
+      "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#39;",
+    })[c]);
+  }
+
+  function renderInline(s) {
+    return escapeHTML(s)
+      .replace(/`([^`]+)`/g, "<code>$1</code>")
+      .replace(/\*\*(.+?)\*\*/g, "<strong>$1</strong>")
+      .replace(/\[([^\]]+)\]\((https?:\/\/[^)\s]+)\)/g, '<a href="$2" target="_blank" rel="noopener">$1</a>');
+  }
+
+  // renderMarkdown renders a small, safe subset of Markdown
+  function renderMarkdown(md) {
+    const out = [];
+    const lines = md.split("\n");
+    let inCode = false;
+    let code = [];
+    let list = null;
+
+    const closeList = () => {
+      if (list) {
+        out.push("</" + list + ">");
+        list = null;
+      }
+    };
+
+    for (const line of lines) {
+      if (line.trim().startsWith("```")) {
+        if (inCode) {
+          out.push("<pre><code>" + escapeHTML(code.join("\n")) + "</code></pre>");
+          code = [];
+        } else {
+          closeList();
+        }
+        inCode = !inCode;
+        continue;
+      }
+      if (inCode) {
+        code.push(line);
+        continue;
+      }
+
+      let m;
+      if ((m = line.match(/^(#{1,6})\s+(.*)$/))) {
+        closeList();
+        const level = Math.min(m[1].length + 2, 6);
+        out.push("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">");
+      } else if ((m = line.match(/^\s*[-*]\s+(.*)$/))) {
+        if (list !== "ul") { closeList(); out.push("<ul>"); list = "ul"; }
+        out.push("<li>" + renderInline(m[1]) + "</li>");
+      } else if ((m = line.match(/^\s*\d+\.\s+(.*)$/))) {
+        if (list !== "ol") { closeList(); out.push("<ol>"); list = "ol"; }
+        out.push("<li>" + renderInline(m[1]) + "</li>");
+      } else if (line.trim() === "") {
+        closeList();
+      } else {
+        closeList();
+        out.push("<p>" + renderInline(line) + "</p>");
+      }
+    }
+    if (inCode) out.push("<pre><code>" + escapeHTML(code.join("\n")) + "</code></pre>");
+    closeList();
+    return out.join("\n");
+  }
+
+  // --- Rendering -------------------------------------------------------------
+
+  function addMessage(role, content) {
+    const el = document.createElement("div");
+    el.className = "message " + role;
+    el.innerHTML = '<div class="role">' + (role === "user" ? "You" : "btcx") + '</div><div class="body"></div>';
+    setContent(el, role, content);
+    $("messages").appendChild(el);
+    el.scrollIntoView({ block: "end" });
+    return el;
+  }
+
+  function setContent(el, role, content) {
+    const body = el.querySelector(".body");
+    if (role === "user") body.textContent = content;
+    else body.innerHTML = renderMarkdown(content);
+  }
+
+  function addSources(el, citations, collection) {
+    if (!citations || citations.length === 0) return;
+    const div = document.createElement("div");
+    div.className = "sources";
+    div.append("Sources: ");
+    citations.forEach((c, i) => {
+      if (i > 0) div.append(", ");
+      const url = collection ? fileURL(collection, c) : "";
+      if (!url) {
+        div.append(c.path);
+        return;
+      }
+      const a = document.createElement("a");
+      a.href = url;
+      a.textContent = c.path;
+      a.addEventListener("click", (e) => {
+        e.preventDefault();
+        openFile(url);
+      });
+      div.appendChild(a);
+    });
+    el.appendChild(div);
+  }
+
+  // fileURL returns the file browser link of a citation ("" outside the collection)
+  function fileURL(collection, c) {
+    if (c.path.startsWith("/") || c.path.startsWith("../")) return "";
+    let url = "collections/" + encodeURIComponent(collection) + "/files/" +
+      c.path.split("/").map(encodeURIComponent).join("/");
+    if (c.start_line) {
+      url += "?lines=" + c.start_line + "-" + Math.max(c.end_line || 0, c.start_line) + "#L" + c.start_line;
+    }
+    return url;
+  }
+
+  // openFile shows a cited file in a new tab; it is fetched here so the API
+  // key header is sent
+  async function openFile(url) {
+    const win = window.open("", "_blank");
+    const [path, anchor] = url.split("#");
+    try {
+      const resp = await authFetch(path);
+      if (!resp.ok) {
+        const data = await resp.json().catch(() => ({}));
+        throw new Error(data.error || resp.statusText);
+      }
+      const blob = new Blob([await resp.text()], { type: "text/html" });
+      win.location = URL.createObjectURL(blob) + (anchor ? "#" + anchor : "");
+    } catch (err) {
+      if (win) win.close();
+      setStatus(err.message, true);
+    }
+  }
+
+  function setStatus(text, isError) {
+    const el = $("status");
+    el.textContent = text || "";
+    el.className = isError ? "error" : "";
+  }
+
+  function formatAge(iso) {
+    const secs = (Date.now() - new Date(iso).getTime()) / 1000;
+    if (secs < 60) return "just now";
+    if (secs < 3600) return Math.floor(secs / 60) + "m ago";
+    if (secs < 86400) return Math.floor(secs / 3600) + "h ago";
+    return Math.floor(secs / 86400) + "d ago";
+  }
+
+  // --- Data loading ----------------------------------------------------------
+
+  async function loadPickers() {
+    const [resources, models] = await Promise.all([api("api/resources"), api("api/models")]);
+
+    const resSelect = $("resources");
+    resSelect.innerHTML = "";
+    resources.forEach((r, i) => {
+      const opt = new Option(r.name, r.name, false, i === 0);
+      if (r.notes) opt.title = r.notes;
+      resSelect.add(opt);
+    });
+
+    const modelSelect = $("model");
+    modelSelect.innerHTML = "";
+    models.forEach((m) => {
+      modelSelect.add(new Option(m.name + " (" + m.provider + ")", m.name, m.default, m.default));
+    });
+  }
+
+  async function loadThreads() {
+    const threads = await api("api/threads");
+    const list = $("threads");
+    list.innerHTML = "";
+    for (const t of threads) {
+      const li = document.createElement("li");
+      li.dataset.id = t.id;
+      if (t.id === state.threadId) li.className = "active";
+
+      const title = document.createElement("span");
+      title.className = "title";
+      title.textContent = t.title;
+      const meta = document.createElement("span");
+      meta.className = "meta";
+      meta.textContent = (t.resources || []).join(", ") + " · " + formatAge(t.updated);
+
+      li.append(title, meta);
+      li.addEventListener("click", () => openThread(t.id));
+      list.appendChild(li);
+    }
+  }
+
+  async function openThread(id) {
+    const thread = await api("api/threads/" + encodeURIComponent(id));
+    state.threadId = thread.id;
+    $("share-thread").disabled = false;
+    $("messages").innerHTML = "";
+    for (const msg of thread.messages) addMessage(msg.role, msg.content);
+
+    // Select the thread's resources so follow-ups search the same repos
+    const resources = new Set(thread.resources || []);
+    for (const opt of $("resources").options) opt.selected = resources.has(opt.value);
+
+    setStatus("");
+    loadThreads();
+  }
+
+  function newThread() {
+    state.threadId = null;
+    $("share-thread").disabled = true;
+    $("messages").innerHTML = "";
+    setStatus("");
+    loadThreads();
+    $("question").focus();
+  }
+
+  // shareThread creates a read-only link to the open thread and copies it
+  async function shareThread() {
+    if (!state.threadId) return;
+    try {
+      const share = await api("api/threads/" + encodeURIComponent(state.threadId) + "/share", { method: "POST" });
+      try {
+        await navigator.clipboard.writeText(share.url);
+        setStatus("Link copied: " + share.url);
+      } catch (err) {
+        setStatus("Share link: " + share.url);
+      }
+    } catch (err) {
+      setStatus(err.message, true);
+    }
+  }
+
+  // --- Asking ----------------------------------------------------------------
+
+  async function ask(event) {
+    event.preventDefault();
+    if (state.busy) return;
+
+    const question = $("question").value.trim();
+    const resources = Array.from($("resources").selectedOptions).map((o) => o.value);
+    if (!question) return;
+    if (resources.length === 0) {
+      setStatus("Select at least one resource.", true);
+      return;
+    }
+
+    state.busy = true;
+    $("send").disabled = true;
+    $("question").value = "";
+    addMessage("user", question);
+    const answerEl = addMessage("assistant", "");
+    let answer = "";
+    setStatus("Thinking...");
+
+    try {
+      await streamAsk({
+        question: question,
+        resources: resources,
+        model: $("model").value,
+        thread_id: state.threadId || undefined,
+      }, {
+        text: (data) => {
+          answer += data.delta;
+          setContent(answerEl, "assistant", answer);
+          answerEl.scrollIntoView({ block: "end" });
+        },
+        status: (data) => setStatus("Using " + data.tool + "..."),
+        done: (data) => {
+          setContent(answerEl, "assistant", data.answer);
+          addSources(answerEl, data.citations, data.collection);
+          state.threadId = data.thread_id;
+          $("share-thread").disabled = false;
+          setStatus("");
+        },
+        error: (data) => {
+          throw new Error(data.error);
+        },
+      });
+    } catch (err) {
+      setStatus(err.message, true);
+    } finally {
+      state.busy = false;
+      $("send").disabled = false;
+      loadThreads();
+    }
+  }
+
+  // --- Init ------------------------------------------------------------------
+
+  $("ask-form").addEventListener("submit", ask);
+  $("new-thread").addEventListener("click", newThread);
+  $("share-thread").addEventListener("click", shareThread);
+  $("question").addEventListener("keydown", (e) => {
+    if (e.key === "Enter" && !e.shiftKey) {
+      e.preventDefault();
+      $("ask-form").requestSubmit();
+    }
+  });
+
+  loadPickers().catch((err) => setStatus(err.message, true));
+  loadThreads().catch((err) => setStatus(err.message, true));
+})();
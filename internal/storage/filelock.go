@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// lockPollInterval is how often a busy lock is retried
+const lockPollInterval = 50 * time.Millisecond
+
+// lockTimeout is how long to wait for another process to release a lock
+const lockTimeout = 10 * time.Second
+
+// lockFile takes an exclusive advisory lock on path, shared between btcx
+// processes, waiting up to lockTimeout; call the returned function to release
+func lockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if ok {
+			return func() {
+				unlock(f)
+				f.Close()
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("timed out waiting for lock %s", path)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
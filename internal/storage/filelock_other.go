@@ -0,0 +1,13 @@
+//go:build !unix && !windows
+
+package storage
+
+import "os"
+
+// tryLock always succeeds where file locking is unsupported
+func tryLock(f *os.File) (bool, error) {
+	return true, nil
+}
+
+// unlock is a no-op where file locking is unsupported
+func unlock(f *os.File) {}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Share is a read-only link to a thread
+type Share struct {
+	// Token is the secret part of the share URL
+	Token string `json:"token"`
+
+	// ThreadID is the shared thread
+	ThreadID string `json:"threadId"`
+
+	// Namespace is the namespace the thread belongs to
+	Namespace string `json:"namespace,omitempty"`
+
+	// Created is when the link was created
+	Created time.Time `json:"created"`
+}
+
+// SharesDir returns the directory where share links are stored
+// Shares are looked up by token alone, so they aren't namespaced
+func (s *Storage) SharesDir() string {
+	return filepath.Join(s.dataDir, "shares")
+}
+
+// CreateShare creates a share link for a thread in this namespace
+func (s *Storage) CreateShare(threadID string) (*Share, error) {
+	if _, err := s.LoadThread(threadID); err != nil {
+		return nil, err
+	}
+
+	token := make([]byte, 24)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to generate share token: %w", err)
+	}
+
+	share := &Share{
+		Token:     hex.EncodeToString(token),
+		ThreadID:  threadID,
+		Namespace: s.namespace,
+		Created:   time.Now(),
+	}
+
+	if err := os.MkdirAll(s.SharesDir(), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create shares directory: %w", err)
+	}
+	data, err := json.MarshalIndent(share, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal share: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.SharesDir(), share.Token+".json"), data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write share: %w", err)
+	}
+
+	return share, nil
+}
+
+// LoadShare returns the share with the given token and its thread
+func (s *Storage) LoadShare(token string) (*Share, *Thread, error) {
+	if !validToken(token) {
+		return nil, nil, fmt.Errorf("share not found")
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.SharesDir(), token+".json"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("share not found")
+	}
+
+	var share Share
+	if err := json.Unmarshal(data, &share); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse share: %w", err)
+	}
+
+	thread, err := s.WithNamespace(share.Namespace).LoadThread(share.ThreadID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &share, thread, nil
+}
+
+// validToken reports whether a share token is well-formed (lowercase hex)
+func validToken(token string) bool {
+	if len(token) != 48 {
+		return false
+	}
+	for _, r := range token {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
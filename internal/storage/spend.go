@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// spendMu serializes spend updates within the process (e.g. concurrent
+// server requests); a lock file serializes them between processes
+var spendMu sync.Mutex
+
+// Spend is the model spend of one calendar month, summed over every thread
+// and command
+type Spend struct {
+	// Month is the month in YYYY-MM form
+	Month string `json:"month"`
+
+	// USD is the estimated cost from the models' pricing
+	USD float64 `json:"usd"`
+
+	// Requests is the number of model requests
+	Requests int `json:"requests"`
+
+	// InputTokens and OutputTokens are the tokens of all requests
+	InputTokens  int `json:"inputTokens"`
+	OutputTokens int `json:"outputTokens"`
+
+	// Days is the cost of each day of the month, keyed by day ("01"-"31")
+	Days map[string]float64 `json:"days,omitempty"`
+
+	// Models is the cost of each model config
+	Models map[string]float64 `json:"models,omitempty"`
+
+	// Updated is when a request was last added
+	Updated time.Time `json:"updated"`
+}
+
+// SpendDir returns the directory where monthly spend is stored
+// Budgets cover every user, so spend isn't namespaced
+func (s *Storage) SpendDir() string {
+	return filepath.Join(s.dataDir, "spend")
+}
+
+// LoadSpend returns the spend of the month containing t (empty if nothing
+// was spent yet)
+func (s *Storage) LoadSpend(t time.Time) (*Spend, error) {
+	month := t.Format("2006-01")
+	data, err := os.ReadFile(filepath.Join(s.SpendDir(), month+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Spend{Month: month}, nil
+		}
+		return nil, fmt.Errorf("failed to read spend: %w", err)
+	}
+
+	var spend Spend
+	if err := json.Unmarshal(data, &spend); err != nil {
+		return nil, fmt.Errorf("failed to parse spend: %w", err)
+	}
+	spend.Month = month
+	return &spend, nil
+}
+
+// AddSpend adds a model request made at t to its month's spend and returns
+// the updated spend
+func (s *Storage) AddSpend(t time.Time, model string, inputTokens, outputTokens int, usd float64) (*Spend, error) {
+	spendMu.Lock()
+	defer spendMu.Unlock()
+
+	if err := os.MkdirAll(s.SpendDir(), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spend directory: %w", err)
+	}
+	release, err := lockFile(filepath.Join(s.SpendDir(), ".lock"))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	spend, err := s.LoadSpend(t)
+	if err != nil {
+		return nil, err
+	}
+	if spend.Days == nil {
+		spend.Days = make(map[string]float64)
+	}
+	if spend.Models == nil {
+		spend.Models = make(map[string]float64)
+	}
+	spend.USD += usd
+	spend.Requests++
+	spend.InputTokens += inputTokens
+	spend.OutputTokens += outputTokens
+	spend.Days[t.Format("02")] += usd
+	spend.Models[model] += usd
+	spend.Updated = t
+
+	data, err := json.MarshalIndent(spend, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal spend: %w", err)
+	}
+
+	// Write atomically, so other processes never read half a file
+	tmp, err := os.CreateTemp(s.SpendDir(), spend.Month+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write spend: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to write spend: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to write spend: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.SpendDir(), spend.Month+".json")); err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to write spend: %w", err)
+	}
+	return spend, nil
+}
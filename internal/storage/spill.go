@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nickcecere/btcx/internal/provider"
+)
+
+const (
+	// DefaultSpillBytes is the message size above which a message's body is
+	// stored in its own file instead of the thread file
+	DefaultSpillBytes = 32 * 1024
+
+	// spillPreviewBytes is how much of a spilled body's text stays in the
+	// thread file
+	spillPreviewBytes = 1024
+)
+
+// Spill points to the body of a message stored outside the thread file
+// Messages of threads loaded with ListThreads keep it, along with previews
+// of their content and tool outputs; LoadThread reads the bodies back
+type Spill struct {
+	// File is the body's file name in the thread's spill directory
+	File string `json:"file"`
+
+	// Bytes is the size of the full body
+	Bytes int `json:"bytes"`
+}
+
+// messageBody is the part of a message that is spilled
+type messageBody struct {
+	Content     string       `json:"content"`
+	Reasoning   string       `json:"reasoning,omitempty"`
+	ToolResults []ToolResult `json:"toolResults,omitempty"`
+}
+
+// WithSpillBytes returns a storage that spills messages larger than n bytes
+// (0 for DefaultSpillBytes, negative to never spill)
+func (s *Storage) WithSpillBytes(n int) *Storage {
+	copied := *s
+	copied.spillBytes = n
+	return &copied
+}
+
+// spillLimit returns the size above which messages are spilled (0 for never)
+func (s *Storage) spillLimit() int {
+	switch {
+	case s.spillBytes < 0:
+		return 0
+	case s.spillBytes == 0:
+		return DefaultSpillBytes
+	}
+	return s.spillBytes
+}
+
+// spillDir returns the directory with the spilled message bodies of a thread
+func (s *Storage) spillDir(threadID string) string {
+	return filepath.Join(s.ThreadsDir(), threadID)
+}
+
+// bodySize returns the size of a message's body
+func bodySize(msg *Message) int {
+	size := len(msg.Content) + len(msg.Reasoning)
+	for _, r := range msg.ToolResults {
+		size += len(r.Output)
+	}
+	return size
+}
+
+// spillMessages returns the messages as they are written to the thread file:
+// images are written to the spill directory, and so are bodies over the
+// limit, which are replaced by a pointer and previews
+// Files of bodies and images no longer in the thread are removed
+func (s *Storage) spillMessages(thread *Thread) ([]Message, error) {
+	limit := s.spillLimit()
+	dir := s.spillDir(thread.ID)
+	keep := make(map[string]bool)
+
+	// The caller keeps using the thread, so messages are changed as copies
+	messages := make([]Message, len(thread.Messages))
+	for i, msg := range thread.Messages {
+		msg, err := spillImages(dir, msg, keep)
+		if err != nil {
+			return nil, err
+		}
+		messages[i] = msg
+		if msg.Spill != nil {
+			// Not read back (ListThreads), so the file still has the body
+			keep[msg.Spill.File] = true
+			continue
+		}
+		size := bodySize(&msg)
+		if limit == 0 || size <= limit {
+			continue
+		}
+
+		data, err := json.Marshal(messageBody{Content: msg.Content, Reasoning: msg.Reasoning, ToolResults: msg.ToolResults})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal message: %w", err)
+		}
+		sum := sha256.Sum256(data)
+		name := hex.EncodeToString(sum[:8]) + ".json"
+		keep[name] = true
+		if err := writeSpilled(dir, name, data); err != nil {
+			return nil, err
+		}
+		messages[i] = spilledMessage(msg, name, size)
+	}
+
+	removeStaleBodies(dir, keep)
+	return messages, nil
+}
+
+// spillImages returns msg with the data of its tool results' images written
+// to files in dir; the thread file keeps their media types and file names
+func spillImages(dir string, msg Message, keep map[string]bool) (Message, error) {
+	copied := false
+	for i, r := range msg.ToolResults {
+		if r.Image == nil {
+			continue
+		}
+		if len(r.Image.Data) > 0 {
+			name := imageFile(r.Image)
+			if err := writeSpilled(dir, name, r.Image.Data); err != nil {
+				return msg, err
+			}
+			if !copied {
+				msg.ToolResults = append([]ToolResult(nil), msg.ToolResults...)
+				copied = true
+			}
+			msg.ToolResults[i].Image = &provider.Image{MediaType: r.Image.MediaType}
+			msg.ToolResults[i].ImageFile = name
+		}
+		if name := msg.ToolResults[i].ImageFile; name != "" {
+			keep[name] = true
+		}
+	}
+	return msg, nil
+}
+
+// imageFile returns the file name of an image: a hash of its data, with its
+// media type's subtype as the extension, e.g. 1a2b3c4d5e6f7a8b.png
+func imageFile(img *provider.Image) string {
+	sum := sha256.Sum256(img.Data)
+	ext := strings.TrimPrefix(img.MediaType, "image/")
+	if ext == "" || strings.ContainsAny(ext, `/\.+`) {
+		ext = "img"
+	}
+	return hex.EncodeToString(sum[:8]) + "." + ext
+}
+
+// writeSpilled writes a file to the spill directory dir, unless it's there
+// already: files are named by their content's hash
+func writeSpilled(dir, name string, data []byte) error {
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// spilledMessage returns msg with previews instead of its body
+func spilledMessage(msg Message, file string, size int) Message {
+	msg.Content = preview(msg.Content)
+	msg.Reasoning = preview(msg.Reasoning)
+	results := make([]ToolResult, len(msg.ToolResults))
+	for i, r := range msg.ToolResults {
+		r.Output = preview(r.Output)
+		results[i] = r
+	}
+	if len(results) > 0 {
+		msg.ToolResults = results
+	}
+	msg.Spill = &Spill{File: file, Bytes: size}
+	return msg
+}
+
+// preview returns the start of text, cut at a line break where possible
+func preview(text string) string {
+	if len(text) <= spillPreviewBytes {
+		return text
+	}
+	cut := spillPreviewBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	if i := strings.LastIndexByte(text[:cut], '\n'); i > cut/2 {
+		cut = i
+	}
+	return text[:cut] + "\n..."
+}
+
+// removeStaleBodies deletes the files in dir that aren't in keep
+func removeStaleBodies(dir string, keep map[string]bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !keep[e.Name()] {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+	if len(keep) == 0 {
+		os.Remove(dir)
+	}
+}
+
+// readFull reads the spilled bodies and the images of a thread back
+func (s *Storage) readFull(thread *Thread) {
+	s.readSpilled(thread)
+	s.readImages(thread)
+}
+
+// readSpilled replaces the previews of spilled messages with their bodies
+// Messages whose body file is missing or damaged keep their previews, so
+// the thread still loads
+func (s *Storage) readSpilled(thread *Thread) {
+	for i := range thread.Messages {
+		msg := &thread.Messages[i]
+		if msg.Spill == nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.spillDir(thread.ID), filepath.Base(msg.Spill.File)))
+		if err != nil {
+			continue
+		}
+		var body messageBody
+		if err := json.Unmarshal(data, &body); err != nil {
+			continue
+		}
+		msg.Content = body.Content
+		msg.Reasoning = body.Reasoning
+		msg.ToolResults = body.ToolResults
+		msg.Spill = nil
+	}
+}
+
+// readImages reads the data of the tool results' images back from their
+// files; images whose file is missing are left without data, and aren't
+// sent to models
+func (s *Storage) readImages(thread *Thread) {
+	for i := range thread.Messages {
+		for j := range thread.Messages[i].ToolResults {
+			r := &thread.Messages[i].ToolResults[j]
+			if r.Image == nil || r.ImageFile == "" || len(r.Image.Data) > 0 {
+				continue
+			}
+			if data, err := os.ReadFile(filepath.Join(s.spillDir(thread.ID), filepath.Base(r.ImageFile))); err == nil {
+				r.Image.Data = data
+			}
+		}
+	}
+}
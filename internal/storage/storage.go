@@ -6,12 +6,20 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
+
+	"github.com/nickcecere/btcx/internal/provider"
 )
 
 // Storage handles persistent data storage
 type Storage struct {
-	dataDir string
+	dataDir   string
+	namespace string
+
+	// spillBytes is the message size above which bodies are stored in
+	// their own files (see WithSpillBytes)
+	spillBytes int
 }
 
 // NewStorage creates a new storage instance
@@ -19,11 +27,48 @@ func NewStorage(dataDir string) *Storage {
 	return &Storage{dataDir: dataDir}
 }
 
+// WithNamespace returns a storage whose threads are kept separate from
+// other namespaces (e.g. one per server user)
+// An empty namespace is the default, shared thread directory
+func (s *Storage) WithNamespace(namespace string) *Storage {
+	return &Storage{dataDir: s.dataDir, namespace: sanitizeNamespace(namespace), spillBytes: s.spillBytes}
+}
+
+// Namespace returns the thread namespace ("" for the default namespace)
+func (s *Storage) Namespace() string {
+	return s.namespace
+}
+
 // ThreadsDir returns the directory where threads are stored
 func (s *Storage) ThreadsDir() string {
+	if s.namespace != "" {
+		return filepath.Join(s.dataDir, "threads", "users", s.namespace)
+	}
 	return filepath.Join(s.dataDir, "threads")
 }
 
+// validID reports whether a thread ID is safe to use as a file name
+func validID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, `/\`) && !strings.Contains(id, "..")
+}
+
+// sanitizeNamespace makes a namespace safe to use as a directory name
+// Letters, digits, '-' and '.' (except a leading one) are kept and every other
+// byte is written as _XX, so distinct namespaces never share a directory
+func sanitizeNamespace(namespace string) string {
+	var sb strings.Builder
+	for i := 0; i < len(namespace); i++ {
+		c := namespace[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '.' && i > 0:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "_%02x", c)
+		}
+	}
+	return sb.String()
+}
+
 // EnsureDirs creates all required directories
 func (s *Storage) EnsureDirs() error {
 	dirs := []string{
@@ -65,6 +110,68 @@ type Thread struct {
 
 	// Messages are the conversation messages
 	Messages []Message `json:"messages"`
+
+	// Digest is a short summary of the thread (btcx threads summarize)
+	Digest *Digest `json:"digest,omitempty"`
+
+	// Notes are the model's working notes, written with the note tool
+	Notes []string `json:"notes,omitempty"`
+
+	// Snapshots are the versions of the resources when the thread was
+	// created, to interpret old answers against the docs they came from
+	Snapshots []ResourceSnapshot `json:"snapshots,omitempty"`
+}
+
+// ResourceSnapshot is the version of a resource when a thread was created
+type ResourceSnapshot struct {
+	// Name is the resource name
+	Name string `json:"name"`
+
+	// Commit is the git commit checked out ("" outside git)
+	Commit string `json:"commit,omitempty"`
+
+	// Modified is the latest file modification time, for resources outside git
+	Modified *time.Time `json:"modified,omitempty"`
+}
+
+// Digest summarizes a thread
+type Digest struct {
+	// Summary is a one-line description, shown in thread lists
+	Summary string `json:"summary"`
+
+	// Findings are the key things learned
+	Findings []string `json:"findings,omitempty"`
+
+	// Files are the files cited in the thread
+	Files []string `json:"files,omitempty"`
+
+	// OpenQuestions are questions the thread left unanswered
+	OpenQuestions []string `json:"openQuestions,omitempty"`
+
+	// Model is the model config name that wrote the digest
+	Model string `json:"model"`
+
+	// Messages is the number of messages summarized; the digest is out of date
+	// once the thread has more
+	Messages int `json:"messages"`
+
+	// Created is when the digest was written
+	Created time.Time `json:"created"`
+}
+
+// DigestCurrent reports whether the digest covers all of the thread's messages
+func (t *Thread) DigestCurrent() bool {
+	return t.Digest != nil && t.Digest.Messages == len(t.Messages)
+}
+
+// LastQuestion returns the most recent user question ("" if there is none)
+func (t *Thread) LastQuestion() string {
+	for i := len(t.Messages) - 1; i >= 0; i-- {
+		if t.Messages[i].Role == "user" {
+			return t.Messages[i].Content
+		}
+	}
+	return ""
 }
 
 // Message represents a single message in a conversation
@@ -75,6 +182,14 @@ type Message struct {
 	// Content is the message text content
 	Content string `json:"content"`
 
+	// Reasoning is the thinking of a reasoning model behind an assistant
+	// message, kept apart from its content
+	Reasoning string `json:"reasoning,omitempty"`
+
+	// ReasoningSignature verifies Reasoning (Anthropic extended thinking), so
+	// it can be sent back when the thread continues
+	ReasoningSignature string `json:"reasoningSignature,omitempty"`
+
 	// ToolCalls are any tool calls made by the assistant
 	ToolCalls []ToolCall `json:"toolCalls,omitempty"`
 
@@ -84,10 +199,98 @@ type Message struct {
 	// ToolCallID is the ID of the tool call this message is responding to (for tool role)
 	ToolCallID string `json:"toolCallId,omitempty"`
 
+	// Verification is the judge's assessment of an assistant answer
+	Verification *Verification `json:"verification,omitempty"`
+
+	// Breakdown attributes the tokens spent on an assistant answer
+	Breakdown *UsageBreakdown `json:"breakdown,omitempty"`
+
+	// Model is the model config name that wrote an assistant message
+	Model string `json:"model,omitempty"`
+
+	// Retry marks a user message that asks the previous question again; the
+	// earlier attempt stays in the thread but is hidden from the model
+	Retry bool `json:"retry,omitempty"`
+
+	// Category is the kind of question a user message asks (how-to, api,
+	// debugging, comparison, other), when classification is enabled
+	Category string `json:"category,omitempty"`
+
+	// Spill points to the message body when it is too large for the thread
+	// file, which then only has previews
+	Spill *Spill `json:"spill,omitempty"`
+
 	// Timestamp is when the message was created
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// Verification is a judge model's assessment of an answer
+type Verification struct {
+	// Model is the judge model config name
+	Model string `json:"model"`
+
+	// Correctness scores how correct the answer is (1-5)
+	Correctness int `json:"correctness"`
+
+	// Grounding scores how well the evidence backs the answer (1-5)
+	Grounding int `json:"grounding"`
+
+	// Confident is set when both scores reach the configured minimum
+	Confident bool `json:"confident"`
+
+	// Caveats are the judge's concerns about the answer
+	Caveats []string `json:"caveats,omitempty"`
+}
+
+// UsageBreakdown attributes the tokens spent answering a question to the
+// tools whose outputs were sent and to each model request
+type UsageBreakdown struct {
+	// InputTokens is the prompt tokens of all requests
+	InputTokens int `json:"inputTokens"`
+
+	// OutputTokens is the completion tokens of all requests
+	OutputTokens int `json:"outputTokens"`
+
+	// ToolTokens maps tool names to the prompt tokens their outputs took up,
+	// counted on every request that sent them again (estimated)
+	ToolTokens map[string]int `json:"toolTokens,omitempty"`
+
+	// Iterations are the usage of each model request, in order
+	Iterations []IterationUsage `json:"iterations,omitempty"`
+}
+
+// IterationUsage is the token usage of one model request
+type IterationUsage struct {
+	// InputTokens is the request's prompt tokens
+	InputTokens int `json:"inputTokens"`
+
+	// OutputTokens is the response's completion tokens
+	OutputTokens int `json:"outputTokens"`
+
+	// ToolTokens is the part of InputTokens taken up by tool outputs (estimated)
+	ToolTokens int `json:"toolTokens"`
+
+	// ToolCalls names the tools the response called
+	ToolCalls []string `json:"toolCalls,omitempty"`
+}
+
+// Add adds another breakdown's usage to this one; its iterations follow
+// this one's
+func (b *UsageBreakdown) Add(other *UsageBreakdown) {
+	if other == nil {
+		return
+	}
+	b.InputTokens += other.InputTokens
+	b.OutputTokens += other.OutputTokens
+	for name, tokens := range other.ToolTokens {
+		if b.ToolTokens == nil {
+			b.ToolTokens = make(map[string]int)
+		}
+		b.ToolTokens[name] += tokens
+	}
+	b.Iterations = append(b.Iterations, other.Iterations...)
+}
+
 // ToolCall represents a tool invocation
 type ToolCall struct {
 	// ID is the unique identifier for this tool call
@@ -98,6 +301,13 @@ type ToolCall struct {
 
 	// Arguments is the JSON arguments passed to the tool
 	Arguments json.RawMessage `json:"arguments"`
+
+	// Evidence is the reference ID of the call's saved full output, e.g.
+	// "read-1a2b3c4d" (only set when the output was truncated to a file)
+	Evidence string `json:"evidence,omitempty"`
+
+	// Failed is set when the tool returned an error
+	Failed bool `json:"failed,omitempty"`
 }
 
 // ToolResult represents the result of a tool call
@@ -110,6 +320,38 @@ type ToolResult struct {
 
 	// Error is any error that occurred
 	Error string `json:"error,omitempty"`
+
+	// Image is an image the tool returned (read of a PNG, JPEG, ...)
+	// Its data is stored in ImageFile, not in the thread file
+	Image *provider.Image `json:"image,omitempty"`
+
+	// ImageFile is the file with Image's data in the thread's spill
+	// directory
+	ImageFile string `json:"imageFile,omitempty"`
+
+	// OutputFile is where the full output was saved when Output was truncated
+	OutputFile string `json:"outputFile,omitempty"`
+
+	// OutputBytes is the size of the full output, before truncation
+	OutputBytes int `json:"outputBytes,omitempty"`
+
+	// Truncated is set when the model only got part of the output
+	Truncated bool `json:"truncated,omitempty"`
+
+	// DurationMs is how long the tool ran
+	DurationMs int64 `json:"durationMs,omitempty"`
+}
+
+// Failed reports whether the tool returned an error
+// Tool errors are sent to the model as output starting with "Error: "
+func (r ToolResult) Failed() bool {
+	return r.Error != "" || strings.HasPrefix(r.Output, "Error: ")
+}
+
+// EvidenceID returns the reference ID of a saved tool output file, e.g.
+// ".../outputs/<thread>/read-1a2b3c4d.txt" -> "read-1a2b3c4d"
+func EvidenceID(outputFile string) string {
+	return strings.TrimSuffix(filepath.Base(outputFile), filepath.Ext(outputFile))
 }
 
 // SaveThread saves a thread to disk
@@ -120,7 +362,15 @@ func (s *Storage) SaveThread(thread *Thread) error {
 
 	thread.Updated = time.Now()
 
-	data, err := json.MarshalIndent(thread, "", "  ")
+	// Large message bodies go to their own files
+	messages, err := s.spillMessages(thread)
+	if err != nil {
+		return err
+	}
+	stored := *thread
+	stored.Messages = messages
+
+	data, err := json.MarshalIndent(&stored, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal thread: %w", err)
 	}
@@ -133,8 +383,22 @@ func (s *Storage) SaveThread(thread *Thread) error {
 	return nil
 }
 
-// LoadThread loads a thread from disk
+// LoadThread loads a thread from disk, with the bodies of spilled messages
 func (s *Storage) LoadThread(id string) (*Thread, error) {
+	thread, err := s.loadThread(id)
+	if err != nil {
+		return nil, err
+	}
+	s.readFull(thread)
+	return thread, nil
+}
+
+// loadThread loads a thread file; spilled messages only have previews
+func (s *Storage) loadThread(id string) (*Thread, error) {
+	if !validID(id) {
+		return nil, fmt.Errorf("thread %q not found", id)
+	}
+
 	path := filepath.Join(s.ThreadsDir(), id+".json")
 
 	data, err := os.ReadFile(path)
@@ -155,6 +419,10 @@ func (s *Storage) LoadThread(id string) (*Thread, error) {
 
 // DeleteThread deletes a thread from disk
 func (s *Storage) DeleteThread(id string) error {
+	if !validID(id) {
+		return fmt.Errorf("thread %q not found", id)
+	}
+
 	path := filepath.Join(s.ThreadsDir(), id+".json")
 
 	if err := os.Remove(path); err != nil {
@@ -163,11 +431,13 @@ func (s *Storage) DeleteThread(id string) error {
 		}
 		return fmt.Errorf("failed to delete thread: %w", err)
 	}
+	os.RemoveAll(s.spillDir(id))
 
 	return nil
 }
 
 // ListThreads returns all threads, sorted by update time (newest first)
+// Spilled messages aren't read back, so they only have previews (see Spill)
 func (s *Storage) ListThreads() ([]*Thread, error) {
 	entries, err := os.ReadDir(s.ThreadsDir())
 	if err != nil {
@@ -184,7 +454,7 @@ func (s *Storage) ListThreads() ([]*Thread, error) {
 		}
 
 		id := entry.Name()[:len(entry.Name())-5] // Remove .json extension
-		thread, err := s.LoadThread(id)
+		thread, err := s.loadThread(id)
 		if err != nil {
 			continue // Skip invalid threads
 		}
@@ -210,13 +480,96 @@ func (s *Storage) GetLatestThread() (*Thread, error) {
 		return nil, fmt.Errorf("no threads found")
 	}
 
+	s.readFull(threads[0])
 	return threads[0], nil
 }
 
-// ClearThreads deletes all threads
+// FindAnswer returns the most recent answer to question over the same
+// resources, and the thread it is in
+// Questions match ignoring case and whitespace; the answer is the last
+// assistant message before the next question. It returns nil if the
+// question hasn't been asked before
+func (s *Storage) FindAnswer(question string, resources []string) (*Thread, *Message, error) {
+	threads, err := s.ListThreads()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	want := normalizeQuestion(question)
+	var bestThread *Thread
+	var best *Message
+	for _, t := range threads {
+		if !sameResources(t.Resources, resources) {
+			continue
+		}
+		for i, msg := range t.Messages {
+			if msg.Role != "user" || normalizeQuestion(msg.Content) != want {
+				continue
+			}
+			var answer *Message
+			for j := i + 1; j < len(t.Messages) && t.Messages[j].Role != "user"; j++ {
+				if t.Messages[j].Role == "assistant" && t.Messages[j].Content != "" {
+					answer = &t.Messages[j]
+				}
+			}
+			if answer != nil && (best == nil || answer.Timestamp.After(best.Timestamp)) {
+				bestThread, best = t, answer
+			}
+		}
+	}
+	if bestThread != nil {
+		s.readFull(bestThread)
+	}
+	return bestThread, best, nil
+}
+
+// normalizeQuestion lowercases a question and collapses its whitespace
+func normalizeQuestion(q string) string {
+	return strings.Join(strings.Fields(strings.ToLower(q)), " ")
+}
+
+// sameResources reports whether two resource lists name the same resources
+func sameResources(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	x := append([]string(nil), a...)
+	y := append([]string(nil), b...)
+	sort.Strings(x)
+	sort.Strings(y)
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ClearThreads deletes all threads of the namespace
+// The default namespace's directory holds the other namespaces, which are
+// left alone
 func (s *Storage) ClearThreads() error {
-	if err := os.RemoveAll(s.ThreadsDir()); err != nil {
+	if s.namespace != "" {
+		if err := os.RemoveAll(s.ThreadsDir()); err != nil {
+			return fmt.Errorf("failed to clear threads: %w", err)
+		}
+		return nil
+	}
+
+	entries, err := os.ReadDir(s.ThreadsDir())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
 		return fmt.Errorf("failed to clear threads: %w", err)
 	}
+	for _, e := range entries {
+		if e.Name() == "users" && e.IsDir() {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(s.ThreadsDir(), e.Name())); err != nil {
+			return fmt.Errorf("failed to clear threads: %w", err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,121 @@
+// Package summary stores generated architecture overviews of resources.
+//
+// Each overview is a markdown file with a small YAML front matter recording
+// the resource version it was generated from, so it can be reused as context
+// for later questions and regenerated once the resource changes.
+package summary
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatter delimits the metadata at the top of a summary file
+const frontMatter = "---\n"
+
+// Summary is a generated overview of one resource
+type Summary struct {
+	// Resource is the resource name
+	Resource string `yaml:"resource"`
+
+	// Version is the resource version (git commit or manifest digest) the
+	// summary was generated from; "" if unknown
+	Version string `yaml:"version,omitempty"`
+
+	// Model is the model name that wrote the summary
+	Model string `yaml:"model"`
+
+	// Created is when the summary was generated
+	Created time.Time `yaml:"created"`
+
+	// Content is the markdown overview
+	Content string `yaml:"-"`
+}
+
+// Current reports whether the summary was generated from version
+// Summaries of resources without a known version are always current
+func (s *Summary) Current(version string) bool {
+	return s.Version == "" || version == "" || s.Version == version
+}
+
+// Store keeps summaries on disk, one markdown file per resource
+type Store struct {
+	dir string
+}
+
+// New creates a store in dir
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Path returns the file a resource's summary is stored in
+func (s *Store) Path(resource string) string {
+	return filepath.Join(s.dir, resource+".md")
+}
+
+// Load returns the stored summary of a resource, or nil if there is none
+func (s *Store) Load(resource string) (*Summary, error) {
+	data, err := os.ReadFile(s.Path(resource))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read summary: %w", err)
+	}
+
+	summary := &Summary{Resource: resource}
+	content := string(data)
+	if rest, ok := strings.CutPrefix(content, frontMatter); ok {
+		if meta, body, ok := strings.Cut(rest, "\n"+frontMatter); ok {
+			if err := yaml.Unmarshal([]byte(meta), summary); err != nil {
+				return nil, fmt.Errorf("failed to parse summary %s: %w", s.Path(resource), err)
+			}
+			content = body
+		}
+	}
+	summary.Content = strings.TrimSpace(content)
+	return summary, nil
+}
+
+// Save writes a summary, replacing any previous one
+func (s *Store) Save(summary *Summary) error {
+	meta, err := yaml.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to encode summary: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(frontMatter)
+	buf.Write(meta)
+	buf.WriteString(frontMatter)
+	buf.WriteString("\n")
+	buf.WriteString(strings.TrimSpace(summary.Content))
+	buf.WriteString("\n")
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create summaries directory: %w", err)
+	}
+	tmp := s.Path(summary.Resource) + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	if err := os.Rename(tmp, s.Path(summary.Resource)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the summary of a resource, if any
+func (s *Store) Remove(resource string) error {
+	if err := os.Remove(s.Path(resource)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove summary: %w", err)
+	}
+	return nil
+}
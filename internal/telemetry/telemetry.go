@@ -0,0 +1,196 @@
+// Package telemetry reports anonymous feature usage when the user opts in.
+//
+// Events name the command, the flags that were set (never their values) and
+// the class of any error. Questions, answers, resource names, paths and
+// error messages are never sent.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultEndpoint receives events when telemetry.endpoint isn't configured
+// Release builds set it with -ldflags "-X .../internal/telemetry.DefaultEndpoint=..."
+var DefaultEndpoint = ""
+
+// Timeout bounds sending an event, so reporting never holds up the CLI for long
+const Timeout = 2 * time.Second
+
+// stateFile is the opt-in state in the data directory
+const stateFile = "telemetry.json"
+
+// State is the user's telemetry choice
+type State struct {
+	// Enabled is set by `btcx telemetry on`
+	Enabled bool `json:"enabled"`
+
+	// ID is a random install ID, replaced each time telemetry is turned on
+	ID string `json:"id,omitempty"`
+
+	// Updated is when the choice was made
+	Updated time.Time `json:"updated"`
+}
+
+// Event is the payload sent for each command run
+type Event struct {
+	ID         string   `json:"id"`
+	Version    string   `json:"version"`
+	OS         string   `json:"os"`
+	Arch       string   `json:"arch"`
+	Command    string   `json:"command"`
+	Flags      []string `json:"flags,omitempty"`
+	DurationMS int64    `json:"duration_ms"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Disabled reports whether telemetry is turned off by the environment
+// (DO_NOT_TRACK=1 or BTCX_TELEMETRY=off), regardless of the saved choice
+func Disabled() bool {
+	if v := os.Getenv("DO_NOT_TRACK"); v != "" && v != "0" {
+		return true
+	}
+	switch strings.ToLower(os.Getenv("BTCX_TELEMETRY")) {
+	case "0", "off", "false":
+		return true
+	}
+	return false
+}
+
+// Load reads the telemetry state from the data directory
+// Telemetry is off unless it was turned on
+func Load(dataDir string) (*State, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, stateFile))
+	if os.IsNotExist(err) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read telemetry state: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse telemetry state: %w", err)
+	}
+	return &state, nil
+}
+
+// Save turns telemetry on or off
+// Turning it on picks a new install ID; turning it off forgets the ID
+func Save(dataDir string, enabled bool) (*State, error) {
+	state := &State{Enabled: enabled, Updated: time.Now()}
+	if enabled {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return nil, fmt.Errorf("failed to generate telemetry ID: %w", err)
+		}
+		state.ID = hex.EncodeToString(id)
+	}
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal telemetry state: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, stateFile), data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write telemetry state: %w", err)
+	}
+	return state, nil
+}
+
+// NewEvent describes a finished command
+// flags are the names of the flags that were set; err is classified, not sent
+func NewEvent(id, version, command string, flags []string, duration time.Duration, err error) *Event {
+	sort.Strings(flags)
+	return &Event{
+		ID:         id,
+		Version:    version,
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		Command:    command,
+		Flags:      flags,
+		DurationMS: duration.Milliseconds(),
+		Error:      ErrorClass(err),
+	}
+}
+
+// ErrorClass reduces an error to a coarse class such as "network" or
+// "timeout", so no message text leaves the machine
+func ErrorClass(err error) string {
+	var netErr net.Error
+	var pathErr *fs.PathError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	case errors.As(err, &pathErr):
+		return "filesystem"
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "unknown command"), strings.HasPrefix(msg, "unknown flag"),
+		strings.HasPrefix(msg, "unknown shorthand flag"), strings.Contains(msg, "required flag"):
+		return "usage"
+	case strings.Contains(msg, "config"):
+		return "config"
+	case strings.Contains(msg, "model") || strings.Contains(msg, "provider") || strings.Contains(msg, "API"):
+		return "provider"
+	case strings.Contains(msg, "resource") || strings.Contains(msg, "clone") || strings.Contains(msg, "fetch"):
+		return "resource"
+	}
+	return "other"
+}
+
+// Send posts an event to the endpoint
+func Send(ctx context.Context, endpoint string, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telemetry event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create telemetry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "btcx")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send telemetry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultCustomTimeout bounds custom tool commands without a timeout
+const DefaultCustomTimeout = 30 * time.Second
+
+// maxCustomOutput caps the output read from a custom tool command
+// Larger outputs are cut here and then truncated like any other tool output
+const maxCustomOutput = 10 << 20
+
+// CustomTool runs a user-defined command
+// The command runs without a shell in the working directory with a minimal
+// environment, a timeout, and arguments validated against its schema
+type CustomTool struct {
+	name        string
+	description string
+	command     string
+	args        []argTemplate
+	allowFlags  bool
+	schema      map[string]interface{}
+	env         map[string]string
+	timeout     time.Duration
+	workingDir  string
+}
+
+// NewCustomTool creates a tool that runs command with args in workingDir
+// A nil schema means the tool takes no arguments. Unless allowFlags is set,
+// values starting with "-" can't be substituted at the start of an arg
+func NewCustomTool(workingDir, name, description, command string, args []string, allowFlags bool, schema map[string]interface{}, env map[string]string, timeout time.Duration) *CustomTool {
+	if schema == nil {
+		schema = map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		}
+	}
+	if timeout <= 0 {
+		timeout = DefaultCustomTimeout
+	}
+
+	templates := make([]argTemplate, len(args))
+	for i, arg := range args {
+		templates[i] = parseArgTemplate(arg)
+	}
+
+	return &CustomTool{
+		name:        name,
+		description: description,
+		command:     command,
+		args:        templates,
+		allowFlags:  allowFlags,
+		schema:      schema,
+		env:         env,
+		timeout:     timeout,
+		workingDir:  workingDir,
+	}
+}
+
+// Name returns the tool name
+func (t *CustomTool) Name() string {
+	return t.name
+}
+
+// Description returns the tool description
+func (t *CustomTool) Description() string {
+	return t.description
+}
+
+// Parameters returns the JSON schema for the tool parameters
+func (t *CustomTool) Parameters() map[string]interface{} {
+	return t.schema
+}
+
+// Execute runs the command
+func (t *CustomTool) Execute(ctx context.Context, args json.RawMessage) (*Result, error) {
+	if len(bytes.TrimSpace(args)) == 0 {
+		args = json.RawMessage("{}")
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(args, &values); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+	if err := validateArgs(t.schema, values); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	cmdArgs, err := t.expandArgs(values)
+	if err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, t.command, cmdArgs...)
+	cmd.Dir = t.workingDir
+	cmd.Env = t.environ(args)
+	cmd.Stdin = bytes.NewReader(args)
+	// Don't wait on children that outlive a killed command
+	cmd.WaitDelay = time.Second
+
+	var stdout, stderr limitedBuffer
+	stdout.max, stderr.max = maxCustomOutput, 64*1024
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%s timed out after %s", t.name, t.timeout)
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			msg := strings.TrimSpace(stderr.String())
+			if msg == "" {
+				msg = strings.TrimSpace(stdout.String())
+			}
+			return nil, fmt.Errorf("%s failed (exit code %d): %s", t.name, exitErr.ExitCode(), msg)
+		}
+		return nil, fmt.Errorf("failed to run %s: %w", t.name, err)
+	}
+
+	output := stdout.String()
+	if strings.TrimSpace(output) == "" {
+		output = "No output"
+	}
+
+	return &Result{
+		Title:  t.name,
+		Output: output,
+		Metadata: map[string]interface{}{
+			"truncated": stdout.truncated,
+		},
+	}, nil
+}
+
+// environ builds the minimal environment for the command
+func (t *CustomTool) environ(args json.RawMessage) []string {
+	var env []string
+	keep := []string{"PATH", "HOME", "LANG", "TMPDIR"}
+	if runtime.GOOS == "windows" {
+		keep = append(keep, "SYSTEMROOT", "TEMP", "TMP", "PATHEXT", "USERPROFILE")
+	}
+	for _, key := range keep {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+
+	env = append(env,
+		"BTCX_TOOL="+t.name,
+		"BTCX_COLLECTION="+t.workingDir,
+		"BTCX_ARGS="+string(args),
+	)
+	for key, value := range t.env {
+		env = append(env, key+"="+value)
+	}
+	return env
+}
+
+// argTemplate is an arg split into literal text and "{name}" placeholders
+type argTemplate struct {
+	parts []argPart
+}
+
+// argPart is literal text, or a placeholder when name is set
+type argPart struct {
+	text string
+	name string
+}
+
+// parseArgTemplate splits an arg at its "{name}" placeholders
+// Braces around anything but an argument name are kept as text
+func parseArgTemplate(arg string) argTemplate {
+	var tmpl argTemplate
+	var text strings.Builder
+	for {
+		start := strings.IndexByte(arg, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(arg[start:], '}')
+		if end < 0 || !validPlaceholder(arg[start+1:start+end]) {
+			text.WriteString(arg[:start+1])
+			arg = arg[start+1:]
+			continue
+		}
+		text.WriteString(arg[:start])
+		if text.Len() > 0 {
+			tmpl.parts = append(tmpl.parts, argPart{text: text.String()})
+			text.Reset()
+		}
+		tmpl.parts = append(tmpl.parts, argPart{name: arg[start+1 : start+end]})
+		arg = arg[start+end+1:]
+	}
+	text.WriteString(arg)
+	if text.Len() > 0 {
+		tmpl.parts = append(tmpl.parts, argPart{text: text.String()})
+	}
+	return tmpl
+}
+
+// expandArgs replaces placeholders with argument values in one pass, so
+// values are never themselves expanded
+// Missing arguments expand to an empty string, and args that expand to
+// nothing are dropped
+func (t *CustomTool) expandArgs(values map[string]interface{}) ([]string, error) {
+	expanded := make([]string, 0, len(t.args))
+	for _, tmpl := range t.args {
+		var b strings.Builder
+		for _, part := range tmpl.parts {
+			if part.name == "" {
+				b.WriteString(part.text)
+				continue
+			}
+			value := formatArg(values[part.name])
+			// A value opening the arg could be taken as an option
+			if b.Len() == 0 && strings.HasPrefix(value, "-") && !t.allowFlags {
+				return nil, fmt.Errorf("%s must not start with \"-\"", part.name)
+			}
+			b.WriteString(value)
+		}
+		if b.Len() == 0 && len(tmpl.parts) > 0 {
+			continue
+		}
+		expanded = append(expanded, b.String())
+	}
+	return expanded, nil
+}
+
+// formatArg formats an argument value for the command line
+func formatArg(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	case float64, bool:
+		return fmt.Sprint(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// validPlaceholder reports whether s looks like an argument name
+func validPlaceholder(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// limitedBuffer keeps at most max bytes of what is written to it
+type limitedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -1,6 +1,9 @@
 package tool
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
@@ -191,3 +194,18 @@ func IsBinaryContent(path string) (bool, error) {
 	// If more than 30% non-printable, consider it binary
 	return float64(nonPrintable)/float64(n) > 0.3, nil
 }
+
+// hashFile returns the hex-encoded SHA-256 of a file's contents
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,151 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickcecere/btcx/internal/resource"
+)
+
+const gitDiffDescription = `Shows what changed in a resource between two versions (tags, branches or commits).
+Returns the changed files with line counts and the unified diff. Use it for questions about changes,
+migrations or breaking changes between releases instead of relying on memory.
+Tags may be given with or without a "v" prefix. Set stat to true to list changed files only.`
+
+// GitDiffTool diffs a git resource between two revisions
+type GitDiffTool struct {
+	workingDir string
+	sandbox    *Sandbox
+}
+
+// NewGitDiffTool creates a new gitdiff tool
+func NewGitDiffTool(workingDir string, sandbox *Sandbox) *GitDiffTool {
+	return &GitDiffTool{
+		workingDir: workingDir,
+		sandbox:    sandbox,
+	}
+}
+
+// Name returns the tool name
+func (t *GitDiffTool) Name() string {
+	return "gitdiff"
+}
+
+// Description returns the tool description
+func (t *GitDiffTool) Description() string {
+	return gitDiffDescription
+}
+
+// Parameters returns the JSON schema for the tool parameters
+func (t *GitDiffTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Resource or directory to diff (e.g. \"svelte\" or \"svelte/packages/svelte/src\")",
+			},
+			"from": map[string]interface{}{
+				"type":        "string",
+				"description": "Older version: tag, branch or commit (e.g. \"v4.0.0\")",
+			},
+			"to": map[string]interface{}{
+				"type":        "string",
+				"description": "Newer version (defaults to the cached version)",
+			},
+			"stat": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Only list changed files with line counts",
+			},
+		},
+		"required": []string{"path", "from"},
+	}
+}
+
+// gitDiffArgs are the arguments for the gitdiff tool
+type gitDiffArgs struct {
+	Path string `json:"path"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	Stat bool   `json:"stat"`
+}
+
+// Execute runs the gitdiff tool
+func (t *GitDiffTool) Execute(ctx context.Context, args json.RawMessage) (*Result, error) {
+	var a gitDiffArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	if strings.TrimSpace(a.From) == "" {
+		return nil, fmt.Errorf("from is required")
+	}
+
+	target, err := t.sandbox.Resolve(t.workingDir, a.Path)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(t.workingDir, target)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("path must name a resource in the collection: %q", a.Path)
+	}
+
+	// The first component is the resource; the rest narrows the diff
+	parts := strings.SplitN(filepath.ToSlash(rel), "/", 2)
+	dir := filepath.Join(t.workingDir, parts[0])
+	var sub string
+	if len(parts) == 2 {
+		sub = parts[1]
+	}
+
+	diff, err := resource.Diff(ctx, dir, resource.DiffOptions{From: a.From, To: a.To, Path: sub})
+	if err != nil {
+		return nil, err
+	}
+
+	to := a.To
+	if to == "" {
+		to = "current"
+	}
+	title := fmt.Sprintf("%s %s..%s", filepath.ToSlash(rel), a.From, to)
+
+	if len(diff.Files) == 0 {
+		return &Result{
+			Title:  title,
+			Output: fmt.Sprintf("No changes in %s between %s and %s", filepath.ToSlash(rel), a.From, to),
+			Metadata: map[string]interface{}{
+				"files":     0,
+				"truncated": false,
+			},
+		}, nil
+	}
+
+	added, deleted := 0, 0
+	for _, f := range diff.Files {
+		added += f.Added
+		deleted += f.Deleted
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("%s %s (%s) .. %s (%s): %d files changed, +%d -%d\n\n",
+		filepath.ToSlash(rel), a.From, diff.From.String()[:7], to, diff.To.String()[:7], len(diff.Files), added, deleted))
+	for _, f := range diff.Files {
+		output.WriteString(fmt.Sprintf("  %s | +%d -%d\n", f.Path, f.Added, f.Deleted))
+	}
+	if !a.Stat {
+		output.WriteString("\n")
+		output.WriteString(diff.Patch)
+	}
+
+	return &Result{
+		Title:  title,
+		Output: output.String(),
+		Metadata: map[string]interface{}{
+			"files":     len(diff.Files),
+			"truncated": false,
+		},
+	}, nil
+}
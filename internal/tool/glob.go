@@ -7,22 +7,43 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/nickcecere/btcx/internal/search"
 )
 
 const globDescription = `Fast file pattern matching tool that works with any codebase size.
 Supports glob patterns like "**/*.js" or "src/**/*.ts".
 Returns matching file paths sorted by modification time.
+Set scope to "docs", "code", "tests" or "examples" to list only documentation, source, test or example files.
 Use this tool when you need to find files by name patterns.`
 
 // GlobTool finds files matching a pattern
 type GlobTool struct {
 	workingDir string
+	sandbox    *Sandbox
+	limits     Limits
+	scope      search.Scope
+	boost      search.Scope
 }
 
 // NewGlobTool creates a new glob tool
-func NewGlobTool(workingDir string) *GlobTool {
-	return &GlobTool{workingDir: workingDir}
+func NewGlobTool(workingDir string, sandbox *Sandbox) *GlobTool {
+	return &GlobTool{workingDir: workingDir, sandbox: sandbox, limits: Limits{}.withDefaults()}
+}
+
+// SetLimits sets the result count limit
+func (t *GlobTool) SetLimits(l Limits) {
+	t.limits = l.withDefaults()
+}
+
+// SetScope sets the default search scope
+func (t *GlobTool) SetScope(scope search.Scope) {
+	t.scope = scope
+}
+
+// SetBoost lists the results of a kind of files first (search.ScopeAll for none)
+func (t *GlobTool) SetBoost(boost search.Scope) {
+	t.boost = boost
 }
 
 // Name returns the tool name
@@ -48,6 +69,7 @@ func (t *GlobTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "The directory to search in. Defaults to the current working directory.",
 			},
+			"scope": scopeParameter,
 		},
 		"required": []string{"pattern"},
 	}
@@ -57,6 +79,7 @@ func (t *GlobTool) Parameters() map[string]interface{} {
 type globArgs struct {
 	Pattern string `json:"pattern"`
 	Path    string `json:"path"`
+	Scope   string `json:"scope"`
 }
 
 // Execute runs the glob tool
@@ -66,30 +89,46 @@ func (t *GlobTool) Execute(ctx context.Context, args json.RawMessage) (*Result,
 		return nil, fmt.Errorf("invalid arguments: %w", err)
 	}
 
-	if a.Pattern == "" {
-		return nil, fmt.Errorf("pattern is required")
+	if strings.TrimSpace(a.Pattern) == "" {
+		return nil, fmt.Errorf("pattern is required, e.g. \"**/*.go\"")
+	}
+	if !doublestar.ValidatePattern(a.Pattern) {
+		return nil, fmt.Errorf("invalid glob pattern %q; check for unbalanced [ ] or { }", a.Pattern)
+	}
+
+	scope, err := resolveScope(a.Scope, t.scope)
+	if err != nil {
+		return nil, err
 	}
 
 	// Resolve search path
-	searchPath := t.workingDir
-	if a.Path != "" {
-		if filepath.IsAbs(a.Path) {
-			searchPath = a.Path
-		} else {
-			searchPath = filepath.Join(t.workingDir, a.Path)
-		}
+	searchPath, err := t.sandbox.Resolve(t.workingDir, a.Path)
+	if err != nil {
+		return nil, err
 	}
 
 	// Run search
 	opts := search.GlobOptions{
-		MaxFiles: 100,
+		MaxFiles: t.limits.MaxFiles,
+		Scope:    scope,
 	}
 
-	files, err := search.Glob(searchPath, a.Pattern, opts)
+	files, err := search.GlobBoosted(searchPath, a.Pattern, opts, t.boost)
 	if err != nil {
 		return nil, fmt.Errorf("glob failed: %w", err)
 	}
 
+	// Drop files reached through symlinks that leave the sandbox
+	if t.sandbox != nil {
+		kept := files[:0]
+		for _, f := range files {
+			if t.sandbox.Allowed(f.Path) {
+				kept = append(kept, f)
+			}
+		}
+		files = kept
+	}
+
 	if len(files) == 0 {
 		return &Result{
 			Title:  filepath.Base(searchPath),
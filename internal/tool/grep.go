@@ -3,8 +3,11 @@ package tool
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/nickcecere/btcx/internal/search"
@@ -12,19 +15,56 @@ import (
 
 const grepDescription = `Fast content search tool that works with any codebase size.
 Searches file contents using regular expressions.
-Supports full regex syntax (e.g., "log.*Error", "function\s+\w+").
+Supports full regex syntax (e.g., "log.*Error", "function\s+\w+"), except lookarounds and backreferences.
 Filter files by pattern with the include parameter (e.g., "*.js", "*.{ts,tsx}").
+Set scope to "docs", "code", "tests" or "examples" to search only documentation, source, test or example files.
 Returns file paths and line numbers with matches, sorted by modification time.
 Use this tool when you need to find files containing specific patterns.`
 
+const grepCompactDescription = `
+Results are compact: paths are relative to the directory named in the first line, files are listed as
+"name:" under a "dir/" line, each match is "line:text" with indentation removed, and runs of 3 or more
+consecutive lines are shown as "first-last:" followed by the lines indented by one space.`
+
+// GrepFormatCompact groups grep matches per file with less repetition
+const GrepFormatCompact = "compact"
+
+// compactRunLength is the shortest run of consecutive lines collapsed into a range
+const compactRunLength = 3
+
 // GrepTool searches file contents using regex
 type GrepTool struct {
 	workingDir string
+	sandbox    *Sandbox
+	limits     Limits
+	scope      search.Scope
+	boost      search.Scope
+	format     string
 }
 
 // NewGrepTool creates a new grep tool
-func NewGrepTool(workingDir string) *GrepTool {
-	return &GrepTool{workingDir: workingDir}
+func NewGrepTool(workingDir string, sandbox *Sandbox) *GrepTool {
+	return &GrepTool{workingDir: workingDir, sandbox: sandbox, limits: Limits{}.withDefaults()}
+}
+
+// SetLimits sets the match and line length limits
+func (t *GrepTool) SetLimits(l Limits) {
+	t.limits = l.withDefaults()
+}
+
+// SetScope sets the default search scope
+func (t *GrepTool) SetScope(scope search.Scope) {
+	t.scope = scope
+}
+
+// SetBoost lists the results of a kind of files first (search.ScopeAll for none)
+func (t *GrepTool) SetBoost(boost search.Scope) {
+	t.boost = boost
+}
+
+// SetFormat sets the result format ("default" or "compact")
+func (t *GrepTool) SetFormat(format string) {
+	t.format = format
 }
 
 // Name returns the tool name
@@ -34,6 +74,9 @@ func (t *GrepTool) Name() string {
 
 // Description returns the tool description
 func (t *GrepTool) Description() string {
+	if t.format == GrepFormatCompact {
+		return grepDescription + grepCompactDescription
+	}
 	return grepDescription
 }
 
@@ -54,6 +97,7 @@ func (t *GrepTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": `File pattern to include in the search (e.g., "*.js", "*.{ts,tsx}")`,
 			},
+			"scope": scopeParameter,
 		},
 		"required": []string{"pattern"},
 	}
@@ -64,6 +108,7 @@ type grepArgs struct {
 	Pattern string `json:"pattern"`
 	Path    string `json:"path"`
 	Include string `json:"include"`
+	Scope   string `json:"scope"`
 }
 
 // Execute runs the grep tool
@@ -73,32 +118,48 @@ func (t *GrepTool) Execute(ctx context.Context, args json.RawMessage) (*Result,
 		return nil, fmt.Errorf("invalid arguments: %w", err)
 	}
 
-	if a.Pattern == "" {
-		return nil, fmt.Errorf("pattern is required")
+	if err := checkPattern(a.Pattern); err != nil {
+		return nil, err
+	}
+
+	scope, err := resolveScope(a.Scope, t.scope)
+	if err != nil {
+		return nil, err
 	}
 
 	// Resolve search path
-	searchPath := t.workingDir
-	if a.Path != "" {
-		if filepath.IsAbs(a.Path) {
-			searchPath = a.Path
-		} else {
-			searchPath = filepath.Join(t.workingDir, a.Path)
-		}
+	searchPath, err := t.sandbox.Resolve(t.workingDir, a.Path)
+	if err != nil {
+		return nil, err
 	}
 
 	// Run search
 	opts := search.GrepOptions{
 		Include:       a.Include,
-		MaxMatches:    100,
-		MaxLineLength: 2000,
+		MaxMatches:    t.limits.MaxMatches,
+		MaxLineLength: t.limits.MaxLineLength,
+		Scope:         scope,
 	}
 
-	matches, err := search.Grep(searchPath, a.Pattern, opts)
+	matches, err := search.GrepBoosted(searchPath, a.Pattern, opts, t.boost)
+	if errors.Is(err, search.ErrGrepTimeout) {
+		return nil, fmt.Errorf("%w; narrow it with path or include, or use a more specific pattern", err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("search failed: %w", err)
 	}
 
+	// Drop matches reached through symlinks that leave the sandbox
+	if t.sandbox != nil {
+		kept := matches[:0]
+		for _, m := range matches {
+			if t.sandbox.Allowed(m.Path) {
+				kept = append(kept, m)
+			}
+		}
+		matches = kept
+	}
+
 	if len(matches) == 0 {
 		return &Result{
 			Title:  a.Pattern,
@@ -109,9 +170,112 @@ func (t *GrepTool) Execute(ctx context.Context, args json.RawMessage) (*Result,
 			},
 		}, nil
 	}
+	truncated := len(matches) >= opts.MaxMatches
+
+	// Identical copies of a file, such as a library vendored by several
+	// resources, are listed once
+	matches, copies := dedupeMatches(matches)
 
 	// Format output
 	var output strings.Builder
+	if t.format == GrepFormatCompact {
+		t.writeCompact(&output, matches, copies)
+	} else {
+		t.writeDefault(&output, matches, copies)
+	}
+
+	if truncated {
+		output.WriteString("\n(Results are truncated. Consider using a more specific path or pattern.)")
+	}
+
+	return &Result{
+		Title:  a.Pattern,
+		Output: output.String(),
+		Metadata: map[string]interface{}{
+			"matches":    len(matches),
+			"truncated":  truncated,
+			"duplicates": countCopies(copies),
+		},
+	}, nil
+}
+
+// dedupeMatches drops the matches in files whose content is identical to
+// an earlier file's, returning the rest and the paths of each kept file's
+// copies
+func dedupeMatches(matches []search.Match) ([]search.Match, map[string][]string) {
+	// Cheap check first: only files of the same size can be copies
+	sizes := make(map[string]int64)
+	bySize := make(map[int64]int)
+	for _, m := range matches {
+		if _, ok := sizes[m.Path]; ok {
+			continue
+		}
+		info, err := os.Stat(m.Path)
+		if err != nil {
+			sizes[m.Path] = -1
+			continue
+		}
+		sizes[m.Path] = info.Size()
+		bySize[info.Size()]++
+	}
+
+	hashes := make(map[string]string)
+	original := make(map[string]string) // hash -> first file with it
+	copyOf := make(map[string]string)   // dropped file -> kept file
+	copies := make(map[string][]string)
+	for _, m := range matches {
+		if _, ok := hashes[m.Path]; ok || sizes[m.Path] < 0 || bySize[sizes[m.Path]] < 2 {
+			continue
+		}
+		hash, err := hashFile(m.Path)
+		hashes[m.Path] = hash
+		if err != nil {
+			continue
+		}
+		if first, ok := original[hash]; ok {
+			copyOf[m.Path] = first
+			copies[first] = append(copies[first], m.Path)
+			continue
+		}
+		original[hash] = m.Path
+	}
+	if len(copyOf) == 0 {
+		return matches, nil
+	}
+
+	kept := matches[:0]
+	for _, m := range matches {
+		if _, ok := copyOf[m.Path]; !ok {
+			kept = append(kept, m)
+		}
+	}
+	return kept, copies
+}
+
+// countCopies returns the number of files dropped as copies
+func countCopies(copies map[string][]string) int {
+	n := 0
+	for _, paths := range copies {
+		n += len(paths)
+	}
+	return n
+}
+
+// alsoFoundIn returns the note listing a file's identical copies ("" if it
+// has none)
+func (t *GrepTool) alsoFoundIn(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	rel := make([]string, len(paths))
+	for i, path := range paths {
+		rel[i] = t.relPath(path)
+	}
+	return " (identical copy also found in " + strings.Join(rel, ", ") + ")"
+}
+
+// writeDefault lists matches per file with their line numbers
+func (t *GrepTool) writeDefault(output *strings.Builder, matches []search.Match, copies map[string][]string) {
 	output.WriteString(fmt.Sprintf("Found %d matches\n", len(matches)))
 
 	currentFile := ""
@@ -120,27 +284,124 @@ func (t *GrepTool) Execute(ctx context.Context, args json.RawMessage) (*Result,
 			if currentFile != "" {
 				output.WriteString("\n")
 			}
-			relPath, _ := filepath.Rel(t.workingDir, match.Path)
-			if relPath == "" {
-				relPath = match.Path
-			}
 			currentFile = match.Path
-			output.WriteString(fmt.Sprintf("%s:\n", relPath))
+			output.WriteString(fmt.Sprintf("%s:%s\n", t.relPath(match.Path), t.alsoFoundIn(copies[match.Path])))
 		}
 		output.WriteString(fmt.Sprintf("  Line %d: %s\n", match.LineNum, match.LineText))
 	}
+}
 
-	truncated := len(matches) >= opts.MaxMatches
-	if truncated {
-		output.WriteString("\n(Results are truncated. Consider using a more specific path or pattern.)")
+// writeCompact lists matches relative to their common directory, grouped
+// per directory and file, without indentation and with runs of consecutive
+// lines collapsed
+func (t *GrepTool) writeCompact(output *strings.Builder, matches []search.Match, copies map[string][]string) {
+	// Group matches per file, keeping the files in result order
+	var files []string
+	byFile := make(map[string][]search.Match)
+	notes := make(map[string]string)
+	for _, m := range matches {
+		path := t.relPath(m.Path)
+		if _, ok := byFile[path]; !ok {
+			files = append(files, path)
+			notes[path] = t.alsoFoundIn(copies[m.Path])
+		}
+		byFile[path] = append(byFile[path], m)
 	}
 
-	return &Result{
-		Title:  a.Pattern,
-		Output: output.String(),
-		Metadata: map[string]interface{}{
-			"matches":   len(matches),
-			"truncated": truncated,
-		},
-	}, nil
+	prefix := commonDir(files)
+	header := fmt.Sprintf("%d matches in %d files", len(matches), len(files))
+	if prefix != "" {
+		header += " under " + prefix + "/"
+	}
+	output.WriteString(header + "\n")
+
+	// Group files per directory, in order of the directory's first file
+	var dirs []string
+	byDir := make(map[string][]string)
+	for _, path := range files {
+		rel := path
+		if prefix != "" {
+			rel = strings.TrimPrefix(path, prefix+"/")
+		}
+		dir, name := "", rel
+		if i := strings.LastIndex(rel, "/"); i >= 0 {
+			dir, name = rel[:i+1], rel[i+1:]
+		}
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], name)
+	}
+
+	for _, dir := range dirs {
+		output.WriteString("\n")
+		if dir != "" {
+			output.WriteString(dir + "\n")
+		}
+		for _, name := range byDir[dir] {
+			path := name
+			if dir != "" {
+				path = dir + name
+			}
+			if prefix != "" {
+				path = prefix + "/" + path
+			}
+			output.WriteString(name + ":" + notes[path] + "\n")
+			writeCompactLines(output, byFile[path])
+		}
+	}
+}
+
+// writeCompactLines writes a file's matches in line order, as "line:text"
+// or, for runs of consecutive lines, a "first-last:" line followed by the
+// lines indented by one space
+func writeCompactLines(output *strings.Builder, matches []search.Match) {
+	sort.Slice(matches, func(i, j int) bool { return matches[i].LineNum < matches[j].LineNum })
+	for i := 0; i < len(matches); {
+		end := i + 1
+		for end < len(matches) && matches[end].LineNum == matches[end-1].LineNum+1 {
+			end++
+		}
+
+		if end-i >= compactRunLength {
+			output.WriteString(fmt.Sprintf("%d-%d:\n", matches[i].LineNum, matches[end-1].LineNum))
+			for _, m := range matches[i:end] {
+				output.WriteString(" " + strings.TrimSpace(m.LineText) + "\n")
+			}
+		} else {
+			for _, m := range matches[i:end] {
+				output.WriteString(fmt.Sprintf("%d:%s\n", m.LineNum, strings.TrimSpace(m.LineText)))
+			}
+		}
+		i = end
+	}
+}
+
+// relPath returns a match path relative to the working directory
+func (t *GrepTool) relPath(path string) string {
+	relPath, _ := filepath.Rel(t.workingDir, path)
+	if relPath == "" {
+		return path
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// commonDir returns the deepest directory containing all paths ("" if none)
+func commonDir(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+
+	common := strings.Split(paths[0], "/")
+	common = common[:len(common)-1]
+	for _, path := range paths[1:] {
+		parts := strings.Split(path, "/")
+		parts = parts[:len(parts)-1]
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	return strings.Join(common, "/")
 }
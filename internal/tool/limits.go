@@ -0,0 +1,86 @@
+package tool
+
+const (
+	// DefaultMaxMatches is the default number of grep matches returned
+	DefaultMaxMatches = 100
+	// DefaultMaxFiles is the default number of glob results returned
+	DefaultMaxFiles = 100
+)
+
+// Limits bounds the work and output of a tool
+// Zero fields use the package defaults
+type Limits struct {
+	// MaxOutputBytes truncates (and saves) outputs larger than this
+	MaxOutputBytes int
+
+	// MaxOutputLines truncates (and saves) outputs with more lines than this
+	MaxOutputLines int
+
+	// MaxReadBytes is the most file content returned by a single read
+	MaxReadBytes int
+
+	// DefaultReadLines is the number of lines read when no limit is given
+	DefaultReadLines int
+
+	// MaxLineLength truncates longer lines in read and grep output
+	MaxLineLength int
+
+	// MaxMatches is the most grep matches returned
+	MaxMatches int
+
+	// MaxFiles is the most glob results returned
+	MaxFiles int
+}
+
+// withDefaults fills zero fields with the package defaults
+func (l Limits) withDefaults() Limits {
+	if l.MaxOutputBytes <= 0 {
+		l.MaxOutputBytes = MaxOutputBytes
+	}
+	if l.MaxOutputLines <= 0 {
+		l.MaxOutputLines = MaxOutputLines
+	}
+	if l.MaxReadBytes <= 0 {
+		l.MaxReadBytes = maxBytes
+	}
+	if l.DefaultReadLines <= 0 {
+		l.DefaultReadLines = defaultReadLimit
+	}
+	if l.MaxLineLength <= 0 {
+		l.MaxLineLength = maxLineLength
+	}
+	if l.MaxMatches <= 0 {
+		l.MaxMatches = DefaultMaxMatches
+	}
+	if l.MaxFiles <= 0 {
+		l.MaxFiles = DefaultMaxFiles
+	}
+	return l
+}
+
+// limitedTool is implemented by tools whose limits can be changed
+type limitedTool interface {
+	SetLimits(l Limits)
+}
+
+// SetLimits sets the limits for a tool, including output truncation
+// Unknown tool names are ignored
+func (r *Registry) SetLimits(name string, l Limits) {
+	t, ok := r.tools[name]
+	if !ok {
+		return
+	}
+	l = l.withDefaults()
+	r.limits[name] = l
+	if lt, ok := t.(limitedTool); ok {
+		lt.SetLimits(l)
+	}
+}
+
+// limitsFor returns the limits of a tool
+func (r *Registry) limitsFor(name string) Limits {
+	if l, ok := r.limits[name]; ok {
+		return l
+	}
+	return Limits{}.withDefaults()
+}
@@ -0,0 +1,95 @@
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// lineReader reads lines while keeping at most a bounded prefix of each
+// line in memory, so files with very long lines (minified JS, JSON blobs)
+// can be streamed without buffering whole lines
+type lineReader struct {
+	r   *bufio.Reader
+	pos int64
+}
+
+// newLineReader creates a line reader starting at byte position pos of r
+func newLineReader(r io.Reader, pos int64) *lineReader {
+	return &lineReader{r: bufio.NewReaderSize(r, 64*1024), pos: pos}
+}
+
+// next reads the next line, keeping at most max bytes of it
+// It returns the kept prefix, the full line length in bytes (excluding the
+// line terminator) and io.EOF once no more lines are available
+func (lr *lineReader) next(max int) ([]byte, int, error) {
+	var kept []byte
+	length := 0
+	sawData := false
+
+	for {
+		chunk, err := lr.r.ReadSlice('\n')
+		lr.pos += int64(len(chunk))
+		if len(chunk) > 0 {
+			sawData = true
+		}
+
+		data := chunk
+		terminated := false
+		if len(data) > 0 && data[len(data)-1] == '\n' {
+			data = data[:len(data)-1]
+			terminated = true
+			// Drop the carriage return of CRLF line endings
+			data = bytes.TrimSuffix(data, []byte{'\r'})
+		}
+		length += len(data)
+		if room := max - len(kept); room > 0 {
+			if len(data) > room {
+				kept = append(kept, data[:room]...)
+			} else {
+				kept = append(kept, data...)
+			}
+		}
+
+		if terminated {
+			break
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			if err == io.EOF && sawData {
+				break
+			}
+			return nil, 0, err
+		}
+	}
+
+	return kept, length, nil
+}
+
+// countLines counts the newlines in the first n bytes of r
+func countLines(r io.Reader, n int64) (int, error) {
+	buf := make([]byte, 64*1024)
+	count := 0
+	remaining := n
+
+	for remaining > 0 {
+		size := int64(len(buf))
+		if remaining < size {
+			size = remaining
+		}
+		read, err := r.Read(buf[:size])
+		count += bytes.Count(buf[:read], []byte{'\n'})
+		remaining -= int64(read)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return count, nil
+}
@@ -17,11 +17,12 @@ Use this tool to explore the structure of a codebase.`
 // ListTool lists directory contents
 type ListTool struct {
 	workingDir string
+	sandbox    *Sandbox
 }
 
 // NewListTool creates a new list tool
-func NewListTool(workingDir string) *ListTool {
-	return &ListTool{workingDir: workingDir}
+func NewListTool(workingDir string, sandbox *Sandbox) *ListTool {
+	return &ListTool{workingDir: workingDir, sandbox: sandbox}
 }
 
 // Name returns the tool name
@@ -61,13 +62,9 @@ func (t *ListTool) Execute(ctx context.Context, args json.RawMessage) (*Result,
 	}
 
 	// Resolve path
-	listPath := t.workingDir
-	if a.Path != "" {
-		if filepath.IsAbs(a.Path) {
-			listPath = a.Path
-		} else {
-			listPath = filepath.Join(t.workingDir, a.Path)
-		}
+	listPath, err := t.sandbox.Resolve(t.workingDir, a.Path)
+	if err != nil {
+		return nil, err
 	}
 
 	// Check if directory exists
@@ -0,0 +1,148 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const noteDescription = `Keeps short working notes for this investigation: findings, file locations,
+leads still to check. Notes persist in the thread and are shown back to you on every turn under
+"Investigation Notes", so record what you learned from a tool result instead of searching again.
+Keep each note to a sentence or two. action is add (default), update or remove (by note number) or clear.`
+
+// Note limits keep the notes block compact
+const (
+	// MaxNotes is the number of notes a thread keeps; the oldest go first
+	MaxNotes = 20
+
+	// maxNoteLength caps a single note, in bytes
+	maxNoteLength = 500
+)
+
+// Notebook stores the notes the note tool writes, e.g. on the current thread
+type Notebook interface {
+	// Notes returns the notes in order
+	Notes() []string
+
+	// SetNotes replaces the notes
+	SetNotes(notes []string)
+}
+
+// NoteTool lets the model keep working notes across iterations
+type NoteTool struct {
+	notebook Notebook
+}
+
+// NewNoteTool creates a new note tool writing to notebook
+func NewNoteTool(notebook Notebook) *NoteTool {
+	return &NoteTool{notebook: notebook}
+}
+
+// Name returns the tool name
+func (t *NoteTool) Name() string {
+	return "note"
+}
+
+// Description returns the tool description
+func (t *NoteTool) Description() string {
+	return noteDescription
+}
+
+// Parameters returns the JSON schema for the tool parameters
+func (t *NoteTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"add", "update", "remove", "clear"},
+				"description": "What to do with the notes (defaults to add)",
+			},
+			"text": map[string]interface{}{
+				"type":        "string",
+				"description": "The note to add, or the new text for update (required for both)",
+			},
+			"number": map[string]interface{}{
+				"type":        "number",
+				"description": "The note number to update or remove (1-based, as shown in the notes)",
+			},
+		},
+	}
+}
+
+// noteArgs are the arguments for the note tool
+type noteArgs struct {
+	Action string `json:"action"`
+	Text   string `json:"text"`
+	Number int    `json:"number"`
+}
+
+// Execute runs the note tool
+func (t *NoteTool) Execute(ctx context.Context, args json.RawMessage) (*Result, error) {
+	var a noteArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	notes := append([]string(nil), t.notebook.Notes()...)
+	text := strings.Join(strings.Fields(a.Text), " ")
+	if len(text) > maxNoteLength {
+		text = truncateUTF8(text, maxNoteLength) + "..."
+	}
+
+	switch a.Action {
+	case "", "add":
+		if text == "" {
+			return nil, fmt.Errorf("text is required")
+		}
+		notes = append(notes, text)
+		if len(notes) > MaxNotes {
+			notes = notes[len(notes)-MaxNotes:]
+		}
+	case "update", "remove":
+		if a.Number < 1 || a.Number > len(notes) {
+			return nil, fmt.Errorf("no note %d (there are %d notes)", a.Number, len(notes))
+		}
+		if a.Action == "remove" {
+			notes = append(notes[:a.Number-1], notes[a.Number:]...)
+			break
+		}
+		if text == "" {
+			return nil, fmt.Errorf("text is required")
+		}
+		notes[a.Number-1] = text
+	case "clear":
+		notes = nil
+	default:
+		return nil, fmt.Errorf("unknown action %q (expected add, update, remove or clear)", a.Action)
+	}
+	t.notebook.SetNotes(notes)
+
+	output := fmt.Sprintf("Notes updated (%d).", len(notes))
+	if len(notes) > 0 {
+		output += "\n\n" + FormatNotes(notes)
+	}
+	return &Result{
+		Title:  fmt.Sprintf("%d notes", len(notes)),
+		Output: output,
+	}, nil
+}
+
+// FormatNotes numbers notes as the note tool refers to them
+func FormatNotes(notes []string) string {
+	var sb strings.Builder
+	for i, note := range notes {
+		fmt.Fprintf(&sb, "%d. %s\n", i+1, note)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	for n > 0 && n < len(s) && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
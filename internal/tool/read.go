@@ -1,36 +1,65 @@
 package tool
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/nickcecere/btcx/internal/provider"
 )
 
 const readDescription = `Reads a file from the local filesystem.
 You can access any file directly by using this tool.
-By default, it reads up to 2000 lines starting from the beginning of the file.
+By default, it reads up to %d lines starting from the beginning of the file.
 You can optionally specify a line offset and limit for long files.
-Any lines longer than 2000 characters will be truncated.
+Any lines longer than %d characters will be truncated; use byteOffset to continue reading inside a long line.
 Results are returned with line numbers starting at 1.`
 
+const readImagesDescription = `
+Images (PNG, JPEG, GIF, WebP) such as architecture diagrams are returned as images you can look at. SVGs are read as text.`
+
 const (
 	defaultReadLimit = 2000
 	maxLineLength    = 2000
-	maxBytes         = 50 * 1024 // 50KB
+	maxBytes         = 50 * 1024       // 50KB
+	maxImageBytes    = 3 * 1024 * 1024 // 3MB, 4MB once base64 encoded
 )
 
+// imageTypes are the image files returned to multimodal models
+var imageTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
 // ReadTool reads file contents
 type ReadTool struct {
 	workingDir string
+	sandbox    *Sandbox
+	limits     Limits
+	images     bool
 }
 
 // NewReadTool creates a new read tool
-func NewReadTool(workingDir string) *ReadTool {
-	return &ReadTool{workingDir: workingDir}
+func NewReadTool(workingDir string, sandbox *Sandbox) *ReadTool {
+	return &ReadTool{workingDir: workingDir, sandbox: sandbox, limits: Limits{}.withDefaults()}
+}
+
+// SetLimits sets the read size and line length limits
+func (t *ReadTool) SetLimits(l Limits) {
+	t.limits = l.withDefaults()
+}
+
+// SetImages lets the tool return image files, for models that accept images
+func (t *ReadTool) SetImages(enabled bool) {
+	t.images = enabled
 }
 
 // Name returns the tool name
@@ -40,7 +69,11 @@ func (t *ReadTool) Name() string {
 
 // Description returns the tool description
 func (t *ReadTool) Description() string {
-	return readDescription
+	desc := fmt.Sprintf(readDescription, t.limits.DefaultReadLines, t.limits.MaxLineLength)
+	if t.images {
+		desc += readImagesDescription
+	}
+	return desc
 }
 
 // Parameters returns the JSON schema for the tool parameters
@@ -58,7 +91,11 @@ func (t *ReadTool) Parameters() map[string]interface{} {
 			},
 			"limit": map[string]interface{}{
 				"type":        "number",
-				"description": "The number of lines to read (defaults to 2000)",
+				"description": fmt.Sprintf("The number of lines to read (defaults to %d)", t.limits.DefaultReadLines),
+			},
+			"byteOffset": map[string]interface{}{
+				"type":        "number",
+				"description": "Byte position to start reading from, for continuing inside very long lines (overrides offset)",
 			},
 		},
 		"required": []string{"filePath"},
@@ -70,6 +107,8 @@ type readArgs struct {
 	FilePath string `json:"filePath"`
 	Offset   int    `json:"offset"`
 	Limit    int    `json:"limit"`
+	// ByteOffset seeks to a byte position, for files with very long lines
+	ByteOffset int64 `json:"byteOffset"`
 }
 
 // Execute runs the read tool
@@ -82,11 +121,17 @@ func (t *ReadTool) Execute(ctx context.Context, args json.RawMessage) (*Result,
 	if a.FilePath == "" {
 		return nil, fmt.Errorf("filePath is required")
 	}
+	if err := checkLineArgument("offset", a.Offset); err != nil {
+		return nil, err
+	}
+	if err := checkLineArgument("limit", a.Limit); err != nil {
+		return nil, err
+	}
 
 	// Resolve file path
-	filePath := a.FilePath
-	if !filepath.IsAbs(filePath) {
-		filePath = filepath.Join(t.workingDir, filePath)
+	filePath, err := t.sandbox.Resolve(t.workingDir, a.FilePath)
+	if err != nil {
+		return nil, err
 	}
 
 	// Check if file exists
@@ -108,6 +153,14 @@ func (t *ReadTool) Execute(ctx context.Context, args json.RawMessage) (*Result,
 		return nil, fmt.Errorf("path is a directory, not a file: %s", filePath)
 	}
 
+	// Images go to the model as images if it can see them
+	if mediaType, ok := imageTypes[strings.ToLower(filepath.Ext(filePath))]; ok {
+		if !t.images {
+			return nil, fmt.Errorf("cannot read image file: %s (the model does not accept images)", filePath)
+		}
+		return t.readImage(filePath, mediaType, info.Size())
+	}
+
 	// Check for binary file by extension first
 	if isBinaryExtension(filePath) {
 		return nil, fmt.Errorf("cannot read binary file: %s", filePath)
@@ -122,9 +175,17 @@ func (t *ReadTool) Execute(ctx context.Context, args json.RawMessage) (*Result,
 	// Set defaults
 	limit := a.Limit
 	if limit == 0 {
-		limit = defaultReadLimit
+		limit = t.limits.DefaultReadLines
 	}
 	offset := a.Offset
+	maxLine := t.limits.MaxLineLength
+
+	if a.ByteOffset < 0 {
+		return nil, fmt.Errorf("byteOffset must not be negative")
+	}
+	if a.ByteOffset > 0 && a.ByteOffset >= info.Size() {
+		return nil, fmt.Errorf("byteOffset %d is beyond the end of the file (%d bytes)", a.ByteOffset, info.Size())
+	}
 
 	// Read file
 	file, err := os.Open(filePath)
@@ -133,37 +194,65 @@ func (t *ReadTool) Execute(ctx context.Context, args json.RawMessage) (*Result,
 	}
 	defer file.Close()
 
-	// Read lines
-	scanner := bufio.NewScanner(file)
-	var lines []string
+	// When seeking to a byte offset, count the preceding lines so line
+	// numbers stay accurate; the line offset is ignored in this mode
 	lineNum := 0
-	bytesRead := 0
-	truncatedByBytes := false
+	if a.ByteOffset > 0 {
+		lineNum, err = countLines(file, a.ByteOffset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		if _, err := file.Seek(a.ByteOffset, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to seek file: %w", err)
+		}
+		offset = lineNum
+	}
 
-	for scanner.Scan() {
-		lineNum++
+	reader := newLineReader(file, a.ByteOffset)
 
-		// Skip lines before offset
-		if lineNum <= offset {
-			continue
+	// Skip lines before offset without keeping their contents
+	eof := false
+	for lineNum < offset {
+		if _, _, err := reader.next(0); err != nil {
+			if err == io.EOF {
+				eof = true
+				break
+			}
+			return nil, fmt.Errorf("failed to read file: %w", err)
 		}
+		lineNum++
+	}
 
-		// Check if we've read enough lines
-		if len(lines) >= limit {
-			break
+	// Read lines
+	var lines []string
+	bytesRead := 0
+	truncatedByBytes := false
+
+	for !eof && len(lines) < limit {
+		lineStart := reader.pos
+		data, length, err := reader.next(maxLine)
+		if err != nil {
+			if err == io.EOF {
+				eof = true
+				break
+			}
+			return nil, fmt.Errorf("failed to read file: %w", err)
 		}
+		lineNum++
 
-		line := scanner.Text()
+		line := strings.ToValidUTF8(string(data), "")
 
-		// Truncate long lines
-		if len(line) > maxLineLength {
-			line = line[:maxLineLength] + "..."
+		// Truncate long lines, pointing at where the rest can be read
+		if length > maxLine {
+			line += fmt.Sprintf("... (line truncated, %d more bytes. Use 'byteOffset' %d to continue this line)",
+				length-maxLine, lineStart+int64(maxLine))
 		}
 
 		// Check bytes limit
 		lineBytes := len(line) + 1 // +1 for newline
-		if bytesRead+lineBytes > maxBytes {
+		if bytesRead+lineBytes > t.limits.MaxReadBytes {
 			truncatedByBytes = true
+			lineNum--
 			break
 		}
 
@@ -171,14 +260,22 @@ func (t *ReadTool) Execute(ctx context.Context, args json.RawMessage) (*Result,
 		bytesRead += lineBytes
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+	// Check whether anything follows without reading the rest of the file
+	hasMoreLines := false
+	if !eof && !truncatedByBytes {
+		if _, err := reader.r.Peek(1); err == nil {
+			hasMoreLines = true
+		}
 	}
 
 	// Format output with line numbers
 	var output strings.Builder
 	output.WriteString("<file>\n")
 
+	if a.ByteOffset > 0 {
+		output.WriteString(fmt.Sprintf("(Starting at byte %d, within line %d)\n", a.ByteOffset, offset+1))
+	}
+
 	for i, line := range lines {
 		lineNumber := offset + i + 1
 		output.WriteString(fmt.Sprintf("%05d| %s\n", lineNumber, line))
@@ -186,10 +283,9 @@ func (t *ReadTool) Execute(ctx context.Context, args json.RawMessage) (*Result,
 
 	// Add truncation message
 	lastReadLine := offset + len(lines)
-	hasMoreLines := lineNum > lastReadLine
 
 	if truncatedByBytes {
-		output.WriteString(fmt.Sprintf("\n(Output truncated at %d bytes. Use 'offset' parameter to read beyond line %d)", maxBytes, lastReadLine))
+		output.WriteString(fmt.Sprintf("\n(Output truncated at %d bytes. Use 'offset' parameter to read beyond line %d)", t.limits.MaxReadBytes, lastReadLine))
 	} else if hasMoreLines {
 		output.WriteString(fmt.Sprintf("\n(File has more lines. Use 'offset' parameter to read beyond line %d)", lastReadLine))
 	} else {
@@ -211,6 +307,39 @@ func (t *ReadTool) Execute(ctx context.Context, args json.RawMessage) (*Result,
 	}, nil
 }
 
+// readImage returns an image file as an image result
+func (t *ReadTool) readImage(filePath, mediaType string, size int64) (*Result, error) {
+	if size > maxImageBytes {
+		return nil, fmt.Errorf("image is too large to read: %s (%d bytes, limit %d)", filePath, size, maxImageBytes)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// Trust the content over the extension, and reject files that aren't images
+	if detected := http.DetectContentType(data); strings.HasPrefix(detected, "image/") {
+		mediaType = detected
+	} else {
+		return nil, fmt.Errorf("cannot read binary file: %s (not a valid image)", filePath)
+	}
+
+	relPath, _ := filepath.Rel(t.workingDir, filePath)
+	if relPath == "" {
+		relPath = filePath
+	}
+
+	return &Result{
+		Title:  relPath,
+		Output: fmt.Sprintf("<image path=%q type=%q bytes=\"%d\">\n(The image is attached.)\n</image>", relPath, mediaType, len(data)),
+		Metadata: map[string]interface{}{
+			"image": mediaType,
+		},
+		Image: &provider.Image{MediaType: mediaType, Data: data},
+	}, nil
+}
+
 // isBinaryExtension checks if a file has a binary extension
 func isBinaryExtension(path string) bool {
 	ext := strings.ToLower(filepath.Ext(path))
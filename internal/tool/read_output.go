@@ -0,0 +1,183 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const readOutputDescription = `Pages through a tool output that was truncated and saved to a file.
+When a grep, glob, list or read result ends with "[Full output saved to: ...]", pass the
+file name shown there as 'id' and an 'offset' to continue reading where the preview stopped.
+Results are returned with line numbers starting at 1.`
+
+// ReadOutputTool reads saved truncated outputs for the current thread
+type ReadOutputTool struct {
+	registry *Registry
+}
+
+// NewReadOutputTool creates a new read_output tool backed by the registry's
+// output directory and thread
+func NewReadOutputTool(registry *Registry) *ReadOutputTool {
+	return &ReadOutputTool{registry: registry}
+}
+
+// Name returns the tool name
+func (t *ReadOutputTool) Name() string {
+	return "read_output"
+}
+
+// Description returns the tool description
+func (t *ReadOutputTool) Description() string {
+	return readOutputDescription
+}
+
+// Parameters returns the JSON schema for the tool parameters
+func (t *ReadOutputTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id": map[string]interface{}{
+				"type":        "string",
+				"description": "The saved output file name (e.g. grep-1a2b3c4d.txt) or full path",
+			},
+			"offset": map[string]interface{}{
+				"type":        "number",
+				"description": "The line number to start reading from (0-based)",
+			},
+			"limit": map[string]interface{}{
+				"type":        "number",
+				"description": "The number of lines to read (defaults to a full page)",
+			},
+		},
+		"required": []string{"id"},
+	}
+}
+
+// readOutputArgs are the arguments for the read_output tool
+type readOutputArgs struct {
+	ID     string `json:"id"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+}
+
+// Execute runs the read_output tool
+func (t *ReadOutputTool) Execute(ctx context.Context, args json.RawMessage) (*Result, error) {
+	var a readOutputArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	if a.ID == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	if err := checkLineArgument("offset", a.Offset); err != nil {
+		return nil, err
+	}
+	if err := checkLineArgument("limit", a.Limit); err != nil {
+		return nil, err
+	}
+
+	// Only files saved for the current thread can be read
+	name := filepath.Base(a.ID)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		return nil, fmt.Errorf("invalid output id: %s", a.ID)
+	}
+	threadDir := filepath.Join(t.registry.outputDir, t.registry.threadID)
+	path := filepath.Join(threadDir, name)
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("saved output not found: %s (outputs are only available within the thread that produced them)", name)
+		}
+		return nil, fmt.Errorf("failed to open saved output: %w", err)
+	}
+	defer file.Close()
+
+	// Pages stay well below the truncation limits
+	limits := t.registry.limitsFor(t.Name())
+	pageLines := limits.MaxOutputLines * 4 / 5
+	pageBytes := limits.MaxOutputBytes * 4 / 5
+
+	limit := a.Limit
+	if limit <= 0 || limit > pageLines {
+		limit = pageLines
+	}
+	offset := a.Offset
+
+	reader := newLineReader(file, 0)
+
+	// Skip lines before offset
+	lineNum := 0
+	eof := false
+	for lineNum < offset {
+		if _, _, err := reader.next(0); err != nil {
+			if err == io.EOF {
+				eof = true
+				break
+			}
+			return nil, fmt.Errorf("failed to read saved output: %w", err)
+		}
+		lineNum++
+	}
+
+	var output strings.Builder
+	output.WriteString("<output>\n")
+
+	read := 0
+	bytesRead := 0
+	truncatedByBytes := false
+	for !eof && read < limit {
+		data, length, err := reader.next(limits.MaxLineLength)
+		if err != nil {
+			if err == io.EOF {
+				eof = true
+				break
+			}
+			return nil, fmt.Errorf("failed to read saved output: %w", err)
+		}
+
+		line := strings.ToValidUTF8(string(data), "")
+		if length > limits.MaxLineLength {
+			line += "..."
+		}
+
+		formatted := fmt.Sprintf("%05d| %s\n", lineNum+1, line)
+		if bytesRead+len(formatted) > pageBytes {
+			truncatedByBytes = true
+			break
+		}
+
+		output.WriteString(formatted)
+		bytesRead += len(formatted)
+		lineNum++
+		read++
+	}
+
+	hasMore := truncatedByBytes
+	if !eof && !hasMore {
+		if _, err := reader.r.Peek(1); err == nil {
+			hasMore = true
+		}
+	}
+
+	if hasMore {
+		output.WriteString(fmt.Sprintf("\n(More output available. Use 'offset' %d to continue)", lineNum))
+	} else {
+		output.WriteString(fmt.Sprintf("\n(End of output - total %d lines)", lineNum))
+	}
+	output.WriteString("\n</output>")
+
+	return &Result{
+		Title:  name,
+		Output: output.String(),
+		Metadata: map[string]interface{}{
+			"truncated": hasMore,
+		},
+	}, nil
+}
@@ -0,0 +1,70 @@
+package tool
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Call is a tool call and its result, as recorded by btcx ask --record
+type Call struct {
+	// Tool is the tool's name
+	Tool string `json:"tool"`
+
+	// Arguments are the call's JSON arguments
+	Arguments json.RawMessage `json:"arguments"`
+
+	// Result is the result returned to the model
+	Result *Result `json:"result,omitempty"`
+
+	// Error is the call's error, if it failed
+	Error string `json:"error,omitempty"`
+}
+
+// SetRecorder has every tool call passed to record once it completes
+func (r *Registry) SetRecorder(record func(Call)) {
+	r.record = record
+}
+
+// SetReplay answers tool calls from recorded calls instead of running the
+// tools: each call gets the result of the first unused recorded call with
+// the same tool and arguments
+func (r *Registry) SetReplay(calls []Call) {
+	r.replay = &replayedCalls{calls: calls, used: make([]bool, len(calls))}
+}
+
+// replayedCalls are the recorded tool calls of a replay
+type replayedCalls struct {
+	mu    sync.Mutex
+	calls []Call
+	used  []bool
+}
+
+// answer returns the recorded result of a tool call
+func (c *replayedCalls) answer(name string, args json.RawMessage) (*Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, call := range c.calls {
+		if c.used[i] || call.Tool != name || !sameJSON(call.Arguments, args) {
+			continue
+		}
+		c.used[i] = true
+		if call.Error != "" {
+			return nil, errors.New(call.Error)
+		}
+		return call.Result, nil
+	}
+	return nil, fmt.Errorf("%s call with arguments %s is not in the recording", name, args)
+}
+
+// sameJSON reports whether two JSON values are the same apart from spacing
+func sameJSON(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
@@ -0,0 +1,227 @@
+package tool
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxEchoedArguments caps the malformed arguments quoted back to the model
+const maxEchoedArguments = 300
+
+// RepairArguments returns tool call arguments as a JSON object, fixing the
+// mistakes models commonly make: trailing commas, unquoted keys,
+// single-quoted strings and objects encoded as a JSON string
+// repaired reports whether anything had to be fixed; empty arguments are an
+// empty object
+func RepairArguments(args json.RawMessage) (fixed json.RawMessage, repaired bool, err error) {
+	trimmed := bytes.TrimSpace(args)
+	if len(trimmed) == 0 || string(trimmed) == "null" {
+		return json.RawMessage("{}"), len(trimmed) != 0, nil
+	}
+	if isObject(trimmed) {
+		return args, false, nil
+	}
+
+	// Some models encode the object as a string
+	var encoded string
+	if json.Unmarshal(trimmed, &encoded) == nil {
+		trimmed = bytes.TrimSpace([]byte(encoded))
+		if isObject(trimmed) {
+			return json.RawMessage(trimmed), true, nil
+		}
+	}
+
+	candidate := []byte(repairJSON(string(trimmed)))
+	if isObject(candidate) {
+		return json.RawMessage(candidate), true, nil
+	}
+
+	// Report the original error, not one about our rewrite
+	var v interface{}
+	if err := json.Unmarshal(trimmed, &v); err != nil {
+		return nil, false, err
+	}
+	return nil, false, fmt.Errorf("arguments must be a JSON object")
+}
+
+// isObject reports whether data is a valid JSON object
+func isObject(data []byte) bool {
+	var obj map[string]interface{}
+	return json.Unmarshal(data, &obj) == nil && obj != nil
+}
+
+// repairJSON rewrites almost-JSON into JSON: code fences are dropped, keys
+// quoted, single-quoted strings double-quoted and trailing commas removed
+// Text inside double-quoted strings is left alone
+func repairJSON(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```json")
+		s = strings.TrimPrefix(s, "```")
+		s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+	}
+
+	var out strings.Builder
+	// last is the last non-space character written outside strings
+	var last byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			end := stringEnd(s, i, '"')
+			out.WriteString(s[i:end])
+			i = end - 1
+			last = '"'
+		case c == '\'':
+			end := stringEnd(s, i, '\'')
+			out.WriteString(requote(s[i:end]))
+			i = end - 1
+			last = '"'
+		case c == ',':
+			if next := nextNonSpace(s, i+1); next == '}' || next == ']' {
+				continue
+			}
+			out.WriteByte(c)
+			last = c
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(s) && isIdentPart(s[j]) {
+				j++
+			}
+			word := s[i:j]
+			if (last == '{' || last == ',') && nextNonSpace(s, j) == ':' {
+				word = `"` + word + `"`
+			} else if literal, ok := pythonLiterals[word]; ok {
+				word = literal
+			}
+			out.WriteString(word)
+			i = j - 1
+			last = 'a'
+		default:
+			out.WriteByte(c)
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				last = c
+			}
+		}
+	}
+	return out.String()
+}
+
+// pythonLiterals maps Python constants to their JSON spelling
+var pythonLiterals = map[string]string{"True": "true", "False": "false", "None": "null"}
+
+// stringEnd returns the index just past the string starting at s[start],
+// or len(s) if it isn't closed
+func stringEnd(s string, start int, quote byte) int {
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// requote turns a single-quoted string into a double-quoted one
+func requote(s string) string {
+	inner := strings.TrimPrefix(s, "'")
+	inner = strings.TrimSuffix(inner, "'")
+	inner = strings.ReplaceAll(inner, `\'`, `'`)
+
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(inner); i++ {
+		switch c := inner[i]; c {
+		case '\\':
+			out.WriteByte(c)
+			if i+1 < len(inner) {
+				i++
+				out.WriteByte(inner[i])
+			}
+		case '"':
+			out.WriteString(`\"`)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+// nextNonSpace returns the first non-space character at or after i (0 if none)
+func nextNonSpace(s string, i int) byte {
+	for ; i < len(s); i++ {
+		if c := s[i]; c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			return c
+		}
+	}
+	return 0
+}
+
+// isIdentStart reports whether c can start an unquoted key
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isIdentPart reports whether c can continue an unquoted key
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c == '-' || (c >= '0' && c <= '9')
+}
+
+// MalformedArguments describes arguments that couldn't be repaired, so the
+// model can send the call again
+// It quotes what was sent and shows the shape the tool expects
+func (r *Registry) MalformedArguments(name string, args json.RawMessage, err error) string {
+	sent := strings.TrimSpace(string(args))
+	var encoded string
+	if json.Unmarshal(args, &encoded) == nil {
+		sent = strings.TrimSpace(encoded)
+	}
+	if len(sent) > maxEchoedArguments {
+		sent = strings.ToValidUTF8(sent[:maxEchoedArguments], "") + "..."
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "the arguments of this %s call couldn't be parsed (%v), so it was not run.\n", name, err)
+	fmt.Fprintf(&sb, "You sent: %s\n", sent)
+	fmt.Fprintf(&sb, "Call %s again with its arguments as a single JSON object", name)
+
+	t, ok := r.Get(name)
+	if !ok {
+		return sb.String()
+	}
+	schema := t.Parameters()
+	properties, _ := schema["properties"].(map[string]interface{})
+	required := stringList(schema["required"])
+
+	example := make([]string, 0, len(required))
+	isRequired := make(map[string]bool)
+	for _, p := range required {
+		isRequired[p] = true
+		typ := "value"
+		if prop, ok := properties[p].(map[string]interface{}); ok {
+			if s, ok := prop["type"].(string); ok {
+				typ = s
+			}
+		}
+		example = append(example, fmt.Sprintf("%q: <%s>", p, typ))
+	}
+	fmt.Fprintf(&sb, ", e.g. {%s}", strings.Join(example, ", "))
+
+	var optional []string
+	for p := range properties {
+		if !isRequired[p] {
+			optional = append(optional, p)
+		}
+	}
+	if len(optional) > 0 {
+		sort.Strings(optional)
+		fmt.Fprintf(&sb, "\nOptional arguments: %s", strings.Join(optional, ", "))
+	}
+	return sb.String()
+}
@@ -0,0 +1,123 @@
+package tool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sandbox restricts tool paths to the collection directory and the
+// resources it links to
+// A nil Sandbox allows every path
+type Sandbox struct {
+	workingDir string
+	roots      []string
+}
+
+// NewSandbox creates a sandbox rooted at the collection directory
+// Top-level symlinks in the directory (the collection's resources) are
+// resolved once and their targets are allowed too
+func NewSandbox(workingDir string) *Sandbox {
+	s := &Sandbox{workingDir: workingDir}
+	s.addRoot(workingDir)
+
+	entries, err := os.ReadDir(workingDir)
+	if err != nil {
+		return s
+	}
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink != 0 {
+			s.addRoot(filepath.Join(workingDir, entry.Name()))
+		}
+	}
+	return s
+}
+
+// addRoot allows a directory after resolving its symlinks
+func (s *Sandbox) addRoot(dir string) {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		resolved = filepath.Clean(dir)
+	}
+	if abs, err := filepath.Abs(resolved); err == nil {
+		resolved = abs
+	}
+	s.roots = append(s.roots, resolved)
+}
+
+// Resolve joins a tool path argument onto the working directory and
+// checks that it stays inside the sandbox
+// An empty path resolves to the working directory
+func (s *Sandbox) Resolve(workingDir, path string) (string, error) {
+	resolved := workingDir
+	if path != "" {
+		if filepath.IsAbs(path) {
+			resolved = path
+		} else {
+			resolved = filepath.Join(workingDir, path)
+		}
+	}
+
+	if err := s.Check(resolved); err != nil {
+		return "", err
+	}
+	return resolved, nil
+}
+
+// Check returns an error if path, after resolving symlinks, is outside
+// the collection and its resources
+func (s *Sandbox) Check(path string) error {
+	if s == nil {
+		return nil
+	}
+
+	real := resolveExisting(path)
+	for _, root := range s.roots {
+		if within(root, real) {
+			return nil
+		}
+	}
+	return fmt.Errorf("path is outside the searched resources: %s (sandbox is enabled; set tools.sandbox: false to allow it)", path)
+}
+
+// Allowed reports whether path is inside the sandbox
+func (s *Sandbox) Allowed(path string) bool {
+	return s.Check(path) == nil
+}
+
+// resolveExisting resolves symlinks in the longest existing prefix of path
+// and appends the remaining elements, so paths that don't exist yet can't
+// hide an escape behind a missing component
+func resolveExisting(path string) string {
+	path = filepath.Clean(path)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	var rest []string
+	current := path
+	for {
+		if resolved, err := filepath.EvalSymlinks(current); err == nil {
+			for i := len(rest) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, rest[i])
+			}
+			return resolved
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return path
+		}
+		rest = append(rest, filepath.Base(current))
+		current = parent
+	}
+}
+
+// within reports whether path is root or inside it
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -0,0 +1,121 @@
+package tool
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// validateArgs checks tool arguments against a JSON object schema
+// It supports the subset tools use: property types, required, enum, array
+// items and additionalProperties. Unknown arguments are rejected unless
+// additionalProperties is true
+func validateArgs(schema map[string]interface{}, values map[string]interface{}) error {
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := values[name]; !ok {
+			return fmt.Errorf("missing required argument %q", name)
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prop, ok := properties[name].(map[string]interface{})
+		if !ok {
+			if extra, _ := schema["additionalProperties"].(bool); extra {
+				continue
+			}
+			return fmt.Errorf("unknown argument %q", name)
+		}
+		if err := validateValue(prop, values[name]); err != nil {
+			return fmt.Errorf("argument %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// validateValue checks a single value against a property schema
+func validateValue(prop map[string]interface{}, value interface{}) error {
+	if enum, ok := prop["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("must be one of %v", enum)
+		}
+	}
+
+	typ, _ := prop["type"].(string)
+	switch typ {
+	case "":
+		return nil
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("must be a string")
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("must be a number")
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("must be an integer")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("must be a boolean")
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("must be an array")
+		}
+		if itemSchema, ok := prop["items"].(map[string]interface{}); ok {
+			for i, item := range items {
+				if err := validateValue(itemSchema, item); err != nil {
+					return fmt.Errorf("item %d: %w", i, err)
+				}
+			}
+		}
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("must be an object")
+		}
+		if _, ok := prop["properties"]; ok {
+			return validateArgs(prop, obj)
+		}
+	default:
+		return fmt.Errorf("unsupported schema type %q", typ)
+	}
+	return nil
+}
+
+// stringList converts a schema list ([]interface{} or []string) to strings
+func stringList(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				out = append(out, strings.TrimSpace(s))
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package tool
+
+import (
+	"github.com/nickcecere/btcx/internal/search"
+)
+
+// scopeParameter is the JSON schema of the scope argument of the search tools
+var scopeParameter = map[string]interface{}{
+	"type":        "string",
+	"enum":        []string{"docs", "code", "tests", "examples", "all"},
+	"description": "Only search documentation (md, mdx, rst), source code, tests, or examples (example code and tests). Defaults to all files.",
+}
+
+// scopedTool is implemented by tools that can be restricted to a scope
+type scopedTool interface {
+	SetScope(scope search.Scope)
+}
+
+// SetScope sets the scope searches use when the model doesn't pass one
+func (r *Registry) SetScope(scope search.Scope) {
+	for _, t := range r.tools {
+		if st, ok := t.(scopedTool); ok {
+			st.SetScope(scope)
+		}
+	}
+}
+
+// boostedTool is implemented by tools that can list a kind of files first
+type boostedTool interface {
+	SetBoost(boost search.Scope)
+}
+
+// SetBoost has the search tools list results of a kind of files first
+func (r *Registry) SetBoost(boost search.Scope) {
+	for _, t := range r.tools {
+		if bt, ok := t.(boostedTool); ok {
+			bt.SetBoost(boost)
+		}
+	}
+}
+
+// resolveScope parses a scope argument, falling back to the tool's default
+func resolveScope(arg string, def search.Scope) (search.Scope, error) {
+	if arg == "" {
+		return def, nil
+	}
+	return search.ParseScope(arg)
+}
@@ -0,0 +1,280 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nickcecere/btcx/internal/index"
+	"github.com/nickcecere/btcx/internal/search"
+)
+
+const semanticSearchDescription = `Searches the resources by meaning rather than exact text.
+Combines keyword (BM25) and fuzzy embedding ranking and returns the best matching code and doc snippets
+with file paths and line ranges. Use it for vague or conceptual questions ("how are retries configured?")
+when you don't know exact identifiers; use grep when you do.`
+
+const (
+	// defaultSemanticResults is the number of snippets returned by default
+	defaultSemanticResults = 6
+	// maxSemanticResults caps the limit argument
+	maxSemanticResults = 20
+	// maxSnippetLines caps the lines shown per snippet
+	maxSnippetLines = 25
+	// maxSnippetLineLength truncates long snippet lines
+	maxSnippetLineLength = 200
+)
+
+// SemanticSearchTool runs hybrid retrieval over a local chunk index
+type SemanticSearchTool struct {
+	workingDir string
+	sandbox    *Sandbox
+	indexDir   string
+
+	scope search.Scope
+
+	mu      sync.Mutex
+	indexes map[string]*index.Index
+}
+
+// NewSemanticSearchTool creates a new semantic_search tool storing its
+// indexes in indexDir
+func NewSemanticSearchTool(workingDir string, sandbox *Sandbox, indexDir string) *SemanticSearchTool {
+	return &SemanticSearchTool{
+		workingDir: workingDir,
+		sandbox:    sandbox,
+		indexDir:   indexDir,
+		indexes:    make(map[string]*index.Index),
+	}
+}
+
+// SetScope sets the default search scope
+func (t *SemanticSearchTool) SetScope(scope search.Scope) {
+	t.scope = scope
+}
+
+// Name returns the tool name
+func (t *SemanticSearchTool) Name() string {
+	return "semantic_search"
+}
+
+// Description returns the tool description
+func (t *SemanticSearchTool) Description() string {
+	return semanticSearchDescription
+}
+
+// Parameters returns the JSON schema for the tool parameters
+func (t *SemanticSearchTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "What you are looking for, in words or identifiers",
+			},
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional resource or directory to restrict the search to (e.g. \"cobra\" or \"cobra/docs\")",
+			},
+			"limit": map[string]interface{}{
+				"type":        "number",
+				"description": fmt.Sprintf("Number of snippets to return (default %d, max %d)", defaultSemanticResults, maxSemanticResults),
+			},
+			"scope": scopeParameter,
+		},
+		"required": []string{"query"},
+	}
+}
+
+// semanticSearchArgs are the arguments for the semantic_search tool
+type semanticSearchArgs struct {
+	Query string `json:"query"`
+	Path  string `json:"path"`
+	Limit int    `json:"limit"`
+	Scope string `json:"scope"`
+}
+
+// resourceIndex is a loaded index and the collection entry it belongs to
+type resourceIndex struct {
+	name  string
+	index *index.Index
+}
+
+// Execute runs the semantic_search tool
+func (t *SemanticSearchTool) Execute(ctx context.Context, args json.RawMessage) (*Result, error) {
+	var a semanticSearchArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	if strings.TrimSpace(a.Query) == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	if a.Limit < 0 {
+		return nil, fmt.Errorf("limit must not be negative, got %d", a.Limit)
+	}
+
+	scope, err := resolveScope(a.Scope, t.scope)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := a.Limit
+	if limit <= 0 {
+		limit = defaultSemanticResults
+	}
+	if limit > maxSemanticResults {
+		limit = maxSemanticResults
+	}
+
+	// Restrict to a resource and optional directory prefix
+	var onlyResource, prefix string
+	if a.Path != "" {
+		searchPath, err := t.sandbox.Resolve(t.workingDir, a.Path)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(t.workingDir, searchPath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return nil, fmt.Errorf("path must be inside the collection: %s", a.Path)
+		}
+		if rel != "." {
+			parts := strings.SplitN(filepath.ToSlash(rel), "/", 2)
+			onlyResource = parts[0]
+			if len(parts) == 2 {
+				prefix = strings.TrimSuffix(parts[1], "/") + "/"
+			}
+		}
+	}
+
+	loaded, err := t.load(ctx, onlyResource)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[*index.Index]string, len(loaded))
+	indexes := make([]*index.Index, 0, len(loaded))
+	for _, ri := range loaded {
+		names[ri.index] = ri.name
+		indexes = append(indexes, ri.index)
+	}
+
+	var filter func(*index.Index, *index.Chunk) bool
+	if prefix != "" || scope != search.ScopeAll {
+		filter = func(_ *index.Index, c *index.Chunk) bool {
+			return strings.HasPrefix(c.Path, prefix) && scope.Match(c.Path)
+		}
+	}
+
+	results := index.Search(indexes, a.Query, limit, filter)
+	if len(results) == 0 {
+		return &Result{
+			Title:  a.Query,
+			Output: "No files found",
+			Metadata: map[string]interface{}{
+				"matches":   0,
+				"truncated": false,
+			},
+		}, nil
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Found %d results\n", len(results)))
+	for _, r := range results {
+		path := names[r.Resource] + "/" + r.Path
+		output.WriteString(fmt.Sprintf("\n%s:%d-%d (keyword rank %s, embedding rank %s)\n",
+			path, r.StartLine, r.EndLine, formatRank(r.BM25Rank), formatRank(r.VectorRank)))
+		output.WriteString(snippet(filepath.Join(r.Resource.Root, filepath.FromSlash(r.Path)), r.StartLine, r.EndLine))
+	}
+	output.WriteString("\n(Use read with offset/limit to see more of a file.)")
+
+	return &Result{
+		Title:  a.Query,
+		Output: output.String(),
+		Metadata: map[string]interface{}{
+			"matches":   len(results),
+			"truncated": false,
+		},
+	}, nil
+}
+
+// load returns the indexes of the collection's resources, building or
+// refreshing them on first use
+func (t *SemanticSearchTool) load(ctx context.Context, only string) ([]resourceIndex, error) {
+	entries, err := os.ReadDir(t.workingDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var loaded []resourceIndex
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || (only != "" && name != only) {
+			continue
+		}
+
+		root, err := filepath.EvalSymlinks(filepath.Join(t.workingDir, name))
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			continue
+		}
+
+		idx, ok := t.indexes[root]
+		if !ok {
+			idx, err = index.Load(ctx, t.indexDir, root)
+			if err != nil {
+				return nil, fmt.Errorf("failed to index %s: %w", name, err)
+			}
+			t.indexes[root] = idx
+		}
+		loaded = append(loaded, resourceIndex{name: name, index: idx})
+	}
+
+	if only != "" && len(loaded) == 0 {
+		return nil, fmt.Errorf("resource not found in collection: %s", only)
+	}
+	return loaded, nil
+}
+
+// formatRank formats a retriever rank, or "-" if not retrieved
+func formatRank(rank int) string {
+	if rank == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("#%d", rank)
+}
+
+// snippet returns numbered lines of a file, capped for brevity
+func snippet(path string, start, end int) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "  (file no longer readable)\n"
+	}
+
+	lines := strings.Split(string(data), "\n")
+	if end > len(lines) {
+		end = len(lines)
+	}
+	if end-start+1 > maxSnippetLines {
+		end = start + maxSnippetLines - 1
+	}
+
+	var b strings.Builder
+	for i := start; i <= end; i++ {
+		line := strings.TrimRight(lines[i-1], "\r")
+		if len(line) > maxSnippetLineLength {
+			line = strings.ToValidUTF8(line[:maxSnippetLineLength], "") + "..."
+		}
+		b.WriteString(fmt.Sprintf("  %05d| %s\n", i, line))
+	}
+	return b.String()
+}
@@ -0,0 +1,218 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const skimDescription = `Skims a file in one call: its length, the first and last lines, and an outline of the
+declarations (functions, types, classes) in between with their line numbers.
+Use this to triage large files cheaply, then read only the sections you need with offset and limit.`
+
+const (
+	// defaultSkimLines is the number of lines shown from each end of a file
+	defaultSkimLines = 20
+	// maxSkimLines caps the lines argument
+	maxSkimLines = 200
+	// maxSkimOutline is the number of declarations listed
+	maxSkimOutline = 80
+	// maxSkimLineLength is where skimmed lines are cut
+	maxSkimLineLength = 200
+)
+
+// declarationPattern matches lines that start a declaration, after modifiers
+var declarationPattern = regexp.MustCompile(`^((export|default|pub(\([a-z]+\))?|async|abstract|public|private|protected|static|final|unsafe)\s+)*(func|function|def|class|type|interface|struct|enum|trait|impl|fn|module|macro)\b`)
+
+// IsDeclaration reports whether a line starts a top-level declaration or
+// one nested a level in, such as a method
+func IsDeclaration(line string) bool {
+	indent := len(line) - len(strings.TrimLeft(line, " \t"))
+	return indent <= 4 && declarationPattern.MatchString(strings.TrimSpace(line))
+}
+
+// SkimTool shows the ends and outline of a file
+type SkimTool struct {
+	workingDir string
+	sandbox    *Sandbox
+}
+
+// NewSkimTool creates a new skim tool
+func NewSkimTool(workingDir string, sandbox *Sandbox) *SkimTool {
+	return &SkimTool{workingDir: workingDir, sandbox: sandbox}
+}
+
+// Name returns the tool name
+func (t *SkimTool) Name() string {
+	return "skim"
+}
+
+// Description returns the tool description
+func (t *SkimTool) Description() string {
+	return skimDescription
+}
+
+// Parameters returns the JSON schema for the tool parameters
+func (t *SkimTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"filePath": map[string]interface{}{
+				"type":        "string",
+				"description": "The path to the file to skim",
+			},
+			"lines": map[string]interface{}{
+				"type":        "number",
+				"description": fmt.Sprintf("The number of lines to show from the start and from the end (defaults to %d)", defaultSkimLines),
+			},
+		},
+		"required": []string{"filePath"},
+	}
+}
+
+// skimArgs are the arguments for the skim tool
+type skimArgs struct {
+	FilePath string `json:"filePath"`
+	Lines    int    `json:"lines"`
+}
+
+// skimLine is a numbered line of a skimmed file
+type skimLine struct {
+	num  int
+	text string
+}
+
+// Execute runs the skim tool
+func (t *SkimTool) Execute(ctx context.Context, args json.RawMessage) (*Result, error) {
+	var a skimArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	if a.FilePath == "" {
+		return nil, fmt.Errorf("filePath is required")
+	}
+	if err := checkLineArgument("lines", a.Lines); err != nil {
+		return nil, err
+	}
+	n := a.Lines
+	if n == 0 {
+		n = defaultSkimLines
+	}
+	if n > maxSkimLines {
+		n = maxSkimLines
+	}
+
+	filePath, err := t.sandbox.Resolve(t.workingDir, a.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file not found: %s", filePath)
+		}
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("path is a directory, not a file: %s", filePath)
+	}
+	if isBinaryExtension(filePath) {
+		return nil, fmt.Errorf("cannot skim binary file: %s", filePath)
+	}
+	if binary, _ := IsBinaryContent(filePath); binary {
+		return nil, fmt.Errorf("cannot skim binary file: %s", filePath)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	// One pass: keep the head, a ring of the last n lines and the
+	// declarations seen after the head
+	var head, outline []skimLine
+	tail := make([]skimLine, 0, n)
+	moreDeclarations := false
+	reader := newLineReader(file, 0)
+	total := 0
+	for {
+		data, _, err := reader.next(maxSkimLineLength)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		total++
+		line := skimLine{num: total, text: strings.ToValidUTF8(string(data), "")}
+
+		if total <= n {
+			head = append(head, line)
+			continue
+		}
+		if len(tail) == n {
+			// The line leaving the tail is between head and tail
+			if old := tail[0]; IsDeclaration(old.text) {
+				if len(outline) < maxSkimOutline {
+					outline = append(outline, old)
+				} else {
+					moreDeclarations = true
+				}
+			}
+			tail = append(tail[:0], tail[1:]...)
+		}
+		tail = append(tail, line)
+	}
+
+	var output strings.Builder
+	output.WriteString("<file>\n")
+	fmt.Fprintf(&output, "(%d lines, %d bytes)\n", total, info.Size())
+	writeLines := func(lines []skimLine) {
+		for _, l := range lines {
+			fmt.Fprintf(&output, "%05d| %s\n", l.num, l.text)
+		}
+	}
+
+	writeLines(head)
+	if skipped := total - len(head) - len(tail); skipped > 0 {
+		fmt.Fprintf(&output, "\n... %d lines", skipped)
+		if len(outline) > 0 {
+			output.WriteString(", declarations:\n")
+			writeLines(outline)
+			if moreDeclarations {
+				output.WriteString("(more declarations not shown)\n")
+			}
+		} else {
+			output.WriteString(", no declarations found\n")
+		}
+		output.WriteString("...\n\n")
+	}
+	writeLines(tail)
+
+	if total > len(head)+len(tail) {
+		output.WriteString("\n(Skimmed. Use read with offset and limit to read a section.)")
+	} else {
+		fmt.Fprintf(&output, "\n(End of file - total %d lines)", total)
+	}
+	output.WriteString("\n</file>")
+
+	relPath, _ := filepath.Rel(t.workingDir, filePath)
+	if relPath == "" {
+		relPath = filePath
+	}
+	return &Result{
+		Title:  relPath,
+		Output: output.String(),
+		Metadata: map[string]interface{}{
+			"lines":   total,
+			"outline": len(outline),
+		},
+	}, nil
+}
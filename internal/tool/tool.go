@@ -4,6 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/audit"
+	"github.com/nickcecere/btcx/internal/provider"
 )
 
 // Tool is the interface that all tools must implement
@@ -31,25 +38,72 @@ type Result struct {
 
 	// Metadata contains additional structured data
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
+
+	// Image is an image for multimodal models (set by read for image files)
+	Image *provider.Image `json:"image,omitempty"`
 }
 
 // Registry holds all available tools
 type Registry struct {
-	tools     map[string]Tool
-	outputDir string
-	threadID  string
+	tools      map[string]Tool
+	workingDir string
+	sandbox    *Sandbox
+	outputDir  string
+	threadID   string
+	audit      *audit.Log
+	auditUser  string
+	limits     map[string]Limits
+	record     func(Call)
+	replay     *replayedCalls
 }
 
 // NewRegistry creates a new tool registry
 func NewRegistry() *Registry {
 	return &Registry{
-		tools: make(map[string]Tool),
+		tools:  make(map[string]Tool),
+		limits: make(map[string]Limits),
 	}
 }
 
 // SetOutputDir sets the directory for truncated outputs
+// This also registers the read_output tool for paging through them
 func (r *Registry) SetOutputDir(dir string) {
 	r.outputDir = dir
+	if dir != "" {
+		r.Register(NewReadOutputTool(r))
+	}
+}
+
+// SetIndexDir sets the directory for search indexes
+// This also registers the semantic_search tool
+func (r *Registry) SetIndexDir(dir string) {
+	if dir != "" {
+		r.Register(NewSemanticSearchTool(r.workingDir, r.sandbox, dir))
+	}
+}
+
+// EnableGitDiff registers the gitdiff tool
+func (r *Registry) EnableGitDiff() {
+	r.Register(NewGitDiffTool(r.workingDir, r.sandbox))
+}
+
+// EnableNotes registers the note tool, writing to notebook
+func (r *Registry) EnableNotes(notebook Notebook) {
+	r.Register(NewNoteTool(notebook))
+}
+
+// SetImages lets the read tool return image files to multimodal models
+func (r *Registry) SetImages(enabled bool) {
+	if t, ok := r.tools["read"].(*ReadTool); ok {
+		t.SetImages(enabled)
+	}
+}
+
+// SetGrepFormat sets the grep result format ("default" or "compact")
+func (r *Registry) SetGrepFormat(format string) {
+	if t, ok := r.tools["grep"].(*GrepTool); ok {
+		t.SetFormat(format)
+	}
 }
 
 // SetThreadID sets the current thread ID for organizing outputs
@@ -57,12 +111,27 @@ func (r *Registry) SetThreadID(threadID string) {
 	r.threadID = threadID
 }
 
+// SetAuditLog records every tool call to log, attributed to user
+func (r *Registry) SetAuditLog(log *audit.Log, user string) {
+	r.audit = log
+	r.auditUser = user
+}
+
+// Sandbox returns the sandbox tool paths are checked against (nil when the
+// sandbox is off)
+func (r *Registry) Sandbox() *Sandbox {
+	return r.sandbox
+}
+
 // GetTruncationConfig returns the truncation configuration
 func (r *Registry) GetTruncationConfig(toolName string) TruncationConfig {
+	limits := r.limitsFor(toolName)
 	return TruncationConfig{
 		OutputDir: r.outputDir,
 		ThreadID:  r.threadID,
 		ToolName:  toolName,
+		MaxBytes:  limits.MaxOutputBytes,
+		MaxLines:  limits.MaxOutputLines,
 	}
 }
 
@@ -77,29 +146,93 @@ func (r *Registry) Get(name string) (Tool, bool) {
 	return tool, ok
 }
 
-// List returns all registered tools
+// List returns all registered tools, sorted by name so requests (and their
+// cache keys) are the same from run to run
 func (r *Registry) List() []Tool {
 	tools := make([]Tool, 0, len(r.tools))
-	for _, tool := range r.tools {
-		tools = append(tools, tool)
+	for _, name := range r.names() {
+		tools = append(tools, r.tools[name])
 	}
 	return tools
 }
 
+// names returns the sorted names of all registered tools
+func (r *Registry) names() []string {
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Execute runs a tool by name
 func (r *Registry) Execute(ctx context.Context, name string, args json.RawMessage) (*Result, error) {
+	// Replayed calls don't touch the resources
+	if r.replay != nil {
+		return r.replay.answer(name, args)
+	}
+
+	start := time.Now()
+	result, outputBytes, err := r.execute(ctx, name, args)
+
+	if r.record != nil {
+		call := Call{Tool: name, Arguments: args, Result: result}
+		if err != nil {
+			call.Error = err.Error()
+		}
+		r.record(call)
+	}
+
+	if r.audit != nil {
+		entry := audit.Entry{
+			Time:          start,
+			ThreadID:      r.threadID,
+			User:          r.auditUser,
+			Tool:          name,
+			Arguments:     args,
+			DurationMs:    time.Since(start).Milliseconds(),
+			ArgumentBytes: len(args),
+			OutputBytes:   outputBytes,
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		} else if result != nil {
+			entry.ReturnedBytes = len(result.Output)
+			entry.Truncated, _ = result.Metadata["truncated"].(bool)
+		}
+		if auditErr := r.audit.Write(entry); auditErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", auditErr)
+		}
+	}
+
+	return result, err
+}
+
+// execute runs a tool and applies truncation, returning the size of the
+// full output before truncation
+func (r *Registry) execute(ctx context.Context, name string, args json.RawMessage) (*Result, int, error) {
 	tool, ok := r.Get(name)
 	if !ok {
-		return nil, fmt.Errorf("tool %q not found. Available tools: grep, glob, read, list", name)
+		return nil, 0, fmt.Errorf("tool %q not found. Available tools: %s", name, strings.Join(r.names(), ", "))
+	}
+	if err := checkArguments(name, args); err != nil {
+		return nil, 0, err
 	}
 
 	result, err := tool.Execute(ctx, args)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	outputBytes := 0
+	if result != nil {
+		outputBytes = len(result.Output)
 	}
 
 	// Apply truncation if output is too large
-	if r.outputDir != "" && result != nil {
+	// read_output pages are already bounded and must not be saved again
+	if r.outputDir != "" && result != nil && name != "read_output" {
 		truncCfg := r.GetTruncationConfig(name)
 		truncResult, truncErr := TruncateOutput(result.Output, truncCfg)
 		if truncErr == nil && truncResult.Truncated {
@@ -114,13 +247,13 @@ func (r *Registry) Execute(ctx context.Context, name string, args json.RawMessag
 		}
 	}
 
-	return result, nil
+	return result, outputBytes, nil
 }
 
 // ToOpenAITools converts the registry to OpenAI-compatible tool definitions
 func (r *Registry) ToOpenAITools() []map[string]interface{} {
 	tools := make([]map[string]interface{}, 0, len(r.tools))
-	for _, tool := range r.tools {
+	for _, tool := range r.List() {
 		tools = append(tools, map[string]interface{}{
 			"type": "function",
 			"function": map[string]interface{}{
@@ -136,7 +269,7 @@ func (r *Registry) ToOpenAITools() []map[string]interface{} {
 // ToAnthropicTools converts the registry to Anthropic-compatible tool definitions
 func (r *Registry) ToAnthropicTools() []map[string]interface{} {
 	tools := make([]map[string]interface{}, 0, len(r.tools))
-	for _, tool := range r.tools {
+	for _, tool := range r.List() {
 		tools = append(tools, map[string]interface{}{
 			"name":         tool.Name(),
 			"description":  tool.Description(),
@@ -147,11 +280,21 @@ func (r *Registry) ToAnthropicTools() []map[string]interface{} {
 }
 
 // DefaultRegistry creates a registry with all default tools
-func DefaultRegistry(workingDir string) *Registry {
+// When sandboxed, tools reject paths outside the working directory and
+// the resources it links to
+func DefaultRegistry(workingDir string, sandboxed bool) *Registry {
+	var sandbox *Sandbox
+	if sandboxed {
+		sandbox = NewSandbox(workingDir)
+	}
+
 	registry := NewRegistry()
-	registry.Register(NewGrepTool(workingDir))
-	registry.Register(NewGlobTool(workingDir))
-	registry.Register(NewReadTool(workingDir))
-	registry.Register(NewListTool(workingDir))
+	registry.workingDir = workingDir
+	registry.sandbox = sandbox
+	registry.Register(NewGrepTool(workingDir, sandbox))
+	registry.Register(NewGlobTool(workingDir, sandbox))
+	registry.Register(NewReadTool(workingDir, sandbox))
+	registry.Register(NewSkimTool(workingDir, sandbox))
+	registry.Register(NewListTool(workingDir, sandbox))
 	return registry
 }
@@ -31,6 +31,9 @@ type TruncationResult struct {
 
 	// OutputPath is the path to the full output file (if truncated)
 	OutputPath string
+
+	// PreviewLines is the number of complete lines kept in Content
+	PreviewLines int
 }
 
 // TruncationConfig holds configuration for truncation
@@ -43,6 +46,12 @@ type TruncationConfig struct {
 
 	// ToolName is the name of the tool that generated the output
 	ToolName string
+
+	// MaxBytes overrides MaxOutputBytes when set
+	MaxBytes int
+
+	// MaxLines overrides MaxOutputLines when set
+	MaxLines int
 }
 
 // TruncateOutput truncates output if it exceeds size limits
@@ -52,7 +61,15 @@ func TruncateOutput(output string, cfg TruncationConfig) (*TruncationResult, err
 	lines := strings.Split(output, "\n")
 	bytes := len(output)
 
-	needsTruncation := bytes > MaxOutputBytes || len(lines) > MaxOutputLines
+	maxBytes, maxLines := cfg.MaxBytes, cfg.MaxLines
+	if maxBytes <= 0 {
+		maxBytes = MaxOutputBytes
+	}
+	if maxLines <= 0 {
+		maxLines = MaxOutputLines
+	}
+
+	needsTruncation := bytes > maxBytes || len(lines) > maxLines
 
 	if !needsTruncation {
 		return &TruncationResult{
@@ -65,7 +82,7 @@ func TruncateOutput(output string, cfg TruncationConfig) (*TruncationResult, err
 	threadDir := filepath.Join(cfg.OutputDir, cfg.ThreadID)
 	if err := os.MkdirAll(threadDir, 0755); err != nil {
 		// If we can't create the directory, return truncated content without file
-		return truncateInMemory(output, lines), nil
+		return truncateInMemory(output, lines, maxBytes, maxLines), nil
 	}
 
 	// Generate unique filename
@@ -75,39 +92,54 @@ func TruncateOutput(output string, cfg TruncationConfig) (*TruncationResult, err
 	// Write full output to file
 	if err := os.WriteFile(outputPath, []byte(output), 0644); err != nil {
 		// If we can't write the file, return truncated content without file
-		return truncateInMemory(output, lines), nil
+		return truncateInMemory(output, lines, maxBytes, maxLines), nil
 	}
 
 	// Create truncated preview
-	result := truncateInMemory(output, lines)
+	result := truncateInMemory(output, lines, maxBytes, maxLines)
 	result.OutputPath = outputPath
 	result.Content += fmt.Sprintf("\n\n[Full output saved to: %s]", outputPath)
+	result.Content += fmt.Sprintf("\n[Use read_output with id %q and offset %d to read the rest]", filename, result.PreviewLines)
 
 	return result, nil
 }
 
 // truncateInMemory truncates content in memory without saving to file
-func truncateInMemory(output string, lines []string) *TruncationResult {
+func truncateInMemory(output string, lines []string, maxBytes, maxLines int) *TruncationResult {
 	var truncatedContent string
+	previewLines := len(lines)
 
 	// Truncate by lines first
-	if len(lines) > MaxOutputLines {
-		truncatedContent = strings.Join(lines[:MaxOutputLines], "\n")
-		truncatedContent += fmt.Sprintf("\n\n[Output truncated at %d lines. Total: %d lines]", MaxOutputLines, len(lines))
+	if len(lines) > maxLines {
+		truncatedContent = strings.Join(lines[:maxLines], "\n")
+		previewLines = maxLines
 	} else {
 		truncatedContent = output
 	}
 
 	// Then truncate by bytes if still too large
-	if len(truncatedContent) > MaxOutputBytes {
-		truncatedContent = truncatedContent[:MaxOutputBytes]
-		truncatedContent += "\n\n[Output truncated at 50KB]"
+	if len(truncatedContent) > maxBytes {
+		truncatedContent = truncatedContent[:maxBytes]
+		// The last line may be cut, so it is not counted as complete
+		previewLines = strings.Count(truncatedContent, "\n")
+		truncatedContent += fmt.Sprintf("\n\n[Output truncated at %s]", formatSize(maxBytes))
+	} else if len(lines) > maxLines {
+		truncatedContent += fmt.Sprintf("\n\n[Output truncated at %d lines. Total: %d lines]", maxLines, len(lines))
 	}
 
 	return &TruncationResult{
-		Content:   truncatedContent,
-		Truncated: true,
+		Content:      truncatedContent,
+		Truncated:    true,
+		PreviewLines: previewLines,
+	}
+}
+
+// formatSize formats a byte count, using KB for whole kilobytes
+func formatSize(n int) string {
+	if n >= 1024 && n%1024 == 0 {
+		return fmt.Sprintf("%dKB", n/1024)
 	}
+	return fmt.Sprintf("%d bytes", n)
 }
 
 // CleanupOldOutputs removes output files older than OutputFileExpiry
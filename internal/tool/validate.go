@@ -0,0 +1,101 @@
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+const (
+	// maxArgumentBytes caps the JSON arguments of a single tool call
+	maxArgumentBytes = 64 * 1024
+	// maxPatternLength caps grep patterns
+	maxPatternLength = 1000
+	// maxLineArgument caps offset and limit arguments counted in lines
+	maxLineArgument = 1000000
+)
+
+// checkArguments rejects tool calls whose arguments are too large to be
+// paths, patterns or queries, before they are parsed
+func checkArguments(name string, args []byte) error {
+	if len(args) > maxArgumentBytes {
+		return fmt.Errorf("%s arguments are %d bytes, the limit is %d; pass paths, patterns and queries, not file contents",
+			name, len(args), maxArgumentBytes)
+	}
+	return nil
+}
+
+// checkLineArgument rejects negative or absurd offset and limit values
+func checkLineArgument(name string, value int) error {
+	if value < 0 {
+		return fmt.Errorf("%s must not be negative, got %d", name, value)
+	}
+	if value > maxLineArgument {
+		return fmt.Errorf("%s %d is too large (at most %d); page through big files with offset and a smaller limit",
+			name, value, maxLineArgument)
+	}
+	return nil
+}
+
+// checkPattern rejects grep patterns that are empty, don't compile, match
+// every line or repeat a repetition, with feedback on what to search for
+// instead
+func checkPattern(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("pattern is required; search for an identifier, string or regular expression")
+	}
+	if len(pattern) > maxPatternLength {
+		return fmt.Errorf("pattern is %d characters, the limit is %d; search for a distinctive part and read the matches",
+			len(pattern), maxPatternLength)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		var syntaxErr *syntax.Error
+		switch {
+		case errors.As(err, &syntaxErr) && syntaxErr.Code == syntax.ErrInvalidPerlOp:
+			return fmt.Errorf("invalid pattern: %w (lookarounds aren't supported; search for the text itself)", err)
+		case errors.As(err, &syntaxErr) && syntaxErr.Code == syntax.ErrInvalidEscape && backreference.MatchString(syntaxErr.Expr):
+			return fmt.Errorf("invalid pattern: %w (backreferences aren't supported; spell out the repeated text)", err)
+		}
+		return fmt.Errorf("invalid pattern: %w (escape literal characters like ( [ { . * + ? with \\)", err)
+	}
+
+	if re.MatchString("") {
+		return fmt.Errorf("pattern %q matches every line; search for a specific identifier or phrase", pattern)
+	}
+	if parsed, err := syntax.Parse(pattern, syntax.Perl); err == nil && repeatsRepeat(parsed) {
+		return fmt.Errorf("pattern %q repeats a repetition like (a+)+, which backtracking engines can take forever on; repeat once instead (a+)", pattern)
+	}
+	return nil
+}
+
+// backreference matches a backreference escape such as \1
+var backreference = regexp.MustCompile(`^\\[1-9]`)
+
+// repeatsRepeat reports whether re applies an unbounded repetition directly
+// to another one, as in (a+)+ or (.*)*
+func repeatsRepeat(re *syntax.Regexp) bool {
+	if unbounded(re) {
+		sub := re.Sub[0]
+		for sub.Op == syntax.OpCapture {
+			sub = sub.Sub[0]
+		}
+		if unbounded(sub) {
+			return true
+		}
+	}
+	for _, sub := range re.Sub {
+		if repeatsRepeat(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// unbounded reports whether re is a *, + or {n,} repetition
+func unbounded(re *syntax.Regexp) bool {
+	return re.Op == syntax.OpStar || re.Op == syntax.OpPlus || (re.Op == syntax.OpRepeat && re.Max == -1)
+}
@@ -12,6 +12,8 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/nickcecere/btcx/internal/agent"
+	"github.com/nickcecere/btcx/internal/i18n"
 	"github.com/nickcecere/btcx/internal/provider"
 	"github.com/nickcecere/btcx/internal/ui"
 )
@@ -47,11 +49,16 @@ var (
 			Foreground(lipgloss.Color("226"))
 )
 
+// maxThinkingLines is the number of lines of thinking shown per answer
+const maxThinkingLines = 8
+
 // Messages for Bubble Tea
 type streamChunkMsg string
 type streamDoneMsg struct {
-	content string
-	err     error
+	content   string
+	thinking  string
+	citations []agent.Citation
+	err       error
 }
 type streamToolMsg string
 type streamToolDoneMsg struct{}
@@ -99,6 +106,14 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// Submit the input - clean ANSI escape sequences
 				question := strings.TrimSpace(m.input.Value())
 				question = cleanInput(question)
+				if question == "/retry" || strings.HasPrefix(question, "/retry ") {
+					m.input.Reset()
+					return m.retry(strings.TrimSpace(strings.TrimPrefix(question, "/retry")))
+				}
+				if question == "/save" || strings.HasPrefix(question, "/save ") {
+					m.input.Reset()
+					return m.save(strings.TrimSpace(strings.TrimPrefix(question, "/save")))
+				}
 				if question != "" {
 					m.input.Reset()
 					m.messages = append(m.messages, Message{
@@ -109,6 +124,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.currentChunk = ""
 					m.currentTool = ""
 					m.err = nil
+					m.status = ""
 					// Start spinner tick and ask question
 					return m, tea.Batch(spinnerTick(), m.askQuestion(question))
 				}
@@ -152,16 +168,19 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.err != nil {
 			m.err = msg.err
 		} else {
+			m.lastCitations = msg.citations
 			// Save the complete assistant message
 			if msg.content != "" {
 				m.messages = append(m.messages, Message{
-					Role:    "assistant",
-					Content: msg.content,
+					Role:     "assistant",
+					Content:  msg.content,
+					Thinking: msg.thinking,
 				})
 			} else if m.currentChunk != "" {
 				m.messages = append(m.messages, Message{
-					Role:    "assistant",
-					Content: m.currentChunk,
+					Role:     "assistant",
+					Content:  m.currentChunk,
+					Thinking: msg.thinking,
 				})
 			}
 			m.currentChunk = ""
@@ -185,11 +204,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 // View renders the UI
 func (m Model) View() string {
 	if m.quitting {
-		return "Goodbye!\n"
+		return i18n.T("tui.goodbye") + "\n"
 	}
 
 	if !m.ready {
-		return "Initializing..."
+		return i18n.T("tui.initializing")
 	}
 
 	// Build the view
@@ -212,25 +231,28 @@ func (m Model) View() string {
 		frames := ui.SpinnerFrames()
 		frame := spinnerStyle.Render(frames[m.spinnerFrame])
 		if m.currentTool != "" {
-			s.WriteString(fmt.Sprintf("%s Using %s...\n", frame, m.currentTool))
+			s.WriteString(frame + " " + i18n.T("tui.usingTool", m.currentTool) + "\n")
 		} else {
-			s.WriteString(fmt.Sprintf("%s Thinking...\n", frame))
+			s.WriteString(frame + " " + i18n.T("tui.thinking") + "\n")
 		}
 	} else {
 		// Clean the input view to remove escape sequences
 		inputView := m.input.View()
 		inputView = cleanInput(inputView)
 		// Restore the box drawing if it got stripped
-		if !strings.Contains(inputView, "Ask") && inputView == "" {
-			inputView = "Ask a question..."
+		if inputView == "" {
+			inputView = i18n.T("tui.placeholder")
 		}
 		s.WriteString(inputView + "\n")
 	}
 
 	// Help
-	help := helpStyle.Render("Enter: send | Ctrl+C: quit")
+	help := helpStyle.Render(i18n.T("tui.help"))
+	if m.status != "" {
+		help = helpStyle.Render(m.status)
+	}
 	if m.err != nil {
-		help = errorStyle.Render(fmt.Sprintf("Error: %v", m.err))
+		help = errorStyle.Render(i18n.T("tui.error", m.err))
 	}
 	s.WriteString(help)
 
@@ -249,13 +271,16 @@ func (m *Model) updateViewport() {
 	for i, msg := range m.messages {
 		switch msg.Role {
 		case "user":
-			content.WriteString(userStyle.Render("You: "))
+			content.WriteString(userStyle.Render(i18n.T("tui.you")))
 			content.WriteString(msg.Content)
 			content.WriteString("\n\n")
 
 		case "assistant":
-			content.WriteString(assistantStyle.Render("Assistant: "))
+			content.WriteString(assistantStyle.Render(i18n.T("tui.assistant")))
 			content.WriteString("\n")
+			if msg.Thinking != "" {
+				content.WriteString(thinkingView(msg.Thinking))
+			}
 			rendered, err := renderer.Render(msg.Content)
 			if err != nil {
 				content.WriteString(msg.Content)
@@ -275,7 +300,7 @@ func (m *Model) updateViewport() {
 
 	// Add streaming content
 	if m.streaming && m.currentChunk != "" {
-		content.WriteString(assistantStyle.Render("Assistant: "))
+		content.WriteString(assistantStyle.Render(i18n.T("tui.assistant")))
 		content.WriteString("\n")
 		rendered, err := renderer.Render(m.currentChunk)
 		if err != nil {
@@ -289,17 +314,96 @@ func (m *Model) updateViewport() {
 	m.viewport.GotoBottom()
 }
 
+// retry asks the last question again, optionally with another model
+func (m Model) retry(modelName string) (tea.Model, tea.Cmd) {
+	m.err = nil
+	m.status = ""
+	if modelName != "" {
+		modelCfg, err := m.Config.GetModelConfig(modelName)
+		if err == nil {
+			err = m.Agent.UseModel(modelCfg)
+		}
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+	}
+
+	thread := m.Agent.GetThread()
+	if thread == nil || thread.LastQuestion() == "" {
+		m.err = fmt.Errorf("no question to retry")
+		return m, nil
+	}
+
+	m.messages = append(m.messages, Message{
+		Role:    "user",
+		Content: fmt.Sprintf("%s (retry with %s)", thread.LastQuestion(), m.Agent.ModelConfig.Name),
+	})
+	m.streaming = true
+	m.currentChunk = ""
+	m.currentTool = ""
+	m.updateViewport()
+	return m, tea.Batch(spinnerTick(), m.runAgent(m.Agent.Retry))
+}
+
+// save writes the last answer and its sources to a markdown file
+func (m Model) save(pathTemplate string) (tea.Model, tea.Cmd) {
+	m.err = nil
+	m.status = ""
+
+	var answer string
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Role == "assistant" {
+			answer = m.messages[i].Content
+			break
+		}
+	}
+	if answer == "" {
+		m.err = fmt.Errorf("no answer to save")
+		return m, nil
+	}
+
+	if pathTemplate == "" {
+		pathTemplate = agent.DefaultSavePath
+	}
+	saved := agent.SavedAnswer{
+		Content:   answer,
+		Citations: m.lastCitations,
+		Model:     m.Agent.ModelConfig.Name,
+	}
+	if thread := m.Agent.GetThread(); thread != nil {
+		saved.Question = thread.LastQuestion()
+		saved.ThreadID = thread.ID
+	}
+	path, err := agent.SaveAnswer(pathTemplate, saved, false)
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	m.status = i18n.T("tui.saved", path)
+	return m, nil
+}
+
 // askQuestion sends a question to the agent
 func (m *Model) askQuestion(question string) tea.Cmd {
+	return m.runAgent(func(ctx context.Context, callback agent.StreamCallback) (*agent.Response, error) {
+		return m.Agent.AskWithCallback(ctx, question, callback)
+	})
+}
+
+// runAgent runs an agent request and reports the answer
+func (m *Model) runAgent(run func(context.Context, agent.StreamCallback) (*agent.Response, error)) tea.Cmd {
 	return func() tea.Msg {
 		ctx := context.Background()
 
-		var fullContent strings.Builder
+		var fullContent, thinking strings.Builder
 
 		callback := func(event provider.StreamEvent) {
 			switch event.Type {
 			case provider.StreamEventText:
 				fullContent.WriteString(event.Delta)
+			case provider.StreamEventThinking:
+				thinking.WriteString(event.Delta)
 			case provider.StreamEventToolCall:
 				if event.ToolCall != nil {
 					// Note: Can't send tea.Msg from here directly
@@ -310,7 +414,7 @@ func (m *Model) askQuestion(question string) tea.Cmd {
 			}
 		}
 
-		resp, err := m.Agent.AskWithCallback(ctx, question, callback)
+		resp, err := run(ctx, callback)
 		if err != nil {
 			return streamDoneMsg{err: err}
 		}
@@ -322,8 +426,22 @@ func (m *Model) askQuestion(question string) tea.Cmd {
 			content = resp.Content
 		}
 
-		return streamDoneMsg{content: content}
+		var citations []agent.Citation
+		if resp != nil {
+			citations = m.Agent.AttributedCitations(resp.ToolCalls)
+		}
+		return streamDoneMsg{content: content, thinking: thinking.String(), citations: citations}
+	}
+}
+
+// thinkingView renders a reasoning model's thinking dimmed, cut to its last
+// lines so the answer stays in view
+func thinkingView(thinking string) string {
+	lines := strings.Split(strings.TrimSpace(thinking), "\n")
+	if len(lines) > maxThinkingLines {
+		lines = append([]string{i18n.T("tui.thinkingCut", len(lines)-maxThinkingLines)}, lines[len(lines)-maxThinkingLines:]...)
 	}
+	return helpStyle.Render(i18n.T("tui.thinkingLabel")+"\n"+strings.Join(lines, "\n")) + "\n\n"
 }
 
 // resourceNames returns a comma-separated list of resource names
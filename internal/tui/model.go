@@ -5,6 +5,7 @@ import (
 	"github.com/charmbracelet/bubbles/viewport"
 	"github.com/nickcecere/btcx/internal/agent"
 	"github.com/nickcecere/btcx/internal/config"
+	"github.com/nickcecere/btcx/internal/i18n"
 	"github.com/nickcecere/btcx/internal/resource"
 )
 
@@ -36,6 +37,12 @@ type Model struct {
 	ready        bool
 	quitting     bool
 
+	// lastCitations are the sources of the last answer, for /save
+	lastCitations []agent.Citation
+
+	// status is a one-off notice shown in place of the help line
+	status string
+
 	// Spinner state
 	spinnerFrame int
 	currentTool  string
@@ -45,13 +52,16 @@ type Model struct {
 type Message struct {
 	Role    string
 	Content string
+
+	// Thinking is the reasoning model's thinking behind an answer
+	Thinking string
 }
 
 // NewModel creates a new TUI model
 func NewModel(cfg *config.Config, paths *config.Paths, collection *resource.Collection, a *agent.Agent) Model {
 	// Create textarea for input
 	ta := textarea.New()
-	ta.Placeholder = "Ask a question..."
+	ta.Placeholder = i18n.T("tui.placeholder")
 	ta.Focus()
 	ta.CharLimit = 4096
 	ta.SetWidth(80)
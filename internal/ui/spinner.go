@@ -44,6 +44,13 @@ func (s *Spinner) UpdateMessage(msg string) {
 	s.message = msg
 }
 
+// Print prints text above the spinner, which continues on the line after it
+func (s *Spinner) Print(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Print("\r\033[2K" + text)
+}
+
 func (s *Spinner) run() {
 	ticker := time.NewTicker(80 * time.Millisecond)
 	defer ticker.Stop()
@@ -58,9 +65,8 @@ func (s *Spinner) run() {
 			return
 		case <-ticker.C:
 			s.mu.Lock()
-			msg := s.message
+			fmt.Printf("\r%s %s", Highlight.Render(spinnerFrames[i]), s.message)
 			s.mu.Unlock()
-			fmt.Printf("\r%s %s", Highlight.Render(spinnerFrames[i]), msg)
 			i = (i + 1) % len(spinnerFrames)
 		}
 	}
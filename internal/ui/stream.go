@@ -0,0 +1,140 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+)
+
+// MarkdownStream prints an answer while it streams in: each markdown block
+// is rendered once the next one starts, so output appears long before the
+// answer is complete
+type MarkdownStream struct {
+	header   string
+	print    func(string)
+	renderer *glamour.TermRenderer
+
+	text    strings.Builder
+	started bool
+	// complete is the length of the text whose rendering is printed
+	complete int
+	// printed is the number of rendered lines printed
+	printed int
+}
+
+// NewMarkdownStream creates a stream printing text with print under a
+// header; markdown renders it, otherwise it is printed as it arrives
+func NewMarkdownStream(header string, markdown bool, print func(string)) *MarkdownStream {
+	s := &MarkdownStream{header: header, print: print}
+	if markdown {
+		// Created up front, as detecting the terminal's background can't
+		// share the terminal with a running spinner
+		renderer, err := glamour.NewTermRenderer(
+			glamour.WithAutoStyle(),
+			glamour.WithWordWrap(100),
+		)
+		if err == nil {
+			s.renderer = renderer
+		}
+	}
+	return s
+}
+
+// Started reports whether anything was printed
+func (s *MarkdownStream) Started() bool {
+	return s.started
+}
+
+// Write adds streamed text and prints the blocks it completes
+func (s *MarkdownStream) Write(delta string) {
+	if delta == "" {
+		return
+	}
+	s.text.WriteString(delta)
+	if s.renderer == nil {
+		s.output(delta)
+		s.complete = s.text.Len()
+		return
+	}
+
+	text := s.text.String()
+	end := blockEnd(text)
+	if end <= s.complete {
+		return
+	}
+	rendered, err := s.renderer.Render(text[:end])
+	if err != nil {
+		// Flush prints the rest as it is
+		return
+	}
+	// The lines after the last block depend on what follows it
+	lines := strings.Split(strings.TrimRight(rendered, "\n"), "\n")
+	s.printLines(lines)
+	s.complete = end
+}
+
+// Flush prints the rest of the text; it returns false if there was none
+func (s *MarkdownStream) Flush() bool {
+	text := s.text.String()
+	if strings.TrimSpace(text) == "" && !s.started {
+		return false
+	}
+	if s.renderer == nil {
+		s.output("\n")
+		return true
+	}
+
+	rendered, err := s.renderer.Render(text)
+	if err != nil {
+		s.output(text[s.complete:] + "\n")
+		return true
+	}
+	s.printLines(strings.Split(strings.TrimSuffix(rendered, "\n"), "\n"))
+	return true
+}
+
+// printLines prints the rendered lines after those already printed
+// Earlier blocks render the same once more text follows, so only new
+// lines are printed
+func (s *MarkdownStream) printLines(lines []string) {
+	if len(lines) <= s.printed {
+		return
+	}
+	s.output(strings.Join(lines[s.printed:], "\n") + "\n")
+	s.printed = len(lines)
+}
+
+// output prints text, after the header the first time
+func (s *MarkdownStream) output(text string) {
+	if !s.started {
+		s.started = true
+		text = Header.Render(s.header) + "\n\n" + text
+	}
+	s.print(text)
+}
+
+// blockEnd returns the length of the complete markdown blocks at the start
+// of text: those followed by a blank line and an unindented line outside
+// code fences (indented lines may continue a list item)
+func blockEnd(text string) int {
+	end := 0
+	fenced, blank := false, false
+	for pos := 0; ; {
+		i := strings.IndexByte(text[pos:], '\n')
+		if i < 0 {
+			// The last line may still be streaming
+			return end
+		}
+		line := text[pos : pos+i]
+		trimmed := strings.TrimSpace(line)
+
+		if !fenced && blank && trimmed != "" && line[0] != ' ' && line[0] != '\t' {
+			end = pos
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+		}
+		blank = trimmed == ""
+		pos += i + 1
+	}
+}
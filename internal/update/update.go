@@ -0,0 +1,323 @@
+// Package update checks GitHub releases for newer btcx versions and
+// replaces the running binary with a verified release build.
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Repo is the GitHub repository releases are published to
+const Repo = "ncecere/btcx"
+
+// checksumsFile is the release asset listing SHA-256 sums of the archives
+const checksumsFile = "checksums.txt"
+
+// maxDownload caps the size of downloaded release assets
+const maxDownload = 200 << 20
+
+// apiBase is the GitHub API base URL
+var apiBase = "https://api.github.com"
+
+// Release is a published GitHub release
+type Release struct {
+	TagName string  `json:"tag_name"`
+	HTMLURL string  `json:"html_url"`
+	Assets  []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Version returns the release version without the leading "v"
+func (r *Release) Version() string {
+	return strings.TrimPrefix(r.TagName, "v")
+}
+
+// asset returns the named asset
+func (r *Release) asset(name string) (*Asset, bool) {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i], true
+		}
+	}
+	return nil, false
+}
+
+// Latest returns the latest release
+func Latest(ctx context.Context) (*Release, error) {
+	return fetchRelease(ctx, "latest")
+}
+
+// Tagged returns the release with the given tag (with or without "v")
+func Tagged(ctx context.Context, tag string) (*Release, error) {
+	if !strings.HasPrefix(tag, "v") {
+		tag = "v" + tag
+	}
+	return fetchRelease(ctx, "tags/"+tag)
+}
+
+// fetchRelease queries the GitHub releases API
+func fetchRelease(ctx context.Context, which string) (*Release, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases/%s", apiBase, Repo, which)
+	resp, err := get(ctx, url, "application/vnd.github+json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check releases: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var release Release
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, fmt.Errorf("failed to decode release: %w", err)
+	}
+	return &release, nil
+}
+
+// Newer reports whether version a is newer than version b
+// Versions are compared numerically by dotted components; a development
+// build ("dev" or unparseable) is never considered newer
+func Newer(a, b string) bool {
+	pa, okA := parseVersion(a)
+	pb, okB := parseVersion(b)
+	if !okA {
+		return false
+	}
+	if !okB {
+		return true
+	}
+	for i := 0; i < 3; i++ {
+		if pa[i] != pb[i] {
+			return pa[i] > pb[i]
+		}
+	}
+	return false
+}
+
+// parseVersion parses "v1.2.3" (pre-release suffixes are ignored)
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// ArchiveName returns the release archive name for this platform
+func ArchiveName(version string) string {
+	ext := "tar.gz"
+	if runtime.GOOS == "windows" {
+		ext = "zip"
+	}
+	return fmt.Sprintf("btcx_%s_%s_%s.%s", strings.TrimPrefix(version, "v"), runtime.GOOS, runtime.GOARCH, ext)
+}
+
+// Download fetches the platform archive of the release, verifies it against
+// the release checksums and returns the btcx binary it contains
+func Download(ctx context.Context, release *Release) ([]byte, error) {
+	name := ArchiveName(release.Version())
+	archive, ok := release.asset(name)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no build for %s/%s (%s)", release.TagName, runtime.GOOS, runtime.GOARCH, name)
+	}
+	sums, ok := release.asset(checksumsFile)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s; refusing to install an unverified binary", release.TagName, checksumsFile)
+	}
+
+	sumData, err := download(ctx, sums.URL)
+	if err != nil {
+		return nil, err
+	}
+	want, err := checksumFor(sumData, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := download(ctx, archive.URL)
+	if err != nil {
+		return nil, err
+	}
+	got := sha256.Sum256(data)
+	if hex.EncodeToString(got[:]) != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, want, hex.EncodeToString(got[:]))
+	}
+
+	binary := "btcx"
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+		return extractZip(data, binary)
+	}
+	return extractTarGz(data, binary)
+}
+
+// checksumFor finds the SHA-256 sum of name in a checksums.txt file
+func checksumFor(sums []byte, name string) (string, error) {
+	for _, line := range strings.Split(string(sums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s in %s", name, checksumsFile)
+}
+
+// extractTarGz returns the named file from a .tar.gz archive
+func extractTarGz(data []byte, name string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg && filepath.Base(hdr.Name) == name {
+			return io.ReadAll(io.LimitReader(tr, maxDownload))
+		}
+	}
+	return nil, fmt.Errorf("%s not found in archive", name)
+}
+
+// extractZip returns the named file from a .zip archive
+func extractZip(data []byte, name string) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	for _, f := range zr.File {
+		if filepath.Base(f.Name) != name || f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		defer rc.Close()
+		return io.ReadAll(io.LimitReader(rc, maxDownload))
+	}
+	return nil, fmt.Errorf("%s not found in archive", name)
+}
+
+// Replace swaps the executable at exe for binary
+// The new file is written next to it and renamed into place, so a failed
+// write never leaves a partial binary. The old binary is moved aside first
+// because Windows can't overwrite a running executable
+func Replace(exe string, binary []byte) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return fmt.Errorf("failed to stat executable: %w", err)
+	}
+
+	dir := filepath.Dir(exe)
+	tmp, err := os.CreateTemp(dir, ".btcx-upgrade-*")
+	if err != nil {
+		return fmt.Errorf("failed to write new binary (is %s writable?): %w", dir, err)
+	}
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0111); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to make new binary executable: %w", err)
+	}
+
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to move old binary aside: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		// Put the old binary back
+		os.Rename(old, exe)
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to install new binary: %w", err)
+	}
+
+	// Best effort; fails on Windows while the old binary is still running
+	os.Remove(old)
+	return nil
+}
+
+// download fetches a release asset
+func download(ctx context.Context, url string) ([]byte, error) {
+	resp, err := get(ctx, url, "application/octet-stream")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", filepath.Base(url), err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", filepath.Base(url), err)
+	}
+	return data, nil
+}
+
+// get performs a GET request, using GITHUB_TOKEN if set to avoid rate limits
+func get(ctx context.Context, url, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", "btcx")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" && strings.HasPrefix(url, apiBase) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("not found")
+		}
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp, nil
+}
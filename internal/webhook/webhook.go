@@ -0,0 +1,90 @@
+// Package webhook posts completion summaries to external endpoints.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nickcecere/btcx/internal/config"
+)
+
+// DefaultTimeout is used when the webhook config does not set a timeout
+const DefaultTimeout = 10 * time.Second
+
+// Payload is the JSON body sent to the webhook after each ask
+type Payload struct {
+	Event     string    `json:"event"`
+	ThreadID  string    `json:"thread_id"`
+	Question  string    `json:"question"`
+	Answer    string    `json:"answer"`
+	Usage     Usage     `json:"usage"`
+	Model     Model     `json:"model"`
+	Resources []string  `json:"resources"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Usage is the token usage reported in the payload
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// Model identifies the model that produced the answer
+type Model struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// Send posts the payload to the configured webhook URL
+// It is a no-op if no URL is configured
+func Send(ctx context.Context, cfg config.WebhookConfig, payload *Payload) error {
+	if cfg.URL == "" {
+		return nil
+	}
+
+	if payload.Event == "" {
+		payload.Event = "ask.completed"
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	timeout := DefaultTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "btcx")
+	for key, value := range cfg.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}